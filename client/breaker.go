@@ -0,0 +1,61 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling Lambda while the breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open: function is failing")
+
+// CircuitBreaker opens after Threshold consecutive failures and lets a single
+// trial call through once Cooldown has passed. A successful trial closes it.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may proceed. A nil breaker always allows.
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.Threshold {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < b.Cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record reports the outcome of a call that Allow let through.
+func (b *CircuitBreaker) Record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+	}
+}
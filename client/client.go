@@ -0,0 +1,156 @@
+// Package client lets other Go services call this function with the typed
+// payloads from the contract package. It is, with contract, the only part of
+// the module meant to be imported from outside.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/contract"
+)
+
+//go:generate go run go.uber.org/mock/mockgen -destination=mock_invoke_test.go -package=client . InvokeAPI
+
+// InvokeAPI is the part of the Lambda client used here, so callers can pass
+// a stub in tests.
+type InvokeAPI interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+// FunctionError is returned when the handler itself failed. It is never
+// retried: the same payload would fail again.
+type FunctionError struct {
+	Type    string `json:"errorType"`
+	Message string `json:"errorMessage"`
+}
+
+func (e *FunctionError) Error() string {
+	return fmt.Sprintf("function error %s: %s", e.Type, e.Message)
+}
+
+type Client struct {
+	api          InvokeAPI
+	functionName string
+
+	// MaxAttempts bounds tries per call for throttling and service errors
+	MaxAttempts int
+	Backoff     time.Duration
+	// Breaker fails calls fast while the function keeps failing; nil disables it
+	Breaker *CircuitBreaker
+}
+
+// New returns a client for functionName (a name, alias ARN or name:alias).
+func New(api InvokeAPI, functionName string) *Client {
+	return &Client{
+		api:          api,
+		functionName: functionName,
+		MaxAttempts:  3,
+		Backoff:      100 * time.Millisecond,
+		Breaker:      NewCircuitBreaker(5, 30*time.Second),
+	}
+}
+
+// NewFromConfig builds the Lambda client from an SDK config.
+func NewFromConfig(cfg aws.Config, functionName string) *Client {
+	return New(lambda.NewFromConfig(cfg), functionName)
+}
+
+func (c *Client) Greet(ctx context.Context, request contract.GreetRequest) (contract.GreetResponse, error) {
+	var response contract.GreetResponse
+	err := c.Call(ctx, request, &response)
+	return response, err
+}
+
+// GreetAsync queues the invocation and returns once Lambda accepted it.
+func (c *Client) GreetAsync(ctx context.Context, request contract.GreetRequest) error {
+	return c.CallAsync(ctx, request)
+}
+
+// Call invokes the function synchronously with request encoded as JSON and
+// decodes the result into response.
+func (c *Client) Call(ctx context.Context, request, response interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %v", err)
+	}
+	output, err := c.invoke(ctx, payload, types.InvocationTypeRequestResponse)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.Unmarshal(output.Payload, response); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// CallAsync invokes the function with InvocationType Event. Failures after
+// Lambda accepted the event go to the function's async destinations.
+func (c *Client) CallAsync(ctx context.Context, request interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %v", err)
+	}
+	_, err = c.invoke(ctx, payload, types.InvocationTypeEvent)
+	return err
+}
+
+func (c *Client) invoke(ctx context.Context, payload []byte, invocationType types.InvocationType) (*lambda.InvokeOutput, error) {
+	if !c.Breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var output *lambda.InvokeOutput
+		output, err = c.api.Invoke(ctx, &lambda.InvokeInput{
+			FunctionName:   aws.String(c.functionName),
+			InvocationType: invocationType,
+			Payload:        payload,
+		})
+		if err == nil && output.FunctionError != nil {
+			functionErr := &FunctionError{Type: aws.ToString(output.FunctionError)}
+			json.Unmarshal(output.Payload, functionErr)
+			c.Breaker.Record(false)
+			return nil, functionErr
+		}
+		if err == nil {
+			c.Breaker.Record(true)
+			return output, nil
+		}
+		if attempt >= c.MaxAttempts || !retryable(err) {
+			break
+		}
+
+		// Exponential backoff with full jitter. The attempt before it failed,
+		// so giving up here still counts against the breaker and ends a trial.
+		wait := time.Duration(rand.Int63n(int64(c.Backoff) << (attempt - 1)))
+		select {
+		case <-ctx.Done():
+			c.Breaker.Record(false)
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	c.Breaker.Record(false)
+	return nil, err
+}
+
+func retryable(err error) bool {
+	var throttled *types.TooManyRequestsException
+	var service *types.ServiceException
+	var notReady *types.ResourceNotReadyException
+	var conflict *types.ResourceConflictException
+	return errors.As(err, &throttled) || errors.As(err, &service) || errors.As(err, &notReady) || errors.As(err, &conflict)
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"go.uber.org/mock/gomock"
+
+	"example-lambda-go/contract"
+)
+
+func newTestClient(t *testing.T) (*Client, *MockInvokeAPI) {
+	api := NewMockInvokeAPI(gomock.NewController(t))
+	c := New(api, "hello")
+	c.Backoff = time.Millisecond
+	return c, api
+}
+
+func TestGreet(t *testing.T) {
+	c, api := newTestClient(t)
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, input *lambda.InvokeInput, _ ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			if aws.ToString(input.FunctionName) != "hello" || input.InvocationType != types.InvocationTypeRequestResponse {
+				t.Errorf("Invoke(%s, %s)", aws.ToString(input.FunctionName), input.InvocationType)
+			}
+			if string(input.Payload) != `{"name":"Ada"}` {
+				t.Errorf("payload = %s", input.Payload)
+			}
+			return &lambda.InvokeOutput{Payload: []byte(`"Hello Ada!"`)}, nil
+		})
+
+	response, err := c.Greet(context.Background(), contract.GreetRequest{Name: "Ada"})
+	if err != nil || response != "Hello Ada!" {
+		t.Errorf("Greet = %q, %v", response, err)
+	}
+}
+
+func TestFunctionErrorIsDecodedAndNotRetried(t *testing.T) {
+	c, api := newTestClient(t)
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(&lambda.InvokeOutput{
+		FunctionError: aws.String("Unhandled"),
+		Payload:       []byte(`{"errorType":"errorString","errorMessage":"name is required"}`),
+	}, nil).Times(1)
+
+	err := c.Call(context.Background(), contract.GreetRequest{}, nil)
+	var functionErr *FunctionError
+	if !errors.As(err, &functionErr) {
+		t.Fatalf("err = %v, want a FunctionError", err)
+	}
+	if functionErr.Type != "errorString" || functionErr.Message != "name is required" {
+		t.Errorf("FunctionError = %+v", functionErr)
+	}
+}
+
+func TestRetryableErrorsAreRetried(t *testing.T) {
+	for _, retryErr := range []error{
+		&types.TooManyRequestsException{},
+		&types.ServiceException{},
+		&types.ResourceNotReadyException{},
+		&types.ResourceConflictException{},
+	} {
+		c, api := newTestClient(t)
+		gomock.InOrder(
+			api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(nil, retryErr),
+			api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(&lambda.InvokeOutput{Payload: []byte(`"ok"`)}, nil),
+		)
+		if err := c.Call(context.Background(), nil, nil); err != nil {
+			t.Errorf("%T: Call = %v, want success after a retry", retryErr, err)
+		}
+	}
+}
+
+func TestOtherErrorsAreNotRetried(t *testing.T) {
+	c, api := newTestClient(t)
+	invalid := &types.InvalidRequestContentException{}
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(nil, invalid).Times(1)
+
+	if err := c.Call(context.Background(), nil, nil); !errors.Is(err, invalid) {
+		t.Errorf("Call = %v, want %v", err, invalid)
+	}
+}
+
+func TestAttemptsAreBounded(t *testing.T) {
+	c, api := newTestClient(t)
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(nil, &types.TooManyRequestsException{}).Times(c.MaxAttempts)
+
+	var throttled *types.TooManyRequestsException
+	if err := c.Call(context.Background(), nil, nil); !errors.As(err, &throttled) {
+		t.Errorf("Call = %v, want the last throttling error", err)
+	}
+}
+
+func TestBreakerOpensAfterFailures(t *testing.T) {
+	c, api := newTestClient(t)
+	c.MaxAttempts = 1
+	c.Breaker = NewCircuitBreaker(2, time.Minute)
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).Return(nil, &types.ServiceException{}).Times(2)
+
+	for i := 0; i < 2; i++ {
+		c.Call(context.Background(), nil, nil)
+	}
+	if err := c.Call(context.Background(), nil, nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Call = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCancelDuringBackoffEndsTrial(t *testing.T) {
+	c, api := newTestClient(t)
+	c.Backoff = time.Hour
+	c.Breaker = NewCircuitBreaker(1, 0)
+	c.Breaker.Record(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	api.EXPECT().Invoke(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, *lambda.InvokeInput, ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+			cancel()
+			return nil, &types.ServiceException{}
+		})
+
+	if err := c.Call(ctx, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Call = %v, want context.Canceled", err)
+	}
+	// Without Record the trial would stay taken and the breaker shut for good
+	if !c.Breaker.Allow() {
+		t.Error("breaker still holds the trial after a cancelled backoff")
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+
+	"example-lambda-go/contract"
+)
+
+// SendMessageAPI is the part of the SQS client used to enqueue jobs.
+type SendMessageAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// JobQueue enqueues jobs for the function's worker (worker.queue_name).
+type JobQueue struct {
+	api      SendMessageAPI
+	queueURL string
+}
+
+func NewJobQueue(api SendMessageAPI, queueURL string) *JobQueue {
+	return &JobQueue{api: api, queueURL: queueURL}
+}
+
+type EnqueueOptions struct {
+	// DedupID drops repeated enqueues of the same job: SQS deduplicates within
+	// 5 minutes on FIFO queues, the worker skips recently completed IDs on
+	// standard queues. Without one, every enqueue is a distinct job.
+	DedupID string
+	// Delay postpones delivery, up to 15 minutes. Not supported per message
+	// on FIFO queues.
+	Delay time.Duration
+	// GroupID orders jobs on FIFO queues; defaults to the job type.
+	GroupID string
+}
+
+// Enqueue sends a job of the given type. payload must match the type the
+// worker registered for jobType.
+func Enqueue[T any](ctx context.Context, q *JobQueue, jobType string, payload T, opts EnqueueOptions) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode job payload: %v", err)
+	}
+	body, err := json.Marshal(contract.Job{Type: jobType, ID: opts.DedupID, Payload: encoded})
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %v", err)
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			contract.JobAttribute: {DataType: aws.String("String"), StringValue: aws.String(contract.JobKind)},
+		},
+	}
+	if strings.HasSuffix(q.queueURL, ".fifo") {
+		group := opts.GroupID
+		if group == "" {
+			group = jobType
+		}
+		input.MessageGroupId = aws.String(group)
+		// FIFO queues reject messages without a deduplication ID unless
+		// content-based deduplication is on, which would also merge two
+		// jobs with the same payload
+		dedupID := opts.DedupID
+		if dedupID == "" {
+			random := make([]byte, 16)
+			if _, err := rand.Read(random); err != nil {
+				return fmt.Errorf("failed to generate deduplication ID: %v", err)
+			}
+			dedupID = hex.EncodeToString(random)
+		}
+		input.MessageDeduplicationId = aws.String(dedupID)
+	} else if opts.Delay > 0 {
+		input.DelaySeconds = int32(opts.Delay / time.Second)
+	}
+
+	if _, err := q.api.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %v", jobType, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+type sentMessages []*sqs.SendMessageInput
+
+func (s *sentMessages) SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	*s = append(*s, params)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func TestEnqueueFIFOAlwaysSetsDedupID(t *testing.T) {
+	var sent sentMessages
+	q := NewJobQueue(&sent, "https://sqs.us-east-1.amazonaws.com/123/jobs.fifo")
+	for _, opts := range []EnqueueOptions{{}, {}, {DedupID: "job-1"}} {
+		if err := Enqueue(context.Background(), q, "email", "ada@example.com", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, second := aws.ToString(sent[0].MessageDeduplicationId), aws.ToString(sent[1].MessageDeduplicationId)
+	if first == "" || first == second {
+		t.Errorf("deduplication IDs without DedupID = %q, %q; want distinct IDs", first, second)
+	}
+	if got := aws.ToString(sent[2].MessageDeduplicationId); got != "job-1" {
+		t.Errorf("deduplication ID = %q, want job-1", got)
+	}
+	if got := aws.ToString(sent[0].MessageGroupId); got != "email" {
+		t.Errorf("group = %q, want the job type", got)
+	}
+}
@@ -0,0 +1,61 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: example-lambda-go/client (interfaces: InvokeAPI)
+//
+// Generated by this command:
+//
+//	mockgen -destination=mock_invoke_test.go -package=client . InvokeAPI
+//
+
+// Package client is a generated GoMock package.
+package client
+
+import (
+	context "context"
+	reflect "reflect"
+
+	lambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockInvokeAPI is a mock of InvokeAPI interface.
+type MockInvokeAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockInvokeAPIMockRecorder
+}
+
+// MockInvokeAPIMockRecorder is the mock recorder for MockInvokeAPI.
+type MockInvokeAPIMockRecorder struct {
+	mock *MockInvokeAPI
+}
+
+// NewMockInvokeAPI creates a new mock instance.
+func NewMockInvokeAPI(ctrl *gomock.Controller) *MockInvokeAPI {
+	mock := &MockInvokeAPI{ctrl: ctrl}
+	mock.recorder = &MockInvokeAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInvokeAPI) EXPECT() *MockInvokeAPIMockRecorder {
+	return m.recorder
+}
+
+// Invoke mocks base method.
+func (m *MockInvokeAPI) Invoke(arg0 context.Context, arg1 *lambda.InvokeInput, arg2 ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Invoke", varargs...)
+	ret0, _ := ret[0].(*lambda.InvokeOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Invoke indicates an expected call of Invoke.
+func (mr *MockInvokeAPIMockRecorder) Invoke(arg0, arg1 any, arg2 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invoke", reflect.TypeOf((*MockInvokeAPI)(nil).Invoke), varargs...)
+}
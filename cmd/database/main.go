@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+
+	"example-lambda-go/internal/cache"
+	"example-lambda-go/internal/database"
+	"example-lambda-go/internal/secretenv"
+)
+
+// Example handler that records a visit per name through RDS Proxy. Select it
+// with lambda.handler: database and fill in the database section of config.yaml.
+// Lookups ({"name": "...", "lookup": true}) are served from internal/cache.
+
+type Event struct {
+	Name   string `json:"name"`
+	Lookup bool   `json:"lookup"`
+}
+
+type Response struct {
+	Name   string `json:"name"`
+	Visits int    `json:"visits"`
+}
+
+var (
+	db     *sql.DB
+	engine string
+	visits *cache.Cache
+)
+
+// Lookups may lag recorded visits by up to this long
+const lookupTTL = 30 * time.Second
+
+const createTable = `CREATE TABLE IF NOT EXISTS visits (
+	name VARCHAR(255) NOT NULL,
+	visited_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+func HandleRequest(ctx context.Context, event Event) (Response, error) {
+	insert, count := "INSERT INTO visits (name) VALUES ($1)", "SELECT COUNT(*) FROM visits WHERE name = $1"
+	if engine == database.MySQL {
+		insert, count = "INSERT INTO visits (name) VALUES (?)", "SELECT COUNT(*) FROM visits WHERE name = ?"
+	}
+
+	response := Response{Name: event.Name}
+	if event.Lookup {
+		value, err := visits.Fetch(ctx, "visits:"+event.Name, lookupTTL, func(ctx context.Context) ([]byte, error) {
+			var n int
+			if err := db.QueryRowContext(ctx, count, event.Name).Scan(&n); err != nil {
+				return nil, err
+			}
+			return []byte(strconv.Itoa(n)), nil
+		})
+		if err != nil {
+			return Response{}, err
+		}
+		response.Visits, err = strconv.Atoi(string(value))
+		return response, err
+	}
+
+	if _, err := db.ExecContext(ctx, insert, event.Name); err != nil {
+		return Response{}, err
+	}
+	if err := db.QueryRowContext(ctx, count, event.Name).Scan(&response.Visits); err != nil {
+		return Response{}, err
+	}
+	return response, nil
+}
+
+func main() {
+	awsCfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	// Replace secretsmanager: references in lambda.environment with the values
+	if err := secretenv.Resolve(context.Background(), secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
+
+	dbCfg := database.ConfigFromEnv()
+	if !dbCfg.Enabled() {
+		log.Fatal("DB_HOST is not set; configure the database section in config.yaml")
+	}
+	engine = dbCfg.Engine
+
+	// Opened once per cold start so warm invocations reuse the connection
+	db, err = database.Open(dbCfg, awsCfg)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := db.ExecContext(context.Background(), createTable); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	visits = cache.NewFromEnv()
+
+	lambda.Start(HandleRequest)
+}
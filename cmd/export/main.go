@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+	gluetypes "github.com/aws/aws-sdk-go-v2/service/glue/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/parquet-go/parquet-go"
+
+	"example-lambda-go/internal/egress"
+	"example-lambda-go/internal/secretenv"
+)
+
+// Record is the row written to the Parquet file. Replace it with the shape of
+// the data your export produces; the parquet tags define the column names.
+type Record struct {
+	ID         string    `json:"id" dynamodbav:"id" parquet:"id"`
+	Name       string    `json:"name" dynamodbav:"name" parquet:"name,optional"`
+	Amount     int64     `json:"amount" dynamodbav:"amount" parquet:"amount,optional"`
+	UpdatedAt  string    `json:"updated_at" dynamodbav:"updated_at" parquet:"updated_at,optional"`
+	ExportedAt time.Time `json:"-" dynamodbav:"-" parquet:"exported_at,timestamp"`
+}
+
+// ExportConfig is read from the environment variables that setup and deploy
+// derive from the export section of config.yaml.
+type ExportConfig struct {
+	SourceType   string
+	SourceTable  string
+	SourceURL    string
+	Bucket       string
+	Prefix       string
+	GlueDatabase string
+	GlueTable    string
+}
+
+type Source interface {
+	Fetch(ctx context.Context) ([]Record, error)
+}
+
+var (
+	exportConfig ExportConfig
+	source       Source
+	s3Client     *s3.Client
+	glueClient   *glue.Client
+)
+
+func loadExportConfig() (ExportConfig, error) {
+	cfg := ExportConfig{
+		SourceType:   os.Getenv("EXPORT_SOURCE_TYPE"),
+		SourceTable:  os.Getenv("EXPORT_SOURCE_TABLE"),
+		SourceURL:    os.Getenv("EXPORT_SOURCE_URL"),
+		Bucket:       os.Getenv("EXPORT_BUCKET"),
+		Prefix:       os.Getenv("EXPORT_PREFIX"),
+		GlueDatabase: os.Getenv("EXPORT_GLUE_DATABASE"),
+		GlueTable:    os.Getenv("EXPORT_GLUE_TABLE"),
+	}
+	if cfg.Bucket == "" {
+		return cfg, errors.New("EXPORT_BUCKET is required")
+	}
+	return cfg, nil
+}
+
+func HandleRequest(ctx context.Context, event events.CloudWatchEvent) (string, error) {
+	// Partition by the scheduled time so retries of the same run overwrite the same object
+	runTime := event.Time
+	if runTime.IsZero() {
+		runTime = time.Now()
+	}
+	runTime = runTime.UTC()
+
+	records, err := source.Fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error fetching records: %v", err)
+	}
+	for i := range records {
+		records[i].ExportedAt = runTime
+	}
+
+	data, err := encodeParquet(records)
+	if err != nil {
+		return "", fmt.Errorf("error encoding Parquet: %v", err)
+	}
+
+	partition := runTime.Format("2006-01-02")
+	partitionPrefix := path.Join(exportConfig.Prefix, "dt="+partition)
+	key := path.Join(partitionPrefix, fmt.Sprintf("part-%s.parquet", runTime.Format("150405")))
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(exportConfig.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/vnd.apache.parquet"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error uploading s3://%s/%s: %v", exportConfig.Bucket, key, err)
+	}
+
+	if exportConfig.GlueDatabase != "" && exportConfig.GlueTable != "" {
+		location := fmt.Sprintf("s3://%s/%s/", exportConfig.Bucket, partitionPrefix)
+		if err := registerPartition(ctx, partition, location); err != nil {
+			return "", fmt.Errorf("error registering Glue partition: %v", err)
+		}
+	}
+
+	return fmt.Sprintf("Exported %d records to s3://%s/%s", len(records), exportConfig.Bucket, key), nil
+}
+
+func encodeParquet(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := parquet.NewGenericWriter[Record](&buf, parquet.Compression(&parquet.Snappy))
+	if _, err := writer.Write(records); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// registerPartition adds the dt=<partition> partition to the Glue table, reusing
+// the table's storage descriptor so the partition inherits its format and SerDe.
+func registerPartition(ctx context.Context, partition, location string) error {
+	table, err := glueClient.GetTable(ctx, &glue.GetTableInput{
+		DatabaseName: aws.String(exportConfig.GlueDatabase),
+		Name:         aws.String(exportConfig.GlueTable),
+	})
+	if err != nil {
+		return err
+	}
+
+	descriptor := gluetypes.StorageDescriptor{}
+	if table.Table.StorageDescriptor != nil {
+		descriptor = *table.Table.StorageDescriptor
+	}
+	descriptor.Location = aws.String(location)
+
+	_, err = glueClient.CreatePartition(ctx, &glue.CreatePartitionInput{
+		DatabaseName: aws.String(exportConfig.GlueDatabase),
+		TableName:    aws.String(exportConfig.GlueTable),
+		PartitionInput: &gluetypes.PartitionInput{
+			Values:            []string{partition},
+			StorageDescriptor: &descriptor,
+		},
+	})
+	var exists *gluetypes.AlreadyExistsException
+	if errors.As(err, &exists) {
+		return nil
+	}
+	return err
+}
+
+func main() {
+	var err error
+	exportConfig, err = loadExportConfig()
+	if err != nil {
+		log.Fatalf("Invalid export configuration: %v", err)
+	}
+
+	// Restrict outbound connections when egress.allow is configured
+	guard, err := egress.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid egress allow list: %v", err)
+	}
+	if guard != nil {
+		egress.Install(guard)
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), config.WithHTTPClient(egress.HTTPClient()))
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	// Replace secretsmanager: references in lambda.environment with the values
+	if err := secretenv.Resolve(context.Background(), secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
+	s3Client = s3.NewFromConfig(awsCfg)
+	glueClient = glue.NewFromConfig(awsCfg)
+
+	switch exportConfig.SourceType {
+	case "dynamodb":
+		source = &DynamoDBSource{Client: dynamodb.NewFromConfig(awsCfg), Table: exportConfig.SourceTable}
+	case "http":
+		source = &HTTPSource{URL: exportConfig.SourceURL}
+	default:
+		log.Fatalf("Unsupported export source type %q (expected dynamodb or http)", exportConfig.SourceType)
+	}
+
+	lambda.Start(HandleRequest)
+}
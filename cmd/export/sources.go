@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"example-lambda-go/internal/httpclient"
+)
+
+// DynamoDBSource scans the whole table. Swap the Scan for a Query on a
+// date-keyed index when the table is large enough for full scans to hurt.
+type DynamoDBSource struct {
+	Client *dynamodb.Client
+	Table  string
+}
+
+func (s *DynamoDBSource) Fetch(ctx context.Context) ([]Record, error) {
+	var records []Record
+	paginator := dynamodb.NewScanPaginator(s.Client, &dynamodb.ScanInput{
+		TableName: aws.String(s.Table),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan table %s: %v", s.Table, err)
+		}
+		var batch []Record
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &batch); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items: %v", err)
+		}
+		records = append(records, batch...)
+	}
+	return records, nil
+}
+
+// Shared across invocations so connections to the source are reused
+var httpClient = httpclient.New(httpclient.Config{Timeout: 30 * time.Second})
+
+// HTTPSource fetches a JSON array of records from an HTTP endpoint.
+type HTTPSource struct {
+	URL string
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context) ([]Record, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %v", s.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", s.URL, resp.Status)
+	}
+
+	var records []Record
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %v", s.URL, err)
+	}
+	return records, nil
+}
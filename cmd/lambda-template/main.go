@@ -0,0 +1,13 @@
+// Command lambda-template sets up, deploys and operates the function
+// described by config.yaml; run it without arguments for the subcommands.
+package main
+
+import (
+	"os"
+
+	"example-lambda-go/internal/cli"
+)
+
+func main() {
+	cli.Main(os.Args[1:])
+}
@@ -2,22 +2,180 @@ package main
 
 import (
 	"context"
-	"fmt"
+	_ "embed"
+	"log"
+	"net/http"
+	"strings"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-xray-sdk-go/instrumentation/awsv2"
+	"github.com/aws/aws-xray-sdk-go/strategy/ctxmissing"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	"example-lambda-go/contract"
+	"example-lambda-go/internal/cache"
+	"example-lambda-go/internal/digest"
+	"example-lambda-go/internal/dynconfig"
+	"example-lambda-go/internal/egress"
+	"example-lambda-go/internal/envelope"
+	"example-lambda-go/internal/errreport"
+	"example-lambda-go/internal/events"
+	"example-lambda-go/internal/health"
+	"example-lambda-go/internal/httpadapter"
+	"example-lambda-go/internal/httpclient"
+	"example-lambda-go/internal/introspect"
+	"example-lambda-go/internal/maintenance"
+	"example-lambda-go/internal/middleware"
+	"example-lambda-go/internal/router"
+	"example-lambda-go/internal/secretenv"
+	"example-lambda-go/internal/shadow"
+	"example-lambda-go/internal/snapshot"
+	"example-lambda-go/internal/tenant"
+	"example-lambda-go/internal/worker"
+)
+
+//go:embed routes.yaml
+var routesFile []byte
+
+// Event is the invoke payload; its shape is the public contract consumers
+// import through the client package.
+type Event = contract.GreetRequest
+
+var (
+	publisher *events.Publisher
+	dynamic   *dynconfig.Store
 )
 
-type Event struct {
-	Name string `json:"name"`
+func HandleRequest(ctx context.Context, event Event) (contract.GreetResponse, error) {
+	greeting := greet(ctx, event.Name)
+
+	// A shadow run must not repeat the primary's side effects
+	if publisher != nil && !shadow.Active(ctx) {
+		if err := publisher.Publish(ctx, events.GreetingSent{Name: event.Name, Greeting: greeting}); err != nil {
+			return "", err
+		}
+	}
+	return contract.GreetResponse(greeting), nil
+}
+
+// ServeGreeting answers GET /?name=... in HTTP mode.
+func ServeGreeting(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(greet(r.Context(), r.URL.Query().Get("name"))))
 }
 
-func HandleRequest(ctx context.Context, event Event) (string, error) {
-	if event.Name != "" {
-		return fmt.Sprintf("Hello, %s!", event.Name), nil
+func greet(ctx context.Context, name string) string {
+	if name == "" {
+		name = "World"
+	}
+	template := "Hello, {name}!"
+	if dynamic != nil {
+		template = dynamic.String(ctx, "greeting.template", template)
 	}
-	return "Hello, World!", nil
+	return strings.ReplaceAll(template, "{name}", name)
 }
 
 func main() {
-	lambda.Start(HandleRequest)
+	// Restrict outbound connections when egress.allow is configured
+	guard, err := egress.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid egress allow list: %v", err)
+	}
+	if guard != nil {
+		egress.Install(guard)
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), config.WithHTTPClient(egress.HTTPClient()))
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	// With lambda.tracing: Active, every AWS call shows up in X-Ray under the
+	// invocation. Calls during init have no segment to join, which is not
+	// worth an error in the logs unless AWS_XRAY_CONTEXT_MISSING asks for one
+	xray.Configure(xray.Config{ContextMissingStrategy: ctxmissing.NewDefaultIgnoreErrorStrategy()})
+	awsv2.AWSV2Instrumentor(&awsCfg.APIOptions)
+
+	// Replace secretsmanager: references in lambda.environment with the values
+	if err := secretenv.Resolve(context.Background(), secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		log.Fatalf("Failed to resolve secrets: %v", err)
+	}
+	publisher = events.NewPublisherFromEnv(eventbridge.NewFromConfig(awsCfg))
+
+	dynamic = dynconfig.NewFromEnv(ssm.NewFromConfig(awsCfg))
+	if dynamic != nil {
+		if err := dynamic.Load(context.Background()); err != nil {
+			log.Fatalf("Failed to load dynamic configuration: %v", err)
+		}
+	}
+
+	routes, err := router.LoadRoutes(routesFile)
+	if err != nil {
+		log.Fatalf("Failed to load routes: %v", err)
+	}
+	checks := health.New()
+	if dynamic != nil {
+		checks.Add(health.CheckerFunc("dynconfig", dynamic.Load))
+	}
+	mux := http.NewServeMux()
+	checks.Register(mux)
+	mux.HandleFunc("/", ServeGreeting)
+
+	r := router.New(routes)
+	r.Register("greet", lambda.NewHandler(HandleRequest))
+	r.Register("http", httpadapter.New(mux))
+	r.Register("digest", digest.Handler(digest.ConfigFromEnv(), cloudwatch.NewFromConfig(awsCfg), sns.NewFromConfig(awsCfg), httpclient.New(httpclient.Config{})))
+	r.Register("snapshot", snapshot.Handler(snapshot.ConfigFromEnv(), snapshot.Clients{
+		Lambda: awslambda.NewFromConfig(awsCfg),
+		Events: eventbridge.NewFromConfig(awsCfg),
+	}, s3.NewFromConfig(awsCfg)))
+
+	// Background jobs enqueued with client.Enqueue arrive through the worker queue
+	jobs := worker.New(sqs.NewFromConfig(awsCfg), worker.PolicyFromEnv(), cache.NewFromEnv())
+	worker.Handle(jobs, "send-greeting", func(ctx context.Context, job contract.GreetRequest) error {
+		_, err := HandleRequest(ctx, job)
+		return err
+	})
+	r.Register("jobs", jobs)
+	if err := r.Validate(); err != nil {
+		log.Fatalf("Invalid routes: %v", err)
+	}
+
+	// Current versions of the enveloped events this function accepts. When an
+	// event shape changes, bump its version and register an upcaster from the
+	// old one, e.g. registry.Upcast("greet", 1, envelope.RenameField("who", "name")).
+	registry := envelope.NewRegistry()
+	registry.Register("greet", 1)
+
+	// What `lambda-template status -introspect` reports of this build
+	manifest := introspect.New(r)
+	manifest.Jobs = jobs.JobTypes()
+	manifest.Events = registry.Versions()
+
+	middlewares := []middleware.Middleware{introspect.Middleware(manifest)}
+	if reportCfg := errreport.ConfigFromEnv(); reportCfg.DSN != "" {
+		reporter, err := errreport.NewReporter(reportCfg.DSN)
+		if err != nil {
+			log.Fatalf("Failed to configure error reporting: %v", err)
+		}
+		middlewares = append(middlewares, errreport.Middleware(reportCfg, reporter))
+	}
+	// Shadowing sits outside maintenance and the envelope so both sides see the same raw event
+	middlewares = append(middlewares, shadow.Middleware(shadow.ConfigFromEnv(), awslambda.NewFromConfig(awsCfg)))
+	middlewares = append(middlewares, maintenance.Middleware(), envelope.Middleware(registry))
+	if tenantCfg := tenant.ConfigFromEnv(); tenantCfg.Enabled() {
+		middlewares = append(middlewares, tenant.Middleware(tenantCfg))
+	}
+	handler := middleware.Chain(r, middlewares...)
+
+	lambda.Start(handler)
 }
@@ -0,0 +1,26 @@
+// Package contract holds the request and response types of the handler's
+// invoke API. cmd/lambda decodes into these types and the client package
+// encodes from them, so consumers importing client can't drift from the
+// payload shape the function actually accepts.
+package contract
+
+// GreetRequest is the payload of a direct (non-HTTP) invocation.
+type GreetRequest struct {
+	Name string `json:"name"`
+}
+
+// GreetResponse is the greeting returned for a GreetRequest, encoded as a
+// JSON string.
+type GreetResponse string
+
+// Operation pairs the request and response types of one kind of invocation.
+// `lambda-template contract` derives the published JSON Schemas from this list.
+type Operation struct {
+	Name     string
+	Request  interface{}
+	Response interface{}
+}
+
+var Operations = []Operation{
+	{Name: "greet", Request: GreetRequest{}, Response: GreetResponse("")},
+}
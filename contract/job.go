@@ -0,0 +1,18 @@
+package contract
+
+import "encoding/json"
+
+// JobAttribute is the SQS message attribute that marks a message as a job, so
+// the function's router can send it to the worker.
+const (
+	JobAttribute = "kind"
+	JobKind      = "job"
+)
+
+// Job is the body of a background job message on the worker queue.
+type Job struct {
+	Type string `json:"type"`
+	// ID identifies the job for deduplication; empty disables it
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
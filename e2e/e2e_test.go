@@ -0,0 +1,238 @@
+//go:build e2e
+
+// Package e2e runs setup, deploy, execute and delete against LocalStack and
+// checks the resources they leave behind. Run it with
+//
+//	LOCALSTACK_AUTH_TOKEN=... go test -tags e2e -timeout 30m ./e2e
+//
+// It needs Docker on the PATH; with this config.yaml the commands reach AWS
+// only through the SDKs. Container image functions and ECR are LocalStack Pro
+// features, hence the auth token; without one the test is skipped.
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/localstack"
+)
+
+const (
+	image        = "localstack/localstack-pro:3.5"
+	region       = "us-east-1"
+	functionName = "e2e-hello"
+	repository   = "e2e-hello"
+)
+
+const configYAML = `aws:
+  region: ` + region + `
+  profile: localstack
+lambda:
+  function_name: ` + functionName + `
+  role_name: e2e-hello-role
+  timeout: 30
+  memory_size: 128
+ecr:
+  repository_name: ` + repository + `
+`
+
+func TestSetupDeployExecuteDelete(t *testing.T) {
+	token := os.Getenv("LOCALSTACK_AUTH_TOKEN")
+	if token == "" {
+		t.Skip("LOCALSTACK_AUTH_TOKEN is not set; ECR and image functions need LocalStack Pro")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not on the PATH")
+	}
+
+	ctx := context.Background()
+	container, err := localstack.Run(ctx, image, testcontainers.WithEnv(map[string]string{
+		"LOCALSTACK_AUTH_TOKEN": token,
+		"SERVICES":              "iam,sts,ecr,lambda,events,logs",
+	}))
+	if err != nil {
+		t.Fatalf("starting LocalStack: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate(ctx) })
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := container.MappedPort(ctx, "4566/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := fmt.Sprintf("http://%s:%s", host, port.Port())
+
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := buildCommand(t, root)
+	work := copyRepository(t, root)
+	env := awsEnvironment(t, endpoint)
+
+	run(t, work, env, "", bin, "setup")
+	lambdaClient, ecrClient := clients(t, endpoint)
+	function, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		t.Fatalf("function after setup: %v", err)
+	}
+	if function.Configuration.PackageType != lambdatypes.PackageTypeImage {
+		t.Errorf("package type = %s, want Image", function.Configuration.PackageType)
+	}
+	if !strings.Contains(aws.ToString(function.Code.ImageUri), repository+":latest") {
+		t.Errorf("image = %s, want the %s repository", aws.ToString(function.Code.ImageUri), repository)
+	}
+
+	run(t, work, env, "", bin, "deploy", "-skip-contract-check")
+	function, err = lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		t.Fatalf("function after deploy: %v", err)
+	}
+	if function.Configuration.LastUpdateStatus != lambdatypes.LastUpdateStatusSuccessful {
+		t.Errorf("last update status = %s, want Successful", function.Configuration.LastUpdateStatus)
+	}
+	if aws.ToInt32(function.Configuration.Timeout) != 30 || aws.ToInt32(function.Configuration.MemorySize) != 128 {
+		t.Errorf("timeout, memory = %d, %d; want the values from config.yaml", aws.ToInt32(function.Configuration.Timeout), aws.ToInt32(function.Configuration.MemorySize))
+	}
+
+	output := run(t, work, env, "", bin, "invoke", "-name", "e2e")
+	if !strings.Contains(output, "Hello, e2e!") {
+		t.Errorf("invoke output:\n%s\nwant the greeting", output)
+	}
+
+	run(t, work, env, "y\n", bin, "delete")
+	var notFound *lambdatypes.ResourceNotFoundException
+	if _, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)}); !errors.As(err, &notFound) {
+		t.Errorf("function after delete: %v, want ResourceNotFoundException", err)
+	}
+	var repoNotFound *ecrtypes.RepositoryNotFoundException
+	if _, err := ecrClient.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{RepositoryNames: []string{repository}}); !errors.As(err, &repoNotFound) {
+		t.Errorf("repository after delete: %v, want RepositoryNotFoundException", err)
+	}
+}
+
+func exe(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// buildCommand builds lambda-template once so the test runs the same binary
+// users do.
+func buildCommand(t *testing.T, root string) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), exe("lambda-template"))
+	cmd := exec.Command("go", "build", "-o", bin, "./cmd/lambda-template")
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building lambda-template: %v\n%s", err, output)
+	}
+	return bin
+}
+
+// copyRepository copies the sources (docker build needs them) into a
+// scratch directory with its own config.yaml, leaving the checkout alone.
+func copyRepository(t *testing.T, root string) string {
+	t.Helper()
+	work := t.TempDir()
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && (entry.Name() == ".git" || entry.Name() == ".lambda-template") {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(work, rel), 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(work, rel), data, 0o644)
+	})
+	if err != nil {
+		t.Fatalf("copying the repository: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "config.yaml"), []byte(configYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return work
+}
+
+// awsEnvironment points the SDKs at LocalStack through a
+// throwaway "localstack" profile.
+func awsEnvironment(t *testing.T, endpoint string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(configFile, []byte("[profile localstack]\nregion = "+region+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsFile, []byte("[localstack]\naws_access_key_id = test\naws_secret_access_key = test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "AWS_") && !strings.HasPrefix(kv, "LAMBDA_EXECUTION_ROLE_ARN=") {
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		"AWS_ENDPOINT_URL="+endpoint,
+		"AWS_CONFIG_FILE="+configFile,
+		"AWS_SHARED_CREDENTIALS_FILE="+credentialsFile,
+	)
+}
+
+func clients(t *testing.T, endpoint string) (*lambda.Client, *ecr.Client) {
+	t.Helper()
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithRegion(region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "test", "")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BaseEndpoint = aws.String(endpoint)
+	return lambda.NewFromConfig(cfg), ecr.NewFromConfig(cfg)
+}
+
+func run(t *testing.T, dir string, env []string, stdin, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(stdin)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", filepath.Base(name), strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
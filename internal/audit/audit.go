@@ -0,0 +1,51 @@
+// Package audit records operational actions taken against a function, such
+// as throttling it, as JSON lines in a local file so there is a trail of who
+// did what during an incident.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPath is used when audit.file is not set in config.yaml.
+var DefaultPath = filepath.Join(".lambda-template", "audit.log")
+
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is the caller's ARN as reported by STS
+	Actor    string `json:"actor"`
+	Action   string `json:"action"`
+	Function string `json:"function"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Record appends the entry to the log at path, creating it if needed.
+func Record(path string, entry Entry) error {
+	if path == "" {
+		path = DefaultPath
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating audit log directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %v", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing audit log: %v", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,83 @@
+// Package cache caches byte values with a TTL, either in the memory of the
+// execution environment or in ElastiCache (Redis). Fetch coalesces
+// concurrent misses for the same key into a single load, so a cold key
+// doesn't stampede the backing service.
+package cache
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"example-lambda-go/internal/metrics"
+)
+
+const metricsNamespace = "LambdaTemplate/Cache"
+
+// Backend stores values. Get returns ok=false on a miss or expired entry.
+type Backend interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+type Cache struct {
+	backend Backend
+	group   singleflight.Group
+}
+
+func New(backend Backend) *Cache {
+	return &Cache{backend: backend}
+}
+
+// NewFromEnv picks the backend from the CACHE_* variables set by setup and
+// deploy: redis when CACHE_ADDRESS is set, in-memory otherwise.
+func NewFromEnv() *Cache {
+	address := os.Getenv("CACHE_ADDRESS")
+	if address == "" || os.Getenv("CACHE_BACKEND") == "memory" {
+		maxEntries, _ := strconv.Atoi(os.Getenv("CACHE_MAX_ENTRIES"))
+		return New(NewMemory(maxEntries))
+	}
+	useTLS := os.Getenv("CACHE_TLS") != "false"
+	return New(NewRedis(address, useTLS))
+}
+
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return c.backend.Get(ctx, key)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.backend.Set(ctx, key, value, ttl)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	return c.backend.Delete(ctx, key)
+}
+
+// Fetch returns the cached value for key, or calls load and caches its
+// result for ttl. Concurrent misses for the same key share one load call.
+// Backend errors are treated as misses so an unavailable cache degrades to
+// calling load rather than failing the request.
+func (c *Cache) Fetch(ctx context.Context, key string, ttl time.Duration, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if value, ok, err := c.backend.Get(ctx, key); err == nil && ok {
+		metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "Hits", Value: 1, Unit: metrics.Count})
+		return value, nil
+	}
+	metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "Misses", Value: 1, Unit: metrics.Count})
+
+	value, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.backend.Set(ctx, key, value, ttl)
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
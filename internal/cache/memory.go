@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultMaxEntries = 10000
+
+// Memory keeps entries in the execution environment. Each environment has its
+// own copy, so it suits data that tolerates being stale for the TTL.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory returns an in-memory backend holding at most maxEntries values
+// (10000 if maxEntries is zero).
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	return &Memory{maxEntries: maxEntries, entries: map[string]memoryEntry{}}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= m.maxEntries {
+		m.evict()
+	}
+	m.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// evict drops expired entries, or an arbitrary one if none have expired.
+func (m *Memory) evict() {
+	now := time.Now()
+	for key, entry := range m.entries {
+		if now.After(entry.expires) {
+			delete(m.entries, key)
+		}
+	}
+	if len(m.entries) < m.maxEntries {
+		return
+	}
+	for key := range m.entries {
+		delete(m.entries, key)
+		return
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis stores entries in ElastiCache (or any Redis). The function must run
+// in a VPC subnet that can reach the cluster; see vpc and cache in config.yaml.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to address (host:port). Enable useTLS for clusters with
+// in-transit encryption, which ElastiCache Serverless always has.
+func NewRedis(address string, useTLS bool) *Redis {
+	options := &redis.Options{
+		Addr:         address,
+		DialTimeout:  2 * time.Second,
+		ReadTimeout:  500 * time.Millisecond,
+		WriteTimeout: 500 * time.Millisecond,
+		// A handful of connections per execution environment is enough
+		PoolSize: 4,
+	}
+	if useTLS {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return &Redis{client: redis.NewClient(options)}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
@@ -0,0 +1,268 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/snapshot"
+)
+
+// defaultSchedule takes a snapshot every day at 03:00 UTC.
+const defaultSchedule = "cron(0 3 * * ? *)"
+
+// Main snapshots the function to backup.bucket, lists the snapshots, or
+// schedules the function to snapshot itself.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template backup", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template backup [list|schedule|unschedule]")
+		fmt.Fprintln(os.Stderr, "Without an action, stores a snapshot of the function's configuration, environment,")
+		fmt.Fprintln(os.Stderr, "resource policy, triggers, aliases and image digest in backup.bucket as a new object;")
+		fmt.Fprintln(os.Stderr, "`lambda-template restore` recreates the function from one. list prints the stored")
+		fmt.Fprintln(os.Stderr, "snapshots, schedule has the function take one on backup.schedule, and unschedule stops that.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	action := flags.Arg(0)
+	switch action {
+	case "", "list", "schedule", "unschedule":
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Backup.Bucket == "" && action != "unschedule" {
+		log.Fatal("backup.bucket must be set in config.yaml")
+	}
+
+	// Load AWS configuration
+	ctx := context.TODO()
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	store := s3.NewFromConfig(awsCfg)
+
+	switch action {
+	case "list":
+		for _, function := range cfg.DeployedFunctions() {
+			keys, err := snapshot.List(ctx, store, cfg.Backup.Bucket, cfg.BackupPrefix(), function)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(keys) == 0 {
+				fmt.Printf("No snapshots of %s in s3://%s/%s\n", function, cfg.Backup.Bucket, cfg.BackupPrefix())
+			}
+			for _, key := range keys {
+				fmt.Println(key)
+			}
+		}
+		return
+	case "schedule":
+		if err := schedule(ctx, awsCfg, cfg); err != nil {
+			log.Fatalf("Error scheduling snapshots: %v", err)
+		}
+		return
+	case "unschedule":
+		if err := unschedule(ctx, eventbridge.NewFromConfig(awsCfg), cfg); err != nil {
+			log.Fatalf("Error removing the snapshot schedule: %v", err)
+		}
+		return
+	}
+
+	clients := snapshot.Clients{Lambda: lambda.NewFromConfig(awsCfg), Events: eventbridge.NewFromConfig(awsCfg)}
+	for _, function := range cfg.DeployedFunctions() {
+		s, err := snapshot.Take(ctx, clients, function, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		key, err := snapshot.Save(ctx, store, cfg.Backup.Bucket, cfg.BackupPrefix(), s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Snapshot of %s (image %s) stored in s3://%s/%s\n", function, s.ImageDigest, cfg.Backup.Bucket, key)
+	}
+}
+
+func ruleName(cfg *config.Config) string {
+	return cfg.Lambda.FunctionName + "-backup"
+}
+
+// schedule lets the function read its own definition and write to the
+// bucket, gives it the BACKUP_* variables, and has EventBridge invoke it
+// with the snapshot event on backup.schedule.
+func schedule(ctx context.Context, awsCfg aws.Config, cfg *config.Config) error {
+	if err := putSnapshotPolicy(ctx, iam.NewFromConfig(awsCfg), cfg); err != nil {
+		return err
+	}
+
+	lambdaClient := lambda.NewFromConfig(awsCfg)
+	function, err := setBackupEnvironment(ctx, lambdaClient, cfg)
+	if err != nil {
+		return err
+	}
+
+	expression := cfg.Backup.Schedule
+	if expression == "" {
+		expression = defaultSchedule
+	}
+	var tags []ebtypes.Tag
+	for key, value := range cfg.ResourceTags(time.Now()) {
+		tags = append(tags, ebtypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	events := eventbridge.NewFromConfig(awsCfg)
+	rule, err := events.PutRule(ctx, &eventbridge.PutRuleInput{
+		Name:               aws.String(ruleName(cfg)),
+		ScheduleExpression: aws.String(expression),
+		Description:        aws.String("Configuration snapshot of " + cfg.Lambda.FunctionName),
+		Tags:               tags,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating schedule rule: %v", err)
+	}
+
+	_, err = lambdaClient.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+		StatementId:  aws.String(ruleName(cfg)),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    rule.RuleArn,
+	})
+	var conflict *lambdatypes.ResourceConflictException
+	if err != nil && !errors.As(err, &conflict) {
+		return fmt.Errorf("error adding invoke permission: %v", err)
+	}
+
+	input, err := json.Marshal(map[string]string{"source": "lambda-template", "detail-type": snapshot.DetailType})
+	if err != nil {
+		return err
+	}
+	_, err = events.PutTargets(ctx, &eventbridge.PutTargetsInput{
+		Rule: aws.String(ruleName(cfg)),
+		Targets: []ebtypes.Target{{
+			Id:    aws.String(cfg.Lambda.FunctionName),
+			Arn:   function.FunctionArn,
+			Input: aws.String(string(input)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error adding schedule target: %v", err)
+	}
+
+	fmt.Printf("Snapshots scheduled with %s\n", expression)
+	return nil
+}
+
+func putSnapshotPolicy(ctx context.Context, client *iam.Client, cfg *config.Config) error {
+	var functions []string
+	for _, function := range cfg.DeployedFunctions() {
+		arn := cfg.Partition().ARN("lambda", cfg.AWS.Region, "*", "function:"+function)
+		functions = append(functions, arn, arn+":*")
+	}
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"lambda:GetFunction", "lambda:GetPolicy", "lambda:ListAliases", "lambda:GetFunctionUrlConfig"},
+				"Resource": functions,
+			},
+			{
+				"Effect": "Allow",
+				// None of these support resource-level permissions for a target
+				"Action":   []string{"lambda:ListEventSourceMappings", "events:ListRuleNamesByTarget", "events:DescribeRule", "events:ListTargetsByRule"},
+				"Resource": "*",
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:PutObject"},
+				"Resource": cfg.Partition().ARN("s3", "", "", cfg.Backup.Bucket+"/"+cfg.BackupPrefix()+"*"),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot policy: %v", err)
+	}
+
+	_, err = client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(cfg.Lambda.RoleName),
+		PolicyName:     aws.String("backup-snapshot"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting snapshot policy: %v", err)
+	}
+	fmt.Println("Snapshot policy attached to Lambda execution role")
+	return nil
+}
+
+// setBackupEnvironment merges the BACKUP_* variables into the function's
+// environment, the same ones deploy sets from the backup section, so the
+// schedule works before the next deploy.
+func setBackupEnvironment(ctx context.Context, client *lambda.Client, cfg *config.Config) (*lambda.GetFunctionConfigurationOutput, error) {
+	function, err := client.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting function configuration: %v", err)
+	}
+	variables := map[string]string{}
+	if function.Environment != nil {
+		for k, v := range function.Environment.Variables {
+			variables[k] = v
+		}
+	}
+	variables["BACKUP_BUCKET"] = cfg.Backup.Bucket
+	variables["BACKUP_PREFIX"] = cfg.BackupPrefix()
+	variables["BACKUP_FUNCTIONS"] = strings.Join(cfg.DeployedFunctions(), ",")
+
+	_, err = client.UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+		Environment:  &lambdatypes.Environment{Variables: variables},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating function configuration: %v", err)
+	}
+	return function, nil
+}
+
+func unschedule(ctx context.Context, events *eventbridge.Client, cfg *config.Config) error {
+	_, err := events.RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{
+		Rule: aws.String(ruleName(cfg)),
+		Ids:  []string{cfg.Lambda.FunctionName},
+	})
+	var notFound *ebtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		fmt.Println("No snapshots are scheduled")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error removing schedule target: %v", err)
+	}
+	if _, err := events.DeleteRule(ctx, &eventbridge.DeleteRuleInput{Name: aws.String(ruleName(cfg))}); err != nil {
+		return fmt.Errorf("error deleting schedule rule: %v", err)
+	}
+	fmt.Println("Snapshot schedule removed")
+	return nil
+}
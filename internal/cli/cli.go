@@ -0,0 +1,244 @@
+// Package cli is the lambda-template command: the global flags shared by
+// every subcommand, and the table that dispatches to them. Each subcommand
+// lives in its own package under internal/cli.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"example-lambda-go/internal/cli/backup"
+	"example-lambda-go/internal/cli/compare"
+	"example-lambda-go/internal/cli/contract"
+	"example-lambda-go/internal/cli/delete"
+	"example-lambda-go/internal/cli/deploy"
+	"example-lambda-go/internal/cli/docs"
+	"example-lambda-go/internal/cli/drift"
+	"example-lambda-go/internal/cli/dynconfig"
+	"example-lambda-go/internal/cli/esm"
+	"example-lambda-go/internal/cli/history"
+	"example-lambda-go/internal/cli/invoke"
+	"example-lambda-go/internal/cli/logs"
+	"example-lambda-go/internal/cli/maintenance"
+	"example-lambda-go/internal/cli/policy"
+	"example-lambda-go/internal/cli/report"
+	"example-lambda-go/internal/cli/restore"
+	"example-lambda-go/internal/cli/rollback"
+	"example-lambda-go/internal/cli/rules"
+	"example-lambda-go/internal/cli/secrets"
+	"example-lambda-go/internal/cli/setup"
+	"example-lambda-go/internal/cli/shadow"
+	"example-lambda-go/internal/cli/slo"
+	"example-lambda-go/internal/cli/status"
+	"example-lambda-go/internal/cli/sweep"
+	"example-lambda-go/internal/cli/throttle"
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+)
+
+type command struct {
+	name, summary string
+	main          func(args []string)
+}
+
+// commands in the order the usage lists them: the lifecycle first, then
+// operations, then tooling.
+var commands = []command{
+	{"setup", "Create the role, repository and function described by config.yaml", setup.Main},
+	{"deploy", "Build and push the image and update the function", deploy.Main},
+	{"rollback", "Point the function back at an earlier image or version", rollback.Main},
+	{"history", "List the deploys and the commits that went out in each", history.Main},
+	{"invoke", "Invoke the function and print the response", invoke.Main},
+	{"local", "Build the image and invoke it locally through the Runtime Interface Emulator", invoke.Local},
+	{"compare", "Invoke two versions or aliases with the same events and diff the responses", compare.Main},
+	{"delete", "Delete everything setup created", delete.Main},
+	{"sweep", "Delete resources left behind by functions no longer in config.yaml", sweep.Main},
+	{"status", "Show the function's state, maintenance mode and triggers", status.Main},
+	{"drift", "Report settings changed outside deploy, once or on an interval with alerts", drift.Main},
+	{"logs", "Print or follow the function's CloudWatch logs", logs.Main},
+	{"inspect", "Show one invocation's logs, trace and shadow comparison by request ID", logs.Inspect},
+	{"errors", "Group recent errors in the logs by cause, with counts and example requests", logs.Errors},
+	{"maintenance", "Turn maintenance mode on or off", maintenance.Main},
+	{"throttle", "Stop all invocations, or let them through again", throttle.Main},
+	{"esm", "Pause or resume event source mappings and schedules", esm.Main},
+	{"slo", "Report error budgets and burn rates", slo.Main},
+	{"report", "Print, send or schedule the daily health digest", report.Main},
+	{"backup", "Snapshot the function's definition to S3, now or on a schedule", backup.Main},
+	{"restore", "Recreate the function from a snapshot, in any account or region", restore.Main},
+	{"shadow", "Compare the shadow function's responses with the function's", shadow.Main},
+	{"config", "Validate and push the dynamic configuration document", dynconfig.Main},
+	{"secrets", "Compare or copy secrets between environments", secrets.Main},
+	{"contract", "Check the handler types against consumer contracts", contract.Main},
+	{"docs", "Generate the runbook and architecture diagram", docs.Main},
+	{"policy", "Print the IAM policy a deployer role needs", policy.Main},
+	{"rules", "Check config.yaml and the Dockerfile against the rules section", rules.Main},
+}
+
+// perFunction commands run once for every entry of functions unless
+// -function picks one.
+var perFunction = map[string]bool{"setup": true, "deploy": true, "delete": true}
+
+// aliases are the names of the standalone binaries the subcommands replaced.
+var aliases = map[string]string{
+	"execute": "invoke",
+}
+
+// Main parses the global flags in args and runs the subcommand after them.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template", flag.ExitOnError)
+	flags.StringVar(&config.Path, "config", "", "Configuration file (default: config.yaml or .lambda/config.yaml here or in a parent directory up to the module root, then $XDG_CONFIG_HOME/lambda-template/config.yaml)")
+	flags.StringVar(&config.Env, "env", "", "Environment from the environments section of the configuration file (also accepted right after the command)")
+	flags.StringVar(&config.Function, "function", "", "Function from the functions section of the configuration file (also accepted right after the command)")
+	flags.StringVar(&config.Profile, "profile", "", "AWS profile, overriding aws.profile")
+	flags.StringVar(&config.Region, "region", "", "AWS region, overriding aws.region")
+	flags.BoolVar(&config.NoResolve, "no-resolve", false, "Leave ${ssm:...} placeholders in the configuration file as written instead of reading the parameters")
+	flags.BoolVar(&console.Show, "links", false, "Print links to the AWS console pages of what the command worked on (also accepted right after the command)")
+	flags.Usage = func() { usage(flags) }
+	flags.Parse(args)
+
+	name := flags.Arg(0)
+	if name == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if config.Path == "" {
+		useFoundConfig()
+	}
+	rest := leadingFlags(flags.Args()[1:])
+	help := name == "help"
+	if help {
+		if len(rest) == 0 {
+			flags.SetOutput(os.Stdout)
+			usage(flags)
+			return
+		}
+		// Each subcommand prints its own usage for -h
+		name, rest = rest[0], []string{"-h"}
+	}
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if perFunction[name] && config.Function == "" && !help && !fromPackage(name, rest) {
+			runPerFunction(c, rest)
+			return
+		}
+		c.main(rest)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "lambda-template: unknown command %q\n\n", name)
+	flags.Usage()
+	os.Exit(2)
+}
+
+// useFoundConfig points config.Path at the configuration file config.Find
+// locates. When it is in a parent directory, the command runs from there, so
+// that the Docker build context and the paths in the file resolve as they do
+// from the project directory.
+func useFoundConfig() {
+	path, projectDir := config.Find()
+	config.Path = path
+	if projectDir == "" {
+		return
+	}
+	if wd, err := os.Getwd(); err == nil && wd == projectDir {
+		return
+	}
+	if err := os.Chdir(projectDir); err != nil {
+		fmt.Fprintf(os.Stderr, "lambda-template: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Using %s\n", path)
+}
+
+// leadingFlags takes -env NAME, -function NAME and -links off the front of a
+// command's arguments, so that `lambda-template deploy -env prod` works like
+// `lambda-template -env prod deploy`, and returns the rest.
+func leadingFlags(args []string) []string {
+	for len(args) > 0 {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		var target *string
+		switch {
+		case !strings.HasPrefix(args[0], "-"):
+			return args
+		case name == "env":
+			target = &config.Env
+		case name == "function":
+			target = &config.Function
+		case name == "links" && (!hasValue || value == "true" || value == "false"):
+			console.Show, args = value != "false", args[1:]
+			continue
+		default:
+			return args
+		}
+		if hasValue {
+			*target, args = value, args[1:]
+			continue
+		}
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "lambda-template: -%s needs a name\n", name)
+			os.Exit(2)
+		}
+		*target, args = args[1], args[2:]
+	}
+	return args
+}
+
+// fromPackage reports whether args deploy a -from-package file. A package
+// holds the image of one entry of functions, which its manifest names, so
+// the deploy runs once for that entry rather than for each.
+func fromPackage(name string, args []string) bool {
+	if name != "deploy" {
+		return false
+	}
+	for _, arg := range args {
+		flagName, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && flagName == "from-package" {
+			return true
+		}
+	}
+	return false
+}
+
+// runPerFunction runs the command for each entry of functions in turn, or
+// once when the project has a single function. It stops at the first
+// failure, since the commands exit on errors.
+func runPerFunction(c command, args []string) {
+	names, err := config.FunctionNames()
+	if err != nil || len(names) == 0 {
+		// Load reports the error again where the command expects it
+		c.main(args)
+		return
+	}
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("==> %s %s\n", c.name, name)
+		config.Function = name
+		c.main(args)
+	}
+}
+
+func usage(flags *flag.FlagSet) {
+	w := flags.Output()
+	fmt.Fprintln(w, "Usage: lambda-template [-config FILE] [-env NAME] [-function NAME] [-profile NAME] [-region REGION] [-links] <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun `lambda-template help <command>` for the arguments of a command.")
+	fmt.Fprintln(w, "\nGlobal flags:")
+	flags.PrintDefaults()
+	fmt.Fprintln(w, "\nAny setting of the configuration file can be overridden with an LT_ variable")
+	fmt.Fprintln(w, "named after its path, e.g. LT_AWS_REGION or LT_LAMBDA_MEMORY_SIZE. Flags take")
+	fmt.Fprintln(w, "precedence over variables, and variables over the file and its environments.")
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+)
+
+func TestLeadingFlags(t *testing.T) {
+	t.Cleanup(func() { config.Env, config.Function = "", "" })
+	for _, test := range []struct {
+		args               string
+		env, function, out string
+	}{
+		{"-env prod -skip-contract-check", "prod", "", "-skip-contract-check"},
+		{"--env=staging", "staging", "", ""},
+		{"-function hello-worker -env prod -explain", "prod", "hello-worker", "-explain"},
+		{"--function=hello-api", "", "hello-api", ""},
+		{"-skip-contract-check -env prod", "", "", "-skip-contract-check -env prod"},
+		{"", "", "", ""},
+	} {
+		config.Env, config.Function = "", ""
+		rest := leadingFlags(strings.Fields(test.args))
+		if config.Env != test.env || config.Function != test.function || strings.Join(rest, " ") != test.out {
+			t.Errorf("leadingFlags(%q) = %q with env %q, function %q; want %q with env %q, function %q",
+				test.args, rest, config.Env, config.Function, test.out, test.env, test.function)
+		}
+	}
+
+	t.Cleanup(func() { console.Show = false })
+	if rest := leadingFlags([]string{"-links", "-env", "prod", "-explain"}); !console.Show || strings.Join(rest, " ") != "-explain" {
+		t.Errorf("leadingFlags(-links -env prod -explain) = %q with links %v", rest, console.Show)
+	}
+}
+
+func TestFromPackageRunsOnce(t *testing.T) {
+	for _, test := range []struct {
+		name, args string
+		want       bool
+	}{
+		{"deploy", "-from-package out-hello.tar", true},
+		{"deploy", "--from-package=out.tar -skip-handler-check", true},
+		{"deploy", "-package out.tar", false},
+		{"setup", "-from-package out.tar", false},
+	} {
+		if got := fromPackage(test.name, strings.Fields(test.args)); got != test.want {
+			t.Errorf("fromPackage(%s %s) = %v, want %v", test.name, test.args, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestDiffResponses(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want []string
+	}{
+		{`{"a":1,"b":[1,2]}`, `{ "b": [1, 2], "a": 1 }`, nil},
+		{`"Hello, Ada!"`, `"Hi, Ada!"`, []string{`$: "Hello, Ada!" != "Hi, Ada!"`}},
+		{`{"a":1,"b":2}`, `{"a":1,"c":2}`, []string{"$.b: only in A", "$.c: only in B"}},
+		{`{"items":[{"id":1},{"id":2}]}`, `{"items":[{"id":1},{"id":3},{"id":4}]}`, []string{
+			"$.items: 2 elements != 3",
+			"$.items[1].id: 2 != 3",
+		}},
+		{`{"a":{"b":true}}`, `{"a":"b"}`, []string{`$.a: {"b":true} != "b"`}},
+		{`not json`, `not json`, nil},
+		{`not json`, `{}`, []string{`response: "not json" != "{}"`}},
+	} {
+		if got := diffResponses([]byte(test.a), []byte(test.b)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("diffResponses(%s, %s) = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+type fakeLambda map[string]*lambda.InvokeOutput
+
+func (f fakeLambda) Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	return f[aws.ToString(params.Qualifier)+" "+string(params.Payload)], nil
+}
+
+func tail(duration string) *string {
+	return aws.String(base64.StdEncoding.EncodeToString([]byte("START RequestId: x\nREPORT RequestId: x\tDuration: " + duration + " ms\tBilled Duration: 13 ms\n")))
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ada.json"), []byte(`{"name":"Ada"}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "bob.json"), []byte(`{"name":"Bob"}`), 0o644)
+	fixtures, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	c := comparer{
+		client: fakeLambda{
+			`live {"name":"Ada"}`:   {Payload: []byte(`"Hello, Ada!"`), LogResult: tail("12.00")},
+			`canary {"name":"Ada"}`: {Payload: []byte(`"Hello, Ada!"`), LogResult: tail("10.00")},
+			`live {"name":"Bob"}`:   {Payload: []byte(`"Hello, Bob!"`), LogResult: tail("20.00")},
+			`canary {"name":"Bob"}`: {Payload: []byte(`{"errorMessage":"boom"}`), FunctionError: aws.String("Unhandled"), LogResult: tail("30.00")},
+		},
+		function: "hello",
+		a:        "live",
+		b:        "canary",
+	}
+	var out bytes.Buffer
+	differing, err := c.run(context.Background(), &out, fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differing != 1 {
+		t.Errorf("%d fixtures differ, want 1", differing)
+	}
+	for _, want := range []string{
+		"ada.json: same response (live 12.0ms, canary 10.0ms, -2.0ms)",
+		"bob.json: 2 differences (live 20.0ms, canary 30.0ms, +10.0ms)",
+		`function error: "" != "Unhandled"`,
+		"1 of 2 fixtures differ; mean latency live 16.0ms, canary 20.0ms, +4.0ms",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, out.String())
+		}
+	}
+}
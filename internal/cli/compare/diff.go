@@ -0,0 +1,80 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// diffResponses describes where two response payloads differ, one line per
+// difference. JSON payloads are compared structurally, so key order and
+// whitespace do not count; anything else is compared as bytes.
+func diffResponses(a, b []byte) []string {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		if string(a) == string(b) {
+			return nil
+		}
+		return []string{fmt.Sprintf("response: %q != %q", a, b)}
+	}
+	return diffValues("$", va, vb, nil)
+}
+
+func diffValues(path string, a, b any, diffs []string) []string {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			va, inA := a[k]
+			vb, inB := b[k]
+			switch {
+			case !inB:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: only in A", path, k))
+			case !inA:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: only in B", path, k))
+			default:
+				diffs = diffValues(path+"."+k, va, vb, diffs)
+			}
+		}
+		return diffs
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		if len(a) != len(b) {
+			diffs = append(diffs, fmt.Sprintf("%s: %d elements != %d", path, len(a), len(b)))
+		}
+		for i := 0; i < len(a) && i < len(b); i++ {
+			diffs = diffValues(path+"["+strconv.Itoa(i)+"]", a[i], b[i], diffs)
+		}
+		return diffs
+	}
+	if !reflect.DeepEqual(a, b) {
+		diffs = append(diffs, fmt.Sprintf("%s: %s != %s", path, encode(a), encode(b)))
+	}
+	return diffs
+}
+
+func encode(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
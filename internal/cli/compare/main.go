@@ -0,0 +1,176 @@
+package compare
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/lambdareport"
+)
+
+type invokeAPI interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+// Main invokes two qualifiers of the function with the same fixtures and
+// reports where their responses and latencies differ.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template compare", flag.ExitOnError)
+	qualifierA := flags.String("qualifier-a", "", "First version or alias, e.g. live")
+	qualifierB := flags.String("qualifier-b", "", "Second version or alias, e.g. canary")
+	events := flags.String("events", "", "Directory of JSON event fixtures, one event per file")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template compare -qualifier-a live -qualifier-b canary -events dir/")
+		fmt.Fprintln(os.Stderr, "Invokes both qualifiers with every *.json file in the events directory and reports")
+		fmt.Fprintln(os.Stderr, "differences in their responses and latency. Exits 1 when any response differs.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *qualifierA == "" || *qualifierB == "" || *events == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join(*events, "*.json"))
+	if err != nil || len(fixtures) == 0 {
+		log.Fatalf("No *.json event fixtures in %s", *events)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	c := comparer{
+		client:   lambda.NewFromConfig(awsCfg),
+		function: cfg.Lambda.FunctionName,
+		a:        *qualifierA,
+		b:        *qualifierB,
+	}
+	differing, err := c.run(context.TODO(), os.Stdout, fixtures)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if differing > 0 {
+		os.Exit(1)
+	}
+}
+
+type comparer struct {
+	client   invokeAPI
+	function string
+	a, b     string
+}
+
+// result is one qualifier's answer to a fixture.
+type result struct {
+	payload       []byte
+	functionError string
+	duration      time.Duration
+}
+
+// run compares every fixture and prints a line per fixture and a summary,
+// returning how many fixtures got different responses.
+func (c *comparer) run(ctx context.Context, w io.Writer, fixtures []string) (int, error) {
+	var differing int
+	var totalA, totalB time.Duration
+	for _, fixture := range fixtures {
+		payload, err := os.ReadFile(fixture)
+		if err != nil {
+			return 0, fmt.Errorf("error reading fixture: %v", err)
+		}
+		a, err := c.invoke(ctx, c.a, payload)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", fixture, err)
+		}
+		b, err := c.invoke(ctx, c.b, payload)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", fixture, err)
+		}
+		totalA += a.duration
+		totalB += b.duration
+
+		var diffs []string
+		if a.functionError != b.functionError {
+			diffs = append(diffs, fmt.Sprintf("function error: %q != %q", a.functionError, b.functionError))
+		}
+		diffs = append(diffs, diffResponses(a.payload, b.payload)...)
+
+		latency := fmt.Sprintf("%s %s, %s %s, %s", c.a, milliseconds(a.duration), c.b, milliseconds(b.duration), delta(a.duration, b.duration))
+		if len(diffs) == 0 {
+			fmt.Fprintf(w, "%s: same response (%s)\n", filepath.Base(fixture), latency)
+			continue
+		}
+		differing++
+		fmt.Fprintf(w, "%s: %d differences (%s)\n", filepath.Base(fixture), len(diffs), latency)
+		for _, d := range diffs {
+			fmt.Fprintf(w, "  %s\n", d)
+		}
+	}
+
+	n := time.Duration(len(fixtures))
+	fmt.Fprintf(w, "\n%d of %d fixtures differ; mean latency %s %s, %s %s, %s\n",
+		differing, len(fixtures), c.a, milliseconds(totalA/n), c.b, milliseconds(totalB/n), delta(totalA/n, totalB/n))
+	return differing, nil
+}
+
+// invoke calls the qualifier with the payload. The latency is the Duration
+// Lambda reports, which leaves out the network; the round trip is used when
+// the log tail does not include it.
+func (c *comparer) invoke(ctx context.Context, qualifier string, payload []byte) (result, error) {
+	start := time.Now()
+	output, err := c.client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(c.function),
+		Qualifier:    aws.String(qualifier),
+		Payload:      payload,
+		LogType:      lambdatypes.LogTypeTail,
+	})
+	if err != nil {
+		return result{}, fmt.Errorf("error invoking %s: %v", qualifier, err)
+	}
+	r := result{
+		payload:       output.Payload,
+		functionError: aws.ToString(output.FunctionError),
+		duration:      time.Since(start),
+	}
+	if tail, err := base64.StdEncoding.DecodeString(aws.ToString(output.LogResult)); err == nil {
+		if report, ok := lambdareport.Parse(string(tail)); ok {
+			if duration, ok := report.Number("Duration"); ok {
+				if ms, err := strconv.ParseFloat(duration, 64); err == nil {
+					r.duration = time.Duration(ms * float64(time.Millisecond))
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+}
+
+func delta(a, b time.Duration) string {
+	if b >= a {
+		return "+" + milliseconds(b-a)
+	}
+	return "-" + milliseconds(a-b)
+}
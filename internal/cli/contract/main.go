@@ -0,0 +1,71 @@
+package contract
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"example-lambda-go/internal/contractcheck"
+)
+
+// Main checks the handler types against the recorded consumer contracts.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template contract", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template contract check [-update] [-dir contracts]")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", contractcheck.DefaultDir, "Directory holding provider.json and consumers/*.json")
+	update := flags.Bool("update", false, "Publish the current types as a new contract version in provider.json")
+	if len(args) == 0 || args[0] != "check" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(args[1:])
+
+	result, err := contractcheck.Check(*dir)
+	if err != nil {
+		log.Fatalf("Error checking contracts: %v", err)
+	}
+
+	for _, b := range result.PublishedBreaks {
+		fmt.Printf("BREAKING (published %s): %s\n", result.Published.Version, b)
+	}
+	for _, b := range result.Breaks {
+		fmt.Printf("BREAKING for consumer %s\n", b)
+	}
+
+	if *update {
+		version, err := contractcheck.Publish(*dir, result)
+		if err != nil {
+			log.Fatalf("Error publishing contract: %v", err)
+		}
+		if version == result.Published.Version {
+			fmt.Printf("Contract unchanged at version %s.\n", version)
+		} else {
+			fmt.Printf("Published contract version %s to %s/provider.json.\n", version, *dir)
+		}
+		if len(result.Breaks) > 0 {
+			fmt.Println("Consumers listed above must re-record their expectations before this can be deployed.")
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !result.OK() {
+		fmt.Println("\nThe handler types break recorded contracts. Restore compatibility, or publish a")
+		fmt.Println("new major version with `contract check -update` and have consumers re-record.")
+		os.Exit(1)
+	}
+	if result.Changed {
+		fmt.Println("Compatible changes not yet published. Run `contract check -update` to publish them.")
+		return
+	}
+	operations := make([]string, 0, len(result.Current.Operations))
+	for name := range result.Current.Operations {
+		operations = append(operations, name)
+	}
+	fmt.Printf("Contract %s OK (%s).\n", result.Published.Version, strings.Join(operations, ", "))
+}
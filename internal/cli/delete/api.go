@@ -0,0 +1,88 @@
+package delete
+
+import (
+	"github.com/aws/aws-sdk-go/service/apigatewayv2"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/wafv2"
+)
+
+//go:generate go run go.uber.org/mock/mockgen -source=api.go -destination=mock_api_test.go -package=delete
+
+// The parts of the SDK clients delete uses, so tests can pass mocks.
+
+type lambdaAPI interface {
+	DeleteFunction(input *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error)
+	DeleteFunctionUrlConfig(input *lambda.DeleteFunctionUrlConfigInput) (*lambda.DeleteFunctionUrlConfigOutput, error)
+	ListEventSourceMappingsPages(input *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error
+	DeleteEventSourceMapping(input *lambda.DeleteEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error)
+}
+
+type ecrAPI interface {
+	DeleteRepository(input *ecr.DeleteRepositoryInput) (*ecr.DeleteRepositoryOutput, error)
+}
+
+type eventsAPI interface {
+	RemoveTargets(input *eventbridge.RemoveTargetsInput) (*eventbridge.RemoveTargetsOutput, error)
+	DeleteRule(input *eventbridge.DeleteRuleInput) (*eventbridge.DeleteRuleOutput, error)
+}
+
+type cloudFrontAPI interface {
+	ListDistributionsPages(input *cloudfront.ListDistributionsInput, fn func(*cloudfront.ListDistributionsOutput, bool) bool) error
+	GetDistributionConfig(input *cloudfront.GetDistributionConfigInput) (*cloudfront.GetDistributionConfigOutput, error)
+	UpdateDistribution(input *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error)
+	WaitUntilDistributionDeployed(input *cloudfront.GetDistributionInput) error
+	DeleteDistribution(input *cloudfront.DeleteDistributionInput) (*cloudfront.DeleteDistributionOutput, error)
+	ListOriginAccessControls(input *cloudfront.ListOriginAccessControlsInput) (*cloudfront.ListOriginAccessControlsOutput, error)
+	GetOriginAccessControl(input *cloudfront.GetOriginAccessControlInput) (*cloudfront.GetOriginAccessControlOutput, error)
+	DeleteOriginAccessControl(input *cloudfront.DeleteOriginAccessControlInput) (*cloudfront.DeleteOriginAccessControlOutput, error)
+}
+
+type wafAPI interface {
+	ListWebACLs(input *wafv2.ListWebACLsInput) (*wafv2.ListWebACLsOutput, error)
+	DeleteWebACL(input *wafv2.DeleteWebACLInput) (*wafv2.DeleteWebACLOutput, error)
+}
+
+type s3API interface {
+	ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error
+	DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error)
+	DeleteBucket(input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error)
+	GetBucketTagging(input *s3.GetBucketTaggingInput) (*s3.GetBucketTaggingOutput, error)
+	GetBucketNotificationConfiguration(input *s3.GetBucketNotificationConfigurationRequest) (*s3.NotificationConfiguration, error)
+	PutBucketNotificationConfiguration(input *s3.PutBucketNotificationConfigurationInput) (*s3.PutBucketNotificationConfigurationOutput, error)
+}
+
+type route53API interface {
+	ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error)
+	ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error)
+	ListHealthChecksPages(input *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool) error
+	ListTagsForResources(input *route53.ListTagsForResourcesInput) (*route53.ListTagsForResourcesOutput, error)
+	DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error)
+}
+
+type apiGatewayAPI interface {
+	GetApis(input *apigatewayv2.GetApisInput) (*apigatewayv2.GetApisOutput, error)
+	DeleteApi(input *apigatewayv2.DeleteApiInput) (*apigatewayv2.DeleteApiOutput, error)
+}
+
+type snsAPI interface {
+	ListSubscriptionsPages(input *sns.ListSubscriptionsInput, fn func(*sns.ListSubscriptionsOutput, bool) bool) error
+	Unsubscribe(input *sns.UnsubscribeInput) (*sns.UnsubscribeOutput, error)
+}
+
+type clients struct {
+	lambda     lambdaAPI
+	ecr        ecrAPI
+	events     eventsAPI
+	cloudfront cloudFrontAPI
+	waf        wafAPI
+	s3         s3API
+	route53    route53API
+	apigateway apiGatewayAPI
+	sns        snsAPI
+}
@@ -0,0 +1,165 @@
+package delete
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/wafv2"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+// errNotFound stands in for the NotFound error of resources that are looked
+// up by name before they are deleted.
+var errNotFound = errors.New("not found")
+
+// errNotCreated is returned for a resource with the configured name that
+// setup did not create, so it is not delete's to remove.
+var errNotCreated = errors.New("not created by setup")
+
+// deleteDistribution disables the function's distribution, waits for
+// CloudFront to deploy that, which takes several minutes, and deletes it.
+func deleteDistribution(config *appconfig.Config, c clients) (string, error) {
+	var id string
+	err := c.cloudfront.ListDistributionsPages(&cloudfront.ListDistributionsInput{}, func(page *cloudfront.ListDistributionsOutput, last bool) bool {
+		for _, summary := range page.DistributionList.Items {
+			if aws.StringValue(summary.Comment) == config.CDNName() {
+				id = aws.StringValue(summary.Id)
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return config.CDNName(), errNotFound
+	}
+
+	current, err := c.cloudfront.GetDistributionConfig(&cloudfront.GetDistributionConfigInput{Id: aws.String(id)})
+	if err != nil {
+		return id, err
+	}
+	etag := current.ETag
+	// A disabled one is left from an earlier delete that was interrupted
+	if aws.BoolValue(current.DistributionConfig.Enabled) {
+		current.DistributionConfig.Enabled = aws.Bool(false)
+		updated, err := c.cloudfront.UpdateDistribution(&cloudfront.UpdateDistributionInput{
+			Id:                 aws.String(id),
+			IfMatch:            etag,
+			DistributionConfig: current.DistributionConfig,
+		})
+		if err != nil {
+			return id, err
+		}
+		etag = updated.ETag
+	}
+	fmt.Printf("Waiting for CloudFront to disable distribution %s; this takes several minutes...\n", id)
+	if err := c.cloudfront.WaitUntilDistributionDeployed(&cloudfront.GetDistributionInput{Id: aws.String(id)}); err != nil {
+		return id, err
+	}
+	_, err = c.cloudfront.DeleteDistribution(&cloudfront.DeleteDistributionInput{Id: aws.String(id), IfMatch: etag})
+	return id, err
+}
+
+func deleteOriginAccessControl(c clients, name string) error {
+	list, err := c.cloudfront.ListOriginAccessControls(&cloudfront.ListOriginAccessControlsInput{})
+	if err != nil {
+		return err
+	}
+	for _, summary := range list.OriginAccessControlList.Items {
+		if aws.StringValue(summary.Name) != name {
+			continue
+		}
+		current, err := c.cloudfront.GetOriginAccessControl(&cloudfront.GetOriginAccessControlInput{Id: summary.Id})
+		if err != nil {
+			return err
+		}
+		_, err = c.cloudfront.DeleteOriginAccessControl(&cloudfront.DeleteOriginAccessControlInput{Id: summary.Id, IfMatch: current.ETag})
+		return err
+	}
+	return errNotFound
+}
+
+// deleteWebACL deletes the web ACL setup created; one given by
+// cdn.web_acl_arn is not the function's to delete.
+func deleteWebACL(config *appconfig.Config, c clients) error {
+	input := &wafv2.ListWebACLsInput{Scope: aws.String(wafv2.ScopeCloudfront)}
+	for {
+		list, err := c.waf.ListWebACLs(input)
+		if err != nil {
+			return err
+		}
+		for _, summary := range list.WebACLs {
+			if aws.StringValue(summary.Name) == config.WebACLName() {
+				_, err := c.waf.DeleteWebACL(&wafv2.DeleteWebACLInput{
+					Name:      summary.Name,
+					Id:        summary.Id,
+					LockToken: summary.LockToken,
+					Scope:     aws.String(wafv2.ScopeCloudfront),
+				})
+				return err
+			}
+		}
+		if aws.StringValue(list.NextMarker) == "" {
+			return errNotFound
+		}
+		input.NextMarker = list.NextMarker
+	}
+}
+
+// deleteAssetsBucket empties the assets bucket, which S3 requires before it
+// can be deleted, and deletes it. Only a bucket setup created, and tagged as
+// this project's, is touched: cdn.assets.bucket may name one that already
+// held other objects.
+func deleteAssetsBucket(config *appconfig.Config, c clients, bucket string) error {
+	tagging, err := c.s3.GetBucketTagging(&s3.GetBucketTaggingInput{Bucket: aws.String(bucket)})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == "NoSuchTagSet" {
+		return errNotCreated
+	}
+	if err != nil {
+		return err
+	}
+	tags := map[string]string{}
+	for _, tag := range tagging.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if tags[appconfig.CreatedByTag] != appconfig.CreatedBy || tags[appconfig.ProjectTag] != config.ProjectID() {
+		return errNotCreated
+	}
+
+	var deleteErr error
+	err = c.s3.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(bucket)}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+		// A page holds at most 1000 keys, as many as one DeleteObjects takes
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+		}
+		output, err := c.s3.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err == nil && len(output.Errors) > 0 {
+			err = fmt.Errorf("deleting %s: %s", aws.StringValue(output.Errors[0].Key), aws.StringValue(output.Errors[0].Message))
+		}
+		deleteErr = err
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleteErr != nil {
+		return deleteErr
+	}
+	_, err = c.s3.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+	return err
+}
@@ -0,0 +1,73 @@
+package delete
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+// deleteDNSRecord deletes the region's record for dns.name. Route53 only
+// deletes a record given exactly as it is, so it is read first.
+func deleteDNSRecord(config *appconfig.Config, c clients) error {
+	name := strings.TrimSuffix(config.DNS.Name, ".") + "."
+	list, err := c.route53.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{
+		HostedZoneId:          aws.String(config.DNS.ZoneID),
+		StartRecordName:       aws.String(name),
+		StartRecordType:       aws.String(route53.RRTypeCname),
+		StartRecordIdentifier: aws.String(config.AWS.Region),
+	})
+	if err != nil {
+		return err
+	}
+	for _, record := range list.ResourceRecordSets {
+		if aws.StringValue(record.Name) != name || aws.StringValue(record.SetIdentifier) != config.AWS.Region {
+			continue
+		}
+		_, err := c.route53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(config.DNS.ZoneID),
+			ChangeBatch: &route53.ChangeBatch{Changes: []*route53.Change{{
+				Action:            aws.String(route53.ChangeActionDelete),
+				ResourceRecordSet: record,
+			}}},
+		})
+		return err
+	}
+	return errNotFound
+}
+
+// deleteHealthCheck deletes the health check setup created for the region,
+// found by its Name tag.
+func deleteHealthCheck(config *appconfig.Config, c clients) error {
+	var ids []*string
+	err := c.route53.ListHealthChecksPages(&route53.ListHealthChecksInput{}, func(page *route53.ListHealthChecksOutput, last bool) bool {
+		for _, check := range page.HealthChecks {
+			ids = append(ids, check.Id)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	// ListTagsForResources takes up to 10 IDs
+	for start := 0; start < len(ids); start += 10 {
+		tags, err := c.route53.ListTagsForResources(&route53.ListTagsForResourcesInput{
+			ResourceType: aws.String(route53.TagResourceTypeHealthcheck),
+			ResourceIds:  ids[start:min(start+10, len(ids))],
+		})
+		if err != nil {
+			return err
+		}
+		for _, set := range tags.ResourceTagSets {
+			for _, tag := range set.Tags {
+				if aws.StringValue(tag.Key) == "Name" && aws.StringValue(tag.Value) == config.HealthCheckReference() {
+					_, err := c.route53.DeleteHealthCheck(&route53.DeleteHealthCheckInput{HealthCheckId: set.ResourceId})
+					return err
+				}
+			}
+		}
+	}
+	return errNotFound
+}
@@ -0,0 +1,105 @@
+package delete
+
+import (
+	"fmt"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/explain"
+)
+
+// Plan is the -explain plan of delete with cfg, which the deployer policy is
+// generated from.
+func Plan(cfg *appconfig.Config, awsAccountID string) *explain.Plan {
+	return explainPlan(cfg, awsAccountID)
+}
+
+// explainPlan lists the calls deleteResources makes with the given config, in
+// the same order.
+func explainPlan(config *appconfig.Config, awsAccountID string) *explain.Plan {
+	region := config.AWS.Region
+
+	p := explain.New("delete")
+	if config.Lambda.Schedule != "" {
+		ruleARN := config.Partition().ARN("events", region, awsAccountID, "rule/"+config.ScheduleRuleName())
+		p.Call("events:RemoveTargets", "detach the function from its schedule").
+			On(ruleARN).From("lambda.schedule", config.Lambda.Schedule)
+		p.Call("events:DeleteRule", "delete the schedule").On(ruleARN)
+	}
+	if config.Export.Schedule != "" {
+		ruleARN := config.Partition().ARN("events", region, awsAccountID, fmt.Sprintf("rule/%s-export", config.Lambda.FunctionName))
+		p.Call("events:RemoveTargets", "detach the function from the export schedule").
+			On(ruleARN).From("export.schedule", config.Export.Schedule)
+		p.Call("events:DeleteRule", "delete the export schedule").On(ruleARN)
+	}
+	if config.Triggers.Kinesis.Enabled() {
+		p.Call("lambda:ListEventSourceMappings", "find the triggers.kinesis mapping").
+			From("triggers.kinesis.stream", config.Triggers.Kinesis.Stream)
+		p.Call("lambda:DeleteEventSourceMapping", "stop reading the stream").
+			On(config.Partition().ARN("lambda", region, awsAccountID, "event-source-mapping:*"))
+	}
+	if config.Triggers.SNS.Enabled() {
+		p.Call("sns:ListSubscriptions", "find the function's subscription").From("triggers.sns.topic", config.Triggers.SNS.Topic)
+		p.Call("sns:Unsubscribe", "unsubscribe the function from the topic").On(config.SNSTopicARN(awsAccountID))
+	}
+	if config.Triggers.S3.Enabled() {
+		bucketARN := config.S3BucketARN()
+		p.Call("s3:GetBucketNotification", "read the bucket's notifications").On(bucketARN).From("triggers.s3.bucket", config.Triggers.S3.Bucket)
+		p.Call("s3:PutBucketNotification", "put them back without the function's").On(bucketARN)
+	}
+	if config.DNS.Enabled() {
+		p.Call("route53:ListResourceRecordSets", "read the region's record to delete it").
+			On(config.Partition().ARN("route53", "", "", "hostedzone/"+config.DNS.ZoneID)).
+			From("dns.name", config.DNS.Name)
+		p.Call("route53:ChangeResourceRecordSets", "delete the region's record").
+			On(config.Partition().ARN("route53", "", "", "hostedzone/"+config.DNS.ZoneID))
+		p.Call("route53:ListHealthChecks", "find the region's health check")
+		p.Call("route53:ListTagsForResources", "find the health check named "+config.HealthCheckReference()).
+			On(config.Partition().ARN("route53", "", "", "healthcheck/*"))
+		p.Call("route53:DeleteHealthCheck", "delete the health check").
+			On(config.Partition().ARN("route53", "", "", "healthcheck/*"))
+	}
+	if config.API.Enabled {
+		apis := config.Partition().ARN("apigateway", region, "", "/apis")
+		p.Call("apigateway:GET", "find the HTTP API (GetApis)").On(apis).From("api.name", config.HTTPAPIName())
+		p.Call("apigateway:DELETE", "delete the HTTP API with its routes and stage (DeleteApi)").On(apis + "/*")
+	}
+	if config.CDN.Enabled {
+		distributionARN := config.Partition().ARN("cloudfront", "", awsAccountID, "distribution/*")
+		p.Call("cloudfront:ListDistributions", "find the function's distribution").From("cdn.enabled", true)
+		p.Call("cloudfront:GetDistributionConfig", "read the distribution's config to disable it").On(distributionARN)
+		p.Call("cloudfront:UpdateDistribution", "disable the distribution").On(distributionARN).If("unless it is disabled already")
+		p.Call("cloudfront:GetDistribution", "wait for the distribution to be disabled").On(distributionARN)
+		p.Call("cloudfront:DeleteDistribution", "delete the distribution").On(distributionARN)
+		oacARN := config.Partition().ARN("cloudfront", "", awsAccountID, "origin-access-control/*")
+		p.Call("cloudfront:ListOriginAccessControls", "find the function's origin access control")
+		p.Call("cloudfront:GetOriginAccessControl", "read its version to delete it").On(oacARN)
+		p.Call("cloudfront:DeleteOriginAccessControl", "delete the origin access control").On(oacARN)
+		if bucket := config.CDN.Assets.Bucket; bucket != "" {
+			bucketARN := config.Partition().ARN("s3", "", "", bucket)
+			p.Call("s3:GetBucketTagging", "check that setup created the assets bucket").On(bucketARN).From("cdn.assets.bucket", bucket)
+			p.Call("s3:ListBucket", "list the assets to empty the bucket").On(bucketARN).If("if setup created it")
+			p.Call("s3:DeleteObject", "delete the assets (DeleteObjects)").On(bucketARN + "/*")
+			p.Call("s3:DeleteBucket", "delete the assets bucket").On(bucketARN)
+			p.Call("cloudfront:DeleteOriginAccessControl", "delete the assets bucket's origin access control").On(oacARN)
+		}
+		if config.CDN.WebACLARN == "" {
+			p.Call("wafv2:ListWebACLs", "find the web ACL setup created")
+			p.Call("wafv2:DeleteWebACL", "delete the web ACL").
+				On(config.Partition().ARN("wafv2", "us-east-1", awsAccountID, "global/webacl/"+config.WebACLName()+"/*"))
+		}
+	}
+	if config.FunctionURL.Enabled {
+		p.Call("lambda:DeleteFunctionUrlConfig", "delete the function URL").
+			On(config.TriggerTargetARN(awsAccountID)).
+			From("function_url.enabled", true)
+	}
+	p.Call("lambda:DeleteFunction", "delete the function").
+		On(config.Partition().ARN("lambda", region, awsAccountID, "function:"+config.Lambda.FunctionName)).
+		From("lambda.function_name", config.Lambda.FunctionName)
+	if !config.SeparateRegistry() {
+		p.Call("ecr:DeleteRepository", "delete the repository and every image in it").
+			On(config.Partition().ARN("ecr", region, awsAccountID, "repository/"+config.ECR.RepositoryName)).
+			From("ecr.repository_name", config.ECR.RepositoryName)
+	}
+	return p
+}
@@ -0,0 +1,35 @@
+package delete
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigatewayv2"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+// deleteHTTPAPI deletes the HTTP API setup created, found by its name and
+// kept unless it carries this project's tags. Its routes, integration and
+// stage go with it, and the permission it was given goes with the function.
+func deleteHTTPAPI(config *appconfig.Config, c clients) error {
+	input := &apigatewayv2.GetApisInput{}
+	for {
+		page, err := c.apigateway.GetApis(input)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if aws.StringValue(item.Name) == config.HTTPAPIName() {
+				tags := aws.StringValueMap(item.Tags)
+				if tags[appconfig.CreatedByTag] != appconfig.CreatedBy || tags[appconfig.ProjectTag] != config.ProjectID() {
+					return errNotCreated
+				}
+				_, err := c.apigateway.DeleteApi(&apigatewayv2.DeleteApiInput{ApiId: item.ApiId})
+				return err
+			}
+		}
+		if aws.StringValue(page.NextToken) == "" {
+			return errNotFound
+		}
+		input.NextToken = page.NextToken
+	}
+}
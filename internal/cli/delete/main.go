@@ -0,0 +1,331 @@
+package delete
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigatewayv2"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/wafv2"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/explain"
+)
+
+// Main deletes what setup created, after asking for confirmation.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template delete", flag.ExitOnError)
+	explainOnly := flags.Bool("explain", false, "List the AWS calls and IAM permissions the deletion would use, then exit")
+	flags.Parse(args)
+
+	config, err := appconfig.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Create AWS session; SDK v1 does not read AWS_ENDPOINT_URL itself, which
+	// the CLI and SDK v2 based commands honour (e.g. for LocalStack)
+	awsConfig := aws.Config{
+		Region: aws.String(config.AWS.Region),
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile: config.AWS.Profile,
+		Config:  awsConfig,
+	})
+	if err != nil {
+		log.Fatalf("Error creating AWS session: %v", err)
+	}
+
+	if *explainOnly {
+		ctx := context.TODO()
+		awsCfg, err := config.AWSConfig(ctx)
+		if err != nil {
+			log.Fatalf("Unable to load SDK config: %v", err)
+		}
+		explainPlan(config, explain.AccountID(ctx, sts.NewFromConfig(awsCfg))).Print(os.Stdout)
+		return
+	}
+
+	// Confirm deletion with user
+	extra := ""
+	if config.CDN.Enabled {
+		extra = ", its CloudFront distribution"
+		if config.CDN.Assets.Bucket != "" {
+			extra += ", the assets bucket " + config.CDN.Assets.Bucket + " with everything in it"
+		}
+	}
+	if config.FunctionURL.Enabled {
+		extra += ", its function URL"
+	}
+	if config.API.Enabled {
+		extra += ", its HTTP API " + config.HTTPAPIName()
+	}
+	if config.Triggers.SNS.Enabled() {
+		extra += ", its subscription to " + config.Triggers.SNS.Topic
+	}
+	if config.Triggers.S3.Enabled() {
+		extra += ", its notifications from bucket " + config.Triggers.S3.Bucket
+	}
+	if config.DNS.Enabled() {
+		extra += fmt.Sprintf(", the %s record of %s", config.AWS.Region, config.DNS.Name)
+	}
+	repository := " and ECR repository " + config.ECR.RepositoryName
+	if config.SeparateRegistry() {
+		repository = ""
+	}
+	fmt.Printf("Are you sure you want to delete the Lambda function %s%s%s? (y/n): ", config.Lambda.FunctionName, extra, repository)
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != "y" && confirmation != "Y" {
+		fmt.Println("Deletion cancelled.")
+		return
+	}
+
+	c := clients{
+		lambda:     lambda.New(sess),
+		ecr:        ecr.New(sess),
+		events:     eventbridge.New(sess),
+		cloudfront: cloudfront.New(sess),
+		// Web ACLs for CloudFront live in us-east-1
+		waf:        wafv2.New(sess, aws.NewConfig().WithRegion("us-east-1")),
+		s3:         s3.New(sess),
+		route53:    route53.New(sess),
+		apigateway: apigatewayv2.New(sess),
+		// Subscriptions are listed in the topic's region
+		sns: sns.New(sess, aws.NewConfig().WithRegion(config.SNSTopicRegion())),
+	}
+	if failed := deleteResources(config, c); failed > 0 {
+		log.Fatalf("%d resource(s) could not be deleted", failed)
+	}
+}
+
+// deleteResources deletes everything setup created, carrying on past
+// failures so one stuck resource does not leave the rest behind. Resources
+// that are already gone count as deleted. It returns the number of failures.
+func deleteResources(config *appconfig.Config, c clients) int {
+	failed := 0
+	report := func(kind, name string, err error) {
+		switch {
+		case err == nil:
+			fmt.Printf("%s '%s' deleted successfully.\n", kind, name)
+		case isNotFound(err):
+			fmt.Printf("%s '%s' does not exist, skipping.\n", kind, name)
+		case errors.Is(err, errNotCreated):
+			fmt.Printf("%s '%s' was not created by setup, keeping it.\n", kind, name)
+		default:
+			log.Printf("Error deleting %s: %v", kind, err)
+			failed++
+		}
+	}
+
+	// Delete the schedule rules
+	if config.Lambda.Schedule != "" {
+		report("Schedule", config.ScheduleRuleName(), deleteRule(c, config.ScheduleRuleName(), config.Lambda.FunctionName))
+	}
+	if config.Export.Schedule != "" {
+		ruleName := config.Lambda.FunctionName + "-export"
+		report("Export schedule", ruleName, deleteRule(c, ruleName, config.Lambda.FunctionName))
+	}
+
+	// Stop reading the stream before the function goes, so no batch is
+	// left half processed
+	if config.Triggers.Kinesis.Enabled() {
+		report("Kinesis trigger", config.Triggers.Kinesis.Stream, deleteKinesisMapping(config, c))
+	}
+
+	// The topic is not setup's, so only the subscription goes
+	if config.Triggers.SNS.Enabled() {
+		report("SNS subscription", config.Triggers.SNS.Topic, unsubscribe(config, c))
+	}
+	if config.Triggers.S3.Enabled() {
+		report("S3 notification", config.Triggers.S3.Bucket, removeS3Notification(config, c))
+	}
+
+	// Take the region out of DNS before its endpoint goes; the other
+	// regions' records and health checks stay
+	if config.DNS.Enabled() {
+		report("DNS record", config.DNS.Name+" ("+config.AWS.Region+")", deleteDNSRecord(config, c))
+		report("Health check", config.HealthCheckReference(), deleteHealthCheck(config, c))
+	}
+
+	if config.API.Enabled {
+		report("HTTP API", config.HTTPAPIName(), deleteHTTPAPI(config, c))
+	}
+
+	// Delete the CloudFront distribution first: its origin access control and
+	// web ACL can't be deleted while it uses them
+	if config.CDN.Enabled {
+		id, err := deleteDistribution(config, c)
+		report("CloudFront distribution", id, err)
+		report("Origin access control", config.CDNName(), deleteOriginAccessControl(c, config.CDNName()))
+		if bucket := config.CDN.Assets.Bucket; bucket != "" {
+			report("Assets bucket", bucket, deleteAssetsBucket(config, c, bucket))
+			report("Origin access control", config.AssetsAccessName(), deleteOriginAccessControl(c, config.AssetsAccessName()))
+		}
+		if config.CDN.WebACLARN == "" {
+			report("Web ACL", config.WebACLName(), deleteWebACL(config, c))
+		}
+	}
+
+	// The URL would go with the function, but not if deleting it fails
+	if config.FunctionURL.Enabled {
+		input := &lambda.DeleteFunctionUrlConfigInput{FunctionName: aws.String(config.Lambda.FunctionName)}
+		if config.Deploy.Alias != "" {
+			input.Qualifier = aws.String(config.Deploy.Alias)
+		}
+		_, err := c.lambda.DeleteFunctionUrlConfig(input)
+		report("Function URL", config.Lambda.FunctionName, err)
+	}
+
+	// Delete Lambda function
+	_, err := c.lambda.DeleteFunction(&lambda.DeleteFunctionInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+	})
+	report("Lambda function", config.Lambda.FunctionName, err)
+
+	// A repository reached with aws.profiles.registry may serve other
+	// environments too, so it stays
+	if config.SeparateRegistry() {
+		fmt.Printf("ECR repository %s left in place: it is in the registry of aws.profiles.registry\n", config.ECR.RepositoryName)
+		return failed
+	}
+	_, err = c.ecr.DeleteRepository(&ecr.DeleteRepositoryInput{
+		RepositoryName: aws.String(config.ECR.RepositoryName),
+		Force:          aws.Bool(true),
+	})
+	report("ECR repository", config.ECR.RepositoryName, err)
+
+	return failed
+}
+
+// deleteRule detaches the target setup gave the rule, which EventBridge
+// requires before deleting it, and deletes the rule.
+func deleteRule(c clients, ruleName, targetID string) error {
+	_, err := c.events.RemoveTargets(&eventbridge.RemoveTargetsInput{
+		Rule: aws.String(ruleName),
+		Ids:  []*string{aws.String(targetID)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.events.DeleteRule(&eventbridge.DeleteRuleInput{
+		Name: aws.String(ruleName),
+	})
+	return err
+}
+
+// deleteKinesisMapping deletes the mapping of triggers.kinesis setup gave
+// the function or deploy.alias, found among its mappings by the stream's
+// name.
+func deleteKinesisMapping(config *appconfig.Config, c clients) error {
+	streamSuffix := ":stream/" + config.KinesisStreamName()
+	var uuid *string
+	err := c.lambda.ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String(config.TriggerTarget()),
+	}, func(page *lambda.ListEventSourceMappingsOutput, lastPage bool) bool {
+		for _, m := range page.EventSourceMappings {
+			if strings.HasSuffix(aws.StringValue(m.EventSourceArn), streamSuffix) {
+				uuid = m.UUID
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if uuid == nil {
+		return errNotFound
+	}
+	_, err = c.lambda.DeleteEventSourceMapping(&lambda.DeleteEventSourceMappingInput{UUID: uuid})
+	return err
+}
+
+// unsubscribe removes the function's subscription to triggers.sns.topic,
+// found among the account's subscriptions by the function and topic names.
+func unsubscribe(config *appconfig.Config, c clients) error {
+	topicSuffix := ":" + config.SNSTopicName()
+	endpointSuffix := ":function:" + config.TriggerTarget()
+	var subscriptionARN *string
+	err := c.sns.ListSubscriptionsPages(&sns.ListSubscriptionsInput{}, func(page *sns.ListSubscriptionsOutput, lastPage bool) bool {
+		for _, s := range page.Subscriptions {
+			if aws.StringValue(s.Protocol) == "lambda" &&
+				strings.HasSuffix(aws.StringValue(s.TopicArn), topicSuffix) &&
+				strings.HasSuffix(aws.StringValue(s.Endpoint), endpointSuffix) {
+				subscriptionARN = s.SubscriptionArn
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if subscriptionARN == nil {
+		return errNotFound
+	}
+	_, err = c.sns.Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: subscriptionARN})
+	return err
+}
+
+// removeS3Notification takes the function's entry out of the notification
+// configuration of triggers.s3.bucket, keeping the bucket's others.
+func removeS3Notification(config *appconfig.Config, c clients) error {
+	bucket := aws.String(config.Triggers.S3.Bucket)
+	notifications, err := c.s3.GetBucketNotificationConfiguration(&s3.GetBucketNotificationConfigurationRequest{Bucket: bucket})
+	if err != nil {
+		return err
+	}
+	var kept []*s3.LambdaFunctionConfiguration
+	for _, f := range notifications.LambdaFunctionConfigurations {
+		if aws.StringValue(f.Id) != config.S3NotificationID() {
+			kept = append(kept, f)
+		}
+	}
+	if len(kept) == len(notifications.LambdaFunctionConfigurations) {
+		return errNotFound
+	}
+	notifications.LambdaFunctionConfigurations = kept
+	_, err = c.s3.PutBucketNotificationConfiguration(&s3.PutBucketNotificationConfigurationInput{
+		Bucket:                    bucket,
+		NotificationConfiguration: notifications,
+	})
+	return err
+}
+
+func isNotFound(err error) bool {
+	if errors.Is(err, errNotFound) {
+		return true
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	// Lambda and EventBridge share the ResourceNotFoundException code
+	case lambda.ErrCodeResourceNotFoundException, ecr.ErrCodeRepositoryNotFoundException,
+		cloudfront.ErrCodeNoSuchDistribution, cloudfront.ErrCodeNoSuchOriginAccessControl,
+		wafv2.ErrCodeWAFNonexistentItemException, s3.ErrCodeNoSuchBucket,
+		route53.ErrCodeNoSuchHealthCheck, apigatewayv2.ErrCodeNotFoundException, sns.ErrCodeNotFoundException:
+		return true
+	}
+	return false
+}
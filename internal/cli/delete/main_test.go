@@ -0,0 +1,435 @@
+package delete
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigatewayv2"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/wafv2"
+	"go.uber.org/mock/gomock"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+func testConfig(schedule string) *appconfig.Config {
+	config := &appconfig.Config{}
+	config.Lambda.FunctionName = "hello"
+	config.ECR.RepositoryName = "hello-repo"
+	config.Export.Schedule = schedule
+	return config
+}
+
+func newClients(ctrl *gomock.Controller) (clients, *MocklambdaAPI, *MockecrAPI, *MockeventsAPI) {
+	l, e, ev := NewMocklambdaAPI(ctrl), NewMockecrAPI(ctrl), NewMockeventsAPI(ctrl)
+	return clients{lambda: l, ecr: e, events: ev}, l, e, ev
+}
+
+func TestDeleteResourcesDeletesEverything(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, ev := newClients(ctrl)
+
+	gomock.InOrder(
+		ev.EXPECT().RemoveTargets(gomock.Any()).DoAndReturn(func(input *eventbridge.RemoveTargetsInput) (*eventbridge.RemoveTargetsOutput, error) {
+			if *input.Rule != "hello-export" || *input.Ids[0] != "hello" {
+				t.Errorf("RemoveTargets(%v)", input)
+			}
+			return &eventbridge.RemoveTargetsOutput{}, nil
+		}),
+		ev.EXPECT().DeleteRule(&eventbridge.DeleteRuleInput{Name: aws.String("hello-export")}).Return(&eventbridge.DeleteRuleOutput{}, nil),
+	)
+	l.EXPECT().DeleteFunction(&lambda.DeleteFunctionInput{FunctionName: aws.String("hello")}).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).DoAndReturn(func(input *ecr.DeleteRepositoryInput) (*ecr.DeleteRepositoryOutput, error) {
+		if *input.RepositoryName != "hello-repo" || !*input.Force {
+			t.Errorf("DeleteRepository(%v), want a forced delete of hello-repo", input)
+		}
+		return &ecr.DeleteRepositoryOutput{}, nil
+	})
+
+	if failed := deleteResources(testConfig("rate(1 day)"), c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesSkipsScheduleWhenNotConfigured(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(testConfig(""), c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesTreatsMissingResourcesAsDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, ev := newClients(ctrl)
+	ev.EXPECT().RemoveTargets(gomock.Any()).Return(nil, awserr.New(eventbridge.ErrCodeResourceNotFoundException, "rule not found", nil))
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "function not found", nil))
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(nil, awserr.New(ecr.ErrCodeRepositoryNotFoundException, "repository not found", nil))
+
+	if failed := deleteResources(testConfig("rate(1 day)"), c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesContinuesPastFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(nil, awserr.New(lambda.ErrCodeResourceConflictException, "update in progress", nil))
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(nil, errors.New("connection reset"))
+
+	if failed := deleteResources(testConfig(""), c); failed != 2 {
+		t.Errorf("failed = %d, want 2", failed)
+	}
+}
+
+func TestDeleteResourcesTearsDownCDN(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	cf, waf := NewMockcloudFrontAPI(ctrl), NewMockwafAPI(ctrl)
+	c.cloudfront, c.waf = cf, waf
+	config := testConfig("")
+	config.CDN.Enabled = true
+
+	cf.EXPECT().ListDistributionsPages(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *cloudfront.ListDistributionsInput, fn func(*cloudfront.ListDistributionsOutput, bool) bool) error {
+		fn(&cloudfront.ListDistributionsOutput{DistributionList: &cloudfront.DistributionList{Items: []*cloudfront.DistributionSummary{
+			{Id: aws.String("EOTHER"), Comment: aws.String("other")},
+			{Id: aws.String("E1DIST"), Comment: aws.String("hello")},
+		}}}, true)
+		return nil
+	})
+	gomock.InOrder(
+		cf.EXPECT().GetDistributionConfig(&cloudfront.GetDistributionConfigInput{Id: aws.String("E1DIST")}).Return(&cloudfront.GetDistributionConfigOutput{
+			ETag:               aws.String("v1"),
+			DistributionConfig: &cloudfront.DistributionConfig{Enabled: aws.Bool(true)},
+		}, nil),
+		cf.EXPECT().UpdateDistribution(gomock.Any()).DoAndReturn(func(input *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error) {
+			if *input.IfMatch != "v1" || *input.DistributionConfig.Enabled {
+				t.Errorf("UpdateDistribution(%v), want v1 disabled", input)
+			}
+			return &cloudfront.UpdateDistributionOutput{ETag: aws.String("v2")}, nil
+		}),
+		cf.EXPECT().WaitUntilDistributionDeployed(gomock.Any()).Return(nil),
+		cf.EXPECT().DeleteDistribution(&cloudfront.DeleteDistributionInput{Id: aws.String("E1DIST"), IfMatch: aws.String("v2")}).Return(&cloudfront.DeleteDistributionOutput{}, nil),
+		cf.EXPECT().ListOriginAccessControls(gomock.Any()).Return(&cloudfront.ListOriginAccessControlsOutput{OriginAccessControlList: &cloudfront.OriginAccessControlList{
+			Items: []*cloudfront.OriginAccessControlSummary{{Id: aws.String("E2OAC"), Name: aws.String("hello")}},
+		}}, nil),
+		cf.EXPECT().GetOriginAccessControl(gomock.Any()).Return(&cloudfront.GetOriginAccessControlOutput{ETag: aws.String("o1")}, nil),
+		cf.EXPECT().DeleteOriginAccessControl(&cloudfront.DeleteOriginAccessControlInput{Id: aws.String("E2OAC"), IfMatch: aws.String("o1")}).Return(&cloudfront.DeleteOriginAccessControlOutput{}, nil),
+	)
+	// The web ACL of another function is left alone
+	waf.EXPECT().ListWebACLs(gomock.Any()).Return(&wafv2.ListWebACLsOutput{WebACLs: []*wafv2.WebACLSummary{
+		{Name: aws.String("other-waf"), Id: aws.String("2"), LockToken: aws.String("t2")},
+		{Name: aws.String("hello-waf"), Id: aws.String("1"), LockToken: aws.String("t1")},
+	}}, nil)
+	waf.EXPECT().DeleteWebACL(&wafv2.DeleteWebACLInput{
+		Name: aws.String("hello-waf"), Id: aws.String("1"), LockToken: aws.String("t1"), Scope: aws.String("CLOUDFRONT"),
+	}).Return(&wafv2.DeleteWebACLOutput{}, nil)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesKeepsSharedWebACL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	cf := NewMockcloudFrontAPI(ctrl)
+	c.cloudfront, c.waf = cf, NewMockwafAPI(ctrl)
+	config := testConfig("")
+	config.CDN.Enabled = true
+	config.CDN.WebACLARN = "arn:aws:wafv2:us-east-1:123:global/webacl/shared/1"
+
+	// Nothing left from an earlier run: both count as deleted
+	cf.EXPECT().ListDistributionsPages(gomock.Any(), gomock.Any()).Return(nil)
+	cf.EXPECT().ListOriginAccessControls(gomock.Any()).Return(&cloudfront.ListOriginAccessControlsOutput{OriginAccessControlList: &cloudfront.OriginAccessControlList{}}, nil)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+// expectSetupTags answers GetBucketTagging with the tags setup puts on the
+// buckets it creates for project shop.
+func expectSetupTags(s3Mock *Mocks3API) {
+	s3Mock.EXPECT().GetBucketTagging(&s3.GetBucketTaggingInput{Bucket: aws.String("hello-assets")}).Return(&s3.GetBucketTaggingOutput{TagSet: []*s3.Tag{
+		{Key: aws.String(appconfig.CreatedByTag), Value: aws.String(appconfig.CreatedBy)},
+		{Key: aws.String(appconfig.ProjectTag), Value: aws.String("shop")},
+	}}, nil)
+}
+
+func TestDeleteAssetsBucketEmptiesItFirst(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s3Mock := NewMocks3API(ctrl)
+	c := clients{s3: s3Mock}
+	config := testConfig("")
+	config.Project = "shop"
+	expectSetupTags(s3Mock)
+
+	s3Mock.EXPECT().ListObjectsV2Pages(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+		if fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String("static/a.css")}, {Key: aws.String("static/b.js")}}}, false) {
+			fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String("static/c.png")}}}, true)
+		}
+		return nil
+	})
+	var deleted []string
+	s3Mock.EXPECT().DeleteObjects(gomock.Any()).Times(2).DoAndReturn(func(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+		for _, object := range input.Delete.Objects {
+			deleted = append(deleted, *object.Key)
+		}
+		return &s3.DeleteObjectsOutput{}, nil
+	})
+	s3Mock.EXPECT().DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("hello-assets")}).Return(&s3.DeleteBucketOutput{}, nil)
+
+	if err := deleteAssetsBucket(config, c, "hello-assets"); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 3 {
+		t.Errorf("deleted %v, want all three objects", deleted)
+	}
+}
+
+func TestDeleteAssetsBucketStopsOnFailedObject(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s3Mock := NewMocks3API(ctrl)
+	c := clients{s3: s3Mock}
+	config := testConfig("")
+	config.Project = "shop"
+	expectSetupTags(s3Mock)
+
+	s3Mock.EXPECT().ListObjectsV2Pages(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+		fn(&s3.ListObjectsV2Output{Contents: []*s3.Object{{Key: aws.String("static/a.css")}}}, true)
+		return nil
+	})
+	s3Mock.EXPECT().DeleteObjects(gomock.Any()).Return(&s3.DeleteObjectsOutput{
+		Errors: []*s3.Error{{Key: aws.String("static/a.css"), Message: aws.String("Access Denied")}},
+	}, nil)
+
+	// DeleteBucket would only fail with BucketNotEmpty
+	if err := deleteAssetsBucket(config, c, "hello-assets"); err == nil || !strings.Contains(err.Error(), "Access Denied") {
+		t.Errorf("err = %v, want the object's error", err)
+	}
+}
+
+func TestDeleteAssetsBucketKeepsBucketsSetupDidNotCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s3Mock := NewMocks3API(ctrl)
+	c := clients{s3: s3Mock}
+	config := testConfig("")
+	config.Project = "shop"
+
+	s3Mock.EXPECT().GetBucketTagging(gomock.Any()).Return(nil, awserr.New("NoSuchTagSet", "The TagSet does not exist", nil))
+	if err := deleteAssetsBucket(config, c, "hello-assets"); !errors.Is(err, errNotCreated) {
+		t.Errorf("err = %v for an untagged bucket, want errNotCreated", err)
+	}
+	// Another project's bucket of the same name is not this one's either
+	s3Mock.EXPECT().GetBucketTagging(gomock.Any()).Return(&s3.GetBucketTaggingOutput{TagSet: []*s3.Tag{
+		{Key: aws.String(appconfig.CreatedByTag), Value: aws.String(appconfig.CreatedBy)},
+		{Key: aws.String(appconfig.ProjectTag), Value: aws.String("billing")},
+	}}, nil)
+	if err := deleteAssetsBucket(config, c, "hello-assets"); !errors.Is(err, errNotCreated) {
+		t.Errorf("err = %v for another project's bucket, want errNotCreated", err)
+	}
+}
+
+func TestDeleteResourcesRemovesRegionFromDNS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	r := NewMockroute53API(ctrl)
+	c.route53 = r
+	config := testConfig("")
+	config.AWS.Region = "eu-west-1"
+	config.DNS = appconfig.DNS{ZoneID: "Z1", Name: "api.example.com", Target: "d-2.example.com", Failover: "SECONDARY"}
+
+	// Records of the other regions share the name and are left alone
+	record := &route53.ResourceRecordSet{Name: aws.String("api.example.com."), SetIdentifier: aws.String("eu-west-1"), Type: aws.String("CNAME")}
+	r.EXPECT().ListResourceRecordSets(gomock.Any()).Return(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: []*route53.ResourceRecordSet{
+		{Name: aws.String("api.example.com."), SetIdentifier: aws.String("ap-south-1"), Type: aws.String("CNAME")},
+		record,
+	}}, nil)
+	r.EXPECT().ChangeResourceRecordSets(gomock.Any()).DoAndReturn(func(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+		change := input.ChangeBatch.Changes[0]
+		if *change.Action != "DELETE" || change.ResourceRecordSet != record {
+			t.Errorf("change = %v, want the region's record deleted as listed", change)
+		}
+		return &route53.ChangeResourceRecordSetsOutput{}, nil
+	})
+	r.EXPECT().ListHealthChecksPages(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool) error {
+		fn(&route53.ListHealthChecksOutput{HealthChecks: []*route53.HealthCheck{
+			{Id: aws.String("hc-1")},
+			{Id: aws.String("hc-2")},
+		}}, true)
+		return nil
+	})
+	r.EXPECT().ListTagsForResources(gomock.Any()).Return(&route53.ListTagsForResourcesOutput{ResourceTagSets: []*route53.ResourceTagSet{
+		{ResourceId: aws.String("hc-1"), Tags: []*route53.Tag{{Key: aws.String("Name"), Value: aws.String("hello-us-east-1")}}},
+		{ResourceId: aws.String("hc-2"), Tags: []*route53.Tag{{Key: aws.String("Name"), Value: aws.String("hello-eu-west-1")}}},
+	}}, nil)
+	r.EXPECT().DeleteHealthCheck(&route53.DeleteHealthCheckInput{HealthCheckId: aws.String("hc-2")}).Return(&route53.DeleteHealthCheckOutput{}, nil)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesDeletesHTTPAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	a := NewMockapiGatewayAPI(ctrl)
+	c.apigateway = a
+	config := testConfig("")
+	config.API.Enabled = true
+	config.Project = "hello"
+
+	gomock.InOrder(
+		a.EXPECT().GetApis(&apigatewayv2.GetApisInput{}).Return(&apigatewayv2.GetApisOutput{
+			Items:     []*apigatewayv2.Api{{ApiId: aws.String("a1"), Name: aws.String("other-api")}},
+			NextToken: aws.String("page-2"),
+		}, nil),
+		a.EXPECT().GetApis(&apigatewayv2.GetApisInput{NextToken: aws.String("page-2")}).Return(&apigatewayv2.GetApisOutput{
+			Items: []*apigatewayv2.Api{{ApiId: aws.String("a2"), Name: aws.String("hello-api"), Tags: aws.StringMap(map[string]string{
+				appconfig.CreatedByTag: appconfig.CreatedBy, appconfig.ProjectTag: "hello",
+			})}},
+		}, nil),
+		a.EXPECT().DeleteApi(&apigatewayv2.DeleteApiInput{ApiId: aws.String("a2")}).Return(&apigatewayv2.DeleteApiOutput{}, nil),
+	)
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteHTTPAPIKeepsUntaggedAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, _, _, _ := newClients(ctrl)
+	a := NewMockapiGatewayAPI(ctrl)
+	c.apigateway = a
+	config := testConfig("")
+	config.API.Enabled = true
+
+	a.EXPECT().GetApis(gomock.Any()).Return(&apigatewayv2.GetApisOutput{
+		Items: []*apigatewayv2.Api{{ApiId: aws.String("a2"), Name: aws.String("hello-api")}},
+	}, nil)
+
+	if err := deleteHTTPAPI(config, c); !errors.Is(err, errNotCreated) {
+		t.Errorf("deleteHTTPAPI = %v, want errNotCreated", err)
+	}
+}
+
+func TestDeleteResourcesUnsubscribesFromSNS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	s := NewMocksnsAPI(ctrl)
+	c.sns = s
+	config := testConfig("")
+	config.Triggers.SNS.Topic = "order-events"
+
+	s.EXPECT().ListSubscriptionsPages(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *sns.ListSubscriptionsInput, fn func(*sns.ListSubscriptionsOutput, bool) bool) error {
+		fn(&sns.ListSubscriptionsOutput{Subscriptions: []*sns.Subscription{
+			{SubscriptionArn: aws.String("arn:aws:sns:us-east-1:123:order-events:other"), Protocol: aws.String("lambda"), TopicArn: aws.String("arn:aws:sns:us-east-1:123:order-events"), Endpoint: aws.String("arn:aws:lambda:us-east-1:123:function:hello-staging")},
+			{SubscriptionArn: aws.String("arn:aws:sns:us-east-1:123:order-events:mine"), Protocol: aws.String("lambda"), TopicArn: aws.String("arn:aws:sns:us-east-1:123:order-events"), Endpoint: aws.String("arn:aws:lambda:us-east-1:123:function:hello")},
+		}}, true)
+		return nil
+	})
+	gomock.InOrder(
+		s.EXPECT().Unsubscribe(&sns.UnsubscribeInput{SubscriptionArn: aws.String("arn:aws:sns:us-east-1:123:order-events:mine")}).Return(&sns.UnsubscribeOutput{}, nil),
+		l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil),
+	)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesRemovesS3Notification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	s := NewMocks3API(ctrl)
+	c.s3 = s
+	config := testConfig("")
+	config.Triggers.S3.Bucket = "uploads"
+
+	other := &s3.LambdaFunctionConfiguration{Id: aws.String("thumbnails"), LambdaFunctionArn: aws.String("arn:aws:lambda:us-east-1:123:function:thumbnails")}
+	s.EXPECT().GetBucketNotificationConfiguration(&s3.GetBucketNotificationConfigurationRequest{Bucket: aws.String("uploads")}).Return(&s3.NotificationConfiguration{
+		LambdaFunctionConfigurations: []*s3.LambdaFunctionConfiguration{
+			{Id: aws.String("hello-trigger"), LambdaFunctionArn: aws.String("arn:aws:lambda:us-east-1:123:function:hello")},
+			other,
+		},
+	}, nil)
+	s.EXPECT().PutBucketNotificationConfiguration(gomock.Any()).DoAndReturn(func(input *s3.PutBucketNotificationConfigurationInput) (*s3.PutBucketNotificationConfigurationOutput, error) {
+		if got := input.NotificationConfiguration.LambdaFunctionConfigurations; len(got) != 1 || got[0] != other {
+			t.Errorf("notifications put back = %v, want only the other function's", got)
+		}
+		return &s3.PutBucketNotificationConfigurationOutput{}, nil
+	})
+	l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesDeletesKinesisMapping(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	config := testConfig("")
+	config.Triggers.Kinesis.Stream = "clicks"
+
+	l.EXPECT().ListEventSourceMappingsPages(&lambda.ListEventSourceMappingsInput{FunctionName: aws.String("hello")}, gomock.Any()).
+		DoAndReturn(func(_ *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+			fn(&lambda.ListEventSourceMappingsOutput{EventSourceMappings: []*lambda.EventSourceMappingConfiguration{
+				{UUID: aws.String("jobs"), EventSourceArn: aws.String("arn:aws:sqs:us-east-1:123:hello-jobs")},
+				{UUID: aws.String("clicks"), EventSourceArn: aws.String("arn:aws:kinesis:us-east-1:123:stream/clicks")},
+			}}, true)
+			return nil
+		})
+	gomock.InOrder(
+		l.EXPECT().DeleteEventSourceMapping(&lambda.DeleteEventSourceMappingInput{UUID: aws.String("clicks")}).Return(&lambda.EventSourceMappingConfiguration{}, nil),
+		l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil),
+	)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
+
+func TestDeleteResourcesDeletesFunctionURL(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c, l, e, _ := newClients(ctrl)
+	config := testConfig("")
+	config.FunctionURL.Enabled = true
+
+	gomock.InOrder(
+		l.EXPECT().DeleteFunctionUrlConfig(&lambda.DeleteFunctionUrlConfigInput{FunctionName: aws.String("hello")}).
+			Return(nil, awserr.New(lambda.ErrCodeResourceNotFoundException, "no URL", nil)),
+		l.EXPECT().DeleteFunction(gomock.Any()).Return(&lambda.DeleteFunctionOutput{}, nil),
+	)
+	e.EXPECT().DeleteRepository(gomock.Any()).Return(&ecr.DeleteRepositoryOutput{}, nil)
+
+	// A URL that is already gone counts as deleted
+	if failed := deleteResources(config, c); failed != 0 {
+		t.Errorf("failed = %d, want 0", failed)
+	}
+}
@@ -0,0 +1,706 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api.go
+//
+// Generated by this command:
+//
+//	mockgen -source=api.go -destination=mock_api_test.go -package=delete
+//
+
+// Package delete is a generated GoMock package.
+package delete
+
+import (
+	reflect "reflect"
+
+	apigatewayv2 "github.com/aws/aws-sdk-go/service/apigatewayv2"
+	cloudfront "github.com/aws/aws-sdk-go/service/cloudfront"
+	ecr "github.com/aws/aws-sdk-go/service/ecr"
+	eventbridge "github.com/aws/aws-sdk-go/service/eventbridge"
+	lambda "github.com/aws/aws-sdk-go/service/lambda"
+	route53 "github.com/aws/aws-sdk-go/service/route53"
+	s3 "github.com/aws/aws-sdk-go/service/s3"
+	sns "github.com/aws/aws-sdk-go/service/sns"
+	wafv2 "github.com/aws/aws-sdk-go/service/wafv2"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MocklambdaAPI is a mock of lambdaAPI interface.
+type MocklambdaAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MocklambdaAPIMockRecorder
+}
+
+// MocklambdaAPIMockRecorder is the mock recorder for MocklambdaAPI.
+type MocklambdaAPIMockRecorder struct {
+	mock *MocklambdaAPI
+}
+
+// NewMocklambdaAPI creates a new mock instance.
+func NewMocklambdaAPI(ctrl *gomock.Controller) *MocklambdaAPI {
+	mock := &MocklambdaAPI{ctrl: ctrl}
+	mock.recorder = &MocklambdaAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocklambdaAPI) EXPECT() *MocklambdaAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteEventSourceMapping mocks base method.
+func (m *MocklambdaAPI) DeleteEventSourceMapping(input *lambda.DeleteEventSourceMappingInput) (*lambda.EventSourceMappingConfiguration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteEventSourceMapping", input)
+	ret0, _ := ret[0].(*lambda.EventSourceMappingConfiguration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteEventSourceMapping indicates an expected call of DeleteEventSourceMapping.
+func (mr *MocklambdaAPIMockRecorder) DeleteEventSourceMapping(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteEventSourceMapping", reflect.TypeOf((*MocklambdaAPI)(nil).DeleteEventSourceMapping), input)
+}
+
+// DeleteFunction mocks base method.
+func (m *MocklambdaAPI) DeleteFunction(input *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFunction", input)
+	ret0, _ := ret[0].(*lambda.DeleteFunctionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFunction indicates an expected call of DeleteFunction.
+func (mr *MocklambdaAPIMockRecorder) DeleteFunction(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFunction", reflect.TypeOf((*MocklambdaAPI)(nil).DeleteFunction), input)
+}
+
+// DeleteFunctionUrlConfig mocks base method.
+func (m *MocklambdaAPI) DeleteFunctionUrlConfig(input *lambda.DeleteFunctionUrlConfigInput) (*lambda.DeleteFunctionUrlConfigOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFunctionUrlConfig", input)
+	ret0, _ := ret[0].(*lambda.DeleteFunctionUrlConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFunctionUrlConfig indicates an expected call of DeleteFunctionUrlConfig.
+func (mr *MocklambdaAPIMockRecorder) DeleteFunctionUrlConfig(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFunctionUrlConfig", reflect.TypeOf((*MocklambdaAPI)(nil).DeleteFunctionUrlConfig), input)
+}
+
+// ListEventSourceMappingsPages mocks base method.
+func (m *MocklambdaAPI) ListEventSourceMappingsPages(input *lambda.ListEventSourceMappingsInput, fn func(*lambda.ListEventSourceMappingsOutput, bool) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListEventSourceMappingsPages", input, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListEventSourceMappingsPages indicates an expected call of ListEventSourceMappingsPages.
+func (mr *MocklambdaAPIMockRecorder) ListEventSourceMappingsPages(input, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListEventSourceMappingsPages", reflect.TypeOf((*MocklambdaAPI)(nil).ListEventSourceMappingsPages), input, fn)
+}
+
+// MockecrAPI is a mock of ecrAPI interface.
+type MockecrAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockecrAPIMockRecorder
+}
+
+// MockecrAPIMockRecorder is the mock recorder for MockecrAPI.
+type MockecrAPIMockRecorder struct {
+	mock *MockecrAPI
+}
+
+// NewMockecrAPI creates a new mock instance.
+func NewMockecrAPI(ctrl *gomock.Controller) *MockecrAPI {
+	mock := &MockecrAPI{ctrl: ctrl}
+	mock.recorder = &MockecrAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockecrAPI) EXPECT() *MockecrAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteRepository mocks base method.
+func (m *MockecrAPI) DeleteRepository(input *ecr.DeleteRepositoryInput) (*ecr.DeleteRepositoryOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRepository", input)
+	ret0, _ := ret[0].(*ecr.DeleteRepositoryOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRepository indicates an expected call of DeleteRepository.
+func (mr *MockecrAPIMockRecorder) DeleteRepository(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRepository", reflect.TypeOf((*MockecrAPI)(nil).DeleteRepository), input)
+}
+
+// MockeventsAPI is a mock of eventsAPI interface.
+type MockeventsAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockeventsAPIMockRecorder
+}
+
+// MockeventsAPIMockRecorder is the mock recorder for MockeventsAPI.
+type MockeventsAPIMockRecorder struct {
+	mock *MockeventsAPI
+}
+
+// NewMockeventsAPI creates a new mock instance.
+func NewMockeventsAPI(ctrl *gomock.Controller) *MockeventsAPI {
+	mock := &MockeventsAPI{ctrl: ctrl}
+	mock.recorder = &MockeventsAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockeventsAPI) EXPECT() *MockeventsAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteRule mocks base method.
+func (m *MockeventsAPI) DeleteRule(input *eventbridge.DeleteRuleInput) (*eventbridge.DeleteRuleOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRule", input)
+	ret0, _ := ret[0].(*eventbridge.DeleteRuleOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteRule indicates an expected call of DeleteRule.
+func (mr *MockeventsAPIMockRecorder) DeleteRule(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRule", reflect.TypeOf((*MockeventsAPI)(nil).DeleteRule), input)
+}
+
+// RemoveTargets mocks base method.
+func (m *MockeventsAPI) RemoveTargets(input *eventbridge.RemoveTargetsInput) (*eventbridge.RemoveTargetsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveTargets", input)
+	ret0, _ := ret[0].(*eventbridge.RemoveTargetsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveTargets indicates an expected call of RemoveTargets.
+func (mr *MockeventsAPIMockRecorder) RemoveTargets(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveTargets", reflect.TypeOf((*MockeventsAPI)(nil).RemoveTargets), input)
+}
+
+// MockcloudFrontAPI is a mock of cloudFrontAPI interface.
+type MockcloudFrontAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockcloudFrontAPIMockRecorder
+}
+
+// MockcloudFrontAPIMockRecorder is the mock recorder for MockcloudFrontAPI.
+type MockcloudFrontAPIMockRecorder struct {
+	mock *MockcloudFrontAPI
+}
+
+// NewMockcloudFrontAPI creates a new mock instance.
+func NewMockcloudFrontAPI(ctrl *gomock.Controller) *MockcloudFrontAPI {
+	mock := &MockcloudFrontAPI{ctrl: ctrl}
+	mock.recorder = &MockcloudFrontAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockcloudFrontAPI) EXPECT() *MockcloudFrontAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteDistribution mocks base method.
+func (m *MockcloudFrontAPI) DeleteDistribution(input *cloudfront.DeleteDistributionInput) (*cloudfront.DeleteDistributionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDistribution", input)
+	ret0, _ := ret[0].(*cloudfront.DeleteDistributionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteDistribution indicates an expected call of DeleteDistribution.
+func (mr *MockcloudFrontAPIMockRecorder) DeleteDistribution(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDistribution", reflect.TypeOf((*MockcloudFrontAPI)(nil).DeleteDistribution), input)
+}
+
+// DeleteOriginAccessControl mocks base method.
+func (m *MockcloudFrontAPI) DeleteOriginAccessControl(input *cloudfront.DeleteOriginAccessControlInput) (*cloudfront.DeleteOriginAccessControlOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOriginAccessControl", input)
+	ret0, _ := ret[0].(*cloudfront.DeleteOriginAccessControlOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteOriginAccessControl indicates an expected call of DeleteOriginAccessControl.
+func (mr *MockcloudFrontAPIMockRecorder) DeleteOriginAccessControl(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOriginAccessControl", reflect.TypeOf((*MockcloudFrontAPI)(nil).DeleteOriginAccessControl), input)
+}
+
+// GetDistributionConfig mocks base method.
+func (m *MockcloudFrontAPI) GetDistributionConfig(input *cloudfront.GetDistributionConfigInput) (*cloudfront.GetDistributionConfigOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDistributionConfig", input)
+	ret0, _ := ret[0].(*cloudfront.GetDistributionConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDistributionConfig indicates an expected call of GetDistributionConfig.
+func (mr *MockcloudFrontAPIMockRecorder) GetDistributionConfig(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDistributionConfig", reflect.TypeOf((*MockcloudFrontAPI)(nil).GetDistributionConfig), input)
+}
+
+// GetOriginAccessControl mocks base method.
+func (m *MockcloudFrontAPI) GetOriginAccessControl(input *cloudfront.GetOriginAccessControlInput) (*cloudfront.GetOriginAccessControlOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOriginAccessControl", input)
+	ret0, _ := ret[0].(*cloudfront.GetOriginAccessControlOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOriginAccessControl indicates an expected call of GetOriginAccessControl.
+func (mr *MockcloudFrontAPIMockRecorder) GetOriginAccessControl(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOriginAccessControl", reflect.TypeOf((*MockcloudFrontAPI)(nil).GetOriginAccessControl), input)
+}
+
+// ListDistributionsPages mocks base method.
+func (m *MockcloudFrontAPI) ListDistributionsPages(input *cloudfront.ListDistributionsInput, fn func(*cloudfront.ListDistributionsOutput, bool) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDistributionsPages", input, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListDistributionsPages indicates an expected call of ListDistributionsPages.
+func (mr *MockcloudFrontAPIMockRecorder) ListDistributionsPages(input, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDistributionsPages", reflect.TypeOf((*MockcloudFrontAPI)(nil).ListDistributionsPages), input, fn)
+}
+
+// ListOriginAccessControls mocks base method.
+func (m *MockcloudFrontAPI) ListOriginAccessControls(input *cloudfront.ListOriginAccessControlsInput) (*cloudfront.ListOriginAccessControlsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOriginAccessControls", input)
+	ret0, _ := ret[0].(*cloudfront.ListOriginAccessControlsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOriginAccessControls indicates an expected call of ListOriginAccessControls.
+func (mr *MockcloudFrontAPIMockRecorder) ListOriginAccessControls(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOriginAccessControls", reflect.TypeOf((*MockcloudFrontAPI)(nil).ListOriginAccessControls), input)
+}
+
+// UpdateDistribution mocks base method.
+func (m *MockcloudFrontAPI) UpdateDistribution(input *cloudfront.UpdateDistributionInput) (*cloudfront.UpdateDistributionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDistribution", input)
+	ret0, _ := ret[0].(*cloudfront.UpdateDistributionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateDistribution indicates an expected call of UpdateDistribution.
+func (mr *MockcloudFrontAPIMockRecorder) UpdateDistribution(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDistribution", reflect.TypeOf((*MockcloudFrontAPI)(nil).UpdateDistribution), input)
+}
+
+// WaitUntilDistributionDeployed mocks base method.
+func (m *MockcloudFrontAPI) WaitUntilDistributionDeployed(input *cloudfront.GetDistributionInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitUntilDistributionDeployed", input)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WaitUntilDistributionDeployed indicates an expected call of WaitUntilDistributionDeployed.
+func (mr *MockcloudFrontAPIMockRecorder) WaitUntilDistributionDeployed(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitUntilDistributionDeployed", reflect.TypeOf((*MockcloudFrontAPI)(nil).WaitUntilDistributionDeployed), input)
+}
+
+// MockwafAPI is a mock of wafAPI interface.
+type MockwafAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockwafAPIMockRecorder
+}
+
+// MockwafAPIMockRecorder is the mock recorder for MockwafAPI.
+type MockwafAPIMockRecorder struct {
+	mock *MockwafAPI
+}
+
+// NewMockwafAPI creates a new mock instance.
+func NewMockwafAPI(ctrl *gomock.Controller) *MockwafAPI {
+	mock := &MockwafAPI{ctrl: ctrl}
+	mock.recorder = &MockwafAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockwafAPI) EXPECT() *MockwafAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteWebACL mocks base method.
+func (m *MockwafAPI) DeleteWebACL(input *wafv2.DeleteWebACLInput) (*wafv2.DeleteWebACLOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebACL", input)
+	ret0, _ := ret[0].(*wafv2.DeleteWebACLOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteWebACL indicates an expected call of DeleteWebACL.
+func (mr *MockwafAPIMockRecorder) DeleteWebACL(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebACL", reflect.TypeOf((*MockwafAPI)(nil).DeleteWebACL), input)
+}
+
+// ListWebACLs mocks base method.
+func (m *MockwafAPI) ListWebACLs(input *wafv2.ListWebACLsInput) (*wafv2.ListWebACLsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebACLs", input)
+	ret0, _ := ret[0].(*wafv2.ListWebACLsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebACLs indicates an expected call of ListWebACLs.
+func (mr *MockwafAPIMockRecorder) ListWebACLs(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebACLs", reflect.TypeOf((*MockwafAPI)(nil).ListWebACLs), input)
+}
+
+// Mocks3API is a mock of s3API interface.
+type Mocks3API struct {
+	ctrl     *gomock.Controller
+	recorder *Mocks3APIMockRecorder
+}
+
+// Mocks3APIMockRecorder is the mock recorder for Mocks3API.
+type Mocks3APIMockRecorder struct {
+	mock *Mocks3API
+}
+
+// NewMocks3API creates a new mock instance.
+func NewMocks3API(ctrl *gomock.Controller) *Mocks3API {
+	mock := &Mocks3API{ctrl: ctrl}
+	mock.recorder = &Mocks3APIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mocks3API) EXPECT() *Mocks3APIMockRecorder {
+	return m.recorder
+}
+
+// DeleteBucket mocks base method.
+func (m *Mocks3API) DeleteBucket(input *s3.DeleteBucketInput) (*s3.DeleteBucketOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucket", input)
+	ret0, _ := ret[0].(*s3.DeleteBucketOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteBucket indicates an expected call of DeleteBucket.
+func (mr *Mocks3APIMockRecorder) DeleteBucket(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucket", reflect.TypeOf((*Mocks3API)(nil).DeleteBucket), input)
+}
+
+// DeleteObjects mocks base method.
+func (m *Mocks3API) DeleteObjects(input *s3.DeleteObjectsInput) (*s3.DeleteObjectsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteObjects", input)
+	ret0, _ := ret[0].(*s3.DeleteObjectsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteObjects indicates an expected call of DeleteObjects.
+func (mr *Mocks3APIMockRecorder) DeleteObjects(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteObjects", reflect.TypeOf((*Mocks3API)(nil).DeleteObjects), input)
+}
+
+// GetBucketNotificationConfiguration mocks base method.
+func (m *Mocks3API) GetBucketNotificationConfiguration(input *s3.GetBucketNotificationConfigurationRequest) (*s3.NotificationConfiguration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBucketNotificationConfiguration", input)
+	ret0, _ := ret[0].(*s3.NotificationConfiguration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBucketNotificationConfiguration indicates an expected call of GetBucketNotificationConfiguration.
+func (mr *Mocks3APIMockRecorder) GetBucketNotificationConfiguration(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBucketNotificationConfiguration", reflect.TypeOf((*Mocks3API)(nil).GetBucketNotificationConfiguration), input)
+}
+
+// GetBucketTagging mocks base method.
+func (m *Mocks3API) GetBucketTagging(input *s3.GetBucketTaggingInput) (*s3.GetBucketTaggingOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBucketTagging", input)
+	ret0, _ := ret[0].(*s3.GetBucketTaggingOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBucketTagging indicates an expected call of GetBucketTagging.
+func (mr *Mocks3APIMockRecorder) GetBucketTagging(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBucketTagging", reflect.TypeOf((*Mocks3API)(nil).GetBucketTagging), input)
+}
+
+// ListObjectsV2Pages mocks base method.
+func (m *Mocks3API) ListObjectsV2Pages(input *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListObjectsV2Pages", input, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListObjectsV2Pages indicates an expected call of ListObjectsV2Pages.
+func (mr *Mocks3APIMockRecorder) ListObjectsV2Pages(input, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListObjectsV2Pages", reflect.TypeOf((*Mocks3API)(nil).ListObjectsV2Pages), input, fn)
+}
+
+// PutBucketNotificationConfiguration mocks base method.
+func (m *Mocks3API) PutBucketNotificationConfiguration(input *s3.PutBucketNotificationConfigurationInput) (*s3.PutBucketNotificationConfigurationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutBucketNotificationConfiguration", input)
+	ret0, _ := ret[0].(*s3.PutBucketNotificationConfigurationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutBucketNotificationConfiguration indicates an expected call of PutBucketNotificationConfiguration.
+func (mr *Mocks3APIMockRecorder) PutBucketNotificationConfiguration(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutBucketNotificationConfiguration", reflect.TypeOf((*Mocks3API)(nil).PutBucketNotificationConfiguration), input)
+}
+
+// Mockroute53API is a mock of route53API interface.
+type Mockroute53API struct {
+	ctrl     *gomock.Controller
+	recorder *Mockroute53APIMockRecorder
+}
+
+// Mockroute53APIMockRecorder is the mock recorder for Mockroute53API.
+type Mockroute53APIMockRecorder struct {
+	mock *Mockroute53API
+}
+
+// NewMockroute53API creates a new mock instance.
+func NewMockroute53API(ctrl *gomock.Controller) *Mockroute53API {
+	mock := &Mockroute53API{ctrl: ctrl}
+	mock.recorder = &Mockroute53APIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *Mockroute53API) EXPECT() *Mockroute53APIMockRecorder {
+	return m.recorder
+}
+
+// ChangeResourceRecordSets mocks base method.
+func (m *Mockroute53API) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeResourceRecordSets", input)
+	ret0, _ := ret[0].(*route53.ChangeResourceRecordSetsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeResourceRecordSets indicates an expected call of ChangeResourceRecordSets.
+func (mr *Mockroute53APIMockRecorder) ChangeResourceRecordSets(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeResourceRecordSets", reflect.TypeOf((*Mockroute53API)(nil).ChangeResourceRecordSets), input)
+}
+
+// DeleteHealthCheck mocks base method.
+func (m *Mockroute53API) DeleteHealthCheck(input *route53.DeleteHealthCheckInput) (*route53.DeleteHealthCheckOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteHealthCheck", input)
+	ret0, _ := ret[0].(*route53.DeleteHealthCheckOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteHealthCheck indicates an expected call of DeleteHealthCheck.
+func (mr *Mockroute53APIMockRecorder) DeleteHealthCheck(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHealthCheck", reflect.TypeOf((*Mockroute53API)(nil).DeleteHealthCheck), input)
+}
+
+// ListHealthChecksPages mocks base method.
+func (m *Mockroute53API) ListHealthChecksPages(input *route53.ListHealthChecksInput, fn func(*route53.ListHealthChecksOutput, bool) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListHealthChecksPages", input, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListHealthChecksPages indicates an expected call of ListHealthChecksPages.
+func (mr *Mockroute53APIMockRecorder) ListHealthChecksPages(input, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHealthChecksPages", reflect.TypeOf((*Mockroute53API)(nil).ListHealthChecksPages), input, fn)
+}
+
+// ListResourceRecordSets mocks base method.
+func (m *Mockroute53API) ListResourceRecordSets(input *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListResourceRecordSets", input)
+	ret0, _ := ret[0].(*route53.ListResourceRecordSetsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListResourceRecordSets indicates an expected call of ListResourceRecordSets.
+func (mr *Mockroute53APIMockRecorder) ListResourceRecordSets(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListResourceRecordSets", reflect.TypeOf((*Mockroute53API)(nil).ListResourceRecordSets), input)
+}
+
+// ListTagsForResources mocks base method.
+func (m *Mockroute53API) ListTagsForResources(input *route53.ListTagsForResourcesInput) (*route53.ListTagsForResourcesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTagsForResources", input)
+	ret0, _ := ret[0].(*route53.ListTagsForResourcesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTagsForResources indicates an expected call of ListTagsForResources.
+func (mr *Mockroute53APIMockRecorder) ListTagsForResources(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTagsForResources", reflect.TypeOf((*Mockroute53API)(nil).ListTagsForResources), input)
+}
+
+// MockapiGatewayAPI is a mock of apiGatewayAPI interface.
+type MockapiGatewayAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockapiGatewayAPIMockRecorder
+}
+
+// MockapiGatewayAPIMockRecorder is the mock recorder for MockapiGatewayAPI.
+type MockapiGatewayAPIMockRecorder struct {
+	mock *MockapiGatewayAPI
+}
+
+// NewMockapiGatewayAPI creates a new mock instance.
+func NewMockapiGatewayAPI(ctrl *gomock.Controller) *MockapiGatewayAPI {
+	mock := &MockapiGatewayAPI{ctrl: ctrl}
+	mock.recorder = &MockapiGatewayAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockapiGatewayAPI) EXPECT() *MockapiGatewayAPIMockRecorder {
+	return m.recorder
+}
+
+// DeleteApi mocks base method.
+func (m *MockapiGatewayAPI) DeleteApi(input *apigatewayv2.DeleteApiInput) (*apigatewayv2.DeleteApiOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteApi", input)
+	ret0, _ := ret[0].(*apigatewayv2.DeleteApiOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteApi indicates an expected call of DeleteApi.
+func (mr *MockapiGatewayAPIMockRecorder) DeleteApi(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteApi", reflect.TypeOf((*MockapiGatewayAPI)(nil).DeleteApi), input)
+}
+
+// GetApis mocks base method.
+func (m *MockapiGatewayAPI) GetApis(input *apigatewayv2.GetApisInput) (*apigatewayv2.GetApisOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApis", input)
+	ret0, _ := ret[0].(*apigatewayv2.GetApisOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApis indicates an expected call of GetApis.
+func (mr *MockapiGatewayAPIMockRecorder) GetApis(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApis", reflect.TypeOf((*MockapiGatewayAPI)(nil).GetApis), input)
+}
+
+// MocksnsAPI is a mock of snsAPI interface.
+type MocksnsAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MocksnsAPIMockRecorder
+}
+
+// MocksnsAPIMockRecorder is the mock recorder for MocksnsAPI.
+type MocksnsAPIMockRecorder struct {
+	mock *MocksnsAPI
+}
+
+// NewMocksnsAPI creates a new mock instance.
+func NewMocksnsAPI(ctrl *gomock.Controller) *MocksnsAPI {
+	mock := &MocksnsAPI{ctrl: ctrl}
+	mock.recorder = &MocksnsAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocksnsAPI) EXPECT() *MocksnsAPIMockRecorder {
+	return m.recorder
+}
+
+// ListSubscriptionsPages mocks base method.
+func (m *MocksnsAPI) ListSubscriptionsPages(input *sns.ListSubscriptionsInput, fn func(*sns.ListSubscriptionsOutput, bool) bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSubscriptionsPages", input, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ListSubscriptionsPages indicates an expected call of ListSubscriptionsPages.
+func (mr *MocksnsAPIMockRecorder) ListSubscriptionsPages(input, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSubscriptionsPages", reflect.TypeOf((*MocksnsAPI)(nil).ListSubscriptionsPages), input, fn)
+}
+
+// Unsubscribe mocks base method.
+func (m *MocksnsAPI) Unsubscribe(input *sns.UnsubscribeInput) (*sns.UnsubscribeOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unsubscribe", input)
+	ret0, _ := ret[0].(*sns.UnsubscribeOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unsubscribe indicates an expected call of Unsubscribe.
+func (mr *MocksnsAPIMockRecorder) Unsubscribe(input any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unsubscribe", reflect.TypeOf((*MocksnsAPI)(nil).Unsubscribe), input)
+}
@@ -0,0 +1,152 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+// publishedVersions holds the version published for each alias by this
+// deploy.
+var publishedVersions = map[string]string{}
+
+// publishAliases gives every configured alias its own version of the
+// function. A version freezes the configuration it was published with, so
+// each alias's environment is applied to $LATEST, published and pointed to
+// in turn; $LATEST then gets the shared environment back, also when an
+// alias fails, so it is never left with another alias's environment.
+func publishAliases(ctx context.Context, functionName string) (err error) {
+	base, err := getFunctionEnvironment(ctx, functionName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		restoreErr := setFunctionEnvironment(ctx, functionName, base)
+		if restoreErr == nil {
+			restoreErr = waitForFunctionUpdated(ctx, functionName)
+		}
+		switch {
+		case restoreErr == nil:
+		case err == nil:
+			err = restoreErr
+		default:
+			err = fmt.Errorf("%v; restoring the environment of $LATEST also failed: %v", err, restoreErr)
+		}
+	}()
+
+	for _, name := range config.AliasNames() {
+		alias := config.Aliases[name]
+		overrides := maps.Clone(alias.Environment)
+		if err := pinSecrets(overrides); err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+		variables := maps.Clone(base)
+		maps.Copy(variables, overrides)
+		if err := setFunctionEnvironment(ctx, functionName, variables); err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+		if err := waitForFunctionUpdated(ctx, functionName); err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+
+		version, err := publishVersion(ctx, functionName, name)
+		if err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+		publishedVersions[name] = version
+		if name == config.Deploy.Alias && canaryWeight > 0 {
+			err = startCanary(ctx, functionName, name, version)
+		} else {
+			err = pointAlias(ctx, functionName, name, version)
+		}
+		if err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+		if err := setProvisionedConcurrency(ctx, functionName, name, alias.ProvisionedConcurrency); err != nil {
+			return fmt.Errorf("alias %s: %v", name, err)
+		}
+		if name != config.Deploy.Alias || canaryWeight == 0 {
+			fmt.Printf("Alias %s now points to version %s\n", name, version)
+		}
+	}
+	return nil
+}
+
+func setFunctionEnvironment(ctx context.Context, functionName string, variables map[string]string) error {
+	return updateConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+		Environment:  &lambdatypes.Environment{Variables: variables},
+	})
+}
+
+func publishVersion(ctx context.Context, functionName, alias string) (string, error) {
+	output, err := api.lambda.PublishVersion(ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(functionName),
+		Description:  aws.String(appconfig.AliasVersionDescription(alias)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to publish version: %v", err)
+	}
+	return aws.ToString(output.Version), nil
+}
+
+// pointAlias moves all of the alias's traffic to version, ending any canary,
+// and creates the alias on the first deploy.
+func pointAlias(ctx context.Context, functionName, alias, version string) error {
+	_, err := api.lambda.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+		RoutingConfig:   &lambdatypes.AliasRoutingConfiguration{},
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		if err != nil {
+			return fmt.Errorf("failed to update alias: %v", err)
+		}
+		return nil
+	}
+
+	_, err = api.lambda.CreateAlias(ctx, &lambda.CreateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(version),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create alias: %v", err)
+	}
+	return nil
+}
+
+// setProvisionedConcurrency configures the alias's provisioned concurrency,
+// or removes it when executions is 0.
+func setProvisionedConcurrency(ctx context.Context, functionName, alias string, executions int) error {
+	if executions == 0 {
+		_, err := api.lambda.DeleteProvisionedConcurrencyConfig(ctx, &lambda.DeleteProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(functionName),
+			Qualifier:    aws.String(alias),
+		})
+		var notFound *lambdatypes.ProvisionedConcurrencyConfigNotFoundException
+		if err != nil && !errors.As(err, &notFound) {
+			return fmt.Errorf("failed to remove provisioned concurrency: %v", err)
+		}
+		return nil
+	}
+
+	_, err := api.lambda.PutProvisionedConcurrencyConfig(ctx, &lambda.PutProvisionedConcurrencyConfigInput{
+		FunctionName:                    aws.String(functionName),
+		Qualifier:                       aws.String(alias),
+		ProvisionedConcurrentExecutions: aws.Int32(int32(executions)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set provisioned concurrency: %v", err)
+	}
+	return nil
+}
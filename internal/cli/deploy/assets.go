@@ -0,0 +1,70 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// checkAssetsDir is run before anything is built, so a typo in -assets does
+// not fail the deploy halfway.
+func checkAssetsDir(dir string) error {
+	if config.CDN.Assets.Bucket == "" {
+		return fmt.Errorf("-assets uploads to cdn.assets.bucket, which is not set")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("-assets: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("-assets: %s is not a directory", dir)
+	}
+	return nil
+}
+
+// uploadAssets syncs dir to the assets bucket under cdn.assets.path and
+// invalidates that path on the distribution. Files that are no longer in dir
+// stay in the bucket: pages cached from the previous deploy may still link
+// to them.
+func uploadAssets(w io.Writer, dir string) error {
+	syncCmd := exec.Command("aws", "s3", "sync", dir,
+		"s3://"+config.CDN.Assets.Bucket+"/"+config.CDN.AssetsPrefix(),
+		"--no-progress",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	syncCmd.Stdout = w
+	syncCmd.Stderr = w
+	if err := hostexec.Run(syncCmd); err != nil {
+		return fmt.Errorf("error syncing %s to the assets bucket: %v", dir, err)
+	}
+
+	// CloudFront is global; its API is in us-east-1
+	listCmd := exec.Command("aws", "cloudfront", "list-distributions",
+		"--query", fmt.Sprintf("DistributionList.Items[?Comment=='%s'].Id | [0]", config.CDNName()),
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", "us-east-1")
+	output, err := hostexec.Output(listCmd)
+	if err != nil {
+		return fmt.Errorf("error looking up the distribution: %v", err)
+	}
+	id := strings.TrimSpace(string(output))
+	if id == "" || id == "None" {
+		return fmt.Errorf("no CloudFront distribution for %s; run setup first", config.CDNName())
+	}
+
+	invalidateCmd := exec.Command("aws", "cloudfront", "create-invalidation",
+		"--distribution-id", id,
+		"--paths", config.CDN.AssetsPath(),
+		"--profile", config.AWS.Profile,
+		"--region", "us-east-1")
+	if output, err := hostexec.CombinedOutput(invalidateCmd); err != nil {
+		return fmt.Errorf("error invalidating %s: %v\n%s", config.CDN.AssetsPath(), err, output)
+	}
+	fmt.Fprintf(w, "Assets uploaded and %s invalidated on distribution %s\n", config.CDN.AssetsPath(), id)
+	return nil
+}
@@ -0,0 +1,446 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// liveTag records on the blue function which of the two functions currently
+// receives traffic from the triggers.
+const liveTag = "lambda-template:live"
+
+// deployBlueGreen deploys the new image to whichever of <name> and
+// <name>-green is idle, verifies it with a test invocation and then moves
+// the triggers over. The previously live function is left untouched so that
+// `deploy -swap` can move the triggers back instantly.
+func deployBlueGreen(ctx context.Context, awsAccountID string) error {
+	live, idle, err := blueGreenFunctions(awsAccountID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Live function is %s; deploying to %s\n", live, idle)
+
+	exists, err := functionExists(idle)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err := updateLambdaFunction(ctx, idle); err != nil {
+			return err
+		}
+		if err := waitForFunctionUpdated(ctx, idle); err != nil {
+			return err
+		}
+	} else {
+		if err := createIdleFunction(live, idle); err != nil {
+			return err
+		}
+		if err := waitForFunctionActive(ctx, idle); err != nil {
+			return err
+		}
+	}
+
+	if err := updateLambdaConfiguration(ctx, idle); err != nil {
+		return err
+	}
+	if err := waitForFunctionUpdated(ctx, idle); err != nil {
+		return err
+	}
+	if err := verifyDeployedImage(ctx, idle); err != nil {
+		return err
+	}
+
+	if err := verifyFunction(idle); err != nil {
+		return fmt.Errorf("verification of %s failed, triggers were not moved: %v", idle, err)
+	}
+
+	return moveTriggers(live, idle, awsAccountID)
+}
+
+// swapBlueGreen moves the triggers from the live function to the idle one
+// without deploying anything, which rolls back the last blue/green deploy.
+func swapBlueGreen(awsAccountID string) error {
+	live, idle, err := blueGreenFunctions(awsAccountID)
+	if err != nil {
+		return err
+	}
+	return moveTriggers(live, idle, awsAccountID)
+}
+
+func greenFunctionName() string {
+	return config.Lambda.FunctionName + "-green"
+}
+
+func functionARN(functionName, awsAccountID string) string {
+	return config.Partition().ARN("lambda", config.AWS.Region, awsAccountID, "function:"+functionName)
+}
+
+func blueGreenFunctions(awsAccountID string) (live, idle string, err error) {
+	cmd := exec.Command("aws", "lambda", "list-tags",
+		"--resource", functionARN(config.Lambda.FunctionName, awsAccountID),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(cmd)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read tags of %s: %v\nOutput: %s", config.Lambda.FunctionName, err, output)
+	}
+
+	var tags struct {
+		Tags map[string]string `json:"Tags"`
+	}
+	if err := json.Unmarshal(output, &tags); err != nil {
+		return "", "", fmt.Errorf("failed to parse tags: %v", err)
+	}
+
+	if tags.Tags[liveTag] == "green" {
+		return greenFunctionName(), config.Lambda.FunctionName, nil
+	}
+	return config.Lambda.FunctionName, greenFunctionName(), nil
+}
+
+func functionExists(functionName string) (bool, error) {
+	cmd := exec.Command("aws", "lambda", "get-function-configuration",
+		"--function-name", functionName,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(cmd)
+	if err == nil {
+		return true, nil
+	}
+	if strings.Contains(string(output), "ResourceNotFoundException") {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to get function %s: %v\nOutput: %s", functionName, err, output)
+}
+
+// createIdleFunction creates the second function of the pair with the same
+// execution role as the live one.
+func createIdleFunction(live, idle string) error {
+	cmd := exec.Command("aws", "lambda", "get-function-configuration",
+		"--function-name", live,
+		"--query", "Role",
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to get role of %s: %v", live, err)
+	}
+	var role string
+	if err := json.Unmarshal(output, &role); err != nil {
+		return fmt.Errorf("failed to parse role of %s: %v", live, err)
+	}
+
+	imageUri := repositoryURI + ":latest"
+	createCmd := exec.Command("aws", "lambda", "create-function",
+		"--function-name", idle,
+		"--package-type", "Image",
+		"--architectures", config.Build.Go.LambdaArchitecture(),
+		"--code", fmt.Sprintf("ImageUri=%s", imageUri),
+		"--role", role,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err = hostexec.CombinedOutput(createCmd)
+	if err != nil {
+		return fmt.Errorf("failed to create function %s: %v\nOutput: %s", idle, err, output)
+	}
+
+	fmt.Printf("Lambda function %s created successfully\n", idle)
+	return nil
+}
+
+// functionWaitTimeout matches the aws lambda wait commands, 300 polls a
+// second apart.
+const functionWaitTimeout = 5 * time.Minute
+
+// waitForFunctionUpdated waits for the last code or configuration update of
+// the function to finish.
+func waitForFunctionUpdated(ctx context.Context, functionName string) error {
+	waiter := lambda.NewFunctionUpdatedV2Waiter(api.lambda)
+	if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)}, functionWaitTimeout); err != nil {
+		return fmt.Errorf("failed waiting for %s: %v", functionName, err)
+	}
+	return nil
+}
+
+// waitForFunctionActive waits for a new function to leave the Pending state.
+func waitForFunctionActive(ctx context.Context, functionName string) error {
+	waiter := lambda.NewFunctionActiveV2Waiter(api.lambda)
+	if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)}, functionWaitTimeout); err != nil {
+		return fmt.Errorf("failed waiting for %s: %v", functionName, err)
+	}
+	return nil
+}
+
+// verifyFunction invokes the function with deploy.verify_payload and fails on
+// any function error. With deploy.verify_path set it sends an HTTP API event
+// for that path (typically /readyz) and also requires a 200 response.
+func verifyFunction(functionName string) error {
+	payload := config.Deploy.VerifyPayload
+	if config.Deploy.VerifyPath != "" {
+		payload = fmt.Sprintf(`{"version":"2.0","rawPath":%q,"requestContext":{"http":{"method":"GET","path":%q}}}`,
+			config.Deploy.VerifyPath, config.Deploy.VerifyPath)
+	}
+	if payload == "" {
+		payload = "{}"
+	}
+
+	responseFile, err := os.CreateTemp("", "verify-*.json")
+	if err != nil {
+		return err
+	}
+	responseFile.Close()
+	defer os.Remove(responseFile.Name())
+
+	cmd := exec.Command("aws", "lambda", "invoke",
+		"--function-name", functionName,
+		"--payload", payload,
+		"--cli-binary-format", "raw-in-base64-out",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region,
+		responseFile.Name())
+	output, err := hostexec.CombinedOutput(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to invoke %s: %v\nOutput: %s", functionName, err, output)
+	}
+
+	var result struct {
+		FunctionError string `json:"FunctionError"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("failed to parse invoke result: %v", err)
+	}
+	response, _ := os.ReadFile(responseFile.Name())
+	if result.FunctionError != "" {
+		return fmt.Errorf("%s: %s", result.FunctionError, response)
+	}
+	if config.Deploy.VerifyPath != "" {
+		var httpResponse struct {
+			StatusCode int `json:"statusCode"`
+		}
+		if err := json.Unmarshal(response, &httpResponse); err != nil || httpResponse.StatusCode != 200 {
+			return fmt.Errorf("%s returned %s", config.Deploy.VerifyPath, response)
+		}
+	}
+
+	fmt.Printf("Verification invoke of %s succeeded: %s\n", functionName, response)
+	return nil
+}
+
+// moveTriggers repoints the event source mappings, EventBridge rule targets
+// and HTTP API integrations of one function to the other and records the new
+// live function.
+// Each mapping and rule is switched in a single API call, so no event is
+// delivered to both functions, but different triggers switch a few hundred
+// milliseconds apart.
+func moveTriggers(from, to, awsAccountID string) error {
+	listMappingsCmd := exec.Command("aws", "lambda", "list-event-source-mappings",
+		"--function-name", from,
+		"--query", "EventSourceMappings[].UUID",
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(listMappingsCmd)
+	if err != nil {
+		return fmt.Errorf("failed to list event source mappings of %s: %v", from, err)
+	}
+	var mappings []string
+	if err := json.Unmarshal(output, &mappings); err != nil {
+		return fmt.Errorf("failed to parse event source mappings: %v", err)
+	}
+
+	for _, uuid := range mappings {
+		updateCmd := exec.Command("aws", "lambda", "update-event-source-mapping",
+			"--uuid", uuid,
+			"--function-name", to,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err := hostexec.CombinedOutput(updateCmd)
+		if err != nil {
+			return fmt.Errorf("failed to move event source mapping %s: %v\nOutput: %s", uuid, err, output)
+		}
+		fmt.Printf("Event source mapping %s now targets %s\n", uuid, to)
+	}
+
+	if err := moveRuleTargets(from, to, awsAccountID); err != nil {
+		return err
+	}
+	if err := moveAPIIntegrations(from, to, awsAccountID); err != nil {
+		return err
+	}
+
+	color := "blue"
+	if to == greenFunctionName() {
+		color = "green"
+	}
+	tagCmd := exec.Command("aws", "lambda", "tag-resource",
+		"--resource", functionARN(config.Lambda.FunctionName, awsAccountID),
+		"--tags", fmt.Sprintf("%s=%s", liveTag, color),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err = hostexec.CombinedOutput(tagCmd)
+	if err != nil {
+		return fmt.Errorf("failed to record live function: %v\nOutput: %s", err, output)
+	}
+
+	fmt.Printf("%s is now live; %s is kept for rollback with -swap\n", to, from)
+	return nil
+}
+
+func moveRuleTargets(from, to, awsAccountID string) error {
+	fromARN, toARN := functionARN(from, awsAccountID), functionARN(to, awsAccountID)
+
+	listRulesCmd := exec.Command("aws", "events", "list-rule-names-by-target",
+		"--target-arn", fromARN,
+		"--query", "RuleNames",
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(listRulesCmd)
+	if err != nil {
+		return fmt.Errorf("failed to list rules targeting %s: %v", from, err)
+	}
+	var rules []string
+	if err := json.Unmarshal(output, &rules); err != nil {
+		return fmt.Errorf("failed to parse rule names: %v", err)
+	}
+
+	for _, rule := range rules {
+		listTargetsCmd := exec.Command("aws", "events", "list-targets-by-rule",
+			"--rule", rule,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err := hostexec.Output(listTargetsCmd)
+		if err != nil {
+			return fmt.Errorf("failed to list targets of rule %s: %v", rule, err)
+		}
+		var response struct {
+			Targets []map[string]interface{} `json:"Targets"`
+		}
+		if err := json.Unmarshal(output, &response); err != nil {
+			return fmt.Errorf("failed to parse targets of rule %s: %v", rule, err)
+		}
+
+		// Keep input transformers and retry policies by rewriting only the ARN
+		var targets []map[string]interface{}
+		for _, target := range response.Targets {
+			if target["Arn"] == fromARN {
+				target["Arn"] = toARN
+				targets = append(targets, target)
+			}
+		}
+
+		ruleARN := config.Partition().ARN("events", config.AWS.Region, awsAccountID, "rule/"+rule)
+		permissionCmd := exec.Command("aws", "lambda", "add-permission",
+			"--function-name", to,
+			"--statement-id", rule,
+			"--action", "lambda:InvokeFunction",
+			"--principal", "events.amazonaws.com",
+			"--source-arn", ruleARN,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err = hostexec.CombinedOutput(permissionCmd)
+		if err != nil && !strings.Contains(string(output), "ResourceConflictException") {
+			return fmt.Errorf("failed to allow rule %s to invoke %s: %v\nOutput: %s", rule, to, err, output)
+		}
+
+		targetsJSON, err := json.Marshal(targets)
+		if err != nil {
+			return err
+		}
+		putTargetsCmd := exec.Command("aws", "events", "put-targets",
+			"--rule", rule,
+			"--targets", string(targetsJSON),
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err = hostexec.CombinedOutput(putTargetsCmd)
+		if err != nil {
+			return fmt.Errorf("failed to move rule %s: %v\nOutput: %s", rule, err, output)
+		}
+		fmt.Printf("Rule %s now targets %s\n", rule, to)
+	}
+	return nil
+}
+
+// moveAPIIntegrations repoints the integrations of the api.enabled HTTP API
+// with one function to the other, once API Gateway may invoke it.
+func moveAPIIntegrations(from, to, awsAccountID string) error {
+	if !config.API.Enabled {
+		return nil
+	}
+	fromARN, toARN := functionARN(from, awsAccountID), functionARN(to, awsAccountID)
+
+	getAPIsCmd := exec.Command("aws", "apigatewayv2", "get-apis",
+		"--query", fmt.Sprintf("Items[?Name=='%s'].ApiId", config.HTTPAPIName()),
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(getAPIsCmd)
+	if err != nil {
+		return fmt.Errorf("failed to look up HTTP API %s: %v", config.HTTPAPIName(), err)
+	}
+	var apiIDs []string
+	if err := json.Unmarshal(output, &apiIDs); err != nil {
+		return fmt.Errorf("failed to parse HTTP APIs: %v", err)
+	}
+	if len(apiIDs) == 0 {
+		return fmt.Errorf("HTTP API %s not found; run setup first", config.HTTPAPIName())
+	}
+	apiID := apiIDs[0]
+
+	getIntegrationsCmd := exec.Command("aws", "apigatewayv2", "get-integrations",
+		"--api-id", apiID,
+		"--query", fmt.Sprintf("Items[?IntegrationUri=='%s'].IntegrationId", fromARN),
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err = hostexec.Output(getIntegrationsCmd)
+	if err != nil {
+		return fmt.Errorf("failed to list integrations of HTTP API %s: %v", apiID, err)
+	}
+	var integrations []string
+	if err := json.Unmarshal(output, &integrations); err != nil {
+		return fmt.Errorf("failed to parse integrations: %v", err)
+	}
+	if len(integrations) == 0 {
+		return nil
+	}
+
+	permissionCmd := exec.Command("aws", "lambda", "add-permission",
+		"--function-name", to,
+		"--statement-id", "apigateway-"+apiID,
+		"--action", "lambda:InvokeFunction",
+		"--principal", "apigateway.amazonaws.com",
+		"--source-arn", config.Partition().ARN("execute-api", config.AWS.Region, awsAccountID, apiID+"/*"),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err = hostexec.CombinedOutput(permissionCmd)
+	if err != nil && !strings.Contains(string(output), "ResourceConflictException") {
+		return fmt.Errorf("failed to allow HTTP API %s to invoke %s: %v\nOutput: %s", apiID, to, err, output)
+	}
+
+	for _, integration := range integrations {
+		updateCmd := exec.Command("aws", "apigatewayv2", "update-integration",
+			"--api-id", apiID,
+			"--integration-id", integration,
+			"--integration-uri", toARN,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err := hostexec.CombinedOutput(updateCmd)
+		if err != nil {
+			return fmt.Errorf("failed to move integration %s of HTTP API %s: %v\nOutput: %s", integration, apiID, err, output)
+		}
+		fmt.Printf("HTTP API %s integration %s now targets %s\n", apiID, integration, to)
+	}
+	return nil
+}
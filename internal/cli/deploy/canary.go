@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/audit"
+)
+
+// canaryWeight is the share of deploy.alias's traffic that -canary sends to
+// the new version; 0 moves the alias outright.
+var canaryWeight float64
+
+// parseCanaryWeight reads a -canary value such as 10% or 2.5.
+func parseCanaryWeight(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent <= 0 || percent >= 100 {
+		return 0, fmt.Errorf("-canary %q: want a percentage above 0 and below 100, e.g. 10%%", value)
+	}
+	return percent / 100, nil
+}
+
+// startCanary sends canaryWeight of the alias's traffic to version and leaves
+// the rest on the version the alias points to. An alias that does not exist
+// yet has nothing to shift from, so it is pointed at version outright.
+func startCanary(ctx context.Context, functionName, alias, version string) error {
+	output, err := api.lambda.GetAlias(ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(functionName),
+		Name:         aws.String(alias),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		fmt.Printf("Alias %s does not exist yet, so it gets all traffic on version %s\n", alias, version)
+		return pointAlias(ctx, functionName, alias, version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get alias: %v", err)
+	}
+	stable := aws.ToString(output.FunctionVersion)
+	if stable == version {
+		// Lambda publishes no new version when nothing changed
+		fmt.Printf("Alias %s already points to version %s; nothing to shift\n", alias, version)
+		return nil
+	}
+
+	_, err = api.lambda.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: aws.String(stable),
+		RoutingConfig: &lambdatypes.AliasRoutingConfiguration{
+			AdditionalVersionWeights: map[string]float64{version: canaryWeight},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to shift traffic: %v", err)
+	}
+	fmt.Printf("Alias %s sends %s of traffic to version %s and the rest to version %s; "+
+		"finish with `lambda-template deploy -promote` or undo with `deploy -abort`\n",
+		alias, strconv.FormatFloat(canaryWeight*100, 'f', -1, 64)+"%", version, stable)
+	return nil
+}
+
+// finishCanary ends the traffic shift on deploy.alias: promote moves all
+// traffic to the canary version, otherwise it all goes back to the stable
+// one. It returns what changed, for the audit log.
+func finishCanary(ctx context.Context, functionName, alias string, promote bool) (string, error) {
+	output, err := api.lambda.GetAlias(ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(functionName),
+		Name:         aws.String(alias),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get alias %s: %v", alias, err)
+	}
+	var canary string
+	if output.RoutingConfig != nil {
+		for version := range output.RoutingConfig.AdditionalVersionWeights {
+			canary = version
+		}
+	}
+	if canary == "" {
+		return "", fmt.Errorf("alias %s has no traffic shift in progress", alias)
+	}
+
+	stable := aws.ToString(output.FunctionVersion)
+	target := stable
+	if promote {
+		target = canary
+	}
+	if err := pointAlias(ctx, functionName, alias, target); err != nil {
+		return "", err
+	}
+	if promote {
+		fmt.Printf("Alias %s promoted: all traffic goes to version %s\n", alias, target)
+		return fmt.Sprintf("%s: version %s -> %s", alias, stable, canary), nil
+	}
+	fmt.Printf("Canary aborted: alias %s sends all traffic to version %s again\n", alias, target)
+	return fmt.Sprintf("%s: canary version %s dropped, %s kept", alias, canary, stable), nil
+}
+
+// recordCanary logs a -promote or -abort in the audit log. The alias has
+// moved by now, so a failure to log it is only a warning.
+func recordCanary(ctx context.Context, action, detail string) {
+	actor := "unknown"
+	if identity, err := api.sts.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
+		actor = aws.ToString(identity.Arn)
+	}
+	err := audit.Record(config.Audit.File, audit.Entry{
+		Actor:    actor,
+		Action:   action,
+		Function: config.Lambda.FunctionName,
+		Detail:   detail,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
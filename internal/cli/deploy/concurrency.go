@@ -0,0 +1,14 @@
+package deploy
+
+import (
+	"context"
+	"os"
+
+	"example-lambda-go/internal/concurrency"
+)
+
+// applyReservedConcurrency sets the function's reserved concurrency to
+// lambda.reserved_concurrency, leaving a throttled function paused.
+func applyReservedConcurrency(ctx context.Context, functionName string) error {
+	return concurrency.Apply(ctx, api.lambda, &config, functionName, os.Stdout)
+}
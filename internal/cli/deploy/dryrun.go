@@ -0,0 +1,80 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/explain"
+	"example-lambda-go/internal/registryauth"
+)
+
+// dryRun prints what deploy would do at deployTime without building or
+// changing anything: a refusal by the windows or the rules, or the changes.
+func dryRun(ctx context.Context, swap bool, deployTime time.Time, checkWindows bool) error {
+	if checkWindows && !swap {
+		if err := checkDeployWindows(deployTime); err != nil {
+			return fmt.Errorf("deploy would be refused: %v", err)
+		}
+	}
+	if err := config.CheckRules(ctx, os.Stdout); err != nil {
+		return fmt.Errorf("deploy would be refused: %v", err)
+	}
+	plan, err := dryRunPlan(ctx, swap)
+	if err != nil {
+		return fmt.Errorf("dry run failed: %v", err)
+	}
+	plan.PrintChanges(os.Stdout)
+	return nil
+}
+
+// dryRunPlan narrows the explanation to what deploy would change in the
+// account as it is now, using only read-only lookups.
+func dryRunPlan(ctx context.Context, swap bool) (*explain.Plan, error) {
+	awsAccountID, err := registryauth.AccountID(ctx, api.sts)
+	if err != nil {
+		return nil, err
+	}
+	if swap {
+		return explainPlan(awsAccountID, swap), nil
+	}
+
+	if repositoryURI, err = registryauth.RepositoryURI(ctx, api.ecr, config.ECR.RepositoryName); err != nil {
+		return nil, err
+	}
+	_, err = api.lambda.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, fmt.Errorf("%s does not exist; run setup first", config.Lambda.FunctionName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting Lambda function: %v", err)
+	}
+
+	p := explainPlan(awsAccountID, swap)
+	if config.Deploy.Strategy == "bluegreen" {
+		_, idle, err := blueGreenFunctions(awsAccountID)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := functionExists(idle)
+		if err != nil {
+			return nil, err
+		}
+		p.Resolve("lambda:CreateFunction", "if the idle function does not exist yet", !exists)
+		for _, step := range p.Steps {
+			if step.When == "on whichever of the pair is idle" {
+				step.When = "on " + idle
+			}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/secretenv"
+)
+
+// printEnvironmentChanges prints the variables config.yaml adds to the
+// function's current environment or changes in it. Variables it does not set
+// are kept, so nothing is removed.
+func printEnvironmentChanges(w io.Writer, functionName string, current, env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		if value, ok := current[key]; !ok || value != env[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "Environment changes to %s:\n", functionName)
+	for _, key := range keys {
+		if value, ok := current[key]; ok {
+			fmt.Fprintf(w, "  ~ %s: %s -> %s\n", key, displayValue(key, value), displayValue(key, env[key]))
+		} else {
+			fmt.Fprintf(w, "  + %s=%s\n", key, displayValue(key, env[key]))
+		}
+	}
+}
+
+// displayValue quotes the value, or hides it when the name suggests a secret
+// and the value is not a reference to one.
+func displayValue(key, value string) string {
+	if _, ok, _ := secretenv.Parse(value); ok {
+		return fmt.Sprintf("%q", value)
+	}
+	if secretenv.LooksSecret(key) && value != "" {
+		return "(hidden)"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// secretARNs caches the ARN of each secret referenced by name, as aliases
+// may reference the same ones.
+var secretARNs = map[string]string{}
+
+// pinSecrets rewrites the references in env to secrets by name to reference
+// their ARNs, so a missing secret fails the deploy rather than the cold start
+// and the function keeps reading the secret that was checked.
+func pinSecrets(env map[string]string) error {
+	for name, value := range env {
+		ref, ok, err := secretenv.Parse(value)
+		if !ok || ref.IsARN() {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		arn, found := secretARNs[ref.Secret]
+		if !found {
+			describeSecretCmd := exec.Command("aws", "secretsmanager", "describe-secret",
+				"--secret-id", ref.Secret,
+				"--query", "ARN",
+				"--output", "text",
+				"--profile", config.AWS.Profile,
+				"--region", config.AWS.Region)
+			output, err := hostexec.CombinedOutput(describeSecretCmd)
+			if err != nil {
+				return fmt.Errorf("%s: failed to look up secret %s: %v\nOutput: %s", name, ref.Secret, err, output)
+			}
+			arn = strings.TrimSpace(string(output))
+			secretARNs[ref.Secret] = arn
+		}
+		env[name] = secretenv.Ref{Secret: arn, Key: ref.Key}.String()
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/explain"
+	"example-lambda-go/internal/lambdaenv"
+)
+
+// Plan is the -explain plan of deploy with cfg, and of deploy -swap under
+// blue/green, which the deployer policy is generated from.
+func Plan(cfg *appconfig.Config, awsAccountID string) []*explain.Plan {
+	config = *cfg
+	plans := []*explain.Plan{explainPlan(awsAccountID, false)}
+	if config.Deploy.Strategy == "bluegreen" {
+		plans = append(plans, explainPlan(awsAccountID, true))
+	}
+	return plans
+}
+
+// explainPlan lists the calls main makes with the current config, in the
+// same order. Keep it in step with main when adding a deploy step.
+func explainPlan(awsAccountID string, swap bool) *explain.Plan {
+	region := config.AWS.Region
+	repositoryARN := config.Partition().ARN("ecr", region, awsAccountID, "repository/"+config.ECR.RepositoryName)
+	blue := functionARN(config.Lambda.FunctionName, awsAccountID)
+	green := functionARN(greenFunctionName(), awsAccountID)
+
+	p := explain.New("deploy")
+	if swap {
+		p.Call("sts:GetCallerIdentity", "look up the account ID for resource ARNs").NoPermission()
+		explainBlueGreenLookup(p, blue)
+		explainMoveTriggers(p, blue, green, awsAccountID)
+		return p
+	}
+
+	if objects := config.Rules.Bundle.ObjectARNs(config.Partition()); len(objects) > 0 {
+		p.Call("s3:GetObject", "fetch the organization rules bundle and its signature").
+			On(objects...).From("rules.bundle.url", config.Rules.Bundle.URL)
+	}
+	p.Call("iam:GetUser", "check that the credentials work").
+		On(config.Partition().ARN("iam", "", awsAccountID, "user/${aws:username}")).
+		From("aws.profile", config.AWS.Profile)
+	if config.SLO.Enabled() {
+		p.Call("cloudwatch:GetMetricData", "check the error budget (skipped with -ignore-slo)").
+			From("slo.availability", config.SLO.Availability).From("slo.latency.target", config.SLO.Latency.Target)
+	}
+	p.Call("sts:GetCallerIdentity", "look up the account ID for resource ARNs").NoPermission()
+	if config.Database.ProxyName != "" {
+		p.Call("rds:DescribeDBProxies", "look up the RDS Proxy endpoint and resource ID").
+			On(config.Partition().ARN("rds", region, awsAccountID, "db-proxy:*")).
+			From("database.proxy_name", config.Database.ProxyName)
+	}
+	p.Call("ecr:DescribeRepositories", "look up the repository URI to push to").
+		On(repositoryARN).From("ecr.repository_name", config.ECR.RepositoryName)
+	p.Call("ecr:GetAuthorizationToken", "log Docker in to the registry and authenticate registry API calls")
+	p.Call("ecr:PutImage", "push the image (docker push)").
+		On(repositoryARN).
+		With("image", imageURI(awsAccountID)).
+		Needs("ecr:BatchCheckLayerAvailability").
+		Needs("ecr:InitiateLayerUpload").
+		Needs("ecr:UploadLayerPart").
+		Needs("ecr:CompleteLayerUpload")
+	p.Call("ecr:DescribeImages", "record the digest of the pushed image").On(repositoryARN)
+	p.Call("ecr:BatchGetImage", "check with the registry API that latest is the pushed image").On(repositoryARN)
+
+	if config.CDN.Assets.Bucket != "" {
+		bucketARN := config.Partition().ARN("s3", "", "", config.CDN.Assets.Bucket)
+		p.Call("s3:ListBucket", "compare -assets with the bucket (aws s3 sync)").
+			On(bucketARN).From("cdn.assets.bucket", config.CDN.Assets.Bucket).If("with -assets")
+		p.Call("s3:PutObject", "upload new and changed assets").
+			On(bucketARN + "/" + config.CDN.AssetsPrefix() + "*").If("with -assets")
+		p.Call("cloudfront:ListDistributions", "look up the function's distribution").If("with -assets")
+		p.Call("cloudfront:CreateInvalidation", "drop cached copies of the old assets").
+			On(config.Partition().ARN("cloudfront", "", awsAccountID, "distribution/*")).
+			From("cdn.assets.path", config.CDN.AssetsPath()).If("with -assets")
+	}
+
+	p.Call("lambda:GetFunction", "read the live function's commit tag to start the changelog from").
+		On(blue).If("if the deploy history has no previous deploy")
+
+	targets := []string{blue}
+	if config.Deploy.Strategy == "bluegreen" {
+		targets = []string{blue, green}
+		explainBlueGreenLookup(p, blue)
+		p.Call("lambda:GetFunctionConfiguration", "check whether the idle function exists").On(green)
+		p.Call("lambda:CreateFunction", "create the idle function with the live function's role").
+			On(targets...).
+			Needs("iam:PassRole", config.Partition().ARN("iam", "", awsAccountID, "role/*")).
+			Needs("ecr:BatchGetImage", repositoryARN).
+			Needs("ecr:GetDownloadUrlForLayer", repositoryARN).
+			If("if the idle function does not exist yet")
+	}
+
+	updateCode := p.Call("lambda:UpdateFunctionCode", "point the function at the new image").
+		On(targets...).
+		Needs("ecr:BatchGetImage", repositoryARN).
+		Needs("ecr:GetDownloadUrlForLayer", repositoryARN).
+		With("image", imageURI(awsAccountID)).
+		From("lambda.function_name", config.Lambda.FunctionName)
+	if config.Deploy.Strategy == "bluegreen" {
+		updateCode.If("on whichever of the pair is idle")
+	}
+	p.Call("lambda:GetFunctionConfiguration", "read the current environment to merge config.yaml into").On(targets...)
+	if ids := config.SecretIDs(); len(ids) > 0 {
+		p.Call("secretsmanager:DescribeSecret", "look up the ARNs of the secrets the environment references by name").
+			On(config.SecretARNs(awsAccountID)...).
+			From("lambda.environment", strings.Join(ids, ", "))
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		p.Call("iam:AttachRolePolicy", "attach AWSLambdaVPCAccessExecutionRole so the function can join the VPC").
+			On(config.Partition().ARN("iam", "", awsAccountID, "role/"+config.Lambda.RoleName)).
+			From("vpc.subnet_ids", strings.Join(config.VPC.SubnetIDs, ","))
+	}
+	if config.Lambda.Tracing == "Active" {
+		p.Call("iam:AttachRolePolicy", "attach AWSXRayDaemonWriteAccess so the function can send traces").
+			On(config.Partition().ARN("iam", "", awsAccountID, "role/"+config.Lambda.RoleName)).
+			From("lambda.tracing", config.Lambda.Tracing)
+	}
+	updateConfig := p.Call("lambda:UpdateFunctionConfiguration", "apply timeout, memory, storage, environment and VPC settings").
+		On(targets...).
+		From("lambda.timeout", config.Lambda.Timeout).
+		From("lambda.memory_size", config.Lambda.MemorySize).
+		From("lambda.ephemeral_storage", config.Lambda.EphemeralStorage)
+	if names := environmentNames(); names != "" {
+		updateConfig.With("environment", names)
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		updateConfig.
+			From("vpc.subnet_ids", strings.Join(config.VPC.SubnetIDs, ",")).
+			Needs("ec2:DescribeSecurityGroups", "*").
+			Needs("ec2:DescribeSubnets", "*").
+			Needs("ec2:DescribeVpcs", "*")
+	}
+	p.Call("lambda:TagResource", "add lambda.tags and the deployed commit and changelog to the function").On(targets...)
+	p.Call("lambda:GetFunction", "wait for the update to finish and check it runs the pushed image").On(targets...)
+	if config.Lambda.ReservedConcurrency != nil {
+		p.Call("lambda:GetAccountSettings", "check the account's unreserved concurrency").
+			From("deploy.min_unreserved_concurrency", config.MinUnreservedConcurrency()).
+			If("unless the reservation is unchanged")
+		p.Call("lambda:PutFunctionConcurrency", "reserve concurrency for the function, or pause it with 0").
+			On(targets...).
+			From("lambda.reserved_concurrency", *config.Lambda.ReservedConcurrency).
+			If("unless it matches or the function is throttled")
+		p.Call("lambda:TagResource", "record the reservation for `throttle off`").
+			On(targets...).If("if the function is throttled")
+	}
+
+	if len(config.Aliases) > 0 {
+		aliases := config.AliasNames()
+		qualified := make([]string, len(aliases))
+		for i, alias := range aliases {
+			qualified[i] = blue + ":" + alias
+		}
+		p.Call("lambda:UpdateFunctionConfiguration", "apply the alias's environment overrides before publishing").
+			On(blue).From("aliases", strings.Join(aliases, ",")).If("once per alias, then again to restore the shared environment")
+		p.Call("lambda:PublishVersion", "publish a version with the alias's configuration").On(blue)
+		if config.Deploy.Alias != "" {
+			p.Call("lambda:GetAlias", "find the version deploy.alias shifts traffic from").
+				On(blue + ":" + config.Deploy.Alias).If("with -canary, -promote or -abort")
+		}
+		p.Call("lambda:UpdateAlias", "point the alias at the new version").On(qualified...)
+		p.Call("lambda:CreateAlias", "create the alias").On(qualified...).If("if the alias does not exist yet")
+		p.Call("lambda:PutProvisionedConcurrencyConfig", "set the alias's provisioned concurrency").
+			On(qualified...).If("for aliases with provisioned_concurrency")
+		p.Call("lambda:DeleteProvisionedConcurrencyConfig", "remove provisioned concurrency").
+			On(qualified...).If("for aliases without provisioned_concurrency")
+	}
+
+	if config.Deploy.Strategy == "bluegreen" {
+		p.Call("lambda:InvokeFunction", "verify the idle function before moving traffic").
+			On(targets...).From("deploy.verify_path", config.Deploy.VerifyPath)
+		explainMoveTriggers(p, blue, green, awsAccountID)
+	}
+
+	scheduleARN := config.Partition().ARN("events", region, awsAccountID, "rule/"+config.ScheduleRuleName())
+	p.Call("events:DescribeRule", "compare the schedule rule with lambda.schedule").
+		On(scheduleARN).From("lambda.schedule", config.Lambda.Schedule)
+	if config.Lambda.Schedule != "" {
+		// A new rule invokes deploy.alias when it is set
+		ruleTargets := targets
+		if config.Deploy.Alias != "" {
+			ruleTargets = []string{config.TriggerTargetARN(awsAccountID)}
+		}
+		p.Call("events:PutRule", "create the schedule rule, or change its schedule").
+			On(scheduleARN).Needs("events:TagResource", scheduleARN).If("unless the rule has lambda.schedule")
+		p.Call("lambda:AddPermission", "allow the new rule to invoke the live function").
+			On(ruleTargets...).If("if the rule was just created")
+		p.Call("events:PutTargets", "point the new rule at the live function").
+			On(scheduleARN).If("if the rule was just created")
+	} else {
+		p.Call("events:ListTargetsByRule", "list the targets of a rule lambda.schedule no longer asks for").
+			On(scheduleARN).If("if the rule exists")
+		p.Call("events:RemoveTargets", "detach the rule's targets").On(scheduleARN).If("if the rule exists")
+		p.Call("events:DeleteRule", "delete the rule").On(scheduleARN).If("if the rule exists")
+	}
+	if config.Triggers.SQS.Enabled() {
+		p.Call("lambda:ListEventSourceMappings", "find the triggers.sqs mapping").
+			From("triggers.sqs.queue", config.Triggers.SQS.Queue)
+		p.Call("lambda:UpdateEventSourceMapping", "change the mapping's batch settings").
+			On(config.Partition().ARN("lambda", region, awsAccountID, "event-source-mapping:*")).
+			From("triggers.sqs.batch_size", config.Triggers.SQS.MessagesPerBatch()).
+			From("triggers.sqs.batch_window", config.Triggers.SQS.BatchWindow).
+			If("unless they match")
+	}
+	if config.Triggers.DynamoDB.Enabled() {
+		p.Call("lambda:ListEventSourceMappings", "find the triggers.dynamodb mapping").
+			From("triggers.dynamodb.stream", config.Triggers.DynamoDB.Stream)
+		p.Call("lambda:UpdateEventSourceMapping", "change the mapping's batch settings").
+			On(config.Partition().ARN("lambda", region, awsAccountID, "event-source-mapping:*")).
+			From("triggers.dynamodb.batch_size", config.Triggers.DynamoDB.RecordsPerBatch()).
+			From("triggers.dynamodb.parallelization_factor", config.Triggers.DynamoDB.BatchesPerShard()).
+			If("unless they match")
+	}
+	if config.Triggers.Kinesis.Enabled() {
+		p.Call("lambda:ListEventSourceMappings", "find the triggers.kinesis mapping").
+			From("triggers.kinesis.stream", config.Triggers.Kinesis.Stream)
+		p.Call("iam:PutRolePolicy", "allow the function to send to a new on-failure destination (kinesis-trigger)").
+			On(config.Partition().ARN("iam", "", awsAccountID, "role/"+config.Lambda.RoleName)).
+			From("triggers.kinesis.on_failure", config.Triggers.Kinesis.OnFailure).
+			If("if the destination changed")
+		p.Call("lambda:UpdateEventSourceMapping", "change the mapping's tumbling window and on-failure destination").
+			On(config.Partition().ARN("lambda", region, awsAccountID, "event-source-mapping:*")).
+			From("triggers.kinesis.tumbling_window", config.Triggers.Kinesis.TumblingWindow).
+			From("triggers.kinesis.on_failure", config.Triggers.Kinesis.OnFailure).
+			If("unless they match")
+	}
+
+	if config.Events.SchemaRegistry != "" {
+		registryARN := config.Partition().ARN("schemas", region, awsAccountID, "registry/"+config.Events.SchemaRegistry)
+		schemaARN := config.Partition().ARN("schemas", region, awsAccountID, fmt.Sprintf("schema/%s/*", config.Events.SchemaRegistry))
+		p.Call("schemas:CreateRegistry", "create the schema registry").
+			On(registryARN).From("events.schema_registry", config.Events.SchemaRegistry)
+		p.Call("schemas:CreateSchema", "publish each event schema").
+			On(schemaARN).From("events.source", config.Events.Source)
+		p.Call("schemas:UpdateSchema", "publish a new version of a changed schema").
+			On(schemaARN).If("if the schema already exists")
+	}
+	if config.FunctionURL.Enabled {
+		p.Call("lambda:GetFunctionUrlConfig", "print the function URL").
+			On(config.TriggerTargetARN(awsAccountID)).From("function_url.enabled", true)
+	}
+	return p
+}
+
+func explainBlueGreenLookup(p *explain.Plan, blue string) {
+	p.Call("lambda:ListTags", "find which of the pair is live").
+		On(blue).From("deploy.strategy", config.Deploy.Strategy)
+}
+
+func explainMoveTriggers(p *explain.Plan, blue, green, awsAccountID string) {
+	ruleARN := config.Partition().ARN("events", config.AWS.Region, awsAccountID, "rule/*")
+	p.Call("lambda:ListEventSourceMappings", "find the live function's event source mappings")
+	p.Call("lambda:UpdateEventSourceMapping", "repoint each mapping at the idle function").
+		Needs("lambda:InvokeFunction", blue, green)
+	p.Call("events:ListRuleNamesByTarget", "find the rules targeting the live function").On(ruleARN)
+	p.Call("events:ListTargetsByRule", "read each rule's targets").On(ruleARN)
+	p.Call("lambda:AddPermission", "allow each rule to invoke the idle function").On(blue, green)
+	p.Call("events:PutTargets", "repoint each rule at the idle function").On(ruleARN)
+	if config.API.Enabled {
+		apis := config.Partition().ARN("apigateway", config.AWS.Region, "", "/apis")
+		p.Call("apigateway:GET", "find the HTTP API and its integrations with the live function").
+			On(apis, apis+"/*/integrations").From("api.name", config.HTTPAPIName())
+		p.Call("lambda:AddPermission", "allow the HTTP API to invoke the idle function").On(green, blue)
+		p.Call("apigateway:PATCH", "repoint each integration at the idle function").
+			On(apis + "/*/integrations/*")
+	}
+	p.Call("lambda:TagResource", "record the new live function").On(blue)
+}
+
+// environmentNames lists the variables deploy sets from config.yaml; their
+// values may be secret, so the plan leaves them out.
+func environmentNames() string {
+	var names []string
+	for name := range lambdaenv.Function(&config, lambdaenv.Resources{DatabaseHost: databaseProxy.Endpoint, WorkerQueueURL: workerQueueURL}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// imageURI is the image deploy pushes. Until the repository has been looked
+// up, it is built from the account and region.
+func imageURI(awsAccountID string) string {
+	if repositoryURI != "" {
+		return repositoryURI + ":latest"
+	}
+	return fmt.Sprintf("%s/%s:latest", config.Partition().Registry(awsAccountID, config.AWS.Region), config.ECR.RepositoryName)
+}
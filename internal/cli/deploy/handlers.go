@@ -0,0 +1,136 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/emulator"
+	"example-lambda-go/internal/introspect"
+	"example-lambda-go/internal/router"
+)
+
+// checkHandlers runs the new image with the emulator, asks it for its
+// introspection manifest and refuses the deploy when an event source in
+// config.yaml has no route to a registered handler. Routes and handlers that
+// nothing configured sends events to are only warned about, on stdout, as
+// they may be invoked directly.
+func checkHandlers(ctx context.Context, w, warnings io.Writer) error {
+	port, err := emulator.FreePort()
+	if err != nil {
+		return err
+	}
+	// The handler's clients need a region; introspection needs nothing else
+	container, err := emulator.Start(localImage(), port, []string{
+		"AWS_REGION=" + config.AWS.Region,
+		"AWS_LAMBDA_FUNCTION_NAME=" + config.Lambda.FunctionName,
+	}, config.Build.Go.Arch())
+	if err != nil {
+		return err
+	}
+	defer emulator.Stop(container)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	body, status, err := emulator.Invoke(ctx, client, fmt.Sprintf("http://127.0.0.1:%d", port), []byte(introspect.Payload))
+	if err != nil {
+		return err
+	}
+	var manifest introspect.Manifest
+	// Builds from before introspection route the payload like any event
+	if status >= 300 || json.Unmarshal(body, &manifest) != nil || manifest.Handlers == nil {
+		fmt.Fprintln(warnings, "The image does not answer introspection; its handlers were not checked")
+		return nil
+	}
+
+	problems, unused := handlerMismatches(&config, manifest)
+	for _, warning := range unused {
+		fmt.Fprintf(warnings, "Warning: %s\n", warning)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the image has no handler for events config.yaml sends it:\n  %s\nDeploying it would drop them; -skip-handler-check deploys anyway", strings.Join(problems, "\n  "))
+	}
+	fmt.Fprintf(w, "%d routes to %d handlers cover the configured event sources\n", len(manifest.Routes), len(manifest.Handlers))
+	return nil
+}
+
+// handlerMismatches compares the event sources of cfg with the routes and
+// handlers of the manifest. Problems are sources whose events no route takes
+// to a registered handler, and routes to handlers that are not registered;
+// unused are handlers no route reaches, and SQS and HTTP routes that no
+// configured source feeds.
+func handlerMismatches(cfg *appconfig.Config, m introspect.Manifest) (problems, unused []string) {
+	registered := map[string]bool{}
+	for _, handler := range m.Handlers {
+		registered[handler] = true
+	}
+	routed := map[string]bool{}
+	var routes []router.Route
+	for _, route := range m.Routes {
+		routed[route.Handler] = true
+		if !registered[route.Handler] {
+			problems = append(problems, fmt.Sprintf("route %s refers to handler %s, which is not registered", route.Name, route.Handler))
+			continue
+		}
+		routes = append(routes, route)
+	}
+	for _, handler := range m.Handlers {
+		if !routed[handler] {
+			unused = append(unused, fmt.Sprintf("handler %s is registered but no route reaches it", handler))
+		}
+	}
+
+	has := func(matches func(router.Route) bool) bool {
+		for _, route := range routes {
+			if matches(route) {
+				return true
+			}
+		}
+		return false
+	}
+	bySQSAttribute := func(r router.Route) bool { return r.Match.SQSAttribute.Name != "" }
+	byHTTPPath := func(r router.Route) bool { return r.Match.HTTPPath != "" }
+	byDefault := func(r router.Route) bool { return r.Default }
+
+	sqsSource := cfg.Worker.QueueName != "" || cfg.Triggers.SQS.Enabled()
+	httpSource := cfg.API.Enabled || cfg.FunctionURL.Enabled || cfg.CDN.Enabled
+	for _, source := range []struct {
+		configured bool
+		name, want string
+		matches    func(router.Route) bool
+	}{
+		// The router sends SQS records by message attribute alone
+		{cfg.Worker.QueueName != "", "worker.queue_name", "sqs_attribute route", bySQSAttribute},
+		{cfg.Triggers.SQS.Enabled(), "triggers.sqs", "sqs_attribute route", bySQSAttribute},
+		{httpSource, "api, function_url or cdn", "http_path route", byHTTPPath},
+		{cfg.Lambda.Schedule != "", "lambda.schedule", "detail_type: Scheduled Event route or default route", func(r router.Route) bool {
+			return r.Match.DetailType == "Scheduled Event" || r.Default
+		}},
+		// Nothing matches these events but the default route
+		{cfg.Triggers.SNS.Enabled(), "triggers.sns", "default route", byDefault},
+		{cfg.Triggers.S3.Enabled(), "triggers.s3", "default route", byDefault},
+		{cfg.Triggers.DynamoDB.Enabled(), "triggers.dynamodb", "default route", byDefault},
+		{cfg.Triggers.Kinesis.Enabled(), "triggers.kinesis", "default route", byDefault},
+	} {
+		if source.configured && !has(source.matches) {
+			problems = append(problems, fmt.Sprintf("%s is set but the image has no %s", source.name, source.want))
+		}
+	}
+	if cfg.Worker.QueueName != "" && len(m.Jobs) == 0 {
+		problems = append(problems, "worker.queue_name is set but the image registers no job types")
+	}
+
+	for _, route := range routes {
+		switch {
+		case bySQSAttribute(route) && !sqsSource:
+			unused = append(unused, fmt.Sprintf("route %s matches SQS messages but neither worker.queue_name nor triggers.sqs is set", route.Name))
+		case byHTTPPath(route) && !httpSource:
+			unused = append(unused, fmt.Sprintf("route %s matches HTTP requests but none of api, function_url and cdn is enabled", route.Name))
+		}
+	}
+	return problems, unused
+}
@@ -0,0 +1,73 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/imagediff"
+	"example-lambda-go/internal/registryauth"
+)
+
+// diffDeployedImage compares the image functionName runs with the one just
+// built. It returns a nil report when the function does not exist yet.
+func diffDeployedImage(ctx context.Context, w io.Writer, functionName string) (*imagediff.Report, error) {
+	function, err := api.lambda.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the deployed image of %s: %v", functionName, err)
+	}
+	deployed := aws.ToString(function.Code.ResolvedImageUri)
+
+	if err := authenticateDocker(ctx, w); err != nil {
+		return nil, err
+	}
+	pullCmd := registryauth.Command(exec.Command("docker", "pull", deployed), dockerEnv)
+	pullCmd.Stdout = w
+	pullCmd.Stderr = w
+	if err := hostexec.Run(pullCmd); err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %v", deployed, err)
+	}
+
+	dir, err := os.MkdirTemp("", "image-diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var images []*imagediff.Image
+	for _, ref := range []string{deployed, fmt.Sprintf("%s/%s:latest", config.ECR.RepositoryName, config.Lambda.FunctionName)} {
+		archive := filepath.Join(dir, fmt.Sprintf("%d.tar", len(images)))
+		saveCmd := exec.Command("docker", "save", "-o", archive, ref)
+		if output, err := hostexec.CombinedOutput(saveCmd); err != nil {
+			return nil, fmt.Errorf("failed to save %s: %v\n%s", ref, err, output)
+		}
+		f, err := os.Open(archive)
+		if err != nil {
+			return nil, err
+		}
+		img, err := imagediff.Load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", ref, err)
+		}
+		images = append(images, img)
+	}
+
+	fmt.Fprintf(w, "Compared %s with the new build\n", deployed)
+	return imagediff.Diff(images[0], images[1]), nil
+}
@@ -0,0 +1,886 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+	"example-lambda-go/internal/contractcheck"
+	"example-lambda-go/internal/events"
+	"example-lambda-go/internal/explain"
+	"example-lambda-go/internal/filelock"
+	"example-lambda-go/internal/history"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/imagediff"
+	"example-lambda-go/internal/jsonschema"
+	"example-lambda-go/internal/lambdaenv"
+	"example-lambda-go/internal/output"
+	"example-lambda-go/internal/pipeline"
+	"example-lambda-go/internal/registryauth"
+	"example-lambda-go/internal/rolepolicy"
+)
+
+var config appconfig.Config
+
+// api holds the SDK clients, built from config once it is loaded.
+var api clients
+
+// repositoryURI is the ECR repository images are pushed to, as reported by
+// ECR, e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com/hello-world.
+var repositoryURI string
+
+// pushedDigest is the digest ECR recorded for the image this deploy pushed,
+// which the function must resolve :latest to once the update finishes.
+var pushedDigest string
+
+// workerQueueURL is set when worker.queue_name is configured.
+var workerQueueURL string
+
+// databaseProxy is filled from describe-db-proxies when database.proxy_name is set.
+var databaseProxy struct {
+	Endpoint   string
+	ResourceID string
+}
+
+// resetState clears what the deploy of another function left behind, as
+// runPerFunction calls Main once for each entry of functions.
+func resetState() {
+	repositoryURI, pushedDigest, workerQueueURL = "", "", ""
+	databaseProxy.Endpoint, databaseProxy.ResourceID = "", ""
+	publishedVersions = map[string]string{}
+	secretARNs = map[string]string{}
+	release = history.Record{}
+	dockerEnv = nil
+	canaryWeight = 0
+}
+
+// Main builds and pushes the image and updates the function, or with -swap
+// moves the triggers back to the idle blue/green function. -promote and
+// -abort finish a -canary deploy without building. -package only builds,
+// into a file that -from-package deploys without building. -assets uploads
+// static files for the CloudFront distribution along with the image.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template deploy", flag.ExitOnError)
+	swap := flags.Bool("swap", false, "Blue/green only: point triggers back at the idle function without building")
+	at := flags.String("at", "", "Wait until this time (e.g. 2024-07-01T02:00Z) before deploying")
+	ignoreWindows := flags.Bool("ignore-windows", false, "Deploy even outside the configured deploy windows")
+	ignoreSLO := flags.Bool("ignore-slo", false, "Deploy even when the error budget is exhausted")
+	verbose := flags.Bool("verbose", false, "Stream build and push output instead of collapsing successful steps")
+	skipContractCheck := flags.Bool("skip-contract-check", false, "Deploy even if the handler types break recorded consumer contracts")
+	skipHandlerCheck := flags.Bool("skip-handler-check", false, "Deploy even if the new image has no handler for an event source in config.yaml")
+	imageDiff := flags.Bool("image-diff", false, "Before pushing, compare the layers and files of the deployed image with the new build")
+	explainOnly := flags.Bool("explain", false, "List the AWS calls, IAM permissions and config values the deploy would use, then exit")
+	canary := flags.String("canary", "", "Send this share of deploy.alias's traffic (e.g. 10%) to the new version and the rest to the current one")
+	promote := flags.Bool("promote", false, "Send all of deploy.alias's traffic to the -canary version, without building")
+	abort := flags.Bool("abort", false, "Send all of deploy.alias's traffic back to the version before -canary, without building")
+	packagePathFlag := flags.String("package", "", "Build the image and write it with config.yaml to this tar file for -from-package, without deploying")
+	fromPackage := flags.String("from-package", "", "Deploy the image and config.yaml of a -package file instead of building")
+	dryRunOnly := flags.Bool("dry-run", false, "Look up the live state and print the changes the deploy would make, with their names and ARNs, without building or changing anything")
+	assetsDir := flags.String("assets", "", "Upload this directory to cdn.assets.bucket and invalidate cdn.assets.path before updating the function")
+	summaryFlag := flags.String("summary", "", "Write the outcome, image digest, version, step times and console links of each function deployed as JSON to this file; deploy.summary by default")
+	flags.Parse(args)
+	resetState()
+	if *packagePathFlag != "" && *fromPackage != "" {
+		log.Fatal("-package and -from-package are mutually exclusive")
+	}
+
+	// A package brings its own configuration
+	var packageDir, packageCommit string
+	if *fromPackage != "" {
+		var manifest packageManifest
+		var err error
+		if packageDir, manifest, err = openPackage(*fromPackage); err != nil {
+			fatalf("%v", err)
+		}
+		tempDirs = append(tempDirs, packageDir)
+		defer removeTempDirs()
+		appconfig.Path = filepath.Join(packageDir, configFile)
+		if appconfig.Function == "" {
+			appconfig.Function = manifest.Entry
+		}
+		if err := loadConfig(); err != nil {
+			fatalf("Failed to load the package's configuration: %v", err)
+		}
+		if config.Lambda.FunctionName != manifest.Function {
+			fatalf("%s holds %s, not %s", *fromPackage, manifest.Function, config.Lambda.FunctionName)
+		}
+		config.Docker.Dockerfile = filepath.Join(packageDir, dockerfileFile)
+		fmt.Printf("Deploying %s from %s, built %s", manifest.Function, *fromPackage, manifest.Created.Format(time.RFC3339))
+		if manifest.Commit != "" {
+			fmt.Printf(" from commit %s", manifest.Commit)
+		}
+		packageCommit = manifest.Commit
+		fmt.Println()
+	} else if err := loadConfig(); err != nil {
+		fatalf("Failed to load configuration: %v", err)
+	}
+	if (*canary != "" || *promote || *abort) && config.Deploy.Alias == "" {
+		fatalf("-canary, -promote and -abort shift the traffic of deploy.alias, which is not set")
+	}
+	if *assetsDir != "" {
+		if err := checkAssetsDir(*assetsDir); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if *canary != "" {
+		var err error
+		if canaryWeight, err = parseCanaryWeight(*canary); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	// -explain gets by with a placeholder account if the SDK can't be set up
+	ctx := context.Background()
+	awsCfg, err := config.AWSConfig(ctx)
+	if err != nil && !*explainOnly {
+		fatalf("Unable to load SDK config: %v", err)
+	}
+	registryCfg, err := config.RegistryAWSConfig(ctx)
+	if err != nil && !*explainOnly {
+		fatalf("Unable to load SDK config for aws.profiles.registry: %v", err)
+	}
+	api = newClients(awsCfg, registryCfg)
+
+	if *explainOnly {
+		explainPlan(explain.AccountID(ctx, api.sts), *swap).Print(os.Stdout)
+		return
+	}
+
+	if *dryRunOnly {
+		deployTime := time.Now()
+		if *at != "" {
+			if deployTime, err = parseDeployTime(*at); err != nil {
+				fatalf("%v", err)
+			}
+		}
+		if err := dryRun(ctx, *swap, deployTime, !*ignoreWindows); err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+
+	// The OS releases the lock even if the process dies before Unlock
+	lock, err := filelock.TryLock(filepath.Join(".lambda-template", config.Lambda.FunctionName+".lock"))
+	if err != nil {
+		fatalf("Another setup or deploy of %s is running from this checkout: %v", config.Lambda.FunctionName, err)
+	}
+	defer lock.Unlock()
+
+	if *packagePathFlag != "" {
+		if !*skipContractCheck {
+			if err := checkContracts(); err != nil {
+				fatalf("Package refused: %v", err)
+			}
+		}
+		if err := config.CheckRules(ctx, os.Stdout); err != nil {
+			fatalf("Package refused: %v", err)
+		}
+		if err := buildDockerImage(os.Stdout); err != nil {
+			fatalf("Error building Docker image: %v", err)
+		}
+		if err := writePackage(os.Stdout, packagePath(*packagePathFlag)); err != nil {
+			fatalf("Error writing package: %v", err)
+		}
+		return
+	}
+
+	if *swap {
+		if config.Deploy.Strategy != "bluegreen" {
+			fatalf("-swap requires deploy.strategy: bluegreen")
+		}
+		awsAccountID, err := registryauth.AccountID(ctx, api.sts)
+		if err != nil {
+			fatalf("Error getting AWS Account ID: %v", err)
+		}
+		if err := swapBlueGreen(awsAccountID); err != nil {
+			fatalf("Error swapping functions: %v", err)
+		}
+		return
+	}
+
+	if *promote || *abort {
+		if *promote && *abort {
+			fatalf("-promote and -abort are mutually exclusive")
+		}
+		detail, err := finishCanary(ctx, config.Lambda.FunctionName, config.Deploy.Alias, *promote)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		action := "deploy -abort"
+		if *promote {
+			action = "deploy -promote"
+		}
+		recordCanary(ctx, action, detail)
+		return
+	}
+
+	// A deploy refused before it starts writes no summary, so CI must not
+	// find the last deploy's; the functions deployed before this one by
+	// the same run keep their entries
+	summaryPath := config.Deploy.Summary
+	if *summaryFlag != "" {
+		summaryPath = *summaryFlag
+	}
+	if summaryPath != "" && len(summaries) == 0 {
+		if err := os.Remove(summaryPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fatalf("Error removing the previous deploy summary: %v", err)
+		}
+	}
+
+	// Validate the window up front so a scheduled deploy fails now, not at the scheduled time
+	deployTime := time.Now()
+	if *at != "" {
+		var err error
+		if deployTime, err = parseDeployTime(*at); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	if !*ignoreWindows {
+		if err := checkDeployWindows(deployTime); err != nil {
+			fatalf("Deploy refused: %v", err)
+		}
+	}
+	if *at != "" {
+		waitUntil(deployTime)
+	}
+
+	run := pipeline.Start("deploy", config.Telemetry, map[string]string{
+		"faas.name":    config.Lambda.FunctionName,
+		"cloud.region": config.AWS.Region,
+	})
+	run.Output.Verbose = *verbose
+	run.OnEnd = func(err error) {
+		removeTempDirs()
+		if summaryPath != "" {
+			writeSummary(summaryPath, run, err)
+		}
+	}
+
+	// Refuse payload shape changes that would break recorded consumers; a
+	// package was checked when it was built, from sources not at hand here
+	if !*skipContractCheck && *fromPackage == "" {
+		if err := run.Step("contract-check", func(ctx context.Context) error { return checkContracts() }); err != nil {
+			run.Fatalf("Deploy refused: %v", err)
+		}
+	}
+
+	// Hold the configuration to the rules section; warnings print even when
+	// the step succeeds, so they go straight to stdout
+	if err := run.Step("rules-check", func(ctx context.Context) error { return config.CheckRules(ctx, os.Stdout) }); err != nil {
+		run.Fatalf("Deploy refused: %v", err)
+	}
+
+	// Freeze deploys once the error budget is gone, except for the fix
+	if config.SLO.Enabled() && !*ignoreSLO {
+		if err := run.Step("slo-check", checkErrorBudget); err != nil {
+			run.Fatalf("Deploy refused: %v", err)
+		}
+	}
+
+	if err := run.Step("iam-check", checkIAMPermissions); err != nil {
+		run.Fatalf("IAM permission check failed: %v", err)
+	}
+
+	awsAccountID, err := registryauth.AccountID(ctx, api.sts)
+	if err != nil {
+		run.Fatalf("Error getting AWS Account ID: %v", err)
+	}
+
+	if config.Worker.QueueName != "" {
+		queue, _ := config.WorkerQueueName()
+		workerQueueURL = fmt.Sprintf("https://%s/%s/%s", config.Partition().Host("sqs", config.AWS.Region), awsAccountID, queue)
+	}
+
+	if config.Database.ProxyName != "" {
+		if err := describeDatabaseProxy(); err != nil {
+			run.Fatalf("Error looking up RDS Proxy: %v", err)
+		}
+	}
+
+	if repositoryURI, err = registryauth.RepositoryURI(ctx, api.ecr, config.ECR.RepositoryName); err != nil {
+		run.Fatalf("Error looking up ECR repository: %v", err)
+	}
+
+	if *fromPackage != "" {
+		if err := run.Step("load", func(ctx context.Context) error { return loadPackageImage(output.Writer(ctx), packageDir) }); err != nil {
+			run.Fatalf("Error loading the package's image: %v", err)
+		}
+	} else if err := run.Step("build", func(ctx context.Context) error { return buildDockerImage(output.Writer(ctx)) }); err != nil {
+		run.Fatalf("Error building Docker image: %v", err)
+	}
+
+	// Refuse an image that would drop the events of a configured trigger
+	if !*skipHandlerCheck {
+		if err := run.Step("handler-check", func(ctx context.Context) error { return checkHandlers(ctx, output.Writer(ctx), os.Stdout) }); err != nil {
+			run.Fatalf("Deploy refused: %v", err)
+		}
+	}
+
+	if *imageDiff {
+		// Triggers, and so traffic, are on the live one of a blue/green pair
+		functionName := config.Lambda.FunctionName
+		if config.Deploy.Strategy == "bluegreen" {
+			if functionName, _, err = blueGreenFunctions(awsAccountID); err != nil {
+				run.Fatalf("Error finding the live function: %v", err)
+			}
+		}
+		var report *imagediff.Report
+		err := run.Step("image-diff", func(ctx context.Context) error {
+			var err error
+			report, err = diffDeployedImage(ctx, output.Writer(ctx), functionName)
+			return err
+		})
+		if err != nil {
+			run.Fatalf("Error comparing images: %v", err)
+		}
+		if report == nil {
+			fmt.Printf("%s is not deployed yet; nothing to compare the image with\n", functionName)
+		} else {
+			report.Print(os.Stdout, 20)
+		}
+	}
+
+	err = run.Step("push", func(ctx context.Context) error {
+		w := output.Writer(ctx)
+		if err := authenticateDocker(ctx, w); err != nil {
+			return fmt.Errorf("error authenticating Docker: %v", err)
+		}
+		if err := tagDockerImage(w); err != nil {
+			return fmt.Errorf("error tagging Docker image: %v", err)
+		}
+		pushed, err := pushDockerImage(w)
+		if err != nil {
+			return err
+		}
+		digest, err := latestImageDigest(ctx)
+		if err != nil {
+			return err
+		}
+		if err := verifyPush(ctx, w, pushed, digest); err != nil {
+			return err
+		}
+		pushedDigest = digest
+		if pushed != "" {
+			pushedDigest = pushed
+		}
+		return nil
+	})
+	if err != nil {
+		run.Fatalf("Error pushing Docker image: %v", err)
+	}
+
+	// Assets go first so the new version never links to files not uploaded yet
+	if *assetsDir != "" {
+		if err := run.Step("assets", func(ctx context.Context) error { return uploadAssets(output.Writer(ctx), *assetsDir) }); err != nil {
+			run.Fatalf("Error uploading assets: %v", err)
+		}
+	}
+
+	prepareRelease(ctx, packageCommit)
+	if config.Deploy.Strategy == "bluegreen" {
+		if err := run.Step("update", func(ctx context.Context) error { return deployBlueGreen(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error in blue/green deployment: %v", err)
+		}
+	} else {
+		err := run.Step("update", func(ctx context.Context) error {
+			if err := updateLambdaFunction(ctx, config.Lambda.FunctionName); err != nil {
+				return fmt.Errorf("error updating Lambda function: %v", err)
+			}
+			if err := updateLambdaConfiguration(ctx, config.Lambda.FunctionName); err != nil {
+				return fmt.Errorf("error updating Lambda configuration: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			run.Fatalf("%v", err)
+		}
+
+		if err := run.Step("wait", func(ctx context.Context) error {
+			if err := waitForFunctionUpdated(ctx, config.Lambda.FunctionName); err != nil {
+				return err
+			}
+			return verifyDeployedImage(ctx, config.Lambda.FunctionName)
+		}); err != nil {
+			run.Fatalf("Error waiting for Lambda function: %v", err)
+		}
+
+		if len(config.Aliases) > 0 {
+			if err := run.Step("aliases", func(ctx context.Context) error { return publishAliases(ctx, config.Lambda.FunctionName) }); err != nil {
+				run.Fatalf("Error publishing alias versions: %v", err)
+			}
+		}
+	}
+
+	// Once the triggers have moved, so a new rule targets the live function
+	if err := run.Step("schedule", func(ctx context.Context) error { return syncSchedule(awsAccountID) }); err != nil {
+		run.Fatalf("Error updating schedule: %v", err)
+	}
+	if config.Triggers.SQS.Enabled() {
+		if err := run.Step("sqs-trigger", func(ctx context.Context) error { return syncSQSTrigger(awsAccountID) }); err != nil {
+			run.Fatalf("Error updating SQS trigger: %v", err)
+		}
+	}
+	if config.Triggers.DynamoDB.Enabled() {
+		if err := run.Step("dynamodb-trigger", func(ctx context.Context) error { return syncDynamoDBTrigger(awsAccountID) }); err != nil {
+			run.Fatalf("Error updating DynamoDB trigger: %v", err)
+		}
+	}
+	if config.Triggers.Kinesis.Enabled() {
+		if err := run.Step("kinesis-trigger", func(ctx context.Context) error { return syncKinesisTrigger(awsAccountID) }); err != nil {
+			run.Fatalf("Error updating Kinesis trigger: %v", err)
+		}
+	}
+
+	if config.Events.SchemaRegistry != "" {
+		if err := run.Step("register-schemas", func(ctx context.Context) error { return registerEventSchemas() }); err != nil {
+			run.Fatalf("Error registering event schemas: %v", err)
+		}
+	}
+
+	run.End(nil)
+	fmt.Println("Deployment completed successfully")
+	recordRelease(ctx)
+	if config.FunctionURL.Enabled {
+		printFunctionURL(ctx)
+	}
+	if console.Show {
+		console.Print(os.Stdout, consoleLinks()...)
+	}
+}
+
+// printFunctionURL prints the URL setup gave the function. The deploy has
+// succeeded by then, so a failed lookup is only reported.
+func printFunctionURL(ctx context.Context) {
+	input := &lambda.GetFunctionUrlConfigInput{FunctionName: aws.String(config.Lambda.FunctionName)}
+	if config.Deploy.Alias != "" {
+		input.Qualifier = aws.String(config.Deploy.Alias)
+	}
+	output, err := api.lambda.GetFunctionUrlConfig(ctx, input)
+	var notFound *lambdatypes.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		fmt.Println("The function has no URL yet; run setup to create it")
+	case err != nil:
+		log.Printf("Could not look up the function URL: %v", err)
+	default:
+		fmt.Printf("Function URL (%s): %s\n", output.AuthType, aws.ToString(output.FunctionUrl))
+	}
+}
+
+func loadConfig() error {
+	cfg, err := appconfig.Load()
+	if err != nil {
+		return err
+	}
+	config = *cfg
+	return nil
+}
+
+func checkIAMPermissions(ctx context.Context) error {
+	if _, err := api.iam.GetUser(ctx, &iam.GetUserInput{}); err != nil {
+		return fmt.Errorf("failed to get IAM user info: %v", err)
+	}
+	fmt.Println("Successfully retrieved IAM user info. You have the necessary permissions.")
+	return nil
+}
+
+// pushURI is repositoryURI on the registry host docker logs in and pushes
+// to, which aws.fips and aws.dual_stack move to the matching endpoint.
+func pushURI() string {
+	return config.Partition().PushURI(repositoryURI, config.Endpoints())
+}
+
+func buildDockerImage(w io.Writer) error {
+	opts, cleanup, err := config.BuildOptions(w)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	cmd := hostexec.DockerBuild(fmt.Sprintf("%s/%s", config.ECR.RepositoryName, config.Lambda.FunctionName), opts)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := hostexec.Run(cmd); err != nil {
+		return fmt.Errorf("failed to build Docker image: %v", err)
+	}
+	fmt.Fprintln(w, "Docker image built successfully")
+	return nil
+}
+
+// authenticateDocker lets docker push to the registry, through the ECR
+// credential helper when the Docker config uses it and docker login otherwise.
+// The docker commands that reach the registry run with dockerEnv.
+func authenticateDocker(ctx context.Context, w io.Writer) error {
+	registry, _, _ := strings.Cut(pushURI(), "/")
+	auth := registryauth.Auth{
+		Password:    func() (string, error) { return api.tokens.Password(ctx) },
+		Credentials: api.registry.Credentials,
+		Region:      api.registry.Region,
+	}
+	env, err := registryauth.Login(ctx, registry, auth, w)
+	if err != nil {
+		return fmt.Errorf("failed to login to ECR: %v%s", err, config.DockerLoginHint(registry))
+	}
+	dockerEnv = env
+	fmt.Fprintln(w, "Successfully authenticated Docker with ECR")
+	return nil
+}
+
+// dockerEnv is what authenticateDocker adds to the environment of docker push
+// and pull.
+var dockerEnv []string
+
+func tagDockerImage(w io.Writer) error {
+	cmd := exec.Command("docker", "tag",
+		localImage(),
+		pushURI()+":latest")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := hostexec.Run(cmd); err != nil {
+		return fmt.Errorf("failed to tag Docker image: %v", err)
+	}
+	fmt.Fprintln(w, "Docker image tagged successfully")
+	return nil
+}
+
+// pushDigestPattern matches the line docker push ends with, e.g.
+// "latest: digest: sha256:... size: 1573".
+var pushDigestPattern = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
+// pushDockerImage pushes the latest tag and returns the digest docker push
+// reports, or "" when its output has none.
+func pushDockerImage(w io.Writer) (string, error) {
+	var output bytes.Buffer
+	cmd := registryauth.Command(exec.Command("docker", "push", pushURI()+":latest"), dockerEnv)
+	cmd.Stdout = io.MultiWriter(w, &output)
+	cmd.Stderr = w
+	if err := hostexec.Run(cmd); err != nil {
+		return "", fmt.Errorf("failed to push Docker image: %v", err)
+	}
+	fmt.Fprintln(w, "Docker image pushed to ECR successfully")
+	var digest string
+	if match := pushDigestPattern.FindSubmatch(output.Bytes()); match != nil {
+		digest = string(match[1])
+	}
+	return digest, nil
+}
+
+// verifyPush asks the registry API, with the token docker logged in with,
+// which image latest now names. It must be the one docker push reported, or
+// the one ECR describes when the output had no digest; otherwise a concurrent
+// push moved the tag. A registry the CLI cannot reach only warns, as docker reached it.
+func verifyPush(ctx context.Context, w io.Writer, pushed, described string) error {
+	httpClient, err := config.HTTPClient(30 * time.Second)
+	if err != nil {
+		return err
+	}
+	host, repository, _ := strings.Cut(pushURI(), "/")
+	registry := registryauth.Registry{Host: host, Tokens: api.tokens, HTTP: httpClient}
+	latest, err := registry.Digest(ctx, repository, "latest")
+	if err != nil {
+		fmt.Fprintf(w, "Warning: could not verify the push with the registry API: %v\n", err)
+		return nil
+	}
+	want := pushed
+	if want == "" {
+		want = described
+	}
+	if latest != want {
+		return fmt.Errorf("%s:latest is %s, not the pushed %s; another push moved the tag", pushURI(), latest, want)
+	}
+	fmt.Fprintf(w, "Registry serves %s as latest\n", latest)
+	return nil
+}
+
+// latestImageDigest asks ECR which image the latest tag now points to.
+func latestImageDigest(ctx context.Context) (string, error) {
+	output, err := api.ecr.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(config.ECR.RepositoryName),
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: aws.String("latest")}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the pushed image: %v", err)
+	}
+	if len(output.ImageDetails) == 0 {
+		return "", fmt.Errorf("%s:latest is not in ECR after the push", config.ECR.RepositoryName)
+	}
+	return aws.ToString(output.ImageDetails[0].ImageDigest), nil
+}
+
+// verifyDeployedImage checks, once an update has finished, that the function
+// runs the image this deploy pushed. Lambda resolves :latest when the update
+// is processed, so a concurrent push or an update that Lambda rejected after
+// accepting the call would otherwise go unnoticed.
+func verifyDeployedImage(ctx context.Context, functionName string) error {
+	function, err := api.lambda.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %v", functionName, err)
+	}
+	if status := function.Configuration.LastUpdateStatus; status != lambdatypes.LastUpdateStatusSuccessful {
+		return fmt.Errorf("update of %s ended %s: %s", functionName, status, aws.ToString(function.Configuration.LastUpdateStatusReason))
+	}
+	resolved := aws.ToString(function.Code.ResolvedImageUri)
+	_, digest, _ := strings.Cut(resolved, "@")
+	codeSha := aws.ToString(function.Configuration.CodeSha256)
+	if digest != pushedDigest || "sha256:"+codeSha != pushedDigest {
+		return fmt.Errorf("%s runs %s (CodeSha256 %s), not the pushed image %s", functionName, resolved, codeSha, pushedDigest)
+	}
+	fmt.Printf("Lambda function %s runs %s\n", functionName, resolved)
+	return nil
+}
+
+func updateLambdaFunction(ctx context.Context, functionName string) error {
+	imageUri := repositoryURI + ":latest"
+	_, err := api.lambda.UpdateFunctionCode(ctx, &lambda.UpdateFunctionCodeInput{
+		FunctionName:  aws.String(functionName),
+		ImageUri:      aws.String(imageUri),
+		Architectures: []lambdatypes.Architecture{lambdatypes.Architecture(config.Build.Go.LambdaArchitecture())},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update Lambda function code: %v", err)
+	}
+
+	fmt.Println("Lambda function code updated successfully")
+	return nil
+}
+
+func updateLambdaConfiguration(ctx context.Context, functionName string) error {
+	input := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	}
+	if config.Lambda.Timeout > 0 {
+		input.Timeout = aws.Int32(int32(config.Lambda.Timeout))
+	}
+	if config.Lambda.MemorySize > 0 {
+		input.MemorySize = aws.Int32(int32(config.Lambda.MemorySize))
+	}
+	if config.Lambda.EphemeralStorage > 0 {
+		input.EphemeralStorage = &lambdatypes.EphemeralStorage{Size: aws.Int32(int32(config.Lambda.EphemeralStorage))}
+	}
+	if description := releaseDescription(); description != "" {
+		input.Description = aws.String(description)
+	}
+
+	if env := lambdaenv.Function(&config, lambdaenv.Resources{DatabaseHost: databaseProxy.Endpoint, WorkerQueueURL: workerQueueURL}); len(env) > 0 {
+		// Merge into the current variables so values set outside config.yaml survive
+		variables, err := getFunctionEnvironment(ctx, functionName)
+		if err != nil {
+			return err
+		}
+		if err := pinSecrets(env); err != nil {
+			return err
+		}
+		printEnvironmentChanges(os.Stdout, functionName, variables, env)
+		for k, v := range env {
+			variables[k] = v
+		}
+		input.Environment = &lambdatypes.Environment{Variables: variables}
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		if err := rolepolicy.Attach(ctx, api.iam, &config, rolepolicy.VPCAccess); err != nil {
+			return err
+		}
+		input.VpcConfig = &lambdatypes.VpcConfig{
+			SubnetIds:        config.VPC.SubnetIDs,
+			SecurityGroupIds: config.VPC.SecurityGroupIDs,
+		}
+	}
+	if config.Lambda.Tracing != "" {
+		if config.Lambda.Tracing == "Active" {
+			if err := rolepolicy.Attach(ctx, api.iam, &config, rolepolicy.XRayWrite); err != nil {
+				return err
+			}
+		}
+		input.TracingConfig = &lambdatypes.TracingConfig{Mode: lambdatypes.TracingMode(config.Lambda.Tracing)}
+	}
+	if config.Lambda.DeadLetterARN != "" {
+		input.DeadLetterConfig = &lambdatypes.DeadLetterConfig{TargetArn: aws.String(config.Lambda.DeadLetterARN)}
+	}
+
+	if err := updateConfiguration(ctx, input); err != nil {
+		return err
+	}
+	if err := tagFunction(ctx, functionName); err != nil {
+		return err
+	}
+	return applyReservedConcurrency(ctx, functionName)
+}
+
+// tagFunction adds lambda.tags and the release's tags to the function; tags
+// set by other means stay.
+func tagFunction(ctx context.Context, functionName string) error {
+	tags := map[string]string{}
+	maps.Copy(tags, releaseTags())
+	maps.Copy(tags, config.Lambda.Tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	function, err := api.lambda.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up function ARN: %v", err)
+	}
+	_, err = api.lambda.TagResource(ctx, &lambda.TagResourceInput{
+		Resource: function.FunctionArn,
+		Tags:     tags,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tag Lambda function: %v", err)
+	}
+	return nil
+}
+
+// updateConfiguration applies input, retrying while a previous update of the
+// function is still in progress or the role's VPC access is not in effect.
+func updateConfiguration(ctx context.Context, input *lambda.UpdateFunctionConfigurationInput) error {
+	maxRetries := 5
+	for i := 0; i < maxRetries; i++ {
+		_, err := api.lambda.UpdateFunctionConfiguration(ctx, input)
+		if err == nil {
+			fmt.Println("Lambda function configuration updated successfully")
+			return nil
+		}
+
+		var conflict *lambdatypes.ResourceConflictException
+		var invalid *lambdatypes.InvalidParameterValueException
+		switch {
+		case errors.As(err, &conflict):
+			fmt.Printf("Lambda function is still updating. Retrying in 10 seconds... (Attempt %d/%d)\n", i+1, maxRetries)
+		case errors.As(err, &invalid) && strings.Contains(invalid.ErrorMessage(), "CreateNetworkInterface"):
+			// A VPC access policy attached moments ago takes a while to apply
+			fmt.Printf("The execution role cannot join the VPC yet. Retrying in 10 seconds... (Attempt %d/%d)\n", i+1, maxRetries)
+		default:
+			return fmt.Errorf("failed to update Lambda function configuration: %v", err)
+		}
+		time.Sleep(10 * time.Second)
+	}
+
+	return fmt.Errorf("failed to update Lambda function configuration after %d attempts", maxRetries)
+}
+
+func getFunctionEnvironment(ctx context.Context, functionName string) (map[string]string, error) {
+	output, err := api.lambda.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Lambda function environment: %v", err)
+	}
+
+	variables := map[string]string{}
+	if output.Environment != nil {
+		for k, v := range output.Environment.Variables {
+			variables[k] = v
+		}
+	}
+	return variables, nil
+}
+
+// registerEventSchemas publishes the JSON Schema of every event in
+// events.Catalog to the configured schema registry, creating new schema
+// versions when the Go types change.
+func registerEventSchemas() error {
+	createRegistryCmd := exec.Command("aws", "schemas", "create-registry",
+		"--registry-name", config.Events.SchemaRegistry,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(createRegistryCmd)
+	if err != nil && !strings.Contains(string(output), "ConflictException") {
+		return fmt.Errorf("failed to create schema registry: %v\nOutput: %s", err, output)
+	}
+
+	for _, event := range events.Catalog {
+		content, err := json.Marshal(jsonschema.Generate(event))
+		if err != nil {
+			return fmt.Errorf("failed to generate schema for %s: %v", event.DetailType(), err)
+		}
+		schemaName := fmt.Sprintf("%s@%s", config.Events.Source, event.DetailType())
+
+		createSchemaCmd := exec.Command("aws", "schemas", "create-schema",
+			"--registry-name", config.Events.SchemaRegistry,
+			"--schema-name", schemaName,
+			"--type", "JSONSchemaDraft4",
+			"--content", string(content),
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		output, err := hostexec.CombinedOutput(createSchemaCmd)
+		if err != nil && strings.Contains(string(output), "ConflictException") {
+			updateSchemaCmd := exec.Command("aws", "schemas", "update-schema",
+				"--registry-name", config.Events.SchemaRegistry,
+				"--schema-name", schemaName,
+				"--type", "JSONSchemaDraft4",
+				"--content", string(content),
+				"--profile", config.AWS.Profile,
+				"--region", config.AWS.Region)
+			output, err = hostexec.CombinedOutput(updateSchemaCmd)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to register schema %s: %v\nOutput: %s", schemaName, err, output)
+		}
+		fmt.Printf("Registered event schema %s\n", schemaName)
+	}
+	return nil
+}
+
+// describeDatabaseProxy looks up the endpoint and resource ID (prx-...) of the
+// configured RDS Proxy; the resource ID is what IAM policies refer to.
+func describeDatabaseProxy() error {
+	describeCmd := exec.Command("aws", "rds", "describe-db-proxies",
+		"--db-proxy-name", config.Database.ProxyName,
+		"--query", "DBProxies[0].[Endpoint,DBProxyArn]",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err := hostexec.CombinedOutput(describeCmd)
+	if err != nil {
+		return fmt.Errorf("error describing RDS Proxy: %v\n%s", err, output)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected describe-db-proxies output: %s", output)
+	}
+	databaseProxy.Endpoint = fields[0]
+	databaseProxy.ResourceID = fields[1][strings.LastIndex(fields[1], ":")+1:]
+	return nil
+}
+
+// checkContracts runs the same check as `contract check` against contracts/.
+func checkContracts() error {
+	result, err := contractcheck.Check(contractcheck.DefaultDir)
+	if err != nil {
+		return fmt.Errorf("error checking contracts: %v", err)
+	}
+	if !result.OK() {
+		for _, b := range append(result.PublishedBreaks, result.Breaks...) {
+			fmt.Printf("BREAKING: %s\n", b)
+		}
+		return fmt.Errorf("handler types break recorded contracts; see `contract check`")
+	}
+	if result.Changed {
+		fmt.Println("Contract has compatible unpublished changes; run `contract check -update` to publish them.")
+	}
+	return nil
+}
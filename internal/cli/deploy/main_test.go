@@ -0,0 +1,1122 @@
+package deploy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.uber.org/mock/gomock"
+
+	"example-lambda-go/internal/audit"
+	"example-lambda-go/internal/concurrency"
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/history"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/introspect"
+	"example-lambda-go/internal/pipeline"
+	"example-lambda-go/internal/registryauth"
+	"example-lambda-go/internal/router"
+)
+
+// useClients swaps in mock SDK clients for one test.
+func useClients(t *testing.T) (*MockecrAPI, *MocklambdaAPI) {
+	ctrl := gomock.NewController(t)
+	e, l := NewMockecrAPI(ctrl), NewMocklambdaAPI(ctrl)
+	previous := api
+	api = clients{iam: NewMockiamAPI(ctrl), ecr: e, sts: NewMockstsAPI(ctrl), lambda: l, tokens: &registryauth.Tokens{Client: e}}
+	t.Cleanup(func() { api = previous })
+	return e, l
+}
+
+func expectLogin(e *MockecrAPI, password string) {
+	e.EXPECT().GetAuthorizationToken(gomock.Any(), gomock.Any()).Return(&ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []ecrtypes.AuthorizationData{{AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:" + password)))}},
+	}, nil)
+}
+
+// useFake swaps in a fake runner and a minimal config for one test.
+func useFake(t *testing.T) *hostexec.Fake {
+	fake := hostexec.NewFake()
+	previous, previousConfig := hostexec.Default, config
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default, config = previous, previousConfig })
+	// Keep the host's Docker credential store out of the login commands
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	config = appconfig.Config{}
+	config.AWS.Region = "us-east-1"
+	config.AWS.Profile = "default"
+	config.Lambda.FunctionName = "hello"
+	return fake
+}
+
+func TestBuildAndPushCommands(t *testing.T) {
+	fake := useFake(t)
+	e, _ := useClients(t)
+	expectLogin(e, "password")
+	config.AWS.Region = "eu-west-1"
+	config.ECR.RepositoryName = "repo"
+	config.Lambda.FunctionName = "fn"
+	config.Lambda.Handler = "export"
+	repositoryURI = "123456789012.dkr.ecr.eu-west-1.amazonaws.com/repo"
+
+	if err := buildDockerImage(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := authenticateDocker(context.Background(), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"docker build --platform linux/amd64 -t repo/fn --build-arg HANDLER=export .",
+		"docker login --username AWS --password-stdin 123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+	}
+	got := fake.Commands()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if fake.Calls[1].Stdin != "password" {
+		t.Errorf("docker login stdin = %q, want the ECR password", fake.Calls[1].Stdin)
+	}
+}
+
+func TestAuthenticateDockerReportsLoginFailure(t *testing.T) {
+	fake := useFake(t)
+	e, _ := useClients(t)
+	expectLogin(e, "password")
+	fake.On([]string{"docker", "login"}, hostexec.Response{Err: errors.New("exit status 1")})
+
+	err := authenticateDocker(context.Background(), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "failed to login to ECR") {
+		t.Errorf("err = %v, want a login failure", err)
+	}
+}
+
+func TestDockerAndRegistryAPIShareToken(t *testing.T) {
+	fake := useFake(t)
+	e, _ := useClients(t)
+	// One token, from the registry client, for docker login and the API
+	e.EXPECT().GetAuthorizationToken(gomock.Any(), gomock.Any()).Return(&ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []ecrtypes.AuthorizationData{{
+			AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:password"))),
+			ExpiresAt:          aws.Time(time.Now().Add(12 * time.Hour)),
+		}},
+	}, nil)
+	const digest = "sha256:4f2b0c1d3e5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "AWS" || password != "password" {
+			t.Errorf("registry API authenticated as %s:%s, want docker login's token", user, password)
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config.TLS.CABundle = bundle
+	repositoryURI = strings.TrimPrefix(server.URL, "https://") + "/repo"
+
+	if err := authenticateDocker(context.Background(), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyPush(context.Background(), io.Discard, digest, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Commands(); len(got) != 1 || !strings.HasPrefix(got[0], "docker login") {
+		t.Errorf("commands = %q, want one docker login", got)
+	}
+}
+
+func TestBlueGreenFunctionsFollowLiveTag(t *testing.T) {
+	for _, test := range []struct {
+		tags       string
+		live, idle string
+	}{
+		{`{"Tags":{}}`, "hello", "hello-green"},
+		{`{"Tags":{"lambda-template:live":"blue"}}`, "hello", "hello-green"},
+		{`{"Tags":{"lambda-template:live":"green"}}`, "hello-green", "hello"},
+	} {
+		fake := useFake(t)
+		fake.On([]string{"aws", "lambda", "list-tags"}, hostexec.Response{Output: []byte(test.tags)})
+
+		live, idle, err := blueGreenFunctions("123")
+		if err != nil || live != test.live || idle != test.idle {
+			t.Errorf("tags %s: blueGreenFunctions = %q, %q, %v; want %q, %q", test.tags, live, idle, err, test.live, test.idle)
+		}
+	}
+}
+
+func TestMoveTriggersRecordsLiveColor(t *testing.T) {
+	for _, test := range []struct {
+		from, to, tag string
+	}{
+		{"hello", "hello-green", "lambda-template:live=green"},
+		{"hello-green", "hello", "lambda-template:live=blue"},
+	} {
+		fake := useFake(t)
+		fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte(`["uuid-1"]`)})
+		fake.On([]string{"aws", "events", "list-rule-names-by-target"}, hostexec.Response{Output: []byte(`[]`)})
+
+		if err := moveTriggers(test.from, test.to, "123"); err != nil {
+			t.Fatal(err)
+		}
+		commands := fake.Commands()
+		if !strings.HasPrefix(commands[1], "aws lambda update-event-source-mapping --uuid uuid-1 --function-name "+test.to+" ") {
+			t.Errorf("mapping update = %q", commands[1])
+		}
+		// The tag always lives on the blue function, whichever is live
+		tag := fake.Calls[len(fake.Calls)-1].Args
+		want := []string{"aws", "lambda", "tag-resource", "--resource", "arn:aws:lambda:us-east-1:123:function:hello", "--tags", test.tag}
+		if strings.Join(tag[:7], " ") != strings.Join(want, " ") {
+			t.Errorf("tag command = %q, want %q", tag, want)
+		}
+	}
+}
+
+func TestMoveTriggersMovesAPIIntegration(t *testing.T) {
+	fake := useFake(t)
+	config.API.Enabled = true
+	fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte(`[]`)})
+	fake.On([]string{"aws", "events", "list-rule-names-by-target"}, hostexec.Response{Output: []byte(`[]`)})
+	fake.On([]string{"aws", "apigatewayv2", "get-apis"}, hostexec.Response{Output: []byte(`["api-1"]`)})
+	fake.On([]string{"aws", "apigatewayv2", "get-integrations"}, hostexec.Response{Output: []byte(`["int-1"]`)})
+
+	if err := moveTriggers("hello", "hello-green", "123"); err != nil {
+		t.Fatal(err)
+	}
+	var permission, update string
+	for _, command := range fake.Commands() {
+		switch {
+		case strings.HasPrefix(command, "aws lambda add-permission"):
+			permission = command
+		case strings.HasPrefix(command, "aws apigatewayv2 update-integration"):
+			update = command
+		}
+	}
+	if !strings.Contains(permission, "--function-name hello-green --statement-id apigateway-api-1") {
+		t.Errorf("add-permission = %q, want the HTTP API allowed to invoke the idle function", permission)
+	}
+	if !strings.Contains(update, "--api-id api-1 --integration-id int-1 --integration-uri arn:aws:lambda:us-east-1:123:function:hello-green") {
+		t.Errorf("update-integration = %q, want the integration moved to the idle function", update)
+	}
+}
+
+func TestSyncSchedule(t *testing.T) {
+	notFound := hostexec.Response{Output: []byte("An error occurred (ResourceNotFoundException) when calling the DescribeRule operation"), Err: errors.New("exit status 254")}
+	for _, test := range []struct {
+		name, schedule string
+		rule           hostexec.Response
+		want, not      []string
+	}{
+		{
+			name: "added", schedule: "rate(5 minutes)", rule: notFound,
+			want: []string{
+				"aws events put-rule --name hello-schedule --schedule-expression rate(5 minutes)",
+				"aws lambda add-permission --function-name hello-green --statement-id hello-schedule",
+				"aws events put-targets --rule hello-schedule --targets Id=hello,Arn=arn:aws:lambda:us-east-1:123:function:hello-green",
+			},
+		},
+		{
+			name: "changed", schedule: "rate(5 minutes)", rule: hostexec.Response{Output: []byte("rate(1 hour)\n")},
+			want: []string{"aws events put-rule --name hello-schedule --schedule-expression rate(5 minutes)"},
+			not:  []string{"aws events put-targets", "--tags"},
+		},
+		{
+			name: "unchanged", schedule: "rate(5 minutes)", rule: hostexec.Response{Output: []byte("rate(5 minutes)\n")},
+			not: []string{"aws events put-rule"},
+		},
+		{
+			name: "removed", rule: hostexec.Response{Output: []byte("rate(5 minutes)\n")},
+			want: []string{"aws events remove-targets --rule hello-schedule", "--ids hello", "aws events delete-rule --name hello-schedule"},
+		},
+		{
+			name: "never scheduled", rule: notFound,
+			not: []string{"aws events put-rule", "aws events delete-rule"},
+		},
+	} {
+		fake := useFake(t)
+		config.Lambda.Schedule = test.schedule
+		config.Deploy.Strategy = "bluegreen"
+		fake.On([]string{"aws", "events", "describe-rule"}, test.rule)
+		fake.On([]string{"aws", "events", "put-rule"}, hostexec.Response{Output: []byte("arn:aws:events:us-east-1:123:rule/hello-schedule\n")})
+		fake.On([]string{"aws", "events", "list-targets-by-rule"}, hostexec.Response{Output: []byte(`["hello"]`)})
+		fake.On([]string{"aws", "lambda", "list-tags"}, hostexec.Response{Output: []byte(`{"Tags":{"lambda-template:live":"green"}}`)})
+
+		if err := syncSchedule("123"); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		commands := strings.Join(fake.Commands(), "\n")
+		for _, want := range test.want {
+			if !strings.Contains(commands, want) {
+				t.Errorf("%s: missing %q in:\n%s", test.name, want, commands)
+			}
+		}
+		for _, not := range test.not {
+			if strings.Contains(commands, not) {
+				t.Errorf("%s: unexpected %q in:\n%s", test.name, not, commands)
+			}
+		}
+	}
+}
+
+func TestSyncSQSTrigger(t *testing.T) {
+	for _, test := range []struct {
+		name, mapping string
+		update        bool
+	}{
+		{name: "changed", mapping: "uuid-1\t10\t0\n", update: true},
+		{name: "unchanged", mapping: "uuid-1\t100\t20\n"},
+		{name: "not created yet", mapping: "None\n"},
+	} {
+		fake := useFake(t)
+		config.Triggers.SQS = appconfig.SQSTrigger{Queue: "arn:aws:sqs:us-east-1:123:orders", BatchSize: 100, BatchWindow: "20s"}
+		fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte(test.mapping)})
+
+		if err := syncSQSTrigger("123"); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		commands := strings.Join(fake.Commands(), "\n")
+		if !strings.Contains(commands, "--function-name hello --event-source-arn arn:aws:sqs:us-east-1:123:orders") {
+			t.Errorf("%s: mapping looked up with:\n%s", test.name, commands)
+		}
+		want := "aws lambda update-event-source-mapping --uuid uuid-1 --batch-size 100 --maximum-batching-window-in-seconds 20"
+		if strings.Contains(commands, want) != test.update {
+			t.Errorf("%s: update %v in:\n%s", test.name, test.update, commands)
+		}
+	}
+}
+
+func TestSyncTriggersOfAlias(t *testing.T) {
+	fake := useFake(t)
+	config.Deploy.Alias = "live"
+	config.Lambda.Schedule = "rate(5 minutes)"
+	config.Triggers.SQS = appconfig.SQSTrigger{Queue: "arn:aws:sqs:us-east-1:123:orders", BatchSize: 100}
+	fake.On([]string{"aws", "events", "describe-rule"}, hostexec.Response{Output: []byte("An error occurred (ResourceNotFoundException) when calling the DescribeRule operation"), Err: errors.New("exit status 254")})
+	fake.On([]string{"aws", "events", "put-rule"}, hostexec.Response{Output: []byte("arn:aws:events:us-east-1:123:rule/hello-schedule\n")})
+	fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte("uuid-1\t100\t0\n")})
+
+	if err := syncSchedule("123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncSQSTrigger("123"); err != nil {
+		t.Fatal(err)
+	}
+	commands := strings.Join(fake.Commands(), "\n")
+	for _, want := range []string{
+		"aws lambda add-permission --function-name hello:live --statement-id hello-schedule",
+		"--targets Id=hello,Arn=arn:aws:lambda:us-east-1:123:function:hello:live",
+		"aws lambda list-event-source-mappings --function-name hello:live --event-source-arn arn:aws:sqs:us-east-1:123:orders",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("missing %q in:\n%s", want, commands)
+		}
+	}
+}
+
+func TestSyncDynamoDBTrigger(t *testing.T) {
+	fake := useFake(t)
+	config.Triggers.DynamoDB = appconfig.DynamoDBTrigger{Stream: "arn:aws:dynamodb:us-east-1:123:table/orders/stream/2024-01-01T00:00:00.000", BatchSize: 500, ParallelizationFactor: 4}
+	fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte("uuid-2\t100\t1\n")})
+
+	if err := syncDynamoDBTrigger("123"); err != nil {
+		t.Fatal(err)
+	}
+	commands := strings.Join(fake.Commands(), "\n")
+	want := "aws lambda update-event-source-mapping --uuid uuid-2 --batch-size 500 --parallelization-factor 4"
+	if !strings.Contains(commands, "[UUID, BatchSize, ParallelizationFactor]") || !strings.Contains(commands, want) {
+		t.Errorf("commands run:\n%s\nwant the mapping looked up and updated with %s", commands, want)
+	}
+}
+
+func TestSyncKinesisTrigger(t *testing.T) {
+	for _, test := range []struct {
+		name, mapping string
+		putPolicy     bool
+		update        string
+	}{
+		{name: "unchanged", mapping: "uuid-3\t60\tarn:aws:sqs:us-east-1:123:clicks-failed\n"},
+		{name: "new destination", mapping: "uuid-3\t60\tNone\n", putPolicy: true,
+			update: `--tumbling-window-in-seconds 60 --destination-config {"OnFailure":{"Destination":"arn:aws:sqs:us-east-1:123:clicks-failed"}}`},
+		{name: "new window", mapping: "uuid-3\t0\tarn:aws:sqs:us-east-1:123:clicks-failed\n",
+			update: "--tumbling-window-in-seconds 60"},
+	} {
+		fake := useFake(t)
+		config.Triggers.Kinesis = appconfig.KinesisTrigger{Stream: "clicks", TumblingWindow: "1m", OnFailure: "arn:aws:sqs:us-east-1:123:clicks-failed"}
+		fake.On([]string{"aws", "lambda", "list-event-source-mappings"}, hostexec.Response{Output: []byte(test.mapping)})
+
+		if err := syncKinesisTrigger("123"); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		commands := strings.Join(fake.Commands(), "\n")
+		if !strings.Contains(commands, "--event-source-arn arn:aws:kinesis:us-east-1:123:stream/clicks") {
+			t.Errorf("%s: mapping looked up with:\n%s", test.name, commands)
+		}
+		if strings.Contains(commands, "aws iam put-role-policy") != test.putPolicy {
+			t.Errorf("%s: role policy put %v in:\n%s", test.name, test.putPolicy, commands)
+		}
+		if updated := strings.Contains(commands, "update-event-source-mapping --uuid uuid-3 "+test.update); updated != (test.update != "") {
+			t.Errorf("%s: want update %q in:\n%s", test.name, test.update, commands)
+		}
+	}
+}
+
+func TestHandlerMismatches(t *testing.T) {
+	manifest := func(routes ...router.Route) introspect.Manifest {
+		return introspect.Manifest{Routes: routes, Handlers: []string{"greet", "jobs"}, Jobs: []string{"send-greeting"}}
+	}
+	jobs := router.Route{Name: "jobs", Handler: "jobs"}
+	jobs.Match.SQSAttribute.Name, jobs.Match.SQSAttribute.Value = "kind", "job"
+	greet := router.Route{Name: "greet-default", Handler: "greet", Default: true}
+
+	for _, test := range []struct {
+		name      string
+		configure func(*appconfig.Config)
+		manifest  introspect.Manifest
+		problems  []string
+		unused    []string
+	}{
+		{
+			name:      "queue without an SQS route",
+			configure: func(c *appconfig.Config) { c.Triggers.SQS.Queue = "orders" },
+			manifest:  manifest(greet),
+			problems:  []string{"triggers.sqs is set but the image has no sqs_attribute route"},
+			unused:    []string{"handler jobs is registered but no route reaches it"},
+		},
+		{
+			name:      "covered",
+			configure: func(c *appconfig.Config) { c.Worker.QueueName = "jobs"; c.Triggers.SNS.Topic = "alerts" },
+			manifest:  manifest(jobs, greet),
+		},
+		{
+			name:      "stream without a default route",
+			configure: func(c *appconfig.Config) { c.Triggers.Kinesis.Stream = "clicks" },
+			manifest:  manifest(jobs),
+			problems:  []string{"triggers.kinesis is set but the image has no default route"},
+			unused: []string{
+				"handler greet is registered but no route reaches it",
+				"route jobs matches SQS messages but neither worker.queue_name nor triggers.sqs is set",
+			},
+		},
+		{
+			name:      "route to an unregistered handler",
+			configure: func(c *appconfig.Config) { c.Lambda.Schedule = "rate(1 hour)" },
+			manifest:  manifest(jobs, router.Route{Name: "fallback", Handler: "orders", Default: true}, greet),
+			problems:  []string{"route fallback refers to handler orders, which is not registered"},
+			unused:    []string{"route jobs matches SQS messages but neither worker.queue_name nor triggers.sqs is set"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &appconfig.Config{}
+			test.configure(cfg)
+			problems, unused := handlerMismatches(cfg, test.manifest)
+			if !reflect.DeepEqual(problems, test.problems) || !reflect.DeepEqual(unused, test.unused) {
+				t.Errorf("got problems %q, unused %q; want %q, %q", problems, unused, test.problems, test.unused)
+			}
+		})
+	}
+}
+
+func TestConfigEnvironmentOverridesFunctionEnvironment(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	config.Tenant.Claim = "tenant_id"
+	config.Lambda.Environment = map[string]string{"LOG_LEVEL": "info"}
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionConfigurationOutput{
+		Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{"TENANT_CLAIM": "org", "SET_BY_HAND": "kept"}},
+	}, nil)
+	var got map[string]string
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionConfigurationInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+		got = input.Environment.Variables
+		return &lambda.UpdateFunctionConfigurationOutput{}, nil
+	})
+
+	if err := updateLambdaConfiguration(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got["TENANT_CLAIM"] != "tenant_id" || got["SET_BY_HAND"] != "kept" || got["LOG_LEVEL"] != "info" {
+		t.Errorf("variables = %v, want config.yaml to win and other variables kept", got)
+	}
+}
+
+func TestPrintEnvironmentChanges(t *testing.T) {
+	var out strings.Builder
+	current := map[string]string{"LOG_LEVEL": "debug", "REGION_NAME": "west", "API_KEY": "old", "SET_BY_HAND": "kept"}
+	env := map[string]string{"LOG_LEVEL": "info", "REGION_NAME": "west", "API_KEY": "new", "FEATURE_FLAGS": "beta"}
+	printEnvironmentChanges(&out, "hello", current, env)
+	want := `Environment changes to hello:
+  ~ API_KEY: (hidden) -> (hidden)
+  + FEATURE_FLAGS="beta"
+  ~ LOG_LEVEL: "debug" -> "info"
+`
+	if out.String() != want {
+		t.Errorf("printed:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	printEnvironmentChanges(&out, "hello", current, map[string]string{"LOG_LEVEL": "debug"})
+	if out.Len() != 0 {
+		t.Errorf("printed %q for an unchanged environment", out.String())
+	}
+}
+
+func TestPinSecrets(t *testing.T) {
+	fake := useFake(t)
+	const arn = "arn:aws:secretsmanager:us-east-1:123:secret:hello/db-AbCdEf"
+	fake.On([]string{"aws", "secretsmanager", "describe-secret", "--secret-id", "hello/db"}, hostexec.Response{Output: []byte(arn + "\n")})
+	fake.On([]string{"aws", "secretsmanager", "describe-secret", "--secret-id", "hello/gone"}, hostexec.Response{Output: []byte("An error occurred (ResourceNotFoundException)"), Err: errors.New("exit status 254")})
+	t.Cleanup(func() { secretARNs = map[string]string{} })
+
+	env := map[string]string{
+		"DB_PASSWORD": "secretsmanager:hello/db:password",
+		"DB_USER":     "secretsmanager:hello/db:username",
+		"OTHER_KEY":   "secretsmanager:arn:aws:secretsmanager:us-east-1:999:secret:shared-XyZ123",
+		"LOG_LEVEL":   "info",
+	}
+	if err := pinSecrets(env); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_PASSWORD": "secretsmanager:" + arn + ":password",
+		"DB_USER":     "secretsmanager:" + arn + ":username",
+		"OTHER_KEY":   "secretsmanager:arn:aws:secretsmanager:us-east-1:999:secret:shared-XyZ123",
+		"LOG_LEVEL":   "info",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("pinned = %v, want %v", env, want)
+	}
+
+	var out strings.Builder
+	printEnvironmentChanges(&out, "hello", nil, map[string]string{"DB_PASSWORD": want["DB_PASSWORD"]})
+	if !strings.Contains(out.String(), arn) {
+		t.Errorf("printed %q, want the reference shown", out.String())
+	}
+
+	if err := pinSecrets(map[string]string{"API_KEY": "secretsmanager:hello/gone"}); err == nil || !strings.Contains(err.Error(), "API_KEY: failed to look up secret hello/gone") {
+		t.Errorf("pinSecrets error = %v", err)
+	}
+}
+
+func TestReleaseGoesInDescriptionAndTags(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	config.Lambda.Tags = map[string]string{"team": "payments"}
+	release = history.Record{Commit: "abcdef1234", Changes: []history.Change{{Commit: "abcdef1234", Subject: "Fix greeting"}, {Commit: "0123456789", Subject: "Add (tabs)"}}}
+	t.Cleanup(func() { release = history.Record{} })
+
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionConfigurationInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+		if want := "Deployed abcdef1: abcdef1 Fix greeting; 0123456 Add (tabs)"; aws.ToString(input.Description) != want {
+			t.Errorf("Description = %q, want %q", aws.ToString(input.Description), want)
+		}
+		return &lambda.UpdateFunctionConfigurationOutput{}, nil
+	})
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionConfigurationOutput{FunctionArn: aws.String("arn:aws:lambda:us-east-1:123:function:hello")}, nil)
+	l.EXPECT().TagResource(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.TagResourceInput, _ ...func(*lambda.Options)) (*lambda.TagResourceOutput, error) {
+		want := map[string]string{"team": "payments", commitTag: "abcdef1234", changelogTag: "abcdef1 Fix greeting 0123456 Add tabs"}
+		if !reflect.DeepEqual(input.Tags, want) {
+			t.Errorf("Tags = %v, want %v", input.Tags, want)
+		}
+		return &lambda.TagResourceOutput{}, nil
+	})
+
+	if err := updateLambdaConfiguration(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReleaseStartsFromLiveCommitWithoutHistory(t *testing.T) {
+	fake := useFake(t)
+	_, l := useClients(t)
+	config.Audit.History = filepath.Join(t.TempDir(), "history.jsonl")
+	t.Cleanup(func() { release = history.Record{} })
+
+	l.EXPECT().GetFunction(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionOutput{Tags: map[string]string{commitTag: "0123456789"}}, nil)
+	fake.On([]string{"git", "log", "--format=%H%x09%s", "0123456789..abcdef1234"}, hostexec.Response{Output: []byte("abcdef1234\tFix greeting\n")})
+
+	prepareRelease(context.Background(), "abcdef1234")
+	if release.Previous != "0123456789" || len(release.Changes) != 1 || release.Changes[0].Subject != "Fix greeting" {
+		t.Errorf("release = %+v, want the changelog since the function's commit tag", release)
+	}
+}
+
+func TestManagedPoliciesAttachedBeforeUpdate(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	config.Lambda.RoleName = "hello-role"
+	config.Lambda.Tracing = "Active"
+	config.VPC.SubnetIDs = []string{"subnet-1", "subnet-2"}
+	config.VPC.SecurityGroupIDs = []string{"sg-1"}
+
+	var steps []string
+	api.iam.(*MockiamAPI).EXPECT().AttachRolePolicy(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *iam.AttachRolePolicyInput, _ ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+		if aws.ToString(input.RoleName) != "hello-role" {
+			t.Errorf("AttachRolePolicy(%s)", aws.ToString(input.RoleName))
+		}
+		steps = append(steps, strings.TrimPrefix(aws.ToString(input.PolicyArn), "arn:aws:iam::aws:policy/"))
+		return &iam.AttachRolePolicyOutput{}, nil
+	}).Times(2)
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionConfigurationInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+		steps = append(steps, "update")
+		if input.VpcConfig == nil || !reflect.DeepEqual(input.VpcConfig.SubnetIds, config.VPC.SubnetIDs) || !reflect.DeepEqual(input.VpcConfig.SecurityGroupIds, config.VPC.SecurityGroupIDs) {
+			t.Errorf("VpcConfig = %+v", input.VpcConfig)
+		}
+		if input.TracingConfig == nil || input.TracingConfig.Mode != lambdatypes.TracingModeActive {
+			t.Errorf("TracingConfig = %+v", input.TracingConfig)
+		}
+		return &lambda.UpdateFunctionConfigurationOutput{}, nil
+	})
+
+	if err := updateLambdaConfiguration(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "service-role/AWSLambdaVPCAccessExecutionRole AWSXRayDaemonWriteAccess update"; strings.Join(steps, " ") != want {
+		t.Errorf("steps %q, want %q: the role needs the policies before the function uses them", strings.Join(steps, " "), want)
+	}
+}
+
+func TestUpdateConfigurationRetriesOnlyConflicts(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.InvalidParameterValueException{Message: aws.String("bad memory size")})
+
+	err := updateConfiguration(context.Background(), &lambda.UpdateFunctionConfigurationInput{FunctionName: aws.String("hello")})
+	if err == nil || !strings.Contains(err.Error(), "bad memory size") {
+		t.Errorf("updateConfiguration = %v, want the SDK error without retrying", err)
+	}
+}
+
+func TestPublishAliasesGivesEachAliasItsOwnVersion(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	config.Aliases = map[string]appconfig.Alias{
+		"live":   {ProvisionedConcurrency: 5},
+		"canary": {Environment: map[string]string{"DB_HOST": "staging-db"}},
+	}
+
+	var steps, environments []string
+	step := func(name string) { steps = append(steps, name) }
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionConfigurationOutput{
+		Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{"DB_HOST": "prod-db"}},
+	}, nil)
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionConfigurationInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+		step("update")
+		environments = append(environments, input.Environment.Variables["DB_HOST"])
+		return &lambda.UpdateFunctionConfigurationOutput{}, nil
+	}).Times(3)
+	l.EXPECT().GetFunction(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *lambda.GetFunctionInput, ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+		step("wait")
+		return &lambda.GetFunctionOutput{Configuration: &lambdatypes.FunctionConfiguration{LastUpdateStatus: lambdatypes.LastUpdateStatusSuccessful}}, nil
+	}).Times(3)
+	l.EXPECT().PublishVersion(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *lambda.PublishVersionInput, ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+		step("publish")
+		return &lambda.PublishVersionOutput{Version: aws.String("7")}, nil
+	}).Times(2)
+	l.EXPECT().UpdateAlias(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateAliasInput, _ ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error) {
+		step("update-alias")
+		if *input.Name == "canary" {
+			return nil, &lambdatypes.ResourceNotFoundException{}
+		}
+		return &lambda.UpdateAliasOutput{}, nil
+	}).Times(2)
+	l.EXPECT().CreateAlias(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateAliasInput, _ ...func(*lambda.Options)) (*lambda.CreateAliasOutput, error) {
+		step("create-alias")
+		if *input.Name != "canary" || *input.FunctionVersion != "7" {
+			t.Errorf("CreateAlias(%s, %s)", *input.Name, *input.FunctionVersion)
+		}
+		return &lambda.CreateAliasOutput{}, nil
+	})
+	l.EXPECT().DeleteProvisionedConcurrencyConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *lambda.DeleteProvisionedConcurrencyConfigInput, ...func(*lambda.Options)) (*lambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+		step("delete-concurrency")
+		return nil, &lambdatypes.ProvisionedConcurrencyConfigNotFoundException{}
+	})
+	l.EXPECT().PutProvisionedConcurrencyConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.PutProvisionedConcurrencyConfigInput, _ ...func(*lambda.Options)) (*lambda.PutProvisionedConcurrencyConfigOutput, error) {
+		step("put-concurrency")
+		if *input.Qualifier != "live" || *input.ProvisionedConcurrentExecutions != 5 {
+			t.Errorf("PutProvisionedConcurrencyConfig(%s, %d)", *input.Qualifier, *input.ProvisionedConcurrentExecutions)
+		}
+		return &lambda.PutProvisionedConcurrencyConfigOutput{}, nil
+	})
+
+	if err := publishAliases(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"update", "wait", "publish", "update-alias", "create-alias", "delete-concurrency",
+		"update", "wait", "publish", "update-alias", "put-concurrency",
+		"update", "wait",
+	}
+	if strings.Join(steps, " ") != strings.Join(want, " ") {
+		t.Errorf("steps:\n%s\nwant:\n%s", strings.Join(steps, " "), strings.Join(want, " "))
+	}
+	if strings.Join(environments, ",") != "staging-db,prod-db,prod-db" {
+		t.Errorf("DB_HOST per update = %q, want canary's override, then live's and the restored base", environments)
+	}
+}
+
+func TestPublishAliasesRestoresBaseOnFailure(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	config.Aliases = map[string]appconfig.Alias{
+		"canary": {Environment: map[string]string{"DB_HOST": "staging-db"}},
+	}
+
+	var environments []string
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionConfigurationOutput{
+		Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{"DB_HOST": "prod-db"}},
+	}, nil)
+	l.EXPECT().UpdateFunctionConfiguration(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionConfigurationInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+		environments = append(environments, input.Environment.Variables["DB_HOST"])
+		return &lambda.UpdateFunctionConfigurationOutput{}, nil
+	}).Times(2)
+	l.EXPECT().GetFunction(gomock.Any(), gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionOutput{
+		Configuration: &lambdatypes.FunctionConfiguration{LastUpdateStatus: lambdatypes.LastUpdateStatusSuccessful},
+	}, nil).Times(2)
+	l.EXPECT().PublishVersion(gomock.Any(), gomock.Any()).Return(nil, errors.New("throttled"))
+
+	if err := publishAliases(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "throttled") {
+		t.Errorf("publishAliases = %v, want the publish error", err)
+	}
+	if strings.Join(environments, ",") != "staging-db,prod-db" {
+		t.Errorf("DB_HOST per update = %q, want the base restored after the failure", environments)
+	}
+}
+
+func TestParseCanaryWeight(t *testing.T) {
+	for value, want := range map[string]float64{"10%": 0.1, "2.5": 0.025, " 50% ": 0.5} {
+		if got, err := parseCanaryWeight(value); err != nil || got != want {
+			t.Errorf("parseCanaryWeight(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"0%", "100%", "ten", "-5"} {
+		if _, err := parseCanaryWeight(value); err == nil {
+			t.Errorf("parseCanaryWeight(%q) succeeded", value)
+		}
+	}
+}
+
+func TestCanaryShiftsThenPromotes(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	previous := canaryWeight
+	canaryWeight = 0.1
+	t.Cleanup(func() { canaryWeight = previous })
+
+	alias := &lambda.GetAliasOutput{FunctionVersion: aws.String("6")}
+	l.EXPECT().GetAlias(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *lambda.GetAliasInput, ...func(*lambda.Options)) (*lambda.GetAliasOutput, error) {
+		return alias, nil
+	}).Times(2)
+	l.EXPECT().UpdateAlias(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateAliasInput, _ ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error) {
+		alias = &lambda.GetAliasOutput{FunctionVersion: input.FunctionVersion, RoutingConfig: input.RoutingConfig}
+		return &lambda.UpdateAliasOutput{}, nil
+	}).Times(2)
+
+	if err := startCanary(context.Background(), "hello", "live", "7"); err != nil {
+		t.Fatal(err)
+	}
+	if *alias.FunctionVersion != "6" || alias.RoutingConfig.AdditionalVersionWeights["7"] != 0.1 {
+		t.Errorf("alias after -canary = %s with %v, want 6 with 10%% to 7", *alias.FunctionVersion, alias.RoutingConfig.AdditionalVersionWeights)
+	}
+
+	detail, err := finishCanary(context.Background(), "hello", "live", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *alias.FunctionVersion != "7" || len(alias.RoutingConfig.AdditionalVersionWeights) != 0 {
+		t.Errorf("alias after -promote = %s with %v, want all traffic on 7", *alias.FunctionVersion, alias.RoutingConfig.AdditionalVersionWeights)
+	}
+	if detail != "live: version 6 -> 7" {
+		t.Errorf("detail = %q", detail)
+	}
+}
+
+func TestRecordCanaryWritesAuditEntry(t *testing.T) {
+	useFake(t)
+	useClients(t)
+	api.sts.(*MockstsAPI).EXPECT().GetCallerIdentity(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{
+		Arn: aws.String("arn:aws:iam::123456789012:user/dev"),
+	}, nil)
+	config.Audit.File = filepath.Join(t.TempDir(), "audit.log")
+
+	recordCanary(context.Background(), "deploy -promote", "live: version 6 -> 7")
+	data, err := os.ReadFile(config.Audit.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry audit.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Action != "deploy -promote" || entry.Actor != "arn:aws:iam::123456789012:user/dev" || entry.Function != "hello" {
+		t.Errorf("entry = %+v", entry)
+	}
+}
+
+func TestAbortWithoutCanaryFails(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	l.EXPECT().GetAlias(gomock.Any(), gomock.Any()).Return(&lambda.GetAliasOutput{FunctionVersion: aws.String("6")}, nil)
+
+	if _, err := finishCanary(context.Background(), "hello", "live", false); err == nil || !strings.Contains(err.Error(), "no traffic shift") {
+		t.Errorf("finishCanary() = %v, want no shift in progress", err)
+	}
+}
+
+func TestVerifyDeployedImage(t *testing.T) {
+	useFake(t)
+	e, l := useClients(t)
+	config.ECR.RepositoryName = "repo"
+	t.Cleanup(func() { pushedDigest = "" })
+
+	e.EXPECT().DescribeImages(gomock.Any(), gomock.Any()).Return(&ecr.DescribeImagesOutput{
+		ImageDetails: []ecrtypes.ImageDetail{{ImageDigest: aws.String("sha256:aaaa")}},
+	}, nil)
+	digest, err := latestImageDigest(context.Background())
+	if err != nil || digest != "sha256:aaaa" {
+		t.Fatalf("latestImageDigest() = %s, %v, want sha256:aaaa", digest, err)
+	}
+	pushedDigest = digest
+
+	running := func(digest string, status lambdatypes.LastUpdateStatus) *lambda.GetFunctionOutput {
+		return &lambda.GetFunctionOutput{
+			Code: &lambdatypes.FunctionCodeLocation{ResolvedImageUri: aws.String("123.dkr.ecr.us-east-1.amazonaws.com/repo@sha256:" + digest)},
+			Configuration: &lambdatypes.FunctionConfiguration{
+				CodeSha256:             aws.String(digest),
+				LastUpdateStatus:       status,
+				LastUpdateStatusReason: aws.String("image not found"),
+			},
+		}
+	}
+	gomock.InOrder(
+		l.EXPECT().GetFunction(gomock.Any(), gomock.Any()).Return(running("aaaa", lambdatypes.LastUpdateStatusSuccessful), nil),
+		l.EXPECT().GetFunction(gomock.Any(), gomock.Any()).Return(running("bbbb", lambdatypes.LastUpdateStatusSuccessful), nil),
+		l.EXPECT().GetFunction(gomock.Any(), gomock.Any()).Return(running("aaaa", lambdatypes.LastUpdateStatusFailed), nil),
+	)
+	if err := verifyDeployedImage(context.Background(), "hello"); err != nil {
+		t.Errorf("verifyDeployedImage() = %v for the pushed image", err)
+	}
+	if err := verifyDeployedImage(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "not the pushed image sha256:aaaa") {
+		t.Errorf("verifyDeployedImage() = %v, want a digest mismatch", err)
+	}
+	if err := verifyDeployedImage(context.Background(), "hello"); err == nil || !strings.Contains(err.Error(), "image not found") {
+		t.Errorf("verifyDeployedImage() = %v, want the failed update's reason", err)
+	}
+}
+
+func TestDryRunPlanResolvesIdleFunction(t *testing.T) {
+	fake := useFake(t)
+	e, l := useClients(t)
+	config.Deploy.Strategy = "bluegreen"
+	config.ECR.RepositoryName = "repo"
+	api.sts.(*MockstsAPI).EXPECT().GetCallerIdentity(gomock.Any(), gomock.Any()).
+		Return(&sts.GetCallerIdentityOutput{Account: aws.String("123")}, nil)
+	e.EXPECT().DescribeRepositories(gomock.Any(), gomock.Any()).Return(&ecr.DescribeRepositoriesOutput{
+		Repositories: []ecrtypes.Repository{{RepositoryUri: aws.String("123.dkr.ecr.us-east-1.amazonaws.com/repo")}},
+	}, nil)
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionConfigurationOutput{}, nil)
+	fake.On([]string{"aws", "lambda", "list-tags"}, hostexec.Response{Output: []byte(`{"Tags":{"lambda-template:live":"blue"}}`)})
+	fake.On([]string{"aws", "lambda", "get-function-configuration"}, hostexec.Response{Output: []byte(`{}`)})
+	t.Cleanup(func() { repositoryURI = "" })
+
+	plan, err := dryRunPlan(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	plan.PrintChanges(&buf)
+	if strings.Contains(buf.String(), "lambda:CreateFunction") {
+		t.Errorf("the existing idle function would be created again:\n%s", buf.String())
+	}
+	for _, want := range []string{
+		"lambda:UpdateFunctionCode: point the function at the new image (on hello-green)",
+		"image=123.dkr.ecr.us-east-1.amazonaws.com/repo:latest",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("dry run is missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDryRunPlanNeedsTheFunction(t *testing.T) {
+	useFake(t)
+	e, l := useClients(t)
+	api.sts.(*MockstsAPI).EXPECT().GetCallerIdentity(gomock.Any(), gomock.Any()).
+		Return(&sts.GetCallerIdentityOutput{Account: aws.String("123")}, nil)
+	e.EXPECT().DescribeRepositories(gomock.Any(), gomock.Any()).Return(&ecr.DescribeRepositoriesOutput{
+		Repositories: []ecrtypes.Repository{{RepositoryUri: aws.String("123.dkr.ecr.us-east-1.amazonaws.com/repo")}},
+	}, nil)
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceNotFoundException{})
+	t.Cleanup(func() { repositoryURI = "" })
+
+	if _, err := dryRunPlan(context.Background(), false); err == nil || !strings.Contains(err.Error(), "run setup first") {
+		t.Errorf("dryRunPlan() = %v, want an error pointing at setup", err)
+	}
+}
+
+func TestPackageRoundTrip(t *testing.T) {
+	fake := useFake(t)
+	config.ECR.RepositoryName = "repo"
+	fake.On([]string{"docker", "save"}, hostexec.Response{Output: []byte("image layers")})
+
+	src := t.TempDir()
+	previousPath := appconfig.Path
+	appconfig.Path = filepath.Join(src, "config.yaml")
+	t.Cleanup(func() { appconfig.Path = previousPath })
+	os.WriteFile(appconfig.Path, []byte("lambda:\n  function_name: hello\n"), 0o644)
+	config.Docker.Dockerfile = filepath.Join(src, "Dockerfile")
+	os.WriteFile(config.Docker.Dockerfile, []byte("FROM scratch\n"), 0o644)
+
+	path := filepath.Join(t.TempDir(), "out.tar")
+	if err := writePackage(io.Discard, path); err != nil {
+		t.Fatal(err)
+	}
+	dir, manifest, err := openPackage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if manifest.Function != "hello" || manifest.Image != "repo/hello:latest" || manifest.Entry != "" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if image, _ := os.ReadFile(filepath.Join(dir, imageFile)); string(image) != "image layers" {
+		t.Errorf("image.tar = %q, want the docker save output", image)
+	}
+
+	if err := loadPackageImage(io.Discard, dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Commands(); got[0] != "docker save repo/hello:latest" || got[len(got)-1] != "docker load -i "+filepath.Join(dir, imageFile) {
+		t.Errorf("commands = %q", got)
+	}
+
+	// A package whose image was swapped is refused
+	os.WriteFile(filepath.Join(dir, imageFile), []byte("other layers"), 0o644)
+	if err := archiveFiles(path, dir, append(packageFiles, checksumsFile)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := openPackage(path); err == nil || !strings.Contains(err.Error(), "image.tar does not match") {
+		t.Errorf("openPackage() of a modified package = %v, want a checksum error", err)
+	}
+}
+
+func TestPackagePathPerFunction(t *testing.T) {
+	useFake(t)
+	if got := packagePath("out.tar"); got != "out.tar" {
+		t.Errorf("packagePath() = %s, want out.tar for a single function", got)
+	}
+	config.Functions = []appconfig.FunctionConfig{{Name: "hello"}, {Name: "worker"}}
+	if got := packagePath("dist/out.tar"); got != "dist/out-hello.tar" {
+		t.Errorf("packagePath() = %s, want dist/out-hello.tar", got)
+	}
+}
+
+func TestUploadAssets(t *testing.T) {
+	fake := useFake(t)
+	config.CDN.Enabled = true
+	config.CDN.Assets.Bucket = "hello-assets"
+	config.CDN.Assets.Path = "/assets/*"
+	fake.On([]string{"aws", "cloudfront", "list-distributions"}, hostexec.Response{Output: []byte("E1DIST\n")})
+
+	if err := checkAssetsDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadAssets(io.Discard, "dist"); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.Commands()
+	if len(got) != 3 || !strings.HasPrefix(got[0], "aws s3 sync dist s3://hello-assets/assets/ ") || strings.Contains(got[0], "--delete") {
+		t.Errorf("commands = %q, want a sync of dist that keeps old assets", got)
+	}
+	if want := "aws cloudfront create-invalidation --distribution-id E1DIST --paths /assets/*"; len(got) != 3 || !strings.HasPrefix(got[2], want) {
+		t.Errorf("commands = %q, want %s", got, want)
+	}
+
+	config.CDN.Assets.Bucket = ""
+	if err := checkAssetsDir(t.TempDir()); err == nil {
+		t.Error("checkAssetsDir() accepted -assets without cdn.assets.bucket")
+	}
+}
+
+func TestDeploySummaryRecordsFailedStep(t *testing.T) {
+	useFake(t)
+	config.Deploy.Alias = "live"
+	t.Cleanup(func() { resetState(); summaries = nil })
+	pushedDigest = "sha256:abc"
+	repositoryURI = "123456789012.dkr.ecr.us-east-1.amazonaws.com/hello"
+	publishedVersions = map[string]string{"live": "7"}
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	run := pipeline.Start("deploy", pipeline.Config{}, nil)
+	run.OnEnd = func(err error) { writeSummary(path, run, err) }
+	run.Step("build", func(ctx context.Context) error { return nil })
+	run.Skip("image-diff", "not asked for")
+	run.Step("wait", func(ctx context.Context) error { return errors.New("timed out") })
+	run.End(errors.New("Error waiting for Lambda function: timed out"))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []deploySummary
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Outcome != "failed" || got.ExitCode != 1 || got.Error != "Error waiting for Lambda function: timed out" {
+		t.Errorf("outcome %q, exit code %d, error %q", got.Outcome, got.ExitCode, got.Error)
+	}
+	if got.ImageURI != repositoryURI+"@sha256:abc" || got.Alias != "live" || got.Version != "7" {
+		t.Errorf("image %q, alias %q, version %q", got.ImageURI, got.Alias, got.Version)
+	}
+	var steps []string
+	for _, step := range got.Steps {
+		steps = append(steps, step.Name+":"+step.Outcome)
+	}
+	if strings.Join(steps, " ") != "build:succeeded image-diff:skipped wait:failed" {
+		t.Errorf("steps %s", strings.Join(steps, " "))
+	}
+	links := map[string]string{}
+	for _, link := range got.Links {
+		links[link.Name] = link.URL
+	}
+	if !strings.HasSuffix(links["Function"], "#/functions/hello/versions/7") || !strings.Contains(links["Image"], "/private/123456789012/hello/_/image/sha256:abc/") {
+		t.Errorf("links %v", got.Links)
+	}
+}
+
+func TestDeploySummaryHasEntryPerFunction(t *testing.T) {
+	useFake(t)
+	t.Cleanup(func() { resetState(); summaries = nil })
+	path := filepath.Join(t.TempDir(), "summary.json")
+	for _, name := range []string{"hello-api", "hello-worker"} {
+		// As Main does for each entry of functions
+		resetState()
+		config.Lambda.FunctionName = name
+		pushedDigest = "sha256:" + name
+		run := pipeline.Start("deploy", pipeline.Config{}, nil)
+		run.OnEnd = func(err error) { writeSummary(path, run, err) }
+		run.End(nil)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []deploySummary
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Function != "hello-api" || entries[1].Function != "hello-worker" ||
+		entries[1].ImageDigest != "sha256:hello-worker" {
+		t.Errorf("entries %+v, want one for each function with its own digest", entries)
+	}
+}
+
+func TestApplyReservedConcurrency(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	reserved := 0
+	config.Lambda.ReservedConcurrency = &reserved
+	function := &lambda.GetFunctionOutput{
+		Configuration: &lambdatypes.FunctionConfiguration{FunctionArn: aws.String("arn:aws:lambda:us-east-1:123:function:hello")},
+		Concurrency:   &lambdatypes.Concurrency{ReservedConcurrentExecutions: aws.Int32(10)},
+		Tags:          map[string]string{},
+	}
+	l.EXPECT().GetFunction(gomock.Any(), gomock.Any()).Return(function, nil).AnyTimes()
+	l.EXPECT().GetAccountSettings(gomock.Any(), gomock.Any()).Return(&lambda.GetAccountSettingsOutput{
+		AccountLimit: &lambdatypes.AccountLimit{ConcurrentExecutions: 1000, UnreservedConcurrentExecutions: aws.Int32(400)},
+	}, nil).AnyTimes()
+
+	// 0 is a value to apply, pausing the function
+	l.EXPECT().PutFunctionConcurrency(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.PutFunctionConcurrencyInput, _ ...func(*lambda.Options)) (*lambda.PutFunctionConcurrencyOutput, error) {
+		if aws.ToInt32(input.ReservedConcurrentExecutions) != 0 {
+			t.Errorf("ReservedConcurrentExecutions = %d, want 0", aws.ToInt32(input.ReservedConcurrentExecutions))
+		}
+		return &lambda.PutFunctionConcurrencyOutput{}, nil
+	})
+	if err := applyReservedConcurrency(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged, so nothing is put
+	reserved = 10
+	if err := applyReservedConcurrency(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A throttled function keeps 0 and the tag takes the new value for throttle off
+	reserved = 50
+	function.Tags[appconfig.ThrottledTag] = "10"
+	l.EXPECT().TagResource(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.TagResourceInput, _ ...func(*lambda.Options)) (*lambda.TagResourceOutput, error) {
+		if input.Tags[appconfig.ThrottledTag] != "50" {
+			t.Errorf("tags %v, want %s=50", input.Tags, appconfig.ThrottledTag)
+		}
+		return &lambda.TagResourceOutput{}, nil
+	})
+	if err := applyReservedConcurrency(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnreservedWarning(t *testing.T) {
+	useFake(t)
+	_, l := useClients(t)
+	l.EXPECT().GetAccountSettings(gomock.Any(), gomock.Any()).Return(&lambda.GetAccountSettingsOutput{
+		AccountLimit: &lambdatypes.AccountLimit{ConcurrentExecutions: 1000, UnreservedConcurrentExecutions: aws.Int32(400)},
+	}, nil).AnyTimes()
+
+	for _, test := range []struct {
+		current, want, threshold int
+		warns                    bool
+	}{
+		{-1, 100, 0, false},
+		{-1, 250, 0, true},
+		// Raising an existing reservation only takes the difference
+		{100, 250, 0, false},
+		{-1, 100, 350, true},
+	} {
+		config.Deploy.MinUnreservedConcurrency = test.threshold
+		warning := concurrency.UnreservedWarning(context.Background(), api.lambda, &config, "hello", test.current, test.want)
+		if (warning != "") != test.warns {
+			t.Errorf("from %d to %d with threshold %d: warning %q, want one: %v", test.current, test.want, test.threshold, warning, test.warns)
+		}
+	}
+}
+
+func TestPushDockerImageReportsDigest(t *testing.T) {
+	fake := useFake(t)
+	repositoryURI = "123456789012.dkr.ecr.us-east-1.amazonaws.com/repo"
+	const digest = "sha256:4f2b0c1d3e5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c"
+	fake.On([]string{"docker", "push"}, hostexec.Response{Output: []byte("5f70bf18a086: Pushed\nlatest: digest: " + digest + " size: 1573\n")})
+
+	got, err := pushDockerImage(io.Discard)
+	if err != nil || got != digest {
+		t.Errorf("pushDockerImage() = %q, %v, want %s", got, err, digest)
+	}
+
+	fake.On([]string{"docker", "push"}, hostexec.Response{})
+	if got, err := pushDockerImage(io.Discard); err != nil || got != "" {
+		t.Errorf("pushDockerImage() without a digest line = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,513 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api.go
+//
+// Generated by this command:
+//
+//	mockgen -source=api.go -destination=mock_api_test.go -package=deploy
+//
+
+// Package deploy is a generated GoMock package.
+package deploy
+
+import (
+	context "context"
+	reflect "reflect"
+
+	ecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	iam "github.com/aws/aws-sdk-go-v2/service/iam"
+	lambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	sts "github.com/aws/aws-sdk-go-v2/service/sts"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockiamAPI is a mock of iamAPI interface.
+type MockiamAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockiamAPIMockRecorder
+}
+
+// MockiamAPIMockRecorder is the mock recorder for MockiamAPI.
+type MockiamAPIMockRecorder struct {
+	mock *MockiamAPI
+}
+
+// NewMockiamAPI creates a new mock instance.
+func NewMockiamAPI(ctrl *gomock.Controller) *MockiamAPI {
+	mock := &MockiamAPI{ctrl: ctrl}
+	mock.recorder = &MockiamAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockiamAPI) EXPECT() *MockiamAPIMockRecorder {
+	return m.recorder
+}
+
+// AttachRolePolicy mocks base method.
+func (m *MockiamAPI) AttachRolePolicy(ctx context.Context, params *iam.AttachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AttachRolePolicy", varargs...)
+	ret0, _ := ret[0].(*iam.AttachRolePolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachRolePolicy indicates an expected call of AttachRolePolicy.
+func (mr *MockiamAPIMockRecorder) AttachRolePolicy(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachRolePolicy", reflect.TypeOf((*MockiamAPI)(nil).AttachRolePolicy), varargs...)
+}
+
+// GetUser mocks base method.
+func (m *MockiamAPI) GetUser(ctx context.Context, params *iam.GetUserInput, optFns ...func(*iam.Options)) (*iam.GetUserOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetUser", varargs...)
+	ret0, _ := ret[0].(*iam.GetUserOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockiamAPIMockRecorder) GetUser(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockiamAPI)(nil).GetUser), varargs...)
+}
+
+// MockecrAPI is a mock of ecrAPI interface.
+type MockecrAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockecrAPIMockRecorder
+}
+
+// MockecrAPIMockRecorder is the mock recorder for MockecrAPI.
+type MockecrAPIMockRecorder struct {
+	mock *MockecrAPI
+}
+
+// NewMockecrAPI creates a new mock instance.
+func NewMockecrAPI(ctrl *gomock.Controller) *MockecrAPI {
+	mock := &MockecrAPI{ctrl: ctrl}
+	mock.recorder = &MockecrAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockecrAPI) EXPECT() *MockecrAPIMockRecorder {
+	return m.recorder
+}
+
+// DescribeImages mocks base method.
+func (m *MockecrAPI) DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeImages", varargs...)
+	ret0, _ := ret[0].(*ecr.DescribeImagesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeImages indicates an expected call of DescribeImages.
+func (mr *MockecrAPIMockRecorder) DescribeImages(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeImages", reflect.TypeOf((*MockecrAPI)(nil).DescribeImages), varargs...)
+}
+
+// DescribeRepositories mocks base method.
+func (m *MockecrAPI) DescribeRepositories(ctx context.Context, params *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeRepositories", varargs...)
+	ret0, _ := ret[0].(*ecr.DescribeRepositoriesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeRepositories indicates an expected call of DescribeRepositories.
+func (mr *MockecrAPIMockRecorder) DescribeRepositories(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeRepositories", reflect.TypeOf((*MockecrAPI)(nil).DescribeRepositories), varargs...)
+}
+
+// GetAuthorizationToken mocks base method.
+func (m *MockecrAPI) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAuthorizationToken", varargs...)
+	ret0, _ := ret[0].(*ecr.GetAuthorizationTokenOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizationToken indicates an expected call of GetAuthorizationToken.
+func (mr *MockecrAPIMockRecorder) GetAuthorizationToken(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationToken", reflect.TypeOf((*MockecrAPI)(nil).GetAuthorizationToken), varargs...)
+}
+
+// MockstsAPI is a mock of stsAPI interface.
+type MockstsAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockstsAPIMockRecorder
+}
+
+// MockstsAPIMockRecorder is the mock recorder for MockstsAPI.
+type MockstsAPIMockRecorder struct {
+	mock *MockstsAPI
+}
+
+// NewMockstsAPI creates a new mock instance.
+func NewMockstsAPI(ctrl *gomock.Controller) *MockstsAPI {
+	mock := &MockstsAPI{ctrl: ctrl}
+	mock.recorder = &MockstsAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockstsAPI) EXPECT() *MockstsAPIMockRecorder {
+	return m.recorder
+}
+
+// GetCallerIdentity mocks base method.
+func (m *MockstsAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetCallerIdentity", varargs...)
+	ret0, _ := ret[0].(*sts.GetCallerIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCallerIdentity indicates an expected call of GetCallerIdentity.
+func (mr *MockstsAPIMockRecorder) GetCallerIdentity(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCallerIdentity", reflect.TypeOf((*MockstsAPI)(nil).GetCallerIdentity), varargs...)
+}
+
+// MocklambdaAPI is a mock of lambdaAPI interface.
+type MocklambdaAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MocklambdaAPIMockRecorder
+}
+
+// MocklambdaAPIMockRecorder is the mock recorder for MocklambdaAPI.
+type MocklambdaAPIMockRecorder struct {
+	mock *MocklambdaAPI
+}
+
+// NewMocklambdaAPI creates a new mock instance.
+func NewMocklambdaAPI(ctrl *gomock.Controller) *MocklambdaAPI {
+	mock := &MocklambdaAPI{ctrl: ctrl}
+	mock.recorder = &MocklambdaAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocklambdaAPI) EXPECT() *MocklambdaAPIMockRecorder {
+	return m.recorder
+}
+
+// CreateAlias mocks base method.
+func (m *MocklambdaAPI) CreateAlias(ctx context.Context, params *lambda.CreateAliasInput, optFns ...func(*lambda.Options)) (*lambda.CreateAliasOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateAlias", varargs...)
+	ret0, _ := ret[0].(*lambda.CreateAliasOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlias indicates an expected call of CreateAlias.
+func (mr *MocklambdaAPIMockRecorder) CreateAlias(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlias", reflect.TypeOf((*MocklambdaAPI)(nil).CreateAlias), varargs...)
+}
+
+// DeleteProvisionedConcurrencyConfig mocks base method.
+func (m *MocklambdaAPI) DeleteProvisionedConcurrencyConfig(ctx context.Context, params *lambda.DeleteProvisionedConcurrencyConfigInput, optFns ...func(*lambda.Options)) (*lambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteProvisionedConcurrencyConfig", varargs...)
+	ret0, _ := ret[0].(*lambda.DeleteProvisionedConcurrencyConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteProvisionedConcurrencyConfig indicates an expected call of DeleteProvisionedConcurrencyConfig.
+func (mr *MocklambdaAPIMockRecorder) DeleteProvisionedConcurrencyConfig(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteProvisionedConcurrencyConfig", reflect.TypeOf((*MocklambdaAPI)(nil).DeleteProvisionedConcurrencyConfig), varargs...)
+}
+
+// GetAccountSettings mocks base method.
+func (m *MocklambdaAPI) GetAccountSettings(ctx context.Context, params *lambda.GetAccountSettingsInput, optFns ...func(*lambda.Options)) (*lambda.GetAccountSettingsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAccountSettings", varargs...)
+	ret0, _ := ret[0].(*lambda.GetAccountSettingsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountSettings indicates an expected call of GetAccountSettings.
+func (mr *MocklambdaAPIMockRecorder) GetAccountSettings(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountSettings", reflect.TypeOf((*MocklambdaAPI)(nil).GetAccountSettings), varargs...)
+}
+
+// GetAlias mocks base method.
+func (m *MocklambdaAPI) GetAlias(ctx context.Context, params *lambda.GetAliasInput, optFns ...func(*lambda.Options)) (*lambda.GetAliasOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAlias", varargs...)
+	ret0, _ := ret[0].(*lambda.GetAliasOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlias indicates an expected call of GetAlias.
+func (mr *MocklambdaAPIMockRecorder) GetAlias(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlias", reflect.TypeOf((*MocklambdaAPI)(nil).GetAlias), varargs...)
+}
+
+// GetFunction mocks base method.
+func (m *MocklambdaAPI) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFunction", varargs...)
+	ret0, _ := ret[0].(*lambda.GetFunctionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFunction indicates an expected call of GetFunction.
+func (mr *MocklambdaAPIMockRecorder) GetFunction(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunction", reflect.TypeOf((*MocklambdaAPI)(nil).GetFunction), varargs...)
+}
+
+// GetFunctionConfiguration mocks base method.
+func (m *MocklambdaAPI) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFunctionConfiguration", varargs...)
+	ret0, _ := ret[0].(*lambda.GetFunctionConfigurationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFunctionConfiguration indicates an expected call of GetFunctionConfiguration.
+func (mr *MocklambdaAPIMockRecorder) GetFunctionConfiguration(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunctionConfiguration", reflect.TypeOf((*MocklambdaAPI)(nil).GetFunctionConfiguration), varargs...)
+}
+
+// GetFunctionUrlConfig mocks base method.
+func (m *MocklambdaAPI) GetFunctionUrlConfig(ctx context.Context, params *lambda.GetFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionUrlConfigOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFunctionUrlConfig", varargs...)
+	ret0, _ := ret[0].(*lambda.GetFunctionUrlConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFunctionUrlConfig indicates an expected call of GetFunctionUrlConfig.
+func (mr *MocklambdaAPIMockRecorder) GetFunctionUrlConfig(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunctionUrlConfig", reflect.TypeOf((*MocklambdaAPI)(nil).GetFunctionUrlConfig), varargs...)
+}
+
+// PublishVersion mocks base method.
+func (m *MocklambdaAPI) PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PublishVersion", varargs...)
+	ret0, _ := ret[0].(*lambda.PublishVersionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PublishVersion indicates an expected call of PublishVersion.
+func (mr *MocklambdaAPIMockRecorder) PublishVersion(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishVersion", reflect.TypeOf((*MocklambdaAPI)(nil).PublishVersion), varargs...)
+}
+
+// PutFunctionConcurrency mocks base method.
+func (m *MocklambdaAPI) PutFunctionConcurrency(ctx context.Context, params *lambda.PutFunctionConcurrencyInput, optFns ...func(*lambda.Options)) (*lambda.PutFunctionConcurrencyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PutFunctionConcurrency", varargs...)
+	ret0, _ := ret[0].(*lambda.PutFunctionConcurrencyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutFunctionConcurrency indicates an expected call of PutFunctionConcurrency.
+func (mr *MocklambdaAPIMockRecorder) PutFunctionConcurrency(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutFunctionConcurrency", reflect.TypeOf((*MocklambdaAPI)(nil).PutFunctionConcurrency), varargs...)
+}
+
+// PutProvisionedConcurrencyConfig mocks base method.
+func (m *MocklambdaAPI) PutProvisionedConcurrencyConfig(ctx context.Context, params *lambda.PutProvisionedConcurrencyConfigInput, optFns ...func(*lambda.Options)) (*lambda.PutProvisionedConcurrencyConfigOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PutProvisionedConcurrencyConfig", varargs...)
+	ret0, _ := ret[0].(*lambda.PutProvisionedConcurrencyConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutProvisionedConcurrencyConfig indicates an expected call of PutProvisionedConcurrencyConfig.
+func (mr *MocklambdaAPIMockRecorder) PutProvisionedConcurrencyConfig(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutProvisionedConcurrencyConfig", reflect.TypeOf((*MocklambdaAPI)(nil).PutProvisionedConcurrencyConfig), varargs...)
+}
+
+// TagResource mocks base method.
+func (m *MocklambdaAPI) TagResource(ctx context.Context, params *lambda.TagResourceInput, optFns ...func(*lambda.Options)) (*lambda.TagResourceOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "TagResource", varargs...)
+	ret0, _ := ret[0].(*lambda.TagResourceOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TagResource indicates an expected call of TagResource.
+func (mr *MocklambdaAPIMockRecorder) TagResource(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagResource", reflect.TypeOf((*MocklambdaAPI)(nil).TagResource), varargs...)
+}
+
+// UpdateAlias mocks base method.
+func (m *MocklambdaAPI) UpdateAlias(ctx context.Context, params *lambda.UpdateAliasInput, optFns ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateAlias", varargs...)
+	ret0, _ := ret[0].(*lambda.UpdateAliasOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAlias indicates an expected call of UpdateAlias.
+func (mr *MocklambdaAPIMockRecorder) UpdateAlias(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAlias", reflect.TypeOf((*MocklambdaAPI)(nil).UpdateAlias), varargs...)
+}
+
+// UpdateFunctionCode mocks base method.
+func (m *MocklambdaAPI) UpdateFunctionCode(ctx context.Context, params *lambda.UpdateFunctionCodeInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateFunctionCode", varargs...)
+	ret0, _ := ret[0].(*lambda.UpdateFunctionCodeOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFunctionCode indicates an expected call of UpdateFunctionCode.
+func (mr *MocklambdaAPIMockRecorder) UpdateFunctionCode(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFunctionCode", reflect.TypeOf((*MocklambdaAPI)(nil).UpdateFunctionCode), varargs...)
+}
+
+// UpdateFunctionConfiguration mocks base method.
+func (m *MocklambdaAPI) UpdateFunctionConfiguration(ctx context.Context, params *lambda.UpdateFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateFunctionConfiguration", varargs...)
+	ret0, _ := ret[0].(*lambda.UpdateFunctionConfigurationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFunctionConfiguration indicates an expected call of UpdateFunctionConfiguration.
+func (mr *MocklambdaAPIMockRecorder) UpdateFunctionConfiguration(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFunctionConfiguration", reflect.TypeOf((*MocklambdaAPI)(nil).UpdateFunctionConfiguration), varargs...)
+}
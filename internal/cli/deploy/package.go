@@ -0,0 +1,326 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+// A package carries a deploy into a network that can reach AWS but not the
+// build's sources and base images: -package builds the image on a connected
+// machine and writes it with the configuration it was built from, and
+// -from-package loads, pushes and deploys it from inside.
+
+const packageFormat = 1
+
+// Files of a package. SHA256SUMS, in the format of sha256sum, covers the
+// others, so a package can also be checked by hand with sha256sum -c.
+const (
+	manifestFile   = "manifest.json"
+	configFile     = "config.yaml"
+	dockerfileFile = "Dockerfile"
+	imageFile      = "image.tar"
+	checksumsFile  = "SHA256SUMS"
+)
+
+var packageFiles = []string{manifestFile, configFile, dockerfileFile, imageFile}
+
+type packageManifest struct {
+	Format   int    `json:"format"`
+	Function string `json:"function"`
+	// Entry is the functions entry the image was built for, empty in
+	// projects without a functions list
+	Entry string `json:"entry,omitempty"`
+	// Image is the local tag the image is saved and loaded under
+	Image   string    `json:"image"`
+	Commit  string    `json:"commit,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// tempDirs are removed when the deploy exits, including through fatalf and
+// run.Fatalf, which skip deferred calls.
+var tempDirs []string
+
+func removeTempDirs() {
+	for _, dir := range tempDirs {
+		os.RemoveAll(dir)
+	}
+	tempDirs = nil
+}
+
+// fatalf is log.Fatalf for a deploy that may have tempDirs.
+func fatalf(format string, args ...interface{}) {
+	removeTempDirs()
+	log.Fatalf(format, args...)
+}
+
+// localImage is the tag buildDockerImage gives the image.
+func localImage() string {
+	return fmt.Sprintf("%s/%s:latest", config.ECR.RepositoryName, config.Lambda.FunctionName)
+}
+
+// packagePath gives every function of a functions list its own package,
+// e.g. out-hello.tar for -package out.tar.
+func packagePath(path string) string {
+	if len(config.Functions) < 2 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + config.Lambda.FunctionName + ext
+}
+
+// writePackage saves the built image with the configuration file, the
+// Dockerfile the rules check reads, a manifest and their checksums to path.
+func writePackage(w io.Writer, path string) error {
+	dir, err := os.MkdirTemp("", "lambda-template-package-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	image, err := os.Create(filepath.Join(dir, imageFile))
+	if err != nil {
+		return err
+	}
+	save := exec.Command("docker", "save", localImage())
+	save.Stdout = image
+	save.Stderr = w
+	err = hostexec.Run(save)
+	image.Close()
+	if err != nil {
+		return fmt.Errorf("failed to save Docker image: %v", err)
+	}
+	if err := copyFile(appconfig.Path, filepath.Join(dir, configFile)); err != nil {
+		return err
+	}
+	if err := copyFile(config.DockerfilePath(), filepath.Join(dir, dockerfileFile)); err != nil {
+		return err
+	}
+
+	manifest := packageManifest{
+		Format:   packageFormat,
+		Function: config.Lambda.FunctionName,
+		Image:    localImage(),
+		Created:  time.Now().UTC(),
+	}
+	if len(config.Functions) > 0 {
+		manifest.Entry = config.Lambda.FunctionName
+	}
+	if sha, err := hostexec.Output(exec.Command("git", "rev-parse", "HEAD")); err == nil {
+		manifest.Commit = strings.TrimSpace(string(sha))
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0o644); err != nil {
+		return err
+	}
+
+	var sums strings.Builder
+	for _, name := range packageFiles {
+		sum, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&sums, "%s  %s\n", sum, name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, checksumsFile), []byte(sums.String()), 0o644); err != nil {
+		return err
+	}
+
+	// Written next to the target and renamed, so a failed write leaves no
+	// package that looks complete
+	tmp := path + ".tmp"
+	if err := archiveFiles(tmp, dir, append(packageFiles, checksumsFile)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Wrote %s; deploy it with `lambda-template deploy -from-package %s`\n", path, filepath.Base(path))
+	return nil
+}
+
+// openPackage extracts the package at path into a new directory and checks
+// it against its checksums. The caller removes the directory.
+func openPackage(path string) (string, packageManifest, error) {
+	var manifest packageManifest
+	dir, err := os.MkdirTemp("", "lambda-template-package-")
+	if err != nil {
+		return "", manifest, err
+	}
+	if err := extractFiles(path, dir); err != nil {
+		os.RemoveAll(dir)
+		return "", manifest, fmt.Errorf("error reading package %s: %v", path, err)
+	}
+	if err := verifyChecksums(dir); err != nil {
+		os.RemoveAll(dir)
+		return "", manifest, fmt.Errorf("package %s: %v", path, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", manifest, fmt.Errorf("package %s: invalid manifest: %v", path, err)
+	}
+	if manifest.Format != packageFormat {
+		os.RemoveAll(dir)
+		return "", manifest, fmt.Errorf("package %s has format %d; this lambda-template reads format %d", path, manifest.Format, packageFormat)
+	}
+	return dir, manifest, nil
+}
+
+// loadPackageImage loads the image of an opened package into Docker, under
+// the tag the push step expects.
+func loadPackageImage(w io.Writer, dir string) error {
+	cmd := exec.Command("docker", "load", "-i", filepath.Join(dir, imageFile))
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := hostexec.Run(cmd); err != nil {
+		return fmt.Errorf("failed to load Docker image: %v", err)
+	}
+	return nil
+}
+
+func verifyChecksums(dir string) error {
+	sums, err := os.Open(filepath.Join(dir, checksumsFile))
+	if err != nil {
+		return fmt.Errorf("no %s", checksumsFile)
+	}
+	defer sums.Close()
+
+	want := map[string]string{}
+	scanner := bufio.NewScanner(sums)
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if ok {
+			want[name] = sum
+		}
+	}
+	for _, name := range packageFiles {
+		if want[name] == "" {
+			return fmt.Errorf("%s has no checksum for %s", checksumsFile, name)
+		}
+		sum, err := fileChecksum(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("missing %s", name)
+		}
+		if sum != want[name] {
+			return fmt.Errorf("%s does not match its checksum; the package is damaged or was modified", name)
+		}
+	}
+	return nil
+}
+
+func archiveFiles(path, dir string, names []string) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(out)
+	for _, name := range names {
+		if err := addFile(tw, filepath.Join(dir, name), name); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractFiles writes the package's known files to dir and ignores anything
+// else, so that entry names can't point outside it.
+func extractFiles(path, dir string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	known := map[string]bool{checksumsFile: true}
+	for _, name := range packageFiles {
+		known[name] = true
+	}
+	tr := tar.NewReader(in)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || !known[header.Name] {
+			continue
+		}
+		out, err := os.Create(filepath.Join(dir, header.Name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0o644)
+}
@@ -0,0 +1,104 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/history"
+	"example-lambda-go/internal/hostexec"
+)
+
+// Tags deploy gives the function: the commit it runs and the changelog of
+// the deploy that put it there.
+const (
+	commitTag    = "lambda-template:commit"
+	changelogTag = "lambda-template:changelog"
+)
+
+// release is what the deploy puts out, for the function's description and
+// tags and the deploy history.
+var release history.Record
+
+// prepareRelease fills in release with the commit being deployed, a
+// package's or the checkout's, and the commits since the commit of the
+// function's previous deploy: the last in the history, or, when the history
+// has none (a new checkout, another machine), the commit tag of the live
+// function. Outside a git checkout only a package's commit is known; without
+// a commit there is no changelog.
+func prepareRelease(ctx context.Context, commit string) {
+	release = history.Record{Function: config.Lambda.FunctionName, Commit: commit}
+	if release.Commit == "" {
+		sha, err := hostexec.Output(exec.Command("git", "rev-parse", "HEAD"))
+		if err != nil {
+			return
+		}
+		release.Commit = strings.TrimSpace(string(sha))
+	}
+	records, err := history.Read(config.Audit.History, config.Lambda.FunctionName)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	} else if len(records) > 0 {
+		release.Previous = records[0].Commit
+	}
+	if release.Previous == "" {
+		// A function that doesn't exist yet has no previous deploy
+		function, err := api.lambda.GetFunction(ctx, &lambda.GetFunctionInput{
+			FunctionName: aws.String(config.Lambda.FunctionName),
+		})
+		if err == nil {
+			release.Previous = function.Tags[commitTag]
+		}
+	}
+	if release.Changes, release.Omitted, err = history.Collect(release.Previous, release.Commit); err != nil {
+		log.Printf("Warning: no changelog: %v", err)
+	}
+}
+
+// releaseDescription is the function's description for the release: the
+// commit and as much of the changelog as Lambda's 256 characters hold.
+func releaseDescription() string {
+	if release.Commit == "" {
+		return ""
+	}
+	description := "Deployed " + history.ShortCommit(release.Commit)
+	if summary := release.Summary(256 - len(description) - 2); summary != "" {
+		description += ": " + summary
+	}
+	return description
+}
+
+// releaseTags are commitTag and changelogTag for the release, if it has a
+// commit.
+func releaseTags() map[string]string {
+	if release.Commit == "" {
+		return nil
+	}
+	return map[string]string{
+		commitTag:    release.Commit,
+		changelogTag: history.TagValue(release.Summary(256)),
+	}
+}
+
+// recordRelease adds the deploy to the history. The deploy has happened by
+// then, so a failure is only a warning.
+func recordRelease(ctx context.Context) {
+	release.Image = pushedDigest
+	release.Actor = "unknown"
+	if identity, err := api.sts.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
+		release.Actor = aws.ToString(identity.Arn)
+	}
+	if err := history.Append(config.Audit.History, release); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if release.Commit != "" {
+		fmt.Printf("Recorded the deploy of %s with %d commit(s); `lambda-template history` lists deploys\n", history.ShortCommit(release.Commit), len(release.Changes)+release.Omitted)
+	}
+}
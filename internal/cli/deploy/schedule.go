@@ -0,0 +1,158 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// syncSchedule brings the function's schedule rule in line with
+// lambda.schedule: it is created when the schedule was added since setup,
+// given the new expression when it changed, and deleted when the schedule
+// was removed. The targets of an existing rule are left alone, since a
+// blue/green deploy moves them between the functions.
+func syncSchedule(awsAccountID string) error {
+	ruleName := config.ScheduleRuleName()
+	current, exists, err := scheduleExpression(ruleName)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case config.Lambda.Schedule == "" && exists:
+		return deleteScheduleRule(ruleName)
+	case config.Lambda.Schedule == "" || config.Lambda.Schedule == current:
+		return nil
+	case exists:
+		if _, err := putRule(ruleName, false); err != nil {
+			return err
+		}
+		fmt.Printf("Schedule changed from %s to %s\n", current, config.Lambda.Schedule)
+		return nil
+	}
+
+	ruleARN, err := putRule(ruleName, true)
+	if err != nil {
+		return err
+	}
+	target := config.TriggerTarget()
+	if config.Deploy.Strategy == "bluegreen" {
+		if target, _, err = blueGreenFunctions(awsAccountID); err != nil {
+			return err
+		}
+	}
+	permissionCmd := exec.Command("aws", "lambda", "add-permission",
+		"--function-name", target,
+		"--statement-id", ruleName,
+		"--action", "lambda:InvokeFunction",
+		"--principal", "events.amazonaws.com",
+		"--source-arn", ruleARN,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(permissionCmd)
+	if err != nil && !strings.Contains(string(output), "ResourceConflictException") {
+		return fmt.Errorf("failed to allow rule %s to invoke %s: %v\nOutput: %s", ruleName, target, err, output)
+	}
+	// The target ID stays the function's name when blue/green moves it
+	putTargetsCmd := exec.Command("aws", "events", "put-targets",
+		"--rule", ruleName,
+		"--targets", fmt.Sprintf("Id=%s,Arn=%s", config.Lambda.FunctionName, functionARN(target, awsAccountID)),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err = hostexec.CombinedOutput(putTargetsCmd)
+	if err != nil {
+		return fmt.Errorf("failed to point rule %s at %s: %v\nOutput: %s", ruleName, target, err, output)
+	}
+	fmt.Printf("Function scheduled with %s\n", config.Lambda.Schedule)
+	return nil
+}
+
+// scheduleExpression returns the schedule of the rule, and whether it exists.
+func scheduleExpression(ruleName string) (string, bool, error) {
+	describeCmd := exec.Command("aws", "events", "describe-rule",
+		"--name", ruleName,
+		"--query", "ScheduleExpression",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(describeCmd)
+	if err != nil {
+		if strings.Contains(string(output), "ResourceNotFoundException") {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to describe rule %s: %v\nOutput: %s", ruleName, err, output)
+	}
+	return strings.TrimSpace(string(output)), true, nil
+}
+
+// putRule sets the rule's schedule to lambda.schedule and returns its ARN.
+// A new rule is tagged as setup tags what it creates.
+func putRule(ruleName string, create bool) (string, error) {
+	putRuleCmd := exec.Command("aws", "events", "put-rule",
+		"--name", ruleName,
+		"--schedule-expression", config.Lambda.Schedule,
+		"--query", "RuleArn",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if create {
+		tags := config.ResourceTags(time.Now())
+		keys := make([]string, 0, len(tags))
+		for key := range tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		putRuleCmd.Args = append(putRuleCmd.Args, "--tags")
+		for _, key := range keys {
+			putRuleCmd.Args = append(putRuleCmd.Args, fmt.Sprintf("Key=%s,Value=%s", key, tags[key]))
+		}
+	}
+	output, err := hostexec.CombinedOutput(putRuleCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to put rule %s: %v\nOutput: %s", ruleName, err, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// deleteScheduleRule removes the rule's targets, which EventBridge requires
+// before deleting it, then the rule.
+func deleteScheduleRule(ruleName string) error {
+	listTargetsCmd := exec.Command("aws", "events", "list-targets-by-rule",
+		"--rule", ruleName,
+		"--query", "Targets[].Id",
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(listTargetsCmd)
+	if err != nil {
+		return fmt.Errorf("failed to list targets of rule %s: %v", ruleName, err)
+	}
+	var ids []string
+	if err := json.Unmarshal(output, &ids); err != nil {
+		return fmt.Errorf("failed to parse targets of rule %s: %v", ruleName, err)
+	}
+	if len(ids) > 0 {
+		removeCmd := exec.Command("aws", "events", "remove-targets",
+			"--rule", ruleName,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+		removeCmd.Args = append(append(removeCmd.Args, "--ids"), ids...)
+		if output, err := hostexec.CombinedOutput(removeCmd); err != nil {
+			return fmt.Errorf("failed to remove targets of rule %s: %v\nOutput: %s", ruleName, err, output)
+		}
+	}
+	deleteCmd := exec.Command("aws", "events", "delete-rule",
+		"--name", ruleName,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(deleteCmd); err != nil {
+		return fmt.Errorf("failed to delete rule %s: %v\nOutput: %s", ruleName, err, output)
+	}
+	fmt.Printf("Schedule removed from config.yaml; rule %s deleted\n", ruleName)
+	return nil
+}
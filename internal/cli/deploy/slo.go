@@ -0,0 +1,35 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+
+	"example-lambda-go/internal/slo"
+)
+
+// checkErrorBudget refuses the deploy once an objective has used up its error
+// budget; a fix can still go out with -ignore-slo.
+func checkErrorBudget(ctx context.Context) error {
+	awsCfg, err := config.AWSConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	functions := []string{config.Lambda.FunctionName}
+	if config.Deploy.Strategy == "bluegreen" {
+		functions = append(functions, greenFunctionName())
+	}
+	report, err := slo.Evaluate(ctx, cloudwatch.NewFromConfig(awsCfg), config.SLO, functions, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, indicator := range report.Indicators {
+		if indicator.BudgetRemaining <= 0 {
+			return fmt.Errorf("the %s error budget for the last %s is exhausted (use -ignore-slo to override)", indicator.Name, report.Window)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"example-lambda-go/internal/console"
+	"example-lambda-go/internal/pipeline"
+)
+
+// deploySummary is an entry of the file deploy.summary or -summary names,
+// written when the deploy of a function ends whichever way it ends, so CI
+// steps after it read the digest and version instead of scraping the log.
+type deploySummary struct {
+	Function string `json:"function"`
+	Region   string `json:"region"`
+	// Outcome is succeeded or failed, and ExitCode the deploy's exit status
+	Outcome  string `json:"outcome"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	// ImageDigest is empty when the deploy failed before pushing
+	ImageDigest string `json:"image_digest,omitempty"`
+	ImageURI    string `json:"image_uri,omitempty"`
+	// Alias is deploy.alias and Version the version it was pointed to;
+	// Versions holds the version published for every alias
+	Alias           string            `json:"alias,omitempty"`
+	Version         string            `json:"version,omitempty"`
+	Versions        map[string]string `json:"versions,omitempty"`
+	Started         time.Time         `json:"started"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Steps           []summaryStep     `json:"steps"`
+	Links           []console.Link    `json:"links"`
+}
+
+type summaryStep struct {
+	Name            string  `json:"name"`
+	Outcome         string  `json:"outcome"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+	Skipped         string  `json:"skipped,omitempty"`
+}
+
+// newSummary describes the run that ended with err.
+func newSummary(run *pipeline.Pipeline, err error, now time.Time) deploySummary {
+	s := deploySummary{
+		Function:        config.Lambda.FunctionName,
+		Region:          config.AWS.Region,
+		Outcome:         "succeeded",
+		Commit:          release.Commit,
+		ImageDigest:     pushedDigest,
+		Alias:           config.Deploy.Alias,
+		Version:         publishedVersions[config.Deploy.Alias],
+		Started:         run.Started().UTC(),
+		DurationSeconds: seconds(now.Sub(run.Started())),
+		Steps:           []summaryStep{},
+		Links:           consoleLinks(),
+	}
+	if err != nil {
+		s.Outcome, s.ExitCode, s.Error = "failed", 1, err.Error()
+	}
+	if pushedDigest != "" && repositoryURI != "" {
+		s.ImageURI = repositoryURI + "@" + pushedDigest
+	}
+	if len(publishedVersions) > 0 {
+		s.Versions = publishedVersions
+	}
+	for _, step := range run.Steps() {
+		result := summaryStep{Name: step.Name, Outcome: "succeeded", DurationSeconds: seconds(step.Duration)}
+		switch {
+		case step.Skipped != "":
+			result.Outcome, result.Skipped = "skipped", step.Skipped
+		case step.Err != nil:
+			result.Outcome, result.Error = "failed", step.Err.Error()
+		}
+		s.Steps = append(s.Steps, result)
+	}
+	return s
+}
+
+// consoleLinks are the console pages of what the deploy changed: the
+// version deploy.alias points to, the logs and the pushed image.
+func consoleLinks() []console.Link {
+	name := config.Lambda.FunctionName
+	c := console.New(config.Partition(), config.AWS.Region)
+	links := []console.Link{c.Function(name, publishedVersions[config.Deploy.Alias]), c.Logs(name), c.Alarms()}
+	if host, repository, ok := strings.Cut(repositoryURI, "/"); ok && pushedDigest != "" {
+		account, _, _ := strings.Cut(host, ".")
+		links = append(links, c.Image(account, repository, pushedDigest))
+	}
+	return links
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// summaries are the entries of the functions this process deployed so far,
+// one for each entry of functions that runPerFunction deployed before.
+var summaries []deploySummary
+
+// writeSummary adds the summary of the run to those of the functions deployed
+// before it and writes them to path as a JSON array. It is written next to
+// path and renamed, so a CI step never reads half of it. The deploy's outcome
+// does not depend on it, so a failure is only a warning.
+func writeSummary(path string, run *pipeline.Pipeline, err error) {
+	summaries = append(summaries, newSummary(run, err, time.Now()))
+	data, marshalErr := json.MarshalIndent(summaries, "", "  ")
+	if marshalErr != nil {
+		log.Printf("Warning: could not write the deploy summary: %v", marshalErr)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		log.Printf("Warning: could not write the deploy summary: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		log.Printf("Warning: could not write the deploy summary: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package deploy
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// syncSQSTrigger brings the batch settings of the triggers.sqs mapping in
+// line with config.yaml. Creating the mapping is left to setup, which also
+// lets the role read the queue.
+func syncSQSTrigger(awsAccountID string) error {
+	trigger := config.Triggers.SQS
+	mapping, err := findMapping(awsAccountID, config.SQSQueueARN(awsAccountID), "BatchSize, MaximumBatchingWindowInSeconds")
+	if err != nil || mapping == nil {
+		return err
+	}
+	uuid := mapping[0]
+	batchSize, _ := strconv.Atoi(mapping[1])
+	// A mapping created without a window reports None
+	window, _ := strconv.Atoi(mapping[2])
+	if batchSize == trigger.MessagesPerBatch() && window == trigger.BatchWindowSeconds() {
+		return nil
+	}
+
+	if err := updateMapping(uuid,
+		"--batch-size", strconv.Itoa(trigger.MessagesPerBatch()),
+		"--maximum-batching-window-in-seconds", strconv.Itoa(trigger.BatchWindowSeconds())); err != nil {
+		return err
+	}
+	fmt.Printf("SQS trigger batches changed from %d messages and %ds to %d messages and %ds\n",
+		batchSize, window, trigger.MessagesPerBatch(), trigger.BatchWindowSeconds())
+	return nil
+}
+
+// syncDynamoDBTrigger is syncSQSTrigger for triggers.dynamodb. The starting
+// position only applies when the mapping is created, so it is left alone.
+func syncDynamoDBTrigger(awsAccountID string) error {
+	trigger := config.Triggers.DynamoDB
+	mapping, err := findMapping(awsAccountID, trigger.Stream, "BatchSize, ParallelizationFactor")
+	if err != nil || mapping == nil {
+		return err
+	}
+	uuid := mapping[0]
+	batchSize, _ := strconv.Atoi(mapping[1])
+	factor, _ := strconv.Atoi(mapping[2])
+	if batchSize == trigger.RecordsPerBatch() && factor == trigger.BatchesPerShard() {
+		return nil
+	}
+
+	if err := updateMapping(uuid,
+		"--batch-size", strconv.Itoa(trigger.RecordsPerBatch()),
+		"--parallelization-factor", strconv.Itoa(trigger.BatchesPerShard())); err != nil {
+		return err
+	}
+	fmt.Printf("DynamoDB trigger batches changed from %d records, %d per shard, to %d records, %d per shard\n",
+		batchSize, factor, trigger.RecordsPerBatch(), trigger.BatchesPerShard())
+	return nil
+}
+
+// syncKinesisTrigger brings the tumbling window and on-failure destination
+// of the triggers.kinesis mapping in line with config.yaml. A new destination
+// is added to the role's kinesis-trigger policy first, since Lambda checks
+// that the function may send to it.
+func syncKinesisTrigger(awsAccountID string) error {
+	trigger := config.Triggers.Kinesis
+	mapping, err := findMapping(awsAccountID, config.KinesisStreamARN(awsAccountID), "TumblingWindowInSeconds, DestinationConfig.OnFailure.Destination")
+	if err != nil || mapping == nil {
+		return err
+	}
+	uuid := mapping[0]
+	// Unset settings are reported as None
+	window, _ := strconv.Atoi(mapping[1])
+	destination := mapping[2]
+	if destination == "None" {
+		destination = ""
+	}
+	if window == trigger.TumblingWindowSeconds() && destination == trigger.OnFailure {
+		return nil
+	}
+
+	if trigger.OnFailure != "" && destination != trigger.OnFailure {
+		policy, err := config.KinesisTriggerPolicy(awsAccountID)
+		if err != nil {
+			return err
+		}
+		putPolicyCmd := exec.Command("aws", "iam", "put-role-policy",
+			"--role-name", config.Lambda.RoleName,
+			"--policy-name", "kinesis-trigger",
+			"--policy-document", policy,
+			"--profile", config.AWS.Profile)
+		if output, err := hostexec.CombinedOutput(putPolicyCmd); err != nil {
+			return fmt.Errorf("failed to allow the role to send to %s: %v\nOutput: %s", trigger.OnFailure, err, output)
+		}
+	}
+	destinationConfig := `{"OnFailure":{}}`
+	if trigger.OnFailure != "" {
+		destinationConfig = fmt.Sprintf(`{"OnFailure":{"Destination":%q}}`, trigger.OnFailure)
+	}
+	if err := updateMapping(uuid,
+		"--tumbling-window-in-seconds", strconv.Itoa(trigger.TumblingWindowSeconds()),
+		"--destination-config", destinationConfig); err != nil {
+		return err
+	}
+	fmt.Printf("Kinesis trigger changed to a %ds tumbling window and on-failure destination %q\n", trigger.TumblingWindowSeconds(), trigger.OnFailure)
+	return nil
+}
+
+// findMapping returns the UUID of the live function's mapping of sourceARN,
+// or of deploy.alias's, followed by the fields it was asked for, or nil when
+// setup has not created the mapping yet.
+func findMapping(awsAccountID, sourceARN, fields string) ([]string, error) {
+	function := config.TriggerTarget()
+	if config.Deploy.Strategy == "bluegreen" {
+		var err error
+		if function, _, err = blueGreenFunctions(awsAccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	listCmd := exec.Command("aws", "lambda", "list-event-source-mappings",
+		"--function-name", function,
+		"--event-source-arn", sourceARN,
+		"--query", fmt.Sprintf("EventSourceMappings[0].[UUID, %s]", fields),
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(listCmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event source mappings of %s: %v\nOutput: %s", function, err, output)
+	}
+	mapping := strings.Fields(string(output))
+	if len(mapping) != 1+len(strings.Split(fields, ",")) || mapping[0] == "None" {
+		fmt.Printf("%s has no trigger for %s yet; `lambda-template setup` creates it\n", function, sourceARN)
+		return nil, nil
+	}
+	return mapping, nil
+}
+
+func updateMapping(uuid string, settings ...string) error {
+	updateCmd := exec.Command("aws", "lambda", "update-event-source-mapping", "--uuid", uuid)
+	updateCmd.Args = append(updateCmd.Args, settings...)
+	updateCmd.Args = append(updateCmd.Args, "--profile", config.AWS.Profile, "--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(updateCmd); err != nil {
+		return fmt.Errorf("failed to update event source mapping %s: %v\nOutput: %s", uuid, err, output)
+	}
+	return nil
+}
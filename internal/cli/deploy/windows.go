@@ -0,0 +1,50 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+)
+
+// parseDeployTime accepts RFC 3339 with or without seconds, e.g.
+// 2024-07-01T02:00Z.
+func parseDeployTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a format like 2024-07-01T02:00Z", value)
+}
+
+// checkDeployWindows returns an error unless t falls inside one of the
+// configured windows. No configured windows means deploys are always allowed.
+func checkDeployWindows(t time.Time) error {
+	if len(config.DeployWindows) == 0 {
+		return nil
+	}
+	for _, window := range config.DeployWindows {
+		open, err := window.Contains(t)
+		if err != nil {
+			return err
+		}
+		if open {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the configured deploy windows (use -ignore-windows to override)", t.Format(time.RFC3339))
+}
+
+// waitUntil blocks until t, printing the remaining time periodically.
+func waitUntil(t time.Time) {
+	for {
+		remaining := time.Until(t)
+		if remaining <= 0 {
+			return
+		}
+		fmt.Printf("Deploy scheduled for %s, starting in %s\n", t.Format(time.RFC3339), remaining.Round(time.Second))
+		if remaining > 10*time.Minute {
+			remaining = 10 * time.Minute
+		}
+		time.Sleep(remaining)
+	}
+}
@@ -0,0 +1,264 @@
+package docs
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// node is a resource in the diagram. Nodes with InVPC are drawn inside the
+// VPC when the function runs in one.
+type node struct {
+	ID, Label string
+	// Shape is a Graphviz shape; renderD2 maps it to the nearest D2 one
+	Shape  string
+	InVPC  bool
+	Dashed bool
+}
+
+type edge struct {
+	From, To, Label string
+}
+
+type graph struct {
+	Nodes []node
+	Edges []edge
+}
+
+func (g *graph) add(n node) {
+	g.Nodes = append(g.Nodes, n)
+}
+
+func (g *graph) connect(from, to, label string) {
+	g.Edges = append(g.Edges, edge{from, to, label})
+}
+
+func diagram(args []string) {
+	flags := flag.NewFlagSet("lambda-template docs diagram", flag.ExitOnError)
+	format := flags.String("format", "dot", "Diagram language: dot (Graphviz) or d2")
+	out := flags.String("o", "", "File to write the diagram to (default docs/architecture.dot or .d2)")
+	svg := flags.Bool("svg", false, "Also render an SVG next to it with the dot or d2 CLI")
+	flags.Parse(args)
+
+	var render func(io.Writer, *graph) error
+	switch *format {
+	case "dot":
+		render = renderDOT
+	case "d2":
+		render = renderD2
+	default:
+		log.Fatalf("Unknown format %q, expected dot or d2", *format)
+	}
+	if *out == "" {
+		*out = filepath.Join("docs", "architecture."+*format)
+	}
+
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	g := architectureGraph()
+	if err := writeFile(*out, func(w io.Writer) error { return render(w, g) }); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Diagram written to %s\n", *out)
+
+	if *svg {
+		target := strings.TrimSuffix(*out, filepath.Ext(*out)) + ".svg"
+		cmd := exec.Command("dot", "-Tsvg", "-o", target, *out)
+		if *format == "d2" {
+			cmd = exec.Command("d2", *out, target)
+		}
+		output, err := hostexec.CombinedOutput(cmd)
+		if err != nil {
+			log.Fatalf("Error rendering %s with %s (is it installed?): %v\n%s", target, cmd.Args[0], err, output)
+		}
+		fmt.Printf("SVG written to %s\n", target)
+	}
+}
+
+// architectureGraph lays out what setup and deploy stand up for config.yaml:
+// the function, what triggers it, where its image comes from and everything
+// it talks to.
+func architectureGraph() *graph {
+	name := config.Lambda.FunctionName
+	g := &graph{}
+	inVPC := len(config.VPC.SubnetIDs) > 0
+
+	g.add(node{ID: "function", Label: "Lambda\n" + name, Shape: "box", InVPC: inVPC})
+	g.add(node{ID: "ecr", Label: "ECR\n" + config.ECR.RepositoryName, Shape: "cylinder"})
+	g.add(node{ID: "role", Label: "IAM role\n" + config.Lambda.RoleName, Shape: "note"})
+	g.add(node{ID: "logs", Label: "CloudWatch Logs\n/aws/lambda/" + name, Shape: "folder"})
+	g.connect("ecr", "function", "image")
+	g.connect("role", "function", "assumed by")
+	g.connect("function", "logs", "logs")
+	if config.Deploy.Strategy == "bluegreen" {
+		g.add(node{ID: "green", Label: "Lambda\n" + name + "-green", Shape: "box", InVPC: inVPC, Dashed: true})
+		g.connect("ecr", "green", "image")
+		g.connect("function", "green", "blue/green")
+	}
+
+	if config.Export.Schedule != "" {
+		g.add(node{ID: "schedule", Label: "EventBridge rule\n" + config.Export.Schedule, Shape: "diamond"})
+		g.connect("schedule", "function", "invokes")
+	}
+	if config.Export.Source.Type != "" {
+		label := "DynamoDB\n" + config.Export.Source.Table
+		if config.Export.Source.Type == "http" {
+			label = "HTTP source\n" + config.Export.Source.URL
+		}
+		g.add(node{ID: "export_source", Label: label, Shape: "cylinder"})
+		g.connect("export_source", "function", "read")
+	}
+	if config.Export.Bucket != "" {
+		g.add(node{ID: "bucket", Label: "S3\n" + config.Export.Bucket, Shape: "folder"})
+		g.connect("function", "bucket", "Parquet")
+		if config.Export.Glue.Table != "" {
+			g.add(node{ID: "glue", Label: "Glue table\n" + config.Export.Glue.Database + "." + config.Export.Glue.Table, Shape: "tab"})
+			g.connect("function", "glue", "partitions")
+			g.connect("glue", "bucket", "describes")
+		}
+	}
+	if config.Worker.QueueName != "" {
+		queue, dlq := config.WorkerQueueName()
+		g.add(node{ID: "queue", Label: "SQS\n" + queue, Shape: "cds"})
+		g.add(node{ID: "dlq", Label: "SQS DLQ\n" + dlq, Shape: "cds"})
+		g.connect("queue", "function", "event source mapping")
+		g.connect("function", "queue", "enqueue")
+		g.connect("queue", "dlq", "redrive")
+	}
+	if config.Events.BusName != "" {
+		g.add(node{ID: "bus", Label: "EventBridge bus\n" + config.Events.BusName, Shape: "hexagon"})
+		g.connect("function", "bus", config.Events.Source)
+	}
+	if config.DynConfig.Parameter != "" {
+		g.add(node{ID: "dynconfig", Label: "SSM parameter\n" + config.DynConfig.Parameter, Shape: "note"})
+		g.connect("dynconfig", "function", "settings")
+	}
+	if config.Database.ProxyName != "" {
+		engine := config.Database.Engine
+		if engine == "" {
+			engine = "postgres"
+		}
+		g.add(node{ID: "proxy", Label: "RDS Proxy\n" + config.Database.ProxyName, Shape: "box", InVPC: inVPC})
+		g.add(node{ID: "database", Label: engine + "\n" + config.Database.Name, Shape: "cylinder", InVPC: inVPC})
+		g.connect("function", "proxy", "IAM auth")
+		g.connect("proxy", "database", "")
+	}
+	if config.Cache.Address != "" {
+		g.add(node{ID: "cache", Label: "Redis\n" + config.Cache.Address, Shape: "cylinder", InVPC: inVPC})
+		g.connect("function", "cache", "cache")
+	}
+	if config.ErrorReporting.DSN != "" {
+		g.add(node{ID: "errors", Label: errorReporter(), Shape: "ellipse"})
+		g.connect("function", "errors", "errors")
+	}
+	for i, host := range config.Egress.Allow {
+		id := fmt.Sprintf("egress_%d", i)
+		g.add(node{ID: id, Label: host, Shape: "ellipse"})
+		g.connect("function", id, "egress")
+	}
+	return g
+}
+
+func renderDOT(w io.Writer, g *graph) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "digraph %q {\n", config.Lambda.FunctionName)
+	b.WriteString("  rankdir=LR;\n  node [fontname=\"Helvetica\", fontsize=10];\n  edge [fontname=\"Helvetica\", fontsize=9];\n\n")
+
+	writeNode := func(indent string, n node) {
+		style := ""
+		if n.Dashed {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "%s%s [label=%q, shape=%s%s];\n", indent, n.ID, n.Label, n.Shape, style)
+	}
+	var vpc []node
+	for _, n := range g.Nodes {
+		if n.InVPC {
+			vpc = append(vpc, n)
+			continue
+		}
+		writeNode("  ", n)
+	}
+	if len(vpc) > 0 {
+		fmt.Fprintf(&b, "\n  subgraph cluster_vpc {\n    label=%q;\n    style=dashed;\n", "VPC\n"+strings.Join(config.VPC.SubnetIDs, ", "))
+		for _, n := range vpc {
+			writeNode("    ", n)
+		}
+		b.WriteString("  }\n")
+	}
+
+	b.WriteString("\n")
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.From, e.To)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%q];\n", e.From, e.To, e.Label)
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// d2Shapes maps the Graphviz shapes used above to D2's.
+var d2Shapes = map[string]string{
+	"box":      "rectangle",
+	"cylinder": "cylinder",
+	"note":     "page",
+	"folder":   "package",
+	"diamond":  "diamond",
+	"tab":      "document",
+	"cds":      "queue",
+	"hexagon":  "hexagon",
+	"ellipse":  "oval",
+}
+
+func renderD2(w io.Writer, g *graph) error {
+	var b bytes.Buffer
+	b.WriteString("direction: right\n\n")
+
+	path := map[string]string{}
+	writeNode := func(indent, prefix string, n node) {
+		path[n.ID] = prefix + n.ID
+		fmt.Fprintf(&b, "%s%s: %q {\n%s  shape: %s\n", indent, n.ID, n.Label, indent, d2Shapes[n.Shape])
+		if n.Dashed {
+			fmt.Fprintf(&b, "%s  style.stroke-dash: 3\n", indent)
+		}
+		fmt.Fprintf(&b, "%s}\n", indent)
+	}
+	var vpc []node
+	for _, n := range g.Nodes {
+		if n.InVPC {
+			vpc = append(vpc, n)
+			continue
+		}
+		writeNode("", "", n)
+	}
+	if len(vpc) > 0 {
+		fmt.Fprintf(&b, "vpc: %q {\n  style.stroke-dash: 3\n", "VPC\n"+strings.Join(config.VPC.SubnetIDs, ", "))
+		for _, n := range vpc {
+			writeNode("  ", "vpc.", n)
+		}
+		b.WriteString("}\n")
+	}
+
+	b.WriteString("\n")
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "%s -> %s\n", path[e.From], path[e.To])
+			continue
+		}
+		fmt.Fprintf(&b, "%s -> %s: %q\n", path[e.From], path[e.To], e.Label)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
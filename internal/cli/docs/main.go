@@ -0,0 +1,91 @@
+package docs
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+var config appconfig.Config
+
+func loadConfig() error {
+	cfg, err := appconfig.Load()
+	if err != nil {
+		return err
+	}
+	config = *cfg
+	return nil
+}
+
+// Main dispatches `docs generate` and `docs diagram`.
+func Main(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template docs generate [-o docs/RUNBOOK.md] [-offline]")
+		fmt.Fprintln(os.Stderr, "       lambda-template docs diagram [-format dot|d2] [-o docs/architecture.dot] [-svg]")
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "generate":
+		generate(args[1:])
+	case "diagram":
+		diagram(args[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func generate(args []string) {
+	flags := flag.NewFlagSet("lambda-template docs generate", flag.ExitOnError)
+	out := flags.String("o", filepath.Join("docs", "RUNBOOK.md"), "File to write the runbook to")
+	offline := flags.Bool("offline", false, "Render from config.yaml only, without reading the deployed state")
+	flags.Parse(args)
+
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	state := &liveState{}
+	if !*offline {
+		awsCfg, err := config.AWSConfig(context.TODO())
+		if err != nil {
+			log.Fatalf("Unable to load SDK config: %v", err)
+		}
+		if state, err = readLiveState(context.TODO(), awsCfg); err != nil {
+			log.Fatalf("Error reading deployed state (use -offline to skip it): %v", err)
+		}
+	}
+
+	if err := writeFile(*out, func(w io.Writer) error { return renderRunbook(w, state) }); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Runbook written to %s\n", *out)
+}
+
+// writeFile creates path, and its directory if needed, with what render writes.
+func writeFile(path string, render func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", path, err)
+	}
+	if err := render(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error rendering %s: %v", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %v", path, err)
+	}
+	return nil
+}
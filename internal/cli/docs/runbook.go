@@ -0,0 +1,277 @@
+package docs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+)
+
+type resource struct {
+	Kind, ID string
+}
+
+// runbook is the data the template renders: config.yaml and the deployed
+// state reduced to what an on-call engineer needs.
+type runbook struct {
+	Config       appconfig.Config
+	State        *liveState
+	Architecture []string
+	Resources    []resource
+	Links        []console.Link
+}
+
+var runbookTemplate = template.Must(template.New("runbook").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`# {{.Config.Lambda.FunctionName}} runbook
+
+<!-- Generated by ` + "`lambda-template docs generate`" + ` from config.yaml{{if .State.Read}} and the deployed state{{end}}. Edit config.yaml and regenerate instead of editing this file. -->
+
+## Architecture
+
+{{range .Architecture}}- {{.}}
+{{end}}
+## Resources
+
+| Resource | ARN / ID |
+| --- | --- |
+{{range .Resources}}| {{.Kind}} | ` + "`{{.ID}}`" + ` |
+{{end}}
+## Deployed state
+
+{{if not .State.Read -}}
+Not read; regenerate without ` + "`-offline`" + ` to include it.
+{{- else if not .State.Deployed -}}
+The function does not exist yet; run ` + "`lambda-template setup`" + `.
+{{- else -}}
+| | |
+| --- | --- |
+| State | {{.State.State}} |
+| Last modified | {{.State.LastModified}} |
+| Image | ` + "`{{.State.ImageURI}}`" + ` |
+| Running digest | ` + "`{{.State.ResolvedImageURI}}`" + ` |
+| Memory | {{.State.MemorySize}} MB |
+| Timeout | {{.State.Timeout}} s |
+{{- if .State.Subnets}}
+| Subnets | {{join .State.Subnets ", "}} |
+| Security groups | {{join .State.SecurityGroups ", "}} |
+{{- end}}
+{{- if ne .State.LiveFunction .Config.Lambda.FunctionName}}
+| Live function | {{.State.LiveFunction}} (blue/green) |
+{{- end}}
+{{- end}}
+
+## Triggers
+
+{{if not .State.Read -}}
+Not read; regenerate without ` + "`-offline`" + ` to include them.
+{{- else if not .State.Triggers -}}
+None. The function is only invoked directly.
+{{- else -}}
+| Kind | ID | Source | Enabled |
+| --- | --- | --- | --- |
+{{- range .State.Triggers}}
+| {{.Kind}} | ` + "`{{.ID}}`" + ` | ` + "`{{.Source}}`" + ` | {{if .Enabled}}yes{{else}}**no**{{end}} |
+{{- end}}
+{{- end}}
+
+## Alarms
+
+{{if not .State.Read -}}
+Not read; regenerate without ` + "`-offline`" + ` to include them.
+{{- else if not .State.Alarms -}}
+No CloudWatch alarms watch this function.
+{{- else -}}
+| Alarm | Metric | State |
+| --- | --- | --- |
+{{- range .State.Alarms}}
+| {{.Name}} | {{.Metric}} | {{.State}} |
+{{- end}}
+{{- end}}
+{{- if .Config.SLO.Enabled}}
+
+Error budgets are defined in the ` + "`slo`" + ` section of config.yaml; ` + "`lambda-template slo`" + ` reports what is left.
+{{- end}}
+
+## Dashboards and links
+
+{{range .Links}}- [{{.Name}}]({{.URL}})
+{{end}}
+## Operations
+
+| Task | Command |
+| --- | --- |
+| Check health, triggers and maintenance mode | ` + "`lambda-template status`" + ` |
+| See which routes, handlers and event versions the deployed code has | ` + "`lambda-template status -introspect`" + ` |
+| Follow what the function logs | ` + "`lambda-template logs -follow`" + ` |
+{{- if .Config.DNS.Enabled}}
+| Follow the logs of every region together | ` + "`lambda-template logs -follow -regions all`" + ` |
+{{- end}}
+| Look into a request a customer reports | ` + "`lambda-template inspect <request-id>`" + ` |
+| See what is failing right now | ` + "`lambda-template errors`" + ` |
+| Export a month of logs for analysis | ` + "`lambda-template logs export -since 30d -to logs/`" + ` |
+| Deploy the current checkout | ` + "`lambda-template deploy`" + ` |
+| See what went out in a recent deploy | ` + "`lambda-template history`" + `, then ` + "`lambda-template history show <n>`" + ` |
+| Find settings changed in the console since the last deploy | ` + "`lambda-template drift`" + ` |
+| Stop all invocations immediately | ` + "`lambda-template throttle on`" + `, then ` + "`lambda-template throttle off`" + ` |
+| Stop consuming queues, streams and schedules | ` + "`lambda-template esm pause`" + `, then ` + "`lambda-template esm resume`" + ` |
+| Answer HTTP callers with a 503 | ` + "`lambda-template maintenance on`" + `, then ` + "`lambda-template maintenance off`" + ` |
+{{- if .Config.SLO.Enabled}}
+| Check the error budget | ` + "`lambda-template slo`" + ` |
+{{- end}}
+| Remove everything | ` + "`lambda-template delete`" + ` |
+
+### Rollback
+
+{{if eq .Config.Deploy.Strategy "bluegreen" -}}
+Deploys go to the idle function of the ` + "`{{.Config.Lambda.FunctionName}}`" + ` / ` + "`{{.Config.Lambda.FunctionName}}-green`" + ` pair, and the previous version stays deployed. To move the triggers back to it:
+
+    lambda-template deploy -swap
+{{- else -}}
+Deploys replace the code in place and push ` + "`:latest`" + `, so roll back by pointing the function at the image pushed before the deployed one:
+
+    lambda-template rollback -previous
+
+` + "`lambda-template rollback`" + ` on its own lists the recent images and published versions; ` + "`-to-image <digest>`" + ` and ` + "`-to-version N`" + ` go back to one of those.
+{{- if .State.ResolvedImageURI}}
+
+The digest running when this runbook was generated was ` + "`{{.State.ResolvedImageURI}}`" + `.
+{{- end}}
+{{- end}}
+{{- if .Config.Backup.Bucket}}
+
+### Disaster recovery
+
+Snapshots of the function's definition are stored in ` + "`s3://{{.Config.Backup.Bucket}}/{{.Config.BackupPrefix}}`" + `; ` + "`lambda-template backup list`" + ` prints them. To recreate the function in another account or region, push its image to ECR there and run:
+
+    lambda-template restore -region <region> -image <image uri>
+{{- end}}
+`))
+
+func renderRunbook(w io.Writer, state *liveState) error {
+	region := config.AWS.Region
+	account := state.AccountID
+	if account == "" {
+		account = "ACCOUNT_ID"
+	}
+	name := config.Lambda.FunctionName
+
+	r := runbook{Config: config, State: state}
+	r.Architecture = architecture()
+
+	functionARN := state.FunctionARN
+	if functionARN == "" {
+		functionARN = config.Partition().ARN("lambda", region, account, "function:"+name)
+	}
+	roleARN := state.RoleARN
+	if roleARN == "" {
+		roleARN = config.Partition().ARN("iam", "", account, "role/"+config.Lambda.RoleName)
+	}
+	r.Resources = []resource{
+		{"Lambda function", functionARN},
+		{"Execution role", roleARN},
+		{"ECR repository", config.Partition().ARN("ecr", region, account, "repository/"+config.ECR.RepositoryName)},
+		{"Log group", config.Partition().ARN("logs", region, account, "log-group:/aws/lambda/"+name)},
+	}
+	if config.Deploy.Strategy == "bluegreen" {
+		r.Resources = append(r.Resources, resource{"Green function", config.Partition().ARN("lambda", region, account, fmt.Sprintf("function:%s-green", name))})
+	}
+	if config.Export.Schedule != "" {
+		r.Resources = append(r.Resources, resource{"Export schedule", config.Partition().ARN("events", region, account, fmt.Sprintf("rule/%s-export", name))})
+	}
+	if config.Export.Bucket != "" {
+		r.Resources = append(r.Resources, resource{"Export bucket", config.Partition().ARN("s3", "", "", config.Export.Bucket)})
+	}
+	if config.Events.BusName != "" {
+		r.Resources = append(r.Resources, resource{"Event bus", config.Partition().ARN("events", region, account, "event-bus/"+config.Events.BusName)})
+	}
+	if config.Worker.QueueName != "" {
+		queue, dlq := config.WorkerQueueName()
+		r.Resources = append(r.Resources,
+			resource{"Worker queue", config.Partition().ARN("sqs", region, account, queue)},
+			resource{"Dead-letter queue", config.Partition().ARN("sqs", region, account, dlq)})
+	}
+	if config.DynConfig.Parameter != "" {
+		r.Resources = append(r.Resources, resource{"Dynamic config", config.Partition().ARN("ssm", region, account, "parameter"+config.DynConfig.Parameter)})
+	}
+	if config.Database.ProxyName != "" {
+		r.Resources = append(r.Resources, resource{"RDS Proxy", config.Database.ProxyName})
+	}
+
+	c := console.New(config.Partition(), region)
+	r.Links = []console.Link{
+		c.Function(name, ""),
+		c.Metrics(name),
+		c.Logs(name),
+		c.Alarms(),
+		c.Repository(account, config.ECR.RepositoryName),
+	}
+	for _, dashboard := range state.Dashboards {
+		r.Links = append(r.Links, c.Dashboard(dashboard))
+	}
+
+	return runbookTemplate.Execute(w, r)
+}
+
+// architecture summarises what config.yaml stands up, one line per piece.
+func architecture() []string {
+	handler := config.Lambda.Handler
+	if handler == "" {
+		handler = "lambda"
+	}
+	lines := []string{
+		fmt.Sprintf("Container image Lambda function `%s` built from `cmd/%s`, %d MB, %d s timeout, in %s.",
+			config.Lambda.FunctionName, handler, config.Lambda.MemorySize, config.Lambda.Timeout, config.AWS.Region),
+		fmt.Sprintf("Images are pushed to the ECR repository `%s`; the function runs as the role `%s`.",
+			config.ECR.RepositoryName, config.Lambda.RoleName),
+	}
+	if config.Deploy.Strategy == "bluegreen" {
+		lines = append(lines, fmt.Sprintf("Blue/green deploys alternate between `%s` and `%s-green`, moving the triggers once the new version passes a test invocation.",
+			config.Lambda.FunctionName, config.Lambda.FunctionName))
+	} else {
+		lines = append(lines, "Deploys update the function in place.")
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		lines = append(lines, fmt.Sprintf("Runs in the VPC through subnets %s with security groups %s.",
+			strings.Join(config.VPC.SubnetIDs, ", "), strings.Join(config.VPC.SecurityGroupIDs, ", ")))
+	}
+	if config.Export.Schedule != "" {
+		lines = append(lines, fmt.Sprintf("Exports %s `%s` to `s3://%s` as Parquet on the schedule `%s`.",
+			config.Export.Source.Type, config.Export.Source.Table+config.Export.Source.URL, config.Export.Bucket, config.Export.Schedule))
+	}
+	if config.Events.BusName != "" {
+		lines = append(lines, fmt.Sprintf("Publishes `%s` events to the EventBridge bus `%s`.", config.Events.Source, config.Events.BusName))
+	}
+	if config.Worker.QueueName != "" {
+		queue, dlq := config.WorkerQueueName()
+		lines = append(lines, fmt.Sprintf("Consumes background jobs from the SQS queue `%s`; failed jobs end up in `%s`.", queue, dlq))
+	}
+	if config.Database.ProxyName != "" {
+		engine := config.Database.Engine
+		if engine == "" {
+			engine = "postgres"
+		}
+		lines = append(lines, fmt.Sprintf("Connects to the %s database `%s` through RDS Proxy `%s` with IAM authentication.",
+			engine, config.Database.Name, config.Database.ProxyName))
+	}
+	if config.Cache.Address != "" {
+		lines = append(lines, fmt.Sprintf("Caches in Redis at `%s`.", config.Cache.Address))
+	}
+	if config.DynConfig.Parameter != "" {
+		lines = append(lines, fmt.Sprintf("Reads runtime settings from the SSM parameter `%s` (update with `config push`).", config.DynConfig.Parameter))
+	}
+	if config.ErrorReporting.DSN != "" {
+		lines = append(lines, "Reports panics and errors to "+errorReporter()+".")
+	}
+	return lines
+}
+
+func errorReporter() string {
+	if strings.HasPrefix(config.ErrorReporting.DSN, "rollbar://") {
+		return "Rollbar"
+	}
+	return "Sentry"
+}
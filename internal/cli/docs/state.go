@@ -0,0 +1,130 @@
+package docs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/triggers"
+)
+
+// liveState is what is actually deployed, as opposed to what config.yaml
+// asks for. The zero value stands for "not read".
+type liveState struct {
+	Read bool
+	// Deployed is false when the function does not exist yet
+	Deployed     bool
+	FunctionARN  string
+	AccountID    string
+	State        string
+	LastModified string
+	// ImageURI is the tag the function was deployed from, ResolvedImageURI
+	// the digest it runs
+	ImageURI         string
+	ResolvedImageURI string
+	RoleARN          string
+	MemorySize       int32
+	Timeout          int32
+	Subnets          []string
+	SecurityGroups   []string
+	// LiveFunction is the function holding the triggers, which differs from
+	// the configured one after a blue/green deploy moved them
+	LiveFunction string
+	Triggers     []triggers.Trigger
+	Alarms       []alarm
+	Dashboards   []string
+}
+
+type alarm struct {
+	Name, Metric, State string
+}
+
+func readLiveState(ctx context.Context, awsCfg aws.Config) (*liveState, error) {
+	state := &liveState{Read: true}
+	lambdaClient := lambda.NewFromConfig(awsCfg)
+
+	function, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting function: %v", err)
+	}
+	state.Deployed = true
+
+	configuration := function.Configuration
+	state.FunctionARN = aws.ToString(configuration.FunctionArn)
+	// arn:aws:lambda:region:account:function:name
+	if parts := strings.Split(state.FunctionARN, ":"); len(parts) > 4 {
+		state.AccountID = parts[4]
+	}
+	state.State = string(configuration.State)
+	state.LastModified = aws.ToString(configuration.LastModified)
+	state.RoleARN = aws.ToString(configuration.Role)
+	state.MemorySize = aws.ToInt32(configuration.MemorySize)
+	state.Timeout = aws.ToInt32(configuration.Timeout)
+	if function.Code != nil {
+		state.ImageURI = aws.ToString(function.Code.ImageUri)
+		state.ResolvedImageURI = aws.ToString(function.Code.ResolvedImageUri)
+	}
+	if configuration.VpcConfig != nil {
+		state.Subnets = configuration.VpcConfig.SubnetIds
+		state.SecurityGroups = configuration.VpcConfig.SecurityGroupIds
+	}
+
+	liveARN, err := triggers.LiveFunctionARN(ctx, lambdaClient, config.Lambda.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	state.LiveFunction = liveARN[strings.LastIndex(liveARN, ":")+1:]
+	if state.Triggers, err = triggers.List(ctx, lambdaClient, eventbridge.NewFromConfig(awsCfg), triggers.AliasARN(liveARN, config.Deploy.Alias)); err != nil {
+		return nil, err
+	}
+
+	cloudwatchClient := cloudwatch.NewFromConfig(awsCfg)
+	functions := map[string]bool{config.Lambda.FunctionName: true, config.Lambda.FunctionName + "-green": true}
+	alarms := cloudwatch.NewDescribeAlarmsPaginator(cloudwatchClient, &cloudwatch.DescribeAlarmsInput{})
+	for alarms.HasMorePages() {
+		page, err := alarms.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing alarms: %v", err)
+		}
+		for _, metricAlarm := range page.MetricAlarms {
+			for _, dimension := range metricAlarm.Dimensions {
+				if aws.ToString(dimension.Name) == "FunctionName" && functions[aws.ToString(dimension.Value)] {
+					state.Alarms = append(state.Alarms, alarm{
+						Name:   aws.ToString(metricAlarm.AlarmName),
+						Metric: aws.ToString(metricAlarm.Namespace) + "/" + aws.ToString(metricAlarm.MetricName),
+						State:  string(metricAlarm.StateValue),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	dashboards := cloudwatch.NewListDashboardsPaginator(cloudwatchClient, &cloudwatch.ListDashboardsInput{
+		DashboardNamePrefix: aws.String(config.Lambda.FunctionName),
+	})
+	for dashboards.HasMorePages() {
+		page, err := dashboards.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing dashboards: %v", err)
+		}
+		for _, entry := range page.DashboardEntries {
+			state.Dashboards = append(state.Dashboards, aws.ToString(entry.DashboardName))
+		}
+	}
+
+	return state, nil
+}
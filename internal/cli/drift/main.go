@@ -0,0 +1,382 @@
+package drift
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/digest"
+	"example-lambda-go/internal/history"
+	"example-lambda-go/internal/secretenv"
+	"example-lambda-go/internal/triggers"
+)
+
+const defaultInterval = 15 * time.Minute
+
+// Main compares the live function with config.yaml and reports the settings
+// changed outside deploy, e.g. in the console, which the next deploy would
+// silently revert. watch keeps checking and alerts when the drift changes.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template drift", flag.ExitOnError)
+	alert := flags.Bool("alert", false, "Also send the findings to the drift (or report) section's Slack webhook and SNS topic when there are any")
+	intervalFlag := flags.String("interval", "", "With watch, the time between checks; drift.interval or 15m by default")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template drift [-alert]")
+		fmt.Fprintln(os.Stderr, "       lambda-template drift watch [-interval 15m]")
+		fmt.Fprintln(os.Stderr, "Compares the function's memory, timeout, storage, role, tracing, VPC, dead-letter target,")
+		fmt.Fprintln(os.Stderr, "lambda.environment and lambda.tags with config.yaml, and its image with the last deploy in")
+		fmt.Fprintln(os.Stderr, "audit.history, exiting 1 on drift. With deploy.strategy bluegreen only the live color is")
+		fmt.Fprintln(os.Stderr, "compared. watch checks until interrupted and alerts when the drift appears, changes or")
+		fmt.Fprintln(os.Stderr, "is resolved.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	watch := false
+	switch flags.Arg(0) {
+	case "":
+	case "watch":
+		watch = true
+		flags.Parse(flags.Args()[1:])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	interval := defaultInterval
+	for _, value := range []string{cfg.Drift.Interval, *intervalFlag} {
+		if value == "" {
+			continue
+		}
+		if interval, err = time.ParseDuration(value); err != nil || interval < time.Minute {
+			log.Fatalf("Invalid interval %q: want a duration of at least 1m", value)
+		}
+	}
+	alerts := digest.Config{SlackWebhook: cfg.Drift.SlackWebhook, SNSTopicARN: cfg.Drift.SNSTopicARN}
+	webhookSetting := "drift.slack_webhook"
+	if !alerts.Enabled() {
+		alerts.SlackWebhook, alerts.SNSTopicARN = cfg.Report.SlackWebhook, cfg.Report.SNSTopicARN
+		webhookSetting = "report.slack_webhook"
+	}
+	if (watch || *alert) && !alerts.Enabled() {
+		log.Fatal("drift.slack_webhook or drift.sns_topic_arn (or the report section's) must be set to send alerts")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	if alerts.SlackWebhook, err = secretenv.Value(ctx, secretsmanager.NewFromConfig(awsCfg), webhookSetting, alerts.SlackWebhook); err != nil {
+		log.Fatal(err)
+	}
+	httpClient, err := cfg.HTTPClient(10 * time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := &detector{
+		cfg:        cfg,
+		lambda:     lambda.NewFromConfig(awsCfg),
+		alerts:     alerts,
+		topic:      sns.NewFromConfig(awsCfg),
+		httpClient: httpClient,
+		w:          os.Stdout,
+	}
+
+	if !watch {
+		findings, err := d.check(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d.print(findings)
+		if len(findings) == 0 {
+			return
+		}
+		if *alert {
+			if err := d.send(ctx, findings); err != nil {
+				log.Fatal(err)
+			}
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("Checking %s for drift every %s; interrupt to stop\n", cfg.Lambda.FunctionName, interval)
+	if err := d.watch(ctx, interval); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// lambdaAPI is the part of the Lambda client drift reads with.
+type lambdaAPI interface {
+	GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+}
+
+type detector struct {
+	cfg        *config.Config
+	lambda     lambdaAPI
+	alerts     digest.Config
+	topic      digest.SNSAPI
+	httpClient *http.Client
+	w          io.Writer
+}
+
+// finding is one setting of a live function that is not what config.yaml,
+// or the last deploy, put there.
+type finding struct {
+	function, setting string
+	want, have        string
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("%s: %s is %s, want %s", f.function, f.setting, f.have, f.want)
+}
+
+// check reads the function that serves traffic and compares it. With
+// deploy.strategy bluegreen that is the color the blue function's
+// triggers.LiveTag names, as for triggers.LiveFunctionARN; the idle color
+// keeps the previous release until the next deploy and is not compared.
+func (d *detector) check(ctx context.Context) ([]finding, error) {
+	records, err := history.Read(d.cfg.Audit.History, d.cfg.Lambda.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	var last *history.Record
+	if len(records) > 0 {
+		last = &records[0]
+	}
+	name := d.cfg.Lambda.FunctionName
+	function, err := d.lambda.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(name)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading function %s: %v", name, err)
+	}
+	if d.cfg.Deploy.Strategy == "bluegreen" && function.Tags[triggers.LiveTag] == "green" {
+		name += "-green"
+		if function, err = d.lambda.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(name)}); err != nil {
+			return nil, fmt.Errorf("error reading function %s: %v", name, err)
+		}
+	}
+	return compare(d.cfg, name, function, last), nil
+}
+
+// watch checks every interval until ctx is done, alerting when the findings
+// differ from the last check's. A failed check is reported and retried at
+// the next interval.
+func (d *detector) watch(ctx context.Context, interval time.Duration) error {
+	var previous []finding
+	first := true
+	for {
+		findings, err := d.check(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			fmt.Fprintf(d.w, "%s  check failed: %v\n", time.Now().Format("2006-01-02 15:04:05"), err)
+		case first || !slices.Equal(findings, previous):
+			d.print(findings)
+			// Starting without drift is not worth an alert
+			if len(findings) > 0 || !first {
+				if err := d.send(ctx, findings); err != nil {
+					fmt.Fprintf(d.w, "Warning: %v\n", err)
+				}
+			}
+			previous, first = findings, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (d *detector) print(findings []finding) {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	if len(findings) == 0 {
+		fmt.Fprintf(d.w, "%s  %s matches config.yaml\n", timestamp, d.cfg.Lambda.FunctionName)
+		return
+	}
+	fmt.Fprintf(d.w, "%s  %d setting(s) changed outside deploy; the next deploy reverts them:\n", timestamp, len(findings))
+	for _, f := range findings {
+		fmt.Fprintf(d.w, "  %s\n", f)
+	}
+}
+
+// send alerts with the findings, or that there are none any more.
+func (d *detector) send(ctx context.Context, findings []finding) error {
+	name := d.cfg.Lambda.FunctionName
+	if len(findings) == 0 {
+		return digest.Notify(ctx, d.alerts, d.topic, d.httpClient, "Drift resolved on "+name, name+" matches config.yaml again.")
+	}
+	lines := make([]string, len(findings))
+	for i, f := range findings {
+		lines[i] = f.String()
+	}
+	subject := fmt.Sprintf("Drift on %s: %d setting(s) changed outside deploy", name, len(findings))
+	text := strings.Join(lines, "\n") + "\n\nDeploy to restore config.yaml, or copy the change into it to keep it."
+	return digest.Notify(ctx, d.alerts, d.topic, d.httpClient, subject, text)
+}
+
+// compare lists the settings of the live function that differ from cfg.
+// Settings config.yaml leaves unset are not compared, and neither are
+// variables and tags it does not name, which other commands and the
+// integrations add. last is the function's latest recorded deploy, if any.
+func compare(cfg *config.Config, name string, function *lambda.GetFunctionOutput, last *history.Record) []finding {
+	live := function.Configuration
+	var findings []finding
+	differs := func(setting, want, have string) {
+		if want != have {
+			findings = append(findings, finding{function: name, setting: setting, want: want, have: have})
+		}
+	}
+
+	if cfg.Lambda.MemorySize > 0 {
+		differs("lambda.memory_size", fmt.Sprint(cfg.Lambda.MemorySize), fmt.Sprint(aws.ToInt32(live.MemorySize)))
+	}
+	if cfg.Lambda.Timeout > 0 {
+		differs("lambda.timeout", fmt.Sprint(cfg.Lambda.Timeout), fmt.Sprint(aws.ToInt32(live.Timeout)))
+	}
+	if cfg.Lambda.EphemeralStorage > 0 && live.EphemeralStorage != nil {
+		differs("lambda.ephemeral_storage", fmt.Sprint(cfg.Lambda.EphemeralStorage), fmt.Sprint(aws.ToInt32(live.EphemeralStorage.Size)))
+	}
+	if role := aws.ToString(live.Role); cfg.Lambda.RoleName != "" && !strings.HasSuffix(role, "/"+cfg.Lambda.RoleName) {
+		differs("lambda.role_name", cfg.Lambda.RoleName, role)
+	}
+	if cfg.Lambda.Tracing != "" && live.TracingConfig != nil {
+		differs("lambda.tracing", cfg.Lambda.Tracing, string(live.TracingConfig.Mode))
+	}
+	if cfg.Lambda.DeadLetterARN != "" {
+		have := ""
+		if live.DeadLetterConfig != nil {
+			have = aws.ToString(live.DeadLetterConfig.TargetArn)
+		}
+		differs("lambda.dead_letter_arn", cfg.Lambda.DeadLetterARN, display(have))
+	}
+	// A function paused with `throttle on` is meant to differ
+	if _, throttled := function.Tags[config.ThrottledTag]; cfg.Lambda.ReservedConcurrency != nil && !throttled {
+		have := "(none)"
+		if function.Concurrency != nil && function.Concurrency.ReservedConcurrentExecutions != nil {
+			have = fmt.Sprint(*function.Concurrency.ReservedConcurrentExecutions)
+		}
+		differs("lambda.reserved_concurrency", fmt.Sprint(*cfg.Lambda.ReservedConcurrency), have)
+	}
+	if len(cfg.VPC.SubnetIDs) > 0 {
+		var subnets, groups []string
+		if live.VpcConfig != nil {
+			subnets, groups = live.VpcConfig.SubnetIds, live.VpcConfig.SecurityGroupIds
+		}
+		differs("vpc.subnet_ids", set(cfg.VPC.SubnetIDs), set(subnets))
+		differs("vpc.security_group_ids", set(cfg.VPC.SecurityGroupIDs), set(groups))
+	}
+
+	variables := map[string]string{}
+	if live.Environment != nil {
+		variables = live.Environment.Variables
+	}
+	for _, key := range sortedKeys(cfg.Lambda.Environment) {
+		want := cfg.Lambda.Environment[key]
+		have, ok := variables[key]
+		if ok && sameVariable(want, have) {
+			continue
+		}
+		// Compared before display, which hides secrets
+		f := finding{function: name, setting: "lambda.environment." + key, want: displayVariable(key, want), have: "(removed)"}
+		if ok {
+			f.have = displayVariable(key, have)
+		}
+		findings = append(findings, f)
+	}
+	for _, key := range sortedKeys(cfg.Lambda.Tags) {
+		have, ok := function.Tags[key]
+		if !ok {
+			have = "(removed)"
+		} else {
+			have = fmt.Sprintf("%q", have)
+		}
+		differs("lambda.tags."+key, fmt.Sprintf("%q", cfg.Lambda.Tags[key]), have)
+	}
+
+	// Only the image deploy pushed is known; the history is kept locally
+	if last != nil && last.Image != "" && function.Code != nil {
+		resolved := aws.ToString(function.Code.ResolvedImageUri)
+		if _, digest, ok := strings.Cut(resolved, "@"); ok && digest != last.Image {
+			findings = append(findings, finding{
+				function: name,
+				setting:  "image",
+				want:     fmt.Sprintf("%s from the deploy of %s", last.Image, last.Time.Local().Format("2006-01-02 15:04")),
+				have:     digest,
+			})
+		}
+	}
+	return findings
+}
+
+// sameVariable reports whether a live value is what config.yaml sets,
+// including a reference to a secret by name that deploy pinned to its ARN.
+func sameVariable(want, have string) bool {
+	if want == have {
+		return true
+	}
+	wantRef, ok, err := secretenv.Parse(want)
+	if !ok || err != nil || wantRef.IsARN() {
+		return false
+	}
+	haveRef, ok, err := secretenv.Parse(have)
+	if !ok || err != nil || !haveRef.IsARN() || haveRef.Key != wantRef.Key {
+		return false
+	}
+	// Secrets Manager adds a hyphen and six characters to the name
+	return strings.HasSuffix(haveRef.Secret[:max(len(haveRef.Secret)-7, 0)], ":secret:"+wantRef.Secret)
+}
+
+// displayVariable quotes a value, or hides it when the name suggests a
+// secret, as alerts go to chat and email.
+func displayVariable(key, value string) string {
+	if _, ok, _ := secretenv.Parse(value); !ok && secretenv.LooksSecret(key) {
+		return "(hidden)"
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+func display(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func set(values []string) string {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	if len(sorted) == 0 {
+		return "(none)"
+	}
+	return strings.Join(sorted, ",")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,172 @@
+package drift
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/digest"
+	"example-lambda-go/internal/history"
+	"example-lambda-go/internal/triggers"
+)
+
+func liveFunction() *lambda.GetFunctionOutput {
+	return &lambda.GetFunctionOutput{
+		Configuration: &lambdatypes.FunctionConfiguration{
+			MemorySize:       aws.Int32(256),
+			Timeout:          aws.Int32(30),
+			EphemeralStorage: &lambdatypes.EphemeralStorage{Size: aws.Int32(512)},
+			Role:             aws.String("arn:aws:iam::123:role/hello-role"),
+			TracingConfig:    &lambdatypes.TracingConfigResponse{Mode: lambdatypes.TracingModeActive},
+			Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{
+				"LOG_LEVEL":     "info",
+				"DB_PASSWORD":   "secretsmanager:arn:aws:secretsmanager:us-east-1:123:secret:hello/db-AbCdEf:password",
+				"API_TOKEN":     "t0k3n",
+				"DIGEST_SET_BY": "report schedule",
+			}},
+		},
+		Code: &lambdatypes.FunctionCodeLocation{ResolvedImageUri: aws.String("123.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:aaaa")},
+		Tags: map[string]string{"team": "payments", "lambda-template:commit": "abc"},
+	}
+}
+
+func testConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Lambda.FunctionName = "hello"
+	cfg.Lambda.RoleName = "hello-role"
+	cfg.Lambda.MemorySize = 256
+	cfg.Lambda.Timeout = 30
+	cfg.Lambda.Tracing = "Active"
+	cfg.Lambda.Environment = map[string]string{
+		"LOG_LEVEL":   "info",
+		"DB_PASSWORD": "secretsmanager:hello/db:password",
+		"API_TOKEN":   "t0k3n",
+	}
+	cfg.Lambda.Tags = map[string]string{"team": "payments"}
+	return cfg
+}
+
+func TestCompare(t *testing.T) {
+	last := &history.Record{Image: "sha256:aaaa", Time: time.Now()}
+	if findings := compare(testConfig(), "hello", liveFunction(), last); len(findings) != 0 {
+		t.Fatalf("compare of a function as deployed = %v, want no drift", findings)
+	}
+
+	function := liveFunction()
+	function.Configuration.MemorySize = aws.Int32(1024)
+	function.Configuration.Role = aws.String("arn:aws:iam::123:role/admin")
+	function.Configuration.Environment.Variables["LOG_LEVEL"] = "debug"
+	function.Configuration.Environment.Variables["API_TOKEN"] = "pasted-in-console"
+	delete(function.Configuration.Environment.Variables, "DB_PASSWORD")
+	function.Tags["team"] = "platform"
+	function.Code.ResolvedImageUri = aws.String("123.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:bbbb")
+
+	var got []string
+	for _, f := range compare(testConfig(), "hello", function, last) {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"hello: lambda.memory_size is 1024, want 256",
+		"hello: lambda.role_name is arn:aws:iam::123:role/admin, want hello-role",
+		"hello: lambda.environment.API_TOKEN is (hidden), want (hidden)",
+		`hello: lambda.environment.DB_PASSWORD is (removed), want "secretsmanager:hello/db:password"`,
+		`hello: lambda.environment.LOG_LEVEL is "debug", want "info"`,
+		`hello: lambda.tags.team is "platform", want "payments"`,
+		"hello: image is sha256:bbbb, want sha256:aaaa from the deploy of ",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("finding %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheckComparesLiveColorOnly(t *testing.T) {
+	cfg := testConfig()
+	cfg.Audit.History = t.TempDir() + "/deploys.log"
+	cfg.Deploy.Strategy = "bluegreen"
+	// Blue is idle and still runs the previous release's settings
+	blue := liveFunction()
+	blue.Configuration.Timeout = aws.Int32(10)
+	blue.Tags[triggers.LiveTag] = "green"
+	green := liveFunction()
+	green.Configuration.MemorySize = aws.Int32(1024)
+	d := &detector{cfg: cfg, lambda: &fakeLambda{functions: []*lambda.GetFunctionOutput{blue, green}, stop: func() {}}}
+
+	findings, err := d.check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].String() != "hello-green: lambda.memory_size is 1024, want 256" {
+		t.Errorf("findings = %v, want the live green function's only", findings)
+	}
+}
+
+// fakeLambda returns each of functions in turn and stops the watch on the
+// last, whose check is discarded.
+type fakeLambda struct {
+	functions []*lambda.GetFunctionOutput
+	calls     int
+	stop      context.CancelFunc
+}
+
+func (f *fakeLambda) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	function := f.functions[f.calls]
+	f.calls++
+	if f.calls == len(f.functions) {
+		f.stop()
+	}
+	return function, nil
+}
+
+type fakeSNS struct{ subjects []string }
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.subjects = append(f.subjects, aws.ToString(params.Subject))
+	return &sns.PublishOutput{}, nil
+}
+
+func TestWatchAlertsWhenDriftChanges(t *testing.T) {
+	cfg := testConfig()
+	cfg.Audit.History = t.TempDir() + "/deploys.log"
+	changed := liveFunction()
+	changed.Configuration.Timeout = aws.Int32(900)
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &fakeLambda{
+		functions: []*lambda.GetFunctionOutput{liveFunction(), changed, changed, liveFunction(), liveFunction()},
+		stop:      cancel,
+	}
+	topic := &fakeSNS{}
+	var out strings.Builder
+	d := &detector{
+		cfg:        cfg,
+		lambda:     client,
+		alerts:     digest.Config{SNSTopicARN: "arn:aws:sns:us-east-1:123:alerts"},
+		topic:      topic,
+		httpClient: http.DefaultClient,
+		w:          &out,
+	}
+	if err := d.watch(ctx, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unchanged drift on the third check is not alerted again
+	want := []string{"Drift on hello: 1 setting(s) changed outside deploy", "Drift resolved on hello"}
+	if strings.Join(topic.subjects, "|") != strings.Join(want, "|") {
+		t.Errorf("alerts %q, want %q", topic.subjects, want)
+	}
+	if !strings.Contains(out.String(), "hello: lambda.timeout is 900, want 30") {
+		t.Errorf("printed:\n%s", out.String())
+	}
+}
@@ -0,0 +1,86 @@
+package dynconfig
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/dynconfig"
+)
+
+// Standard parameters hold up to 4 KB; larger documents need the advanced tier.
+const standardTierLimit = 4096
+
+// Main validates and uploads the dynamic configuration document; it is the
+// `config` subcommand.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template config", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template config push -file dynconfig.yaml")
+		flags.PrintDefaults()
+	}
+	file := flags.String("file", "dynconfig.yaml", "Local JSON or YAML document to upload")
+	validateOnly := flags.Bool("validate", false, "Only validate the document")
+	if len(args) == 0 || args[0] != "push" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(args[1:])
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.DynConfig.Parameter == "" {
+		log.Fatal("dynconfig.parameter is not set in config.yaml")
+	}
+
+	// Validate the document the same way the handler will parse it
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", *file, err)
+	}
+	if _, err := dynconfig.Parse(data); err != nil {
+		log.Fatalf("Invalid document %s: %v", *file, err)
+	}
+	if len(data) > 8192 {
+		log.Fatalf("Document %s is %d bytes; SSM parameters are limited to 8 KB", *file, len(data))
+	}
+	if *validateOnly {
+		fmt.Printf("%s is valid.\n", *file)
+		return
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	client := ssm.NewFromConfig(awsCfg)
+
+	tier := types.ParameterTierStandard
+	if len(data) > standardTierLimit {
+		tier = types.ParameterTierAdvanced
+	}
+
+	output, err := client.PutParameter(context.TODO(), &ssm.PutParameterInput{
+		Name:      aws.String(cfg.DynConfig.Parameter),
+		Value:     aws.String(string(data)),
+		Type:      types.ParameterTypeString,
+		Tier:      tier,
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		log.Fatalf("Error updating parameter %s: %v", cfg.DynConfig.Parameter, err)
+	}
+
+	fmt.Printf("Pushed %s to %s (version %d). Functions pick it up within their refresh TTL.\n", *file, cfg.DynConfig.Parameter, output.Version)
+}
@@ -0,0 +1,77 @@
+package esm
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/triggers"
+)
+
+// Main pauses or resumes the function's triggers.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template esm", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template esm pause|resume")
+		fmt.Fprintln(os.Stderr, "Disables or enables the function's event source mappings and scheduled rules.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	action := flags.Arg(0)
+	if action != "pause" && action != "resume" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	lambdaClient := lambda.NewFromConfig(awsCfg)
+	eventsClient := eventbridge.NewFromConfig(awsCfg)
+
+	functionARN, err := triggers.LiveFunctionARN(context.TODO(), lambdaClient, cfg.Lambda.FunctionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	list, err := triggers.List(context.TODO(), lambdaClient, eventsClient, triggers.AliasARN(functionARN, cfg.Deploy.Alias))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(list) == 0 {
+		fmt.Printf("'%s' has no event source mappings or scheduled rules.\n", cfg.Lambda.FunctionName)
+		return
+	}
+
+	// Carry on past failures so a single stuck trigger doesn't leave the rest running
+	enable := action == "resume"
+	failed := 0
+	for _, trigger := range list {
+		if trigger.Enabled == enable {
+			fmt.Printf("%s %s (%s) is already %sd\n", trigger.Kind, trigger.ID, trigger.Source, action)
+			continue
+		}
+		if err := triggers.SetEnabled(context.TODO(), lambdaClient, eventsClient, trigger, enable); err != nil {
+			log.Print(err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s (%s) %sd\n", trigger.Kind, trigger.ID, trigger.Source, action)
+	}
+	if failed > 0 {
+		log.Fatalf("%d trigger(s) could not be %sd", failed, action)
+	}
+}
@@ -0,0 +1,109 @@
+package history
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/history"
+)
+
+// Main lists the function's deploys from the history deploy keeps, newest
+// first, or with `history show <n>` prints everything that went out in one.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template history", flag.ExitOnError)
+	limit := flags.Int("limit", 20, "Deploys to list; 0 lists all")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template history [-limit 20]")
+		fmt.Fprintln(os.Stderr, "       lambda-template history show <n>")
+		fmt.Fprintln(os.Stderr, "Lists the deploys recorded in audit.history, newest first and numbered from 1;")
+		fmt.Fprintln(os.Stderr, "show prints deploy n with the full list of commits that went out in it.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	records, err := history.Read(cfg.Audit.History, cfg.Lambda.FunctionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flags.Arg(0) {
+	case "":
+		list(os.Stdout, cfg.Lambda.FunctionName, records, *limit)
+	case "show":
+		n, err := strconv.Atoi(flags.Arg(1))
+		if flags.NArg() != 2 || err != nil {
+			flags.Usage()
+			os.Exit(2)
+		}
+		if err := show(os.Stdout, records, n); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func list(w io.Writer, function string, records []history.Record, limit int) {
+	if len(records) == 0 {
+		fmt.Fprintf(w, "No deploys of %s recorded yet\n", function)
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tDEPLOYED\tCOMMIT\tCOMMITS\tBY")
+	for i, r := range records {
+		if limit > 0 && i == limit {
+			break
+		}
+		commit := history.ShortCommit(r.Commit)
+		if commit == "" {
+			commit = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", i+1, r.Time.Local().Format("2006-01-02 15:04"), commit, len(r.Changes)+r.Omitted, r.Actor)
+	}
+	tw.Flush()
+	if limit > 0 && len(records) > limit {
+		fmt.Fprintf(w, "... %d older; -limit 0 lists all\n", len(records)-limit)
+	}
+}
+
+// show prints deploy n, counted from the newest.
+func show(w io.Writer, records []history.Record, n int) error {
+	if n < 1 || n > len(records) {
+		return fmt.Errorf("no deploy %d; %d are recorded", n, len(records))
+	}
+	r := records[n-1]
+	fmt.Fprintf(w, "Deploy %d of %s\n", n, r.Function)
+	fmt.Fprintf(w, "  Deployed: %s by %s\n", r.Time.Local().Format("2006-01-02 15:04:05"), r.Actor)
+	if r.Commit != "" {
+		fmt.Fprintf(w, "  Commit:   %s\n", r.Commit)
+	}
+	if r.Previous != "" {
+		fmt.Fprintf(w, "  Previous: %s\n", r.Previous)
+	}
+	if r.Image != "" {
+		fmt.Fprintf(w, "  Image:    %s\n", r.Image)
+	}
+	if len(r.Changes) == 0 {
+		fmt.Fprintln(w, "\nNo commits recorded")
+		return nil
+	}
+	fmt.Fprintln(w, "\nChanges")
+	for _, c := range r.Changes {
+		fmt.Fprintf(w, "  %s %s\n", history.ShortCommit(c.Commit), c.Subject)
+	}
+	if r.Omitted > 0 {
+		fmt.Fprintf(w, "  ... and %d older commits\n", r.Omitted)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"example-lambda-go/internal/history"
+)
+
+func TestListAndShow(t *testing.T) {
+	records := []history.Record{
+		{Function: "hello", Time: time.Now(), Actor: "arn:aws:iam::123:user/ada", Commit: "ccccccc123", Previous: "aaaaaaa123", Image: "sha256:feed",
+			Changes: []history.Change{{Commit: "ccccccc123", Subject: "Fix greeting"}, {Commit: "bbbbbbb123", Subject: "Add tabs"}}, Omitted: 3},
+		{Function: "hello", Time: time.Now().Add(-time.Hour), Actor: "arn:aws:iam::123:user/ada", Commit: "aaaaaaa123"},
+	}
+
+	var out strings.Builder
+	list(&out, "hello", records, 1)
+	for _, want := range []string{"1  ", "ccccccc", "5 ", "... 1 older"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("list printed:\n%s\nwant %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if err := show(&out, records, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Previous: aaaaaaa123", "ccccccc Fix greeting\n", "bbbbbbb Add tabs\n", "and 3 older commits"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("show printed:\n%s\nwant %q", out.String(), want)
+		}
+	}
+	if err := show(&out, records, 3); err == nil {
+		t.Error("show(3) of two deploys succeeded")
+	}
+}
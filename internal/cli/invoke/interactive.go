@@ -0,0 +1,351 @@
+package invoke
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example-lambda-go/contract"
+	"example-lambda-go/internal/jsonschema"
+)
+
+// fixtureName is what a saved fixture may be called: a file name without
+// directories, which compare -events and -payload-file read back.
+var fixtureName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// operationSchema returns the request schema of the contract operation
+// called name, or of the only one when name is empty.
+func operationSchema(name string) (*jsonschema.Schema, error) {
+	names := make([]string, 0, len(contract.Operations))
+	for _, op := range contract.Operations {
+		if op.Name == name || (name == "" && len(contract.Operations) == 1) {
+			return jsonschema.Generate(op.Request), nil
+		}
+		names = append(names, op.Name)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("give -operation with one of %s", strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("no operation %q in contract.Operations; want one of %s", name, strings.Join(names, ", "))
+}
+
+// builder asks for a payload one field at a time, reading answers from in
+// and writing prompts to w.
+type builder struct {
+	in *bufio.Scanner
+	w  io.Writer
+}
+
+var errInputEnded = errors.New("input ended before the payload was complete")
+
+// buildPayload walks schema, asking for every field and checking each answer
+// against its type. Optional fields are left out when skipped, and fields
+// with an obvious default take it when the answer is empty. Once the payload
+// is complete it offers to save it as a fixture in fixtures.
+func buildPayload(in io.Reader, w io.Writer, schema *jsonschema.Schema, fixtures string) ([]byte, error) {
+	b := &builder{in: bufio.NewScanner(in), w: w}
+	if schema.Title != "" {
+		fmt.Fprintf(w, "Building a %s; press Enter to take the default or skip an optional field\n", schema.Title)
+	}
+	value, _, err := b.value("", schema, true)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding payload: %v", err)
+	}
+	fmt.Fprintf(w, "Payload: %s\n", payload)
+
+	for {
+		name, err := b.ask(fmt.Sprintf("Save as a fixture in %s (name, Enter to skip)", fixtures))
+		// Piped answers may stop at the payload; it is sent unsaved
+		if errors.Is(err, errInputEnded) || name == "" {
+			return payload, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		path, err := saveFixture(fixtures, name, payload)
+		if err != nil {
+			fmt.Fprintf(w, "  %v\n", err)
+			continue
+		}
+		fmt.Fprintf(w, "Saved %s; send it again with -payload-file %s\n", path, path)
+		return payload, nil
+	}
+}
+
+// value asks for the value at path. It returns false for an optional field
+// that was skipped.
+func (b *builder) value(path string, schema *jsonschema.Schema, required bool) (interface{}, bool, error) {
+	switch {
+	case schema.Type == "object" && schema.Properties != nil:
+		return b.object(path, schema, required)
+	case schema.Type == "object":
+		return b.mapping(path, schema, required)
+	case schema.Type == "array":
+		return b.array(path, schema, required)
+	}
+
+	label := display(path)
+	hint, fallback := schema.Type, ""
+	switch {
+	case schema.Format == "date-time":
+		hint, fallback = "RFC 3339 time", time.Now().UTC().Format(time.RFC3339)
+	case schema.Type == "boolean":
+		hint, fallback = "true or false", "false"
+	case schema.Type == "integer", schema.Type == "number":
+		fallback = "0"
+	case schema.Type == "":
+		hint = "any JSON"
+	}
+	if !required {
+		fallback = ""
+		hint += ", optional"
+	}
+	for {
+		prompt := fmt.Sprintf("%s (%s)", label, hint)
+		if fallback != "" {
+			prompt += fmt.Sprintf(" [%s]", fallback)
+		}
+		answer, err := b.ask(prompt)
+		if err != nil {
+			return nil, false, err
+		}
+		if answer == "" {
+			if !required {
+				return nil, false, nil
+			}
+			if fallback == "" && schema.Type != "string" {
+				fmt.Fprintf(b.w, "  %s is required\n", label)
+				continue
+			}
+			answer = fallback
+		}
+		value, err := parseAnswer(schema, answer)
+		if err != nil {
+			fmt.Fprintf(b.w, "  %v\n", err)
+			continue
+		}
+		return value, true, nil
+	}
+}
+
+// object asks for each property in turn, required ones first.
+func (b *builder) object(path string, schema *jsonschema.Schema, required bool) (interface{}, bool, error) {
+	if !required {
+		include, err := b.confirm(fmt.Sprintf("Set %s (optional object)?", display(path)))
+		if err != nil || !include {
+			return nil, false, err
+		}
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		ri, rj := contains(schema.Required, names[i]), contains(schema.Required, names[j])
+		if ri != rj {
+			return ri
+		}
+		return names[i] < names[j]
+	})
+
+	fields := map[string]interface{}{}
+	for _, name := range names {
+		value, set, err := b.value(join(path, name), schema.Properties[name], contains(schema.Required, name))
+		if err != nil {
+			return nil, false, err
+		}
+		if set {
+			fields[name] = value
+		}
+	}
+	return fields, true, nil
+}
+
+// mapping asks for keys until an empty one, and the value of each.
+func (b *builder) mapping(path string, schema *jsonschema.Schema, required bool) (interface{}, bool, error) {
+	fields := map[string]interface{}{}
+	for {
+		key, err := b.ask(fmt.Sprintf("%s: next key (Enter to finish)", display(path)))
+		if err != nil {
+			return nil, false, err
+		}
+		if key == "" {
+			break
+		}
+		valueSchema := schema.AdditionalProperties
+		if valueSchema == nil {
+			valueSchema = &jsonschema.Schema{}
+		}
+		value, _, err := b.value(join(path, key), valueSchema, true)
+		if err != nil {
+			return nil, false, err
+		}
+		fields[key] = value
+	}
+	if len(fields) == 0 && !required {
+		return nil, false, nil
+	}
+	return fields, true, nil
+}
+
+// array asks for items until the answer to another one is no.
+func (b *builder) array(path string, schema *jsonschema.Schema, required bool) (interface{}, bool, error) {
+	itemSchema := schema.Items
+	if itemSchema == nil {
+		itemSchema = &jsonschema.Schema{}
+	}
+	items := []interface{}{}
+	for {
+		more, err := b.confirm(fmt.Sprintf("Add an item to %s (%d so far)?", display(path), len(items)))
+		if err != nil {
+			return nil, false, err
+		}
+		if !more {
+			break
+		}
+		value, _, err := b.value(fmt.Sprintf("%s[%d]", path, len(items)), itemSchema, true)
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, value)
+	}
+	if len(items) == 0 && !required {
+		return nil, false, nil
+	}
+	return items, true, nil
+}
+
+func (b *builder) confirm(prompt string) (bool, error) {
+	for {
+		answer, err := b.ask(prompt + " [y/N]")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "", "n", "no":
+			return false, nil
+		case "y", "yes":
+			return true, nil
+		}
+		fmt.Fprintln(b.w, "  answer y or n")
+	}
+}
+
+func (b *builder) ask(prompt string) (string, error) {
+	fmt.Fprintf(b.w, "%s: ", prompt)
+	if !b.in.Scan() {
+		fmt.Fprintln(b.w)
+		if err := b.in.Err(); err != nil {
+			return "", err
+		}
+		return "", errInputEnded
+	}
+	return strings.TrimSpace(b.in.Text()), nil
+}
+
+// parseAnswer converts an answer to the JSON value the schema describes, or
+// explains why it is not one.
+func parseAnswer(schema *jsonschema.Schema, answer string) (interface{}, error) {
+	switch {
+	case schema.Format == "date-time":
+		if _, err := time.Parse(time.RFC3339, answer); err != nil {
+			return nil, fmt.Errorf("%q is not an RFC 3339 time, e.g. 2024-05-01T12:00:00Z", answer)
+		}
+		return answer, nil
+	case schema.Type == "string":
+		return answer, nil
+	case schema.Type == "boolean":
+		value, err := strconv.ParseBool(answer)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", answer)
+		}
+		return value, nil
+	case schema.Type == "integer":
+		value, err := strconv.ParseInt(answer, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a whole number", answer)
+		}
+		return value, nil
+	case schema.Type == "number":
+		value, err := strconv.ParseFloat(answer, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", answer)
+		}
+		return value, nil
+	}
+	if !json.Valid([]byte(answer)) {
+		return nil, fmt.Errorf("%q is not valid JSON; quote strings", answer)
+	}
+	return json.RawMessage(answer), nil
+}
+
+// saveFixture writes the payload, indented, to dir/name.json unless a
+// fixture of that name exists, and returns its path.
+func saveFixture(dir, name string, payload []byte) (string, error) {
+	name = strings.TrimSuffix(name, ".json")
+	if !fixtureName.MatchString(name) {
+		return "", fmt.Errorf("fixture name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	var indented strings.Builder
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return "", err
+	}
+	encoder := json.NewEncoder(&indented)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating fixture directory: %v", err)
+	}
+	path := filepath.Join(dir, name+".json")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("%s already exists; pick another name", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error saving fixture: %v", err)
+	}
+	if _, err := f.WriteString(indented.String()); err != nil {
+		f.Close()
+		return "", fmt.Errorf("error saving fixture: %v", err)
+	}
+	return path, f.Close()
+}
+
+func display(path string) string {
+	if path == "" {
+		return "payload"
+	}
+	return path
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package invoke
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/emulator"
+	"example-lambda-go/internal/hostexec"
+)
+
+// Local builds the function's image and invokes it on this machine through
+// the Runtime Interface Emulator that the Lambda base images include, so a
+// change can be tried without pushing it to ECR.
+func Local(args []string) {
+	flags := flag.NewFlagSet("lambda-template local", flag.ExitOnError)
+	name := flags.String("name", "", "Name to pass to the Lambda function")
+	payloadFlag := flags.String("payload", "", "Send this JSON event instead of a greeting, or - to read it from stdin")
+	payloadFile := flags.String("payload-file", "", "Send the JSON event in this file instead of a greeting")
+	port := flags.Int("port", 9000, "Host port to publish the emulator on")
+	noBuild := flags.Bool("no-build", false, "Run the image the previous local run built")
+	var env []string
+	flags.Func("env", "Set a variable in the function's environment, as KEY=VALUE; repeatable", func(value string) error {
+		if !strings.Contains(value, "=") {
+			return fmt.Errorf("want KEY=VALUE, got %q", value)
+		}
+		env = append(env, value)
+		return nil
+	})
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	payload, err := readPayload(*name, *payloadFlag, *payloadFile, os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	image := cfg.ECR.RepositoryName + ":local"
+	if !*noBuild {
+		if err := buildLocal(cfg, image); err != nil {
+			log.Fatal(err)
+		}
+	}
+	env = append(localEnvironment(context.TODO(), cfg), env...)
+	if err := runLocal(context.TODO(), image, cfg.Build.Go.Arch(), *port, env, cfg.Lambda.Timeout, payload, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func buildLocal(cfg *config.Config, image string) error {
+	opts, cleanup, err := cfg.BuildOptions(os.Stdout)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	buildCmd := hostexec.DockerBuild(image, opts)
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	if err := hostexec.Run(buildCmd); err != nil {
+		return fmt.Errorf("failed to build Docker image: %v", err)
+	}
+	return nil
+}
+
+// localEnvironment is what Lambda would give the function: its name, limits
+// and region, and credentials of the configured profile so the handler can
+// reach AWS. Without credentials the handler still runs.
+func localEnvironment(ctx context.Context, cfg *config.Config) []string {
+	env := []string{
+		"AWS_REGION=" + cfg.AWS.Region,
+		"AWS_DEFAULT_REGION=" + cfg.AWS.Region,
+		"AWS_LAMBDA_FUNCTION_NAME=" + cfg.Lambda.FunctionName,
+	}
+	if cfg.Lambda.Timeout > 0 {
+		env = append(env, "AWS_LAMBDA_FUNCTION_TIMEOUT="+strconv.Itoa(cfg.Lambda.Timeout))
+	}
+	if cfg.Lambda.MemorySize > 0 {
+		env = append(env, "AWS_LAMBDA_FUNCTION_MEMORY_SIZE="+strconv.Itoa(cfg.Lambda.MemorySize))
+	}
+
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err == nil {
+		var creds aws.Credentials
+		creds, err = awsCfg.Credentials.Retrieve(ctx)
+		if err == nil {
+			env = append(env, "AWS_ACCESS_KEY_ID="+creds.AccessKeyID, "AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey)
+			if creds.SessionToken != "" {
+				env = append(env, "AWS_SESSION_TOKEN="+creds.SessionToken)
+			}
+			return env
+		}
+	}
+	fmt.Printf("Running without AWS credentials (%v); calls the handler makes to AWS will fail\n", err)
+	return env
+}
+
+// runLocal starts the image with the emulator, invokes it once with payload
+// and prints the response and the container's logs. The container is
+// removed afterwards, whatever happens. arm64 images have no emulator in
+// their entrypoint, see emulator.Start.
+func runLocal(ctx context.Context, image, arch string, port int, env []string, timeout int, payload []byte, w io.Writer) error {
+	container, err := emulator.Start(image, port, env, arch)
+	if err != nil {
+		return err
+	}
+	defer emulator.Stop(container)
+
+	if timeout <= 0 {
+		timeout = 3 // Lambda's default
+	}
+	client := &http.Client{Timeout: time.Duration(timeout)*time.Second + 10*time.Second}
+	response, status, err := emulator.Invoke(ctx, client, fmt.Sprintf("http://127.0.0.1:%d", port), payload)
+
+	// The logs end with the REPORT line, and explain a failure to start
+	logsCmd := exec.Command("docker", "logs", container)
+	if logs, logsErr := hostexec.CombinedOutput(logsCmd); logsErr == nil && len(logs) > 0 {
+		fmt.Fprintln(w, "Function logs:")
+		fmt.Fprintln(w, strings.TrimRight(string(logs), "\n"))
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Lambda function response:")
+	fmt.Fprintln(w, string(response))
+	if status >= 300 {
+		return fmt.Errorf("the emulator returned status %d", status)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package invoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/client"
+	"example-lambda-go/contract"
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+	"example-lambda-go/internal/jsonschema"
+	"example-lambda-go/internal/lambdareport"
+)
+
+type LambdaEvent = contract.GreetRequest
+
+// Main invokes the function with a greeting for -name, with any JSON event
+// from -payload or -payload-file, or with one built from prompts with
+// -interactive, and prints the response.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template invoke", flag.ExitOnError)
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Parse command-line arguments
+	name := flags.String("name", "", "Name to pass to the Lambda function")
+	payloadFlag := flags.String("payload", "", "Send this JSON event instead of a greeting, or - to read it from stdin")
+	payloadFile := flags.String("payload-file", "", "Send the JSON event in this file instead of a greeting, e.g. an S3 or SQS test event")
+	url := flags.String("url", "", "Call this function URL, which must be the one of the alias invoked (or \"auto\" to look it up), with SigV4 signing instead of the Invoke API")
+	alias := flags.String("alias", cfg.Deploy.Alias, "Invoke this alias from config.yaml (e.g. canary); defaults to deploy.alias, or the only alias")
+	latest := flags.Bool("latest", false, "Invoke $LATEST, the unpublished code, instead of the alias")
+	iKnow := flags.Bool("i-know", false, "With -latest, invoke $LATEST even where deploy.protected is set")
+	typeFlag := flags.String("invocation-type", "request", "request waits for the response, event queues the invocation and returns, dryrun only checks that you may invoke the function")
+	logs := flags.Bool("logs", false, "Print the last 4 KB of the invocation's logs, its billed duration and the memory it used")
+	interactive := flags.Bool("interactive", false, "Build the payload field by field from the JSON Schema of the operation's request")
+	operation := flags.String("operation", "", "With -interactive, the operation in contract.Operations to build a request for; defaults to the only one")
+	fixtures := flags.String("fixtures", "events", "With -interactive, the directory to save the payload in as a named fixture")
+	flags.Parse(args)
+	var payload []byte
+	if *interactive {
+		if *name != "" || *payloadFlag != "" || *payloadFile != "" {
+			log.Fatal("-interactive builds the payload; drop -name, -payload and -payload-file")
+		}
+		var schema *jsonschema.Schema
+		schema, err = operationSchema(*operation)
+		if err == nil {
+			// Prompts go to stderr, so the response can still be piped
+			payload, err = buildPayload(os.Stdin, os.Stderr, schema, *fixtures)
+		}
+	} else {
+		payload, err = readPayload(*name, *payloadFlag, *payloadFile, os.Stdin)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	invocationType, err := parseInvocationType(*typeFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *url != "" && invocationType != lambdatypes.InvocationTypeRequestResponse {
+		log.Fatal("-url calls the function URL, which only answers synchronously; drop -url or -invocation-type")
+	}
+	if *logs && (*url != "" || invocationType != lambdatypes.InvocationTypeRequestResponse) {
+		log.Fatal("-logs needs a synchronous call through the Invoke API; drop -url and -invocation-type")
+	}
+	qualifier, err := resolveQualifier(cfg, *alias, *latest, *iKnow)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	// Create Lambda client
+	client := lambda.NewFromConfig(awsCfg)
+
+	if *url != "" {
+		httpClient, err := cfg.HTTPClient(30 * time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+		target, err := functionURL(context.TODO(), client, cfg.Lambda.FunctionName, qualifier, *url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printTarget(cfg.Lambda.FunctionName, qualifier)
+		if err := invokeURL(awsCfg, httpClient.Transport, target, payload); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Invoke Lambda function
+	input := &lambda.InvokeInput{
+		FunctionName:   aws.String(cfg.Lambda.FunctionName),
+		Payload:        payload,
+		InvocationType: invocationType,
+	}
+	if qualifier != "" {
+		input.Qualifier = aws.String(qualifier)
+	}
+	printTarget(cfg.Lambda.FunctionName, qualifier)
+	if *logs {
+		input.LogType = lambdatypes.LogTypeTail
+	}
+	result, err := client.Invoke(context.TODO(), input)
+	if err != nil {
+		log.Fatalf("Error invoking Lambda function: %v", err)
+	}
+	if console.Show {
+		// With the note above, out of the way of a piped response
+		c := console.New(cfg.Partition(), cfg.AWS.Region)
+		console.Print(os.Stderr, c.Function(cfg.Lambda.FunctionName, strings.TrimPrefix(qualifier, "$LATEST")), c.Logs(cfg.Lambda.FunctionName))
+	}
+
+	// Neither type runs the handler before returning, so there is no response
+	switch invocationType {
+	case lambdatypes.InvocationTypeEvent:
+		fmt.Printf("Invocation queued (status %d); follow it with `lambda-template logs -follow`\n", result.StatusCode)
+		return
+	case lambdatypes.InvocationTypeDryRun:
+		fmt.Printf("Dry run succeeded (status %d): the payload is accepted and these credentials may invoke the function\n", result.StatusCode)
+		return
+	}
+
+	// Print the Lambda function response
+	fmt.Println("Lambda function response:")
+	fmt.Println(string(result.Payload))
+
+	if *logs {
+		printLogs(os.Stdout, aws.ToString(result.LogResult))
+	}
+
+	// Check if there was a function error
+	if result.FunctionError != nil {
+		fmt.Printf("Lambda function error: %s\n", *result.FunctionError)
+		log.Fatal("Lambda function returned an error")
+	}
+}
+
+// resolveQualifier returns the alias to invoke, or $LATEST, and "" for the
+// unqualified function when no aliases are in use. Without -alias, it is
+// deploy.alias or the only alias configured. Where aliases are in use,
+// $LATEST is rarely what was deployed, so in a deploy.protected environment
+// it takes -latest -i-know.
+func resolveQualifier(cfg *config.Config, alias string, latest, iKnow bool) (string, error) {
+	if alias == "$LATEST" {
+		latest = true
+	}
+	names := cfg.AliasNames()
+	if !latest {
+		switch {
+		case alias != "" || len(names) == 0:
+			return alias, nil
+		case len(names) == 1:
+			return names[0], nil
+		}
+		return "", fmt.Errorf("no deploy.alias to invoke by default: give -alias with one of %s, or -latest", strings.Join(names, ", "))
+	}
+	if len(cfg.Aliases) > 0 && cfg.Deploy.Protected && !iKnow {
+		return "", fmt.Errorf("%s is protected: $LATEST runs code no alias points at; add -i-know to invoke it anyway", environmentName())
+	}
+	return "$LATEST", nil
+}
+
+// printTarget says which code the invoke runs, on stderr so the response can
+// still be piped.
+func printTarget(functionName, qualifier string) {
+	if qualifier == "" || qualifier == "$LATEST" {
+		fmt.Fprintf(os.Stderr, "Invoking %s at $LATEST, the unpublished code\n", functionName)
+	} else {
+		fmt.Fprintf(os.Stderr, "Invoking %s:%s\n", functionName, qualifier)
+	}
+}
+
+func environmentName() string {
+	if config.Env != "" {
+		return "environment " + config.Env
+	}
+	return "this environment"
+}
+
+// readPayload returns the event to invoke with: a greeting for name, the JSON
+// in payload, read from stdin when it is -, or the JSON in payloadFile.
+// Exactly one of them must be given.
+func readPayload(name, payload, payloadFile string, stdin io.Reader) ([]byte, error) {
+	given := 0
+	for _, value := range []string{name, payload, payloadFile} {
+		if value != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, fmt.Errorf("give exactly one of -name, -payload and -payload-file")
+	}
+
+	var data []byte
+	var err error
+	source := "-payload"
+	switch {
+	case name != "":
+		data, err = json.Marshal(LambdaEvent{Name: name})
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling Lambda event: %v", err)
+		}
+		return data, nil
+	case payload == "-":
+		source = "stdin"
+		data, err = io.ReadAll(stdin)
+	case payload != "":
+		data = []byte(payload)
+	default:
+		source = payloadFile
+		data, err = os.ReadFile(payloadFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading payload from %s: %v", source, err)
+	}
+	// Lambda rejects anything else with a less helpful InvalidRequestContentException
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("payload from %s is not valid JSON", source)
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+// parseInvocationType reads -invocation-type, which also takes the API's
+// names, e.g. RequestResponse.
+func parseInvocationType(value string) (lambdatypes.InvocationType, error) {
+	switch strings.ToLower(value) {
+	case "request", "requestresponse":
+		return lambdatypes.InvocationTypeRequestResponse, nil
+	case "event":
+		return lambdatypes.InvocationTypeEvent, nil
+	case "dryrun":
+		return lambdatypes.InvocationTypeDryRun, nil
+	}
+	return "", fmt.Errorf("-invocation-type %q: want request, event or dryrun", value)
+}
+
+// printLogs prints the log tail Lambda returns for LogType Tail, followed by
+// the billed duration and memory from its REPORT line.
+func printLogs(w io.Writer, logResult string) {
+	tail, err := base64.StdEncoding.DecodeString(logResult)
+	if err != nil {
+		fmt.Fprintf(w, "Could not decode the execution logs: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, "Execution logs (last 4 KB):")
+	fmt.Fprintln(w, strings.TrimRight(string(tail), "\n"))
+	if r, ok := lambdareport.Parse(string(tail)); ok {
+		billed, hasBilled := r.Number("Billed Duration")
+		used, hasUsed := r.Number("Max Memory Used")
+		size, hasSize := r.Number("Memory Size")
+		if hasBilled && hasUsed && hasSize {
+			fmt.Fprintf(w, "Billed duration: %s ms, max memory used: %s of %s MB\n", billed, used, size)
+		}
+	}
+}
+
+type urlConfigAPI interface {
+	GetFunctionUrlConfig(ctx context.Context, params *lambda.GetFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionUrlConfigOutput, error)
+}
+
+// functionURL returns the URL of the qualifier resolveQualifier chose: the
+// alias's own URL, or the function's, which runs $LATEST. A URL given with
+// -url must be that one, so that it can't reach code the qualifier and the
+// protected-$LATEST check would not.
+func functionURL(ctx context.Context, lambdaClient urlConfigAPI, functionName, qualifier, url string) (string, error) {
+	input := &lambda.GetFunctionUrlConfigInput{FunctionName: aws.String(functionName)}
+	name := functionName
+	if qualifier != "" && qualifier != "$LATEST" {
+		input.Qualifier = aws.String(qualifier)
+		name += ":" + qualifier
+	}
+	urlConfig, err := lambdaClient.GetFunctionUrlConfig(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("error looking up the function URL of %s: %v", name, err)
+	}
+	want := aws.ToString(urlConfig.FunctionUrl)
+	if url != "auto" && strings.TrimSuffix(url, "/") != strings.TrimSuffix(want, "/") {
+		return "", fmt.Errorf("%s is not the function URL of %s, %s; choose the alias with -alias or -latest", url, name, want)
+	}
+	return want, nil
+}
+
+// invokeURL POSTs the payload to the function URL, signed for AuthType
+// AWS_IAM and sent through transport.
+func invokeURL(awsCfg aws.Config, transport http.RoundTripper, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(context.TODO(), http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid function URL: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	urlClient := client.NewURLClient(awsCfg)
+	urlClient.Transport.(*client.SigV4Transport).Base = transport
+	resp, err := urlClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling function URL: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %v", err)
+	}
+
+	fmt.Printf("Function URL response (%s):\n", resp.Status)
+	fmt.Println(string(body))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("function URL returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package invoke
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/emulator"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/jsonschema"
+)
+
+func TestReadPayload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(file, []byte(`{"Records": [{"eventSource": "aws:sqs"}]}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name, payload, payloadFile, stdin string
+		want                              string
+	}{
+		{name: "Ada", want: `{"name":"Ada"}`},
+		{payload: `{"rawPath": "/hello"}`, want: `{"rawPath": "/hello"}`},
+		{payload: "-", stdin: " [1, 2]\n", want: "[1, 2]"},
+		{payloadFile: file, want: `{"Records": [{"eventSource": "aws:sqs"}]}`},
+	} {
+		got, err := readPayload(test.name, test.payload, test.payloadFile, strings.NewReader(test.stdin))
+		if err != nil {
+			t.Errorf("readPayload(%q, %q, %q) error: %v", test.name, test.payload, test.payloadFile, err)
+		} else if string(got) != test.want {
+			t.Errorf("readPayload(%q, %q, %q) = %s, want %s", test.name, test.payload, test.payloadFile, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		name, payload, payloadFile, want string
+	}{
+		{want: "exactly one"},
+		{name: "Ada", payload: "{}", want: "exactly one"},
+		{payload: "{name: Ada}", want: "not valid JSON"},
+		{payloadFile: filepath.Join(t.TempDir(), "missing.json"), want: "missing.json"},
+	} {
+		if _, err := readPayload(test.name, test.payload, test.payloadFile, strings.NewReader("")); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("readPayload(%q, %q, %q) error = %v, want %q", test.name, test.payload, test.payloadFile, err, test.want)
+		}
+	}
+}
+
+func TestPrintLogs(t *testing.T) {
+	tail := "START RequestId: abc Version: $LATEST\n" +
+		"END RequestId: abc\n" +
+		"REPORT RequestId: abc\tDuration: 12.34 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 31 MB\t\n"
+	var out strings.Builder
+	printLogs(&out, base64.StdEncoding.EncodeToString([]byte(tail)))
+	if want := "Billed duration: 13 ms, max memory used: 31 of 128 MB\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("printLogs = %q, want it to end with %q", out.String(), want)
+	}
+}
+
+func TestRunLocal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"message":"Hello, Ada!"}`)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	fake := hostexec.NewFake()
+	previous := hostexec.Default
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default = previous })
+	fake.On([]string{"docker", "run"}, hostexec.Response{Output: []byte("c0ffee\n")})
+	fake.On([]string{"docker", "logs"}, hostexec.Response{Output: []byte("START RequestId: abc\nREPORT RequestId: abc\tDuration: 1.00 ms\n")})
+
+	var out strings.Builder
+	env := []string{"AWS_REGION=us-west-2", "AWS_SECRET_ACCESS_KEY=secret"}
+	if err := runLocal(context.Background(), "hello:local", "arm64", port, env, 3, []byte(`{"name":"Ada"}`), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		fmt.Sprintf("docker run --detach --rm --publish 127.0.0.1:%d:8080 --env AWS_REGION --env AWS_SECRET_ACCESS_KEY --platform linux/arm64 --entrypoint %s hello:local /var/task/main", port, emulator.RIE),
+		"docker logs c0ffee",
+		"docker stop c0ffee",
+	}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if !strings.Contains(out.String(), "REPORT RequestId: abc") || !strings.Contains(out.String(), `{"message":"Hello, Ada!"}`) {
+		t.Errorf("output should have the logs and the response:\n%s", out.String())
+	}
+}
+
+func TestResolveQualifier(t *testing.T) {
+	withAliases := func(protected bool, names ...string) *config.Config {
+		cfg := &config.Config{Aliases: map[string]config.Alias{}}
+		for _, name := range names {
+			cfg.Aliases[name] = config.Alias{}
+		}
+		cfg.Deploy.Protected = protected
+		return cfg
+	}
+	for _, test := range []struct {
+		cfg           *config.Config
+		alias         string
+		latest, iKnow bool
+		want, err     string
+	}{
+		{cfg: withAliases(false), want: ""},
+		{cfg: withAliases(true), latest: true, want: "$LATEST"},
+		{cfg: withAliases(false, "live"), alias: "live", want: "live"},
+		{cfg: withAliases(false, "live"), want: "live"},
+		{cfg: withAliases(false, "canary", "live"), alias: "canary", want: "canary"},
+		{cfg: withAliases(false, "canary", "live"), err: "one of canary, live"},
+		{cfg: withAliases(false, "live"), alias: "live", latest: true, want: "$LATEST"},
+		{cfg: withAliases(true, "live"), alias: "live", latest: true, err: "-i-know"},
+		{cfg: withAliases(true, "live"), alias: "$LATEST", err: "-i-know"},
+		{cfg: withAliases(true, "live"), alias: "live", latest: true, iKnow: true, want: "$LATEST"},
+	} {
+		got, err := resolveQualifier(test.cfg, test.alias, test.latest, test.iKnow)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("resolveQualifier(%v, %q, %v, %v) error = %v, want one mentioning %s", test.cfg.AliasNames(), test.alias, test.latest, test.iKnow, err, test.err)
+			}
+		} else if err != nil || got != test.want {
+			t.Errorf("resolveQualifier(%v, %q, %v, %v) = %q, %v; want %q", test.cfg.AliasNames(), test.alias, test.latest, test.iKnow, got, err, test.want)
+		}
+	}
+}
+
+func TestBuildPayload(t *testing.T) {
+	schema := &jsonschema.Schema{
+		Title: "OrderRequest",
+		Type:  "object",
+		Properties: map[string]*jsonschema.Schema{
+			"id":       {Type: "integer"},
+			"express":  {Type: "boolean"},
+			"note":     {Type: "string"},
+			"tags":     {Type: "array", Items: &jsonschema.Schema{Type: "string"}},
+			"customer": {Type: "object", Properties: map[string]*jsonschema.Schema{"name": {Type: "string"}}, Required: []string{"name"}},
+		},
+		Required: []string{"id", "express", "customer"},
+	}
+	dir := t.TempDir()
+	// Required fields come first, in name order: customer.name, express, id;
+	// then note and tags. "seven" is rejected and asked again.
+	answers := strings.Join([]string{"Ada", "", "seven", "7", "", "y", "gift", "n", "../order", "order"}, "\n") + "\n"
+	var prompts strings.Builder
+	got, err := buildPayload(strings.NewReader(answers), &prompts, schema, dir)
+	if err != nil {
+		t.Fatalf("buildPayload error: %v\n%s", err, prompts.String())
+	}
+	want := `{"customer":{"name":"Ada"},"express":false,"id":7,"tags":["gift"]}`
+	if string(got) != want {
+		t.Errorf("buildPayload = %s, want %s", got, want)
+	}
+	for _, prompt := range []string{`"seven" is not a whole number`, "fixture name \"../order\"", "Saved " + filepath.Join(dir, "order.json")} {
+		if !strings.Contains(prompts.String(), prompt) {
+			t.Errorf("prompts missing %q:\n%s", prompt, prompts.String())
+		}
+	}
+	saved, err := readPayload("", "", filepath.Join(dir, "order.json"), nil)
+	var compact bytes.Buffer
+	if err != nil || json.Compact(&compact, saved) != nil || compact.String() != want {
+		t.Errorf("saved fixture = %s, %v; want %s", saved, err, want)
+	}
+
+	// Input that ends early fails rather than sending half a payload
+	if _, err := buildPayload(strings.NewReader("Ada\n"), io.Discard, schema, dir); err == nil {
+		t.Error("buildPayload with too few answers succeeded")
+	}
+}
+
+type urlConfigs map[string]string
+
+func (u urlConfigs) GetFunctionUrlConfig(ctx context.Context, params *lambda.GetFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionUrlConfigOutput, error) {
+	url, ok := u[aws.ToString(params.Qualifier)]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &lambda.GetFunctionUrlConfigOutput{FunctionUrl: aws.String(url)}, nil
+}
+
+func TestFunctionURLFollowsQualifier(t *testing.T) {
+	urls := urlConfigs{"": "https://latest.lambda-url.us-east-1.on.aws/", "live": "https://live.lambda-url.us-east-1.on.aws/"}
+	for _, test := range []struct {
+		qualifier, url string
+		want, err      string
+	}{
+		{qualifier: "live", url: "auto", want: urls["live"]},
+		{qualifier: "$LATEST", url: "auto", want: urls[""]},
+		{qualifier: "", url: "https://latest.lambda-url.us-east-1.on.aws", want: urls[""]},
+		{qualifier: "live", url: urls[""], err: "is not the function URL of hello:live"},
+		{qualifier: "canary", url: "auto", err: "hello:canary"},
+	} {
+		got, err := functionURL(context.Background(), urls, "hello", test.qualifier, test.url)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("functionURL(%q, %q) = %v, want %q", test.qualifier, test.url, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("functionURL(%q, %q) = %q, %v; want %q", test.qualifier, test.url, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"example-lambda-go/internal/slo"
+)
+
+// errorFilter narrows the events read to those that may be errors: lines
+// logged at ERROR, errors the runtime reports, panics, timeouts and crashes.
+// parseError then decides.
+const errorFilter = `?ERROR ?errorMessage ?panic ?"Task timed out" ?"Runtime exited"`
+
+// Errors groups the errors the function logged recently by their cause, to
+// answer what is failing right now: how often each has happened, when it
+// started and stopped, and requests to look into.
+func Errors(args []string) {
+	flags := flag.NewFlagSet("lambda-template errors", flag.ExitOnError)
+	sinceFlag := flags.String("since", "1h", "How far back to look, e.g. 15m, 6h or 7d")
+	top := flags.Int("top", 10, "Number of clusters to print, most frequent first; 0 prints all")
+	examples := flags.Int("examples", 3, "Number of request IDs to print for each cluster")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template errors [-since 1h] [-top 10] [-examples 3]")
+		fmt.Fprintln(os.Stderr, "Groups the error lines in the function's logs by their message with IDs, numbers")
+		fmt.Fprintln(os.Stderr, "and quoted values left out, and by the top of their stack trace.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	since, err := slo.ParseWindow(*sinceFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sources, err := loadSources(context.TODO(), "", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := &tailer{sources: sources, filter: errorFilter}
+	events, err := t.fetch(context.TODO(), time.Now().Add(-since))
+	if err != nil {
+		log.Fatal(err)
+	}
+	clusters := clusterErrors(events, *examples)
+	printClusters(os.Stdout, clusters, *top, *sinceFlag)
+}
+
+// cluster is the errors with one signature.
+type cluster struct {
+	signature   string
+	count       int
+	first, last time.Time
+	requests    []string
+}
+
+// clusterErrors groups the error events by signature, most frequent first,
+// keeping up to examples distinct request IDs of each.
+func clusterErrors(events []event, examples int) []*cluster {
+	bySignature := map[string]*cluster{}
+	var clusters []*cluster
+	for _, e := range events {
+		message := strings.TrimRight(aws.ToString(e.Message), "\n")
+		signature, requestID, ok := parseError(message)
+		if !ok {
+			continue
+		}
+		at := time.UnixMilli(aws.ToInt64(e.Timestamp))
+		c := bySignature[signature]
+		if c == nil {
+			c = &cluster{signature: signature, first: at}
+			bySignature[signature] = c
+			clusters = append(clusters, c)
+		}
+		c.count++
+		if at.Before(c.first) {
+			c.first = at
+		}
+		if at.After(c.last) {
+			c.last = at
+		}
+		if requestID != "" && len(c.requests) < examples && !contains(c.requests, requestID) {
+			c.requests = append(c.requests, requestID)
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if clusters[i].count != clusters[j].count {
+			return clusters[i].count > clusters[j].count
+		}
+		return clusters[i].last.After(clusters[j].last)
+	})
+	return clusters
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	quotedPattern = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	hexPattern    = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{8,}\b`)
+	numberPattern = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	// textPrefix is what Lambda puts before a line in its text log format:
+	// the time, the request ID and the level
+	textPrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T[\d:.]+Z\s+(\S+\s+)?((ERROR|WARN|INFO|DEBUG)\s+)?`)
+)
+
+// parseError returns the signature of the error a log line reports and the
+// ID of the request it was logged for, or false when the line is not an
+// error.
+func parseError(line string) (signature, requestID string, ok bool) {
+	var fields map[string]interface{}
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &fields) == nil {
+		return parseJSONError(fields)
+	}
+
+	requestID = uuidPattern.FindString(line)
+	switch {
+	case strings.Contains(line, "Task timed out"), strings.Contains(line, "Runtime exited"), strings.Contains(line, "panic:"):
+	case strings.Contains(line, "ERROR"):
+	default:
+		return "", "", false
+	}
+	message := textPrefix.ReplaceAllString(line, "")
+	if i := strings.Index(message, "panic:"); i >= 0 {
+		message = message[i:]
+	}
+	return normalize(message), requestID, true
+}
+
+// parseJSONError reads the fields of a line from log/slog, Lambda's JSON log
+// format or the runtime's error report.
+func parseJSONError(fields map[string]interface{}) (signature, requestID string, ok bool) {
+	for _, key := range []string{"requestId", "request_id", "AWSRequestId"} {
+		if id, found := fields[key].(string); found {
+			requestID = id
+			break
+		}
+	}
+
+	if errorMessage, found := fields["errorMessage"].(string); found {
+		signature = errorMessage
+		if errorType, _ := fields["errorType"].(string); errorType != "" {
+			signature = errorType + ": " + errorMessage
+		}
+		signature = normalize(signature)
+		if frame := topFrame(fields["stackTrace"]); frame != "" {
+			signature += " at " + frame
+		}
+		return signature, requestID, true
+	}
+
+	level, _ := fields["level"].(string)
+	if !strings.EqualFold(level, "ERROR") && !strings.EqualFold(level, "FATAL") {
+		return "", "", false
+	}
+	message, found := fields["msg"].(string)
+	if !found {
+		message, _ = fields["message"].(string)
+	}
+	if cause, _ := fields["error"].(string); cause != "" {
+		message += ": " + cause
+	}
+	return normalize(message), requestID, true
+}
+
+// topFrame returns the function and file of the first entry of the
+// runtime's stack trace, without its line, which changes with every edit.
+func topFrame(trace interface{}) string {
+	frames, _ := trace.([]interface{})
+	if len(frames) == 0 {
+		return ""
+	}
+	switch frame := frames[0].(type) {
+	case map[string]interface{}:
+		label, _ := frame["label"].(string)
+		path, _ := frame["path"].(string)
+		return strings.TrimSpace(label + " " + path)
+	case string:
+		return numberPattern.ReplaceAllString(frame, "<n>")
+	}
+	return ""
+}
+
+// normalize leaves out of a message what changes between occurrences of the
+// same error: IDs, quoted values, numbers and hex strings.
+func normalize(message string) string {
+	message = uuidPattern.ReplaceAllString(message, "<id>")
+	message = quotedPattern.ReplaceAllString(message, "<str>")
+	message = numberPattern.ReplaceAllString(message, "<n>")
+	message = hexPattern.ReplaceAllString(message, "<hex>")
+	return strings.Join(strings.Fields(message), " ")
+}
+
+func printClusters(w io.Writer, clusters []*cluster, top int, since string) {
+	total := 0
+	for _, c := range clusters {
+		total += c.count
+	}
+	if total == 0 {
+		fmt.Fprintf(w, "No errors logged in the last %s\n", since)
+		return
+	}
+	fmt.Fprintf(w, "%d errors in %d clusters logged in the last %s\n", total, len(clusters), since)
+	if top > 0 && len(clusters) > top {
+		clusters = clusters[:top]
+	}
+	for _, c := range clusters {
+		fmt.Fprintf(w, "\n%5d  %s\n", c.count, c.signature)
+		fmt.Fprintf(w, "       first %s, last %s\n", c.first.Format("2006-01-02 15:04:05"), c.last.Format("2006-01-02 15:04:05"))
+		if len(c.requests) > 0 {
+			fmt.Fprintf(w, "       requests %s\n", strings.Join(c.requests, ", "))
+		}
+	}
+}
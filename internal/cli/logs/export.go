@@ -0,0 +1,308 @@
+package logs
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/slo"
+)
+
+// exportPollInterval is how often an S3 export task is checked on.
+var exportPollInterval = 5 * time.Second
+
+// exportAPI is the part of the CloudWatch Logs client logs export uses.
+type exportAPI interface {
+	logsAPI
+	CreateExportTask(ctx context.Context, params *cloudwatchlogs.CreateExportTaskInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateExportTaskOutput, error)
+	DescribeExportTasks(ctx context.Context, params *cloudwatchlogs.DescribeExportTasksInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeExportTasksOutput, error)
+}
+
+// export copies the function's raw log events out of CloudWatch Logs, one
+// partition per UTC day, so long windows can be analyzed with other tools
+// instead of slow and costly queries.
+func export(args []string) {
+	flags := flag.NewFlagSet("lambda-template logs export", flag.ExitOnError)
+	sinceFlag := flags.String("since", "1d", "How far back to export, e.g. 12h or 30d")
+	to := flags.String("to", "", "s3://bucket/prefix, a .ndjson or .ndjson.gz file, or a directory ending in / for a file per day")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template logs export [-since 1d] -to s3://bucket/prefix|file.ndjson.gz|dir/")
+		fmt.Fprintln(os.Stderr, "Exports the function's log events of each UTC day in the window. To S3, CloudWatch Logs")
+		fmt.Fprintln(os.Stderr, "writes each day under <prefix>/<function>/<day>; the bucket policy must let")
+		fmt.Fprintln(os.Stderr, "logs.<region>.amazonaws.com write to it. Otherwise the events are written as JSON")
+		fmt.Fprintln(os.Stderr, "lines, gzipped when the file name ends in .gz.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *to == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	since, err := slo.ParseWindow(*sinceFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	client := cloudwatchlogs.NewFromConfig(awsCfg)
+
+	end := time.Now()
+	days := partitions(end.Add(-since), end)
+	// With blue/green both functions serve traffic, so both are exported
+	functions := cfg.DeployedFunctions()
+	if location, ok := strings.CutPrefix(*to, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(location, "/")
+		err = exportToS3(context.TODO(), client, functions, bucket, prefix, days, os.Stdout)
+	} else {
+		err = exportToFiles(context.TODO(), client, functions, *to, days, os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// partition is the part of the window in one UTC day.
+type partition struct {
+	day      string
+	from, to time.Time
+}
+
+// partitions splits the window from start to end at UTC midnights.
+func partitions(start, end time.Time) []partition {
+	var days []partition
+	for from := start.UTC(); from.Before(end); {
+		midnight := time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, time.UTC)
+		to := midnight
+		if end.Before(to) {
+			to = end
+		}
+		days = append(days, partition{day: from.Format("2006-01-02"), from: from, to: to})
+		from = midnight
+	}
+	return days
+}
+
+// exportToS3 runs an export task for each function and day in turn, as
+// CloudWatch Logs runs one per account at a time, and waits for each.
+func exportToS3(ctx context.Context, client exportAPI, functions []string, bucket, prefix string, days []partition, w io.Writer) error {
+	total := len(days) * len(functions)
+	n := 0
+	for _, day := range days {
+		for _, function := range functions {
+			n++
+			group := "/aws/lambda/" + function
+			destination := path.Join(prefix, function, day.day)
+			// To is inclusive, so each day ends a millisecond before the next
+			created, err := client.CreateExportTask(ctx, &cloudwatchlogs.CreateExportTaskInput{
+				LogGroupName:      aws.String(group),
+				From:              aws.Int64(day.from.UnixMilli()),
+				To:                aws.Int64(day.to.UnixMilli() - 1),
+				Destination:       aws.String(bucket),
+				DestinationPrefix: aws.String(destination),
+				TaskName:          aws.String(fmt.Sprintf("%s-%s", function, day.day)),
+			})
+			var notFound *logstypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				// Lambda creates the group on the first invocation
+				fmt.Fprintf(w, "[%d/%d] %s %s: no log group\n", n, total, group, day.day)
+				continue
+			}
+			var limit *logstypes.LimitExceededException
+			if errors.As(err, &limit) {
+				return fmt.Errorf("another export task is running in the account; CloudWatch Logs runs one at a time: %v", err)
+			}
+			if err != nil {
+				return fmt.Errorf("error exporting %s for %s: %v", group, day.day, err)
+			}
+			if err := waitForExport(ctx, client, aws.ToString(created.TaskId)); err != nil {
+				return fmt.Errorf("error exporting %s for %s: %v", group, day.day, err)
+			}
+			fmt.Fprintf(w, "[%d/%d] %s %s: exported to s3://%s/%s\n", n, total, group, day.day, bucket, destination)
+		}
+	}
+	return nil
+}
+
+// waitForExport returns once the task has completed, or an error if it
+// failed or was cancelled.
+func waitForExport(ctx context.Context, client exportAPI, taskID string) error {
+	for {
+		described, err := client.DescribeExportTasks(ctx, &cloudwatchlogs.DescribeExportTasksInput{TaskId: aws.String(taskID)})
+		if err != nil {
+			return fmt.Errorf("error checking export task %s: %v", taskID, err)
+		}
+		if len(described.ExportTasks) == 0 || described.ExportTasks[0].Status == nil {
+			return fmt.Errorf("export task %s not found", taskID)
+		}
+		status := described.ExportTasks[0].Status
+		switch status.Code {
+		case logstypes.ExportTaskStatusCodeCompleted:
+			return nil
+		case logstypes.ExportTaskStatusCodeFailed, logstypes.ExportTaskStatusCodeCancelled, logstypes.ExportTaskStatusCodePendingCancel:
+			return fmt.Errorf("export task %s %s: %s", taskID, strings.ToLower(string(status.Code)), aws.ToString(status.Message))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(exportPollInterval):
+		}
+	}
+}
+
+// exportedEvent is a line of an exported file.
+type exportedEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Function  string `json:"function"`
+	Stream    string `json:"stream"`
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+}
+
+// exportToFiles writes the events of each day to the file at to, or, when to
+// ends in a slash, to <to>/<day>.ndjson.gz. Events are written in the order
+// CloudWatch Logs returns them, function by function.
+func exportToFiles(ctx context.Context, client logsAPI, functions []string, to string, days []partition, w io.Writer) error {
+	partitioned := strings.HasSuffix(to, "/") || strings.HasSuffix(to, string(filepath.Separator))
+	var out *exportFile
+	if !partitioned {
+		var err error
+		if out, err = createExportFile(to); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	for i, day := range days {
+		if partitioned {
+			var err error
+			if out, err = createExportFile(filepath.Join(to, day.day+".ndjson.gz")); err != nil {
+				return err
+			}
+		}
+		count := 0
+		for _, function := range functions {
+			n, err := writeEvents(ctx, client, function, day, out)
+			if err != nil {
+				out.Close()
+				return err
+			}
+			count += n
+		}
+		if partitioned {
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "[%d/%d] %s: %d events written to %s\n", i+1, len(days), day.day, count, out.path)
+	}
+	if !partitioned {
+		return out.Close()
+	}
+	return nil
+}
+
+// writeEvents writes the events the function logged during the day.
+func writeEvents(ctx context.Context, client logsAPI, function string, day partition, out *exportFile) (int, error) {
+	group := "/aws/lambda/" + function
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(client, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(group),
+		StartTime:    aws.Int64(day.from.UnixMilli()),
+		EndTime:      aws.Int64(day.to.UnixMilli() - 1),
+	})
+	encoder := json.NewEncoder(out)
+	count := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		var notFound *logstypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("error reading %s for %s: %v", group, day.day, err)
+		}
+		for _, e := range page.Events {
+			err := encoder.Encode(exportedEvent{
+				Timestamp: aws.ToInt64(e.Timestamp),
+				Function:  function,
+				Stream:    aws.ToString(e.LogStreamName),
+				ID:        aws.ToString(e.EventId),
+				Message:   strings.TrimRight(aws.ToString(e.Message), "\n"),
+			})
+			if err != nil {
+				return count, fmt.Errorf("error writing %s: %v", out.path, err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// exportFile is a file being exported to, gzipped when its name ends in .gz.
+type exportFile struct {
+	path string
+	f    *os.File
+	gz   *gzip.Writer
+}
+
+func createExportFile(name string) (*exportFile, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating %s: %v", filepath.Dir(name), err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s: %v", name, err)
+	}
+	out := &exportFile{path: name, f: f}
+	if strings.HasSuffix(name, ".gz") {
+		out.gz = gzip.NewWriter(f)
+	}
+	return out, nil
+}
+
+func (e *exportFile) Write(p []byte) (int, error) {
+	if e.gz != nil {
+		return e.gz.Write(p)
+	}
+	return e.f.Write(p)
+}
+
+// Close flushes and closes the file; closing it again does nothing.
+func (e *exportFile) Close() error {
+	if e.f == nil {
+		return nil
+	}
+	var err error
+	if e.gz != nil {
+		err = e.gz.Close()
+	}
+	if closeErr := e.f.Close(); err == nil {
+		err = closeErr
+	}
+	e.f = nil
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", e.path, err)
+	}
+	return nil
+}
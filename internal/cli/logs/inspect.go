@@ -0,0 +1,233 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/lambdareport"
+	"example-lambda-go/internal/shadow"
+	"example-lambda-go/internal/slo"
+)
+
+// Inspect prints what is known of one invocation: its log lines and the
+// figures of its REPORT line, its X-Ray trace when tracing is on, and how
+// the shadow's response compared when it was mirrored. Support gets a
+// request ID from a customer; this is the whole picture of it.
+func Inspect(args []string) {
+	flags := flag.NewFlagSet("lambda-template inspect", flag.ExitOnError)
+	sinceFlag := flags.String("since", "24h", "How far back the invocation may be, e.g. 6h or 7d")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template inspect [-since 24h] <request-id>")
+		fmt.Fprintln(os.Stderr, "Finds the invocation's log lines in every log group the function logs to, its X-Ray")
+		fmt.Fprintln(os.Stderr, "trace with lambda.tracing Active, and the shadow comparison with shadow.function set.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	requestID := flags.Arg(0)
+	since, err := slo.ParseWindow(*sinceFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	sources, err := loadSources(context.TODO(), "", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	in := &inspector{
+		requestID: requestID,
+		sources:   sources,
+		shadow:    cfg.Shadow.Function,
+		profile:   cfg.AWS.Profile,
+		region:    cfg.AWS.Region,
+		w:         os.Stdout,
+	}
+	if err := in.run(context.TODO(), time.Now().Add(-since)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type inspector struct {
+	requestID string
+	sources   []*source
+	// shadow is shadow.function, whose comparisons are read when set
+	shadow          string
+	profile, region string
+	w               io.Writer
+}
+
+func (in *inspector) run(ctx context.Context, start time.Time) error {
+	t := &tailer{sources: in.sources, filter: fmt.Sprintf("%q", in.requestID)}
+	events, err := t.fetch(ctx, start)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("no log lines mention request %s since %s; try a longer -since", in.requestID, start.Format("2006-01-02 15:04"))
+	}
+
+	function := events[0].source.function
+	fmt.Fprintf(in.w, "Request %s of %s\n", in.requestID, function)
+	var report lambdareport.Report
+	for _, e := range events {
+		if r, ok := lambdareport.Parse(aws.ToString(e.Message)); ok {
+			report = r
+			break
+		}
+	}
+	if len(report) > 0 {
+		fmt.Fprintln(in.w, "\nInvocation")
+		for _, key := range []string{"Duration", "Billed Duration", "Init Duration", "Memory Size", "Max Memory Used", "Status", "Error Type"} {
+			if value, ok := report[key]; ok {
+				fmt.Fprintf(in.w, "  %-16s %s\n", key, value)
+			}
+		}
+	}
+
+	fmt.Fprintln(in.w, "\nLogs")
+	for _, e := range events {
+		timestamp := time.UnixMilli(aws.ToInt64(e.Timestamp)).Format("2006-01-02 15:04:05.000")
+		message := formatLine(strings.TrimRight(aws.ToString(e.Message), "\n"))
+		// Continuation lines, such as the trace ID after REPORT, line up
+		// with the message
+		message = strings.ReplaceAll(message, "\n", "\n"+strings.Repeat(" ", 2+len(timestamp)+2))
+		fmt.Fprintf(in.w, "  %s  %s\n", timestamp, message)
+	}
+
+	if traceID := report["XRAY TraceId"]; traceID != "" {
+		fmt.Fprintf(in.w, "\nTrace %s\n", traceID)
+		if err := in.printTrace(traceID); err != nil {
+			fmt.Fprintf(in.w, "  %v\n", err)
+		}
+	}
+
+	if in.shadow != "" {
+		if err := in.printComparison(ctx, events[0].source, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segment is the part of an X-Ray segment document the report shows.
+type segment struct {
+	Name        string    `json:"name"`
+	Origin      string    `json:"origin"`
+	StartTime   float64   `json:"start_time"`
+	EndTime     float64   `json:"end_time"`
+	Error       bool      `json:"error"`
+	Fault       bool      `json:"fault"`
+	Throttle    bool      `json:"throttle"`
+	Subsegments []segment `json:"subsegments"`
+}
+
+// printTrace prints the trace's segments and their subsegments as a tree,
+// with durations and failures. X-Ray makes a trace available a few seconds
+// after the invocation ends.
+func (in *inspector) printTrace(traceID string) error {
+	getTraceCmd := exec.Command("aws", "xray", "batch-get-traces",
+		"--trace-ids", traceID,
+		"--query", "Traces[0].Segments[].Document",
+		"--output", "json",
+		"--profile", in.profile,
+		"--region", in.region)
+	output, err := hostexec.CombinedOutput(getTraceCmd)
+	if err != nil {
+		return fmt.Errorf("error getting trace: %v\n%s", err, output)
+	}
+	var documents []string
+	if err := json.Unmarshal(output, &documents); err != nil {
+		return fmt.Errorf("error parsing trace: %v", err)
+	}
+	if len(documents) == 0 {
+		return fmt.Errorf("trace not found; X-Ray keeps traces for 30 days")
+	}
+
+	var segments []segment
+	for _, document := range documents {
+		var s segment
+		if err := json.Unmarshal([]byte(document), &s); err != nil {
+			return fmt.Errorf("error parsing trace segment: %v", err)
+		}
+		segments = append(segments, s)
+	}
+	printSegments(in.w, segments, 1)
+	return nil
+}
+
+func printSegments(w io.Writer, segments []segment, depth int) {
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].StartTime < segments[j].StartTime })
+	for _, s := range segments {
+		name := s.Name
+		if s.Origin != "" {
+			name += " (" + s.Origin + ")"
+		}
+		var failures []string
+		for _, failure := range []struct {
+			set  bool
+			name string
+		}{{s.Fault, "fault"}, {s.Error, "error"}, {s.Throttle, "throttled"}} {
+			if failure.set {
+				failures = append(failures, failure.name)
+			}
+		}
+		line := fmt.Sprintf("%s%s  %.1f ms", strings.Repeat("  ", depth), name, (s.EndTime-s.StartTime)*1000)
+		if len(failures) > 0 {
+			line += "  " + strings.Join(failures, ", ")
+		}
+		fmt.Fprintln(w, line)
+		printSegments(w, s.Subsegments, depth+1)
+	}
+}
+
+// printComparison prints what the shadow logged about the request, when it
+// was mirrored: its response and the primary's, and their timings.
+func (in *inspector) printComparison(ctx context.Context, primary *source, start time.Time) error {
+	group := shadow.LogGroup(in.shadow)
+	t := &tailer{
+		sources: []*source{{function: strings.TrimPrefix(group, "/aws/lambda/"), group: group, logs: primary.logs}},
+		filter:  fmt.Sprintf(`{ $.shadow_id = %q }`, in.requestID),
+	}
+	events, err := t.fetch(ctx, start)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(in.w, "\nShadow comparison")
+	if len(events) == 0 {
+		fmt.Fprintf(in.w, "  not mirrored to %s\n", in.shadow)
+		return nil
+	}
+	var c shadow.Comparison
+	if err := json.Unmarshal([]byte(aws.ToString(events[0].Message)), &c); err != nil {
+		return fmt.Errorf("error parsing shadow comparison: %v", err)
+	}
+	fmt.Fprintf(in.w, "  match %v; primary %d ms, shadow %d ms\n", c.Match, c.PrimaryMS, c.ShadowMS)
+	if c.PrimaryError != "" || c.ShadowError != "" {
+		fmt.Fprintf(in.w, "  primary error: %s\n  shadow error: %s\n", c.PrimaryError, c.ShadowError)
+	}
+	// Responses are only logged when they differ
+	if !c.Match {
+		fmt.Fprintf(in.w, "  primary response: %s\n  shadow response: %s\n", c.PrimaryResponse, c.ShadowResponse)
+	}
+	return nil
+}
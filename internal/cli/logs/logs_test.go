@@ -0,0 +1,312 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// fakeLogs holds the events of each log group; a group without an entry does
+// not exist.
+type fakeLogs map[string][]logstypes.FilteredLogEvent
+
+func (f fakeLogs) FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	events, ok := f[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, &logstypes.ResourceNotFoundException{}
+	}
+	output := &cloudwatchlogs.FilterLogEventsOutput{}
+	for _, e := range events {
+		ts := aws.ToInt64(e.Timestamp)
+		if ts >= aws.ToInt64(params.StartTime) && (params.EndTime == nil || ts <= *params.EndTime) {
+			output.Events = append(output.Events, e)
+		}
+	}
+	return output, nil
+}
+
+func logged(id string, ms int64, message string) logstypes.FilteredLogEvent {
+	return logstypes.FilteredLogEvent{EventId: aws.String(id), Timestamp: aws.Int64(ms), Message: aws.String(message)}
+}
+
+func TestPollPrintsEachEventOnce(t *testing.T) {
+	logs := fakeLogs{
+		"/aws/lambda/hello":       {logged("1", 1000, "START RequestId: abc\n"), logged("3", 3000, "three\n")},
+		"/aws/lambda/hello-green": {logged("2", 2000, "two\n")},
+	}
+	var out strings.Builder
+	tl := &tailer{w: &out}
+	for _, function := range []string{"hello", "hello-green", "never-invoked"} {
+		tl.sources = append(tl.sources, &source{label: function, group: "/aws/lambda/" + function, logs: logs})
+	}
+	ctx := context.Background()
+
+	if err := tl.poll(ctx, time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[0], "hello  START RequestId: abc") || !strings.HasSuffix(lines[1], "hello-green  two") {
+		t.Errorf("first poll printed:\n%s", out.String())
+	}
+
+	// A late event with the last timestamp is printed; the rest are not again
+	logs["/aws/lambda/hello"] = append(logs["/aws/lambda/hello"], logged("4", 3000, "also three\n"))
+	out.Reset()
+	if err := tl.poll(ctx, time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); !strings.HasSuffix(got, "also three") || strings.Count(got, "\n") != 0 {
+		t.Errorf("second poll printed:\n%s", out.String())
+	}
+}
+
+func TestPollMergesRegions(t *testing.T) {
+	east := fakeLogs{"/aws/lambda/hello": {logged("1", 1000, "east one\n"), logged("1b", 3000, "east three\n")}}
+	west := fakeLogs{"/aws/lambda/hello": {logged("1", 2000, "west two\n")}}
+	var out strings.Builder
+	tl := &tailer{w: &out, color: true, sources: []*source{
+		{label: "hello us-east-1", color: "36", group: "/aws/lambda/hello", logs: east},
+		{label: "hello eu-west-1", color: "33", group: "/aws/lambda/hello", logs: west},
+	}}
+	ctx := context.Background()
+
+	if err := tl.poll(ctx, time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{"\x1b[36mhello us-east-1\x1b[0m  east one", "\x1b[33mhello eu-west-1\x1b[0m  west two", "\x1b[36mhello us-east-1\x1b[0m  east three"}
+	if len(lines) != len(want) {
+		t.Fatalf("poll printed:\n%s", out.String())
+	}
+	for i := range want {
+		if !strings.HasSuffix(lines[i], want[i]) {
+			t.Errorf("line %d = %q, want it to end in %q", i, lines[i], want[i])
+		}
+	}
+
+	// Each region resumes from its own newest event: one arriving late in
+	// eu-west-1, older than the newest in us-east-1, is still printed
+	west["/aws/lambda/hello"] = append(west["/aws/lambda/hello"], logged("2", 2500, "west late\n"))
+	out.Reset()
+	if err := tl.poll(ctx, time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); !strings.HasSuffix(got, "west late") || strings.Count(got, "\n") != 0 {
+		t.Errorf("second poll printed:\n%s", out.String())
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	start := time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 3, 6, 0, 0, 0, time.UTC)
+	got := partitions(start, end)
+	want := []partition{
+		{day: "2024-07-01", from: start, to: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)},
+		{day: "2024-07-02", from: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC), to: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)},
+		{day: "2024-07-03", from: time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC), to: end},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partitions =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestExportToFilesPartitionsByDay(t *testing.T) {
+	day1 := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	logs := fakeLogs{
+		"/aws/lambda/hello":       {logged("1", day1.Add(time.Hour).UnixMilli(), "one\n"), logged("3", day2.Add(time.Hour).UnixMilli(), "three\n")},
+		"/aws/lambda/hello-green": {logged("2", day2.UnixMilli(), "two\n")},
+	}
+	dir := t.TempDir() + "/"
+	var out strings.Builder
+	err := exportToFiles(context.Background(), logs, []string{"hello", "hello-green"}, dir, partitions(day1, day2.Add(2*time.Hour)), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string][]string{
+		"2024-07-01.ndjson.gz": {"one"},
+		"2024-07-02.ndjson.gz": {"three", "two"},
+	} {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages []string
+		for scanner := bufio.NewScanner(gz); scanner.Scan(); {
+			var e exportedEvent
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, e.Message)
+		}
+		if !reflect.DeepEqual(messages, want) {
+			t.Errorf("%s has %q, want %q", file, messages, want)
+		}
+	}
+	if !strings.Contains(out.String(), "[2/2] 2024-07-02: 2 events") {
+		t.Errorf("progress:\n%s", out.String())
+	}
+}
+
+// fakeExports completes each export task on the second check.
+type fakeExports struct {
+	fakeLogs
+	created []cloudwatchlogs.CreateExportTaskInput
+	checks  int
+}
+
+func (f *fakeExports) CreateExportTask(ctx context.Context, params *cloudwatchlogs.CreateExportTaskInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateExportTaskOutput, error) {
+	if _, ok := f.fakeLogs[aws.ToString(params.LogGroupName)]; !ok {
+		return nil, &logstypes.ResourceNotFoundException{}
+	}
+	f.created = append(f.created, *params)
+	return &cloudwatchlogs.CreateExportTaskOutput{TaskId: aws.String("task")}, nil
+}
+
+func (f *fakeExports) DescribeExportTasks(ctx context.Context, params *cloudwatchlogs.DescribeExportTasksInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeExportTasksOutput, error) {
+	f.checks++
+	code := logstypes.ExportTaskStatusCodeRunning
+	if f.checks%2 == 0 {
+		code = logstypes.ExportTaskStatusCodeCompleted
+	}
+	return &cloudwatchlogs.DescribeExportTasksOutput{ExportTasks: []logstypes.ExportTask{{Status: &logstypes.ExportTaskStatus{Code: code}}}}, nil
+}
+
+func TestExportToS3(t *testing.T) {
+	exportPollInterval = 0
+	t.Cleanup(func() { exportPollInterval = 5 * time.Second })
+	client := &fakeExports{fakeLogs: fakeLogs{"/aws/lambda/hello": nil}}
+	day1 := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	var out strings.Builder
+	err := exportToS3(context.Background(), client, []string{"hello", "never-invoked"}, "archive", "lambda", partitions(day1, day1.AddDate(0, 0, 2)), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.created) != 2 || client.checks != 4 {
+		t.Fatalf("created %d tasks and checked %d times, want 2 and 4", len(client.created), client.checks)
+	}
+	task := client.created[1]
+	if aws.ToString(task.Destination) != "archive" || aws.ToString(task.DestinationPrefix) != "lambda/hello/2024-07-02" ||
+		aws.ToInt64(task.To) != day1.AddDate(0, 0, 2).UnixMilli()-1 {
+		t.Errorf("second task = %+v", task)
+	}
+	if !strings.Contains(out.String(), "[4/4] /aws/lambda/never-invoked 2024-07-02: no log group") {
+		t.Errorf("progress:\n%s", out.String())
+	}
+}
+
+func TestClusterErrors(t *testing.T) {
+	s := &source{}
+	lines := []string{
+		`{"time":"2024-07-01T10:00:00Z","level":"ERROR","msg":"charge failed","error":"card 4242 declined","requestId":"11111111-2222-3333-4444-555555555555"}`,
+		`{"time":"2024-07-01T10:05:00Z","level":"ERROR","msg":"charge failed","error":"card 1881 declined","requestId":"66666666-2222-3333-4444-555555555555"}`,
+		`{"time":"2024-07-01T10:06:00Z","level":"INFO","msg":"charge retried"}`,
+		`{"errorMessage":"open \"/tmp/a\": no such file","errorType":"PathError","stackTrace":[{"label":"handle","path":"main.go","line":42}]}`,
+		"2024-07-01T10:07:00.000Z 77777777-2222-3333-4444-555555555555 Task timed out after 30.00 seconds",
+		"no problem here",
+	}
+	var events []event
+	for i, line := range lines {
+		events = append(events, event{s, logged(strings.Repeat("x", i+1), int64(1000*(i+1)), line+"\n")})
+	}
+
+	clusters := clusterErrors(events, 1)
+	if len(clusters) != 3 {
+		t.Fatalf("got %d clusters, want 3: %+v", len(clusters), clusters)
+	}
+	charge := clusters[0]
+	if charge.signature != "charge failed: card <n> declined" || charge.count != 2 ||
+		!reflect.DeepEqual(charge.requests, []string{"11111111-2222-3333-4444-555555555555"}) ||
+		charge.first != time.UnixMilli(1000) || charge.last != time.UnixMilli(2000) {
+		t.Errorf("first cluster = %+v", charge)
+	}
+	// Ties are broken by the most recent
+	if got := clusters[1].signature; got != "Task timed out after <n> seconds" {
+		t.Errorf("second cluster = %q", got)
+	}
+	if got := clusters[2].signature; got != "PathError: open <str>: no such file at handle main.go" {
+		t.Errorf("third cluster = %q", got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	fake := hostexec.NewFake()
+	previous := hostexec.Default
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default = previous })
+	segments, _ := json.Marshal([]string{
+		`{"name":"hello","origin":"AWS::Lambda::Function","start_time":1.0,"end_time":1.25,"subsegments":[{"name":"DynamoDB","start_time":1.1,"end_time":1.2,"fault":true}]}`,
+	})
+	fake.On([]string{"aws", "xray", "batch-get-traces", "--trace-ids", "1-abc-def"}, hostexec.Response{Output: segments})
+
+	id := "11111111-2222-3333-4444-555555555555"
+	logs := fakeLogs{
+		"/aws/lambda/hello": {
+			logged("1", 1000, "START RequestId: "+id+" Version: $LATEST\n"),
+			logged("2", 1100, `{"level":"ERROR","msg":"save failed","requestId":"`+id+`"}`+"\n"),
+			logged("3", 1300, "REPORT RequestId: "+id+"\tDuration: 250.00 ms\tBilled Duration: 251 ms\tMemory Size: 128 MB\tMax Memory Used: 40 MB\t\nXRAY TraceId: 1-abc-def\tSegmentId: 123\tSampled: true\n"),
+		},
+		"/aws/lambda/hello-shadow": {
+			logged("4", 2000, fmt.Sprintf(`{"msg":"shadow comparison","shadow_id":%q,"match":false,"primary_ms":250,"shadow_ms":90,"primary_response":"{}","shadow_response":"{\"ok\":true}"}`, id)),
+		},
+	}
+	var out strings.Builder
+	in := &inspector{
+		requestID: id,
+		sources:   []*source{{function: "hello", group: "/aws/lambda/hello", logs: logs}},
+		shadow:    "hello-shadow:live",
+		w:         &out,
+	}
+	if err := in.run(context.Background(), time.UnixMilli(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Duration         250.00 ms",
+		"Max Memory Used  40 MB",
+		"ERROR save failed",
+		"\n                           XRAY TraceId: 1-abc-def",
+		"Trace 1-abc-def\n  hello (AWS::Lambda::Function)  250.0 ms\n    DynamoDB  100.0 ms  fault",
+		"match false; primary 250 ms, shadow 90 ms",
+		`shadow response: {"ok":true}`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	for line, want := range map[string]string{
+		`{"time":"2024-07-01T10:00:00Z","level":"INFO","msg":"order placed","order_id":"o-1","items":3}`:       "INFO  order placed items=3 order_id=o-1",
+		`{"timestamp":"2024-07-01T10:00:00Z","level":"ERROR","message":"failed","error":"connection refused"}`: `ERROR failed error="connection refused"`,
+		`{"msg":"no level","nested":{"a":1}}`:     `no level nested={"a":1}`,
+		"REPORT RequestId: abc\tDuration: 1.2 ms": "REPORT RequestId: abc\tDuration: 1.2 ms",
+		"{not json": "{not json",
+	} {
+		if got := formatLine(line); got != want {
+			t.Errorf("formatLine(%s) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"example-lambda-go/internal/config"
+)
+
+// pollInterval is how often -follow asks for new events. CloudWatch Logs
+// usually takes a few seconds to make an event searchable anyway.
+const pollInterval = 2 * time.Second
+
+// Main prints the function's CloudWatch logs, and with -follow keeps
+// printing new events until interrupted. With -functions or -regions it reads
+// several log groups at once and merges their events in time order.
+// `logs export` copies them out instead.
+func Main(args []string) {
+	if len(args) > 0 && args[0] == "export" {
+		export(args[1:])
+		return
+	}
+	flags := flag.NewFlagSet("lambda-template logs", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "Keep printing new events until interrupted")
+	since := flags.Duration("since", 10*time.Minute, "How far back to start, e.g. 15m or 2h")
+	filter := flags.String("filter", "", "CloudWatch Logs filter pattern, e.g. ERROR or '{ $.level = \"ERROR\" }'")
+	raw := flags.Bool("raw", false, "Print messages as logged instead of formatting JSON lines")
+	functions := flags.String("functions", "", "Entries of functions to read together, comma-separated, or all")
+	regions := flags.String("regions", "", "Regions to read together, comma-separated, or all for every region an environment sets the function up in")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template logs [-follow] [-since 10m] [-filter pattern] [-raw] [-functions a,b|all] [-regions r1,r2|all]")
+		fmt.Fprintln(os.Stderr, "       lambda-template logs export [-since 1d] -to s3://bucket/prefix|file.ndjson.gz|dir/")
+		fmt.Fprintln(os.Stderr, "Prints the function's log events from /aws/lambda/<function>. JSON lines, as the")
+		fmt.Fprintln(os.Stderr, "handler's logger writes them, are shown as time, level, message and key=value fields.")
+		fmt.Fprintln(os.Stderr, "Events of several functions or regions are merged in time order, each prefixed with")
+		fmt.Fprintln(os.Stderr, "where it was logged; the prefixes are colored on a terminal unless NO_COLOR is set.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	sources, err := loadSources(context.TODO(), *functions, *regions)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := &tailer{
+		sources: sources,
+		filter:  *filter,
+		raw:     *raw,
+		color:   useColor(os.Stdout),
+		w:       os.Stdout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	start := time.Now().Add(-*since)
+	if err := t.poll(ctx, start); err != nil {
+		log.Fatal(err)
+	}
+	if !*follow {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+		if err := t.poll(ctx, start); err != nil && ctx.Err() == nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// loadSources returns the log groups to read: those of each function in
+// each region. Without -functions it is the function -function selects, and
+// without -regions its region.
+func loadSources(ctx context.Context, functions, regions string) ([]*source, error) {
+	names := []string{config.Function}
+	switch functions {
+	case "":
+	case "all":
+		all, err := config.FunctionNames()
+		if err != nil {
+			return nil, err
+		}
+		if len(all) > 0 {
+			names = all
+		}
+	default:
+		names = strings.Split(functions, ",")
+	}
+
+	var sources []*source
+	multiRegion := false
+	for _, name := range names {
+		config.Function = strings.TrimSpace(name)
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %v", err)
+		}
+		awsCfg, err := cfg.AWSConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config: %v", err)
+		}
+
+		functionRegions := []string{cfg.AWS.Region}
+		switch regions {
+		case "":
+		case "all":
+			if functionRegions, err = config.Regions(); err != nil {
+				return nil, err
+			}
+		default:
+			functionRegions = strings.Split(regions, ",")
+		}
+		multiRegion = multiRegion || len(functionRegions) > 1
+
+		for _, region := range functionRegions {
+			regionCfg := awsCfg.Copy()
+			regionCfg.Region = strings.TrimSpace(region)
+			client := cloudwatchlogs.NewFromConfig(regionCfg)
+			// With blue/green both functions serve traffic, so both are read
+			for _, function := range cfg.DeployedFunctions() {
+				sources = append(sources, &source{
+					function: function,
+					region:   regionCfg.Region,
+					group:    "/aws/lambda/" + function,
+					logs:     client,
+				})
+			}
+		}
+	}
+	for i, s := range sources {
+		s.label = s.function
+		if multiRegion {
+			s.label += " " + s.region
+		}
+		s.color = palette[i%len(palette)]
+	}
+	return sources, nil
+}
+
+// palette holds the ANSI colors of the prefixes, one per log group in turn.
+// Red is left out, so a prefix does not read as an error.
+var palette = []string{"36", "33", "35", "32", "34", "96", "93", "95", "92", "94"}
+
+// useColor reports whether f is a terminal and NO_COLOR (no-color.org) is
+// not set.
+func useColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// logsAPI is the part of the CloudWatch Logs client logs reads with.
+type logsAPI interface {
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
+// source is one log group being read.
+type source struct {
+	function, region, group string
+	// label prefixes the group's events when there are several groups, in
+	// color when the tailer has it
+	label, color string
+	logs         logsAPI
+	// last is the timestamp of the newest event printed from the group, and
+	// seen the IDs of the events printed with it: the next poll starts at
+	// last, since more events with that timestamp may arrive, and skips
+	// those. Each group keeps its own, as regions deliver events with
+	// different delays
+	last int64
+	seen map[string]bool
+}
+
+type event struct {
+	source *source
+	logstypes.FilteredLogEvent
+}
+
+type tailer struct {
+	sources []*source
+	filter  string
+	raw     bool
+	color   bool
+	w       io.Writer
+}
+
+// poll prints the events logged since start that it has not printed yet, in
+// time order across the log groups.
+func (t *tailer) poll(ctx context.Context, start time.Time) error {
+	events, err := t.fetch(ctx, start)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		s := e.source
+		id := aws.ToString(e.EventId)
+		if s.seen[id] {
+			continue
+		}
+		if ts := aws.ToInt64(e.Timestamp); ts > s.last || s.seen == nil {
+			s.last = ts
+			s.seen = map[string]bool{}
+		}
+		s.seen[id] = true
+		t.print(e)
+	}
+	return nil
+}
+
+// fetch reads the log groups in parallel and returns their events in time
+// order.
+func (t *tailer) fetch(ctx context.Context, start time.Time) ([]event, error) {
+	found := make([][]event, len(t.sources))
+	errs := make([]error, len(t.sources))
+	var wg sync.WaitGroup
+	for i, s := range t.sources {
+		wg.Add(1)
+		go func(i int, s *source) {
+			defer wg.Done()
+			found[i], errs[i] = t.read(ctx, s, start)
+		}(i, s)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	var events []event
+	for _, e := range found {
+		events = append(events, e...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return aws.ToInt64(events[i].Timestamp) < aws.ToInt64(events[j].Timestamp)
+	})
+	return events, nil
+}
+
+// read returns the events of the source's group from start, or from the
+// newest event printed from it.
+func (t *tailer) read(ctx context.Context, s *source, start time.Time) ([]event, error) {
+	from := start.UnixMilli()
+	if s.last != 0 {
+		from = s.last
+	}
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(s.group),
+		StartTime:    aws.Int64(from),
+	}
+	if t.filter != "" {
+		input.FilterPattern = aws.String(t.filter)
+	}
+	var events []event
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(s.logs, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		var notFound *logstypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			// Lambda creates the group on the first invocation
+			break
+		}
+		if err != nil {
+			if s.region != "" {
+				return nil, fmt.Errorf("error reading %s in %s: %v", s.group, s.region, err)
+			}
+			return nil, fmt.Errorf("error reading %s: %v", s.group, err)
+		}
+		for _, e := range page.Events {
+			events = append(events, event{s, e})
+		}
+	}
+	return events, nil
+}
+
+func (t *tailer) print(e event) {
+	timestamp := time.UnixMilli(aws.ToInt64(e.Timestamp)).Format("2006-01-02 15:04:05.000")
+	prefix := timestamp
+	if len(t.sources) > 1 {
+		label := e.source.label
+		if t.color {
+			label = "\x1b[" + e.source.color + "m" + label + "\x1b[0m"
+		}
+		prefix += " " + label
+	}
+	message := strings.TrimRight(aws.ToString(e.Message), "\n")
+	if !t.raw {
+		message = formatLine(message)
+	}
+	fmt.Fprintf(t.w, "%s  %s\n", prefix, message)
+}
+
+// formatLine shows a JSON log line, from log/slog or Lambda's JSON log
+// format, as its level and message followed by its other fields sorted by
+// key. Anything else, such as Lambda's START and REPORT lines, is returned
+// as it is.
+func formatLine(line string) string {
+	var fields map[string]interface{}
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &fields) != nil {
+		return line
+	}
+	level, _ := fields["level"].(string)
+	message, ok := fields["msg"].(string)
+	if !ok {
+		message, _ = fields["message"].(string)
+	}
+	// The event's own timestamp is printed already
+	for _, key := range []string{"level", "msg", "message", "time", "timestamp"} {
+		delete(fields, key)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	if level != "" {
+		fmt.Fprintf(&b, "%-5s ", strings.ToUpper(level))
+	}
+	b.WriteString(message)
+	for _, key := range keys {
+		value := fields[key]
+		if s, ok := value.(string); ok {
+			if strings.ContainsAny(s, " \t\"=") {
+				value = fmt.Sprintf("%q", s)
+			}
+		} else if encoded, err := json.Marshal(value); err == nil {
+			value = string(encoded)
+		}
+		fmt.Fprintf(&b, " %s=%v", key, value)
+	}
+	return b.String()
+}
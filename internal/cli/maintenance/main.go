@@ -0,0 +1,111 @@
+package maintenance
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/triggers"
+)
+
+// Main turns maintenance mode on or off.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template maintenance", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template maintenance on|off")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	mode := flags.Arg(0)
+	if mode != "on" && mode != "off" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	functionARN, err := setMode(context.TODO(), lambda.NewFromConfig(awsCfg), cfg, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Maintenance mode %s for '%s'.\n", mode, functionARN)
+}
+
+type lambdaAPI interface {
+	triggers.LambdaAPI
+	GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error)
+	UpdateFunctionConfiguration(ctx context.Context, params *lambda.UpdateFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error)
+}
+
+// setMode sets or clears the maintenance variables of the function that
+// serves traffic: the configured one, or its -green twin after a blue/green
+// deploy. It returns that function's ARN.
+func setMode(ctx context.Context, client lambdaAPI, cfg *config.Config, mode string) (string, error) {
+	if cfg.Deploy.Alias != "" {
+		return "", fmt.Errorf("deploy.alias %s runs a published version, whose environment cannot change; set MAINTENANCE_MODE: \"on\" in lambda.environment and deploy instead", cfg.Deploy.Alias)
+	}
+	functionARN, err := triggers.LiveFunctionARN(ctx, client, cfg.Lambda.FunctionName)
+	if err != nil {
+		return "", err
+	}
+
+	// Read the current variables so only the maintenance ones change
+	function, err := client.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting function configuration: %v", err)
+	}
+	variables := map[string]string{}
+	if function.Environment != nil {
+		for k, v := range function.Environment.Variables {
+			variables[k] = v
+		}
+	}
+
+	if mode == "on" {
+		variables["MAINTENANCE_MODE"] = "on"
+		if cfg.Maintenance.Response != "" {
+			variables["MAINTENANCE_RESPONSE"] = cfg.Maintenance.Response
+		}
+	} else {
+		delete(variables, "MAINTENANCE_MODE")
+		delete(variables, "MAINTENANCE_RESPONSE")
+	}
+
+	_, err = client.UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(functionARN),
+		Environment:  &types.Environment{Variables: variables},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating function configuration: %v", err)
+	}
+
+	// New execution environments pick up the flag once the update completes
+	waiter := lambda.NewFunctionUpdatedV2Waiter(client)
+	err = waiter.Wait(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionARN),
+	}, 5*time.Minute)
+	if err != nil {
+		return "", fmt.Errorf("error waiting for function update: %v", err)
+	}
+	return functionARN, nil
+}
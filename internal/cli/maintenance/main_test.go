@@ -0,0 +1,102 @@
+package maintenance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/triggers"
+)
+
+// fakeLambda holds the functions by name, with their tags and variables.
+type fakeLambda struct {
+	tags      map[string]map[string]string
+	variables map[string]map[string]string
+}
+
+func arn(name string) string {
+	return "arn:aws:lambda:us-east-1:123:function:" + name
+}
+
+func (f fakeLambda) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	name := strings.TrimPrefix(aws.ToString(params.FunctionName), arn(""))
+	return &lambda.GetFunctionOutput{
+		Tags: f.tags[name],
+		Configuration: &lambdatypes.FunctionConfiguration{
+			FunctionArn:      aws.String(arn(name)),
+			LastUpdateStatus: lambdatypes.LastUpdateStatusSuccessful,
+		},
+	}, nil
+}
+
+func (f fakeLambda) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	name := strings.TrimPrefix(aws.ToString(params.FunctionName), arn(""))
+	return &lambda.GetFunctionConfigurationOutput{Environment: &lambdatypes.EnvironmentResponse{Variables: f.variables[name]}}, nil
+}
+
+func (f fakeLambda) UpdateFunctionConfiguration(ctx context.Context, params *lambda.UpdateFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionConfigurationOutput, error) {
+	name := strings.TrimPrefix(aws.ToString(params.FunctionName), arn(""))
+	f.variables[name] = params.Environment.Variables
+	return &lambda.UpdateFunctionConfigurationOutput{}, nil
+}
+
+func (f fakeLambda) ListEventSourceMappings(ctx context.Context, params *lambda.ListEventSourceMappingsInput, optFns ...func(*lambda.Options)) (*lambda.ListEventSourceMappingsOutput, error) {
+	return &lambda.ListEventSourceMappingsOutput{}, nil
+}
+
+func (f fakeLambda) UpdateEventSourceMapping(ctx context.Context, params *lambda.UpdateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error) {
+	return &lambda.UpdateEventSourceMappingOutput{}, nil
+}
+
+func TestSetModeUsesLiveColor(t *testing.T) {
+	client := fakeLambda{
+		tags: map[string]map[string]string{"hello": {triggers.LiveTag: "green"}},
+		variables: map[string]map[string]string{
+			"hello":       {"LOG_LEVEL": "info"},
+			"hello-green": {"LOG_LEVEL": "info"},
+		},
+	}
+	cfg := &config.Config{}
+	cfg.Lambda.FunctionName = "hello"
+	cfg.Deploy.Strategy = "bluegreen"
+
+	functionARN, err := setMode(context.Background(), client, cfg, "on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if functionARN != arn("hello-green") {
+		t.Errorf("setMode changed %s, want the live green function", functionARN)
+	}
+	if client.variables["hello-green"]["MAINTENANCE_MODE"] != "on" || client.variables["hello-green"]["LOG_LEVEL"] != "info" {
+		t.Errorf("green variables = %v, want MAINTENANCE_MODE added", client.variables["hello-green"])
+	}
+	if _, ok := client.variables["hello"]["MAINTENANCE_MODE"]; ok {
+		t.Error("the idle blue function was put in maintenance")
+	}
+
+	if _, err := setMode(context.Background(), client, cfg, "off"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.variables["hello-green"]["MAINTENANCE_MODE"]; ok {
+		t.Error("maintenance off left MAINTENANCE_MODE on the green function")
+	}
+}
+
+func TestSetModeRefusesAlias(t *testing.T) {
+	client := fakeLambda{variables: map[string]map[string]string{"hello": {}}}
+	cfg := &config.Config{}
+	cfg.Lambda.FunctionName = "hello"
+	cfg.Deploy.Alias = "live"
+
+	if _, err := setMode(context.Background(), client, cfg, "on"); err == nil || !strings.Contains(err.Error(), "deploy.alias live") {
+		t.Errorf("setMode with deploy.alias error = %v, want a refusal", err)
+	}
+	if len(client.variables["hello"]) > 0 {
+		t.Errorf("$LATEST variables changed to %v", client.variables["hello"])
+	}
+}
@@ -0,0 +1,18 @@
+package policy
+
+import (
+	"example-lambda-go/internal/cli/delete"
+	"example-lambda-go/internal/cli/deploy"
+	"example-lambda-go/internal/cli/setup"
+	"example-lambda-go/internal/explain"
+)
+
+// deployerPolicy allows the calls setup, deploy and delete make with config.
+// It is generated from their -explain plans, so a call added to one of the
+// commands together with its plan step is in the policy too.
+func deployerPolicy(awsAccountID string) explain.PolicyDocument {
+	plans := []*explain.Plan{setup.Plan(&config, awsAccountID)}
+	plans = append(plans, deploy.Plan(&config, awsAccountID)...)
+	plans = append(plans, delete.Plan(&config, awsAccountID))
+	return explain.Policy(plans...)
+}
@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+func TestDeployerPolicyCoversDeploySettings(t *testing.T) {
+	previous := config
+	t.Cleanup(func() { config = previous })
+	config = appconfig.Config{}
+	config.AWS.Region = "us-east-1"
+	config.Lambda.FunctionName = "hello"
+	config.Lambda.RoleName = "hello-role"
+	config.ECR.RepositoryName = "hello"
+	config.Lambda.Tracing = "Active"
+	config.VPC.SubnetIDs = []string{"subnet-1"}
+	config.Lambda.Environment = map[string]string{"DB_PASSWORD": "secretsmanager:hello/db"}
+
+	allowed := map[string][]string{}
+	for _, statement := range deployerPolicy("123").Statement {
+		for _, action := range statement.Action {
+			allowed[action] = append(allowed[action], statement.Resource...)
+		}
+	}
+	for action, resource := range map[string]string{
+		"iam:AttachRolePolicy":          "arn:aws:iam::123:role/hello-role",
+		"lambda:TagResource":            "arn:aws:lambda:us-east-1:123:function:hello",
+		"lambda:UpdateFunctionCode":     "arn:aws:lambda:us-east-1:123:function:hello",
+		"secretsmanager:DescribeSecret": "arn:aws:secretsmanager:us-east-1:123:secret:hello/db-??????",
+		"lambda:DeleteFunction":         "arn:aws:lambda:us-east-1:123:function:hello",
+		"ec2:DescribeSubnets":           "*",
+	} {
+		if !slices.Contains(allowed[action], resource) {
+			t.Errorf("%s on %s is not allowed: %v", action, resource, allowed[action])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/registryauth"
+)
+
+var config appconfig.Config
+
+func loadConfig() error {
+	cfg, err := appconfig.Load()
+	if err != nil {
+		return err
+	}
+	config = *cfg
+	return nil
+}
+
+// Main prints the deployer policy for config.yaml.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template policy", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template policy deployer [-account 123456789012]")
+		fmt.Fprintln(os.Stderr, "Prints the IAM policy a CI role needs to run setup, deploy and delete with config.yaml.")
+		flags.PrintDefaults()
+	}
+	account := flags.String("account", "", "AWS account ID for the resource ARNs (default: the account of the current credentials)")
+	if len(args) == 0 || args[0] != "deployer" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(args[1:])
+
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	awsAccountID := *account
+	if awsAccountID == "" {
+		var err error
+		if awsAccountID, err = getAWSAccountID(); err != nil {
+			log.Fatalf("Error getting AWS Account ID (pass -account to skip the lookup): %v", err)
+		}
+	}
+
+	data, err := json.MarshalIndent(deployerPolicy(awsAccountID), "", "  ")
+	if err != nil {
+		log.Fatalf("Error encoding policy: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+func getAWSAccountID() (string, error) {
+	cfg, err := config.AWSConfig(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return registryauth.AccountID(context.Background(), sts.NewFromConfig(cfg))
+}
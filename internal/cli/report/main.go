@@ -0,0 +1,275 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/digest"
+	"example-lambda-go/internal/registryauth"
+	"example-lambda-go/internal/secretenv"
+)
+
+// defaultSchedule sends the digest every day at 08:00 UTC.
+const defaultSchedule = "cron(0 8 * * ? *)"
+
+// Main prints or sends the health digest, or schedules the function to
+// send it every day.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template report", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template report [send|schedule|unschedule]")
+		fmt.Fprintln(os.Stderr, "Without an action, prints the digest of the past day: invocations, errors, throttles,")
+		fmt.Fprintln(os.Stderr, "p99 duration, estimated cost and alarms that are not OK. send delivers it to the")
+		fmt.Fprintln(os.Stderr, "report section's Slack webhook and SNS topic now; schedule has the function send it")
+		fmt.Fprintln(os.Stderr, "on report.schedule, and unschedule stops that.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	action := flags.Arg(0)
+	switch action {
+	case "", "send", "schedule", "unschedule":
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	digestCfg := digest.Config{
+		Functions:    cfg.DeployedFunctions(),
+		SlackWebhook: cfg.Report.SlackWebhook,
+		SNSTopicARN:  cfg.Report.SNSTopicARN,
+		MemoryMB:     cfg.Lambda.MemorySize,
+		Arm64:        cfg.Build.Go.LambdaArchitecture() == "arm64",
+	}
+	if action != "" && action != "unschedule" && !digestCfg.Enabled() {
+		log.Fatal("report.slack_webhook or report.sns_topic_arn must be set in config.yaml")
+	}
+
+	// Load AWS configuration
+	ctx := context.TODO()
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	switch action {
+	case "schedule":
+		if err := schedule(ctx, awsCfg, cfg); err != nil {
+			log.Fatalf("Error scheduling the digest: %v", err)
+		}
+		return
+	case "unschedule":
+		if err := unschedule(ctx, eventbridge.NewFromConfig(awsCfg), cfg); err != nil {
+			log.Fatalf("Error removing the digest schedule: %v", err)
+		}
+		return
+	}
+
+	if digestCfg.SlackWebhook, err = secretenv.Value(ctx, secretsmanager.NewFromConfig(awsCfg), "report.slack_webhook", digestCfg.SlackWebhook); err != nil {
+		log.Fatal(err)
+	}
+	d, err := digest.Collect(ctx, cloudwatch.NewFromConfig(awsCfg), digestCfg, time.Now())
+	if err != nil {
+		log.Fatalf("Error collecting the digest: %v", err)
+	}
+	if action == "" {
+		fmt.Print(d.Text())
+		return
+	}
+	httpClient, err := cfg.HTTPClient(10 * time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := digest.Send(ctx, digestCfg, sns.NewFromConfig(awsCfg), httpClient, d); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Digest sent")
+}
+
+func ruleName(cfg *config.Config) string {
+	return cfg.Lambda.FunctionName + "-digest"
+}
+
+// schedule lets the function read metrics and alarms and publish to the
+// topic, gives it the DIGEST_* variables, and has EventBridge invoke it with
+// the digest event on report.schedule.
+func schedule(ctx context.Context, awsCfg aws.Config, cfg *config.Config) error {
+	if err := putDigestPolicy(ctx, iam.NewFromConfig(awsCfg), sts.NewFromConfig(awsCfg), cfg); err != nil {
+		return err
+	}
+
+	lambdaClient := lambda.NewFromConfig(awsCfg)
+	function, err := setDigestEnvironment(ctx, lambdaClient, cfg)
+	if err != nil {
+		return err
+	}
+
+	expression := cfg.Report.Schedule
+	if expression == "" {
+		expression = defaultSchedule
+	}
+	var tags []ebtypes.Tag
+	for key, value := range cfg.ResourceTags(time.Now()) {
+		tags = append(tags, ebtypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	events := eventbridge.NewFromConfig(awsCfg)
+	rule, err := events.PutRule(ctx, &eventbridge.PutRuleInput{
+		Name:               aws.String(ruleName(cfg)),
+		ScheduleExpression: aws.String(expression),
+		Description:        aws.String("Daily health digest of " + cfg.Lambda.FunctionName),
+		Tags:               tags,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating schedule rule: %v", err)
+	}
+
+	_, err = lambdaClient.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+		StatementId:  aws.String(ruleName(cfg)),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    rule.RuleArn,
+	})
+	var conflict *lambdatypes.ResourceConflictException
+	if err != nil && !errors.As(err, &conflict) {
+		return fmt.Errorf("error adding invoke permission: %v", err)
+	}
+
+	input, err := json.Marshal(map[string]string{"source": "lambda-template", "detail-type": digest.DetailType})
+	if err != nil {
+		return err
+	}
+	_, err = events.PutTargets(ctx, &eventbridge.PutTargetsInput{
+		Rule: aws.String(ruleName(cfg)),
+		Targets: []ebtypes.Target{{
+			Id:    aws.String(cfg.Lambda.FunctionName),
+			Arn:   function.FunctionArn,
+			Input: aws.String(string(input)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error adding schedule target: %v", err)
+	}
+
+	fmt.Printf("Digest scheduled with %s\n", expression)
+	return nil
+}
+
+func putDigestPolicy(ctx context.Context, client *iam.Client, stsClient *sts.Client, cfg *config.Config) error {
+	statements := []map[string]interface{}{{
+		"Effect": "Allow",
+		// Neither action supports resource-level permissions
+		"Action":   []string{"cloudwatch:GetMetricData", "cloudwatch:DescribeAlarms"},
+		"Resource": "*",
+	}}
+	if cfg.Report.SNSTopicARN != "" {
+		statements = append(statements, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   []string{"sns:Publish"},
+			"Resource": []string{cfg.Report.SNSTopicARN},
+		})
+	}
+	if ref, ok, _ := secretenv.Parse(cfg.Report.SlackWebhook); ok {
+		accountID, err := registryauth.AccountID(ctx, stsClient)
+		if err != nil {
+			return err
+		}
+		// The function resolves DIGEST_SLACK_WEBHOOK at cold start
+		statements = append(statements, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   []string{"secretsmanager:GetSecretValue"},
+			"Resource": []string{cfg.SecretARN(ref.Secret, accountID)},
+		})
+	}
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding digest policy: %v", err)
+	}
+
+	_, err = client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(cfg.Lambda.RoleName),
+		PolicyName:     aws.String("report-digest"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting digest policy: %v", err)
+	}
+	fmt.Println("Digest policy attached to Lambda execution role")
+	return nil
+}
+
+// setDigestEnvironment merges the DIGEST_* variables into the function's
+// environment, the same ones deploy sets from the report section, so the
+// schedule works before the next deploy.
+func setDigestEnvironment(ctx context.Context, client *lambda.Client, cfg *config.Config) (*lambda.GetFunctionConfigurationOutput, error) {
+	function, err := client.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting function configuration: %v", err)
+	}
+	variables := map[string]string{}
+	if function.Environment != nil {
+		for k, v := range function.Environment.Variables {
+			variables[k] = v
+		}
+	}
+	variables["DIGEST_FUNCTIONS"] = strings.Join(cfg.DeployedFunctions(), ",")
+	variables["DIGEST_SLACK_WEBHOOK"] = cfg.Report.SlackWebhook
+	variables["DIGEST_SNS_TOPIC_ARN"] = cfg.Report.SNSTopicARN
+
+	_, err = client.UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+		Environment:  &lambdatypes.Environment{Variables: variables},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating function configuration: %v", err)
+	}
+	return function, nil
+}
+
+func unschedule(ctx context.Context, events *eventbridge.Client, cfg *config.Config) error {
+	_, err := events.RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{
+		Rule: aws.String(ruleName(cfg)),
+		Ids:  []string{cfg.Lambda.FunctionName},
+	})
+	var notFound *ebtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		fmt.Println("No digest is scheduled")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error removing schedule target: %v", err)
+	}
+	if _, err := events.DeleteRule(ctx, &eventbridge.DeleteRuleInput{Name: aws.String(ruleName(cfg))}); err != nil {
+		return fmt.Errorf("error deleting schedule rule: %v", err)
+	}
+	fmt.Println("Digest schedule removed")
+	return nil
+}
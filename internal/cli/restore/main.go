@@ -0,0 +1,87 @@
+package restore
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/snapshot"
+)
+
+// Main recreates the function from a snapshot in backup.bucket, in the
+// account and region of the configured profile.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template restore", flag.ExitOnError)
+	key := flags.String("snapshot", "", "Key of the snapshot in backup.bucket; the newest of the function's by default")
+	name := flags.String("name", "", "Create the function under this name instead of the snapshot's")
+	image := flags.String("image", "", "Image to create the function from; required in another region, as Lambda only runs images from ECR in its own region. The aliases' versions are restored from its repository by digest")
+	role := flags.String("role", "", "Execution role ARN; by default the snapshot's role in the target account")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template restore [-snapshot key] [-name function] [-image uri] [-role arn]")
+		fmt.Fprintln(os.Stderr, "Creates the function of a `lambda-template backup` snapshot in the account and region of")
+		fmt.Fprintln(os.Stderr, "-profile and -region, with its permissions, aliases, function URL and triggers. ARNs")
+		fmt.Fprintln(os.Stderr, "are moved to that account and region; the queues, topics and role they name must exist.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Backup.Bucket == "" {
+		log.Fatal("backup.bucket must be set in config.yaml")
+	}
+
+	// Load AWS configuration
+	ctx := context.TODO()
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	store := s3.NewFromConfig(awsCfg)
+
+	if *key == "" {
+		keys, err := snapshot.List(ctx, store, cfg.Backup.Bucket, cfg.BackupPrefix(), cfg.Lambda.FunctionName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(keys) == 0 {
+			log.Fatalf("No snapshots of %s in s3://%s/%s", cfg.Lambda.FunctionName, cfg.Backup.Bucket, cfg.BackupPrefix())
+		}
+		*key = keys[len(keys)-1]
+	}
+	s, err := snapshot.Load(ctx, store, cfg.Backup.Bucket, *key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		log.Fatalf("Error getting AWS Account ID: %v", err)
+	}
+	target := snapshot.Target{
+		Function: *name,
+		Region:   cfg.AWS.Region,
+		Account:  aws.ToString(identity.Account),
+		Image:    *image,
+		Role:     *role,
+	}
+	fmt.Printf("Restoring %s from the snapshot taken %s in %s (account %s)\n", s.Function, s.Taken.Format("2006-01-02 15:04 MST"), s.Region, s.Account)
+
+	clients := snapshot.Clients{Lambda: lambda.NewFromConfig(awsCfg), Events: eventbridge.NewFromConfig(awsCfg)}
+	if err := snapshot.Restore(ctx, clients, s, target, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Restore completed successfully")
+}
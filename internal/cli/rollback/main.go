@@ -0,0 +1,433 @@
+package rollback
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/audit"
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+)
+
+type lambdaAPI interface {
+	GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+	ListVersionsByFunction(ctx context.Context, params *lambda.ListVersionsByFunctionInput, optFns ...func(*lambda.Options)) (*lambda.ListVersionsByFunctionOutput, error)
+	UpdateFunctionCode(ctx context.Context, params *lambda.UpdateFunctionCodeInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error)
+	PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error)
+	UpdateAlias(ctx context.Context, params *lambda.UpdateAliasInput, optFns ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error)
+}
+
+type ecrAPI interface {
+	DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error)
+}
+
+// historyLength is how many images and versions are listed.
+const historyLength = 10
+
+// updateTimeout bounds the wait for the function to take the old image.
+const updateTimeout = 5 * time.Minute
+
+// Main lists the images and versions the function can go back to, or points
+// it back at one of them.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template rollback", flag.ExitOnError)
+	previous := flags.Bool("previous", false, "Go back to the image pushed before the deployed one")
+	toVersion := flags.String("to-version", "", "Go back to the image of this published version")
+	toImage := flags.String("to-image", "", "Go back to this image digest, or a unique prefix of it")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template rollback [-previous | -to-version N | -to-image sha256:...]")
+		fmt.Fprintln(os.Stderr, "Without a target, lists the recent images and published versions. With one, points")
+		fmt.Fprintln(os.Stderr, "$LATEST back at that image and waits for the update to finish. With deploy.alias set,")
+		fmt.Fprintln(os.Stderr, "the alias is moved instead: to the version itself, or to a new version of the image.")
+		fmt.Fprintln(os.Stderr, "-to-version and -previous then only take versions published for the alias, which")
+		fmt.Fprintln(os.Stderr, "carry its environment.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	targets := 0
+	for _, set := range []bool{*previous, *toVersion != "", *toImage != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets > 1 || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Deploy.Strategy == "bluegreen" && targets > 0 {
+		log.Fatalf("deploy.strategy is bluegreen; run `lambda-template deploy -swap` to go back to the previous color")
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	registryCfg, err := cfg.RegistryAWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config for aws.profiles.registry: %v", err)
+	}
+
+	r := rollbacker{
+		lambda:     lambda.NewFromConfig(awsCfg),
+		ecr:        ecr.NewFromConfig(registryCfg),
+		function:   cfg.Lambda.FunctionName,
+		repository: cfg.ECR.RepositoryName,
+		alias:      cfg.Deploy.Alias,
+	}
+	ctx := context.TODO()
+	h, err := r.history(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if targets == 0 {
+		h.print(os.Stdout)
+		return
+	}
+
+	var digest, version string
+	switch {
+	case *previous:
+		digest, version, err = h.previous()
+	case *toVersion != "":
+		version = *toVersion
+		digest, err = r.versionDigest(ctx, version)
+	default:
+		digest, err = h.find(*toImage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	target := qualifiedName(r.function, r.alias)
+	if digest == h.current {
+		fmt.Printf("'%s' already runs %s.\n", target, digest)
+		return
+	}
+	if err := r.rollback(ctx, h.repositoryURI+"@"+digest, version); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("'%s' rolled back from %s to %s.\n", target, h.current, digest)
+
+	// The rollback has happened by now, so a failure to log it is only a warning
+	actor := "unknown"
+	if identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
+		actor = aws.ToString(identity.Arn)
+	}
+	err = audit.Record(cfg.Audit.File, audit.Entry{
+		Actor:    actor,
+		Action:   "rollback",
+		Function: target,
+		Detail:   fmt.Sprintf("%s -> %s", h.current, digest),
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if console.Show {
+		c := console.New(cfg.Partition(), cfg.AWS.Region)
+		account, _, _ := strings.Cut(h.repositoryURI, ".")
+		console.Print(os.Stdout, c.Function(r.function, r.alias), c.Logs(r.function), c.Image(account, r.repository, digest))
+	}
+}
+
+type rollbacker struct {
+	lambda     lambdaAPI
+	ecr        ecrAPI
+	function   string
+	repository string
+	// alias is deploy.alias, which rollbacks move when set
+	alias string
+}
+
+type image struct {
+	digest string
+	tags   []string
+	pushed time.Time
+}
+
+type version struct {
+	version  string
+	digest   string
+	modified string
+}
+
+// deployed is what a qualifier of the function runs, and the description
+// of the version it resolves to.
+type deployed struct {
+	repositoryURI, digest, description string
+}
+
+// history is what the function can be rolled back to, newest first.
+type history struct {
+	repositoryURI string
+	// current is the digest $LATEST, or the alias, runs
+	current string
+	images  []image
+	// alias is deploy.alias; versions then only holds those published for it
+	alias    string
+	versions []version
+}
+
+// history reads the deployed image, the repository's images and the
+// function's published versions.
+func (r *rollbacker) history(ctx context.Context) (*history, error) {
+	current, err := r.resolve(ctx, r.alias)
+	if err != nil {
+		return nil, err
+	}
+	h := &history{repositoryURI: current.repositoryURI, current: current.digest, alias: r.alias}
+
+	images := ecr.NewDescribeImagesPaginator(r.ecr, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(r.repository),
+	})
+	for images.HasMorePages() {
+		page, err := images.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing images in %s: %v", r.repository, err)
+		}
+		for _, detail := range page.ImageDetails {
+			h.images = append(h.images, image{
+				digest: aws.ToString(detail.ImageDigest),
+				tags:   detail.ImageTags,
+				pushed: aws.ToTime(detail.ImagePushedAt),
+			})
+		}
+	}
+	sort.SliceStable(h.images, func(i, j int) bool { return h.images[i].pushed.After(h.images[j].pushed) })
+
+	versions := lambda.NewListVersionsByFunctionPaginator(r.lambda, &lambda.ListVersionsByFunctionInput{
+		FunctionName: aws.String(r.function),
+	})
+	var numbers []int
+	modified := map[int]string{}
+	for versions.HasMorePages() {
+		page, err := versions.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing versions of %s: %v", r.function, err)
+		}
+		for _, v := range page.Versions {
+			// $LATEST and anything else that isn't a published version
+			n, err := strconv.Atoi(aws.ToString(v.Version))
+			if err != nil {
+				continue
+			}
+			if r.alias != "" && !config.PublishedFor(aws.ToString(v.Description), r.alias) {
+				continue
+			}
+			numbers = append(numbers, n)
+			modified[n] = aws.ToString(v.LastModified)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(numbers)))
+	if len(numbers) > historyLength {
+		numbers = numbers[:historyLength]
+	}
+	// The version list leaves out the image, so each one is looked up
+	for _, n := range numbers {
+		d, err := r.resolve(ctx, strconv.Itoa(n))
+		if err != nil {
+			return nil, err
+		}
+		h.versions = append(h.versions, version{version: strconv.Itoa(n), digest: d.digest, modified: modified[n]})
+	}
+	return h, nil
+}
+
+// resolve looks up what a qualifier of the function runs, splitting its
+// image into the repository URI and digest.
+func (r *rollbacker) resolve(ctx context.Context, qualifier string) (deployed, error) {
+	input := &lambda.GetFunctionInput{FunctionName: aws.String(r.function)}
+	if qualifier != "" {
+		input.Qualifier = aws.String(qualifier)
+	}
+	output, err := r.lambda.GetFunction(ctx, input)
+	if err != nil {
+		return deployed{}, fmt.Errorf("error getting %s: %v", qualifiedName(r.function, qualifier), err)
+	}
+	var resolved string
+	if output.Code != nil {
+		resolved = aws.ToString(output.Code.ResolvedImageUri)
+	}
+	repositoryURI, digest, ok := strings.Cut(resolved, "@")
+	if !ok {
+		return deployed{}, fmt.Errorf("%s is not deployed from a container image", qualifiedName(r.function, qualifier))
+	}
+	d := deployed{repositoryURI: repositoryURI, digest: digest}
+	if output.Configuration != nil {
+		d.description = aws.ToString(output.Configuration.Description)
+	}
+	return d, nil
+}
+
+// versionDigest returns the image digest of a published version. With an
+// alias, the version must have been published for it: another alias's
+// version carries that alias's environment.
+func (r *rollbacker) versionDigest(ctx context.Context, v string) (string, error) {
+	if _, err := strconv.Atoi(v); err != nil {
+		return "", fmt.Errorf("-to-version %q is not a version number", v)
+	}
+	d, err := r.resolve(ctx, v)
+	if err != nil {
+		return "", err
+	}
+	if r.alias != "" && !config.PublishedFor(d.description, r.alias) {
+		return "", fmt.Errorf("version %s was not published for alias %s; `lambda-template rollback` lists the versions that were", v, r.alias)
+	}
+	return d.digest, nil
+}
+
+// rollback points $LATEST at imageURI and waits until Lambda has taken it.
+// With an alias, the alias is then moved to a new version of it, or straight
+// to version when the target is a published version.
+func (r *rollbacker) rollback(ctx context.Context, imageURI, version string) error {
+	if r.alias != "" && version != "" {
+		return r.pointAlias(ctx, version)
+	}
+	_, err := r.lambda.UpdateFunctionCode(ctx, &lambda.UpdateFunctionCodeInput{
+		FunctionName: aws.String(r.function),
+		ImageUri:     aws.String(imageURI),
+	})
+	if err != nil {
+		return fmt.Errorf("error updating %s to %s: %v", r.function, imageURI, err)
+	}
+	waiter := lambda.NewFunctionUpdatedV2Waiter(r.lambda)
+	if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(r.function)}, updateTimeout); err != nil {
+		return fmt.Errorf("failed waiting for %s: %v", r.function, err)
+	}
+	if r.alias == "" {
+		return nil
+	}
+
+	published, err := r.lambda.PublishVersion(ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(r.function),
+		Description:  aws.String(config.AliasVersionDescription(r.alias) + ": rollback to " + imageURI),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish version: %v", err)
+	}
+	return r.pointAlias(ctx, aws.ToString(published.Version))
+}
+
+func (r *rollbacker) pointAlias(ctx context.Context, version string) error {
+	_, err := r.lambda.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(r.function),
+		Name:            aws.String(r.alias),
+		FunctionVersion: aws.String(version),
+		// A rollback ends any canary the alias still sends traffic to
+		RoutingConfig: &lambdatypes.AliasRoutingConfiguration{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to point alias %s at version %s: %v", r.alias, version, err)
+	}
+	return nil
+}
+
+// previous returns the image pushed before the deployed one. Run again after
+// a rollback, it steps one further back. With an alias it also returns the
+// newest version published for the alias with that image, and fails when
+// there is none.
+func (h *history) previous() (digest, version string, err error) {
+	for i, img := range h.images {
+		if img.digest != h.current {
+			continue
+		}
+		if i+1 == len(h.images) {
+			return "", "", fmt.Errorf("no image was pushed before the deployed one, %s", h.current)
+		}
+		digest = h.images[i+1].digest
+		if h.alias == "" {
+			return digest, "", nil
+		}
+		for _, v := range h.versions {
+			if v.digest == digest {
+				return digest, v.version, nil
+			}
+		}
+		return "", "", fmt.Errorf("no version published for alias %s runs %s, the image pushed before the deployed one; use -to-image to publish one", h.alias, shortDigest(digest))
+	}
+	return "", "", fmt.Errorf("the deployed image %s is no longer in the repository; use -to-image or -to-version", h.current)
+}
+
+// find returns the repository image whose digest starts with prefix, with or
+// without the sha256: algorithm.
+func (h *history) find(prefix string) (string, error) {
+	if !strings.HasPrefix(prefix, "sha256:") {
+		prefix = "sha256:" + prefix
+	}
+	var matches []string
+	for _, img := range h.images {
+		if strings.HasPrefix(img.digest, prefix) {
+			matches = append(matches, img.digest)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no image %s in the repository", prefix)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%s matches %d images; give more of the digest", prefix, len(matches))
+}
+
+// print lists the recent images and versions, marking the deployed image.
+func (h *history) print(w io.Writer) {
+	fmt.Fprintf(w, "Images in %s, newest first:\n", h.repositoryURI)
+	for i, img := range h.images {
+		if i == historyLength {
+			fmt.Fprintf(w, "  ... %d older\n", len(h.images)-historyLength)
+			break
+		}
+		mark := " "
+		if img.digest == h.current {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s  %s  %s\n", mark, shortDigest(img.digest), img.pushed.UTC().Format("2006-01-02 15:04"), strings.Join(img.tags, ","))
+	}
+	if len(h.versions) > 0 {
+		heading := "Published versions"
+		if h.alias != "" {
+			heading = "Versions published for alias " + h.alias
+		}
+		fmt.Fprintf(w, "\n%s, newest first:\n", heading)
+		for _, v := range h.versions {
+			fmt.Fprintf(w, "  %-4s %s  %s\n", v.version, shortDigest(v.digest), v.modified)
+		}
+	}
+	fmt.Fprintln(w, "\n* is deployed. Roll back with -previous, -to-version N or -to-image <digest>.")
+}
+
+// shortDigest is enough of a digest to tell images apart and pass to -to-image.
+func shortDigest(digest string) string {
+	hex := strings.TrimPrefix(digest, "sha256:")
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
+}
+
+func qualifiedName(function, qualifier string) string {
+	if qualifier == "" {
+		return function
+	}
+	return function + ":" + qualifier
+}
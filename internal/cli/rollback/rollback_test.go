@@ -0,0 +1,240 @@
+package rollback
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/config"
+)
+
+const repo = "123456789012.dkr.ecr.us-east-1.amazonaws.com/hello-world"
+
+// fakeLambda maps qualifiers, "" for $LATEST, to the digest they run, and
+// published versions to their descriptions.
+type fakeLambda struct {
+	digests      map[string]string
+	descriptions map[string]string
+}
+
+func (f fakeLambda) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	qualifier := aws.ToString(params.Qualifier)
+	return &lambda.GetFunctionOutput{
+		Code: &lambdatypes.FunctionCodeLocation{ResolvedImageUri: aws.String(repo + "@" + f.digests[qualifier])},
+		Configuration: &lambdatypes.FunctionConfiguration{
+			LastUpdateStatus: lambdatypes.LastUpdateStatusSuccessful,
+			Description:      aws.String(f.descriptions[qualifier]),
+		},
+	}, nil
+}
+
+func (f fakeLambda) ListVersionsByFunction(ctx context.Context, params *lambda.ListVersionsByFunctionInput, optFns ...func(*lambda.Options)) (*lambda.ListVersionsByFunctionOutput, error) {
+	output := &lambda.ListVersionsByFunctionOutput{}
+	for qualifier := range f.digests {
+		if _, err := strconv.Atoi(qualifier); err != nil && qualifier != "" {
+			continue
+		}
+		if qualifier == "" {
+			qualifier = "$LATEST"
+		}
+		output.Versions = append(output.Versions, lambdatypes.FunctionConfiguration{
+			Version:      aws.String(qualifier),
+			Description:  aws.String(f.descriptions[qualifier]),
+			LastModified: aws.String("2024-07-01T10:00:00.000+0000"),
+		})
+	}
+	return output, nil
+}
+
+func (f fakeLambda) UpdateFunctionCode(ctx context.Context, params *lambda.UpdateFunctionCodeInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionCodeOutput, error) {
+	_, f.digests[""], _ = strings.Cut(aws.ToString(params.ImageUri), "@")
+	return &lambda.UpdateFunctionCodeOutput{}, nil
+}
+
+func (f fakeLambda) PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	version := strconv.Itoa(len(f.digests))
+	f.digests[version] = f.digests[""]
+	f.descriptions[version] = aws.ToString(params.Description)
+	return &lambda.PublishVersionOutput{Version: aws.String(version)}, nil
+}
+
+func (f fakeLambda) UpdateAlias(ctx context.Context, params *lambda.UpdateAliasInput, optFns ...func(*lambda.Options)) (*lambda.UpdateAliasOutput, error) {
+	// Without a routing config Lambda keeps any canary's weights
+	if params.RoutingConfig == nil || len(params.RoutingConfig.AdditionalVersionWeights) > 0 {
+		return nil, fmt.Errorf("alias %s moved without clearing its routing config", aws.ToString(params.Name))
+	}
+	name, version := aws.ToString(params.Name), aws.ToString(params.FunctionVersion)
+	f.digests[name], f.descriptions[name] = f.digests[version], f.descriptions[version]
+	return &lambda.UpdateAliasOutput{}, nil
+}
+
+type fakeECR []ecrtypes.ImageDetail
+
+func (f fakeECR) DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	return &ecr.DescribeImagesOutput{ImageDetails: f}, nil
+}
+
+func pushed(digest string, day int, tags ...string) ecrtypes.ImageDetail {
+	return ecrtypes.ImageDetail{
+		ImageDigest:   aws.String(digest),
+		ImageTags:     tags,
+		ImagePushedAt: aws.Time(time.Date(2024, 7, day, 10, 0, 0, 0, time.UTC)),
+	}
+}
+
+func newRollbacker() (*rollbacker, fakeLambda) {
+	functions := fakeLambda{
+		digests:      map[string]string{"": "sha256:cccc", "1": "sha256:aaaa", "2": "sha256:bbbb", "3": "sha256:cccc"},
+		descriptions: map[string]string{},
+	}
+	return &rollbacker{
+		lambda:     functions,
+		ecr:        fakeECR{pushed("sha256:aaaa", 1), pushed("sha256:cccc", 3, "latest"), pushed("sha256:bbbb", 2), pushed("sha256:abcd", 1)},
+		function:   "hello",
+		repository: "hello-world",
+	}, functions
+}
+
+func TestHistory(t *testing.T) {
+	r, _ := newRollbacker()
+	h, err := r.history(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.repositoryURI != repo || h.current != "sha256:cccc" {
+		t.Errorf("deployed %s@%s, want %s@sha256:cccc", h.repositoryURI, h.current, repo)
+	}
+	if len(h.versions) != 3 || h.versions[0].version != "3" || h.versions[2].digest != "sha256:aaaa" {
+		t.Errorf("versions = %+v, want 3, 2, 1 with their images", h.versions)
+	}
+
+	var out bytes.Buffer
+	h.print(&out)
+	for _, want := range []string{"* cccc  2024-07-03 10:00  latest", "  bbbb  2024-07-02 10:00", "  2    bbbb  2024-07-01T10:00:00.000+0000"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("listing is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTargets(t *testing.T) {
+	r, functions := newRollbacker()
+	ctx := context.Background()
+	h, err := r.history(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest, _, err := h.previous(); err != nil || digest != "sha256:bbbb" {
+		t.Errorf("previous() = %s, %v, want sha256:bbbb", digest, err)
+	}
+	if digest, err := r.versionDigest(ctx, "1"); err != nil || digest != "sha256:aaaa" {
+		t.Errorf("versionDigest(1) = %s, %v, want sha256:aaaa", digest, err)
+	}
+	if _, err := r.versionDigest(ctx, "live"); err == nil {
+		t.Error("versionDigest(live) succeeded, want an error for an alias")
+	}
+	if digest, err := h.find("bb"); err != nil || digest != "sha256:bbbb" {
+		t.Errorf("find(bb) = %s, %v, want sha256:bbbb", digest, err)
+	}
+	if _, err := h.find("sha256:a"); err == nil || !strings.Contains(err.Error(), "matches 2 images") {
+		t.Errorf("find(sha256:a) error = %v, want an ambiguous prefix", err)
+	}
+
+	if err := r.rollback(ctx, repo+"@sha256:bbbb", ""); err != nil {
+		t.Fatal(err)
+	}
+	if functions.digests[""] != "sha256:bbbb" {
+		t.Errorf("$LATEST runs %s after rollback, want sha256:bbbb", functions.digests[""])
+	}
+	// A second -previous steps further back
+	if h, err = r.history(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if digest, _, err := h.previous(); err != nil || digest != "sha256:aaaa" {
+		t.Errorf("previous() after rollback = %s, %v, want sha256:aaaa", digest, err)
+	}
+}
+
+func TestRollbackAlias(t *testing.T) {
+	r, functions := newRollbacker()
+	r.alias = "live"
+	functions.digests["live"] = "sha256:cccc"
+	ctx := context.Background()
+
+	// A published version is pointed to as it is
+	if err := r.rollback(ctx, repo+"@sha256:aaaa", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if functions.digests["live"] != "sha256:aaaa" || functions.digests[""] != "sha256:cccc" {
+		t.Errorf("live runs %s and $LATEST %s, want sha256:aaaa and an untouched $LATEST", functions.digests["live"], functions.digests[""])
+	}
+
+	// An image gets a version of its own
+	if err := r.rollback(ctx, repo+"@sha256:bbbb", ""); err != nil {
+		t.Fatal(err)
+	}
+	if functions.digests["live"] != "sha256:bbbb" || functions.digests["5"] != "sha256:bbbb" {
+		t.Errorf("live runs %s, version 5 %s; want both on sha256:bbbb", functions.digests["live"], functions.digests["5"])
+	}
+	h, err := r.history(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.current != "sha256:bbbb" {
+		t.Errorf("history is relative to %s, want the alias's sha256:bbbb", h.current)
+	}
+}
+
+func TestAliasTargetsOnlyItsVersions(t *testing.T) {
+	r, functions := newRollbacker()
+	r.alias = "live"
+	// 1 and 3 were published for live, 2 for staging with its environment
+	functions.descriptions["1"] = config.AliasVersionDescription("live")
+	functions.descriptions["2"] = config.AliasVersionDescription("staging")
+	functions.descriptions["3"] = config.AliasVersionDescription("live")
+	functions.digests["live"], functions.descriptions["live"] = "sha256:cccc", functions.descriptions["3"]
+	ctx := context.Background()
+
+	if _, err := r.versionDigest(ctx, "2"); err == nil || !strings.Contains(err.Error(), "not published for alias live") {
+		t.Errorf("versionDigest(2) error = %v, want staging's version refused", err)
+	}
+	if digest, err := r.versionDigest(ctx, "1"); err != nil || digest != "sha256:aaaa" {
+		t.Errorf("versionDigest(1) = %s, %v, want sha256:aaaa", digest, err)
+	}
+
+	h, err := r.history(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.versions) != 2 || h.versions[0].version != "3" || h.versions[1].version != "1" {
+		t.Errorf("versions = %+v, want live's 3 and 1", h.versions)
+	}
+	// bbbb was pushed before cccc, but only staging has a version of it
+	if _, _, err := h.previous(); err == nil || !strings.Contains(err.Error(), "no version published for alias live") {
+		t.Errorf("previous() error = %v, want no version of live", err)
+	}
+
+	// A rollback to an image publishes a version for the alias, which
+	// -previous can then go back to
+	if err := r.rollback(ctx, repo+"@sha256:bbbb", ""); err != nil {
+		t.Fatal(err)
+	}
+	functions.digests["live"] = "sha256:cccc"
+	if h, err = r.history(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if digest, version, err := h.previous(); err != nil || digest != "sha256:bbbb" || version != "5" {
+		t.Errorf("previous() = %s, %s, %v; want version 5 with sha256:bbbb", digest, version, err)
+	}
+}
@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/rules"
+)
+
+// Main checks the configuration against the rules section, as setup and
+// deploy do before they change anything, and exits 1 on a denial.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template rules", flag.ExitOnError)
+	list := flags.Bool("list", false, "List the built-in rules and their levels instead")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template rules [-list]")
+		fmt.Fprintln(os.Stderr, "Checks config.yaml and the Dockerfile against the built-in and custom rules in the")
+		fmt.Fprintln(os.Stderr, "rules section, and those of the organization bundle it names, and exits 1 if any")
+		fmt.Fprintln(os.Stderr, "rule set to deny does not hold.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	if *list {
+		inForce, err := cfg.RulesInForce(ctx, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range rules.BuiltinNames() {
+			fmt.Printf("%-18s %s\n", name, inForce.Level(name))
+		}
+		for _, rule := range inForce.Custom {
+			level := rule.Level
+			if level == "" {
+				level = rules.Warn
+			}
+			fmt.Printf("%-18s %s (custom, %s)\n", rule.Name, level, rule.Field)
+		}
+		return
+	}
+
+	if err := cfg.CheckRules(ctx, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("No rule set to deny is broken")
+}
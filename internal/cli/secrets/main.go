@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"example-lambda-go/internal/config"
+)
+
+// secretValue is a resolved parameter or secret. Values never leave this
+// process; only their hashes are printed.
+type secretValue struct {
+	Name   string
+	Value  string
+	Type   ssmtypes.ParameterType
+	Binary bool
+	Exists bool
+}
+
+type store interface {
+	Get(ctx context.Context, name string) (secretValue, error)
+	Create(ctx context.Context, from secretValue, name string) error
+}
+
+// Main compares or copies secrets between environments.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template secrets", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template secrets diff|sync -from ENV -to ENV")
+		flags.PrintDefaults()
+	}
+	from := flags.String("from", "", "Source environment name substituted for {env}")
+	to := flags.String("to", "", "Target environment name substituted for {env}")
+	flags.Parse(reorderArgs(args))
+	action := flags.Arg(0)
+	if (action != "diff" && action != "sync") || *from == "" || *to == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if len(cfg.Secrets.Parameters) == 0 && len(cfg.Secrets.SecretsManager) == 0 {
+		log.Fatal("No secrets configured. Add parameters or secrets_manager names under secrets: in config.yaml.")
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	type entry struct {
+		store    store
+		template string
+	}
+	var entries []entry
+	parameters := &parameterStore{client: ssm.NewFromConfig(awsCfg)}
+	for _, name := range cfg.Secrets.Parameters {
+		entries = append(entries, entry{parameters, name})
+	}
+	secrets := &secretsManagerStore{client: secretsmanager.NewFromConfig(awsCfg)}
+	for _, name := range cfg.Secrets.SecretsManager {
+		entries = append(entries, entry{secrets, name})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME (%s)\t%s\t%s\tSTATUS\n", *to, *from, *to)
+	missing, copied := 0, 0
+	for _, e := range entries {
+		source, err := e.store.Get(context.TODO(), expand(e.template, *from))
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", expand(e.template, *from), err)
+		}
+		target, err := e.store.Get(context.TODO(), expand(e.template, *to))
+		if err != nil {
+			log.Fatalf("Error reading %s: %v", expand(e.template, *to), err)
+		}
+
+		status := "same"
+		switch {
+		case !source.Exists && !target.Exists:
+			status = "missing in both"
+		case !source.Exists:
+			status = "missing in " + *from
+		case !target.Exists:
+			status = "missing"
+			missing++
+			if action == "sync" {
+				if err := e.store.Create(context.TODO(), source, target.Name); err != nil {
+					log.Fatalf("Error creating %s: %v", target.Name, err)
+				}
+				status = "copied"
+				copied++
+			}
+		case source.Value != target.Value:
+			status = "different"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", target.Name, hash(source), hash(target), status)
+	}
+	w.Flush()
+
+	if action == "sync" {
+		fmt.Printf("\nCopied %d missing value(s) from %s to %s.\n", copied, *from, *to)
+	} else if missing > 0 {
+		fmt.Printf("\n%d value(s) missing in %s. Run `secrets sync -from %s -to %s` to copy them.\n", missing, *to, *from, *to)
+		os.Exit(1)
+	}
+}
+
+// reorderArgs moves the positional action behind the flags so both
+// `secrets diff -from a -to b` and `secrets -from a -to b diff` work.
+func reorderArgs(args []string) []string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return append(args[1:], args[0])
+	}
+	return args
+}
+
+func expand(template, env string) string {
+	return strings.ReplaceAll(template, "{env}", env)
+}
+
+func hash(v secretValue) string {
+	if !v.Exists {
+		return "-"
+	}
+	sum := sha256.Sum256([]byte(v.Value))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+type parameterStore struct {
+	client *ssm.Client
+}
+
+func (s *parameterStore) Get(ctx context.Context, name string) (secretValue, error) {
+	output, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	var notFound *ssmtypes.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return secretValue{Name: name}, nil
+	}
+	if err != nil {
+		return secretValue{}, err
+	}
+	return secretValue{
+		Name:   name,
+		Value:  aws.ToString(output.Parameter.Value),
+		Type:   output.Parameter.Type,
+		Exists: true,
+	}, nil
+}
+
+func (s *parameterStore) Create(ctx context.Context, from secretValue, name string) error {
+	_, err := s.client.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:  aws.String(name),
+		Value: aws.String(from.Value),
+		Type:  from.Type,
+	})
+	return err
+}
+
+type secretsManagerStore struct {
+	client *secretsmanager.Client
+}
+
+func (s *secretsManagerStore) Get(ctx context.Context, name string) (secretValue, error) {
+	output, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	var notFound *smtypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return secretValue{Name: name}, nil
+	}
+	if err != nil {
+		return secretValue{}, err
+	}
+	if output.SecretBinary != nil {
+		return secretValue{Name: name, Value: string(output.SecretBinary), Binary: true, Exists: true}, nil
+	}
+	return secretValue{Name: name, Value: aws.ToString(output.SecretString), Exists: true}, nil
+}
+
+func (s *secretsManagerStore) Create(ctx context.Context, from secretValue, name string) error {
+	input := &secretsmanager.CreateSecretInput{Name: aws.String(name)}
+	if from.Binary {
+		input.SecretBinary = []byte(from.Value)
+	} else {
+		input.SecretString = aws.String(from.Value)
+	}
+	_, err := s.client.CreateSecret(ctx, input)
+	return err
+}
@@ -0,0 +1,58 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	appconfig "example-lambda-go/internal/config"
+)
+
+// createDeployAlias creates deploy.alias, pointed at a version published
+// from the new function, so the triggers setup creates next have an alias
+// to invoke. An alias that exists already is left to deploy, which also
+// gives a new one the alias's own environment on its first run.
+func createDeployAlias(ctx context.Context) error {
+	functionName, alias := config.Lambda.FunctionName, config.Deploy.Alias
+	_, err := api.lambda.GetAlias(ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(functionName),
+		Name:         aws.String(alias),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if err == nil {
+		fmt.Printf("Alias %s already exists\n", alias)
+		return nil
+	}
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("error reading alias %s: %v", alias, err)
+	}
+
+	// A function can only be published once it is active
+	waiter := lambda.NewFunctionActiveV2Waiter(api.lambda)
+	if err := waiter.Wait(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("error waiting for the function to become active: %v", err)
+	}
+	version, err := api.lambda.PublishVersion(ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(functionName),
+		Description:  aws.String(appconfig.AliasVersionDescription(alias)),
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing version: %v", err)
+	}
+	_, err = api.lambda.CreateAlias(ctx, &lambda.CreateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(alias),
+		FunctionVersion: version.Version,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating alias %s: %v", alias, err)
+	}
+
+	fmt.Printf("Alias %s created at version %s\n", alias, aws.ToString(version.Version))
+	return nil
+}
@@ -0,0 +1,206 @@
+package setup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+// assetsOrigin is the ID of the assets bucket among the distribution's origins.
+const assetsOrigin = "assets"
+
+// setupAssetsBucket creates the private bucket cdn.assets serves from and the
+// origin access control CloudFront reads it with, returning the control's ID.
+func setupAssetsBucket() (string, error) {
+	bucket := config.CDN.Assets.Bucket
+	args := []string{"aws", "s3api", "create-bucket", "--bucket", bucket}
+	// us-east-1 is the default location and can't be given as a constraint
+	if config.AWS.Region != "us-east-1" {
+		args = append(args, "--create-bucket-configuration", "LocationConstraint="+config.AWS.Region)
+	}
+	args = append(args, "--profile", config.AWS.Profile, "--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(exec.Command(args[0], args[1:]...))
+	switch {
+	case err == nil:
+		// delete removes the bucket and its objects only with these tags
+		if err := tagAssetsBucket(bucket); err != nil {
+			return "", err
+		}
+		fmt.Printf("Assets bucket '%s' created successfully\n", bucket)
+	case !strings.Contains(string(output), "BucketAlreadyOwnedByYou"):
+		return "", fmt.Errorf("error creating assets bucket: %v\n%s", err, output)
+	case assetsBucketTagged(bucket):
+		fmt.Println("Assets bucket already exists")
+	default:
+		fmt.Printf("Assets bucket '%s' already exists and was not created by setup; delete will keep it\n", bucket)
+	}
+
+	blockCmd := exec.Command("aws", "s3api", "put-public-access-block",
+		"--bucket", bucket,
+		"--public-access-block-configuration", "BlockPublicAcls=true,IgnorePublicAcls=true,BlockPublicPolicy=true,RestrictPublicBuckets=true",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(blockCmd); err != nil {
+		return "", fmt.Errorf("error blocking public access to the assets bucket: %v\n%s", err, output)
+	}
+	return getOrCreateOriginAccessControl(config.AssetsAccessName(), "s3")
+}
+
+// tagAssetsBucket puts createdTags on a bucket setup just created.
+func tagAssetsBucket(bucket string) error {
+	type tag struct{ Key, Value string }
+	var tagging struct{ TagSet []tag }
+	for _, key := range tagKeys() {
+		tagging.TagSet = append(tagging.TagSet, tag{key, createdTags[key]})
+	}
+	data, err := json.Marshal(tagging)
+	if err != nil {
+		return fmt.Errorf("error encoding assets bucket tags: %v", err)
+	}
+	tagCmd := exec.Command("aws", "s3api", "put-bucket-tagging",
+		"--bucket", bucket,
+		"--tagging", string(data),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(tagCmd); err != nil {
+		return fmt.Errorf("error tagging assets bucket: %v\n%s", err, output)
+	}
+	return nil
+}
+
+// assetsBucketTagged reports whether an existing bucket carries the tags
+// setup puts on the buckets it creates, for this project. A bucket without
+// tags answers with an error.
+func assetsBucketTagged(bucket string) bool {
+	getCmd := exec.Command("aws", "s3api", "get-bucket-tagging",
+		"--bucket", bucket,
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(getCmd)
+	if err != nil {
+		return false
+	}
+	var tagging struct{ TagSet []struct{ Key, Value string } }
+	if json.Unmarshal(output, &tagging) != nil {
+		return false
+	}
+	tags := map[string]string{}
+	for _, tag := range tagging.TagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags[appconfig.CreatedByTag] == appconfig.CreatedBy && tags[appconfig.ProjectTag] == config.ProjectID()
+}
+
+// putAssetsBucketPolicy lets the distribution, and nothing else, read the
+// assets bucket.
+func putAssetsBucketPolicy(distributionARN string) error {
+	bucketARN := config.Partition().ARN("s3", "", "", config.CDN.Assets.Bucket)
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "cloudfront.amazonaws.com"},
+			"Action":    "s3:GetObject",
+			"Resource":  bucketARN + "/*",
+			"Condition": map[string]interface{}{
+				"StringEquals": map[string]string{"AWS:SourceArn": distributionARN},
+			},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding assets bucket policy: %v", err)
+	}
+	policyCmd := exec.Command("aws", "s3api", "put-bucket-policy",
+		"--bucket", config.CDN.Assets.Bucket,
+		"--policy", string(policy),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(policyCmd); err != nil {
+		return fmt.Errorf("error putting assets bucket policy: %v\n%s", err, output)
+	}
+	fmt.Println("Assets bucket policy allows the distribution to read it")
+	return nil
+}
+
+// addAssets adds the assets bucket origin and the cache behavior routing
+// cdn.assets.path to it to a distribution config decoded from JSON. It
+// reports false if the config has the origin already.
+func addAssets(distConfig map[string]interface{}, oacID string) bool {
+	origins, _ := distConfig["Origins"].(map[string]interface{})
+	items, _ := origins["Items"].([]interface{})
+	for _, item := range items {
+		if origin, _ := item.(map[string]interface{}); origin["Id"] == assetsOrigin {
+			return false
+		}
+	}
+	items = append(items, map[string]interface{}{
+		"Id":                    assetsOrigin,
+		"DomainName":            config.CDN.Assets.Bucket + "." + config.Partition().Host("s3", config.AWS.Region),
+		"OriginAccessControlId": oacID,
+		"S3OriginConfig":        map[string]interface{}{"OriginAccessIdentity": ""},
+	})
+	distConfig["Origins"] = map[string]interface{}{"Quantity": len(items), "Items": items}
+
+	behaviors, _ := distConfig["CacheBehaviors"].(map[string]interface{})
+	behaviorItems, _ := behaviors["Items"].([]interface{})
+	// Behaviors match in order, so the assets path goes before any others
+	behaviorItems = append([]interface{}{map[string]interface{}{
+		"PathPattern":          config.CDN.AssetsPath(),
+		"TargetOriginId":       assetsOrigin,
+		"ViewerProtocolPolicy": "redirect-to-https",
+		"CachePolicyId":        appconfig.CachingOptimized,
+		"Compress":             true,
+		"AllowedMethods": map[string]interface{}{
+			"Quantity":      2,
+			"Items":         []string{"GET", "HEAD"},
+			"CachedMethods": map[string]interface{}{"Quantity": 2, "Items": []string{"GET", "HEAD"}},
+		},
+	}}, behaviorItems...)
+	distConfig["CacheBehaviors"] = map[string]interface{}{"Quantity": len(behaviorItems), "Items": behaviorItems}
+	return true
+}
+
+// addAssetsToDistribution adds the assets bucket to a distribution created
+// before cdn.assets was set.
+func addAssetsToDistribution(id, oacID string) error {
+	getCmd := exec.Command("aws", "cloudfront", "get-distribution-config",
+		"--id", id,
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.Output(getCmd)
+	if err != nil {
+		return fmt.Errorf("error getting distribution config: %v", err)
+	}
+	var current struct {
+		ETag               string
+		DistributionConfig map[string]interface{}
+	}
+	if err := json.Unmarshal(output, &current); err != nil {
+		return fmt.Errorf("error decoding distribution config: %v", err)
+	}
+	if !addAssets(current.DistributionConfig, oacID) {
+		return nil
+	}
+
+	distConfig, err := json.Marshal(current.DistributionConfig)
+	if err != nil {
+		return fmt.Errorf("error encoding distribution config: %v", err)
+	}
+	updateCmd := exec.Command("aws", "cloudfront", "update-distribution",
+		"--id", id,
+		"--if-match", current.ETag,
+		"--distribution-config", string(distConfig),
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	if output, err := hostexec.CombinedOutput(updateCmd); err != nil {
+		return fmt.Errorf("error adding the assets bucket to the distribution: %v\n%s", err, output)
+	}
+	fmt.Printf("CloudFront distribution now serves %s from the assets bucket\n", config.CDN.AssetsPath())
+	return nil
+}
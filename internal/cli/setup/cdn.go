@@ -0,0 +1,313 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+// CloudFront and web ACLs for it are global, managed through us-east-1, and
+// so is Route53.
+const cloudFrontRegion = "us-east-1"
+
+// distribution is what setupCDN needs of the function's distribution.
+type distribution struct {
+	ID, ARN, DomainName string
+}
+
+// setupCDN serves the function through CloudFront behind a WAF web ACL. The
+// function URL requires IAM auth, which only CloudFront's origin access
+// control signs for, so the WAF can't be bypassed by calling the URL.
+func setupCDN(ctx context.Context) error {
+	functionURL, err := createFunctionURL(ctx, lambdatypes.FunctionUrlAuthTypeAwsIam)
+	if err != nil {
+		return err
+	}
+	oacID, err := getOrCreateOriginAccessControl(config.CDNName(), "lambda")
+	if err != nil {
+		return err
+	}
+	webACLARN := config.CDN.WebACLARN
+	if webACLARN == "" {
+		if webACLARN, err = getOrCreateWebACL(); err != nil {
+			return err
+		}
+	}
+	var assetsOAC string
+	if config.CDN.Assets.Bucket != "" {
+		if assetsOAC, err = setupAssetsBucket(); err != nil {
+			return err
+		}
+	}
+	dist, err := getOrCreateDistribution(functionURL, oacID, webACLARN, assetsOAC)
+	if err != nil {
+		return err
+	}
+	if assetsOAC != "" {
+		if err := putAssetsBucketPolicy(dist.ARN); err != nil {
+			return err
+		}
+	}
+
+	_, err = api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName:        aws.String(config.Lambda.FunctionName),
+		Qualifier:           urlQualifier(),
+		StatementId:         aws.String("cloudfront-" + dist.ID),
+		Action:              aws.String("lambda:InvokeFunctionUrl"),
+		Principal:           aws.String("cloudfront.amazonaws.com"),
+		SourceArn:           aws.String(dist.ARN),
+		FunctionUrlAuthType: lambdatypes.FunctionUrlAuthTypeAwsIam,
+	})
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error allowing CloudFront to invoke the function URL: %v", err)
+	}
+
+	fmt.Printf("Function served at https://%s (CloudFront takes a few minutes to deploy a new distribution)\n", dist.DomainName)
+	return nil
+}
+
+// createFunctionURL gives the function a URL with the auth type and returns
+// it. A URL that already exists is switched to the auth type, so one created
+// public by hand stops being reachable around CloudFront.
+func createFunctionURL(ctx context.Context, authType lambdatypes.FunctionUrlAuthType) (string, error) {
+	output, err := api.lambda.CreateFunctionUrlConfig(ctx, &lambda.CreateFunctionUrlConfigInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+		Qualifier:    urlQualifier(),
+		AuthType:     authType,
+	})
+	if err == nil {
+		fmt.Println("Function URL created successfully")
+		return aws.ToString(output.FunctionUrl), nil
+	}
+	if !isConflict(err) {
+		return "", fmt.Errorf("error creating function URL: %v", err)
+	}
+	updated, err := api.lambda.UpdateFunctionUrlConfig(ctx, &lambda.UpdateFunctionUrlConfigInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+		Qualifier:    urlQualifier(),
+		AuthType:     authType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error updating function URL: %v", err)
+	}
+	fmt.Println("Function URL already exists")
+	return aws.ToString(updated.FunctionUrl), nil
+}
+
+// getOrCreateOriginAccessControl returns the ID of the origin access
+// control called name, creating it for originType, lambda or s3, if needed.
+func getOrCreateOriginAccessControl(name, originType string) (string, error) {
+	id, err := cloudFrontQuery([]string{"cloudfront", "list-origin-access-controls"},
+		fmt.Sprintf("OriginAccessControlList.Items[?Name=='%s'].Id | [0]", name))
+	if err != nil || id != "" {
+		return id, err
+	}
+
+	createCmd := exec.Command("aws", "cloudfront", "create-origin-access-control",
+		"--origin-access-control-config", fmt.Sprintf("Name=%s,SigningProtocol=sigv4,SigningBehavior=always,OriginAccessControlOriginType=%s", name, originType),
+		"--query", "OriginAccessControl.Id",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.CombinedOutput(createCmd)
+	if err != nil {
+		return "", fmt.Errorf("error creating origin access control: %v\n%s", err, output)
+	}
+	fmt.Printf("Origin access control '%s' created successfully\n", name)
+	return strings.TrimSpace(string(output)), nil
+}
+
+// getOrCreateWebACL creates the web ACL cdn.web_acl_arn stands in for. An
+// existing one is left as it is, rules and all.
+func getOrCreateWebACL() (string, error) {
+	name := config.WebACLName()
+	arn, err := cloudFrontQuery([]string{"wafv2", "list-web-acls", "--scope", "CLOUDFRONT"},
+		fmt.Sprintf("WebACLs[?Name=='%s'].ARN | [0]", name))
+	if err != nil || arn != "" {
+		return arn, err
+	}
+
+	rules, err := json.Marshal(webACLRules())
+	if err != nil {
+		return "", fmt.Errorf("error encoding web ACL rules: %v", err)
+	}
+	visibility, err := json.Marshal(visibilityConfig(name))
+	if err != nil {
+		return "", fmt.Errorf("error encoding web ACL visibility config: %v", err)
+	}
+	createCmd := exec.Command("aws", "wafv2", "create-web-acl",
+		"--name", name,
+		"--scope", "CLOUDFRONT",
+		"--default-action", "Allow={}",
+		"--rules", string(rules),
+		"--visibility-config", string(visibility),
+		"--query", "Summary.ARN",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.CombinedOutput(createCmd)
+	if err != nil {
+		return "", fmt.Errorf("error creating web ACL: %v\n%s", err, output)
+	}
+	fmt.Printf("Web ACL '%s' created successfully\n", name)
+	return strings.TrimSpace(string(output)), nil
+}
+
+// webACLRules are the AWS managed common and known bad inputs rule groups,
+// and the rate limit when cdn.rate_limit is set.
+func webACLRules() []map[string]interface{} {
+	var rules []map[string]interface{}
+	for _, group := range []string{"AWSManagedRulesCommonRuleSet", "AWSManagedRulesKnownBadInputsRuleSet"} {
+		rules = append(rules, map[string]interface{}{
+			"Name":     group,
+			"Priority": len(rules),
+			"Statement": map[string]interface{}{
+				"ManagedRuleGroupStatement": map[string]string{"VendorName": "AWS", "Name": group},
+			},
+			"OverrideAction":   map[string]interface{}{"None": map[string]string{}},
+			"VisibilityConfig": visibilityConfig(group),
+		})
+	}
+	if config.CDN.RateLimit > 0 {
+		rules = append(rules, map[string]interface{}{
+			"Name":     "rate-limit",
+			"Priority": len(rules),
+			"Statement": map[string]interface{}{
+				"RateBasedStatement": map[string]interface{}{"Limit": config.CDN.RateLimit, "AggregateKeyType": "IP"},
+			},
+			"Action":           map[string]interface{}{"Block": map[string]string{}},
+			"VisibilityConfig": visibilityConfig("rate-limit"),
+		})
+	}
+	return rules
+}
+
+// visibilityConfig publishes a web ACL's or rule's metrics under metric.
+func visibilityConfig(metric string) map[string]interface{} {
+	return map[string]interface{}{"SampledRequestsEnabled": true, "CloudWatchMetricsEnabled": true, "MetricName": metric}
+}
+
+// getOrCreateDistribution finds the function's distribution by its comment
+// or creates it. An existing distribution is not updated, except to add the
+// assets bucket when it has been configured since.
+func getOrCreateDistribution(functionURL, oacID, webACLARN, assetsOAC string) (distribution, error) {
+	var dist distribution
+	found, err := cloudFrontQuery([]string{"cloudfront", "list-distributions"},
+		fmt.Sprintf("DistributionList.Items[?Comment=='%s'] | [0].[Id, ARN, DomainName]", config.CDNName()))
+	if err != nil {
+		return dist, err
+	}
+	if found != "" {
+		fmt.Println("CloudFront distribution already exists")
+		if dist, err = parseDistribution(found); err != nil || assetsOAC == "" {
+			return dist, err
+		}
+		return dist, addAssetsToDistribution(dist.ID, assetsOAC)
+	}
+
+	origin, err := url.Parse(functionURL)
+	if err != nil || origin.Host == "" {
+		return dist, fmt.Errorf("function URL %q has no host", functionURL)
+	}
+	newConfig := distributionConfig(origin.Host, oacID, webACLARN)
+	if assetsOAC != "" {
+		addAssets(newConfig, assetsOAC)
+	}
+	distConfig, err := json.Marshal(newConfig)
+	if err != nil {
+		return dist, fmt.Errorf("error encoding distribution config: %v", err)
+	}
+	createCmd := exec.Command("aws", "cloudfront", "create-distribution",
+		"--distribution-config", string(distConfig),
+		"--query", "Distribution.[Id, ARN, DomainName]",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.CombinedOutput(createCmd)
+	if err != nil {
+		return dist, fmt.Errorf("error creating CloudFront distribution: %v\n%s", err, output)
+	}
+	fmt.Println("CloudFront distribution created successfully")
+	return parseDistribution(string(output))
+}
+
+func parseDistribution(text string) (distribution, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 3 {
+		return distribution{}, fmt.Errorf("unexpected distribution description %q", text)
+	}
+	return distribution{ID: fields[0], ARN: fields[1], DomainName: fields[2]}, nil
+}
+
+func distributionConfig(originHost, oacID, webACLARN string) map[string]interface{} {
+	priceClass := config.CDN.PriceClass
+	if priceClass == "" {
+		priceClass = "PriceClass_All"
+	}
+	methods := []string{"GET", "HEAD", "OPTIONS", "PUT", "PATCH", "POST", "DELETE"}
+	return map[string]interface{}{
+		"CallerReference": fmt.Sprintf("%s-%d", config.CDNName(), time.Now().Unix()),
+		"Comment":         config.CDNName(),
+		"Enabled":         true,
+		"PriceClass":      priceClass,
+		"HttpVersion":     "http2and3",
+		"WebACLId":        webACLARN,
+		"Origins": map[string]interface{}{
+			"Quantity": 1,
+			"Items": []interface{}{map[string]interface{}{
+				"Id":                    "function-url",
+				"DomainName":            originHost,
+				"OriginAccessControlId": oacID,
+				"CustomOriginConfig": map[string]interface{}{
+					"HTTPPort":             80,
+					"HTTPSPort":            443,
+					"OriginProtocolPolicy": "https-only",
+					"OriginSslProtocols":   map[string]interface{}{"Quantity": 1, "Items": []string{"TLSv1.2"}},
+				},
+			}},
+		},
+		"DefaultCacheBehavior": map[string]interface{}{
+			"TargetOriginId":        "function-url",
+			"ViewerProtocolPolicy":  "redirect-to-https",
+			"CachePolicyId":         config.CDN.CachePolicyID(),
+			"OriginRequestPolicyId": appconfig.AllViewerExceptHostHeader,
+			"Compress":              true,
+			"AllowedMethods": map[string]interface{}{
+				"Quantity":      len(methods),
+				"Items":         methods,
+				"CachedMethods": map[string]interface{}{"Quantity": 2, "Items": []string{"GET", "HEAD"}},
+			},
+		},
+	}
+}
+
+// cloudFrontQuery runs a list command of one of the global services with a
+// JMESPath query and returns its text output, empty when the query matched
+// nothing.
+func cloudFrontQuery(args []string, query string) (string, error) {
+	args = append(args,
+		"--query", query,
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.CombinedOutput(exec.Command("aws", args...))
+	if err != nil {
+		return "", fmt.Errorf("error running aws %s: %v\n%s", strings.Join(args[:2], " "), err, output)
+	}
+	text := strings.TrimSpace(string(output))
+	if text == "None" {
+		return "", nil
+	}
+	return text, nil
+}
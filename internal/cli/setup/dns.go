@@ -0,0 +1,143 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// setupDNS health-checks this region's endpoint and points the region's
+// record for dns.name at it. The records of the other regions, added by
+// their own setup runs, are left alone.
+func setupDNS(ctx context.Context) error {
+	healthCheckID, err := getOrCreateHealthCheck()
+	if err != nil {
+		return err
+	}
+	changeBatch, err := json.Marshal(map[string]interface{}{
+		"Comment": "lambda-template setup of " + config.HealthCheckReference(),
+		"Changes": []map[string]interface{}{{
+			"Action":            "UPSERT",
+			"ResourceRecordSet": dnsRecord(healthCheckID),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding DNS record: %v", err)
+	}
+	changeCmd := exec.Command("aws", "route53", "change-resource-record-sets",
+		"--hosted-zone-id", config.DNS.ZoneID,
+		"--change-batch", string(changeBatch),
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	if output, err := hostexec.CombinedOutput(changeCmd); err != nil {
+		return fmt.Errorf("error updating DNS record: %v\n%s", err, output)
+	}
+	fmt.Printf("%s routes to %s (%s) while its health check passes\n", config.DNS.Name, config.DNS.Target, config.AWS.Region)
+	return nil
+}
+
+// dnsRecord is the region's CNAME for dns.name. The region is the set
+// identifier, which keeps the records of different regions apart.
+func dnsRecord(healthCheckID string) map[string]interface{} {
+	record := map[string]interface{}{
+		"Name":            config.DNS.Name,
+		"Type":            "CNAME",
+		"SetIdentifier":   config.AWS.Region,
+		"TTL":             60,
+		"HealthCheckId":   healthCheckID,
+		"ResourceRecords": []map[string]string{{"Value": config.DNS.Target}},
+	}
+	if config.DNS.RoutingPolicy() == "latency" {
+		record["Region"] = config.AWS.Region
+	} else {
+		record["Failover"] = config.DNS.Failover
+	}
+	return record
+}
+
+// getOrCreateHealthCheck returns the region's health check, pointed at the
+// current dns.target and path. The check is found by its Name tag: Route53
+// never accepts a caller reference twice, so a check recreated after delete
+// needs a new one.
+func getOrCreateHealthCheck() (string, error) {
+	reference := config.HealthCheckReference()
+	id, err := findHealthCheck(reference)
+	if err != nil {
+		return "", err
+	}
+
+	if id != "" {
+		updateCmd := exec.Command("aws", "route53", "update-health-check",
+			"--health-check-id", id,
+			"--fully-qualified-domain-name", config.DNS.Target,
+			"--resource-path", config.DNS.HealthCheckResourcePath(),
+			"--profile", config.AWS.Profile,
+			"--region", cloudFrontRegion)
+		if output, err := hostexec.CombinedOutput(updateCmd); err != nil {
+			return "", fmt.Errorf("error updating health check: %v\n%s", err, output)
+		}
+		return id, nil
+	}
+
+	createCmd := exec.Command("aws", "route53", "create-health-check",
+		"--caller-reference", fmt.Sprintf("%s-%d", reference, time.Now().Unix()),
+		"--health-check-config", fmt.Sprintf("Type=HTTPS,FullyQualifiedDomainName=%s,ResourcePath=%s,Port=443,EnableSNI=true,RequestInterval=30,FailureThreshold=3",
+			config.DNS.Target, config.DNS.HealthCheckResourcePath()),
+		"--query", "HealthCheck.Id",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	output, err := hostexec.CombinedOutput(createCmd)
+	if err != nil {
+		return "", fmt.Errorf("error creating health check: %v\n%s", err, output)
+	}
+	id = strings.TrimSpace(string(output))
+
+	// The Name tag is what setup and delete find the check by, and what the
+	// Route53 console lists health checks by
+	tagCmd := exec.Command("aws", "route53", "change-tags-for-resource",
+		"--resource-type", "healthcheck",
+		"--resource-id", id,
+		"--add-tags", "Key=Name,Value="+reference,
+		"--profile", config.AWS.Profile,
+		"--region", cloudFrontRegion)
+	if output, err := hostexec.CombinedOutput(tagCmd); err != nil {
+		// Untagged, the check would never be found again
+		deleteCmd := exec.Command("aws", "route53", "delete-health-check",
+			"--health-check-id", id,
+			"--profile", config.AWS.Profile,
+			"--region", cloudFrontRegion)
+		hostexec.CombinedOutput(deleteCmd)
+		return "", fmt.Errorf("error tagging health check: %v\n%s", err, output)
+	}
+	fmt.Printf("Health check '%s' created for https://%s%s\n", reference, config.DNS.Target, config.DNS.HealthCheckResourcePath())
+	return id, nil
+}
+
+// findHealthCheck returns the ID of the health check whose Name tag is
+// reference, or "" if there is none.
+func findHealthCheck(reference string) (string, error) {
+	ids, err := cloudFrontQuery([]string{"route53", "list-health-checks"}, "HealthChecks[].Id")
+	if err != nil {
+		return "", err
+	}
+	all := strings.Fields(ids)
+	// list-tags-for-resources takes up to 10 IDs
+	for start := 0; start < len(all); start += 10 {
+		batch := all[start:min(start+10, len(all))]
+		args := append([]string{"route53", "list-tags-for-resources", "--resource-type", "healthcheck", "--resource-ids"}, batch...)
+		id, err := cloudFrontQuery(args, fmt.Sprintf("ResourceTagSets[?Tags[?Key=='Name' && Value=='%s']].ResourceId | [0]", reference))
+		if err != nil {
+			return "", err
+		}
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
+}
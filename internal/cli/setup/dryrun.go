@@ -0,0 +1,68 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/explain"
+	"example-lambda-go/internal/registryauth"
+)
+
+// dryRunPlan narrows the explanation to what setup would change in the
+// account as it is now. Only read-only lookups are made: whether the role,
+// repository and function exist settles the steps that depend on it.
+func dryRunPlan(ctx context.Context) (*explain.Plan, error) {
+	awsAccountID, err := registryauth.AccountID(ctx, api.sts)
+	if err != nil {
+		return nil, err
+	}
+
+	roleExists := os.Getenv("LAMBDA_EXECUTION_ROLE_ARN") != ""
+	if !roleExists {
+		_, err := api.iam.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(config.Lambda.RoleName)})
+		var noSuchEntity *iamtypes.NoSuchEntityException
+		if err != nil && !errors.As(err, &noSuchEntity) {
+			return nil, fmt.Errorf("error getting IAM role: %v", err)
+		}
+		roleExists = err == nil
+	}
+
+	// Without the repository, the plan falls back to the URI ECR would give it
+	repositories, err := api.ecr.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{config.ECR.RepositoryName},
+	})
+	var repositoryNotFound *ecrtypes.RepositoryNotFoundException
+	if err != nil && !errors.As(err, &repositoryNotFound) {
+		return nil, fmt.Errorf("error describing ECR repository: %v", err)
+	}
+	repositoryExists := err == nil && len(repositories.Repositories) > 0
+	if repositoryExists {
+		repositoryURI = aws.ToString(repositories.Repositories[0].RepositoryUri)
+	}
+
+	_, err = api.lambda.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(config.Lambda.FunctionName),
+	})
+	var notFound *lambdatypes.ResourceNotFoundException
+	if err != nil && !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("error getting Lambda function: %v", err)
+	}
+	functionExists := err == nil
+
+	p := explainPlan(awsAccountID)
+	p.Resolve("iam:CreateRole", "if the role does not exist", !roleExists)
+	p.Resolve("iam:AttachRolePolicy", "if the role was just created", !roleExists)
+	p.Resolve("ecr:CreateRepository", "unless the repository exists", !repositoryExists)
+	p.Resolve("lambda:CreateFunction", "unless the function exists", !functionExists)
+	return p, nil
+}
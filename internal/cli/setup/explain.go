@@ -0,0 +1,334 @@
+package setup
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/explain"
+)
+
+// Plan is the -explain plan of setup with cfg, which the deployer policy is
+// generated from.
+func Plan(cfg *appconfig.Config, awsAccountID string) *explain.Plan {
+	config = *cfg
+	return explainPlan(awsAccountID)
+}
+
+// explainPlan lists the calls main makes with the current config, in the
+// same order. Keep it in step with main when adding a setup step.
+func explainPlan(awsAccountID string) *explain.Plan {
+	region := config.AWS.Region
+	roleARN := os.Getenv("LAMBDA_EXECUTION_ROLE_ARN")
+	if roleARN == "" {
+		roleARN = config.Partition().ARN("iam", "", awsAccountID, "role/"+config.Lambda.RoleName)
+	}
+	repositoryARN := config.Partition().ARN("ecr", region, awsAccountID, "repository/"+config.ECR.RepositoryName)
+	functionARN := config.Partition().ARN("lambda", region, awsAccountID, "function:"+config.Lambda.FunctionName)
+
+	p := explain.New("setup")
+	if objects := config.Rules.Bundle.ObjectARNs(config.Partition()); len(objects) > 0 {
+		p.Call("s3:GetObject", "fetch the organization rules bundle and its signature").
+			On(objects...).From("rules.bundle.url", config.Rules.Bundle.URL)
+	}
+	p.Call("iam:GetRole", "check whether the execution role exists").
+		On(roleARN).From("lambda.role_name", config.Lambda.RoleName).
+		If("unless LAMBDA_EXECUTION_ROLE_ARN is set")
+	p.Call("iam:CreateRole", "create the execution role, trusted by lambda.amazonaws.com").
+		On(roleARN).Needs("iam:TagRole", roleARN).If("if the role does not exist")
+	p.Call("iam:AttachRolePolicy", "attach AWSLambdaBasicExecutionRole for CloudWatch Logs").
+		On(roleARN).If("if the role was just created")
+	p.Call("ecr:CreateRepository", "create the image repository").
+		On(repositoryARN).Needs("ecr:TagResource", repositoryARN).From("ecr.repository_name", config.ECR.RepositoryName).
+		If("unless the repository exists")
+	p.Call("ecr:DescribeRepositories", "look up the repository URI to push to").On(repositoryARN)
+	p.Call("sts:GetCallerIdentity", "look up the account ID for resource ARNs").NoPermission()
+	if config.SeparateRegistry() {
+		p.Call("ecr:GetRepositoryPolicy", "read the repository policy, as aws.profiles.registry").On(repositoryARN)
+		p.Call("ecr:SetRepositoryPolicy", "let this account and its functions pull the images").
+			On(repositoryARN).
+			From("aws.profiles.registry", config.AWS.Profiles.Registry)
+	}
+
+	if config.Export.Bucket != "" {
+		p.Call("iam:PutRolePolicy", "grant the function access to the export source, bucket and Glue table (export-access)").
+			On(roleARN).From("export.bucket", config.Export.Bucket).From("export.source.type", config.Export.Source.Type)
+	}
+	if config.Events.BusName != "" {
+		if config.Events.BusName != "default" {
+			p.Call("events:CreateEventBus", "create the event bus").
+				On(config.Partition().ARN("events", region, awsAccountID, "event-bus/"+config.Events.BusName)).
+				From("events.bus_name", config.Events.BusName)
+		}
+		p.Call("iam:PutRolePolicy", "allow the function to publish to the bus (events-publish)").
+			On(roleARN).From("events.bus_name", config.Events.BusName)
+	}
+	if config.DynConfig.Parameter != "" {
+		p.Call("iam:PutRolePolicy", "allow the function to read its dynamic configuration (dynconfig-read)").
+			On(roleARN).From("dynconfig.parameter", config.DynConfig.Parameter)
+	}
+	if ids := config.SecretIDs(); len(ids) > 0 {
+		p.Call("iam:PutRolePolicy", "allow the function to read the secrets its environment references (secrets-read)").
+			On(roleARN).From("lambda.environment", strings.Join(ids, ", "))
+	}
+	if config.Lambda.DeadLetterARN != "" {
+		p.Call("iam:PutRolePolicy", "allow the function to send failed events to its dead-letter target (dead-letter)").
+			On(roleARN).From("lambda.dead_letter_arn", config.Lambda.DeadLetterARN)
+	}
+	if config.Shadow.Function != "" {
+		p.Call("iam:PutRolePolicy", "allow the function to invoke its shadow (shadow-invoke)").
+			On(roleARN).From("shadow.function", config.Shadow.Function)
+	}
+	if config.Database.ProxyName != "" {
+		p.Call("rds:DescribeDBProxies", "look up the RDS Proxy endpoint and resource ID").
+			On(config.Partition().ARN("rds", region, awsAccountID, "db-proxy:*")).
+			From("database.proxy_name", config.Database.ProxyName)
+		p.Call("iam:PutRolePolicy", "allow the function to connect as the database user (rds-connect)").
+			On(roleARN).From("database.user", config.Database.User)
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		p.Call("iam:AttachRolePolicy", "attach AWSLambdaVPCAccessExecutionRole so the function can join the VPC").
+			On(roleARN).From("vpc.subnet_ids", strings.Join(config.VPC.SubnetIDs, ","))
+		if config.Cache.SecurityGroupID != "" {
+			p.Call("ec2:AuthorizeSecurityGroupIngress", "open the cache port to the function's security groups").
+				On(config.Partition().ARN("ec2", region, awsAccountID, "security-group/"+config.Cache.SecurityGroupID)).
+				From("cache.security_group_id", config.Cache.SecurityGroupID).From("cache.address", config.Cache.Address).
+				If("once per vpc.security_group_ids entry")
+		}
+	}
+	if config.Lambda.Tracing == "Active" {
+		p.Call("iam:AttachRolePolicy", "attach AWSXRayDaemonWriteAccess so the function can send traces").
+			On(roleARN).From("lambda.tracing", config.Lambda.Tracing)
+	}
+	if config.Worker.QueueName != "" {
+		queue, dlq := config.WorkerQueueName()
+		queueARNs := []string{
+			config.Partition().ARN("sqs", region, awsAccountID, dlq),
+			config.Partition().ARN("sqs", region, awsAccountID, queue),
+		}
+		p.Call("sqs:CreateQueue", "create the dead-letter queue, then the worker queue").
+			On(queueARNs...).From("worker.queue_name", config.Worker.QueueName).From("worker.fifo", config.Worker.FIFO)
+		p.Call("sqs:GetQueueUrl", "look up an existing queue").On(queueARNs...).If("if a queue already exists")
+		p.Call("sqs:SetQueueAttributes", "update the attributes of an existing queue").On(queueARNs...).If("if a queue already exists")
+		p.Call("iam:PutRolePolicy", "allow the function to consume and send jobs (worker-queue)").On(roleARN)
+	}
+	if config.Triggers.SQS.Enabled() {
+		p.Call("iam:PutRolePolicy", "allow the function to consume the triggers.sqs queue (sqs-trigger)").
+			On(roleARN).From("triggers.sqs.queue", config.Triggers.SQS.Queue)
+	}
+	if config.Triggers.Kinesis.Enabled() {
+		p.Call("iam:PutRolePolicy", "allow the function to read the triggers.kinesis stream (kinesis-trigger)").
+			On(roleARN).From("triggers.kinesis.stream", config.Triggers.Kinesis.Stream).
+			From("triggers.kinesis.on_failure", config.Triggers.Kinesis.OnFailure)
+	}
+	if config.Triggers.DynamoDB.Enabled() {
+		p.Call("iam:PutRolePolicy", "allow the function to read the triggers.dynamodb stream (dynamodb-trigger)").
+			On(roleARN).From("triggers.dynamodb.stream", config.Triggers.DynamoDB.Stream)
+	}
+
+	p.Call("ecr:GetAuthorizationToken", "log Docker in to the registry")
+	p.Call("ecr:PutImage", "push the image (docker push)").
+		On(repositoryARN).
+		With("image", imageURI(awsAccountID)).
+		Needs("ecr:BatchCheckLayerAvailability").
+		Needs("ecr:InitiateLayerUpload").
+		Needs("ecr:UploadLayerPart").
+		Needs("ecr:CompleteLayerUpload")
+
+	createFunction := p.Call("lambda:CreateFunction", "create the function from the image with the config.yaml environment").
+		On(functionARN).
+		Needs("iam:PassRole", roleARN).
+		Needs("ecr:BatchGetImage", repositoryARN).
+		Needs("ecr:GetDownloadUrlForLayer", repositoryARN).
+		Needs("lambda:TagResource", functionARN).
+		With("image", imageURI(awsAccountID)).
+		With("role", roleARN).
+		From("lambda.function_name", config.Lambda.FunctionName).
+		From("lambda.timeout", config.Lambda.Timeout).
+		From("lambda.memory_size", config.Lambda.MemorySize).
+		From("lambda.ephemeral_storage", config.Lambda.EphemeralStorage).
+		If("unless the function exists")
+	if len(config.VPC.SubnetIDs) > 0 {
+		createFunction.
+			Needs("ec2:DescribeSecurityGroups", "*").
+			Needs("ec2:DescribeSubnets", "*").
+			Needs("ec2:DescribeVpcs", "*")
+	}
+	if config.Lambda.ReservedConcurrency != nil {
+		p.Call("lambda:GetFunction", "check whether the function is throttled and its reservation").On(functionARN)
+		p.Call("lambda:GetAccountSettings", "check the account's unreserved concurrency").
+			From("deploy.min_unreserved_concurrency", config.MinUnreservedConcurrency()).
+			If("unless the reservation is unchanged")
+		p.Call("lambda:PutFunctionConcurrency", "reserve concurrency for the function, or pause it with 0").
+			On(functionARN).
+			From("lambda.reserved_concurrency", *config.Lambda.ReservedConcurrency).
+			If("unless it matches or the function is throttled")
+		p.Call("lambda:TagResource", "record the reservation for `throttle off`").
+			On(functionARN).If("if the function is throttled")
+	}
+
+	// The triggers, URL and API invoke deploy.alias when it is set
+	targetARN := config.TriggerTargetARN(awsAccountID)
+	if config.Deploy.Alias != "" {
+		p.Call("lambda:GetAlias", "check whether the alias exists").
+			On(targetARN).From("deploy.alias", config.Deploy.Alias)
+		p.Call("lambda:GetFunction", "wait for the new function to become active").
+			On(functionARN).If("unless the alias exists")
+		p.Call("lambda:PublishVersion", "publish a version for the alias").
+			On(functionARN).If("unless the alias exists")
+		p.Call("lambda:CreateAlias", "point the alias at the version").
+			On(targetARN).If("unless the alias exists")
+	}
+
+	if config.Worker.QueueName != "" {
+		p.Call("lambda:CreateEventSourceMapping", "deliver worker jobs to the function").
+			From("worker.batch_size", config.Worker.BatchSize)
+	}
+	if config.Triggers.SQS.Enabled() {
+		p.Call("lambda:CreateEventSourceMapping", "deliver the queue's messages to the function").
+			From("triggers.sqs.batch_size", config.Triggers.SQS.MessagesPerBatch()).
+			From("triggers.sqs.batch_window", config.Triggers.SQS.BatchWindow).
+			If("unless the mapping exists")
+	}
+	if config.Triggers.DynamoDB.Enabled() {
+		p.Call("lambda:CreateEventSourceMapping", "deliver the stream's records to the function").
+			From("triggers.dynamodb.starting_position", config.Triggers.DynamoDB.Position()).
+			From("triggers.dynamodb.batch_size", config.Triggers.DynamoDB.RecordsPerBatch()).
+			From("triggers.dynamodb.parallelization_factor", config.Triggers.DynamoDB.BatchesPerShard()).
+			If("unless the mapping exists")
+	}
+	if config.Triggers.Kinesis.Enabled() {
+		p.Call("lambda:CreateEventSourceMapping", "deliver the stream's records to the function").
+			From("triggers.kinesis.starting_position", config.Triggers.Kinesis.Position()).
+			From("triggers.kinesis.tumbling_window", config.Triggers.Kinesis.TumblingWindow).
+			From("triggers.kinesis.on_failure", config.Triggers.Kinesis.OnFailure).
+			If("unless the mapping exists")
+	}
+	if config.Triggers.SNS.Enabled() {
+		topicARN := config.SNSTopicARN(awsAccountID)
+		p.Call("lambda:AddPermission", "allow the topic to invoke the function").
+			On(targetARN).From("triggers.sns.topic", config.Triggers.SNS.Topic)
+		p.Call("sns:Subscribe", "subscribe the function to the topic").On(topicARN)
+	}
+	if config.Triggers.S3.Enabled() {
+		bucketARN := config.S3BucketARN()
+		p.Call("lambda:AddPermission", "allow the bucket to invoke the function").
+			On(targetARN).From("triggers.s3.bucket", config.Triggers.S3.Bucket)
+		p.Call("s3:GetBucketNotification", "read the bucket's other notifications to keep them").On(bucketARN)
+		p.Call("s3:PutBucketNotification", "notify the function of "+strings.Join(config.Triggers.S3.EventTypes(), ", ")).On(bucketARN)
+	}
+	if config.Lambda.Schedule != "" {
+		ruleARN := config.Partition().ARN("events", region, awsAccountID, "rule/"+config.ScheduleRuleName())
+		p.Call("events:PutRule", "create the function's schedule").
+			On(ruleARN).Needs("events:TagResource", ruleARN).From("lambda.schedule", config.Lambda.Schedule)
+		p.Call("lambda:AddPermission", "allow the schedule to invoke the function").On(targetARN)
+		p.Call("events:PutTargets", "point the schedule at the function").On(ruleARN)
+	}
+	if config.Export.Schedule != "" {
+		ruleARN := config.Partition().ARN("events", region, awsAccountID, fmt.Sprintf("rule/%s-export", config.Lambda.FunctionName))
+		p.Call("events:PutRule", "create the export schedule").
+			On(ruleARN).Needs("events:TagResource", ruleARN).From("export.schedule", config.Export.Schedule)
+		p.Call("lambda:AddPermission", "allow the rule to invoke the function").On(targetARN)
+		p.Call("events:PutTargets", "point the rule at the function").On(ruleARN)
+	}
+	if config.FunctionURL.Enabled {
+		authType := config.FunctionURL.URLAuthType()
+		p.Call("lambda:CreateFunctionUrlConfig", "give the function a URL with "+authType+" auth").
+			On(targetARN).From("function_url.auth_type", config.FunctionURL.AuthType)
+		p.Call("lambda:UpdateFunctionUrlConfig", "switch an existing function URL to "+authType+" auth").
+			On(targetARN).If("if the function already has a URL")
+		if authType == "NONE" {
+			p.Call("lambda:AddPermission", "allow anyone to call the function URL").On(targetARN)
+		}
+	}
+	if config.API.Enabled {
+		apis := config.Partition().ARN("apigateway", region, "", "/apis")
+		p.Call("apigateway:GET", "look for the function's HTTP API (GetApis)").
+			On(apis).From("api.name", config.HTTPAPIName())
+		p.Call("apigateway:POST", "create the HTTP API with a $default route to the function (CreateApi)").
+			On(apis).
+			Needs("apigateway:POST", config.Partition().ARN("apigateway", region, "", "/tags/*")).
+			If("if it does not exist yet")
+		p.Call("lambda:AddPermission", "allow the HTTP API to invoke the function").On(targetARN)
+	}
+	if config.CDN.Enabled {
+		explainCDN(p, awsAccountID, targetARN)
+	}
+	if config.DNS.Enabled() {
+		healthChecks := config.Partition().ARN("route53", "", "", "healthcheck/*")
+		p.Call("route53:ListHealthChecks", "find the region's health check").
+			From("dns.target", config.DNS.Target)
+		p.Call("route53:ListTagsForResources", "find the health check named "+config.HealthCheckReference()).
+			On(healthChecks)
+		p.Call("route53:CreateHealthCheck", "check https://"+config.DNS.Target+config.DNS.HealthCheckResourcePath()).
+			If("if it does not exist yet")
+		p.Call("route53:ChangeTagsForResource", "name the new health check "+config.HealthCheckReference()).
+			On(healthChecks).If("if it was just created")
+		p.Call("route53:UpdateHealthCheck", "point the existing health check at dns.target").
+			On(healthChecks).If("if it existed already")
+		p.Call("route53:ChangeResourceRecordSets", "upsert the region's "+config.DNS.RoutingPolicy()+" record").
+			On(config.Partition().ARN("route53", "", "", "hostedzone/"+config.DNS.ZoneID)).
+			From("dns.name", config.DNS.Name)
+	}
+	return p
+}
+
+func explainCDN(p *explain.Plan, awsAccountID, functionARN string) {
+	distributionARN := config.Partition().ARN("cloudfront", "", awsAccountID, "distribution/*")
+	p.Call("lambda:CreateFunctionUrlConfig", "give the function a URL that requires IAM auth").
+		On(functionARN).From("cdn.enabled", true)
+	p.Call("lambda:UpdateFunctionUrlConfig", "switch an existing function URL to IAM auth").
+		On(functionARN).If("if the function already has a URL")
+	p.Call("cloudfront:ListOriginAccessControls", "look for the function's origin access control")
+	p.Call("cloudfront:CreateOriginAccessControl", "let CloudFront sign requests to the function URL").
+		If("if it does not exist yet")
+	webACL := config.CDN.WebACLARN
+	if webACL == "" {
+		webACL = config.Partition().ARN("wafv2", cloudFrontRegion, awsAccountID, "global/webacl/"+config.WebACLName()+"/*")
+		p.Call("wafv2:ListWebACLs", "look for the function's web ACL")
+		p.Call("wafv2:CreateWebACL", "create the web ACL with the AWS managed rule groups").
+			On(webACL).
+			From("cdn.rate_limit", config.CDN.RateLimit).
+			If("if it does not exist yet")
+	}
+	var bucketARN string
+	if config.CDN.Assets.Bucket != "" {
+		bucketARN = config.Partition().ARN("s3", "", "", config.CDN.Assets.Bucket)
+		p.Call("s3:CreateBucket", "create the assets bucket").
+			On(bucketARN).From("cdn.assets.bucket", config.CDN.Assets.Bucket).If("unless it exists")
+		p.Call("s3:PutBucketTagging", "mark the assets bucket as setup's, for delete").
+			On(bucketARN).If("if setup created it")
+		p.Call("s3:GetBucketTagging", "check whether an existing assets bucket is setup's").
+			On(bucketARN).If("if it exists")
+		p.Call("s3:PutBucketPublicAccessBlock", "keep the assets bucket private").On(bucketARN)
+		p.Call("cloudfront:CreateOriginAccessControl", "let CloudFront sign requests to the assets bucket").
+			If("if it does not exist yet")
+	}
+	p.Call("cloudfront:ListDistributions", "look for the function's distribution")
+	p.Call("cloudfront:CreateDistribution", "serve the function URL through CloudFront").
+		On(distributionARN).
+		Needs("wafv2:GetWebACL", webACL).
+		From("cdn.cache_policy", config.CDN.CachePolicy).
+		From("cdn.price_class", config.CDN.PriceClass).
+		From("cdn.web_acl_arn", config.CDN.WebACLARN).
+		If("if it does not exist yet")
+	if bucketARN != "" {
+		p.Call("cloudfront:GetDistributionConfig", "read an existing distribution's config").
+			On(distributionARN).If("if the distribution exists without the assets bucket")
+		p.Call("cloudfront:UpdateDistribution", "serve cdn.assets.path from the assets bucket").
+			On(distributionARN).From("cdn.assets.path", config.CDN.AssetsPath()).
+			If("if the distribution exists without the assets bucket")
+		p.Call("s3:PutBucketPolicy", "let only the distribution read the assets bucket").On(bucketARN)
+	}
+	p.Call("lambda:AddPermission", "allow the distribution to invoke the function URL").On(functionARN)
+}
+
+// imageURI is the image setup pushes and creates the function from. Until
+// the repository has been looked up, it is built from the account and region.
+func imageURI(awsAccountID string) string {
+	if repositoryURI != "" {
+		return repositoryURI + ":latest"
+	}
+	return fmt.Sprintf("%s/%s:latest", config.Partition().Registry(awsAccountID, config.AWS.Region), config.ECR.RepositoryName)
+}
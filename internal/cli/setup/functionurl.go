@@ -0,0 +1,50 @@
+package setup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// publicURLStatement is the statement ID the Lambda console gives the
+// permission that opens a URL without auth to everyone.
+const publicURLStatement = "FunctionURLAllowPublicAccess"
+
+// setupFunctionURL gives the function the URL function_url describes. A
+// URL without auth also needs a resource policy that lets anyone call it.
+func setupFunctionURL(ctx context.Context) error {
+	authType := lambdatypes.FunctionUrlAuthType(config.FunctionURL.URLAuthType())
+	functionURL, err := createFunctionURL(ctx, authType)
+	if err != nil {
+		return err
+	}
+
+	if authType == lambdatypes.FunctionUrlAuthTypeNone {
+		_, err := api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+			FunctionName:        aws.String(config.Lambda.FunctionName),
+			Qualifier:           urlQualifier(),
+			StatementId:         aws.String(publicURLStatement),
+			Action:              aws.String("lambda:InvokeFunctionUrl"),
+			Principal:           aws.String("*"),
+			FunctionUrlAuthType: lambdatypes.FunctionUrlAuthTypeNone,
+		})
+		if err != nil && !isConflict(err) {
+			return fmt.Errorf("error allowing public access to the function URL: %v", err)
+		}
+	}
+
+	fmt.Printf("Function URL (%s): %s\n", authType, functionURL)
+	return nil
+}
+
+// urlQualifier is the Qualifier of the function URL and its permissions:
+// deploy.alias when it is set, so the URL invokes the published version.
+func urlQualifier() *string {
+	if config.Deploy.Alias == "" {
+		return nil
+	}
+	return aws.String(config.Deploy.Alias)
+}
@@ -0,0 +1,112 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+// httpAPI is what setupHTTPAPI needs of the function's HTTP API.
+type httpAPI struct {
+	ID, Endpoint string
+}
+
+// httpAPIID is the ID of the HTTP API setupHTTPAPI found or created.
+var httpAPIID string
+
+// setupHTTPAPI serves the function through an API Gateway HTTP API and lets
+// API Gateway invoke it. An API that already exists is left as it is.
+func setupHTTPAPI(ctx context.Context, awsAccountID string) error {
+	functionARN := config.TriggerTargetARN(awsAccountID)
+	httpAPI, err := getOrCreateHTTPAPI(functionARN)
+	if err != nil {
+		return err
+	}
+	httpAPIID = httpAPI.ID
+
+	_, err = api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(config.TriggerTarget()),
+		StatementId:  aws.String("apigateway-" + httpAPI.ID),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("apigateway.amazonaws.com"),
+		SourceArn:    aws.String(config.Partition().ARN("execute-api", config.AWS.Region, awsAccountID, httpAPI.ID+"/*")),
+	})
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error allowing API Gateway to invoke the function: %v", err)
+	}
+
+	fmt.Printf("Function served at %s\n", httpAPI.Endpoint)
+	return nil
+}
+
+// getOrCreateHTTPAPI returns the API named api.name, creating it if needed.
+// Quick create gives the new API an integration with the function, a
+// $default route to it and a $default stage that deploys automatically. An
+// API of that name that setup did not create for this project is refused
+// rather than adopted, as delete would then remove it.
+func getOrCreateHTTPAPI(functionARN string) (httpAPI, error) {
+	name := config.HTTPAPIName()
+	existing, err := apiGatewayQuery([]string{"apigatewayv2", "get-apis"},
+		fmt.Sprintf(`Items[?Name=='%s'] | [0].[ApiId, ApiEndpoint, Tags."%s", Tags."%s"]`, name, appconfig.CreatedByTag, appconfig.ProjectTag))
+	if err != nil {
+		return httpAPI{}, err
+	}
+	if existing != "" {
+		fields := strings.Fields(existing)
+		if len(fields) != 4 || fields[2] != appconfig.CreatedBy || fields[3] != config.ProjectID() {
+			return httpAPI{}, fmt.Errorf("HTTP API '%s' exists but was not created by setup for this project; give api.name a name of its own", name)
+		}
+		fmt.Printf("HTTP API '%s' already exists\n", name)
+		return parseHTTPAPI(strings.Join(fields[:2], " "))
+	}
+
+	tags, err := json.Marshal(createdTags)
+	if err != nil {
+		return httpAPI{}, fmt.Errorf("error encoding tags: %v", err)
+	}
+	created, err := apiGatewayQuery([]string{"apigatewayv2", "create-api",
+		"--name", name,
+		"--protocol-type", "HTTP",
+		"--target", functionARN,
+		"--tags", string(tags)},
+		"[ApiId, ApiEndpoint]")
+	if err != nil {
+		return httpAPI{}, err
+	}
+	fmt.Printf("HTTP API '%s' created successfully\n", name)
+	return parseHTTPAPI(created)
+}
+
+func parseHTTPAPI(text string) (httpAPI, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return httpAPI{}, fmt.Errorf("unexpected HTTP API description %q", text)
+	}
+	return httpAPI{ID: fields[0], Endpoint: fields[1]}, nil
+}
+
+// apiGatewayQuery is cloudFrontQuery for the function's region.
+func apiGatewayQuery(args []string, query string) (string, error) {
+	args = append(args,
+		"--query", query,
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.CombinedOutput(exec.Command("aws", args...))
+	if err != nil {
+		return "", fmt.Errorf("error running aws %s: %v\n%s", strings.Join(args[:2], " "), err, output)
+	}
+	text := strings.TrimSpace(string(output))
+	if text == "None" {
+		return "", nil
+	}
+	return text, nil
+}
@@ -0,0 +1,1165 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/concurrency"
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+	"example-lambda-go/internal/explain"
+	"example-lambda-go/internal/filelock"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/lambdaenv"
+	"example-lambda-go/internal/output"
+	"example-lambda-go/internal/pipeline"
+	"example-lambda-go/internal/registryauth"
+	"example-lambda-go/internal/rolepolicy"
+)
+
+var config appconfig.Config
+
+// api holds the SDK clients, built from config once it is loaded.
+var api clients
+
+// repositoryURI is the ECR repository images are pushed to, as reported by
+// ECR, e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com/hello-world.
+var repositoryURI string
+
+// workerQueueURL is set when worker.queue_name is configured.
+var workerQueueURL string
+
+// databaseProxy is filled from describe-db-proxies when database.proxy_name is set.
+var databaseProxy struct {
+	Endpoint   string
+	ResourceID string
+}
+
+// Main creates the role, repository and function described by config.yaml.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template setup", flag.ExitOnError)
+	explainOnly := flags.Bool("explain", false, "Print the AWS calls setup would make, the IAM permissions they need and the config feeding them, then exit")
+	dryRun := flags.Bool("dry-run", false, "Look up what already exists and print the changes setup would make, with their names and ARNs, without making any")
+	resume := flags.Bool("resume", false, "Skip the steps the previous, failed setup completed, if the configuration is unchanged")
+	flags.Parse(args)
+
+	// Load configuration
+	if err := loadConfig(); err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Without credentials -explain still prints the plan, with a placeholder
+	// account
+	ctx := context.Background()
+	awsCfg, err := config.AWSConfig(ctx)
+	if err != nil && !*explainOnly {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	registryCfg, err := config.RegistryAWSConfig(ctx)
+	if err != nil && !*explainOnly {
+		log.Fatalf("Unable to load SDK config for aws.profiles.registry: %v", err)
+	}
+	api = newClients(awsCfg, registryCfg)
+
+	if *explainOnly {
+		explainPlan(explain.AccountID(ctx, api.sts)).Print(os.Stdout)
+		return
+	}
+
+	// Nothing is built or changed, so neither the lock nor the pipeline is needed
+	if *dryRun {
+		if err := config.CheckRules(ctx, os.Stdout); err != nil {
+			log.Fatalf("Setup would be refused: %v", err)
+		}
+		plan, err := dryRunPlan(ctx)
+		if err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		plan.PrintChanges(os.Stdout)
+		return
+	}
+
+	// The OS releases the lock even if the process dies before Unlock
+	lock, err := filelock.TryLock(filepath.Join(".lambda-template", config.Lambda.FunctionName+".lock"))
+	if err != nil {
+		log.Fatalf("Another setup or deploy of %s is running from this checkout: %v", config.Lambda.FunctionName, err)
+	}
+	defer lock.Unlock()
+
+	// Read under the lock, as a concurrent setup would be writing it
+	done, err = loadProgress(*resume)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	run := pipeline.Start("setup", config.Telemetry, map[string]string{
+		"faas.name":    config.Lambda.FunctionName,
+		"cloud.region": config.AWS.Region,
+	})
+
+	// Warnings print even when the step succeeds, so they go straight to stdout
+	if err := run.Step("rules-check", func(ctx context.Context) error { return config.CheckRules(ctx, os.Stdout) }); err != nil {
+		run.Fatalf("Setup refused: %v", err)
+	}
+
+	// Check if LAMBDA_EXECUTION_ROLE_ARN exists
+	roleARN := os.Getenv("LAMBDA_EXECUTION_ROLE_ARN")
+	if roleARN == "" {
+		_, err := step(run, "role", func(ctx context.Context) error {
+			var err error
+			roleARN, err = getOrCreateLambdaExecutionRole(ctx)
+			return err
+		})
+		if err != nil {
+			run.Fatalf("Failed to get or create Lambda execution role: %v", err)
+		}
+		os.Setenv("LAMBDA_EXECUTION_ROLE_ARN", roleARN)
+	}
+
+	// Create ECR repository
+	ran, err := step(run, "ecr", func(ctx context.Context) error {
+		if err := createECRRepository(ctx); err != nil {
+			return err
+		}
+		var err error
+		repositoryURI, err = registryauth.RepositoryURI(ctx, api.ecr, config.ECR.RepositoryName)
+		return err
+	})
+	if err != nil {
+		run.Fatalf("Error creating ECR repository: %v", err)
+	}
+	if ran {
+		fmt.Println("ECR repository created successfully")
+	}
+
+	// Get AWS Account ID
+	awsAccountID, err := registryauth.AccountID(ctx, api.sts)
+	if err != nil {
+		run.Fatalf("Error getting AWS Account ID: %v", err)
+	}
+
+	// A repository in a shared registry account must let this one pull
+	if config.SeparateRegistry() {
+		if _, err := step(run, "ecr-policy", func(ctx context.Context) error { return allowRegistryPull(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error allowing %s to pull from the ECR repository: %v", awsAccountID, err)
+		}
+	}
+
+	// Grant the execution role access to the export source, bucket and Glue table
+	if config.Export.Bucket != "" {
+		if err := putExportPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching export policy: %v", err)
+		}
+	}
+
+	// Create the event bus and allow the function to publish to it
+	if config.Events.BusName != "" {
+		if err := setupEventBus(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error setting up event bus: %v", err)
+		}
+	}
+
+	// Allow the function to read its dynamic configuration parameter
+	if config.DynConfig.Parameter != "" {
+		if err := putDynConfigPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching dynamic configuration policy: %v", err)
+		}
+	}
+
+	// Allow the function to read the secrets its environment references
+	if len(config.SecretIDs()) > 0 {
+		if err := putSecretsPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching secrets policy: %v", err)
+		}
+	}
+
+	// Allow Lambda to deliver failed asynchronous invocations to the dead-letter target
+	if config.Lambda.DeadLetterARN != "" {
+		if err := putDeadLetterPolicy(ctx); err != nil {
+			run.Fatalf("Error attaching dead-letter policy: %v", err)
+		}
+	}
+
+	// Allow the function to mirror sampled invocations to its shadow
+	if config.Shadow.Function != "" {
+		if err := putShadowPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching shadow policy: %v", err)
+		}
+	}
+
+	// Allow the function to connect to the database through RDS Proxy as database.user
+	if config.Database.ProxyName != "" {
+		if err := describeDatabaseProxy(); err != nil {
+			run.Fatalf("Error looking up RDS Proxy: %v", err)
+		}
+		if err := putDatabasePolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching database policy: %v", err)
+		}
+	}
+
+	// Run the function inside the VPC so it can reach RDS Proxy and ElastiCache
+	if len(config.VPC.SubnetIDs) > 0 {
+		if err := setupVPCAccess(ctx); err != nil {
+			run.Fatalf("Error setting up VPC access: %v", err)
+		}
+	}
+
+	// Let the function send the segments of active tracing to X-Ray
+	if config.Lambda.Tracing == "Active" {
+		if err := rolepolicy.Attach(ctx, api.iam, &config, rolepolicy.XRayWrite); err != nil {
+			run.Fatalf("Error attaching X-Ray policy: %v", err)
+		}
+	}
+
+	// Create the worker queue and its dead-letter queue
+	if config.Worker.QueueName != "" {
+		if err := setupWorkerQueue(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error setting up worker queue: %v", err)
+		}
+	}
+
+	// Allow the function to consume the queue of triggers.sqs
+	if config.Triggers.SQS.Enabled() {
+		if err := putSQSTriggerPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching SQS trigger policy: %v", err)
+		}
+	}
+
+	// Allow the function to read the stream of triggers.dynamodb
+	if config.Triggers.DynamoDB.Enabled() {
+		if err := putDynamoDBTriggerPolicy(ctx); err != nil {
+			run.Fatalf("Error attaching DynamoDB trigger policy: %v", err)
+		}
+	}
+
+	// Allow the function to read the stream of triggers.kinesis
+	if config.Triggers.Kinesis.Enabled() {
+		if err := putKinesisTriggerPolicy(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error attaching Kinesis trigger policy: %v", err)
+		}
+	}
+
+	// Build and push Docker image
+	if _, err := step(run, "build-push", func(ctx context.Context) error { return buildAndPushDockerImage(ctx, output.Writer(ctx)) }); err != nil {
+		run.Fatalf("Error building and pushing Docker image: %v", err)
+	}
+
+	// Create Lambda function with a container image
+	if ran, err := step(run, "create-function", func(ctx context.Context) error { return createLambdaFunction(ctx, roleARN) }); err != nil {
+		log.Printf("Error creating Lambda function: %v", err)
+	} else if ran {
+		fmt.Println("Lambda function created successfully")
+	}
+	if config.Lambda.ReservedConcurrency != nil {
+		if _, err := step(run, "reserved-concurrency", setReservedConcurrency); err != nil {
+			run.Fatalf("Error setting reserved concurrency: %v", err)
+		}
+	}
+
+	// Give the triggers below deploy.alias to invoke
+	if config.Deploy.Alias != "" {
+		if _, err := step(run, "alias", createDeployAlias); err != nil {
+			run.Fatalf("Error creating alias %s: %v", config.Deploy.Alias, err)
+		}
+	}
+
+	// Deliver worker jobs to the function
+	if config.Worker.QueueName != "" {
+		if err := createWorkerMapping(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error creating worker queue mapping: %v", err)
+		}
+	}
+
+	// Deliver the messages of triggers.sqs to the function
+	if config.Triggers.SQS.Enabled() {
+		if _, err := step(run, "sqs-trigger", func(ctx context.Context) error { return createSQSTrigger(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error creating SQS trigger: %v", err)
+		}
+	}
+
+	// Deliver the records of triggers.dynamodb to the function
+	if config.Triggers.DynamoDB.Enabled() {
+		if _, err := step(run, "dynamodb-trigger", createDynamoDBTrigger); err != nil {
+			run.Fatalf("Error creating DynamoDB trigger: %v", err)
+		}
+	}
+
+	// Deliver the records of triggers.kinesis to the function
+	if config.Triggers.Kinesis.Enabled() {
+		if _, err := step(run, "kinesis-trigger", func(ctx context.Context) error { return createKinesisTrigger(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error creating Kinesis trigger: %v", err)
+		}
+	}
+
+	// Deliver the messages of triggers.sns to the function
+	if config.Triggers.SNS.Enabled() {
+		if _, err := step(run, "sns-trigger", func(ctx context.Context) error { return subscribeSNSTrigger(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error subscribing to SNS topic: %v", err)
+		}
+	}
+
+	// Have triggers.s3 notify the function of its events
+	if config.Triggers.S3.Enabled() {
+		if _, err := step(run, "s3-trigger", func(ctx context.Context) error { return notifyS3Trigger(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error adding S3 notification: %v", err)
+		}
+	}
+
+	// Invoke the function on lambda.schedule
+	if config.Lambda.Schedule != "" {
+		if _, err := step(run, "schedule", func(ctx context.Context) error { return createSchedule(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error creating schedule: %v", err)
+		}
+	}
+
+	// Schedule the export handler
+	if config.Export.Schedule != "" {
+		if err := createExportSchedule(ctx, awsAccountID); err != nil {
+			run.Fatalf("Error creating export schedule: %v", err)
+		}
+	}
+
+	// Give the function a URL of its own
+	if config.FunctionURL.Enabled {
+		if _, err := step(run, "function-url", setupFunctionURL); err != nil {
+			run.Fatalf("Error setting up function URL: %v", err)
+		}
+	}
+
+	// Serve the function through an API Gateway HTTP API
+	if config.API.Enabled {
+		if _, err := step(run, "api", func(ctx context.Context) error { return setupHTTPAPI(ctx, awsAccountID) }); err != nil {
+			run.Fatalf("Error setting up HTTP API: %v", err)
+		}
+	}
+
+	// Serve the function through CloudFront and WAF
+	if config.CDN.Enabled {
+		if _, err := step(run, "cdn", setupCDN); err != nil {
+			run.Fatalf("Error setting up CloudFront: %v", err)
+		}
+	}
+
+	// Route this region's share of dns.name
+	if config.DNS.Enabled() {
+		if _, err := step(run, "dns", setupDNS); err != nil {
+			run.Fatalf("Error setting up DNS: %v", err)
+		}
+	}
+
+	run.End(nil)
+	done.finish()
+	if console.Show {
+		console.Print(os.Stdout, consoleLinks(awsAccountID)...)
+	}
+}
+
+// consoleLinks are the console pages of what setup created. The HTTP API is
+// only known when its step ran rather than being resumed past.
+func consoleLinks(awsAccountID string) []console.Link {
+	name := config.Lambda.FunctionName
+	c := console.New(config.Partition(), config.AWS.Region)
+	links := []console.Link{c.Function(name, ""), c.Logs(name), c.Repository(awsAccountID, config.ECR.RepositoryName), c.Alarms()}
+	if httpAPIID != "" {
+		links = append(links, c.APIStage(httpAPIID, "$default"))
+	}
+	return links
+}
+
+func loadConfig() error {
+	cfg, err := appconfig.Load()
+	if err != nil {
+		return err
+	}
+	config = *cfg
+	createdTags = config.ResourceTags(time.Now())
+	return nil
+}
+
+// createdTags go on everything setup creates, so sweep can tell what this
+// tool created and for which function.
+var createdTags map[string]string
+
+// tagKeys returns the keys of createdTags in order, for the APIs that take a
+// list of tags.
+func tagKeys() []string {
+	keys := make([]string, 0, len(createdTags))
+	for key := range createdTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lambdaTrustPolicy lets Lambda assume the execution role.
+const lambdaTrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"lambda.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+
+func getOrCreateLambdaExecutionRole(ctx context.Context) (string, error) {
+	// Try to get the role first
+	role, err := api.iam.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(config.Lambda.RoleName),
+	})
+	if err == nil {
+		fmt.Println("Lambda execution role already exists")
+		return aws.ToString(role.Role.Arn), nil
+	}
+	var noSuchEntity *iamtypes.NoSuchEntityException
+	if !errors.As(err, &noSuchEntity) {
+		return "", fmt.Errorf("error getting IAM role: %v", err)
+	}
+
+	// If the role doesn't exist, create it
+	var tags []iamtypes.Tag
+	for _, key := range tagKeys() {
+		tags = append(tags, iamtypes.Tag{Key: aws.String(key), Value: aws.String(createdTags[key])})
+	}
+	created, err := api.iam.CreateRole(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String(config.Lambda.RoleName),
+		AssumeRolePolicyDocument: aws.String(lambdaTrustPolicy),
+		Tags:                     tags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating IAM role: %v", err)
+	}
+
+	if err := rolepolicy.Attach(ctx, api.iam, &config, rolepolicy.BasicExecution); err != nil {
+		return "", err
+	}
+
+	fmt.Println("Lambda execution role created successfully")
+	return aws.ToString(created.Role.Arn), nil
+}
+
+func createECRRepository(ctx context.Context) error {
+	var tags []ecrtypes.Tag
+	for _, key := range tagKeys() {
+		tags = append(tags, ecrtypes.Tag{Key: aws.String(key), Value: aws.String(createdTags[key])})
+	}
+	_, err := api.ecr.CreateRepository(ctx, &ecr.CreateRepositoryInput{
+		RepositoryName: aws.String(config.ECR.RepositoryName),
+		Tags:           tags,
+	})
+	var alreadyExists *ecrtypes.RepositoryAlreadyExistsException
+	if errors.As(err, &alreadyExists) {
+		fmt.Println("ECR repository already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating ECR repository: %v", err)
+	}
+	return nil
+}
+
+// allowRegistryPull lets the function's account pull the repository's
+// images, for a repository in another account: its principals, and Lambda
+// for the account's functions. Statements for other accounts are kept.
+func allowRegistryPull(ctx context.Context, awsAccountID string) error {
+	policy := map[string]interface{}{"Version": "2012-10-17"}
+	current, err := api.ecr.GetRepositoryPolicy(ctx, &ecr.GetRepositoryPolicyInput{RepositoryName: aws.String(config.ECR.RepositoryName)})
+	var notFound *ecrtypes.RepositoryPolicyNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+	case err != nil:
+		return fmt.Errorf("error reading the repository policy: %v", err)
+	default:
+		if err := json.Unmarshal([]byte(aws.ToString(current.PolicyText)), &policy); err != nil {
+			return fmt.Errorf("error parsing the repository policy: %v", err)
+		}
+	}
+
+	var statements []interface{}
+	switch s := policy["Statement"].(type) {
+	case []interface{}:
+		statements = s
+	case map[string]interface{}:
+		statements = []interface{}{s}
+	}
+	pull := []string{"ecr:BatchGetImage", "ecr:GetDownloadUrlForLayer"}
+	ours := []interface{}{
+		map[string]interface{}{
+			"Sid":       "AccountPull" + awsAccountID,
+			"Effect":    "Allow",
+			"Principal": map[string]interface{}{"AWS": config.Partition().ARN("iam", "", awsAccountID, "root")},
+			"Action":    pull,
+		},
+		map[string]interface{}{
+			"Sid":       "LambdaPull" + awsAccountID,
+			"Effect":    "Allow",
+			"Principal": map[string]interface{}{"Service": "lambda.amazonaws.com"},
+			"Action":    pull,
+			"Condition": map[string]interface{}{"StringLike": map[string]interface{}{
+				"aws:sourceArn": config.Partition().ARN("lambda", config.AWS.Region, awsAccountID, "function:*"),
+			}},
+		},
+	}
+	kept := ours
+	for _, statement := range statements {
+		sid, _ := statement.(map[string]interface{})["Sid"].(string)
+		if sid != "AccountPull"+awsAccountID && sid != "LambdaPull"+awsAccountID {
+			kept = append(kept, statement)
+		}
+	}
+	policy["Statement"] = kept
+	text, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	_, err = api.ecr.SetRepositoryPolicy(ctx, &ecr.SetRepositoryPolicyInput{
+		RepositoryName: aws.String(config.ECR.RepositoryName),
+		PolicyText:     aws.String(string(text)),
+	})
+	if err != nil {
+		return fmt.Errorf("error setting the repository policy: %v", err)
+	}
+	fmt.Printf("Account %s may pull from ECR repository %s\n", awsAccountID, config.ECR.RepositoryName)
+	return nil
+}
+
+// pushURI is repositoryURI on the registry host docker logs in and pushes
+// to, which aws.fips and aws.dual_stack move to the matching endpoint.
+func pushURI() string {
+	return config.Partition().PushURI(repositoryURI, config.Endpoints())
+}
+
+func createLambdaFunction(ctx context.Context, roleARN string) error {
+	imageUri := repositoryURI + ":latest"
+
+	input := &lambda.CreateFunctionInput{
+		FunctionName:  aws.String(config.Lambda.FunctionName),
+		PackageType:   lambdatypes.PackageTypeImage,
+		Architectures: []lambdatypes.Architecture{lambdatypes.Architecture(config.Build.Go.LambdaArchitecture())},
+		Code:          &lambdatypes.FunctionCode{ImageUri: aws.String(imageUri)},
+		Role:          aws.String(roleARN),
+		Tags:          createdTags,
+	}
+	if config.Lambda.Timeout > 0 {
+		input.Timeout = aws.Int32(int32(config.Lambda.Timeout))
+	}
+	if config.Lambda.MemorySize > 0 {
+		input.MemorySize = aws.Int32(int32(config.Lambda.MemorySize))
+	}
+	if config.Lambda.EphemeralStorage > 0 {
+		input.EphemeralStorage = &lambdatypes.EphemeralStorage{Size: aws.Int32(int32(config.Lambda.EphemeralStorage))}
+	}
+	if env := lambdaenv.Function(&config, lambdaenv.Resources{DatabaseHost: databaseProxy.Endpoint, WorkerQueueURL: workerQueueURL}); len(env) > 0 {
+		input.Environment = &lambdatypes.Environment{Variables: env}
+	}
+	if len(config.VPC.SubnetIDs) > 0 {
+		input.VpcConfig = &lambdatypes.VpcConfig{
+			SubnetIds:        config.VPC.SubnetIDs,
+			SecurityGroupIds: config.VPC.SecurityGroupIDs,
+		}
+	}
+	if config.Lambda.Tracing != "" {
+		input.TracingConfig = &lambdatypes.TracingConfig{Mode: lambdatypes.TracingMode(config.Lambda.Tracing)}
+	}
+	if config.Lambda.DeadLetterARN != "" {
+		input.DeadLetterConfig = &lambdatypes.DeadLetterConfig{TargetArn: aws.String(config.Lambda.DeadLetterARN)}
+	}
+
+	_, err := api.lambda.CreateFunction(ctx, input)
+	if isConflict(err) {
+		fmt.Println("Lambda function already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating Lambda function: %v", err)
+	}
+
+	fmt.Println("Lambda function created successfully")
+	return nil
+}
+
+// setReservedConcurrency applies lambda.reserved_concurrency to the
+// function as deploy does, leaving a throttled one paused; deploy keeps it
+// in line afterwards.
+func setReservedConcurrency(ctx context.Context) error {
+	return concurrency.Apply(ctx, api.lambda, &config, config.Lambda.FunctionName, os.Stdout)
+}
+
+// isConflict reports whether a Lambda call failed because what it creates
+// already exists.
+func isConflict(err error) bool {
+	var conflict *lambdatypes.ResourceConflictException
+	return errors.As(err, &conflict)
+}
+
+func buildAndPushDockerImage(ctx context.Context, w io.Writer) error {
+	// Log in to ECR, or let its credential helper answer
+	registry, _, _ := strings.Cut(pushURI(), "/")
+	auth := registryauth.Auth{
+		Password:    func() (string, error) { return api.tokens.Password(ctx) },
+		Credentials: api.registry.Credentials,
+		Region:      api.registry.Region,
+	}
+	dockerEnv, err := registryauth.Login(ctx, registry, auth, w)
+	if err != nil {
+		return fmt.Errorf("failed to login to ECR: %v%s", err, config.DockerLoginHint(registry))
+	}
+
+	// Build Docker image
+	opts, cleanup, err := config.BuildOptions(w)
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+	buildCmd := hostexec.DockerBuild(config.ECR.RepositoryName, opts)
+	buildCmd.Stdout = w
+	buildCmd.Stderr = w
+	if err := hostexec.Run(buildCmd); err != nil {
+		return fmt.Errorf("failed to build Docker image: %v", err)
+	}
+
+	// Tag Docker image
+	imageUri := pushURI() + ":latest"
+	tagCmd := exec.Command("docker", "tag", config.ECR.RepositoryName, imageUri)
+	tagCmd.Stdout = w
+	tagCmd.Stderr = w
+	if err := hostexec.Run(tagCmd); err != nil {
+		return fmt.Errorf("failed to tag Docker image: %v", err)
+	}
+
+	// Push Docker image to ECR
+	pushCmd := registryauth.Command(exec.Command("docker", "push", imageUri), dockerEnv)
+	pushCmd.Stdout = w
+	pushCmd.Stderr = w
+	if err := hostexec.Run(pushCmd); err != nil {
+		return fmt.Errorf("failed to push Docker image to ECR: %v", err)
+	}
+
+	fmt.Fprintln(w, "Docker image built and pushed successfully")
+	return nil
+}
+
+func putExportPolicy(ctx context.Context, awsAccountID string) error {
+	type statement struct {
+		Effect   string   `json:"Effect"`
+		Action   []string `json:"Action"`
+		Resource []string `json:"Resource"`
+	}
+	statements := []statement{{
+		Effect:   "Allow",
+		Action:   []string{"s3:PutObject"},
+		Resource: []string{config.Partition().ARN("s3", "", "", fmt.Sprintf("%s/%s*", config.Export.Bucket, config.Export.Prefix))},
+	}}
+	if config.Export.Source.Type == "dynamodb" {
+		statements = append(statements, statement{
+			Effect:   "Allow",
+			Action:   []string{"dynamodb:Scan"},
+			Resource: []string{config.Partition().ARN("dynamodb", config.AWS.Region, awsAccountID, "table/"+config.Export.Source.Table)},
+		})
+	}
+	if config.Export.Glue.Database != "" {
+		statements = append(statements, statement{
+			Effect: "Allow",
+			Action: []string{"glue:GetTable", "glue:CreatePartition"},
+			Resource: []string{
+				config.Partition().ARN("glue", config.AWS.Region, awsAccountID, "catalog"),
+				config.Partition().ARN("glue", config.AWS.Region, awsAccountID, "database/"+config.Export.Glue.Database),
+				config.Partition().ARN("glue", config.AWS.Region, awsAccountID, fmt.Sprintf("table/%s/%s", config.Export.Glue.Database, config.Export.Glue.Table)),
+			},
+		})
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding export policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("export-access"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting export policy: %v", err)
+	}
+
+	fmt.Println("Export policy attached to Lambda execution role")
+	return nil
+}
+
+func createExportSchedule(ctx context.Context, awsAccountID string) error {
+	if err := putScheduleRule(ctx, awsAccountID, config.Lambda.FunctionName+"-export", config.Export.Schedule); err != nil {
+		return err
+	}
+	fmt.Printf("Export scheduled with %s\n", config.Export.Schedule)
+	return nil
+}
+
+// createSchedule invokes the function on lambda.schedule.
+func createSchedule(ctx context.Context, awsAccountID string) error {
+	if err := putScheduleRule(ctx, awsAccountID, config.ScheduleRuleName(), config.Lambda.Schedule); err != nil {
+		return err
+	}
+	fmt.Printf("Function scheduled with %s\n", config.Lambda.Schedule)
+	return nil
+}
+
+// putScheduleRule creates or updates the rule, allows it to invoke the
+// function and points it at the function.
+func putScheduleRule(ctx context.Context, awsAccountID, ruleName, expression string) error {
+	functionARN := config.TriggerTargetARN(awsAccountID)
+
+	putRuleCmd := exec.Command("aws", "events", "put-rule",
+		"--name", ruleName,
+		"--schedule-expression", expression,
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	putRuleCmd.Args = append(putRuleCmd.Args, "--tags")
+	for _, key := range tagKeys() {
+		putRuleCmd.Args = append(putRuleCmd.Args, fmt.Sprintf("Key=%s,Value=%s", key, createdTags[key]))
+	}
+
+	output, err := hostexec.CombinedOutput(putRuleCmd)
+	if err != nil {
+		return fmt.Errorf("error creating schedule rule: %v\n%s", err, output)
+	}
+
+	var ruleResponse struct {
+		RuleArn string `json:"RuleArn"`
+	}
+	if err := json.Unmarshal(output, &ruleResponse); err != nil {
+		return fmt.Errorf("error parsing schedule rule response: %v", err)
+	}
+
+	_, err = api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(config.TriggerTarget()),
+		StatementId:  aws.String(ruleName),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    aws.String(ruleResponse.RuleArn),
+	})
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error adding invoke permission: %v", err)
+	}
+
+	putTargetsCmd := exec.Command("aws", "events", "put-targets",
+		"--rule", ruleName,
+		"--targets", fmt.Sprintf("Id=%s,Arn=%s", config.Lambda.FunctionName, functionARN),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err = hostexec.CombinedOutput(putTargetsCmd)
+	if err != nil {
+		return fmt.Errorf("error adding schedule target: %v\n%s", err, output)
+	}
+	return nil
+}
+
+func setupEventBus(ctx context.Context, awsAccountID string) error {
+	if config.Events.BusName != "default" {
+		createBusCmd := exec.Command("aws", "events", "create-event-bus",
+			"--name", config.Events.BusName,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+
+		output, err := hostexec.CombinedOutput(createBusCmd)
+		if err != nil {
+			if !strings.Contains(string(output), "ResourceAlreadyExistsException") {
+				return fmt.Errorf("error creating event bus: %v\n%s", err, output)
+			}
+			fmt.Println("Event bus already exists")
+		} else {
+			fmt.Printf("Event bus '%s' created successfully\n", config.Events.BusName)
+		}
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"events:PutEvents"},
+			"Resource": []string{config.Partition().ARN("events", config.AWS.Region, awsAccountID, "event-bus/"+config.Events.BusName)},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding events policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("events-publish"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting events policy: %v", err)
+	}
+
+	fmt.Println("Events policy attached to Lambda execution role")
+	return nil
+}
+
+func putDynConfigPolicy(ctx context.Context, awsAccountID string) error {
+	parameterARN := config.Partition().ARN("ssm", config.AWS.Region, awsAccountID, "parameter/"+strings.TrimPrefix(config.DynConfig.Parameter, "/"))
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"ssm:GetParameter"},
+			"Resource": []string{parameterARN},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding dynamic configuration policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("dynconfig-read"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting dynamic configuration policy: %v", err)
+	}
+
+	fmt.Println("Dynamic configuration policy attached to Lambda execution role")
+	return nil
+}
+
+func putSecretsPolicy(ctx context.Context, awsAccountID string) error {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"secretsmanager:GetSecretValue"},
+			"Resource": config.SecretARNs(awsAccountID),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding secrets policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("secrets-read"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting secrets policy: %v", err)
+	}
+
+	fmt.Println("Secrets policy attached to Lambda execution role")
+	return nil
+}
+
+// putDeadLetterPolicy lets the execution role send to lambda.dead_letter_arn,
+// which Lambda checks when the function is created or updated.
+func putDeadLetterPolicy(ctx context.Context) error {
+	action := "sqs:SendMessage"
+	if parts := strings.Split(config.Lambda.DeadLetterARN, ":"); len(parts) > 2 && parts[2] == "sns" {
+		action = "sns:Publish"
+	}
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{action},
+			"Resource": []string{config.Lambda.DeadLetterARN},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding dead-letter policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("dead-letter"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting dead-letter policy: %v", err)
+	}
+
+	fmt.Println("Dead-letter policy attached to Lambda execution role")
+	return nil
+}
+
+// shadowFunctionARN returns the ARN of shadow.function without its
+// qualifier, which may be configured as a name, ARN or name:alias.
+func shadowFunctionARN(awsAccountID string) string {
+	function := config.Shadow.Function
+	if !strings.HasPrefix(function, "arn:") {
+		name, _, _ := strings.Cut(function, ":")
+		return config.Partition().ARN("lambda", config.AWS.Region, awsAccountID, "function:"+name)
+	}
+	if parts := strings.Split(function, ":"); len(parts) > 7 {
+		return strings.Join(parts[:7], ":")
+	}
+	return function
+}
+
+func putShadowPolicy(ctx context.Context, awsAccountID string) error {
+	functionARN := shadowFunctionARN(awsAccountID)
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"lambda:InvokeFunction"},
+			"Resource": []string{functionARN, functionARN + ":*"},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding shadow policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("shadow-invoke"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting shadow policy: %v", err)
+	}
+
+	fmt.Println("Shadow policy attached to Lambda execution role")
+	return nil
+}
+
+// describeDatabaseProxy looks up the endpoint and resource ID (prx-...) of the
+// configured RDS Proxy; the resource ID is what IAM policies refer to.
+func describeDatabaseProxy() error {
+	describeCmd := exec.Command("aws", "rds", "describe-db-proxies",
+		"--db-proxy-name", config.Database.ProxyName,
+		"--query", "DBProxies[0].[Endpoint,DBProxyArn]",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err := hostexec.CombinedOutput(describeCmd)
+	if err != nil {
+		return fmt.Errorf("error describing RDS Proxy: %v\n%s", err, output)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return fmt.Errorf("unexpected describe-db-proxies output: %s", output)
+	}
+	databaseProxy.Endpoint = fields[0]
+	databaseProxy.ResourceID = fields[1][strings.LastIndex(fields[1], ":")+1:]
+	return nil
+}
+
+func putDatabasePolicy(ctx context.Context, awsAccountID string) error {
+	userARN := config.Partition().ARN("rds-db", config.AWS.Region, awsAccountID, fmt.Sprintf("dbuser:%s/%s", databaseProxy.ResourceID, config.Database.User))
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"rds-db:connect"},
+			"Resource": []string{userARN},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding database policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("rds-connect"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting database policy: %v", err)
+	}
+
+	fmt.Printf("Granted rds-db:connect as %s through proxy %s\n", config.Database.User, config.Database.ProxyName)
+	return nil
+}
+
+// setupVPCAccess lets the execution role manage the function's network
+// interfaces and opens the cache cluster's security group to the function.
+func setupVPCAccess(ctx context.Context) error {
+	if err := rolepolicy.Attach(ctx, api.iam, &config, rolepolicy.VPCAccess); err != nil {
+		return err
+	}
+
+	if config.Cache.SecurityGroupID == "" {
+		return nil
+	}
+	port := "6379"
+	if _, p, err := net.SplitHostPort(config.Cache.Address); err == nil {
+		port = p
+	}
+	for _, groupID := range config.VPC.SecurityGroupIDs {
+		authorizeCmd := exec.Command("aws", "ec2", "authorize-security-group-ingress",
+			"--group-id", config.Cache.SecurityGroupID,
+			"--protocol", "tcp",
+			"--port", port,
+			"--source-group", groupID,
+			"--profile", config.AWS.Profile,
+			"--region", config.AWS.Region)
+
+		output, err := hostexec.CombinedOutput(authorizeCmd)
+		if err != nil && !strings.Contains(string(output), "InvalidPermission.Duplicate") {
+			return fmt.Errorf("error authorizing cache access from %s: %v\n%s", groupID, err, output)
+		}
+	}
+
+	fmt.Printf("Allowed %s to reach the cache on port %s\n", strings.Join(config.VPC.SecurityGroupIDs, ", "), port)
+	return nil
+}
+
+func setupWorkerQueue(ctx context.Context, awsAccountID string) error {
+	queue, dlq := config.WorkerQueueName()
+
+	dlqAttributes := map[string]string{"MessageRetentionPeriod": "1209600"}
+	if config.Worker.FIFO {
+		dlqAttributes["FifoQueue"] = "true"
+	}
+	if _, err := createQueue(dlq, dlqAttributes); err != nil {
+		return err
+	}
+
+	maxReceiveCount := config.Worker.MaxReceiveCount
+	if maxReceiveCount == 0 {
+		maxReceiveCount = 5
+	}
+	redrivePolicy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": config.Partition().ARN("sqs", config.AWS.Region, awsAccountID, dlq),
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding redrive policy: %v", err)
+	}
+	// AWS recommends a visibility timeout of six times the function timeout
+	attributes := map[string]string{
+		"VisibilityTimeout": strconv.Itoa(max(config.Lambda.Timeout, 3) * 6),
+		"RedrivePolicy":     string(redrivePolicy),
+	}
+	if config.Worker.FIFO {
+		attributes["FifoQueue"] = "true"
+	}
+	workerQueueURL, err = createQueue(queue, attributes)
+	if err != nil {
+		return err
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:ChangeMessageVisibility", "sqs:SendMessage"},
+			"Resource": []string{config.Partition().ARN("sqs", config.AWS.Region, awsAccountID, queue)},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding worker queue policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("worker-queue"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting worker queue policy: %v", err)
+	}
+
+	fmt.Printf("Worker queue %s ready (dead-letter queue %s)\n", queue, dlq)
+	return nil
+}
+
+// createQueue creates the queue, or updates the attributes of an existing
+// one, and returns its URL.
+func createQueue(name string, attributes map[string]string) (string, error) {
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return "", fmt.Errorf("error encoding queue attributes: %v", err)
+	}
+
+	createQueueCmd := exec.Command("aws", "sqs", "create-queue",
+		"--queue-name", name,
+		"--attributes", string(encoded),
+		"--query", "QueueUrl",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err := hostexec.CombinedOutput(createQueueCmd)
+	if err == nil {
+		return strings.TrimSpace(string(output)), nil
+	}
+	if !strings.Contains(string(output), "QueueAlreadyExists") {
+		return "", fmt.Errorf("error creating queue %s: %v\n%s", name, err, output)
+	}
+
+	getURLCmd := exec.Command("aws", "sqs", "get-queue-url",
+		"--queue-name", name,
+		"--query", "QueueUrl",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err = hostexec.CombinedOutput(getURLCmd)
+	if err != nil {
+		return "", fmt.Errorf("error getting URL of queue %s: %v\n%s", name, err, output)
+	}
+	queueURL := strings.TrimSpace(string(output))
+
+	// FifoQueue can't be changed after creation
+	delete(attributes, "FifoQueue")
+	encoded, err = json.Marshal(attributes)
+	if err != nil {
+		return "", fmt.Errorf("error encoding queue attributes: %v", err)
+	}
+	setAttributesCmd := exec.Command("aws", "sqs", "set-queue-attributes",
+		"--queue-url", queueURL,
+		"--attributes", string(encoded),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+
+	output, err = hostexec.CombinedOutput(setAttributesCmd)
+	if err != nil {
+		return "", fmt.Errorf("error updating queue %s: %v\n%s", name, err, output)
+	}
+	return queueURL, nil
+}
+
+func createWorkerMapping(ctx context.Context, awsAccountID string) error {
+	queue, _ := config.WorkerQueueName()
+	batchSize := config.Worker.BatchSize
+	if batchSize == 0 {
+		batchSize = 10
+	}
+
+	_, err := api.lambda.CreateEventSourceMapping(ctx, &lambda.CreateEventSourceMappingInput{
+		FunctionName:          aws.String(config.TriggerTarget()),
+		EventSourceArn:        aws.String(config.Partition().ARN("sqs", config.AWS.Region, awsAccountID, queue)),
+		BatchSize:             aws.Int32(int32(batchSize)),
+		FunctionResponseTypes: []lambdatypes.FunctionResponseType{lambdatypes.FunctionResponseTypeReportBatchItemFailures},
+	})
+	if isConflict(err) {
+		fmt.Println("Worker queue mapping already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating event source mapping: %v", err)
+	}
+
+	fmt.Println("Worker queue mapping created successfully")
+	return nil
+}
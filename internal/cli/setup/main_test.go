@@ -0,0 +1,991 @@
+package setup
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"go.uber.org/mock/gomock"
+
+	appconfig "example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/pipeline"
+	"example-lambda-go/internal/registryauth"
+)
+
+// useFake swaps in a fake runner and a minimal config for one test.
+func useFake(t *testing.T) *hostexec.Fake {
+	fake := hostexec.NewFake()
+	previous, previousConfig := hostexec.Default, config
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default, config = previous, previousConfig })
+	// Keep the host's Docker credential store out of the login commands
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	config = appconfig.Config{}
+	config.AWS.Region = "us-east-1"
+	config.AWS.Profile = "default"
+	config.Lambda.FunctionName = "hello"
+	config.Lambda.RoleName = "hello-role"
+	config.ECR.RepositoryName = "hello-repo"
+	return fake
+}
+
+func cliError(code string) hostexec.Response {
+	return hostexec.Response{
+		Output: []byte("\nAn error occurred (" + code + ") when calling the operation: exists\n"),
+		Err:    errors.New("exit status 254"),
+	}
+}
+
+func newMockClients(ctrl *gomock.Controller) (clients, *MockiamAPI, *MockecrAPI, *MocklambdaAPI) {
+	i, e, l := NewMockiamAPI(ctrl), NewMockecrAPI(ctrl), NewMocklambdaAPI(ctrl)
+	return clients{iam: i, ecr: e, sts: NewMockstsAPI(ctrl), lambda: l, tokens: &registryauth.Tokens{Client: e}}, i, e, l
+}
+
+// useClients swaps in mock SDK clients for one test.
+func useClients(t *testing.T) (*MockiamAPI, *MockecrAPI, *MocklambdaAPI) {
+	c, i, e, l := newMockClients(gomock.NewController(t))
+	previous := api
+	api = c
+	t.Cleanup(func() { api = previous })
+	return i, e, l
+}
+
+func TestExistingRoleIsReused(t *testing.T) {
+	useFake(t)
+	i, _, _ := useClients(t)
+	i.EXPECT().GetRole(gomock.Any(), &iam.GetRoleInput{RoleName: aws.String("hello-role")}).
+		Return(&iam.GetRoleOutput{Role: &iamtypes.Role{Arn: aws.String("arn:aws:iam::123:role/hello-role")}}, nil)
+
+	arn, err := getOrCreateLambdaExecutionRole(context.Background())
+	if err != nil || arn != "arn:aws:iam::123:role/hello-role" {
+		t.Fatalf("getOrCreateLambdaExecutionRole = %q, %v", arn, err)
+	}
+}
+
+func TestMissingRoleIsCreated(t *testing.T) {
+	useFake(t)
+	i, _, _ := useClients(t)
+	gomock.InOrder(
+		i.EXPECT().GetRole(gomock.Any(), gomock.Any()).Return(nil, &iamtypes.NoSuchEntityException{}),
+		i.EXPECT().CreateRole(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *iam.CreateRoleInput, _ ...func(*iam.Options)) (*iam.CreateRoleOutput, error) {
+			if *input.RoleName != "hello-role" || !strings.Contains(*input.AssumeRolePolicyDocument, "lambda.amazonaws.com") {
+				t.Errorf("CreateRole(%v), want hello-role trusted by Lambda", input)
+			}
+			return &iam.CreateRoleOutput{Role: &iamtypes.Role{Arn: aws.String("arn:aws:iam::123:role/hello-role")}}, nil
+		}),
+		i.EXPECT().AttachRolePolicy(gomock.Any(), &iam.AttachRolePolicyInput{
+			RoleName:  aws.String("hello-role"),
+			PolicyArn: aws.String("arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole"),
+		}).Return(&iam.AttachRolePolicyOutput{}, nil),
+	)
+
+	arn, err := getOrCreateLambdaExecutionRole(context.Background())
+	if err != nil || arn != "arn:aws:iam::123:role/hello-role" {
+		t.Fatalf("getOrCreateLambdaExecutionRole = %q, %v", arn, err)
+	}
+}
+
+func TestRoleLookupErrorsAreReported(t *testing.T) {
+	useFake(t)
+	i, _, _ := useClients(t)
+	i.EXPECT().GetRole(gomock.Any(), gomock.Any()).Return(nil, errors.New("AccessDenied"))
+
+	if _, err := getOrCreateLambdaExecutionRole(context.Background()); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("getOrCreateLambdaExecutionRole = %v, want the lookup error rather than a create attempt", err)
+	}
+}
+
+func TestCreateConflictsMeanAlreadyExists(t *testing.T) {
+	useFake(t)
+	_, e, l := useClients(t)
+	e.EXPECT().CreateRepository(gomock.Any(), gomock.Any()).Return(nil, &ecrtypes.RepositoryAlreadyExistsException{})
+	l.EXPECT().CreateFunction(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+	l.EXPECT().CreateEventSourceMapping(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+	config.Worker.QueueName = "jobs"
+
+	ctx := context.Background()
+	if err := createECRRepository(ctx); err != nil {
+		t.Errorf("createECRRepository = %v", err)
+	}
+	if err := createLambdaFunction(ctx, "arn:aws:iam::123:role/hello-role"); err != nil {
+		t.Errorf("createLambdaFunction = %v", err)
+	}
+	if err := createWorkerMapping(ctx, "123"); err != nil {
+		t.Errorf("createWorkerMapping = %v", err)
+	}
+}
+
+func TestCreateErrorsAreReported(t *testing.T) {
+	useFake(t)
+	_, e, l := useClients(t)
+	e.EXPECT().CreateRepository(gomock.Any(), gomock.Any()).Return(nil, errors.New("AccessDeniedException"))
+	l.EXPECT().CreateFunction(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.InvalidParameterValueException{Message: aws.String("bad role")})
+
+	ctx := context.Background()
+	if err := createECRRepository(ctx); err == nil || !strings.Contains(err.Error(), "AccessDeniedException") {
+		t.Errorf("createECRRepository = %v, want the SDK error", err)
+	}
+	if err := createLambdaFunction(ctx, "role"); err == nil || !strings.Contains(err.Error(), "InvalidParameterValueException") {
+		t.Errorf("createLambdaFunction = %v, want the SDK error", err)
+	}
+}
+
+func TestCreateFunctionInput(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.VPC.SubnetIDs = []string{"subnet-1", "subnet-2"}
+	config.VPC.SecurityGroupIDs = []string{"sg-1"}
+	config.DynConfig.Parameter = "/hello/config"
+	config.Lambda.Timeout, config.Lambda.MemorySize, config.Lambda.EphemeralStorage = 30, 512, 2048
+	previous := repositoryURI
+	repositoryURI = "123.dkr.ecr.us-east-1.amazonaws.com/hello-repo"
+	t.Cleanup(func() { repositoryURI = previous })
+
+	l.EXPECT().CreateFunction(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateFunctionInput, _ ...func(*lambda.Options)) (*lambda.CreateFunctionOutput, error) {
+		if *input.Code.ImageUri != repositoryURI+":latest" || *input.Role != "role" || input.PackageType != lambdatypes.PackageTypeImage {
+			t.Errorf("CreateFunction code %v, role %v, package type %v", *input.Code.ImageUri, *input.Role, input.PackageType)
+		}
+		if !reflect.DeepEqual(input.Architectures, []lambdatypes.Architecture{lambdatypes.ArchitectureX8664}) {
+			t.Errorf("Architectures = %v, want x86_64", input.Architectures)
+		}
+		if input.Environment == nil || input.Environment.Variables["DYNCONFIG_PARAMETER"] != "/hello/config" {
+			t.Errorf("Environment = %v, want DYNCONFIG_PARAMETER", input.Environment)
+		}
+		if input.VpcConfig == nil || !reflect.DeepEqual(input.VpcConfig.SubnetIds, config.VPC.SubnetIDs) || !reflect.DeepEqual(input.VpcConfig.SecurityGroupIds, config.VPC.SecurityGroupIDs) {
+			t.Errorf("VpcConfig = %v, want the vpc section", input.VpcConfig)
+		}
+		if aws.ToInt32(input.Timeout) != 30 || aws.ToInt32(input.MemorySize) != 512 || input.EphemeralStorage == nil || aws.ToInt32(input.EphemeralStorage.Size) != 2048 {
+			t.Errorf("Timeout, MemorySize, EphemeralStorage = %v, %v, %v; want 30, 512, 2048", input.Timeout, input.MemorySize, input.EphemeralStorage)
+		}
+		return &lambda.CreateFunctionOutput{}, nil
+	})
+
+	if err := createLambdaFunction(context.Background(), "role"); err != nil {
+		t.Fatalf("createLambdaFunction = %v", err)
+	}
+}
+
+func TestECRPassword(t *testing.T) {
+	_, e, _ := useClients(t)
+	e.EXPECT().GetAuthorizationToken(gomock.Any(), gomock.Any()).Return(&ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []ecrtypes.AuthorizationData{{AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:secret:with:colons")))}},
+	}, nil)
+
+	password, err := api.tokens.Password(context.Background())
+	if err != nil || password != "secret:with:colons" {
+		t.Errorf("ECR password = %q, %v", password, err)
+	}
+}
+
+func TestAllowRegistryPull(t *testing.T) {
+	useFake(t)
+	_, e, _ := useClients(t)
+	existing := `{"Version":"2012-10-17","Statement":[
+		{"Sid":"AccountPull999","Effect":"Allow","Principal":{"AWS":"arn:aws:iam::999:root"},"Action":["ecr:BatchGetImage"]},
+		{"Sid":"LambdaPull123","Effect":"Deny","Principal":"*","Action":"ecr:*"}]}`
+	e.EXPECT().GetRepositoryPolicy(gomock.Any(), gomock.Any()).Return(&ecr.GetRepositoryPolicyOutput{PolicyText: aws.String(existing)}, nil)
+	var policy struct {
+		Statement []struct {
+			Sid       string
+			Effect    string
+			Condition map[string]map[string]string
+		}
+	}
+	e.EXPECT().SetRepositoryPolicy(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *ecr.SetRepositoryPolicyInput, _ ...func(*ecr.Options)) (*ecr.SetRepositoryPolicyOutput, error) {
+		if err := json.Unmarshal([]byte(aws.ToString(input.PolicyText)), &policy); err != nil {
+			t.Fatal(err)
+		}
+		return &ecr.SetRepositoryPolicyOutput{}, nil
+	})
+
+	if err := allowRegistryPull(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	var sids []string
+	for _, statement := range policy.Statement {
+		sids = append(sids, statement.Sid)
+	}
+	if !reflect.DeepEqual(sids, []string{"AccountPull123", "LambdaPull123", "AccountPull999"}) {
+		t.Fatalf("statements %v, want this account's replaced and the other account's kept", sids)
+	}
+	if policy.Statement[1].Effect != "Allow" || policy.Statement[1].Condition["StringLike"]["aws:sourceArn"] != "arn:aws:lambda:us-east-1:123:function:*" {
+		t.Errorf("Lambda statement = %+v, want an Allow for the account's functions", policy.Statement[1])
+	}
+}
+
+func TestPutSecretsPolicy(t *testing.T) {
+	useFake(t)
+	i, _, _ := useClients(t)
+	const arn = "arn:aws:secretsmanager:us-east-1:999:secret:shared/key-AbCdEf"
+	config.Lambda.Environment = map[string]string{
+		"DB_USER":     "secretsmanager:hello/db:username",
+		"DB_PASSWORD": "secretsmanager:hello/db:password",
+		"SHARED_KEY":  "secretsmanager:" + arn,
+		"LOG_LEVEL":   "info",
+	}
+	var policy struct {
+		Statement []struct {
+			Action   []string
+			Resource []string
+		}
+	}
+	i.EXPECT().PutRolePolicy(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *iam.PutRolePolicyInput, _ ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+		if aws.ToString(input.PolicyName) != "secrets-read" {
+			t.Errorf("policy name = %s", aws.ToString(input.PolicyName))
+		}
+		if err := json.Unmarshal([]byte(aws.ToString(input.PolicyDocument)), &policy); err != nil {
+			t.Fatal(err)
+		}
+		return &iam.PutRolePolicyOutput{}, nil
+	})
+
+	if err := putSecretsPolicy(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{arn, "arn:aws:secretsmanager:us-east-1:123:secret:hello/db-??????"}
+	if len(policy.Statement) != 1 || !reflect.DeepEqual(policy.Statement[0].Resource, want) {
+		t.Errorf("policy = %+v, want GetSecretValue on %v", policy, want)
+	}
+}
+
+func TestExistingQueueIsUpdated(t *testing.T) {
+	fake := useFake(t)
+	fake.On([]string{"aws", "sqs", "create-queue"}, cliError("QueueAlreadyExists"))
+	fake.On([]string{"aws", "sqs", "get-queue-url"}, hostexec.Response{Output: []byte("https://sqs.us-east-1.amazonaws.com/123/jobs.fifo\n")})
+
+	url, err := createQueue("jobs.fifo", map[string]string{"FifoQueue": "true", "VisibilityTimeout": "30"})
+	if err != nil || url != "https://sqs.us-east-1.amazonaws.com/123/jobs.fifo" {
+		t.Fatalf("createQueue = %q, %v", url, err)
+	}
+	set := fake.Calls[len(fake.Calls)-1].Args
+	if set[2] != "set-queue-attributes" || !reflect.DeepEqual(set[5:7], []string{"--attributes", `{"VisibilityTimeout":"30"}`}) {
+		t.Errorf("last command = %q, want set-queue-attributes without FifoQueue", set)
+	}
+}
+
+func TestWorkerQueueName(t *testing.T) {
+	useFake(t)
+	for _, test := range []struct {
+		name       string
+		fifo       bool
+		queue      string
+		deadLetter string
+	}{
+		{"jobs", false, "jobs", "jobs-dlq"},
+		{"jobs", true, "jobs.fifo", "jobs-dlq.fifo"},
+		{"jobs.fifo", true, "jobs.fifo", "jobs-dlq.fifo"},
+	} {
+		config.Worker.QueueName, config.Worker.FIFO = test.name, test.fifo
+		queue, dlq := config.WorkerQueueName()
+		if queue != test.queue || dlq != test.deadLetter {
+			t.Errorf("workerQueueName(%q, fifo=%v) = %q, %q; want %q, %q", test.name, test.fifo, queue, dlq, test.queue, test.deadLetter)
+		}
+	}
+}
+
+func TestDryRunPlanSkipsWhatExists(t *testing.T) {
+	useFake(t)
+	t.Setenv("LAMBDA_EXECUTION_ROLE_ARN", "")
+	i, e, l := useClients(t)
+	s := NewMockstsAPI(gomock.NewController(t))
+	api.sts = s
+	s.EXPECT().GetCallerIdentity(gomock.Any(), gomock.Any()).Return(&sts.GetCallerIdentityOutput{Account: aws.String("123")}, nil)
+	i.EXPECT().GetRole(gomock.Any(), gomock.Any()).Return(&iam.GetRoleOutput{}, nil)
+	e.EXPECT().DescribeRepositories(gomock.Any(), gomock.Any()).Return(&ecr.DescribeRepositoriesOutput{
+		Repositories: []ecrtypes.Repository{{RepositoryUri: aws.String("123.dkr.ecr.us-east-1.amazonaws.com/hello-repo")}},
+	}, nil)
+	l.EXPECT().GetFunctionConfiguration(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceNotFoundException{})
+	t.Cleanup(func() { repositoryURI = "" })
+
+	plan, err := dryRunPlan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []string
+	for _, step := range plan.Steps {
+		if step.Mutates() {
+			changes = append(changes, step.Operation+" "+step.When)
+		}
+	}
+	want := []string{"ecr:PutImage ", "lambda:CreateFunction "}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+	if values := plan.Steps[len(plan.Steps)-1].Values; values[0] != "image=123.dkr.ecr.us-east-1.amazonaws.com/hello-repo:latest" {
+		t.Errorf("CreateFunction values = %q", values)
+	}
+}
+
+func TestExplainPlanUsesRegionPartition(t *testing.T) {
+	for _, test := range []struct {
+		region, function, image string
+	}{
+		{"us-gov-west-1", "arn:aws-us-gov:lambda:us-gov-west-1:123:function:hello", "image=123.dkr.ecr.us-gov-west-1.amazonaws.com/hello-repo:latest"},
+		{"cn-north-1", "arn:aws-cn:lambda:cn-north-1:123:function:hello", "image=123.dkr.ecr.cn-north-1.amazonaws.com.cn/hello-repo:latest"},
+	} {
+		useFake(t)
+		t.Setenv("LAMBDA_EXECUTION_ROLE_ARN", "")
+		config.AWS.Region = test.region
+
+		var buf strings.Builder
+		explainPlan("123").Print(&buf)
+		for _, want := range []string{test.function, test.image} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s: plan is missing %s:\n%s", test.region, want, buf.String())
+			}
+		}
+		if strings.Contains(buf.String(), "arn:aws:") {
+			t.Errorf("%s: plan has commercial ARNs:\n%s", test.region, buf.String())
+		}
+	}
+}
+
+func TestSetupCDN(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.CDN.Enabled = true
+	config.CDN.RateLimit = 2000
+
+	fake.On([]string{"aws", "cloudfront", "list-origin-access-controls"}, hostexec.Response{Output: []byte("None\n")})
+	fake.On([]string{"aws", "cloudfront", "create-origin-access-control"}, hostexec.Response{Output: []byte("E2OAC\n")})
+	fake.On([]string{"aws", "wafv2", "list-web-acls"}, hostexec.Response{Output: []byte("None\n")})
+	fake.On([]string{"aws", "wafv2", "create-web-acl"}, hostexec.Response{Output: []byte("arn:aws:wafv2:us-east-1:123:global/webacl/hello-waf/1\n")})
+	fake.On([]string{"aws", "cloudfront", "list-distributions"}, hostexec.Response{Output: []byte("None\n")})
+	fake.On([]string{"aws", "cloudfront", "create-distribution"}, hostexec.Response{
+		Output: []byte("E1DIST\tarn:aws:cloudfront::123:distribution/E1DIST\td111.cloudfront.net\n"),
+	})
+
+	// A URL someone created public by hand is locked down
+	l.EXPECT().CreateFunctionUrlConfig(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+	l.EXPECT().UpdateFunctionUrlConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionUrlConfigInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionUrlConfigOutput, error) {
+		if input.AuthType != lambdatypes.FunctionUrlAuthTypeAwsIam {
+			t.Errorf("function URL auth = %s, want AWS_IAM", input.AuthType)
+		}
+		return &lambda.UpdateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-east-1.on.aws/")}, nil
+	})
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if *input.Principal != "cloudfront.amazonaws.com" || *input.SourceArn != "arn:aws:cloudfront::123:distribution/E1DIST" {
+			t.Errorf("AddPermission(%s, %s), want CloudFront limited to the distribution", *input.Principal, *input.SourceArn)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+
+	if err := setupCDN(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var createWebACL, createDistribution string
+	for _, command := range fake.Commands() {
+		switch {
+		case strings.HasPrefix(command, "aws wafv2 create-web-acl"):
+			createWebACL = command
+		case strings.HasPrefix(command, "aws cloudfront create-distribution"):
+			createDistribution = command
+		}
+	}
+	for _, want := range []string{"--scope CLOUDFRONT", "AWSManagedRulesCommonRuleSet", `"Limit":2000`, "--region us-east-1"} {
+		if !strings.Contains(createWebACL, want) {
+			t.Errorf("create-web-acl is missing %s: %s", want, createWebACL)
+		}
+	}
+	for _, want := range []string{`"DomainName":"abc.lambda-url.us-east-1.on.aws"`, `"OriginAccessControlId":"E2OAC"`, `"WebACLId":"arn:aws:wafv2:us-east-1:123:global/webacl/hello-waf/1"`, `"Comment":"hello"`} {
+		if !strings.Contains(createDistribution, want) {
+			t.Errorf("create-distribution is missing %s: %s", want, createDistribution)
+		}
+	}
+}
+
+func TestSetupCDNReusesExisting(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.CDN.Enabled = true
+	config.CDN.WebACLARN = "arn:aws:wafv2:us-east-1:123:global/webacl/shared/1"
+
+	fake.On([]string{"aws", "cloudfront", "list-origin-access-controls"}, hostexec.Response{Output: []byte("E2OAC\n")})
+	fake.On([]string{"aws", "cloudfront", "list-distributions"}, hostexec.Response{
+		Output: []byte("E1DIST\tarn:aws:cloudfront::123:distribution/E1DIST\td111.cloudfront.net\n"),
+	})
+	l.EXPECT().CreateFunctionUrlConfig(gomock.Any(), gomock.Any()).Return(&lambda.CreateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-east-1.on.aws/")}, nil)
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+
+	if err := setupCDN(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range fake.Commands() {
+		if strings.Contains(command, " create-") {
+			t.Errorf("ran %s with everything in place", command)
+		}
+	}
+}
+
+func TestSetupCDNAddsAssetsToExistingDistribution(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.CDN.Enabled = true
+	config.CDN.WebACLARN = "arn:aws:wafv2:us-east-1:123:global/webacl/shared/1"
+	config.CDN.Assets.Bucket = "hello-assets"
+
+	fake.On([]string{"aws", "s3api", "create-bucket"}, hostexec.Response{
+		Output: []byte("An error occurred (BucketAlreadyOwnedByYou) when calling the CreateBucket operation"),
+		Err:    errors.New("exit status 254"),
+	})
+	fake.On([]string{"aws", "cloudfront", "list-origin-access-controls"}, hostexec.Response{Output: []byte("E2OAC\n")})
+	fake.On([]string{"aws", "cloudfront", "list-distributions"}, hostexec.Response{
+		Output: []byte("E1DIST\tarn:aws:cloudfront::123:distribution/E1DIST\td111.cloudfront.net\n"),
+	})
+	fake.On([]string{"aws", "cloudfront", "get-distribution-config"}, hostexec.Response{Output: []byte(`{"ETag": "v1", "DistributionConfig": {
+		"Origins": {"Quantity": 1, "Items": [{"Id": "function"}]},
+		"CacheBehaviors": {"Quantity": 1, "Items": [{"PathPattern": "/api/*", "TargetOriginId": "function"}]}
+	}}`)})
+	l.EXPECT().CreateFunctionUrlConfig(gomock.Any(), gomock.Any()).Return(&lambda.CreateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-east-1.on.aws/")}, nil)
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).Return(&lambda.AddPermissionOutput{}, nil)
+
+	if err := setupCDN(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var update, bucketPolicy string
+	for _, command := range fake.Commands() {
+		switch {
+		case strings.HasPrefix(command, "aws cloudfront update-distribution"):
+			update = command
+		case strings.HasPrefix(command, "aws s3api put-bucket-policy"):
+			bucketPolicy = command
+		}
+	}
+	for _, want := range []string{"--if-match v1", `"DomainName":"hello-assets.s3.us-east-1.amazonaws.com"`, `"Quantity":2`} {
+		if !strings.Contains(update, want) {
+			t.Errorf("update-distribution is missing %s: %s", want, update)
+		}
+	}
+	if static, api := strings.Index(update, `"/static/*"`), strings.Index(update, `"/api/*"`); static < 0 || static > api {
+		t.Errorf("update-distribution does not put /static/* before the other behaviors: %s", update)
+	}
+	if !strings.Contains(bucketPolicy, `"AWS:SourceArn":"arn:aws:cloudfront::123:distribution/E1DIST"`) {
+		t.Errorf("bucket policy is not limited to the distribution: %s", bucketPolicy)
+	}
+}
+
+func TestSetupAssetsBucketTagsWhatItCreates(t *testing.T) {
+	fake := useFake(t)
+	config.CDN.Assets.Bucket = "hello-assets"
+	previous := createdTags
+	createdTags = map[string]string{appconfig.CreatedByTag: appconfig.CreatedBy, appconfig.ProjectTag: "shop"}
+	t.Cleanup(func() { createdTags = previous })
+	fake.On([]string{"aws", "cloudfront", "list-origin-access-controls"}, hostexec.Response{Output: []byte("E3OAC\n")})
+
+	if _, err := setupAssetsBucket(); err != nil {
+		t.Fatal(err)
+	}
+	var tagging string
+	for _, command := range fake.Commands() {
+		if strings.HasPrefix(command, "aws s3api put-bucket-tagging") {
+			tagging = command
+		}
+	}
+	if !strings.Contains(tagging, `{"Key":"lambda-template:project","Value":"shop"}`) {
+		t.Errorf("put-bucket-tagging = %q, want the project tag", tagging)
+	}
+}
+
+func TestAddAssetsIsIdempotent(t *testing.T) {
+	useFake(t)
+	config.CDN.Assets.Bucket = "hello-assets"
+	distConfig := map[string]interface{}{}
+
+	if !addAssets(distConfig, "E3OAC") {
+		t.Fatal("addAssets() = false for a config without the assets origin")
+	}
+	if addAssets(distConfig, "E3OAC") {
+		t.Error("addAssets() = true for a config that has the assets origin")
+	}
+	if origins := distConfig["Origins"].(map[string]interface{}); origins["Quantity"] != 1 {
+		t.Errorf("Origins = %v, want the assets origin once", origins)
+	}
+}
+
+func TestSetupDNS(t *testing.T) {
+	fake := useFake(t)
+	config.AWS.Region = "eu-west-1"
+	config.DNS = appconfig.DNS{ZoneID: "Z1", Name: "api.example.com", Target: "d-2.execute-api.eu-west-1.amazonaws.com", Failover: "SECONDARY"}
+
+	fake.On([]string{"aws", "route53", "list-health-checks"}, hostexec.Response{Output: []byte("None\n")})
+	fake.On([]string{"aws", "route53", "create-health-check"}, hostexec.Response{Output: []byte("hc-1\n")})
+
+	if err := setupDNS(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var create, change string
+	for _, command := range fake.Commands() {
+		switch {
+		case strings.HasPrefix(command, "aws route53 create-health-check"):
+			create = command
+		case strings.HasPrefix(command, "aws route53 change-resource-record-sets"):
+			change = command
+		}
+	}
+	for _, want := range []string{"--caller-reference hello-eu-west-1-", "FullyQualifiedDomainName=d-2.execute-api.eu-west-1.amazonaws.com,ResourcePath=/readyz"} {
+		if !strings.Contains(create, want) {
+			t.Errorf("create-health-check is missing %s: %s", want, create)
+		}
+	}
+	for _, want := range []string{`"Action":"UPSERT"`, `"Failover":"SECONDARY"`, `"HealthCheckId":"hc-1"`, `"SetIdentifier":"eu-west-1"`} {
+		if !strings.Contains(change, want) {
+			t.Errorf("change-resource-record-sets is missing %s: %s", want, change)
+		}
+	}
+}
+
+func TestSetupDNSUpdatesExistingHealthCheck(t *testing.T) {
+	fake := useFake(t)
+	config.DNS = appconfig.DNS{ZoneID: "Z1", Name: "api.example.com", Target: "d-1.example.com", Routing: "latency", HealthCheckPath: "/healthz"}
+
+	fake.On([]string{"aws", "route53", "list-health-checks"}, hostexec.Response{Output: []byte("hc-9\thc-1\n")})
+	fake.On([]string{"aws", "route53", "list-tags-for-resources"}, hostexec.Response{Output: []byte("hc-1\n")})
+
+	if err := setupDNS(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := fake.Commands()
+	if len(got) != 4 || !strings.Contains(got[1], "--resource-ids hc-9 hc-1") || !strings.Contains(got[1], "Value=='hello-us-east-1'") ||
+		!strings.HasPrefix(got[2], "aws route53 update-health-check --health-check-id hc-1 --fully-qualified-domain-name d-1.example.com --resource-path /healthz") {
+		t.Errorf("commands = %q, want the health check found by its Name tag and updated in place", got)
+	}
+	if len(got) == 4 && (!strings.Contains(got[3], `"Region":"us-east-1"`) || strings.Contains(got[3], "Failover")) {
+		t.Errorf("record = %s, want latency routing", got[3])
+	}
+}
+
+func TestResumeSkipsCompletedSteps(t *testing.T) {
+	useFake(t)
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	previousDone, previousURI := done, repositoryURI
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		done, repositoryURI = previousDone, previousURI
+	})
+	t.Setenv("LAMBDA_EXECUTION_ROLE_ARN", "")
+
+	// The first run creates the repository, then fails building the image
+	var err error
+	if done, err = loadProgress(false); err != nil {
+		t.Fatal(err)
+	}
+	run := pipeline.Start("setup", pipeline.Config{}, nil)
+	step(run, "ecr", func(ctx context.Context) error {
+		repositoryURI = "123.dkr.ecr.us-east-1.amazonaws.com/hello-repo"
+		return nil
+	})
+	if _, err := step(run, "build-push", func(ctx context.Context) error { return errors.New("no space left") }); err == nil {
+		t.Fatal("build-push error was not returned")
+	}
+
+	repositoryURI = ""
+	if done, err = loadProgress(true); err != nil {
+		t.Fatal(err)
+	}
+	if repositoryURI != "123.dkr.ecr.us-east-1.amazonaws.com/hello-repo" {
+		t.Errorf("repositoryURI = %q, want the one the ecr step found", repositoryURI)
+	}
+	var steps []string
+	for _, name := range []string{"ecr", "build-push"} {
+		ran, err := step(run, name, func(ctx context.Context) error { steps = append(steps, name); return nil })
+		if err != nil || ran != (name == "build-push") {
+			t.Errorf("step(%s) = %v, %v", name, ran, err)
+		}
+	}
+	if !reflect.DeepEqual(steps, []string{"build-push"}) {
+		t.Errorf("ran %v, want only build-push", steps)
+	}
+
+	// A changed configuration starts over
+	config.Lambda.MemorySize = 512
+	if done, err = loadProgress(true); err != nil {
+		t.Fatal(err)
+	}
+	if done.has("ecr") {
+		t.Error("progress of another configuration was resumed")
+	}
+}
+
+func TestSetupHTTPAPI(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	previousTags := createdTags
+	createdTags = map[string]string{appconfig.CreatedByTag: appconfig.CreatedBy}
+	t.Cleanup(func() { createdTags = previousTags })
+	config.API.Enabled = true
+
+	fake.On([]string{"aws", "apigatewayv2", "get-apis"}, hostexec.Response{Output: []byte("None\n")})
+	fake.On([]string{"aws", "apigatewayv2", "create-api"}, hostexec.Response{Output: []byte("a1b2c3\thttps://a1b2c3.execute-api.us-east-1.amazonaws.com\n")})
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if *input.Principal != "apigateway.amazonaws.com" || *input.SourceArn != "arn:aws:execute-api:us-east-1:123:a1b2c3/*" {
+			t.Errorf("AddPermission(%s, %s), want API Gateway limited to the API", *input.Principal, *input.SourceArn)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+
+	if err := setupHTTPAPI(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	var create string
+	for _, command := range fake.Commands() {
+		if strings.HasPrefix(command, "aws apigatewayv2 create-api") {
+			create = command
+		}
+	}
+	for _, want := range []string{"--name hello-api", "--protocol-type HTTP", "--target arn:aws:lambda:us-east-1:123:function:hello", `"created-by":"lambda-template"`} {
+		if !strings.Contains(create, want) {
+			t.Errorf("create-api is missing %s: %s", want, create)
+		}
+	}
+}
+
+func TestSetupHTTPAPIReusesExisting(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.API.Enabled = true
+	config.API.Name = "hello-public"
+	config.Project = "hello"
+
+	fake.On([]string{"aws", "apigatewayv2", "get-apis"}, hostexec.Response{Output: []byte("z9y8x7\thttps://z9y8x7.execute-api.us-east-1.amazonaws.com\tlambda-template\thello\n")})
+	// The permission was added by the setup that created the API
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+
+	if err := setupHTTPAPI(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range fake.Commands() {
+		if strings.HasPrefix(command, "aws apigatewayv2 get-apis") && !strings.Contains(command, "Name=='hello-public'") {
+			t.Errorf("get-apis does not look for api.name: %s", command)
+		}
+		if strings.HasPrefix(command, "aws apigatewayv2 create-api") {
+			t.Errorf("existing API was created again: %s", command)
+		}
+	}
+}
+
+func TestSetupHTTPAPIRefusesUntaggedAPI(t *testing.T) {
+	fake := useFake(t)
+	useClients(t)
+	config.API.Enabled = true
+	config.Project = "hello"
+
+	for _, output := range []string{
+		"z9y8x7\thttps://z9y8x7.execute-api.us-east-1.amazonaws.com\tNone\tNone\n",
+		"z9y8x7\thttps://z9y8x7.execute-api.us-east-1.amazonaws.com\tlambda-template\tother-project\n",
+	} {
+		fake.On([]string{"aws", "apigatewayv2", "get-apis"}, hostexec.Response{Output: []byte(output)})
+		if err := setupHTTPAPI(context.Background(), "123"); err == nil || !strings.Contains(err.Error(), "not created by setup") {
+			t.Errorf("setupHTTPAPI with %q = %v, want the API refused", output, err)
+		}
+	}
+}
+
+func TestSetupFunctionURLWithoutAuth(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.FunctionURL = appconfig.FunctionURL{Enabled: true, AuthType: "NONE"}
+
+	l.EXPECT().CreateFunctionUrlConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateFunctionUrlConfigInput, _ ...func(*lambda.Options)) (*lambda.CreateFunctionUrlConfigOutput, error) {
+		if input.AuthType != lambdatypes.FunctionUrlAuthTypeNone {
+			t.Errorf("function URL auth = %s, want NONE", input.AuthType)
+		}
+		return &lambda.CreateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-east-1.on.aws/")}, nil
+	})
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if *input.Principal != "*" || *input.Action != "lambda:InvokeFunctionUrl" || input.FunctionUrlAuthType != lambdatypes.FunctionUrlAuthTypeNone {
+			t.Errorf("AddPermission(%s, %s, %s), want anyone allowed to call the URL", *input.Principal, *input.Action, input.FunctionUrlAuthType)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+
+	if err := setupFunctionURL(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetupFunctionURLWithIAMAuth(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.FunctionURL = appconfig.FunctionURL{Enabled: true}
+
+	// No resource policy is added: callers need their own permission
+	l.EXPECT().CreateFunctionUrlConfig(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceConflictException{})
+	l.EXPECT().UpdateFunctionUrlConfig(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.UpdateFunctionUrlConfigInput, _ ...func(*lambda.Options)) (*lambda.UpdateFunctionUrlConfigOutput, error) {
+		if input.AuthType != lambdatypes.FunctionUrlAuthTypeAwsIam {
+			t.Errorf("function URL auth = %s, want AWS_IAM", input.AuthType)
+		}
+		return &lambda.UpdateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.us-east-1.on.aws/")}, nil
+	})
+
+	if err := setupFunctionURL(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPutScheduleRuleTagsOnce(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	previous := createdTags
+	createdTags = map[string]string{appconfig.CreatedByTag: appconfig.CreatedBy, appconfig.ProjectTag: "shop"}
+	t.Cleanup(func() { createdTags = previous })
+	fake.On([]string{"aws", "events", "put-rule"}, hostexec.Response{Output: []byte(`{"RuleArn":"arn:aws:events:us-east-1:123:rule/hello-schedule"}`)})
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).Return(&lambda.AddPermissionOutput{}, nil)
+
+	if err := putScheduleRule(context.Background(), "123", "hello-schedule", "rate(1 hour)"); err != nil {
+		t.Fatal(err)
+	}
+	args := fake.Calls[0].Args
+	var flags int
+	for _, arg := range args {
+		if arg == "--tags" {
+			flags++
+		}
+	}
+	// --tags given twice keeps only the last list, dropping the other tags
+	if flags != 1 || !strings.HasSuffix(strings.Join(args, " "), "--tags Key=created-by,Value=lambda-template Key=lambda-template:project,Value=shop") {
+		t.Errorf("put-rule %q, want every tag after a single --tags", args)
+	}
+}
+
+func TestPutScheduleRuleTargetsAlias(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.Deploy.Alias = "live"
+	fake.On([]string{"aws", "events", "put-rule"}, hostexec.Response{Output: []byte(`{"RuleArn":"arn:aws:events:us-east-1:123:rule/hello-schedule"}`)})
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if got := aws.ToString(input.FunctionName); got != "hello:live" {
+			t.Errorf("AddPermission on %s, want the alias", got)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+
+	if err := putScheduleRule(context.Background(), "123", "hello-schedule", "rate(1 hour)"); err != nil {
+		t.Fatal(err)
+	}
+	commands := strings.Join(fake.Commands(), "\n")
+	if !strings.Contains(commands, "--targets Id=hello,Arn=arn:aws:lambda:us-east-1:123:function:hello:live") {
+		t.Errorf("commands run:\n%s\nwant the rule pointed at the alias", commands)
+	}
+}
+
+func TestCreateDeployAlias(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Deploy.Alias = "live"
+
+	gomock.InOrder(
+		l.EXPECT().GetAlias(gomock.Any(), gomock.Any()).Return(nil, &lambdatypes.ResourceNotFoundException{}),
+		l.EXPECT().GetFunction(gomock.Any(), gomock.Any(), gomock.Any()).Return(&lambda.GetFunctionOutput{
+			Configuration: &lambdatypes.FunctionConfiguration{State: lambdatypes.StateActive},
+		}, nil),
+		l.EXPECT().PublishVersion(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.PublishVersionInput, _ ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+			if got, want := aws.ToString(input.Description), appconfig.AliasVersionDescription("live"); got != want {
+				t.Errorf("version description = %q, want %q", got, want)
+			}
+			return &lambda.PublishVersionOutput{Version: aws.String("1")}, nil
+		}),
+		l.EXPECT().CreateAlias(gomock.Any(), &lambda.CreateAliasInput{
+			FunctionName:    aws.String("hello"),
+			Name:            aws.String("live"),
+			FunctionVersion: aws.String("1"),
+		}).Return(&lambda.CreateAliasOutput{}, nil),
+	)
+
+	if err := createDeployAlias(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateDeployAliasKeepsExisting(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Deploy.Alias = "live"
+
+	// Deploy owns the version an existing alias points at
+	l.EXPECT().GetAlias(gomock.Any(), gomock.Any()).Return(&lambda.GetAliasOutput{FunctionVersion: aws.String("7")}, nil)
+
+	if err := createDeployAlias(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateSQSTrigger(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.SQS = appconfig.SQSTrigger{Queue: "orders", BatchSize: 100, BatchWindow: "20s"}
+
+	l.EXPECT().CreateEventSourceMapping(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateEventSourceMappingInput, _ ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+		if got := aws.ToString(input.EventSourceArn); got != "arn:aws:sqs:us-east-1:123:orders" {
+			t.Errorf("event source = %s", got)
+		}
+		if aws.ToInt32(input.BatchSize) != 100 || aws.ToInt32(input.MaximumBatchingWindowInSeconds) != 20 {
+			t.Errorf("batch size %d, window %d; want 100 and 20", aws.ToInt32(input.BatchSize), aws.ToInt32(input.MaximumBatchingWindowInSeconds))
+		}
+		return &lambda.CreateEventSourceMappingOutput{}, nil
+	})
+
+	if err := createSQSTrigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateSQSTriggerOnAlias(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Deploy.Alias = "live"
+	config.Triggers.SQS = appconfig.SQSTrigger{Queue: "orders"}
+
+	l.EXPECT().CreateEventSourceMapping(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateEventSourceMappingInput, _ ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+		if got := aws.ToString(input.FunctionName); got != "hello:live" {
+			t.Errorf("mapping created for %s, want the alias", got)
+		}
+		return &lambda.CreateEventSourceMappingOutput{}, nil
+	})
+
+	if err := createSQSTrigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscribeSNSTrigger(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.SNS = appconfig.SNSTrigger{Topic: "order-events"}
+
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if *input.Principal != "sns.amazonaws.com" || *input.SourceArn != "arn:aws:sns:us-east-1:123:order-events" {
+			t.Errorf("AddPermission(%s, %s), want SNS limited to the topic", *input.Principal, *input.SourceArn)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+	fake.On([]string{"aws", "sns", "subscribe"}, hostexec.Response{Output: []byte("arn:aws:sns:us-east-1:123:order-events:0f1e\n")})
+
+	if err := subscribeSNSTrigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	commands := fake.Commands()
+	if len(commands) != 1 || !strings.Contains(commands[0], "--protocol lambda --notification-endpoint arn:aws:lambda:us-east-1:123:function:hello") {
+		t.Errorf("commands = %q, want the function subscribed", commands)
+	}
+}
+
+func TestSubscribeSNSTriggerInTopicRegion(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.SNS = appconfig.SNSTrigger{Topic: "arn:aws:sns:eu-west-1:456:order-events"}
+
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).Return(&lambda.AddPermissionOutput{}, nil)
+	fake.On([]string{"aws", "sns", "subscribe"}, hostexec.Response{Output: []byte("arn:aws:sns:eu-west-1:456:order-events:0f1e\n")})
+
+	if err := subscribeSNSTrigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	if commands := fake.Commands(); len(commands) != 1 || !strings.HasSuffix(commands[0], "--region eu-west-1") {
+		t.Errorf("commands = %q, want the subscription made in the topic's region", commands)
+	}
+}
+
+func TestNotifyS3Trigger(t *testing.T) {
+	fake := useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.S3 = appconfig.S3Trigger{Bucket: "uploads.example.com", Prefix: "incoming/", Suffix: ".csv"}
+
+	l.EXPECT().AddPermission(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.AddPermissionInput, _ ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+		if *input.Principal != "s3.amazonaws.com" || *input.SourceArn != "arn:aws:s3:::uploads.example.com" || aws.ToString(input.SourceAccount) != "123" {
+			t.Errorf("AddPermission(%s, %s, %s), want S3 limited to the bucket in the account", *input.Principal, *input.SourceArn, aws.ToString(input.SourceAccount))
+		}
+		if *input.StatementId != "s3-uploads-example-com" {
+			t.Errorf("StatementId = %s, want the bucket name without dots", *input.StatementId)
+		}
+		return &lambda.AddPermissionOutput{}, nil
+	})
+	// A previous setup's entry is replaced and the other function's is kept
+	fake.On([]string{"aws", "s3api", "get-bucket-notification-configuration"}, hostexec.Response{Output: []byte(`{
+		"LambdaFunctionConfigurations": [
+			{"Id": "hello-trigger", "LambdaFunctionArn": "arn:aws:lambda:us-east-1:123:function:hello", "Events": ["s3:ObjectRemoved:*"]},
+			{"Id": "thumbnails", "LambdaFunctionArn": "arn:aws:lambda:us-east-1:123:function:thumbnails", "Events": ["s3:ObjectCreated:Put"]}
+		],
+		"EventBridgeConfiguration": {}
+	}`)})
+
+	if err := notifyS3Trigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+	var put string
+	for _, command := range fake.Commands() {
+		if strings.HasPrefix(command, "aws s3api put-bucket-notification-configuration") {
+			put = command
+		}
+	}
+	for _, want := range []string{
+		`"EventBridgeConfiguration":{}`,
+		`"Id":"thumbnails"`,
+		`"Events":["s3:ObjectCreated:*"]`,
+		`"FilterRules":[{"Name":"prefix","Value":"incoming/"},{"Name":"suffix","Value":".csv"}]`,
+	} {
+		if !strings.Contains(put, want) {
+			t.Errorf("put-bucket-notification-configuration is missing %s: %s", want, put)
+		}
+	}
+	if strings.Contains(put, "ObjectRemoved") {
+		t.Errorf("previous entry was kept: %s", put)
+	}
+}
+
+func TestCreateDynamoDBTrigger(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.DynamoDB = appconfig.DynamoDBTrigger{Stream: "arn:aws:dynamodb:us-east-1:123:table/orders/stream/2024-01-01T00:00:00.000", StartingPosition: "TRIM_HORIZON", ParallelizationFactor: 4}
+
+	l.EXPECT().CreateEventSourceMapping(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateEventSourceMappingInput, _ ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+		if input.StartingPosition != lambdatypes.EventSourcePositionTrimHorizon || aws.ToInt32(input.BatchSize) != 100 || aws.ToInt32(input.ParallelizationFactor) != 4 {
+			t.Errorf("starting position %s, batch size %d, parallelization factor %d; want TRIM_HORIZON, 100 and 4", input.StartingPosition, aws.ToInt32(input.BatchSize), aws.ToInt32(input.ParallelizationFactor))
+		}
+		return &lambda.CreateEventSourceMappingOutput{}, nil
+	})
+
+	if err := createDynamoDBTrigger(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateKinesisTrigger(t *testing.T) {
+	useFake(t)
+	_, _, l := useClients(t)
+	config.Triggers.Kinesis = appconfig.KinesisTrigger{Stream: "clicks", TumblingWindow: "30s", OnFailure: "arn:aws:sqs:us-east-1:123:clicks-failed"}
+
+	l.EXPECT().CreateEventSourceMapping(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, input *lambda.CreateEventSourceMappingInput, _ ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+		if got := aws.ToString(input.EventSourceArn); got != "arn:aws:kinesis:us-east-1:123:stream/clicks" {
+			t.Errorf("event source = %s", got)
+		}
+		if input.StartingPosition != lambdatypes.EventSourcePositionLatest || aws.ToInt32(input.TumblingWindowInSeconds) != 30 {
+			t.Errorf("starting position %s, tumbling window %d; want LATEST and 30", input.StartingPosition, aws.ToInt32(input.TumblingWindowInSeconds))
+		}
+		if input.DestinationConfig == nil || aws.ToString(input.DestinationConfig.OnFailure.Destination) != "arn:aws:sqs:us-east-1:123:clicks-failed" {
+			t.Errorf("destination config = %+v, want the on-failure queue", input.DestinationConfig)
+		}
+		return &lambda.CreateEventSourceMappingOutput{}, nil
+	})
+
+	if err := createKinesisTrigger(context.Background(), "123"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,573 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: api.go
+//
+// Generated by this command:
+//
+//	mockgen -source=api.go -destination=mock_api_test.go -package=setup
+//
+
+// Package setup is a generated GoMock package.
+package setup
+
+import (
+	context "context"
+	reflect "reflect"
+
+	ecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	iam "github.com/aws/aws-sdk-go-v2/service/iam"
+	lambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	sts "github.com/aws/aws-sdk-go-v2/service/sts"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockiamAPI is a mock of iamAPI interface.
+type MockiamAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockiamAPIMockRecorder
+}
+
+// MockiamAPIMockRecorder is the mock recorder for MockiamAPI.
+type MockiamAPIMockRecorder struct {
+	mock *MockiamAPI
+}
+
+// NewMockiamAPI creates a new mock instance.
+func NewMockiamAPI(ctrl *gomock.Controller) *MockiamAPI {
+	mock := &MockiamAPI{ctrl: ctrl}
+	mock.recorder = &MockiamAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockiamAPI) EXPECT() *MockiamAPIMockRecorder {
+	return m.recorder
+}
+
+// AttachRolePolicy mocks base method.
+func (m *MockiamAPI) AttachRolePolicy(ctx context.Context, params *iam.AttachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AttachRolePolicy", varargs...)
+	ret0, _ := ret[0].(*iam.AttachRolePolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AttachRolePolicy indicates an expected call of AttachRolePolicy.
+func (mr *MockiamAPIMockRecorder) AttachRolePolicy(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AttachRolePolicy", reflect.TypeOf((*MockiamAPI)(nil).AttachRolePolicy), varargs...)
+}
+
+// CreateRole mocks base method.
+func (m *MockiamAPI) CreateRole(ctx context.Context, params *iam.CreateRoleInput, optFns ...func(*iam.Options)) (*iam.CreateRoleOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateRole", varargs...)
+	ret0, _ := ret[0].(*iam.CreateRoleOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRole indicates an expected call of CreateRole.
+func (mr *MockiamAPIMockRecorder) CreateRole(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRole", reflect.TypeOf((*MockiamAPI)(nil).CreateRole), varargs...)
+}
+
+// GetRole mocks base method.
+func (m *MockiamAPI) GetRole(ctx context.Context, params *iam.GetRoleInput, optFns ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetRole", varargs...)
+	ret0, _ := ret[0].(*iam.GetRoleOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRole indicates an expected call of GetRole.
+func (mr *MockiamAPIMockRecorder) GetRole(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockiamAPI)(nil).GetRole), varargs...)
+}
+
+// PutRolePolicy mocks base method.
+func (m *MockiamAPI) PutRolePolicy(ctx context.Context, params *iam.PutRolePolicyInput, optFns ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PutRolePolicy", varargs...)
+	ret0, _ := ret[0].(*iam.PutRolePolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutRolePolicy indicates an expected call of PutRolePolicy.
+func (mr *MockiamAPIMockRecorder) PutRolePolicy(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutRolePolicy", reflect.TypeOf((*MockiamAPI)(nil).PutRolePolicy), varargs...)
+}
+
+// MockecrAPI is a mock of ecrAPI interface.
+type MockecrAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockecrAPIMockRecorder
+}
+
+// MockecrAPIMockRecorder is the mock recorder for MockecrAPI.
+type MockecrAPIMockRecorder struct {
+	mock *MockecrAPI
+}
+
+// NewMockecrAPI creates a new mock instance.
+func NewMockecrAPI(ctrl *gomock.Controller) *MockecrAPI {
+	mock := &MockecrAPI{ctrl: ctrl}
+	mock.recorder = &MockecrAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockecrAPI) EXPECT() *MockecrAPIMockRecorder {
+	return m.recorder
+}
+
+// CreateRepository mocks base method.
+func (m *MockecrAPI) CreateRepository(ctx context.Context, params *ecr.CreateRepositoryInput, optFns ...func(*ecr.Options)) (*ecr.CreateRepositoryOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateRepository", varargs...)
+	ret0, _ := ret[0].(*ecr.CreateRepositoryOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRepository indicates an expected call of CreateRepository.
+func (mr *MockecrAPIMockRecorder) CreateRepository(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRepository", reflect.TypeOf((*MockecrAPI)(nil).CreateRepository), varargs...)
+}
+
+// DescribeRepositories mocks base method.
+func (m *MockecrAPI) DescribeRepositories(ctx context.Context, params *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DescribeRepositories", varargs...)
+	ret0, _ := ret[0].(*ecr.DescribeRepositoriesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DescribeRepositories indicates an expected call of DescribeRepositories.
+func (mr *MockecrAPIMockRecorder) DescribeRepositories(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DescribeRepositories", reflect.TypeOf((*MockecrAPI)(nil).DescribeRepositories), varargs...)
+}
+
+// GetAuthorizationToken mocks base method.
+func (m *MockecrAPI) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAuthorizationToken", varargs...)
+	ret0, _ := ret[0].(*ecr.GetAuthorizationTokenOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizationToken indicates an expected call of GetAuthorizationToken.
+func (mr *MockecrAPIMockRecorder) GetAuthorizationToken(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationToken", reflect.TypeOf((*MockecrAPI)(nil).GetAuthorizationToken), varargs...)
+}
+
+// GetRepositoryPolicy mocks base method.
+func (m *MockecrAPI) GetRepositoryPolicy(ctx context.Context, params *ecr.GetRepositoryPolicyInput, optFns ...func(*ecr.Options)) (*ecr.GetRepositoryPolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetRepositoryPolicy", varargs...)
+	ret0, _ := ret[0].(*ecr.GetRepositoryPolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepositoryPolicy indicates an expected call of GetRepositoryPolicy.
+func (mr *MockecrAPIMockRecorder) GetRepositoryPolicy(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepositoryPolicy", reflect.TypeOf((*MockecrAPI)(nil).GetRepositoryPolicy), varargs...)
+}
+
+// SetRepositoryPolicy mocks base method.
+func (m *MockecrAPI) SetRepositoryPolicy(ctx context.Context, params *ecr.SetRepositoryPolicyInput, optFns ...func(*ecr.Options)) (*ecr.SetRepositoryPolicyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SetRepositoryPolicy", varargs...)
+	ret0, _ := ret[0].(*ecr.SetRepositoryPolicyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetRepositoryPolicy indicates an expected call of SetRepositoryPolicy.
+func (mr *MockecrAPIMockRecorder) SetRepositoryPolicy(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRepositoryPolicy", reflect.TypeOf((*MockecrAPI)(nil).SetRepositoryPolicy), varargs...)
+}
+
+// MockstsAPI is a mock of stsAPI interface.
+type MockstsAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockstsAPIMockRecorder
+}
+
+// MockstsAPIMockRecorder is the mock recorder for MockstsAPI.
+type MockstsAPIMockRecorder struct {
+	mock *MockstsAPI
+}
+
+// NewMockstsAPI creates a new mock instance.
+func NewMockstsAPI(ctrl *gomock.Controller) *MockstsAPI {
+	mock := &MockstsAPI{ctrl: ctrl}
+	mock.recorder = &MockstsAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockstsAPI) EXPECT() *MockstsAPIMockRecorder {
+	return m.recorder
+}
+
+// GetCallerIdentity mocks base method.
+func (m *MockstsAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetCallerIdentity", varargs...)
+	ret0, _ := ret[0].(*sts.GetCallerIdentityOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCallerIdentity indicates an expected call of GetCallerIdentity.
+func (mr *MockstsAPIMockRecorder) GetCallerIdentity(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCallerIdentity", reflect.TypeOf((*MockstsAPI)(nil).GetCallerIdentity), varargs...)
+}
+
+// MocklambdaAPI is a mock of lambdaAPI interface.
+type MocklambdaAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MocklambdaAPIMockRecorder
+}
+
+// MocklambdaAPIMockRecorder is the mock recorder for MocklambdaAPI.
+type MocklambdaAPIMockRecorder struct {
+	mock *MocklambdaAPI
+}
+
+// NewMocklambdaAPI creates a new mock instance.
+func NewMocklambdaAPI(ctrl *gomock.Controller) *MocklambdaAPI {
+	mock := &MocklambdaAPI{ctrl: ctrl}
+	mock.recorder = &MocklambdaAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocklambdaAPI) EXPECT() *MocklambdaAPIMockRecorder {
+	return m.recorder
+}
+
+// AddPermission mocks base method.
+func (m *MocklambdaAPI) AddPermission(ctx context.Context, params *lambda.AddPermissionInput, optFns ...func(*lambda.Options)) (*lambda.AddPermissionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddPermission", varargs...)
+	ret0, _ := ret[0].(*lambda.AddPermissionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddPermission indicates an expected call of AddPermission.
+func (mr *MocklambdaAPIMockRecorder) AddPermission(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPermission", reflect.TypeOf((*MocklambdaAPI)(nil).AddPermission), varargs...)
+}
+
+// CreateAlias mocks base method.
+func (m *MocklambdaAPI) CreateAlias(ctx context.Context, params *lambda.CreateAliasInput, optFns ...func(*lambda.Options)) (*lambda.CreateAliasOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateAlias", varargs...)
+	ret0, _ := ret[0].(*lambda.CreateAliasOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAlias indicates an expected call of CreateAlias.
+func (mr *MocklambdaAPIMockRecorder) CreateAlias(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAlias", reflect.TypeOf((*MocklambdaAPI)(nil).CreateAlias), varargs...)
+}
+
+// CreateEventSourceMapping mocks base method.
+func (m *MocklambdaAPI) CreateEventSourceMapping(ctx context.Context, params *lambda.CreateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.CreateEventSourceMappingOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateEventSourceMapping", varargs...)
+	ret0, _ := ret[0].(*lambda.CreateEventSourceMappingOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEventSourceMapping indicates an expected call of CreateEventSourceMapping.
+func (mr *MocklambdaAPIMockRecorder) CreateEventSourceMapping(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEventSourceMapping", reflect.TypeOf((*MocklambdaAPI)(nil).CreateEventSourceMapping), varargs...)
+}
+
+// CreateFunction mocks base method.
+func (m *MocklambdaAPI) CreateFunction(ctx context.Context, params *lambda.CreateFunctionInput, optFns ...func(*lambda.Options)) (*lambda.CreateFunctionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateFunction", varargs...)
+	ret0, _ := ret[0].(*lambda.CreateFunctionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateFunction indicates an expected call of CreateFunction.
+func (mr *MocklambdaAPIMockRecorder) CreateFunction(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFunction", reflect.TypeOf((*MocklambdaAPI)(nil).CreateFunction), varargs...)
+}
+
+// CreateFunctionUrlConfig mocks base method.
+func (m *MocklambdaAPI) CreateFunctionUrlConfig(ctx context.Context, params *lambda.CreateFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.CreateFunctionUrlConfigOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CreateFunctionUrlConfig", varargs...)
+	ret0, _ := ret[0].(*lambda.CreateFunctionUrlConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateFunctionUrlConfig indicates an expected call of CreateFunctionUrlConfig.
+func (mr *MocklambdaAPIMockRecorder) CreateFunctionUrlConfig(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFunctionUrlConfig", reflect.TypeOf((*MocklambdaAPI)(nil).CreateFunctionUrlConfig), varargs...)
+}
+
+// GetAccountSettings mocks base method.
+func (m *MocklambdaAPI) GetAccountSettings(ctx context.Context, params *lambda.GetAccountSettingsInput, optFns ...func(*lambda.Options)) (*lambda.GetAccountSettingsOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAccountSettings", varargs...)
+	ret0, _ := ret[0].(*lambda.GetAccountSettingsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountSettings indicates an expected call of GetAccountSettings.
+func (mr *MocklambdaAPIMockRecorder) GetAccountSettings(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountSettings", reflect.TypeOf((*MocklambdaAPI)(nil).GetAccountSettings), varargs...)
+}
+
+// GetAlias mocks base method.
+func (m *MocklambdaAPI) GetAlias(ctx context.Context, params *lambda.GetAliasInput, optFns ...func(*lambda.Options)) (*lambda.GetAliasOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetAlias", varargs...)
+	ret0, _ := ret[0].(*lambda.GetAliasOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAlias indicates an expected call of GetAlias.
+func (mr *MocklambdaAPIMockRecorder) GetAlias(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAlias", reflect.TypeOf((*MocklambdaAPI)(nil).GetAlias), varargs...)
+}
+
+// GetFunction mocks base method.
+func (m *MocklambdaAPI) GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFunction", varargs...)
+	ret0, _ := ret[0].(*lambda.GetFunctionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFunction indicates an expected call of GetFunction.
+func (mr *MocklambdaAPIMockRecorder) GetFunction(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunction", reflect.TypeOf((*MocklambdaAPI)(nil).GetFunction), varargs...)
+}
+
+// GetFunctionConfiguration mocks base method.
+func (m *MocklambdaAPI) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetFunctionConfiguration", varargs...)
+	ret0, _ := ret[0].(*lambda.GetFunctionConfigurationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFunctionConfiguration indicates an expected call of GetFunctionConfiguration.
+func (mr *MocklambdaAPIMockRecorder) GetFunctionConfiguration(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFunctionConfiguration", reflect.TypeOf((*MocklambdaAPI)(nil).GetFunctionConfiguration), varargs...)
+}
+
+// PublishVersion mocks base method.
+func (m *MocklambdaAPI) PublishVersion(ctx context.Context, params *lambda.PublishVersionInput, optFns ...func(*lambda.Options)) (*lambda.PublishVersionOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PublishVersion", varargs...)
+	ret0, _ := ret[0].(*lambda.PublishVersionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PublishVersion indicates an expected call of PublishVersion.
+func (mr *MocklambdaAPIMockRecorder) PublishVersion(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishVersion", reflect.TypeOf((*MocklambdaAPI)(nil).PublishVersion), varargs...)
+}
+
+// PutFunctionConcurrency mocks base method.
+func (m *MocklambdaAPI) PutFunctionConcurrency(ctx context.Context, params *lambda.PutFunctionConcurrencyInput, optFns ...func(*lambda.Options)) (*lambda.PutFunctionConcurrencyOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "PutFunctionConcurrency", varargs...)
+	ret0, _ := ret[0].(*lambda.PutFunctionConcurrencyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutFunctionConcurrency indicates an expected call of PutFunctionConcurrency.
+func (mr *MocklambdaAPIMockRecorder) PutFunctionConcurrency(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutFunctionConcurrency", reflect.TypeOf((*MocklambdaAPI)(nil).PutFunctionConcurrency), varargs...)
+}
+
+// TagResource mocks base method.
+func (m *MocklambdaAPI) TagResource(ctx context.Context, params *lambda.TagResourceInput, optFns ...func(*lambda.Options)) (*lambda.TagResourceOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "TagResource", varargs...)
+	ret0, _ := ret[0].(*lambda.TagResourceOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TagResource indicates an expected call of TagResource.
+func (mr *MocklambdaAPIMockRecorder) TagResource(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TagResource", reflect.TypeOf((*MocklambdaAPI)(nil).TagResource), varargs...)
+}
+
+// UpdateFunctionUrlConfig mocks base method.
+func (m *MocklambdaAPI) UpdateFunctionUrlConfig(ctx context.Context, params *lambda.UpdateFunctionUrlConfigInput, optFns ...func(*lambda.Options)) (*lambda.UpdateFunctionUrlConfigOutput, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, params}
+	for _, a := range optFns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateFunctionUrlConfig", varargs...)
+	ret0, _ := ret[0].(*lambda.UpdateFunctionUrlConfigOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFunctionUrlConfig indicates an expected call of UpdateFunctionUrlConfig.
+func (mr *MocklambdaAPIMockRecorder) UpdateFunctionUrlConfig(ctx, params any, optFns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, params}, optFns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFunctionUrlConfig", reflect.TypeOf((*MocklambdaAPI)(nil).UpdateFunctionUrlConfig), varargs...)
+}
@@ -0,0 +1,147 @@
+package setup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"example-lambda-go/internal/pipeline"
+)
+
+// progress is what the current setup of the function has completed. It is
+// kept in .lambda-template/<function>.setup.json until setup finishes, so
+// that setup -resume can skip the steps before the one that failed.
+type progress struct {
+	// Config fingerprints the configuration the steps ran with; resuming
+	// with another one starts over, as the completed steps may not match
+	Config string   `json:"config"`
+	Steps  []string `json:"steps"`
+	// RoleARN and RepositoryURI are what the role and ecr steps found, which
+	// later steps need when those are skipped
+	RoleARN       string `json:"role_arn,omitempty"`
+	RepositoryURI string `json:"repository_uri,omitempty"`
+
+	path string
+}
+
+// done is the progress of this run; without -resume, it starts empty.
+var done = &progress{}
+
+func progressPath() string {
+	return filepath.Join(".lambda-template", config.Lambda.FunctionName+".setup.json")
+}
+
+func configFingerprint() (string, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("error encoding configuration: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// loadProgress starts the progress of this run. With resume, it picks up the
+// progress the previous run left and restores what its steps found;
+// otherwise the previous progress is discarded.
+func loadProgress(resume bool) (*progress, error) {
+	fingerprint, err := configFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	p := &progress{Config: fingerprint, path: progressPath()}
+	if !resume {
+		if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error removing setup progress: %v", err)
+		}
+		return p, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("No unfinished setup to resume; running every step")
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading setup progress: %v", err)
+	}
+	var previous progress
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("error decoding setup progress %s: %v", p.path, err)
+	}
+	if previous.Config != fingerprint {
+		fmt.Println("The configuration changed since the unfinished setup; running every step")
+		return p, nil
+	}
+	previous.path = p.path
+	if previous.has("role") {
+		os.Setenv("LAMBDA_EXECUTION_ROLE_ARN", previous.RoleARN)
+	}
+	if previous.has("ecr") {
+		repositoryURI = previous.RepositoryURI
+	}
+	return &previous, nil
+}
+
+func (p *progress) has(step string) bool {
+	for _, s := range p.Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records the step and what it found.
+func (p *progress) complete(step string) error {
+	p.Steps = append(p.Steps, step)
+	p.RoleARN = os.Getenv("LAMBDA_EXECUTION_ROLE_ARN")
+	p.RepositoryURI = repositoryURI
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding setup progress: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("error saving setup progress: %v", err)
+	}
+	if err := os.WriteFile(p.path, data, 0o644); err != nil {
+		return fmt.Errorf("error saving setup progress: %v", err)
+	}
+	return nil
+}
+
+// finish removes the progress once every step has run.
+func (p *progress) finish() {
+	if p.path == "" {
+		return
+	}
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Could not remove setup progress %s: %v\n", p.path, err)
+	}
+}
+
+// step runs fn as the named pipeline step and records it as completed, or
+// skips it when the run being resumed completed it. ran is false when the
+// step was skipped.
+func step(run *pipeline.Pipeline, name string, fn func(ctx context.Context) error) (ran bool, err error) {
+	if done.has(name) {
+		run.Skip(name, "completed by the setup being resumed")
+		return false, nil
+	}
+	if err := run.Step(name, fn); err != nil {
+		if len(done.Steps) > 0 {
+			fmt.Printf("Completed steps are recorded in %s; `lambda-template setup -resume` skips them\n", done.path)
+		}
+		return true, err
+	}
+	return true, done.complete(name)
+}
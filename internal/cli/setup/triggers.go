@@ -0,0 +1,308 @@
+package setup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// putSQSTriggerPolicy lets the execution role read and delete the messages
+// of triggers.sqs.queue, which Lambda checks when the mapping is created.
+func putSQSTriggerPolicy(ctx context.Context, awsAccountID string) error {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"sqs:ReceiveMessage", "sqs:DeleteMessage", "sqs:GetQueueAttributes", "sqs:ChangeMessageVisibility"},
+			"Resource": []string{config.SQSQueueARN(awsAccountID)},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding SQS trigger policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("sqs-trigger"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting SQS trigger policy: %v", err)
+	}
+
+	fmt.Println("SQS trigger policy attached to Lambda execution role")
+	return nil
+}
+
+// createSQSTrigger maps triggers.sqs.queue to the function. A mapping that
+// already exists is left to deploy, which brings its batch settings in line.
+func createSQSTrigger(ctx context.Context, awsAccountID string) error {
+	trigger := config.Triggers.SQS
+	_, err := api.lambda.CreateEventSourceMapping(ctx, &lambda.CreateEventSourceMappingInput{
+		FunctionName:                   aws.String(config.TriggerTarget()),
+		EventSourceArn:                 aws.String(config.SQSQueueARN(awsAccountID)),
+		BatchSize:                      aws.Int32(int32(trigger.MessagesPerBatch())),
+		MaximumBatchingWindowInSeconds: aws.Int32(int32(trigger.BatchWindowSeconds())),
+	})
+	if isConflict(err) {
+		fmt.Println("SQS trigger already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating event source mapping: %v", err)
+	}
+
+	fmt.Printf("SQS trigger created for %s\n", trigger.Queue)
+	return nil
+}
+
+// putDynamoDBTriggerPolicy lets the execution role read triggers.dynamodb.stream,
+// the scoped equivalent of AWSLambdaDynamoDBExecutionRole without its logs
+// permissions, which the role has already.
+func putDynamoDBTriggerPolicy(ctx context.Context) error {
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":   "Allow",
+			"Action":   []string{"dynamodb:DescribeStream", "dynamodb:GetRecords", "dynamodb:GetShardIterator"},
+			"Resource": []string{config.Triggers.DynamoDB.Stream},
+		}, {
+			// ListStreams cannot be scoped
+			"Effect":   "Allow",
+			"Action":   []string{"dynamodb:ListStreams"},
+			"Resource": []string{"*"},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding DynamoDB trigger policy: %v", err)
+	}
+
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("dynamodb-trigger"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting DynamoDB trigger policy: %v", err)
+	}
+
+	fmt.Println("DynamoDB trigger policy attached to Lambda execution role")
+	return nil
+}
+
+// createDynamoDBTrigger maps triggers.dynamodb.stream to the function. As
+// for SQS, deploy brings the batch settings of an existing mapping in line;
+// its starting position cannot change.
+func createDynamoDBTrigger(ctx context.Context) error {
+	trigger := config.Triggers.DynamoDB
+	_, err := api.lambda.CreateEventSourceMapping(ctx, &lambda.CreateEventSourceMappingInput{
+		FunctionName:          aws.String(config.TriggerTarget()),
+		EventSourceArn:        aws.String(trigger.Stream),
+		StartingPosition:      lambdatypes.EventSourcePosition(trigger.Position()),
+		BatchSize:             aws.Int32(int32(trigger.RecordsPerBatch())),
+		ParallelizationFactor: aws.Int32(int32(trigger.BatchesPerShard())),
+	})
+	if isConflict(err) {
+		fmt.Println("DynamoDB trigger already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating event source mapping: %v", err)
+	}
+
+	fmt.Printf("DynamoDB trigger created for %s, from %s\n", trigger.Stream, trigger.Position())
+	return nil
+}
+
+// putKinesisTriggerPolicy lets the execution role read triggers.kinesis.stream
+// and send to its on_failure destination.
+func putKinesisTriggerPolicy(ctx context.Context, awsAccountID string) error {
+	policy, err := config.KinesisTriggerPolicy(awsAccountID)
+	if err != nil {
+		return err
+	}
+	_, err = api.iam.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(config.Lambda.RoleName),
+		PolicyName:     aws.String("kinesis-trigger"),
+		PolicyDocument: aws.String(policy),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting Kinesis trigger policy: %v", err)
+	}
+
+	fmt.Println("Kinesis trigger policy attached to Lambda execution role")
+	return nil
+}
+
+// createKinesisTrigger maps triggers.kinesis.stream to the function. Deploy
+// brings the tumbling window and on-failure destination of an existing
+// mapping in line.
+func createKinesisTrigger(ctx context.Context, awsAccountID string) error {
+	trigger := config.Triggers.Kinesis
+	input := &lambda.CreateEventSourceMappingInput{
+		FunctionName:            aws.String(config.TriggerTarget()),
+		EventSourceArn:          aws.String(config.KinesisStreamARN(awsAccountID)),
+		StartingPosition:        lambdatypes.EventSourcePosition(trigger.Position()),
+		TumblingWindowInSeconds: aws.Int32(int32(trigger.TumblingWindowSeconds())),
+	}
+	if trigger.OnFailure != "" {
+		input.DestinationConfig = &lambdatypes.DestinationConfig{
+			OnFailure: &lambdatypes.OnFailure{Destination: aws.String(trigger.OnFailure)},
+		}
+	}
+	_, err := api.lambda.CreateEventSourceMapping(ctx, input)
+	if isConflict(err) {
+		fmt.Println("Kinesis trigger already exists")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating event source mapping: %v", err)
+	}
+
+	fmt.Printf("Kinesis trigger created for %s, from %s\n", trigger.Stream, trigger.Position())
+	return nil
+}
+
+// subscribeSNSTrigger lets triggers.sns.topic invoke the function and
+// subscribes the function to it. SNS returns the existing subscription when
+// the function is subscribed already. The subscription is made in the
+// topic's region, which may differ from the function's.
+func subscribeSNSTrigger(ctx context.Context, awsAccountID string) error {
+	topicARN := config.SNSTopicARN(awsAccountID)
+	functionARN := config.TriggerTargetARN(awsAccountID)
+
+	_, err := api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(config.TriggerTarget()),
+		StatementId:  aws.String("sns-" + config.SNSTopicName()),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("sns.amazonaws.com"),
+		SourceArn:    aws.String(topicARN),
+	})
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error allowing SNS to invoke the function: %v", err)
+	}
+
+	subscribeCmd := exec.Command("aws", "sns", "subscribe",
+		"--topic-arn", topicARN,
+		"--protocol", "lambda",
+		"--notification-endpoint", functionARN,
+		"--return-subscription-arn",
+		"--query", "SubscriptionArn",
+		"--output", "text",
+		"--profile", config.AWS.Profile,
+		"--region", config.SNSTopicRegion())
+	output, err := hostexec.CombinedOutput(subscribeCmd)
+	if err != nil {
+		return fmt.Errorf("error subscribing to %s: %v\n%s", topicARN, err, output)
+	}
+
+	fmt.Printf("Function subscribed to %s (%s)\n", config.Triggers.SNS.Topic, strings.TrimSpace(string(output)))
+	return nil
+}
+
+// notifyS3Trigger lets triggers.s3.bucket invoke the function and adds the
+// function to the bucket's notification configuration. S3 replaces the
+// configuration as a whole, so the bucket's other notifications are read
+// and put back with it; a previous entry of the function's is replaced.
+func notifyS3Trigger(ctx context.Context, awsAccountID string) error {
+	trigger := config.Triggers.S3
+	functionARN := config.TriggerTargetARN(awsAccountID)
+
+	// A bucket ARN has no account, so SourceAccount keeps a bucket of the
+	// same name in another account from invoking the function. Statement IDs
+	// cannot contain the dots bucket names may have.
+	_, err := api.lambda.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName:  aws.String(config.TriggerTarget()),
+		StatementId:   aws.String("s3-" + strings.ReplaceAll(trigger.Bucket, ".", "-")),
+		Action:        aws.String("lambda:InvokeFunction"),
+		Principal:     aws.String("s3.amazonaws.com"),
+		SourceArn:     aws.String(config.S3BucketARN()),
+		SourceAccount: aws.String(awsAccountID),
+	})
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("error allowing S3 to invoke the function: %v", err)
+	}
+
+	getCmd := exec.Command("aws", "s3api", "get-bucket-notification-configuration",
+		"--bucket", trigger.Bucket,
+		"--output", "json",
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	output, err := hostexec.Output(getCmd)
+	if err != nil {
+		return fmt.Errorf("error reading the notifications of bucket %s: %v", trigger.Bucket, err)
+	}
+	notifications, err := withS3Notification(output, functionARN)
+	if err != nil {
+		return fmt.Errorf("error reading the notifications of bucket %s: %v", trigger.Bucket, err)
+	}
+
+	putCmd := exec.Command("aws", "s3api", "put-bucket-notification-configuration",
+		"--bucket", trigger.Bucket,
+		"--notification-configuration", string(notifications),
+		"--profile", config.AWS.Profile,
+		"--region", config.AWS.Region)
+	if output, err := hostexec.CombinedOutput(putCmd); err != nil {
+		return fmt.Errorf("error adding the function to the notifications of bucket %s: %v\n%s", trigger.Bucket, err, output)
+	}
+
+	fmt.Printf("Bucket %s notifies the function of %s\n", trigger.Bucket, strings.Join(trigger.EventTypes(), ", "))
+	return nil
+}
+
+// withS3Notification returns the bucket's notification configuration, as
+// get-bucket-notification-configuration prints it, with the function's entry
+// set from triggers.s3.
+func withS3Notification(current []byte, functionARN string) ([]byte, error) {
+	notifications := map[string]json.RawMessage{}
+	if len(strings.TrimSpace(string(current))) > 0 {
+		if err := json.Unmarshal(current, &notifications); err != nil {
+			return nil, err
+		}
+	}
+	var functions []map[string]interface{}
+	if existing, ok := notifications["LambdaFunctionConfigurations"]; ok {
+		if err := json.Unmarshal(existing, &functions); err != nil {
+			return nil, err
+		}
+	}
+
+	trigger := config.Triggers.S3
+	entry := map[string]interface{}{
+		"Id":                config.S3NotificationID(),
+		"LambdaFunctionArn": functionARN,
+		"Events":            trigger.EventTypes(),
+	}
+	var rules []map[string]string
+	if trigger.Prefix != "" {
+		rules = append(rules, map[string]string{"Name": "prefix", "Value": trigger.Prefix})
+	}
+	if trigger.Suffix != "" {
+		rules = append(rules, map[string]string{"Name": "suffix", "Value": trigger.Suffix})
+	}
+	if len(rules) > 0 {
+		entry["Filter"] = map[string]interface{}{"Key": map[string]interface{}{"FilterRules": rules}}
+	}
+
+	kept := []map[string]interface{}{entry}
+	for _, f := range functions {
+		if f["Id"] != config.S3NotificationID() {
+			kept = append(kept, f)
+		}
+	}
+	encoded, err := json.Marshal(kept)
+	if err != nil {
+		return nil, err
+	}
+	notifications["LambdaFunctionConfigurations"] = encoded
+	return json.Marshal(notifications)
+}
@@ -0,0 +1,52 @@
+package shadow
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/shadow"
+)
+
+// Main reports how the shadow's results compared with the function's over
+// the mirrored invocations it logged.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template shadow", flag.ExitOnError)
+	since := flags.Duration("since", 24*time.Hour, "How far back to read comparisons")
+	limit := flags.Int("limit", 10, "Mismatches to print")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template shadow [-since 24h] [-limit 10]")
+		fmt.Fprintln(os.Stderr, "Summarizes the comparisons logged by the shadow.function in config.yaml: how many")
+		fmt.Fprintln(os.Stderr, "responses matched, errors on either side, latency, and the mismatches themselves.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.Shadow.Function == "" {
+		log.Fatal("shadow.function is not set in config.yaml")
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	logGroup := shadow.LogGroup(cfg.Shadow.Function)
+	report, err := shadow.Collect(context.TODO(), cloudwatchlogs.NewFromConfig(awsCfg), logGroup, time.Now().Add(-*since))
+	if err != nil {
+		log.Fatalf("Error collecting shadow comparisons: %v", err)
+	}
+	report.Print(os.Stdout, *limit)
+}
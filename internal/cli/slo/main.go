@@ -0,0 +1,64 @@
+package slo
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/slo"
+)
+
+// Main reports the error budgets and exits 1 when one is exhausted or
+// burning too fast.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template slo", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template slo")
+		fmt.Fprintln(os.Stderr, "Reports the error budget left for the objectives in config.yaml and exits 1 when it is")
+		fmt.Fprintln(os.Stderr, "exhausted or burning faster than a burn alert allows.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if !cfg.SLO.Enabled() {
+		log.Fatal("slo.availability or slo.latency is not set in config.yaml")
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+
+	// Traffic moves between the pair, so both count towards the budget
+	functions := []string{cfg.Lambda.FunctionName}
+	if cfg.Deploy.Strategy == "bluegreen" {
+		functions = append(functions, cfg.Lambda.FunctionName+"-green")
+	}
+
+	report, err := slo.Evaluate(context.TODO(), cloudwatch.NewFromConfig(awsCfg), cfg.SLO, functions, time.Now())
+	if err != nil {
+		log.Fatalf("Error evaluating SLOs: %v", err)
+	}
+	report.Print(os.Stdout)
+
+	switch {
+	case report.Exhausted():
+		fmt.Println("\nError budget exhausted.")
+		os.Exit(1)
+	case report.Firing():
+		fmt.Println("\nError budget is burning too fast.")
+		os.Exit(1)
+	}
+}
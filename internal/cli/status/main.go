@@ -0,0 +1,230 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/console"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/introspect"
+	"example-lambda-go/internal/router"
+	"example-lambda-go/internal/triggers"
+)
+
+// Main prints the function's state, maintenance mode and triggers, and with
+// -introspect what the deployed code handles.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template status", flag.ExitOnError)
+	introspectFlag := flags.Bool("introspect", false, "Also ask the deployed code for its routes, handlers, event versions and build")
+	flags.Parse(args)
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	client := lambda.NewFromConfig(awsCfg)
+	eventsClient := eventbridge.NewFromConfig(awsCfg)
+
+	function, err := client.GetFunction(context.TODO(), &lambda.GetFunctionInput{
+		FunctionName: aws.String(cfg.Lambda.FunctionName),
+	})
+	if err != nil {
+		log.Fatalf("Error getting function: %v", err)
+	}
+	configuration := function.Configuration
+
+	// Triggers may sit on the -green twin after a blue/green deploy
+	functionARN, err := triggers.LiveFunctionARN(context.TODO(), client, cfg.Lambda.FunctionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	maintenance, err := maintenanceMode(context.TODO(), client, functionARN, cfg.Deploy.Alias)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Function:       %s\n", aws.ToString(configuration.FunctionName))
+	fmt.Printf("State:          %s (last update: %s)\n", configuration.State, configuration.LastUpdateStatus)
+	fmt.Printf("Last modified:  %s\n", aws.ToString(configuration.LastModified))
+	if function.Code != nil {
+		fmt.Printf("Image:          %s\n", aws.ToString(function.Code.ImageUri))
+	}
+	fmt.Printf("Code SHA256:    %s\n", aws.ToString(configuration.CodeSha256))
+	fmt.Printf("Memory:         %d MB\n", aws.ToInt32(configuration.MemorySize))
+	fmt.Printf("Timeout:        %d s\n", aws.ToInt32(configuration.Timeout))
+	if configuration.EphemeralStorage != nil {
+		fmt.Printf("Storage (/tmp): %d MB\n", aws.ToInt32(configuration.EphemeralStorage.Size))
+	}
+	if function.Concurrency != nil && function.Concurrency.ReservedConcurrentExecutions != nil {
+		fmt.Printf("Reserved conc.: %d\n", *function.Concurrency.ReservedConcurrentExecutions)
+	}
+	fmt.Printf("Maintenance:    %s\n", maintenance)
+
+	list, err := triggers.List(context.TODO(), client, eventsClient, triggers.AliasARN(functionARN, cfg.Deploy.Alias))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Triggers:       %s\n", triggers.State(list))
+	for _, trigger := range list {
+		state := "enabled"
+		if !trigger.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("  %-21s %s  %s  %s\n", trigger.Kind, trigger.ID, trigger.Source, state)
+	}
+
+	if *introspectFlag {
+		// Through the alias invoke calls, which is what callers reach
+		manifest, err := fetchManifest(context.TODO(), client, functionARN, cfg.Deploy.Alias)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printManifest(os.Stdout, manifest)
+	}
+	if console.Show {
+		console.Print(os.Stdout, consoleLinks(cfg, function)...)
+	}
+}
+
+type configurationAPI interface {
+	GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error)
+}
+
+// maintenanceMode reads MAINTENANCE_MODE where traffic sees it: on the live
+// color, through the alias when there is one.
+func maintenanceMode(ctx context.Context, client configurationAPI, functionARN, alias string) (string, error) {
+	input := &lambda.GetFunctionConfigurationInput{FunctionName: aws.String(functionARN)}
+	if alias != "" {
+		input.Qualifier = aws.String(alias)
+	}
+	configuration, err := client.GetFunctionConfiguration(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("error getting function configuration: %v", err)
+	}
+	if configuration.Environment != nil && configuration.Environment.Variables["MAINTENANCE_MODE"] == "on" {
+		return "on", nil
+	}
+	return "off", nil
+}
+
+// consoleLinks are the console pages of the function as status found it:
+// the alias invoke calls, its logs and metrics, the image it runs and the
+// stage of its HTTP API.
+func consoleLinks(cfg *config.Config, function *lambda.GetFunctionOutput) []console.Link {
+	name := cfg.Lambda.FunctionName
+	c := console.New(cfg.Partition(), cfg.AWS.Region)
+	links := []console.Link{c.Function(name, cfg.Deploy.Alias), c.Metrics(name), c.Logs(name), c.Alarms()}
+	// e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com/hello@sha256:...
+	if function.Code != nil {
+		host, rest, _ := strings.Cut(aws.ToString(function.Code.ResolvedImageUri), "/")
+		repository, digest, ok := strings.Cut(rest, "@")
+		if account, _, _ := strings.Cut(host, "."); ok {
+			links = append(links, c.Image(account, repository, digest))
+		}
+	}
+	if cfg.API.Enabled {
+		output, err := hostexec.Output(exec.Command("aws", "apigatewayv2", "get-apis",
+			"--query", fmt.Sprintf("Items[?Name=='%s'] | [0].ApiId", cfg.HTTPAPIName()),
+			"--output", "text",
+			"--profile", cfg.AWS.Profile,
+			"--region", cfg.AWS.Region))
+		if id := strings.TrimSpace(string(output)); err != nil {
+			log.Printf("Could not look up the HTTP API: %v", err)
+		} else if id != "" && id != "None" {
+			links = append(links, c.APIStage(id, "$default"))
+		}
+	}
+	return links
+}
+
+// fetchManifest invokes the function with the reserved introspection payload.
+func fetchManifest(ctx context.Context, client *lambda.Client, functionARN, alias string) (introspect.Manifest, error) {
+	input := &lambda.InvokeInput{FunctionName: aws.String(functionARN), Payload: []byte(introspect.Payload)}
+	if alias != "" {
+		input.Qualifier = aws.String(alias)
+	}
+	result, err := client.Invoke(ctx, input)
+	if err != nil {
+		return introspect.Manifest{}, fmt.Errorf("error invoking function: %v", err)
+	}
+	var manifest introspect.Manifest
+	if result.FunctionError != nil || json.Unmarshal(result.Payload, &manifest) != nil || manifest.Build.GoVersion == "" {
+		return introspect.Manifest{}, fmt.Errorf("the deployed code does not answer introspection: it predates it or is not built from cmd/lambda; it answered %s", result.Payload)
+	}
+	return manifest, nil
+}
+
+func printManifest(w io.Writer, m introspect.Manifest) {
+	build := m.Build.GoVersion
+	for _, detail := range []string{m.Arch, m.Build.Version, m.Build.Revision, m.Build.BuildTime} {
+		if detail != "" && detail != "(devel)" {
+			build += ", " + detail
+		}
+	}
+	fmt.Fprintf(w, "Build:          %s\n", build)
+	if m.Tags != "" {
+		fmt.Fprintf(w, "Build tags:     %s\n", m.Tags)
+	}
+	fmt.Fprintf(w, "Handlers:       %s\n", strings.Join(m.Handlers, ", "))
+	fmt.Fprintln(w, "Routes:")
+	for _, route := range m.Routes {
+		fmt.Fprintf(w, "  %-21s %-12s %s\n", route.Name, route.Handler, describeMatch(route))
+	}
+	if len(m.Jobs) > 0 {
+		fmt.Fprintf(w, "Job types:      %s\n", strings.Join(m.Jobs, ", "))
+	}
+	if len(m.Events) > 0 {
+		names := make([]string, 0, len(m.Events))
+		for name := range m.Events {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		versions := make([]string, len(names))
+		for i, name := range names {
+			versions[i] = fmt.Sprintf("%s v%d", name, m.Events[name])
+		}
+		fmt.Fprintf(w, "Event versions: %s\n", strings.Join(versions, ", "))
+	}
+}
+
+// describeMatch spells out what events a route takes, as routes.yaml would.
+func describeMatch(route router.Route) string {
+	m := route.Match
+	switch {
+	case route.Default:
+		return "anything no other route matches"
+	case m.HTTPPath != "":
+		method := m.HTTPMethod
+		if method == "" {
+			method = "any method"
+		}
+		return fmt.Sprintf("HTTP %s %s", method, m.HTTPPath)
+	case m.SQSAttribute.Name != "":
+		return fmt.Sprintf("SQS messages with %s=%s", m.SQSAttribute.Name, m.SQSAttribute.Value)
+	case m.DetailType != "":
+		return fmt.Sprintf("EventBridge detail-type %q", m.DetailType)
+	case m.EventType != "":
+		return fmt.Sprintf("envelope type %s", m.EventType)
+	}
+	return "nothing"
+}
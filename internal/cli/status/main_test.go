@@ -0,0 +1,36 @@
+package status
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// fakeConfigurations maps function:qualifier to MAINTENANCE_MODE.
+type fakeConfigurations map[string]string
+
+func (f fakeConfigurations) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	key := aws.ToString(params.FunctionName) + ":" + aws.ToString(params.Qualifier)
+	return &lambda.GetFunctionConfigurationOutput{
+		Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{"MAINTENANCE_MODE": f[key]}},
+	}, nil
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	// $LATEST is in maintenance but the alias's published version is not
+	client := fakeConfigurations{"hello-green:": "on", "hello:": "on", "hello:live": ""}
+	for _, test := range []struct {
+		function, alias, want string
+	}{
+		{"hello-green", "", "on"},
+		{"hello", "live", "off"},
+	} {
+		got, err := maintenanceMode(context.Background(), client, test.function, test.alias)
+		if err != nil || got != test.want {
+			t.Errorf("maintenanceMode(%s, %q) = %s, %v; want %s", test.function, test.alias, got, err, test.want)
+		}
+	}
+}
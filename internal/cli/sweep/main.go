@@ -0,0 +1,376 @@
+package sweep
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	taggingtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+
+	"example-lambda-go/internal/config"
+)
+
+// Main lists the resources this project created for functions that no
+// environment or functions entry of the config file deploys any more, such
+// as those of preview environments, and deletes them with -delete after
+// asking for confirmation.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template sweep", flag.ExitOnError)
+	days := flags.Int("days", 7, "Only sweep resources created at least this many days ago")
+	remove := flags.Bool("delete", false, "Delete the resources listed instead of only listing them")
+	yes := flags.Bool("yes", false, "With -delete, delete without asking, e.g. from a scheduled CI job")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template sweep [-days N] [-delete [-yes]]")
+		fmt.Fprintln(os.Stderr, "Lists the functions, rules, repositories and roles in the region tagged as created by")
+		fmt.Fprintln(os.Stderr, "lambda-template for this project (lambda-template:project, see project in config.yaml)")
+		fmt.Fprintln(os.Stderr, "whose function no environment of config.yaml deploys, with the functions' log groups.")
+		fmt.Fprintln(os.Stderr, "-delete deletes them. Resources without the project tag are never listed.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *yes && !*remove {
+		fmt.Fprintln(os.Stderr, "-yes only applies with -delete")
+		os.Exit(2)
+	}
+
+	inventory, err := config.LoadInventory()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	cfg := inventory.Config
+
+	// Load AWS configuration
+	ctx := context.TODO()
+	awsCfg, err := cfg.AWSConfig(ctx)
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	c := clients{
+		tagging: resourcegroupstaggingapi.NewFromConfig(awsCfg),
+		lambda:  lambda.NewFromConfig(awsCfg),
+		events:  eventbridge.NewFromConfig(awsCfg),
+		ecr:     ecr.NewFromConfig(awsCfg),
+		iam:     iam.NewFromConfig(awsCfg),
+		logs:    cloudwatchlogs.NewFromConfig(awsCfg),
+	}
+
+	project := cfg.ProjectID()
+	resources, err := c.find(ctx, project)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cutoff := time.Now().AddDate(0, 0, -*days)
+	orphans := selectOrphans(resources, inventory, cutoff)
+	orphans, err = c.addLogGroups(ctx, orphans)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(orphans) == 0 {
+		fmt.Printf("Nothing to sweep in %s: every resource of project %s created more than %d days ago belongs to a function in %s\n", cfg.AWS.Region, project, *days, config.Path)
+		return
+	}
+
+	fmt.Printf("Resources of project %s in %s whose function is not in %s:\n\n", project, cfg.AWS.Region, config.Path)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tFUNCTION\tCREATED")
+	for _, r := range orphans {
+		created := "-"
+		if !r.created.IsZero() {
+			created = r.created.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.kind, r.name, r.function, created)
+	}
+	w.Flush()
+	fmt.Println()
+
+	if !*remove {
+		fmt.Printf("Run `lambda-template sweep -delete` to delete these %d resources.\n", len(orphans))
+		return
+	}
+	if !*yes {
+		fmt.Printf("Delete these %d resources? (y/n): ", len(orphans))
+		var confirmation string
+		fmt.Scanln(&confirmation)
+		if confirmation != "y" && confirmation != "Y" {
+			fmt.Println("Sweep cancelled.")
+			return
+		}
+	}
+
+	failed := 0
+	for _, r := range orphans {
+		if err := c.delete(ctx, r); err != nil {
+			log.Printf("Error deleting %s %s: %v", strings.ToLower(r.kind), r.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s '%s' deleted successfully.\n", r.kind, r.name)
+	}
+	if failed > 0 {
+		log.Fatalf("%d resource(s) could not be deleted", failed)
+	}
+}
+
+// The kinds of resource sweep deletes, in the order it deletes them: the
+// triggers before the functions, and the roles once nothing uses them.
+const (
+	kindRule       = "Rule"
+	kindFunction   = "Function"
+	kindLogGroup   = "Log group"
+	kindRepository = "Repository"
+	kindRole       = "Role"
+)
+
+var deleteOrder = map[string]int{kindRule: 0, kindFunction: 1, kindLogGroup: 2, kindRepository: 3, kindRole: 4}
+
+type resource struct {
+	kind, name string
+	// function is the function the resource was created for
+	function string
+	// created is zero when the resource has no valid created tag
+	created time.Time
+}
+
+// fromTags reads a resource's tags; ok is false unless this tool created it
+// for project. Other projects in the account tag theirs the same way, so the
+// created-by tag alone is not enough.
+func fromTags(kind, name, project string, tags map[string]string) (r resource, ok bool) {
+	if tags[config.CreatedByTag] != config.CreatedBy || tags[config.ProjectTag] != project {
+		return resource{}, false
+	}
+	r = resource{kind: kind, name: name, function: tags[config.FunctionTag]}
+	if created, err := time.Parse("2006-01-02", tags[config.CreatedTag]); err == nil {
+		r.created = created
+	}
+	return r, true
+}
+
+// selectOrphans returns the resources created before cutoff that nothing in
+// the inventory uses, in the order they are deleted. A resource whose
+// function is deployed stays, and so does a role or repository that another
+// function is configured to share.
+func selectOrphans(resources []resource, inventory *config.Inventory, cutoff time.Time) []resource {
+	var orphans []resource
+	for _, r := range resources {
+		if r.created.IsZero() || !r.created.Before(cutoff) || inventory.Functions[r.function] {
+			continue
+		}
+		switch {
+		case r.kind == kindFunction && inventory.Functions[r.name],
+			r.kind == kindRepository && inventory.Repositories[r.name],
+			r.kind == kindRole && inventory.Roles[r.name]:
+			continue
+		}
+		orphans = append(orphans, r)
+	}
+	sortResources(orphans)
+	return orphans
+}
+
+func sortResources(resources []resource) {
+	sort.SliceStable(resources, func(i, j int) bool {
+		if deleteOrder[resources[i].kind] != deleteOrder[resources[j].kind] {
+			return deleteOrder[resources[i].kind] < deleteOrder[resources[j].kind]
+		}
+		return resources[i].name < resources[j].name
+	})
+}
+
+type clients struct {
+	tagging *resourcegroupstaggingapi.Client
+	lambda  *lambda.Client
+	events  *eventbridge.Client
+	ecr     *ecr.Client
+	iam     *iam.Client
+	logs    *cloudwatchlogs.Client
+}
+
+// find lists the resources this tool created for project in the region,
+// and its roles.
+func (c clients) find(ctx context.Context, project string) ([]resource, error) {
+	var resources []resource
+
+	// IAM is not covered by the tagging API
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(c.tagging, &resourcegroupstaggingapi.GetResourcesInput{
+		TagFilters: []taggingtypes.TagFilter{
+			{Key: aws.String(config.CreatedByTag), Values: []string{config.CreatedBy}},
+			{Key: aws.String(config.ProjectTag), Values: []string{project}},
+		},
+		ResourceTypeFilters: []string{"lambda:function", "ecr:repository", "events:rule"},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing tagged resources: %v", err)
+		}
+		for _, mapping := range page.ResourceTagMappingList {
+			tags := map[string]string{}
+			for _, tag := range mapping.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			kind, name := parseARN(aws.ToString(mapping.ResourceARN))
+			if kind == "" {
+				continue
+			}
+			if r, ok := fromTags(kind, name, project, tags); ok {
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	roles := iam.NewListRolesPaginator(c.iam, &iam.ListRolesInput{})
+	for roles.HasMorePages() {
+		page, err := roles.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing roles: %v", err)
+		}
+		for _, role := range page.Roles {
+			if strings.HasPrefix(aws.ToString(role.Path), "/aws-service-role/") {
+				continue
+			}
+			output, err := c.iam.ListRoleTags(ctx, &iam.ListRoleTagsInput{RoleName: role.RoleName})
+			if err != nil {
+				return nil, fmt.Errorf("error listing the tags of role %s: %v", aws.ToString(role.RoleName), err)
+			}
+			tags := map[string]string{}
+			for _, tag := range output.Tags {
+				tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+			if r, ok := fromTags(kindRole, aws.ToString(role.RoleName), project, tags); ok {
+				resources = append(resources, r)
+			}
+		}
+	}
+	return resources, nil
+}
+
+// parseARN returns the kind and name of a resource the tagging API lists.
+func parseARN(arn string) (kind, name string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return "", ""
+	}
+	switch resource := parts[5]; {
+	case parts[2] == "lambda" && strings.HasPrefix(resource, "function:"):
+		return kindFunction, strings.TrimPrefix(resource, "function:")
+	case parts[2] == "ecr" && strings.HasPrefix(resource, "repository/"):
+		return kindRepository, strings.TrimPrefix(resource, "repository/")
+	case parts[2] == "events" && strings.HasPrefix(resource, "rule/") && strings.Count(resource, "/") == 1:
+		// Rules on other buses are rule/<bus>/<name>; this tool only uses the default bus
+		return kindRule, strings.TrimPrefix(resource, "rule/")
+	}
+	return "", ""
+}
+
+// addLogGroups adds the log groups Lambda created for the orphaned
+// functions. They carry no tags of this tool, so they are only swept along
+// with their function.
+func (c clients) addLogGroups(ctx context.Context, orphans []resource) ([]resource, error) {
+	for _, r := range orphans {
+		if r.kind != kindFunction {
+			continue
+		}
+		name := "/aws/lambda/" + r.name
+		output, err := c.logs.DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(name)})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up log group %s: %v", name, err)
+		}
+		for _, group := range output.LogGroups {
+			if aws.ToString(group.LogGroupName) == name {
+				orphans = append(orphans, resource{kind: kindLogGroup, name: name, function: r.name, created: r.created})
+			}
+		}
+	}
+	sortResources(orphans)
+	return orphans, nil
+}
+
+func (c clients) delete(ctx context.Context, r resource) error {
+	switch r.kind {
+	case kindRule:
+		targets, err := c.events.ListTargetsByRule(ctx, &eventbridge.ListTargetsByRuleInput{Rule: aws.String(r.name)})
+		if err != nil {
+			return err
+		}
+		if len(targets.Targets) > 0 {
+			ids := make([]string, len(targets.Targets))
+			for i, target := range targets.Targets {
+				ids[i] = aws.ToString(target.Id)
+			}
+			if _, err := c.events.RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{Rule: aws.String(r.name), Ids: ids}); err != nil {
+				return err
+			}
+		}
+		_, err = c.events.DeleteRule(ctx, &eventbridge.DeleteRuleInput{Name: aws.String(r.name)})
+		return err
+	case kindFunction:
+		// Event source mappings outlive their function
+		mappings := lambda.NewListEventSourceMappingsPaginator(c.lambda, &lambda.ListEventSourceMappingsInput{FunctionName: aws.String(r.name)})
+		for mappings.HasMorePages() {
+			page, err := mappings.NextPage(ctx)
+			if err != nil {
+				return err
+			}
+			for _, mapping := range page.EventSourceMappings {
+				if _, err := c.lambda.DeleteEventSourceMapping(ctx, &lambda.DeleteEventSourceMappingInput{UUID: mapping.UUID}); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := c.lambda.DeleteFunction(ctx, &lambda.DeleteFunctionInput{FunctionName: aws.String(r.name)})
+		return err
+	case kindLogGroup:
+		_, err := c.logs.DeleteLogGroup(ctx, &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String(r.name)})
+		return err
+	case kindRepository:
+		_, err := c.ecr.DeleteRepository(ctx, &ecr.DeleteRepositoryInput{RepositoryName: aws.String(r.name), Force: true})
+		return err
+	case kindRole:
+		return c.deleteRole(ctx, r.name)
+	}
+	return fmt.Errorf("unknown kind %s", r.kind)
+}
+
+// deleteRole detaches and deletes the role's policies, which IAM requires
+// before the role can go.
+func (c clients) deleteRole(ctx context.Context, name string) error {
+	attached := iam.NewListAttachedRolePoliciesPaginator(c.iam, &iam.ListAttachedRolePoliciesInput{RoleName: aws.String(name)})
+	for attached.HasMorePages() {
+		page, err := attached.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, policy := range page.AttachedPolicies {
+			if _, err := c.iam.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{RoleName: aws.String(name), PolicyArn: policy.PolicyArn}); err != nil {
+				return err
+			}
+		}
+	}
+	inline := iam.NewListRolePoliciesPaginator(c.iam, &iam.ListRolePoliciesInput{RoleName: aws.String(name)})
+	for inline.HasMorePages() {
+		page, err := inline.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, policy := range page.PolicyNames {
+			if _, err := c.iam.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{RoleName: aws.String(name), PolicyName: aws.String(policy)}); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := c.iam.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(name)})
+	return err
+}
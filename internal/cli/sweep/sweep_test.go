@@ -0,0 +1,84 @@
+package sweep
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"example-lambda-go/internal/config"
+)
+
+func TestFromTags(t *testing.T) {
+	tags := map[string]string{
+		config.CreatedByTag: config.CreatedBy,
+		config.ProjectTag:   "shop",
+		config.FunctionTag:  "hello-pr-12",
+		config.CreatedTag:   "2024-07-01",
+	}
+	r, ok := fromTags(kindFunction, "hello-pr-12", "shop", tags)
+	want := resource{kind: kindFunction, name: "hello-pr-12", function: "hello-pr-12", created: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)}
+	if !ok || r != want {
+		t.Errorf("fromTags = %+v, %v; want %+v", r, ok, want)
+	}
+	if _, ok := fromTags(kindFunction, "other", "shop", map[string]string{"team": "payments"}); ok {
+		t.Error("a resource without the created-by tag is not this tool's")
+	}
+	if _, ok := fromTags(kindFunction, "hello-pr-12", "billing", tags); ok {
+		t.Error("a resource of another project was swept")
+	}
+	delete(tags, config.ProjectTag)
+	if _, ok := fromTags(kindFunction, "hello-pr-12", "shop", tags); ok {
+		t.Error("a resource without the project tag was swept")
+	}
+}
+
+func TestParseARN(t *testing.T) {
+	tests := map[string][2]string{
+		"arn:aws:lambda:us-west-2:123456789012:function:hello-pr-12": {kindFunction, "hello-pr-12"},
+		"arn:aws:ecr:us-west-2:123456789012:repository/team/hello":   {kindRepository, "team/hello"},
+		"arn:aws:events:us-west-2:123456789012:rule/hello-export":    {kindRule, "hello-export"},
+		"arn:aws:events:us-west-2:123456789012:rule/orders/on-order": {"", ""},
+		"arn:aws:sqs:us-west-2:123456789012:jobs":                    {"", ""},
+	}
+	for arn, want := range tests {
+		if kind, name := parseARN(arn); kind != want[0] || name != want[1] {
+			t.Errorf("parseARN(%s) = %q, %q; want %q, %q", arn, kind, name, want[0], want[1])
+		}
+	}
+}
+
+func TestSelectOrphans(t *testing.T) {
+	inventory := &config.Inventory{
+		Functions:    map[string]bool{"hello": true, "hello-green": true},
+		Roles:        map[string]bool{"shared-role": true},
+		Repositories: map[string]bool{"hello": true},
+	}
+	old := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	resources := []resource{
+		{kind: kindRole, name: "hello-pr-12-role", function: "hello-pr-12", created: old},
+		{kind: kindFunction, name: "hello-pr-12", function: "hello-pr-12", created: old},
+		{kind: kindRule, name: "hello-pr-12-export", function: "hello-pr-12", created: old},
+		{kind: kindRepository, name: "hello-pr-12", function: "hello-pr-12", created: old},
+		// Deployed functions and what they use stay
+		{kind: kindFunction, name: "hello", function: "hello", created: old},
+		{kind: kindRule, name: "hello-backup", function: "hello", created: old},
+		// Created for a removed function, but shared with a deployed one
+		{kind: kindRole, name: "shared-role", function: "hello-pr-7", created: old},
+		{kind: kindRepository, name: "hello", function: "hello-pr-7", created: old},
+		// Too recent, or of unknown age
+		{kind: kindFunction, name: "hello-pr-13", function: "hello-pr-13", created: recent},
+		{kind: kindFunction, name: "hello-pr-14", function: "hello-pr-14"},
+	}
+
+	got := selectOrphans(resources, inventory, time.Date(2024, 6, 24, 0, 0, 0, 0, time.UTC))
+	want := []resource{
+		{kind: kindRule, name: "hello-pr-12-export", function: "hello-pr-12", created: old},
+		{kind: kindFunction, name: "hello-pr-12", function: "hello-pr-12", created: old},
+		{kind: kindRepository, name: "hello-pr-12", function: "hello-pr-12", created: old},
+		{kind: kindRole, name: "hello-pr-12-role", function: "hello-pr-12", created: old},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectOrphans =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package throttle
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"example-lambda-go/internal/audit"
+	"example-lambda-go/internal/config"
+)
+
+// Main stops all invocations with `throttle on` and undoes it with
+// `throttle off`.
+func Main(args []string) {
+	flags := flag.NewFlagSet("lambda-template throttle", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: lambda-template throttle on|off")
+		fmt.Fprintln(os.Stderr, "on sets reserved concurrency to 0, stopping all invocations; off restores the previous value.")
+		fmt.Fprintln(os.Stderr, "With deploy.strategy bluegreen both colors are throttled.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	mode := flags.Arg(0)
+	if mode != "on" && mode != "off" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Load AWS configuration
+	awsCfg, err := cfg.AWSConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Unable to load SDK config: %v", err)
+	}
+	client := lambda.NewFromConfig(awsCfg)
+
+	// With blue/green both colors serve traffic at some point, so both are
+	// throttled: a swap must not bring an unthrottled function back
+	var details []string
+	for _, name := range cfg.DeployedFunctions() {
+		detail, err := throttle(context.TODO(), client, name, mode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	if len(details) == 0 {
+		return
+	}
+	detail := strings.Join(details, "; ")
+
+	// The action has happened by now, so a failure to log it is only a warning
+	actor := "unknown"
+	if identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{}); err == nil {
+		actor = aws.ToString(identity.Arn)
+	}
+	err = audit.Record(cfg.Audit.File, audit.Entry{
+		Actor:    actor,
+		Action:   "throttle " + mode,
+		Function: cfg.Lambda.FunctionName,
+		Detail:   detail,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// throttle turns throttling of the function on or off and returns what it
+// changed, or "" when the function already was as asked.
+func throttle(ctx context.Context, client *lambda.Client, name, mode string) (string, error) {
+	function, err := client.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting function %s: %v", name, err)
+	}
+	functionARN := aws.ToString(function.Configuration.FunctionArn)
+	previous, throttled := function.Tags[config.ThrottledTag]
+
+	if mode == "on" {
+		if throttled {
+			fmt.Printf("'%s' is already throttled; run `lambda-template throttle off` to restore reserved concurrency %s.\n", name, previous)
+			return "", nil
+		}
+		current := "none"
+		if function.Concurrency != nil && function.Concurrency.ReservedConcurrentExecutions != nil {
+			current = strconv.Itoa(int(*function.Concurrency.ReservedConcurrentExecutions))
+		}
+
+		// Record the old value first; without it `throttle off` could not restore it
+		_, err = client.TagResource(ctx, &lambda.TagResourceInput{
+			Resource: aws.String(functionARN),
+			Tags:     map[string]string{config.ThrottledTag: current},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error recording current reserved concurrency of %s: %v", name, err)
+		}
+		_, err = client.PutFunctionConcurrency(ctx, &lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 aws.String(name),
+			ReservedConcurrentExecutions: aws.Int32(0),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error setting reserved concurrency of %s to 0: %v", name, err)
+		}
+		fmt.Printf("'%s' throttled: reserved concurrency is 0, all invocations are rejected.\n", name)
+		return fmt.Sprintf("%s: reserved concurrency %s -> 0", name, current), nil
+	}
+
+	if !throttled {
+		fmt.Printf("'%s' is not throttled.\n", name)
+		return "", nil
+	}
+	if previous == "none" {
+		_, err = client.DeleteFunctionConcurrency(ctx, &lambda.DeleteFunctionConcurrencyInput{
+			FunctionName: aws.String(name),
+		})
+	} else {
+		var value int
+		if value, err = strconv.Atoi(previous); err != nil {
+			return "", fmt.Errorf("invalid %s tag %q on %s: %v", config.ThrottledTag, previous, name, err)
+		}
+		_, err = client.PutFunctionConcurrency(ctx, &lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 aws.String(name),
+			ReservedConcurrentExecutions: aws.Int32(int32(value)),
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("error restoring reserved concurrency of %s: %v", name, err)
+	}
+	_, err = client.UntagResource(ctx, &lambda.UntagResourceInput{
+		Resource: aws.String(functionARN),
+		TagKeys:  []string{config.ThrottledTag},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error removing the %s tag from %s: %v", config.ThrottledTag, name, err)
+	}
+	fmt.Printf("'%s' unthrottled: reserved concurrency restored to %s.\n", name, previous)
+	return fmt.Sprintf("%s: reserved concurrency 0 -> %s", name, previous), nil
+}
@@ -0,0 +1,101 @@
+// Package concurrency applies lambda.reserved_concurrency to a function, as
+// setup does to the function it creates and deploy on every deploy.
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"example-lambda-go/internal/config"
+)
+
+// LambdaAPI is the subset of the Lambda client Apply uses.
+type LambdaAPI interface {
+	GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+	TagResource(ctx context.Context, params *lambda.TagResourceInput, optFns ...func(*lambda.Options)) (*lambda.TagResourceOutput, error)
+	PutFunctionConcurrency(ctx context.Context, params *lambda.PutFunctionConcurrencyInput, optFns ...func(*lambda.Options)) (*lambda.PutFunctionConcurrencyOutput, error)
+	GetAccountSettings(ctx context.Context, params *lambda.GetAccountSettingsInput, optFns ...func(*lambda.Options)) (*lambda.GetAccountSettingsOutput, error)
+}
+
+// Apply sets the reserved concurrency of functionName to
+// lambda.reserved_concurrency, where 0 pauses the function. A function paused
+// with `throttle on` stays paused: the value is recorded for `throttle off`
+// to restore instead.
+func Apply(ctx context.Context, client LambdaAPI, cfg *config.Config, functionName string, w io.Writer) error {
+	if cfg.Lambda.ReservedConcurrency == nil {
+		return nil
+	}
+	want := *cfg.Lambda.ReservedConcurrency
+	function, err := client.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %v", functionName, err)
+	}
+
+	if previous, throttled := function.Tags[config.ThrottledTag]; throttled {
+		if previous != strconv.Itoa(want) {
+			_, err := client.TagResource(ctx, &lambda.TagResourceInput{
+				Resource: function.Configuration.FunctionArn,
+				Tags:     map[string]string{config.ThrottledTag: strconv.Itoa(want)},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record reserved concurrency for throttle off: %v", err)
+			}
+		}
+		fmt.Fprintf(w, "%s is throttled; `lambda-template throttle off` will set reserved concurrency %d\n", functionName, want)
+		return nil
+	}
+
+	current := -1
+	if function.Concurrency != nil && function.Concurrency.ReservedConcurrentExecutions != nil {
+		current = int(*function.Concurrency.ReservedConcurrentExecutions)
+	}
+	if current == want {
+		return nil
+	}
+	if warning := UnreservedWarning(ctx, client, cfg, functionName, current, want); warning != "" {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+
+	_, err = client.PutFunctionConcurrency(ctx, &lambda.PutFunctionConcurrencyInput{
+		FunctionName:                 aws.String(functionName),
+		ReservedConcurrentExecutions: aws.Int32(int32(want)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set reserved concurrency of %s to %d: %v", functionName, want, err)
+	}
+	if want == 0 {
+		fmt.Fprintf(w, "%s is paused: reserved concurrency 0 rejects every invocation\n", functionName)
+	} else {
+		fmt.Fprintf(w, "Reserved concurrency of %s set to %d\n", functionName, want)
+	}
+	return nil
+}
+
+// UnreservedWarning warns when changing the function's reservation from
+// current (-1 for none) to want leaves the account's unreserved concurrency,
+// which every function without a reservation shares, below
+// deploy.min_unreserved_concurrency. Lambda itself rejects anything below
+// its minimum, so a failed lookup only says the check was skipped.
+func UnreservedWarning(ctx context.Context, client LambdaAPI, cfg *config.Config, functionName string, current, want int) string {
+	settings, err := client.GetAccountSettings(ctx, &lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return fmt.Sprintf("could not check the account's unreserved concurrency: %v", err)
+	}
+	if settings.AccountLimit == nil {
+		return ""
+	}
+	threshold := cfg.MinUnreservedConcurrency()
+	after := int(aws.ToInt32(settings.AccountLimit.UnreservedConcurrentExecutions)) + max(current, 0) - want
+	if after >= threshold {
+		return ""
+	}
+	return fmt.Sprintf("reserving %d for %s leaves %d of the account's %d concurrent executions unreserved, below deploy.min_unreserved_concurrency (%d)",
+		want, functionName, after, settings.AccountLimit.ConcurrentExecutions, threshold)
+}
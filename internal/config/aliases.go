@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Alias is a Lambda alias deploy manages, from aliases. Each alias gets its
+// own published version, so the overrides only apply through the alias.
+type Alias struct {
+	// Environment is merged over the function's environment
+	Environment map[string]string `yaml:"environment"`
+	// ProvisionedConcurrency of 0 removes any provisioned concurrency
+	ProvisionedConcurrency int `yaml:"provisioned_concurrency"`
+}
+
+var aliasName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+var allDigits = regexp.MustCompile(`^[0-9]+$`)
+
+// AliasNames returns the configured aliases in the order deploy updates them.
+func (c *Config) AliasNames() []string {
+	names := make([]string, 0, len(c.Aliases))
+	for name := range c.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AliasVersionDescription is the description of the versions deploy and
+// rollback publish for alias, which rollback goes back to.
+func AliasVersionDescription(alias string) string {
+	return "lambda-template deploy for alias " + alias
+}
+
+// PublishedFor reports whether a version with description was published for
+// alias, rather than for another alias with its own environment.
+func PublishedFor(description, alias string) bool {
+	rest, ok := strings.CutPrefix(description, AliasVersionDescription(alias))
+	return ok && (rest == "" || strings.HasPrefix(rest, ":"))
+}
+
+// addDeployAlias adds deploy.alias to aliases when it has no entry there, so
+// that deploy publishes a version for it like for any other alias.
+func (c *Config) addDeployAlias() {
+	if c.Deploy.Alias == "" {
+		return
+	}
+	if _, ok := c.Aliases[c.Deploy.Alias]; ok {
+		return
+	}
+	if c.Aliases == nil {
+		c.Aliases = map[string]Alias{}
+	}
+	c.Aliases[c.Deploy.Alias] = Alias{}
+}
+
+func (c *Config) validateAliases() error {
+	if c.Deploy.Alias != "" && c.Deploy.Strategy == "bluegreen" {
+		return fmt.Errorf("deploy.alias: not supported with deploy.strategy bluegreen, which moves triggers between functions instead")
+	}
+	if len(c.Aliases) > 0 && c.Deploy.Strategy == "bluegreen" {
+		return fmt.Errorf("aliases: not supported with deploy.strategy bluegreen, which moves triggers between functions instead")
+	}
+	for name, alias := range c.Aliases {
+		// Lambda reserves all-digit names for versions
+		if !aliasName.MatchString(name) || allDigits.MatchString(name) || name == "$LATEST" {
+			return fmt.Errorf("aliases: invalid alias name %q", name)
+		}
+		if alias.ProvisionedConcurrency < 0 {
+			return fmt.Errorf("aliases.%s.provisioned_concurrency: must not be negative", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"example-lambda-go/internal/partition"
+)
+
+// CDN fronts the function with a CloudFront distribution and a WAF web ACL,
+// from the cdn section. The function URL behind it only accepts requests
+// CloudFront signs with origin access control, so it can't be called around
+// the WAF.
+type CDN struct {
+	Enabled bool `yaml:"enabled"`
+	// CachePolicy is a CloudFront managed cache policy, CachingDisabled by
+	// default, or the ID of one of your own
+	CachePolicy string `yaml:"cache_policy"`
+	// PriceClass is PriceClass_100, PriceClass_200 or PriceClass_All (default)
+	PriceClass string `yaml:"price_class"`
+	// WebACLARN attaches an existing web ACL of CLOUDFRONT scope; without it
+	// setup creates <function>-waf with the AWS managed common and known bad
+	// inputs rule groups
+	WebACLARN string `yaml:"web_acl_arn"`
+	// RateLimit adds a rule to the created web ACL that blocks an IP address
+	// after this many requests in five minutes
+	RateLimit int `yaml:"rate_limit"`
+	// Assets serves Path from an S3 bucket through the same distribution,
+	// and everything else from the function; deploy -assets uploads to it
+	Assets struct {
+		Bucket string `yaml:"bucket"`
+		// Path is a path pattern, /static/* by default
+		Path string `yaml:"path"`
+	} `yaml:"assets"`
+}
+
+// IDs of the CloudFront managed cache policies the function's distribution
+// uses by default: nothing from the function is cached, and the assets bucket
+// is cached as long as possible.
+const (
+	CachingDisabled  = "4135ea2d-6df8-44a3-9df3-4b5a84be39ad"
+	CachingOptimized = "658327ea-f89d-4fab-a63d-7e88639e58f6"
+)
+
+// managedCachePolicies are the CloudFront managed cache policies by name.
+var managedCachePolicies = map[string]string{
+	"CachingDisabled":                           CachingDisabled,
+	"CachingOptimized":                          CachingOptimized,
+	"CachingOptimizedForUncompressedObjects":    "b2884449-e4de-46a7-ac36-70bc7f1ddd6d",
+	"UseOriginCacheControlHeaders":              "83da9c7e-98b4-4e11-a168-04f0df8e2c65",
+	"UseOriginCacheControlHeaders-QueryStrings": "4cc15a8a-d715-48a4-82b8-cc0b614638fe",
+}
+
+// AllViewerExceptHostHeader is the managed origin request policy the
+// distribution forwards requests with: a function URL only answers to its own
+// Host header.
+const AllViewerExceptHostHeader = "b689b0a8-53d0-40ab-baf2-68738e2966ac"
+
+// CachePolicyID resolves cdn.cache_policy to a cache policy ID.
+func (c CDN) CachePolicyID() string {
+	if c.CachePolicy == "" {
+		return CachingDisabled
+	}
+	if id, ok := managedCachePolicies[c.CachePolicy]; ok {
+		return id
+	}
+	return c.CachePolicy
+}
+
+// AssetsPath is the path pattern CloudFront serves from the assets bucket.
+func (c CDN) AssetsPath() string {
+	if c.Assets.Path == "" {
+		return "/static/*"
+	}
+	return c.Assets.Path
+}
+
+// AssetsPrefix is where in the bucket the files under AssetsPath are, since
+// CloudFront asks S3 for the whole path: static/ for /static/*.
+func (c CDN) AssetsPrefix() string {
+	return strings.TrimSuffix(strings.TrimPrefix(c.AssetsPath(), "/"), "*")
+}
+
+// CDNName is the name of the origin access control and the web ACL setup
+// creates for the function; the distribution, which has no name, carries it
+// as its comment.
+func (c *Config) CDNName() string {
+	return c.Lambda.FunctionName
+}
+
+// WebACLName is the name of the web ACL setup creates when cdn.web_acl_arn
+// is not set.
+func (c *Config) WebACLName() string {
+	return c.CDNName() + "-waf"
+}
+
+// AssetsAccessName is the name of the origin access control CloudFront
+// reads the assets bucket with.
+func (c *Config) AssetsAccessName() string {
+	return c.CDNName() + "-assets"
+}
+
+func (c *Config) validateCDN() error {
+	cdn := c.CDN
+	if !cdn.Enabled {
+		if cdn.Assets.Bucket != "" {
+			return fmt.Errorf("cdn.assets: the bucket is served through CloudFront; set cdn.enabled as well")
+		}
+		return nil
+	}
+	if path := cdn.AssetsPath(); !strings.HasPrefix(path, "/") || !strings.HasSuffix(path, "/*") || path == "/*" {
+		return fmt.Errorf("cdn.assets.path: want a directory pattern such as /static/*, got %q", path)
+	}
+	if c.Partition() != partition.AWS {
+		return fmt.Errorf("cdn: CloudFront can't sign requests to function URLs in %s", c.AWS.Region)
+	}
+	switch cdn.PriceClass {
+	case "", "PriceClass_100", "PriceClass_200", "PriceClass_All":
+	default:
+		return fmt.Errorf("cdn.price_class: must be PriceClass_100, PriceClass_200 or PriceClass_All, got %q", cdn.PriceClass)
+	}
+	if _, managed := managedCachePolicies[cdn.CachePolicy]; cdn.CachePolicy != "" && !managed && len(cdn.CachePolicy) != 36 {
+		return fmt.Errorf("cdn.cache_policy: %q is neither a managed cache policy nor a policy ID", cdn.CachePolicy)
+	}
+	if cdn.WebACLARN != "" && !strings.Contains(cdn.WebACLARN, ":global/webacl/") && !unresolved(cdn.WebACLARN) {
+		return fmt.Errorf("cdn.web_acl_arn: CloudFront needs a web ACL of CLOUDFRONT scope (arn:aws:wafv2:us-east-1:...:global/webacl/...)")
+	}
+	if cdn.RateLimit != 0 && cdn.RateLimit < 100 {
+		return fmt.Errorf("cdn.rate_limit: WAF rate limits start at 100 requests per five minutes")
+	}
+	if cdn.RateLimit != 0 && cdn.WebACLARN != "" {
+		return fmt.Errorf("cdn.rate_limit applies to the web ACL setup creates; add the rule to %s instead", cdn.WebACLARN)
+	}
+	return nil
+}
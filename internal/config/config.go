@@ -0,0 +1,792 @@
+// Package config loads config.yaml, the one file that describes the function
+// and everything set up around it, for all lambda-template subcommands.
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gopkg.in/yaml.v2"
+
+	"example-lambda-go/internal/credcache"
+	"example-lambda-go/internal/gobuild"
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/partition"
+	"example-lambda-go/internal/pipeline"
+	"example-lambda-go/internal/rules"
+	"example-lambda-go/internal/secretenv"
+	"example-lambda-go/internal/slo"
+)
+
+// DefaultPath is the name of the configuration file, which Find looks for
+// when -config is not given.
+const DefaultPath = "config.yaml"
+
+// Path, Env, Function, Profile and Region are set by the global flags of
+// lambda-template. Env selects an entry of environments and Function an entry
+// of functions; Profile and Region override aws.profile and aws.region when
+// set, including an environment's.
+var (
+	Path     = DefaultPath
+	Env      string
+	Function string
+	Profile  string
+	Region   string
+)
+
+type Config struct {
+	// Project tells this project's resources from those of other projects
+	// in the same account; see ProjectID
+	Project string `yaml:"project"`
+	AWS     struct {
+		Region  string `yaml:"region"`
+		Profile string `yaml:"profile"`
+		// Profiles split the work between two principals: Registry for the
+		// ECR repository, e.g. in a shared account, and Deploy, which
+		// replaces Profile, for everything else
+		Profiles struct {
+			Registry string `yaml:"registry"`
+			Deploy   string `yaml:"deploy"`
+		} `yaml:"profiles"`
+		// FIPS and DualStack switch every AWS endpoint, including the
+		// registry images are pushed to, to its FIPS 140 or IPv6 variant
+		FIPS      bool `yaml:"fips"`
+		DualStack bool `yaml:"dual_stack"`
+		// NoCredentialsCache stops sharing assumed-role and SSO credentials
+		// between commands through the local cache
+		NoCredentialsCache bool `yaml:"no_credentials_cache"`
+	} `yaml:"aws"`
+	Lambda struct {
+		FunctionName string `yaml:"function_name"`
+		RoleName     string `yaml:"role_name"`
+		// Timeout is in seconds, up to 900; MemorySize, in MB, from 128 to
+		// 10240, also sets the CPU share
+		Timeout    int `yaml:"timeout"`
+		MemorySize int `yaml:"memory_size"`
+		// EphemeralStorage is the size of /tmp in MB, from 512 (Lambda's
+		// default) to 10240
+		EphemeralStorage int               `yaml:"ephemeral_storage"`
+		Handler          string            `yaml:"handler"`
+		Tags             map[string]string `yaml:"tags"`
+		// Environment variables of the function; the values other sections
+		// derive, e.g. DYNCONFIG_PARAMETER, can be overridden here
+		Environment map[string]string `yaml:"environment"`
+		// Tracing is Active to sample requests with X-Ray, which also gives
+		// the role the X-Ray write policy, or PassThrough
+		Tracing string `yaml:"tracing"`
+		// DeadLetterARN is the SQS queue or SNS topic that asynchronous
+		// invocations go to once their retries are used up
+		DeadLetterARN string `yaml:"dead_letter_arn"`
+		// Schedule invokes the function on an EventBridge schedule, e.g.
+		// rate(5 minutes) or cron(0 8 * * ? *)
+		Schedule string `yaml:"schedule"`
+		// ReservedConcurrency sets aside that many concurrent executions for
+		// the function and caps it there; 0 pauses it. Unset leaves the
+		// function's reserved concurrency as it is
+		ReservedConcurrency *int `yaml:"reserved_concurrency"`
+	} `yaml:"lambda"`
+	ECR struct {
+		RepositoryName string `yaml:"repository_name"`
+	} `yaml:"ecr"`
+	Export struct {
+		Schedule string `yaml:"schedule"`
+		Source   struct {
+			Type  string `yaml:"type"`
+			Table string `yaml:"table"`
+			URL   string `yaml:"url"`
+		} `yaml:"source"`
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+		Glue   struct {
+			Database string `yaml:"database"`
+			Table    string `yaml:"table"`
+		} `yaml:"glue"`
+	} `yaml:"export"`
+	Events struct {
+		BusName        string `yaml:"bus_name"`
+		Source         string `yaml:"source"`
+		SchemaRegistry string `yaml:"schema_registry"`
+	} `yaml:"events"`
+	Tenant struct {
+		Path           string  `yaml:"path"`
+		Claim          string  `yaml:"claim"`
+		Required       bool    `yaml:"required"`
+		MaxConcurrency int     `yaml:"max_concurrency"`
+		RateLimit      float64 `yaml:"rate_limit"`
+		Burst          int     `yaml:"burst"`
+	} `yaml:"tenant"`
+	DynConfig struct {
+		Parameter string `yaml:"parameter"`
+		TTL       string `yaml:"ttl"`
+	} `yaml:"dynconfig"`
+	Database struct {
+		// Engine is "postgres" (default) or "mysql"
+		Engine    string `yaml:"engine"`
+		ProxyName string `yaml:"proxy_name"`
+		Name      string `yaml:"name"`
+		User      string `yaml:"user"`
+	} `yaml:"database"`
+	VPC struct {
+		SubnetIDs        []string `yaml:"subnet_ids"`
+		SecurityGroupIDs []string `yaml:"security_group_ids"`
+	} `yaml:"vpc"`
+	Cache struct {
+		// Backend is "memory" (default without an address) or "redis"
+		Backend string `yaml:"backend"`
+		Address string `yaml:"address"`
+		TLS     bool   `yaml:"tls"`
+		// SecurityGroupID of the cluster; setup opens it to the function's groups
+		SecurityGroupID string `yaml:"security_group_id"`
+	} `yaml:"cache"`
+	Egress struct {
+		// Allow lists hostnames (*.example.com wildcards) and CIDRs
+		Allow []string `yaml:"allow"`
+	} `yaml:"egress"`
+	Worker struct {
+		QueueName string `yaml:"queue_name"`
+		FIFO      bool   `yaml:"fifo"`
+		BatchSize int    `yaml:"batch_size"`
+		// MaxReceiveCount is how many attempts a job gets before the DLQ
+		MaxReceiveCount int    `yaml:"max_receive_count"`
+		BackoffBase     string `yaml:"backoff_base"`
+		BackoffMax      string `yaml:"backoff_max"`
+		DedupTTL        string `yaml:"dedup_ttl"`
+	} `yaml:"worker"`
+	ErrorReporting struct {
+		DSN         string  `yaml:"dsn"`
+		SampleRate  float64 `yaml:"sample_rate"`
+		Environment string  `yaml:"environment"`
+	} `yaml:"error_reporting"`
+	Secrets struct {
+		// Names may contain {env}, which is replaced by the environment name
+		Parameters     []string `yaml:"parameters"`
+		SecretsManager []string `yaml:"secrets_manager"`
+	} `yaml:"secrets"`
+	Maintenance struct {
+		Response string `yaml:"response"`
+	} `yaml:"maintenance"`
+	Audit struct {
+		File string `yaml:"file"`
+		// History is where deploy records what it deployed, for
+		// `lambda-template history`
+		History string `yaml:"history"`
+	} `yaml:"audit"`
+	Deploy struct {
+		// Strategy is "inplace" (default) or "bluegreen"
+		Strategy      string `yaml:"strategy"`
+		VerifyPayload string `yaml:"verify_payload"`
+		VerifyPath    string `yaml:"verify_path"`
+		// Alias, e.g. live, is pointed at a version published by every
+		// deploy, and invoke and the triggers call it rather than $LATEST
+		Alias string `yaml:"alias"`
+		// Protected environments, e.g. prod, only let invoke call $LATEST
+		// with -latest -i-know when aliases are in use
+		Protected bool `yaml:"protected"`
+		// Summary is a file deploy writes a JSON summary of its outcome to,
+		// for later CI steps; -summary overrides it
+		Summary string `yaml:"summary"`
+		// MinUnreservedConcurrency is the account's unreserved concurrency
+		// below which applying lambda.reserved_concurrency warns; 200 by
+		// default, and Lambda itself never lets it drop below 100
+		MinUnreservedConcurrency int `yaml:"min_unreserved_concurrency"`
+	} `yaml:"deploy"`
+	Shadow struct {
+		// Function receives a sampled copy of invocations; a name, ARN or name:alias
+		Function   string  `yaml:"function"`
+		SampleRate float64 `yaml:"sample_rate"`
+		// Primary goes in the shadow's own config: the name of the function
+		// whose mirrored events it compares
+		Primary string `yaml:"primary"`
+	} `yaml:"shadow"`
+	// Functions replaces lambda.function_name, lambda.handler and
+	// ecr.repository_name in projects with several handlers
+	Functions []FunctionConfig `yaml:"functions"`
+	Report    struct {
+		// Schedule of the digest, e.g. cron(0 8 ? * MON-FRI *); every day at
+		// 08:00 UTC by default
+		Schedule string `yaml:"schedule"`
+		// SlackWebhook references the Secrets Manager secret holding the
+		// webhook URL, e.g. secretsmanager:hello/slack-webhook, so the URL
+		// is not stored in the function's configuration
+		SlackWebhook string `yaml:"slack_webhook"`
+		SNSTopicARN  string `yaml:"sns_topic_arn"`
+	} `yaml:"report"`
+	Drift struct {
+		// Interval between the checks of `drift watch`, 15m by default
+		Interval string `yaml:"interval"`
+		// SlackWebhook and SNSTopicARN receive the alerts; the report
+		// section's are used when neither is set
+		SlackWebhook string `yaml:"slack_webhook"`
+		SNSTopicARN  string `yaml:"sns_topic_arn"`
+	} `yaml:"drift"`
+	Backup struct {
+		// Bucket holds the snapshots of `lambda-template backup`, under
+		// Prefix (snapshots/ by default)
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+		// Schedule of `backup schedule`, every day at 03:00 UTC by default
+		Schedule string `yaml:"schedule"`
+	} `yaml:"backup"`
+	Aliases       map[string]Alias `yaml:"aliases"`
+	DeployWindows []DeployWindow   `yaml:"deploy_windows"`
+	SLO           slo.Config       `yaml:"slo"`
+	Rules         rules.Config     `yaml:"rules"`
+	Telemetry     pipeline.Config  `yaml:"telemetry"`
+	Build         struct {
+		Go gobuild.Config `yaml:"go"`
+	} `yaml:"build"`
+	Docker struct {
+		// Dockerfile is set from the selected functions entry
+		Dockerfile string `yaml:"-"`
+		// Secrets are passed to docker build --secret for Dockerfile steps
+		// that use RUN --mount=type=secret,id=<id>
+		Secrets []hostexec.Secret `yaml:"secrets"`
+		// SSH forwards the SSH agent (docker build --ssh default), e.g. for
+		// private modules fetched over git+ssh
+		SSH bool `yaml:"ssh"`
+	} `yaml:"docker"`
+	TLS struct {
+		// CABundle is a PEM file of certificates to trust besides the
+		// system's, e.g. the root of a TLS-intercepting proxy
+		CABundle string `yaml:"ca_bundle"`
+	} `yaml:"tls"`
+	CDN         CDN         `yaml:"cdn"`
+	DNS         DNS         `yaml:"dns"`
+	API         HTTPAPI     `yaml:"api"`
+	FunctionURL FunctionURL `yaml:"function_url"`
+	Triggers    Triggers    `yaml:"triggers"`
+}
+
+// Load reads the configuration from Path and applies the global flags.
+func Load() (*Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validateFunctions(); err != nil {
+		return nil, err
+	}
+	if err := cfg.selectFunction(Function); err != nil {
+		return nil, err
+	}
+	if cfg.AWS.FIPS && cfg.Partition() == partition.China {
+		return nil, fmt.Errorf("aws.fips: there are no FIPS endpoints in %s", cfg.AWS.Region)
+	}
+	// Written in any case, e.g. active, and kept as Lambda spells it
+	switch strings.ToLower(cfg.Lambda.Tracing) {
+	case "":
+	case "active":
+		cfg.Lambda.Tracing = "Active"
+	case "passthrough":
+		cfg.Lambda.Tracing = "PassThrough"
+	default:
+		return nil, fmt.Errorf("lambda.tracing: must be Active or PassThrough, got %q", cfg.Lambda.Tracing)
+	}
+	if err := cfg.validateSizing(); err != nil {
+		return nil, err
+	}
+	if cfg.Lambda.ReservedConcurrency != nil && *cfg.Lambda.ReservedConcurrency < 0 {
+		return nil, fmt.Errorf("lambda.reserved_concurrency: must not be negative, got %d", *cfg.Lambda.ReservedConcurrency)
+	}
+	if cfg.Deploy.MinUnreservedConcurrency < 0 {
+		return nil, fmt.Errorf("deploy.min_unreserved_concurrency: must not be negative, got %d", cfg.Deploy.MinUnreservedConcurrency)
+	}
+	if err := cfg.validateVPC(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateEnvironment(); err != nil {
+		return nil, err
+	}
+	if cfg.Drift.Interval != "" && !unresolved(cfg.Drift.Interval) {
+		if d, err := time.ParseDuration(cfg.Drift.Interval); err != nil || d < time.Minute {
+			return nil, fmt.Errorf("drift.interval: want a duration of at least 1m, e.g. 15m, got %q", cfg.Drift.Interval)
+		}
+	}
+	if err := cfg.validateSlackWebhook(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Build.Go.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.addDeployAlias()
+	if err := cfg.validateAliases(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Rules.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateCDN(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateDNS(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateHTTPAPI(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateFunctionURL(); err != nil {
+		return nil, err
+	}
+	if err := validateSchedule("lambda.schedule", cfg.Lambda.Schedule); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateTriggers(); err != nil {
+		return nil, err
+	}
+	if cfg.TLS.CABundle != "" && !unresolved(cfg.TLS.CABundle) {
+		if _, err := cfg.certPool(); err != nil {
+			return nil, err
+		}
+	}
+	for _, secret := range cfg.Docker.Secrets {
+		if secret.ID == "" || (secret.Src == "") == (secret.Env == "") {
+			return nil, fmt.Errorf("docker.secrets: each secret needs an id and either src or env")
+		}
+	}
+	return cfg, nil
+}
+
+// validateSizing holds the lambda settings setup and deploy apply to the
+// function to the ranges Lambda takes; unset ones keep Lambda's defaults.
+func (c *Config) validateSizing() error {
+	for _, setting := range []struct {
+		field         string
+		value, lo, hi int
+		unit          string
+	}{
+		{"lambda.timeout", c.Lambda.Timeout, 1, 900, "seconds"},
+		{"lambda.memory_size", c.Lambda.MemorySize, 128, 10240, "MB"},
+		{"lambda.ephemeral_storage", c.Lambda.EphemeralStorage, 512, 10240, "MB"},
+	} {
+		if setting.value != 0 && (setting.value < setting.lo || setting.value > setting.hi) {
+			return fmt.Errorf("%s: must be from %d to %d %s, got %d", setting.field, setting.lo, setting.hi, setting.unit, setting.value)
+		}
+	}
+	return nil
+}
+
+// validateVPC checks the IDs of the vpc section against Lambda's limits of 16
+// subnets and 5 security groups. Security groups alone would be ignored, as
+// only subnets put the function in the VPC.
+func (c *Config) validateVPC() error {
+	subnets, groups := c.VPC.SubnetIDs, c.VPC.SecurityGroupIDs
+	switch {
+	case len(subnets) == 0 && len(groups) == 0:
+		return nil
+	case len(subnets) == 0:
+		return fmt.Errorf("vpc.security_group_ids: set without vpc.subnet_ids, so the function would not join the VPC")
+	case len(subnets) > 16:
+		return fmt.Errorf("vpc.subnet_ids: at most 16, got %d", len(subnets))
+	case len(groups) > 5:
+		return fmt.Errorf("vpc.security_group_ids: at most 5, got %d", len(groups))
+	}
+	for _, id := range subnets {
+		if !strings.HasPrefix(id, "subnet-") && !unresolved(id) {
+			return fmt.Errorf("vpc.subnet_ids: %q is not a subnet ID, e.g. subnet-0abc123", id)
+		}
+	}
+	for _, id := range groups {
+		if !strings.HasPrefix(id, "sg-") && !unresolved(id) {
+			return fmt.Errorf("vpc.security_group_ids: %q is not a security group ID, e.g. sg-0abc123", id)
+		}
+	}
+	return nil
+}
+
+var variableName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+// reservedVariables are set by Lambda, which refuses functions that set them.
+var reservedVariables = []string{
+	"_HANDLER", "_X_AMZN_TRACE_ID", "AWS_DEFAULT_REGION", "AWS_REGION", "AWS_EXECUTION_ENV",
+	"AWS_ACCESS_KEY", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+	"AWS_LAMBDA_RUNTIME_API", "LAMBDA_TASK_ROOT", "LAMBDA_RUNTIME_DIR",
+}
+
+// settableLambdaVariables are the AWS_LAMBDA_ variables Lambda lets
+// functions set.
+var settableLambdaVariables = []string{"AWS_LAMBDA_EXEC_WRAPPER"}
+
+// validateEnvironment checks the names of lambda.environment, and the
+// secret references in it and in the environments of aliases.
+func (c *Config) validateEnvironment() error {
+	for name, value := range c.Lambda.Environment {
+		if !variableName.MatchString(name) {
+			return fmt.Errorf("lambda.environment: invalid variable name %q", name)
+		}
+		if slices.Contains(reservedVariables, name) || (strings.HasPrefix(name, "AWS_LAMBDA_") && !slices.Contains(settableLambdaVariables, name)) {
+			return fmt.Errorf("lambda.environment: %s is reserved by Lambda", name)
+		}
+		if _, _, err := secretenv.Parse(value); err != nil {
+			return fmt.Errorf("lambda.environment.%s: %v", name, err)
+		}
+	}
+	for alias, a := range c.Aliases {
+		for name, value := range a.Environment {
+			if _, _, err := secretenv.Parse(value); err != nil {
+				return fmt.Errorf("aliases.%s.environment.%s: %v", alias, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSlackWebhook requires report.slack_webhook to reference a secret:
+// deploy passes it to the function as DIGEST_SLACK_WEBHOOK, where anyone who
+// can read the function's configuration would see a plain URL.
+func (c *Config) validateSlackWebhook() error {
+	webhook := c.Report.SlackWebhook
+	if webhook == "" || unresolved(webhook) {
+		return nil
+	}
+	_, ok, err := secretenv.Parse(webhook)
+	if err != nil {
+		return fmt.Errorf("report.slack_webhook: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("report.slack_webhook: want a %s<name or ARN>[:<key>] reference to the secret holding the URL, not the URL itself", secretenv.Prefix)
+	}
+	return nil
+}
+
+// SecretIDs returns the names and ARNs of the secrets that lambda.environment,
+// the environments of aliases and report.slack_webhook reference, each once,
+// in order.
+func (c *Config) SecretIDs() []string {
+	environments := []map[string]string{c.Lambda.Environment, {"DIGEST_SLACK_WEBHOOK": c.Report.SlackWebhook}}
+	for _, name := range c.AliasNames() {
+		environments = append(environments, c.Aliases[name].Environment)
+	}
+	var ids []string
+	for _, env := range environments {
+		for _, value := range env {
+			if ref, ok, err := secretenv.Parse(value); ok && err == nil && !slices.Contains(ids, ref.Secret) {
+				ids = append(ids, ref.Secret)
+			}
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// SecretARNs returns the ARNs of the secrets SecretIDs names, in the
+// account. Secrets Manager appends six random characters to the name of a
+// secret to form its ARN, so secrets referenced by name are matched with a
+// wildcard.
+func (c *Config) SecretARNs(accountID string) []string {
+	var arns []string
+	for _, id := range c.SecretIDs() {
+		arns = append(arns, c.SecretARN(id, accountID))
+	}
+	return arns
+}
+
+// SecretARN returns the ARN, or the wildcard that matches it, of the secret
+// with the name or ARN id.
+func (c *Config) SecretARN(id, accountID string) string {
+	if strings.HasPrefix(id, "arn:") {
+		return id
+	}
+	return c.Partition().ARN("secretsmanager", c.AWS.Region, accountID, "secret:"+id+"-??????")
+}
+
+// load reads the configuration from Path and applies the overrides. Later
+// ones win: the file, then its environments entry, then LT_ variables, then
+// the -profile and -region flags.
+func load() (*Config, error) {
+	return loadEnvironment(Env)
+}
+
+// loadEnvironment is load for the environment env rather than -env; the
+// empty string is the top level of the file.
+func loadEnvironment(env string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+
+	if env != "" {
+		if err := applyEnvironment(cfg, data, env); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnvVars(cfg, os.Environ()); err != nil {
+		return nil, err
+	}
+	if cfg.AWS.Profiles.Deploy != "" {
+		cfg.AWS.Profile = cfg.AWS.Profiles.Deploy
+	}
+	if Profile != "" {
+		cfg.AWS.Profile = Profile
+	}
+	if Region != "" {
+		cfg.AWS.Region = Region
+	}
+	if !NoResolve {
+		if err := resolveParameters(context.TODO(), cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// BuildOptions prepares a docker build of the handler with build.go and the
+// docker section, returning a function that removes anything it created.
+func (c *Config) BuildOptions(w io.Writer) (hostexec.BuildOptions, func(), error) {
+	opts, cleanup, err := c.Build.Go.Prepare(w, c.Lambda.Handler)
+	if err != nil {
+		return opts, cleanup, err
+	}
+	for _, secret := range c.Docker.Secrets {
+		if secret.Src != "" {
+			if _, err := os.Stat(secret.Src); err != nil {
+				return opts, cleanup, fmt.Errorf("docker secret %s: %v", secret.ID, err)
+			}
+		} else if _, ok := os.LookupEnv(secret.Env); !ok {
+			return opts, cleanup, fmt.Errorf("docker secret %s: %s is not set", secret.ID, secret.Env)
+		}
+		opts.Secrets = append(opts.Secrets, secret)
+	}
+	opts.Dockerfile = c.Docker.Dockerfile
+	if c.Docker.SSH {
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			return opts, cleanup, fmt.Errorf("docker.ssh forwards the SSH agent, but SSH_AUTH_SOCK is not set; start ssh-agent and add a key")
+		}
+		opts.SSH = true
+	}
+	return opts, cleanup, nil
+}
+
+// DeployedFunctions returns the Lambda functions that serve traffic: the
+// function, and its green twin with deploy.strategy bluegreen.
+func (c *Config) DeployedFunctions() []string {
+	functions := []string{c.Lambda.FunctionName}
+	if c.Deploy.Strategy == "bluegreen" {
+		functions = append(functions, c.Lambda.FunctionName+"-green")
+	}
+	return functions
+}
+
+// MinUnreservedConcurrency is deploy.min_unreserved_concurrency with its
+// default. Lambda keeps 100 unreserved whatever is asked for; warning from
+// twice that leaves room before functions without a reservation throttle.
+func (c *Config) MinUnreservedConcurrency() int {
+	if c.Deploy.MinUnreservedConcurrency == 0 {
+		return 200
+	}
+	return c.Deploy.MinUnreservedConcurrency
+}
+
+// BackupPrefix is backup.prefix with its default, ending in a slash.
+func (c *Config) BackupPrefix() string {
+	if c.Backup.Prefix == "" {
+		return "snapshots/"
+	}
+	return strings.TrimSuffix(c.Backup.Prefix, "/") + "/"
+}
+
+// TriggerTarget is the function that triggers, the function URL and the
+// HTTP API invoke: lambda.function_name, qualified by deploy.alias when it is
+// set so that they run the published version rather than $LATEST, which
+// deploy updates before publishing.
+func (c *Config) TriggerTarget() string {
+	if c.Deploy.Alias == "" {
+		return c.Lambda.FunctionName
+	}
+	return c.Lambda.FunctionName + ":" + c.Deploy.Alias
+}
+
+// TriggerTargetARN is the ARN of TriggerTarget in the account.
+func (c *Config) TriggerTargetARN(accountID string) string {
+	return c.Partition().ARN("lambda", c.AWS.Region, accountID, "function:"+c.TriggerTarget())
+}
+
+// WorkerQueueName returns the worker queue and its dead-letter queue, with
+// the .fifo suffix SQS requires for FIFO queues.
+func (c *Config) WorkerQueueName() (queue, dlq string) {
+	base := strings.TrimSuffix(c.Worker.QueueName, ".fifo")
+	if c.Worker.FIFO {
+		return base + ".fifo", base + "-dlq.fifo"
+	}
+	return base, base + "-dlq"
+}
+
+// Partition returns the AWS partition of aws.region, for building ARNs and
+// endpoints.
+func (c *Config) Partition() partition.Partition {
+	return partition.ForRegion(c.AWS.Region)
+}
+
+// Endpoints returns the endpoint variant aws.fips and aws.dual_stack select.
+func (c *Config) Endpoints() partition.Endpoints {
+	return partition.Endpoints{FIPS: c.AWS.FIPS, DualStack: c.AWS.DualStack}
+}
+
+// DockerfilePath returns the Dockerfile the image is built from.
+func (c *Config) DockerfilePath() string {
+	if c.Docker.Dockerfile != "" {
+		return c.Docker.Dockerfile
+	}
+	return "Dockerfile"
+}
+
+// RulesInForce returns the rules section merged with the organization
+// bundle it names, if any. Warnings about fetching the bundle go to w.
+func (c *Config) RulesInForce(ctx context.Context, w io.Writer) (rules.Config, error) {
+	if c.Rules.Bundle.URL == "" {
+		return c.Rules, nil
+	}
+	var s3Client rules.S3API
+	if strings.HasPrefix(c.Rules.Bundle.URL, "s3://") || strings.HasPrefix(c.Rules.Bundle.SignatureURL, "s3://") {
+		awsCfg, err := c.AWSConfig(ctx)
+		if err != nil {
+			return rules.Config{}, err
+		}
+		s3Client = s3.NewFromConfig(awsCfg)
+	}
+	httpClient, err := c.HTTPClient(30 * time.Second)
+	if err != nil {
+		return rules.Config{}, err
+	}
+	org, err := rules.LoadBundle(ctx, w, c.Rules.Bundle, s3Client, httpClient, ".lambda-template")
+	if err != nil {
+		return rules.Config{}, err
+	}
+	return rules.Merge(org, c.Rules), nil
+}
+
+// CheckRules evaluates the rules in force against the configuration and the
+// Dockerfile, printing the findings to w.
+func (c *Config) CheckRules(ctx context.Context, w io.Writer) error {
+	cfg, err := c.RulesInForce(ctx, w)
+	if err != nil {
+		return err
+	}
+	return rules.Check(w, cfg, c, c.DockerfilePath())
+}
+
+// AWSConfig loads the SDK configuration for the configured region and
+// profile, the same credentials the aws CLI calls use. With aws.fips or
+// aws.dual_stack the SDK clients use those endpoints, and so do the aws CLI
+// calls, which get the matching variables through hostexec.SetAWSEnv; the
+// same goes for tls.ca_bundle. The SDK takes its proxy from HTTPS_PROXY and
+// NO_PROXY. Credentials of a profile that assumes a role or signs in with
+// SSO are cached between commands, see cacheCredentials.
+func (c *Config) AWSConfig(ctx context.Context) (aws.Config, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(c.AWS.Region),
+		awsconfig.WithSharedConfigProfile(c.AWS.Profile),
+	}
+	var cliEnv []string
+	if c.TLS.CABundle != "" {
+		bundle, err := os.ReadFile(c.TLS.CABundle)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("tls.ca_bundle: %v", err)
+		}
+		// The SDK adds the bundle to the system roots; the aws CLI trusts
+		// only the bundle, which behind an intercepting proxy is all it sees
+		opts = append(opts, awsconfig.WithCustomCABundle(bytes.NewReader(bundle)))
+		cliEnv = append(cliEnv, "AWS_CA_BUNDLE="+c.TLS.CABundle)
+	}
+	if c.AWS.FIPS {
+		opts = append(opts, awsconfig.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+		cliEnv = append(cliEnv, "AWS_USE_FIPS_ENDPOINT=true")
+	}
+	if c.AWS.DualStack {
+		opts = append(opts, awsconfig.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+		cliEnv = append(cliEnv, "AWS_USE_DUALSTACK_ENDPOINT=true")
+	}
+	hostexec.SetAWSEnv(cliEnv)
+	opts = append(opts, awsconfig.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+		o.TokenProvider = stscreds.StdinTokenProvider
+	}))
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, err
+	}
+	if !c.AWS.NoCredentialsCache {
+		c.cacheCredentials(ctx, &cfg)
+	}
+	return cfg, nil
+}
+
+// RegistryAWSConfig is AWSConfig for the ECR repository's clients: with
+// aws.profiles.registry set, its credentials, otherwise the same as
+// AWSConfig's.
+func (c *Config) RegistryAWSConfig(ctx context.Context) (aws.Config, error) {
+	if !c.SeparateRegistry() {
+		return c.AWSConfig(ctx)
+	}
+	registry := *c
+	registry.AWS.Profile = c.AWS.Profiles.Registry
+	return registry.AWSConfig(ctx)
+}
+
+// SeparateRegistry reports whether the repository is reached with a profile
+// of its own, so it may be in another account than the function.
+func (c *Config) SeparateRegistry() bool {
+	return c.AWS.Profiles.Registry != "" && c.AWS.Profiles.Registry != c.AWS.Profile
+}
+
+// cacheCredentials puts the credentials cache in front of cfg's credentials
+// when they come from a profile that assumes a role or signs in with SSO, so
+// that one MFA code or SSO login serves every command until the session
+// expires. The aws CLI calls made with the profile get the same
+// credentials. Keys in the environment take precedence over the profile and
+// are left alone.
+func (c *Config) cacheCredentials(ctx context.Context, cfg *aws.Config) {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || cfg.Credentials == nil {
+		return
+	}
+	profile := c.AWS.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	shared, err := awsconfig.LoadSharedConfigProfile(ctx, profile)
+	if err != nil {
+		return
+	}
+	session := shared.RoleARN
+	if session == "" {
+		session = shared.SSOAccountID + "/" + shared.SSORoleName
+	}
+	if session == "/" {
+		return
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+	cfg.Credentials = aws.NewCredentialsCache(&credcache.Provider{
+		Base:    cfg.Credentials,
+		Path:    filepath.Join(cacheDir, "lambda-template", "credentials", credcache.FileName(profile, session)),
+		KeyPath: filepath.Join(configDir, "lambda-template", "credentials.key"),
+	}, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = credcache.RefreshWindow
+	})
+	hostexec.UseCredentials(c.AWS.Profile, cfg.Credentials)
+}
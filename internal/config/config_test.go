@@ -0,0 +1,1059 @@
+package config
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := Path
+	Path = path
+	t.Cleanup(func() { Path, Env, Function, Profile, Region = previous, "", "", "", "" })
+}
+
+func TestLoadAppliesGlobalFlags(t *testing.T) {
+	writeConfig(t, "aws:\n  region: us-west-2\n  profile: personal\nlambda:\n  function_name: hello\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Region != "us-west-2" || cfg.AWS.Profile != "personal" || cfg.Lambda.FunctionName != "hello" {
+		t.Errorf("Load() = %+v", cfg)
+	}
+
+	Profile, Region = "ci", "eu-west-1"
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Region != "eu-west-1" || cfg.AWS.Profile != "ci" {
+		t.Errorf("region, profile = %s, %s; want the flag values", cfg.AWS.Region, cfg.AWS.Profile)
+	}
+}
+
+func TestLoadAppliesProfiles(t *testing.T) {
+	writeConfig(t, "aws:\n  region: us-west-2\n  profile: personal\n  profiles:\n    registry: shared-ecr\n    deploy: workload\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Profile != "workload" || !cfg.SeparateRegistry() {
+		t.Errorf("profile = %s, separate registry %v; want workload, true", cfg.AWS.Profile, cfg.SeparateRegistry())
+	}
+
+	Profile = "ci"
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Profile != "ci" || cfg.AWS.Profiles.Registry != "shared-ecr" {
+		t.Errorf("profile, registry = %s, %s; want the flag to replace the deploy profile only", cfg.AWS.Profile, cfg.AWS.Profiles.Registry)
+	}
+
+	Profile = ""
+	writeConfig(t, "aws:\n  profile: personal\n  profiles:\n    registry: personal\n")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SeparateRegistry() {
+		t.Error("SeparateRegistry() = true for a registry profile that is aws.profile")
+	}
+}
+
+func TestLoadAppliesEnvVars(t *testing.T) {
+	writeConfig(t, "aws:\n  region: us-west-2\nlambda:\n  function_name: hello\n  memory_size: 256\n")
+	t.Setenv("LT_AWS_REGION", "eu-west-1")
+	t.Setenv("LT_LAMBDA_MEMORY_SIZE", "1024")
+	t.Setenv("LT_LAMBDA_TAGS", "{team: payments}")
+	t.Setenv("LT_VPC_SUBNET_IDS", "subnet-a, subnet-b")
+	t.Setenv("LT_SLO_LATENCY_THRESHOLD", "500ms")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Region != "eu-west-1" || cfg.Lambda.MemorySize != 1024 || cfg.Lambda.Tags["team"] != "payments" ||
+		strings.Join(cfg.VPC.SubnetIDs, " ") != "subnet-a subnet-b" || cfg.SLO.Latency.Threshold != "500ms" {
+		t.Errorf("Load() = %+v", cfg)
+	}
+
+	Region = "ap-south-1"
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AWS.Region != "ap-south-1" {
+		t.Errorf("region = %s; want the flag to win over LT_AWS_REGION", cfg.AWS.Region)
+	}
+
+	t.Setenv("LT_LAMBDA_MEMORY_SIZE", "lots")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LT_LAMBDA_MEMORY_SIZE") {
+		t.Errorf("Load() with a malformed number = %v", err)
+	}
+	t.Setenv("LT_LAMBDA_MEMORY_SIZE", "1024")
+	t.Setenv("LT_AWS_REGON", "eu-west-1")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LT_AWS_REGON") {
+		t.Errorf("Load() with a misspelt variable = %v", err)
+	}
+}
+
+func TestLoadAppliesEnvironment(t *testing.T) {
+	writeConfig(t, `aws:
+  region: us-west-2
+  profile: dev
+lambda:
+  function_name: hello-dev
+  timeout: 30
+ecr:
+  repository_name: hello-dev
+egress:
+  allow: [api.example.com, 10.0.0.0/8]
+environments:
+  prod:
+    aws:
+      profile: prod
+    lambda:
+      function_name: hello-prod
+    ecr:
+      repository_name: hello-prod
+    egress:
+      allow: [api.example.com]
+`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.FunctionName != "hello-dev" || cfg.AWS.Profile != "dev" {
+		t.Errorf("without -env: function, profile = %s, %s; want the top-level values", cfg.Lambda.FunctionName, cfg.AWS.Profile)
+	}
+
+	Env, Region = "prod", "eu-west-1"
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.FunctionName != "hello-prod" || cfg.ECR.RepositoryName != "hello-prod" || cfg.AWS.Profile != "prod" {
+		t.Errorf("function, repository, profile = %s, %s, %s; want the prod values", cfg.Lambda.FunctionName, cfg.ECR.RepositoryName, cfg.AWS.Profile)
+	}
+	if cfg.Lambda.Timeout != 30 {
+		t.Errorf("timeout = %d, want the top-level value the environment leaves out", cfg.Lambda.Timeout)
+	}
+	if cfg.AWS.Region != "eu-west-1" {
+		t.Errorf("region = %s, want the -region flag to win", cfg.AWS.Region)
+	}
+	if strings.Join(cfg.Egress.Allow, ",") != "api.example.com" {
+		t.Errorf("egress.allow = %q, want the environment's list to replace the top-level one", cfg.Egress.Allow)
+	}
+
+	Env = "staging"
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "have prod") {
+		t.Errorf("Load() error = %v, want the unknown environment listed against prod", err)
+	}
+}
+
+func TestLoadValidatesBuild(t *testing.T) {
+	writeConfig(t, "build:\n  go:\n    goarch: 386\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "goarch") {
+		t.Errorf("Load() error = %v, want the goarch error", err)
+	}
+}
+
+func TestLoadValidatesSizing(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"lambda:\n  timeout: 901\n", "lambda.timeout"},
+		{"lambda:\n  memory_size: 64\n", "lambda.memory_size"},
+		{"lambda:\n  ephemeral_storage: 256\n", "lambda.ephemeral_storage"},
+		{"functions:\n  - name: export\n    ephemeral_storage: 20480\n", "lambda.ephemeral_storage"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "lambda:\n  ephemeral_storage: 1024\nfunctions:\n  - name: export\n    ephemeral_storage: 10240\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.EphemeralStorage != 10240 {
+		t.Errorf("EphemeralStorage = %d, want the functions entry's 10240", cfg.Lambda.EphemeralStorage)
+	}
+}
+
+func TestLoadNormalizesTracing(t *testing.T) {
+	writeConfig(t, "lambda:\n  tracing: active\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.Tracing != "Active" {
+		t.Errorf("Tracing = %q, want Active", cfg.Lambda.Tracing)
+	}
+
+	writeConfig(t, "lambda:\n  tracing: on\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "lambda.tracing") {
+		t.Errorf("Load() with tracing: on = %v", err)
+	}
+}
+
+func TestLoadReservedConcurrency(t *testing.T) {
+	writeConfig(t, "lambda:\n  reserved_concurrency: 0\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.ReservedConcurrency == nil || *cfg.Lambda.ReservedConcurrency != 0 {
+		t.Errorf("ReservedConcurrency = %v, want 0 kept apart from unset", cfg.Lambda.ReservedConcurrency)
+	}
+
+	writeConfig(t, "lambda:\n  reserved_concurrency: -1\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "lambda.reserved_concurrency") {
+		t.Errorf("Load() with reserved_concurrency: -1 = %v", err)
+	}
+}
+
+func TestLoadValidatesVPC(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"vpc:\n  security_group_ids: [sg-1]\n", "vpc.security_group_ids: set without vpc.subnet_ids"},
+		{"vpc:\n  subnet_ids: [vpc-1]\n  security_group_ids: [sg-1]\n", `"vpc-1" is not a subnet ID`},
+		{"vpc:\n  subnet_ids: [subnet-1]\n  security_group_ids: [sg-1, sg-2, sg-3, sg-4, sg-5, sg-6]\n", "at most 5"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "vpc:\n  subnet_ids: [subnet-1, subnet-2]\n  security_group_ids: [sg-1]\n")
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadValidatesEnvironment(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"lambda:\n  environment:\n    1ST: x\n", "invalid variable name"},
+		{"lambda:\n  environment:\n    AWS_REGION: us-east-1\n", "AWS_REGION is reserved"},
+		{"lambda:\n  environment:\n    AWS_LAMBDA_LOG_LEVEL: debug\n", "reserved"},
+		{"lambda:\n  environment:\n    DB_PASSWORD: \"secretsmanager:hello/db:\"\n", "lambda.environment.DB_PASSWORD"},
+		{"aliases:\n  canary:\n    environment:\n      API_KEY: \"secretsmanager:\"\n", "aliases.canary.environment.API_KEY"},
+		{"report:\n  slack_webhook: https://hooks.slack.com/services/T000/B000/XXXX\n", "report.slack_webhook: want a secretsmanager:"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "lambda:\n  environment:\n    AWS_LAMBDA_EXEC_WRAPPER: /opt/wrapper\n")
+	if _, err := Load(); err != nil {
+		t.Errorf("Load() with AWS_LAMBDA_EXEC_WRAPPER: %v", err)
+	}
+
+	writeConfig(t, "lambda:\n  environment:\n    DB_USER: secretsmanager:hello/db:username\n    DB_PASSWORD: secretsmanager:hello/db:password\n    LOG_LEVEL: info\n"+
+		"aliases:\n  canary:\n    environment:\n      API_KEY: secretsmanager:hello/canary-key\n"+
+		"report:\n  slack_webhook: secretsmanager:hello/slack-webhook\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := cfg.SecretIDs(); strings.Join(ids, ",") != "hello/canary-key,hello/db,hello/slack-webhook" {
+		t.Errorf("SecretIDs() = %q", ids)
+	}
+	cfg.AWS.Region = "us-east-1"
+	if arns := cfg.SecretARNs("123"); len(arns) != 3 || arns[1] != "arn:aws:secretsmanager:us-east-1:123:secret:hello/db-??????" {
+		t.Errorf("SecretARNs() = %q", arns)
+	}
+}
+
+func TestPublishedFor(t *testing.T) {
+	for description, want := range map[string]bool{
+		"lambda-template deploy for alias live":                            true,
+		"lambda-template deploy for alias live: rollback to repo@sha256:a": true,
+		"lambda-template deploy for alias live2":                           false,
+		"lambda-template deploy for alias staging":                         false,
+		"": false,
+	} {
+		if got := PublishedFor(description, "live"); got != want {
+			t.Errorf("PublishedFor(%q, live) = %v, want %v", description, got, want)
+		}
+	}
+}
+
+func TestLoadValidatesAliases(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"aliases:\n  \"42\":\n    provisioned_concurrency: 1\n", "invalid alias name"},
+		{"aliases:\n  canary:\n    provisioned_concurrency: -1\n", "must not be negative"},
+		{"deploy:\n  strategy: bluegreen\naliases:\n  live: {}\n", "bluegreen"},
+		{"deploy:\n  strategy: bluegreen\n  alias: live\n", "deploy.alias"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "aliases:\n  live:\n    provisioned_concurrency: 5\n  canary:\n    environment:\n      DB_HOST: staging\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cfg.AliasNames(); strings.Join(names, ",") != "canary,live" {
+		t.Errorf("AliasNames() = %q", names)
+	}
+	if cfg.Aliases["canary"].Environment["DB_HOST"] != "staging" || cfg.Aliases["live"].ProvisionedConcurrency != 5 {
+		t.Errorf("Aliases = %+v", cfg.Aliases)
+	}
+
+	// deploy.alias is published without an entry of its own, and keeps one it has
+	writeConfig(t, "deploy:\n  alias: prod\naliases:\n  live:\n    provisioned_concurrency: 5\n")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if names := cfg.AliasNames(); strings.Join(names, ",") != "live,prod" {
+		t.Errorf("AliasNames() with deploy.alias = %q", names)
+	}
+	writeConfig(t, "deploy:\n  alias: live\naliases:\n  live:\n    provisioned_concurrency: 5\n")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Aliases["live"].ProvisionedConcurrency != 5 {
+		t.Errorf("deploy.alias replaced the live entry: %+v", cfg.Aliases)
+	}
+}
+
+func TestLoadSelectsFunction(t *testing.T) {
+	writeConfig(t, `lambda:
+  function_name: unused
+  role_name: hello-role
+  timeout: 30
+  handler: lambda
+ecr:
+  repository_name: unused
+functions:
+  - name: hello-api
+  - name: hello-worker
+    handler: worker
+    dockerfile: worker.Dockerfile
+    repository_name: workers
+    memory_size: 1024
+`)
+	names, err := FunctionNames()
+	if err != nil || strings.Join(names, ",") != "hello-api,hello-worker" {
+		t.Fatalf("FunctionNames() = %q, %v", names, err)
+	}
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "-function") {
+		t.Errorf("Load() without -function error = %v, want a request to choose one", err)
+	}
+
+	Function = "hello-api"
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.FunctionName != "hello-api" || cfg.Lambda.Handler != "lambda" || cfg.ECR.RepositoryName != "hello-api" || cfg.Docker.Dockerfile != "" {
+		t.Errorf("hello-api: lambda = %+v, ecr = %+v, dockerfile %q", cfg.Lambda, cfg.ECR, cfg.Docker.Dockerfile)
+	}
+
+	Function = "hello-worker"
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.Handler != "worker" || cfg.Lambda.MemorySize != 1024 || cfg.Lambda.Timeout != 30 || cfg.Lambda.RoleName != "hello-role" ||
+		cfg.ECR.RepositoryName != "workers" || cfg.Docker.Dockerfile != "worker.Dockerfile" {
+		t.Errorf("hello-worker: lambda = %+v, ecr = %+v, dockerfile %q", cfg.Lambda, cfg.ECR, cfg.Docker.Dockerfile)
+	}
+
+	Function = "hello-cron"
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "hello-api, hello-worker") {
+		t.Errorf("Load() with an unknown function error = %v", err)
+	}
+}
+
+func TestLoadWithoutFunctions(t *testing.T) {
+	writeConfig(t, "lambda:\n  function_name: hello\n")
+	if names, err := FunctionNames(); err != nil || len(names) != 0 {
+		t.Errorf("FunctionNames() = %q, %v; want none", names, err)
+	}
+	Function = "hello"
+	if _, err := Load(); err == nil {
+		t.Error("Load() accepted -function without a functions list")
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	writeConfig(t, `lambda:
+  function_name: hello
+  memory_size: 4096
+rules:
+  memory: {max: 1024}
+  custom:
+    - name: team-tag
+      field: lambda.tags.team
+      required: true
+`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerfile := filepath.Join(t.TempDir(), "Dockerfile")
+	os.WriteFile(dockerfile, []byte("FROM golang:1.22.3\n"), 0o644)
+	cfg.Docker.Dockerfile = dockerfile
+
+	var out strings.Builder
+	err = cfg.CheckRules(context.Background(), &out)
+	if err == nil || !strings.Contains(out.String(), "DENY  memory-band") || !strings.Contains(out.String(), "WARN  team-tag: lambda.tags.team is not set") {
+		t.Errorf("CheckRules() = %v, output:\n%s", err, out.String())
+	}
+}
+
+func TestDeployWindowContains(t *testing.T) {
+	// Fridays 22:00 to Saturday 05:00
+	window := DeployWindow{Days: []string{"fri"}, Start: "22:00", End: "05:00"}
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2024-07-05T23:00:00Z", true},  // Friday night
+		{"2024-07-06T04:59:00Z", true},  // Saturday morning, opened on Friday
+		{"2024-07-06T05:00:00Z", false}, // closed
+		{"2024-07-06T23:00:00Z", false}, // Saturday night
+	}
+	for _, tt := range tests {
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		got, err := window.Contains(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestBuildOptionsDockerSection(t *testing.T) {
+	writeConfig(t, "docker:\n  secrets:\n    - id: api_token\n      env: TEST_API_TOKEN\n  ssh: true\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_API_TOKEN", "token")
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, cleanup, err := cfg.BuildOptions(io.Discard)
+	cleanup()
+	if err == nil || !strings.Contains(err.Error(), "SSH_AUTH_SOCK") {
+		t.Errorf("BuildOptions() error = %v, want the missing agent reported", err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/agent.sock")
+	opts, cleanup, err := cfg.BuildOptions(io.Discard)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.SSH || len(opts.Secrets) != 1 || opts.Secrets[0].String() != "id=api_token,env=TEST_API_TOKEN" {
+		t.Errorf("BuildOptions() = %+v", opts)
+	}
+}
+
+func TestLoadRejectsIncompleteSecret(t *testing.T) {
+	writeConfig(t, "docker:\n  secrets:\n    - id: api_token\n")
+	if _, err := Load(); err == nil {
+		t.Error("Load() accepted a secret without src or env")
+	}
+}
+
+func TestLoadValidatesCDN(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"aws:\n  region: us-gov-west-1\ncdn:\n  enabled: true\n", "us-gov-west-1"},
+		{"cdn:\n  enabled: true\n  price_class: PriceClass_50\n", "cdn.price_class"},
+		{"cdn:\n  enabled: true\n  cache_policy: NoCaching\n", "cdn.cache_policy"},
+		{"cdn:\n  enabled: true\n  web_acl_arn: arn:aws:wafv2:eu-west-1:123456789012:regional/webacl/api/1\n", "CLOUDFRONT scope"},
+		{"cdn:\n  enabled: true\n  rate_limit: 50\n", "cdn.rate_limit"},
+		{"cdn:\n  assets:\n    bucket: hello-assets\n", "cdn.enabled"},
+		{"cdn:\n  enabled: true\n  assets:\n    bucket: hello-assets\n    path: /*\n", "cdn.assets.path"},
+		{"cdn:\n  enabled: true\n  assets:\n    bucket: hello-assets\n    path: static/*\n", "cdn.assets.path"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "aws:\n  region: eu-west-1\ncdn:\n  enabled: true\n  cache_policy: CachingOptimized\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := cfg.CDN.CachePolicyID(); id != "658327ea-f89d-4fab-a63d-7e88639e58f6" {
+		t.Errorf("CachePolicyID() = %s, want the CachingOptimized policy", id)
+	}
+	if id := (CDN{}).CachePolicyID(); id != "4135ea2d-6df8-44a3-9df3-4b5a84be39ad" {
+		t.Errorf("CachePolicyID() = %s, want CachingDisabled by default", id)
+	}
+	if path, prefix := cfg.CDN.AssetsPath(), cfg.CDN.AssetsPrefix(); path != "/static/*" || prefix != "static/" {
+		t.Errorf("AssetsPath(), AssetsPrefix() = %s, %s, want /static/*, static/ by default", path, prefix)
+	}
+}
+
+func TestLoadValidatesDNS(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"dns:\n  name: api.example.com\n", "zone_id is required"},
+		{"dns:\n  zone_id: Z1\n  name: api.example.com\n", "name and target"},
+		{"dns:\n  zone_id: Z1\n  name: api.example.com\n  target: https://d-1.example.com/\n  failover: PRIMARY\n", "dns.target"},
+		{"dns:\n  zone_id: Z1\n  name: api.example.com\n  target: d-1.example.com\n", "PRIMARY or SECONDARY"},
+		{"dns:\n  zone_id: Z1\n  name: api.example.com\n  target: d-1.example.com\n  routing: latency\n  failover: PRIMARY\n", "only applies"},
+		{"dns:\n  zone_id: Z1\n  name: api.example.com\n  target: d-1.example.com\n  routing: weighted\n", "dns.routing"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	// The region's role usually comes from its environment
+	writeConfig(t, "lambda:\n  function_name: hello\ndns:\n  zone_id: Z1\n  name: api.example.com\n  target: d-1.example.com\nenvironments:\n  west:\n    aws:\n      region: eu-west-1\n    dns:\n      target: d-2.example.com\n      failover: SECONDARY\n")
+	Env = "west"
+	t.Cleanup(func() { Env = "" })
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref := cfg.HealthCheckReference(); ref != "hello-eu-west-1" {
+		t.Errorf("HealthCheckReference() = %s, want hello-eu-west-1", ref)
+	}
+	if path := cfg.DNS.HealthCheckResourcePath(); path != "/readyz" {
+		t.Errorf("HealthCheckResourcePath() = %s, want /readyz by default", path)
+	}
+}
+
+func TestLoadValidatesFunctionURL(t *testing.T) {
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"function_url:\n  auth_type: NONE\n", "function_url.enabled"},
+		{"function_url:\n  enabled: true\n  auth_type: PUBLIC\n", "AWS_IAM or NONE"},
+		{"function_url:\n  enabled: true\n  auth_type: NONE\ncdn:\n  enabled: true\n", "cdn needs AWS_IAM"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+
+	writeConfig(t, "function_url:\n  enabled: true\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth := cfg.FunctionURL.URLAuthType(); auth != "AWS_IAM" {
+		t.Errorf("URLAuthType() = %s, want AWS_IAM by default", auth)
+	}
+}
+
+func TestLoadInventory(t *testing.T) {
+	writeConfig(t, `lambda:
+  function_name: hello
+  role_name: hello-role
+ecr:
+  repository_name: hello
+environments:
+  staging:
+    lambda:
+      function_name: hello-staging
+    deploy:
+      strategy: bluegreen
+  tools:
+    lambda:
+      role_name: tools-role
+    functions:
+      - name: api
+      - name: worker
+        repository_name: shared
+`)
+	inventory, err := LoadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, function := range []string{"hello", "hello-staging", "hello-staging-green", "api", "worker"} {
+		if !inventory.Functions[function] {
+			t.Errorf("function %s missing from %v", function, inventory.Functions)
+		}
+	}
+	if len(inventory.Functions) != 5 {
+		t.Errorf("got functions %v", inventory.Functions)
+	}
+	if !inventory.Roles["hello-role"] || !inventory.Roles["tools-role"] || !inventory.Repositories["shared"] || !inventory.Repositories["api"] {
+		t.Errorf("got roles %v, repositories %v", inventory.Roles, inventory.Repositories)
+	}
+	if inventory.Config.Lambda.FunctionName != "hello" {
+		t.Errorf("Config should be the top level without -env, got %s", inventory.Config.Lambda.FunctionName)
+	}
+}
+
+func TestLoadSchedule(t *testing.T) {
+	writeConfig(t, "lambda:\n  schedule: every 5 minutes\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "lambda.schedule") {
+		t.Errorf("Load() error = %v, want one about lambda.schedule", err)
+	}
+
+	// A functions entry's schedule replaces the top-level one
+	t.Cleanup(func() { Function = "" })
+	writeConfig(t, "lambda:\n  schedule: rate(1 hour)\nfunctions:\n  - name: api\n  - name: sync\n    schedule: cron(0 8 * * ? *)\n")
+	for function, want := range map[string]string{"api": "rate(1 hour)", "sync": "cron(0 8 * * ? *)"} {
+		Function = function
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Lambda.Schedule != want || cfg.ScheduleRuleName() != function+"-schedule" {
+			t.Errorf("%s: schedule %q, rule %s", function, cfg.Lambda.Schedule, cfg.ScheduleRuleName())
+		}
+	}
+}
+
+func TestLoadSQSTrigger(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"triggers:\n  sqs:\n    batch_size: 5\n":                            "set queue",
+		"triggers:\n  sqs:\n    queue: orders\n    batch_size: 100\n":       "needs a batch_window",
+		"triggers:\n  sqs:\n    queue: orders\n    batch_window: 10m\n":     "batch_window",
+		"triggers:\n  sqs:\n    queue: orders.fifo\n    batch_size: 100\n":  "FIFO",
+		"triggers:\n  sqs:\n    queue: orders.fifo\n    batch_window: 5s\n": "FIFO",
+		"triggers:\n  sqs:\n    queue: orders\n    batch_size: 20000\n":     "between 1 and 10000",
+	} {
+		writeConfig(t, yaml)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%sLoad() error = %v, want one mentioning %q", yaml, err, want)
+		}
+	}
+
+	writeConfig(t, "aws:\n  region: eu-west-1\ntriggers:\n  sqs:\n    queue: orders\n    batch_size: 100\n    batch_window: 1m\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SQSQueueARN("123"); got != "arn:aws:sqs:eu-west-1:123:orders" {
+		t.Errorf("SQSQueueARN = %s", got)
+	}
+	if got := cfg.Triggers.SQS.BatchWindowSeconds(); got != 60 {
+		t.Errorf("BatchWindowSeconds = %d, want 60", got)
+	}
+}
+
+func TestLoadSNSTrigger(t *testing.T) {
+	writeConfig(t, "deploy:\n  strategy: bluegreen\ntriggers:\n  sns:\n    topic: order-events\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "bluegreen") {
+		t.Errorf("Load() error = %v, want triggers.sns rejected with blue/green", err)
+	}
+
+	writeConfig(t, "triggers:\n  sns:\n    topic: arn:aws:sns:eu-west-1:456:order-events\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SNSTopicARN("123"); got != "arn:aws:sns:eu-west-1:456:order-events" {
+		t.Errorf("SNSTopicARN = %s", got)
+	}
+	if got := cfg.SNSTopicName(); got != "order-events" {
+		t.Errorf("SNSTopicName = %s", got)
+	}
+	if got := cfg.SNSTopicRegion(); got != "eu-west-1" {
+		t.Errorf("SNSTopicRegion = %s, want the topic's region", got)
+	}
+}
+
+func TestLoadS3Trigger(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"triggers:\n  s3:\n    prefix: uploads/\n":                                 "set bucket",
+		"triggers:\n  s3:\n    bucket: uploads\n    events: [ObjectCreated:Put]\n": "S3 event types",
+		"deploy:\n  strategy: bluegreen\ntriggers:\n  s3:\n    bucket: uploads\n":  "bluegreen",
+	} {
+		writeConfig(t, yaml)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%sLoad() error = %v, want one mentioning %q", yaml, err, want)
+		}
+	}
+
+	writeConfig(t, "lambda:\n  function_name: hello\ntriggers:\n  s3:\n    bucket: uploads\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.S3BucketARN(); got != "arn:aws:s3:::uploads" {
+		t.Errorf("S3BucketARN = %s", got)
+	}
+	if got := cfg.Triggers.S3.EventTypes(); len(got) != 1 || got[0] != "s3:ObjectCreated:*" {
+		t.Errorf("EventTypes = %v, want s3:ObjectCreated:*", got)
+	}
+}
+
+func TestLoadDynamoDBTrigger(t *testing.T) {
+	const stream = "arn:aws:dynamodb:us-east-1:123:table/orders/stream/2024-01-01T00:00:00.000"
+	for yaml, want := range map[string]string{
+		"triggers:\n  dynamodb:\n    batch_size: 10\n":                                              "set stream",
+		"triggers:\n  dynamodb:\n    stream: orders\n":                                              "stream's ARN",
+		"triggers:\n  dynamodb:\n    stream: " + stream + "\n    starting_position: AT_TIMESTAMP\n": "LATEST or TRIM_HORIZON",
+		"triggers:\n  dynamodb:\n    stream: " + stream + "\n    parallelization_factor: 11\n":      "between 1 and 10",
+	} {
+		writeConfig(t, yaml)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%sLoad() error = %v, want one mentioning %q", yaml, err, want)
+		}
+	}
+
+	writeConfig(t, "triggers:\n  dynamodb:\n    stream: "+stream+"\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trigger := cfg.Triggers.DynamoDB; trigger.Position() != "LATEST" || trigger.RecordsPerBatch() != 100 || trigger.BatchesPerShard() != 1 {
+		t.Errorf("defaults: %s, %d, %d; want LATEST, 100, 1", trigger.Position(), trigger.RecordsPerBatch(), trigger.BatchesPerShard())
+	}
+}
+
+func TestLoadKinesisTrigger(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"triggers:\n  kinesis:\n    tumbling_window: 1m\n":                           "set stream",
+		"triggers:\n  kinesis:\n    stream: clicks\n    tumbling_window: 20m\n":      "up to 15m",
+		"triggers:\n  kinesis:\n    stream: clicks\n    on_failure: clicks-failed\n": "SQS queue or SNS topic",
+	} {
+		writeConfig(t, yaml)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%sLoad() error = %v, want one mentioning %q", yaml, err, want)
+		}
+	}
+
+	writeConfig(t, "aws:\n  region: us-east-1\ntriggers:\n  kinesis:\n    stream: clicks\n    on_failure: arn:aws:sns:us-east-1:123:clicks-failed\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.KinesisStreamARN("123"); got != "arn:aws:kinesis:us-east-1:123:stream/clicks" {
+		t.Errorf("KinesisStreamARN = %s", got)
+	}
+	policy, err := cfg.KinesisTriggerPolicy("123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(policy, `"Action":["sns:Publish"],"Effect":"Allow","Resource":["arn:aws:sns:us-east-1:123:clicks-failed"]`) {
+		t.Errorf("policy does not allow publishing failed batches: %s", policy)
+	}
+}
+
+func TestRegions(t *testing.T) {
+	writeConfig(t, `aws:
+  region: us-east-1
+lambda:
+  function_name: hello
+environments:
+  eu:
+    aws:
+      region: eu-west-1
+  eu-staging:
+    aws:
+      region: eu-west-1
+    lambda:
+      function_name: hello-staging
+  ap:
+    aws:
+      region: ap-south-1
+`)
+	Env = "eu"
+	t.Cleanup(func() { Env = "" })
+	regions, err := Regions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"eu-west-1", "us-east-1", "ap-south-1"}; !reflect.DeepEqual(regions, want) {
+		t.Errorf("Regions() = %v, want %v", regions, want)
+	}
+
+	Region = "us-west-2"
+	t.Cleanup(func() { Region = "" })
+	if regions, err := Regions(); err != nil || !reflect.DeepEqual(regions, []string{"us-west-2"}) {
+		t.Errorf("Regions() with -region = %v, %v", regions, err)
+	}
+}
+
+func TestResourceTags(t *testing.T) {
+	writeConfig(t, "lambda:\n  function_name: hello\n  tags:\n    team: payments\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := cfg.ResourceTags(time.Date(2024, 7, 1, 23, 0, 0, 0, time.FixedZone("PDT", -7*3600)))
+	if tags["team"] != "payments" || tags[CreatedByTag] != CreatedBy || tags[FunctionTag] != "hello" || tags[CreatedTag] != "2024-07-02" {
+		t.Errorf("got %v", tags)
+	}
+	if len(cfg.Lambda.Tags) != 1 {
+		t.Errorf("lambda.tags should not change, got %v", cfg.Lambda.Tags)
+	}
+	if want := filepath.Base(filepath.Dir(Path)); tags[ProjectTag] != want {
+		t.Errorf("%s = %q, want the config file's directory %q", ProjectTag, tags[ProjectTag], want)
+	}
+
+	writeConfig(t, "project: shop\n")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if tags := cfg.ResourceTags(time.Now()); tags[ProjectTag] != "shop" {
+		t.Errorf("%s = %q, want project", ProjectTag, tags[ProjectTag])
+	}
+}
+
+func TestAWSConfigEndpoints(t *testing.T) {
+	t.Setenv("AWS_USE_FIPS_ENDPOINT", "")
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	t.Cleanup(func() { hostexec.SetAWSEnv(nil) })
+	writeConfig(t, "aws:\n  region: us-gov-west-1\n  fips: true\n  dual_stack: true\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.AWSConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"AWS_USE_FIPS_ENDPOINT=true", "AWS_USE_DUALSTACK_ENDPOINT=true"}; !reflect.DeepEqual(hostexec.AWSEnv(), want) {
+		t.Errorf("aws CLI environment = %q, want %q", hostexec.AWSEnv(), want)
+	}
+	if os.Getenv("AWS_USE_FIPS_ENDPOINT") != "" {
+		t.Error("AWSConfig() changed this process's environment")
+	}
+
+	// The next configuration loaded does not inherit the switches
+	writeConfig(t, "aws:\n  region: us-east-1\n")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.AWSConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if env := hostexec.AWSEnv(); len(env) != 0 {
+		t.Errorf("aws CLI environment = %q after a config without the switches", env)
+	}
+
+	writeConfig(t, "aws:\n  region: cn-north-1\n  fips: true\n")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "aws.fips") {
+		t.Errorf("Load() error = %v, want no FIPS endpoints in China", err)
+	}
+}
+
+func TestHTTPClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o644)
+
+	writeConfig(t, "tls:\n  ca_bundle: "+bundle+"\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := cfg.HTTPClient(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET with tls.ca_bundle: %v", err)
+	}
+	resp.Body.Close()
+
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Cleanup(func() { hostexec.SetAWSEnv(nil) })
+	if _, err := cfg.AWSConfig(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if env := hostexec.AWSEnv(); !reflect.DeepEqual(env, []string{"AWS_CA_BUNDLE=" + bundle}) || os.Getenv("AWS_CA_BUNDLE") != "" {
+		t.Errorf("aws CLI environment = %q, want only the bundle, and not in this process's", env)
+	}
+
+	os.WriteFile(bundle, []byte("not a certificate"), 0o644)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "no PEM certificates") {
+		t.Errorf("Load() error = %v, want a bundle without certificates", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(parts ...string) string {
+		dir := filepath.Join(append([]string{root}, parts...)...)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	touch := func(path string) {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// repo/.git, repo/services/api/.lambda/config.yaml, repo/services/api/cmd/lambda
+	repo := mkdir("repo")
+	mkdir("repo", ".git")
+	api := mkdir("repo", "services", "api")
+	mkdir("repo", "services", "api", ".lambda")
+	touch(filepath.Join(api, ".lambda", "config.yaml"))
+	handler := mkdir("repo", "services", "api", "cmd", "lambda")
+	xdg := mkdir("xdg")
+
+	for _, test := range []struct {
+		name, dir, wantPath, wantDir string
+	}{
+		{"project directory", api, filepath.Join(api, ".lambda", "config.yaml"), api},
+		{"subdirectory", handler, filepath.Join(api, ".lambda", "config.yaml"), api},
+		{"outside any project", repo, DefaultPath, ""},
+	} {
+		path, dir := find(test.dir, xdg)
+		if path != test.wantPath || dir != test.wantDir {
+			t.Errorf("%s: find() = %q, %q; want %q, %q", test.name, path, dir, test.wantPath, test.wantDir)
+		}
+	}
+
+	// config.yaml takes precedence over .lambda/config.yaml in the same directory
+	touch(filepath.Join(api, "config.yaml"))
+	if path, _ := find(handler, xdg); path != filepath.Join(api, "config.yaml") {
+		t.Errorf("find() = %q, want ./config.yaml first", path)
+	}
+
+	// The search stops at the repository root before falling back to XDG
+	touch(filepath.Join(root, "config.yaml"))
+	mkdir("xdg", "lambda-template")
+	touch(filepath.Join(xdg, "lambda-template", "config.yaml"))
+	if path, dir := find(repo, xdg); path != filepath.Join(xdg, "lambda-template", "config.yaml") || dir != "" {
+		t.Errorf("find() = %q, %q; want the XDG file without a project directory", path, dir)
+	}
+}
+
+type fakeParameters struct {
+	values map[string]string
+	calls  [][]string
+}
+
+func (f *fakeParameters) GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	f.calls = append(f.calls, params.Names)
+	output := &ssm.GetParametersOutput{}
+	for _, name := range params.Names {
+		if value, ok := f.values[name]; ok {
+			// SSM returns the version or label of /name:3 apart
+			p := ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)}
+			if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+				p.Name, p.Selector = aws.String(name[:i]), aws.String(name[i:])
+			}
+			output.Parameters = append(output.Parameters, p)
+		} else {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+		}
+	}
+	return output, nil
+}
+
+func useParameters(t *testing.T, values map[string]string) *fakeParameters {
+	fake := &fakeParameters{values: values}
+	previous := newParameterClient
+	newParameterClient = func(context.Context, *Config) (GetParametersAPI, error) { return fake, nil }
+	t.Cleanup(func() {
+		newParameterClient, NoResolve = previous, false
+		parameterCache.values = map[string]string{}
+	})
+	return fake
+}
+
+func TestLoadResolvesParameters(t *testing.T) {
+	fake := useParameters(t, map[string]string{
+		"/hello/function": "hello-prod",
+		"/hello/account":  "123456789012",
+		"/hello/db-host":  "db.internal",
+		"/hello/token:3":  "third",
+	})
+	writeConfig(t, "aws:\n  region: us-west-2\nlambda:\n  function_name: ${ssm:/hello/function}\n"+
+		"  role_name: ${ssm:/hello/function}-role\n"+
+		"  dead_letter_arn: arn:aws:sqs:us-west-2:${ssm:/hello/account}:dlq\n"+
+		"  environment:\n    DB_HOST: ${ssm:/hello/db-host}\n    TOKEN: ${ssm:/hello/token:3}\n")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.FunctionName != "hello-prod" || cfg.Lambda.RoleName != "hello-prod-role" ||
+		cfg.Lambda.DeadLetterARN != "arn:aws:sqs:us-west-2:123456789012:dlq" || cfg.Lambda.Environment["DB_HOST"] != "db.internal" ||
+		cfg.Lambda.Environment["TOKEN"] != "third" {
+		t.Errorf("Lambda = %+v", cfg.Lambda)
+	}
+	if len(fake.calls) != 1 || len(fake.calls[0]) != 4 {
+		t.Errorf("GetParameters calls = %v, want one batch of the four names", fake.calls)
+	}
+
+	// A second load is served from the cache
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.calls) != 1 {
+		t.Errorf("GetParameters calls = %v after a second load, want none more", fake.calls)
+	}
+
+	NoResolve = true
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lambda.FunctionName != "${ssm:/hello/function}" {
+		t.Errorf("function_name with NoResolve = %q", cfg.Lambda.FunctionName)
+	}
+	// Checks of a setting's form wait for its value
+	writeConfig(t, "lambda:\n  function_name: hello\n  schedule: ${ssm:/hello/schedule}\n"+
+		"vpc:\n  subnet_ids: [\"${ssm:/hello/subnet}\"]\n  security_group_ids: [\"${ssm:/hello/sg}\"]\n")
+	if _, err := Load(); err != nil {
+		t.Errorf("Load() with NoResolve and unresolved VPC IDs = %v", err)
+	}
+	NoResolve = false
+
+	for _, test := range []struct {
+		config, want string
+	}{
+		{"lambda:\n  function_name: ${ssm:/hello/missing}\n", "/hello/missing (used by lambda.function_name)"},
+		{"aws:\n  region: ${ssm:/hello/region}\n", "aws.region: ${ssm:...} cannot be used here"},
+		{"lambda:\n  function_name: ${ssm:}\n", "lambda.function_name: ${ssm:} names no parameter"},
+	} {
+		writeConfig(t, test.config)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Load(%q) error = %v, want %q", test.config, err, test.want)
+		}
+	}
+}
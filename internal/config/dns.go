@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DNS routes a hostname to the region the function is set up in, from the
+// dns section. Multi-region deploys run setup once per region, with -region
+// or an environment per region; each run adds that region's Route53 health
+// check and record to the shared name, and Route53 answers with the
+// healthy region, or the closest healthy one with latency routing.
+type DNS struct {
+	ZoneID string `yaml:"zone_id"`
+	// Name is the record clients use, e.g. api.example.com
+	Name string `yaml:"name"`
+	// Target is this region's endpoint for Name, e.g. the regional domain
+	// of an API Gateway custom domain; it must answer for Name itself
+	Target string `yaml:"target"`
+	// Routing is failover (default) or latency
+	Routing string `yaml:"routing"`
+	// Failover is PRIMARY or SECONDARY: the role of this region under
+	// failover routing, usually set per environment
+	Failover string `yaml:"failover"`
+	// HealthCheckPath is requested over HTTPS on Target, /readyz by default
+	HealthCheckPath string `yaml:"health_check_path"`
+}
+
+// Enabled reports whether setup manages a record for the function.
+func (d DNS) Enabled() bool {
+	return d.ZoneID != ""
+}
+
+// RoutingPolicy is dns.routing with its default.
+func (d DNS) RoutingPolicy() string {
+	if d.Routing == "" {
+		return "failover"
+	}
+	return d.Routing
+}
+
+// HealthCheckResourcePath is dns.health_check_path with its default.
+func (d DNS) HealthCheckResourcePath() string {
+	if d.HealthCheckPath == "" {
+		return "/readyz"
+	}
+	return d.HealthCheckPath
+}
+
+// HealthCheckReference is the Name tag of the region's health check, which
+// setup and delete find it by: the function and region, since the function
+// has the same name in every region.
+func (c *Config) HealthCheckReference() string {
+	return c.Lambda.FunctionName + "-" + c.AWS.Region
+}
+
+func (c *Config) validateDNS() error {
+	dns := c.DNS
+	if !dns.Enabled() {
+		if dns.Name != "" || dns.Target != "" {
+			return fmt.Errorf("dns: zone_id is required with name and target")
+		}
+		return nil
+	}
+	if dns.Name == "" || dns.Target == "" {
+		return fmt.Errorf("dns: name and target are required with zone_id")
+	}
+	if strings.Contains(dns.Target, "/") && !unresolved(dns.Target) {
+		return fmt.Errorf("dns.target: want a host name such as d-abc123.execute-api.%s.amazonaws.com, got %q", c.AWS.Region, dns.Target)
+	}
+	if !strings.HasPrefix(dns.HealthCheckResourcePath(), "/") {
+		return fmt.Errorf("dns.health_check_path: must start with /, got %q", dns.HealthCheckPath)
+	}
+	switch dns.RoutingPolicy() {
+	case "failover":
+		if dns.Failover != "PRIMARY" && dns.Failover != "SECONDARY" {
+			return fmt.Errorf("dns.failover: failover routing needs PRIMARY or SECONDARY for %s, got %q", c.AWS.Region, dns.Failover)
+		}
+	case "latency":
+		if dns.Failover != "" {
+			return fmt.Errorf("dns.failover only applies to dns.routing: failover")
+		}
+	default:
+		return fmt.Errorf("dns.routing: must be failover or latency, got %q", dns.Routing)
+	}
+	return nil
+}
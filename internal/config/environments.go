@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// applyEnvironment lays environments.<name> from the config file over cfg.
+// An environment can set any part of the configuration; what it leaves out
+// keeps the top-level value, and lists replace rather than append.
+func applyEnvironment(cfg *Config, data []byte, name string) error {
+	var file struct {
+		Environments map[string]yaml.MapSlice `yaml:"environments"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("error parsing environments: %v", err)
+	}
+	environment, ok := file.Environments[name]
+	if !ok {
+		names := make([]string, 0, len(file.Environments))
+		for n := range file.Environments {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("environment %q requested, but the config file has no environments section", name)
+		}
+		return fmt.Errorf("unknown environment %q (have %s)", name, strings.Join(names, ", "))
+	}
+
+	overlay, err := yaml.Marshal(environment)
+	if err != nil {
+		return fmt.Errorf("error encoding environment %s: %v", name, err)
+	}
+	if err := yaml.Unmarshal(overlay, cfg); err != nil {
+		return fmt.Errorf("error parsing environment %s: %v", name, err)
+	}
+	return nil
+}
+
+// environmentNames returns "" for the top level of the config file followed
+// by the names in environments, sorted.
+func environmentNames() ([]string, error) {
+	data, err := os.ReadFile(Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	var file struct {
+		Environments map[string]yaml.MapSlice `yaml:"environments"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing environments: %v", err)
+	}
+	names := []string{""}
+	for name := range file.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+// Regions returns the regions the function is set up in, that of -env
+// first: a multi-region function has an environment per region that keeps
+// its name, so every environment giving the function the same name counts.
+// With -region, which overrides them all, there is one.
+func Regions() ([]string, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	environments, err := environmentNames()
+	if err != nil {
+		return nil, err
+	}
+	regions := []string{cfg.AWS.Region}
+	seen := map[string]bool{cfg.AWS.Region: true}
+	for _, env := range environments {
+		other, err := loadEnvironment(env)
+		if err != nil {
+			return nil, err
+		}
+		// An environment with other functions does not set this one up
+		if other.validateFunctions() != nil || other.selectFunction(Function) != nil {
+			continue
+		}
+		if other.Lambda.FunctionName == cfg.Lambda.FunctionName && !seen[other.AWS.Region] {
+			seen[other.AWS.Region] = true
+			regions = append(regions, other.AWS.Region)
+		}
+	}
+	return regions, nil
+}
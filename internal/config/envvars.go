@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix starts the environment variables that override settings of the
+// configuration file: LT_ followed by the setting's path in upper case with
+// underscores, e.g. LT_AWS_REGION for aws.region.
+const EnvPrefix = "LT_"
+
+// applyEnvVars sets the fields of cfg named by LT_ variables in environ.
+// Strings are taken as they are, string lists are comma-separated and
+// anything else (numbers, booleans, maps, lists of entries) is YAML, e.g.
+// LT_LAMBDA_TAGS='{team: payments}'. A variable naming no setting is an
+// error, so that a misspelt override does not go unnoticed.
+func applyEnvVars(cfg *Config, environ []string) error {
+	fields := map[string]reflect.Value{}
+	envFields(reflect.ValueOf(cfg).Elem(), EnvPrefix, fields)
+
+	var unknown []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		field, ok := fields[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s: no such setting in the configuration", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// envFields adds the settings under v to fields by variable name. Sections
+// are descended into; every other field is a single setting.
+func envFields(v reflect.Value, prefix string, fields map[string]reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(tag)
+		if f := v.Field(i); f.Kind() == reflect.Struct {
+			envFields(f, name+"_", fields)
+		} else {
+			fields[name] = f
+		}
+	}
+}
+
+func setField(field reflect.Value, value string) error {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+		return nil
+	}
+	parsed := reflect.New(field.Type())
+	if err := yaml.UnmarshalStrict([]byte(value), parsed.Interface()); err != nil {
+		return err
+	}
+	field.Set(parsed.Elem())
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Find looks for the configuration file when -config is not given: in the
+// working directory as config.yaml or .lambda/config.yaml, then the same in
+// each parent up to the module or repository root (the first directory with
+// a go.mod or .git), then in $XDG_CONFIG_HOME/lambda-template. projectDir is
+// the directory the file describes, which relative paths in it and the
+// Docker build context refer to; it is empty for the XDG location and when
+// nothing is found, in which case path is DefaultPath.
+func Find() (path, projectDir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return DefaultPath, ""
+	}
+	return find(wd, xdgConfigHome())
+}
+
+func find(dir, xdgHome string) (path, projectDir string) {
+	for {
+		for _, candidate := range []string{DefaultPath, filepath.Join(".lambda", DefaultPath)} {
+			if isFile(filepath.Join(dir, candidate)) {
+				return filepath.Join(dir, candidate), dir
+			}
+		}
+		if exists(filepath.Join(dir, "go.mod")) || exists(filepath.Join(dir, ".git")) {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	if xdgHome != "" {
+		if path := filepath.Join(xdgHome, "lambda-template", DefaultPath); isFile(path) {
+			return path, ""
+		}
+	}
+	return DefaultPath, ""
+}
+
+func xdgConfigHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config")
+	}
+	return ""
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FunctionConfig is an entry of functions: one handler of the project,
+// deployed as its own Lambda function from its own image. Settings left out
+// keep the top-level lambda values.
+type FunctionConfig struct {
+	// Name is the Lambda function name and what -function selects
+	Name    string `yaml:"name"`
+	Handler string `yaml:"handler"`
+	// Dockerfile defaults to the Dockerfile in the project directory
+	Dockerfile string `yaml:"dockerfile"`
+	// RepositoryName defaults to Name, so the images do not overwrite each
+	// other's latest tag
+	RepositoryName   string `yaml:"repository_name"`
+	Timeout          int    `yaml:"timeout"`
+	MemorySize       int    `yaml:"memory_size"`
+	EphemeralStorage int    `yaml:"ephemeral_storage"`
+	Schedule         string `yaml:"schedule"`
+	// ReservedConcurrency is per function, so entries usually set their own
+	ReservedConcurrency *int `yaml:"reserved_concurrency"`
+}
+
+// FunctionNames returns the names in functions, in the order setup, deploy
+// and delete go through them; it is empty for a single-function project.
+func FunctionNames() ([]string, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validateFunctions(); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(cfg.Functions))
+	for i, f := range cfg.Functions {
+		names[i] = f.Name
+	}
+	return names, nil
+}
+
+func (c *Config) validateFunctions() error {
+	seen := map[string]bool{}
+	for i, f := range c.Functions {
+		if f.Name == "" {
+			return fmt.Errorf("functions[%d]: name is required", i)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("functions: %s is listed twice", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
+
+// selectFunction lays the functions entry chosen with -function over the
+// lambda and ecr sections. A list of one needs no -function.
+func (c *Config) selectFunction(name string) error {
+	if len(c.Functions) == 0 {
+		if name != "" {
+			return fmt.Errorf("function %q requested, but the config file has no functions list", name)
+		}
+		return nil
+	}
+
+	var f *FunctionConfig
+	names := make([]string, len(c.Functions))
+	for i := range c.Functions {
+		names[i] = c.Functions[i].Name
+		if c.Functions[i].Name == name || (name == "" && len(c.Functions) == 1) {
+			f = &c.Functions[i]
+		}
+	}
+	if f == nil {
+		if name == "" {
+			return fmt.Errorf("the config file lists several functions (%s); choose one with -function", strings.Join(names, ", "))
+		}
+		return fmt.Errorf("unknown function %q (have %s)", name, strings.Join(names, ", "))
+	}
+
+	c.Lambda.FunctionName = f.Name
+	if f.Handler != "" {
+		c.Lambda.Handler = f.Handler
+	}
+	if f.Timeout > 0 {
+		c.Lambda.Timeout = f.Timeout
+	}
+	if f.MemorySize > 0 {
+		c.Lambda.MemorySize = f.MemorySize
+	}
+	if f.EphemeralStorage > 0 {
+		c.Lambda.EphemeralStorage = f.EphemeralStorage
+	}
+	if f.Schedule != "" {
+		c.Lambda.Schedule = f.Schedule
+	}
+	if f.ReservedConcurrency != nil {
+		c.Lambda.ReservedConcurrency = f.ReservedConcurrency
+	}
+	c.ECR.RepositoryName = f.RepositoryName
+	if c.ECR.RepositoryName == "" {
+		c.ECR.RepositoryName = f.Name
+	}
+	c.Docker.Dockerfile = f.Dockerfile
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import "fmt"
+
+// FunctionURL gives the function an HTTPS endpoint of its own, from the
+// function_url section, for callers such as webhooks that need nothing
+// API Gateway or CloudFront would add.
+type FunctionURL struct {
+	Enabled bool `yaml:"enabled"`
+	// AuthType is AWS_IAM (default), which takes requests signed by callers
+	// allowed to invoke the URL, or NONE, which takes any request
+	AuthType string `yaml:"auth_type"`
+}
+
+// URLAuthType is function_url.auth_type with its default.
+func (f FunctionURL) URLAuthType() string {
+	if f.AuthType == "" {
+		return "AWS_IAM"
+	}
+	return f.AuthType
+}
+
+func (c *Config) validateFunctionURL() error {
+	url := c.FunctionURL
+	if !url.Enabled {
+		if url.AuthType != "" {
+			return fmt.Errorf("function_url.auth_type: set function_url.enabled as well")
+		}
+		return nil
+	}
+	if c.Deploy.Strategy == "bluegreen" {
+		return fmt.Errorf("function_url: not supported with deploy.strategy bluegreen, as each function has its own URL and a swap would change it; use api instead")
+	}
+	switch url.URLAuthType() {
+	case "AWS_IAM":
+	case "NONE":
+		// CloudFront signs its requests to the URL; a public one would let
+		// callers around the WAF
+		if c.CDN.Enabled {
+			return fmt.Errorf("function_url.auth_type: cdn needs AWS_IAM, got NONE")
+		}
+	default:
+		return fmt.Errorf("function_url.auth_type: must be AWS_IAM or NONE, got %q", url.AuthType)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import "fmt"
+
+// HTTPAPI puts an API Gateway HTTP API in front of the function, from the
+// api section: a $default route sends every request to the function with
+// payload format 2.0, which cmd/lambda serves.
+type HTTPAPI struct {
+	Enabled bool `yaml:"enabled"`
+	// Name is the API's name, which setup and delete find it by;
+	// <function>-api by default
+	Name string `yaml:"name"`
+}
+
+// HTTPAPIName is api.name with its default.
+func (c *Config) HTTPAPIName() string {
+	if c.API.Name == "" {
+		return c.Lambda.FunctionName + "-api"
+	}
+	return c.API.Name
+}
+
+func (c *Config) validateHTTPAPI() error {
+	if !c.API.Enabled && c.API.Name != "" {
+		return fmt.Errorf("api.name: set api.enabled as well")
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// NoResolve leaves ${ssm:...} placeholders in the configuration as they are
+// written, to see what the file says without reaching SSM.
+var NoResolve bool
+
+// parameterPlaceholder is ${ssm:<name>} in any string setting, where name is
+// a parameter name or ARN; the whole value or part of it may be one.
+var parameterPlaceholder = regexp.MustCompile(`\$\{ssm:([^}]*)\}`)
+
+// unresolved reports whether value holds a placeholder -no-resolve left as it
+// is written. Validations that check the form of a setting skip such values,
+// which only SSM knows.
+func unresolved(value string) bool {
+	return NoResolve && parameterPlaceholder.MatchString(value)
+}
+
+// GetParametersAPI is the subset of the SSM client used to resolve
+// placeholders.
+type GetParametersAPI interface {
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+}
+
+// newParameterClient returns the client placeholders are resolved with,
+// reaching SSM as the configuration's profile and region.
+var newParameterClient = func(ctx context.Context, c *Config) (GetParametersAPI, error) {
+	awsCfg, err := c.AWSConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ssm.NewFromConfig(awsCfg), nil
+}
+
+// parameterCache holds the values read by this process by region, profile
+// and name, as commands load the configuration once per function and
+// environment. Values are never written to disk; they may be SecureStrings.
+var parameterCache = struct {
+	sync.Mutex
+	values map[string]string
+}{values: map[string]string{}}
+
+// resolveParameters replaces the ${ssm:...} placeholders in every string
+// setting of cfg with the parameters' values, read in batches with
+// decryption.
+func resolveParameters(ctx context.Context, cfg *Config) error {
+	uses := map[string][]string{}
+	err := walkStrings(reflect.ValueOf(cfg).Elem(), "", func(path, value string) (string, error) {
+		for _, match := range parameterPlaceholder.FindAllStringSubmatch(value, -1) {
+			if match[1] == "" {
+				return "", fmt.Errorf("%s: %s names no parameter", path, match[0])
+			}
+			uses[match[1]] = append(uses[match[1]], path)
+		}
+		return value, nil
+	})
+	if err != nil || len(uses) == 0 {
+		return err
+	}
+	// These choose where the parameters are read from
+	for path, value := range map[string]string{
+		"aws.region":            cfg.AWS.Region,
+		"aws.profile":           cfg.AWS.Profile,
+		"aws.profiles.deploy":   cfg.AWS.Profiles.Deploy,
+		"aws.profiles.registry": cfg.AWS.Profiles.Registry,
+	} {
+		if parameterPlaceholder.MatchString(value) {
+			return fmt.Errorf("%s: ${ssm:...} cannot be used here, as it says where to read parameters from", path)
+		}
+	}
+
+	values, err := readParameters(ctx, cfg, uses)
+	if err != nil {
+		return err
+	}
+	return walkStrings(reflect.ValueOf(cfg).Elem(), "", func(path, value string) (string, error) {
+		return parameterPlaceholder.ReplaceAllStringFunc(value, func(placeholder string) string {
+			return values[parameterPlaceholder.FindStringSubmatch(placeholder)[1]]
+		}), nil
+	})
+}
+
+// readParameters returns the value of each parameter in uses, from the cache
+// or from SSM. A parameter SSM does not return is reported, under the name it
+// is written with, with the settings using it.
+func readParameters(ctx context.Context, cfg *Config, uses map[string][]string) (map[string]string, error) {
+	parameterCache.Lock()
+	defer parameterCache.Unlock()
+	cacheKey := func(name string) string {
+		return cfg.AWS.Region + "\x00" + cfg.AWS.Profile + "\x00" + name
+	}
+
+	values := map[string]string{}
+	var missing []string
+	for name := range uses {
+		if value, ok := parameterCache.values[cacheKey(name)]; ok {
+			values[name] = value
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	sort.Strings(missing)
+
+	client, err := newParameterClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading AWS configuration to resolve ${ssm:...}: %v", err)
+	}
+	var invalid []string
+	// GetParameters takes up to 10 names
+	for start := 0; start < len(missing); start += 10 {
+		batch := missing[start:min(start+10, len(missing))]
+		output, err := client.GetParameters(ctx, &ssm.GetParametersInput{Names: batch, WithDecryption: aws.Bool(true)})
+		if err != nil {
+			return nil, fmt.Errorf("error reading SSM parameters for ${ssm:...}: %v", err)
+		}
+		// Parameters come back under their name, which for an ARN is not
+		// the one requested, with the :3 or :label of /name:3 apart
+		returned := map[string]string{}
+		for _, p := range output.Parameters {
+			selector := aws.ToString(p.Selector)
+			returned[aws.ToString(p.Name)+selector] = aws.ToString(p.Value)
+			returned[aws.ToString(p.ARN)+selector] = aws.ToString(p.Value)
+		}
+		for _, name := range batch {
+			value, ok := returned[name]
+			if !ok {
+				invalid = append(invalid, fmt.Sprintf("%s (used by %s)", name, strings.Join(uses[name], ", ")))
+				continue
+			}
+			values[name] = value
+			parameterCache.values[cacheKey(name)] = value
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("SSM parameters not found in %s: %s", cfg.AWS.Region, strings.Join(invalid, "; "))
+	}
+	return values, nil
+}
+
+// walkStrings calls fn with every string setting under v and its path, e.g.
+// lambda.environment.DB_HOST, and stores what fn returns.
+func walkStrings(v reflect.Value, path string, fn func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		value, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		if value != v.String() {
+			v.SetString(value)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return walkStrings(v.Elem(), path, fn)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			fieldPath := join(path, name)
+			if strings.Contains(opts, "inline") {
+				fieldPath = path
+			}
+			if err := walkStrings(v.Field(i), fieldPath, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values cannot be set in place
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := walkStrings(elem, join(path, fmt.Sprint(key.Interface())), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+	return nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
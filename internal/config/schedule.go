@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ScheduleRuleName is the EventBridge rule that invokes the function on
+// lambda.schedule.
+func (c *Config) ScheduleRuleName() string {
+	return c.Lambda.FunctionName + "-schedule"
+}
+
+// validateSchedule checks that a schedule is an EventBridge rate or cron
+// expression; EventBridge checks the rest when the rule is put.
+func validateSchedule(field, schedule string) error {
+	if schedule == "" || unresolved(schedule) {
+		return nil
+	}
+	if (!strings.HasPrefix(schedule, "rate(") && !strings.HasPrefix(schedule, "cron(")) || !strings.HasSuffix(schedule, ")") {
+		return fmt.Errorf("%s: want rate(...) or cron(...), got %q", field, schedule)
+	}
+	return nil
+}
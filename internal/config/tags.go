@@ -0,0 +1,111 @@
+package config
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// The resources setup and the schedule commands create carry these tags on
+// top of lambda.tags, so `lambda-template sweep` can find the ones that no
+// config file deploys any more.
+const (
+	// CreatedByTag is set to CreatedBy on every resource this tool creates
+	CreatedByTag = "created-by"
+	CreatedBy    = "lambda-template"
+	// FunctionTag names the function the resource was created for
+	FunctionTag = "lambda-template:function"
+	// CreatedTag is the day the resource was created, as 2006-01-02
+	CreatedTag = "lambda-template:created"
+	// ProjectTag is the ProjectID of the config file the resource was
+	// created from; sweep only considers resources of its own project
+	ProjectTag = "lambda-template:project"
+)
+
+// ThrottledTag holds the reserved concurrency from before `throttle on`, or
+// "none" when the function had none, so `throttle off` can restore it from
+// any machine. Deploy leaves a throttled function's concurrency alone and
+// updates the tag instead.
+const ThrottledTag = "lambda-template:throttled-from"
+
+// ResourceTags are the tags of a resource created now for the function:
+// lambda.tags and the tags that mark it as this tool's.
+func (c *Config) ResourceTags(now time.Time) map[string]string {
+	tags := make(map[string]string, len(c.Lambda.Tags)+4)
+	for k, v := range c.Lambda.Tags {
+		tags[k] = v
+	}
+	tags[CreatedByTag] = CreatedBy
+	tags[FunctionTag] = c.Lambda.FunctionName
+	tags[CreatedTag] = now.UTC().Format("2006-01-02")
+	tags[ProjectTag] = c.ProjectID()
+	return tags
+}
+
+// ProjectID identifies the project in ProjectTag: project when set, and
+// otherwise the name of the directory holding the config file, which is
+// usually the repository's.
+func (c *Config) ProjectID() string {
+	if c.Project != "" {
+		return c.Project
+	}
+	path, err := filepath.Abs(Path)
+	if err != nil {
+		return filepath.Base(filepath.Dir(Path))
+	}
+	return filepath.Base(filepath.Dir(path))
+}
+
+// Inventory is what the config file deploys, in every environment and for
+// every functions entry.
+type Inventory struct {
+	Functions, Roles, Repositories map[string]bool
+	// Config is the configuration of -env, for its aws section; unlike
+	// Load, it needs no -function when the file lists several functions
+	Config *Config
+}
+
+// LoadInventory reads the inventory from Path. Each environment is loaded
+// the way -env loads it, so LT_ variables and -profile and -region apply.
+func LoadInventory() (*Inventory, error) {
+	environments, err := environmentNames()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	inventory := &Inventory{Functions: map[string]bool{}, Roles: map[string]bool{}, Repositories: map[string]bool{}, Config: cfg}
+	for _, env := range environments {
+		cfg, err := loadEnvironment(env)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.validateFunctions(); err != nil {
+			return nil, err
+		}
+		names := []string{""}
+		if len(cfg.Functions) > 0 {
+			names = names[:0]
+			for _, f := range cfg.Functions {
+				names = append(names, f.Name)
+			}
+		}
+		for _, name := range names {
+			// selectFunction changes the sections it lays the entry over
+			cfg, err := loadEnvironment(env)
+			if err != nil {
+				return nil, err
+			}
+			if err := cfg.selectFunction(name); err != nil {
+				return nil, err
+			}
+			for _, function := range cfg.DeployedFunctions() {
+				inventory.Functions[function] = true
+			}
+			inventory.Roles[cfg.Lambda.RoleName] = true
+			inventory.Repositories[cfg.ECR.RepositoryName] = true
+		}
+	}
+	return inventory, nil
+}
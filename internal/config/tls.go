@@ -0,0 +1,53 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTPClient returns a client for the calls the CLI makes outside the SDK,
+// such as fetching the rules bundle or posting the digest to Slack. Like the
+// SDK it goes through the proxy in HTTPS_PROXY, except for NO_PROXY hosts,
+// and it trusts tls.ca_bundle.
+func (c *Config) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.TLS.CABundle != "" {
+		pool, err := c.certPool()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// certPool returns the system roots with the certificates of tls.ca_bundle.
+func (c *Config) certPool() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	bundle, err := os.ReadFile(c.TLS.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("tls.ca_bundle: %v", err)
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("tls.ca_bundle: no PEM certificates in %s", c.TLS.CABundle)
+	}
+	return pool, nil
+}
+
+// DockerLoginHint explains a failed docker login to registry when
+// tls.ca_bundle is set. The Docker daemon, not the CLI, connects to the
+// registry, so neither the bundle nor the proxy reach it from here.
+func (c *Config) DockerLoginHint(registry string) string {
+	if c.TLS.CABundle == "" {
+		return ""
+	}
+	return fmt.Sprintf("; the Docker daemon verifies the registry itself, so install tls.ca_bundle as /etc/docker/certs.d/%s/ca.crt "+
+		"(or in the system trust store on Docker Desktop) and set its proxy in the daemon configuration", registry)
+}
@@ -0,0 +1,371 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Triggers are the event sources, from the triggers section, that setup maps
+// to the function.
+type Triggers struct {
+	SQS      SQSTrigger      `yaml:"sqs"`
+	SNS      SNSTrigger      `yaml:"sns"`
+	S3       S3Trigger       `yaml:"s3"`
+	DynamoDB DynamoDBTrigger `yaml:"dynamodb"`
+	Kinesis  KinesisTrigger  `yaml:"kinesis"`
+}
+
+// SQSTrigger delivers the messages of an existing queue to the function.
+type SQSTrigger struct {
+	// Queue is the queue's ARN, or its name in the function's account and
+	// region
+	Queue string `yaml:"queue"`
+	// BatchSize is the most messages in one invocation, 10 by default
+	BatchSize int `yaml:"batch_size"`
+	// BatchWindow is how long to gather messages before invoking, e.g. 20s;
+	// a batch size over 10 needs one
+	BatchWindow string `yaml:"batch_window"`
+}
+
+// SNSTrigger subscribes the function to an existing topic.
+type SNSTrigger struct {
+	// Topic is the topic's ARN, or its name in the function's account and
+	// region
+	Topic string `yaml:"topic"`
+}
+
+// Enabled reports whether triggers.sns names a topic.
+func (t SNSTrigger) Enabled() bool {
+	return t.Topic != ""
+}
+
+// SNSTopicARN is the ARN of triggers.sns.topic.
+func (c *Config) SNSTopicARN(accountID string) string {
+	if strings.HasPrefix(c.Triggers.SNS.Topic, "arn:") {
+		return c.Triggers.SNS.Topic
+	}
+	return c.Partition().ARN("sns", c.AWS.Region, accountID, c.Triggers.SNS.Topic)
+}
+
+// SNSTopicRegion is the region of triggers.sns.topic, which the function is
+// subscribed from: a topic ARN may name another region than aws.region.
+func (c *Config) SNSTopicRegion() string {
+	if parts := strings.Split(c.Triggers.SNS.Topic, ":"); len(parts) == 6 && parts[0] == "arn" {
+		return parts[3]
+	}
+	return c.AWS.Region
+}
+
+// SNSTopicName is the name of triggers.sns.topic, which it ends with when
+// it is an ARN.
+func (c *Config) SNSTopicName() string {
+	topic := c.Triggers.SNS.Topic
+	return topic[strings.LastIndex(topic, ":")+1:]
+}
+
+// S3Trigger has an existing bucket notify the function of its events.
+type S3Trigger struct {
+	Bucket string `yaml:"bucket"`
+	// Events are S3 event types, s3:ObjectCreated:* by default
+	Events []string `yaml:"events"`
+	// Prefix and Suffix limit the notifications to matching object keys,
+	// e.g. uploads/ and .csv
+	Prefix string `yaml:"prefix"`
+	Suffix string `yaml:"suffix"`
+}
+
+// Enabled reports whether triggers.s3 names a bucket.
+func (t S3Trigger) Enabled() bool {
+	return t.Bucket != ""
+}
+
+// EventTypes is triggers.s3.events with its default.
+func (t S3Trigger) EventTypes() []string {
+	if len(t.Events) == 0 {
+		return []string{"s3:ObjectCreated:*"}
+	}
+	return t.Events
+}
+
+// S3BucketARN is the ARN of triggers.s3.bucket.
+func (c *Config) S3BucketARN() string {
+	return c.Partition().ARN("s3", "", "", c.Triggers.S3.Bucket)
+}
+
+// S3NotificationID identifies the function's entry among the bucket's
+// notification configurations, which are replaced as a whole.
+func (c *Config) S3NotificationID() string {
+	return c.Lambda.FunctionName + "-trigger"
+}
+
+// DynamoDBTrigger delivers the records of an existing DynamoDB stream to the
+// function.
+type DynamoDBTrigger struct {
+	// Stream is the stream's ARN, the table's LatestStreamArn
+	Stream string `yaml:"stream"`
+	// StartingPosition is where a new mapping starts reading: LATEST, the
+	// default, or TRIM_HORIZON for the oldest record in the stream
+	StartingPosition string `yaml:"starting_position"`
+	// BatchSize is the most records in one invocation, 100 by default
+	BatchSize int `yaml:"batch_size"`
+	// ParallelizationFactor is how many batches of each shard are
+	// processed at once, from 1 (the default) to 10
+	ParallelizationFactor int `yaml:"parallelization_factor"`
+}
+
+// Enabled reports whether triggers.dynamodb names a stream.
+func (t DynamoDBTrigger) Enabled() bool {
+	return t.Stream != ""
+}
+
+// Position is triggers.dynamodb.starting_position with its default.
+func (t DynamoDBTrigger) Position() string {
+	if t.StartingPosition == "" {
+		return "LATEST"
+	}
+	return t.StartingPosition
+}
+
+// RecordsPerBatch is triggers.dynamodb.batch_size with its default.
+func (t DynamoDBTrigger) RecordsPerBatch() int {
+	if t.BatchSize == 0 {
+		return 100
+	}
+	return t.BatchSize
+}
+
+// BatchesPerShard is triggers.dynamodb.parallelization_factor with its
+// default.
+func (t DynamoDBTrigger) BatchesPerShard() int {
+	if t.ParallelizationFactor == 0 {
+		return 1
+	}
+	return t.ParallelizationFactor
+}
+
+// KinesisTrigger delivers the records of an existing Kinesis data stream to
+// the function.
+type KinesisTrigger struct {
+	// Stream is the stream's ARN, or its name in the function's account and
+	// region
+	Stream string `yaml:"stream"`
+	// StartingPosition is where a new mapping starts reading: LATEST, the
+	// default, or TRIM_HORIZON
+	StartingPosition string `yaml:"starting_position"`
+	// TumblingWindow groups records into windows of up to 15m, e.g. 1m,
+	// whose state the function carries from one batch to the next
+	TumblingWindow string `yaml:"tumbling_window"`
+	// OnFailure is the ARN of an SQS queue or SNS topic that gets the
+	// details of batches the function gave up on
+	OnFailure string `yaml:"on_failure"`
+}
+
+// Enabled reports whether triggers.kinesis names a stream.
+func (t KinesisTrigger) Enabled() bool {
+	return t.Stream != ""
+}
+
+// Position is triggers.kinesis.starting_position with its default.
+func (t KinesisTrigger) Position() string {
+	if t.StartingPosition == "" {
+		return "LATEST"
+	}
+	return t.StartingPosition
+}
+
+// TumblingWindowSeconds is triggers.kinesis.tumbling_window in seconds, 0
+// without one.
+func (t KinesisTrigger) TumblingWindowSeconds() int {
+	window, _ := time.ParseDuration(t.TumblingWindow)
+	return int(window / time.Second)
+}
+
+// KinesisStreamARN is the ARN of triggers.kinesis.stream.
+func (c *Config) KinesisStreamARN(accountID string) string {
+	if strings.HasPrefix(c.Triggers.Kinesis.Stream, "arn:") {
+		return c.Triggers.Kinesis.Stream
+	}
+	return c.Partition().ARN("kinesis", c.AWS.Region, accountID, "stream/"+c.Triggers.Kinesis.Stream)
+}
+
+// KinesisStreamName is the name of triggers.kinesis.stream, which it ends
+// with when it is an ARN.
+func (c *Config) KinesisStreamName() string {
+	stream := c.Triggers.Kinesis.Stream
+	return stream[strings.LastIndex(stream, "/")+1:]
+}
+
+// KinesisTriggerPolicy is the role policy that lets the function read
+// triggers.kinesis.stream and send failed batches to on_failure, the scoped
+// equivalent of AWSLambdaKinesisExecutionRole.
+func (c *Config) KinesisTriggerPolicy(accountID string) (string, error) {
+	statements := []map[string]interface{}{{
+		"Effect": "Allow",
+		"Action": []string{"kinesis:DescribeStream", "kinesis:DescribeStreamSummary", "kinesis:GetRecords",
+			"kinesis:GetShardIterator", "kinesis:ListShards", "kinesis:SubscribeToShard"},
+		"Resource": []string{c.KinesisStreamARN(accountID)},
+	}, {
+		// ListStreams cannot be scoped
+		"Effect":   "Allow",
+		"Action":   []string{"kinesis:ListStreams"},
+		"Resource": []string{"*"},
+	}}
+	if destination := c.Triggers.Kinesis.OnFailure; destination != "" {
+		action := "sqs:SendMessage"
+		if strings.Contains(destination, ":sns:") {
+			action = "sns:Publish"
+		}
+		statements = append(statements, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   []string{action},
+			"Resource": []string{destination},
+		})
+	}
+	policy, err := json.Marshal(map[string]interface{}{"Version": "2012-10-17", "Statement": statements})
+	if err != nil {
+		return "", fmt.Errorf("error encoding Kinesis trigger policy: %v", err)
+	}
+	return string(policy), nil
+}
+
+// Enabled reports whether triggers.sqs names a queue.
+func (t SQSTrigger) Enabled() bool {
+	return t.Queue != ""
+}
+
+// SQSQueueARN is the ARN of triggers.sqs.queue.
+func (c *Config) SQSQueueARN(accountID string) string {
+	if strings.HasPrefix(c.Triggers.SQS.Queue, "arn:") {
+		return c.Triggers.SQS.Queue
+	}
+	return c.Partition().ARN("sqs", c.AWS.Region, accountID, c.Triggers.SQS.Queue)
+}
+
+// MessagesPerBatch is triggers.sqs.batch_size with its default.
+func (t SQSTrigger) MessagesPerBatch() int {
+	if t.BatchSize == 0 {
+		return 10
+	}
+	return t.BatchSize
+}
+
+// BatchWindowSeconds is triggers.sqs.batch_window in seconds, which is what
+// Lambda takes.
+func (t SQSTrigger) BatchWindowSeconds() int {
+	window, _ := time.ParseDuration(t.BatchWindow)
+	return int(window / time.Second)
+}
+
+func (c *Config) validateTriggers() error {
+	// Moving a subscription means unsubscribing one function and
+	// subscribing the other, which would drop or repeat messages
+	if c.Triggers.SNS.Enabled() && c.Deploy.Strategy == "bluegreen" {
+		return fmt.Errorf("triggers.sns: not supported with deploy.strategy bluegreen, which cannot move a subscription without dropping messages")
+	}
+	if err := c.validateS3Trigger(); err != nil {
+		return err
+	}
+	if err := c.validateDynamoDBTrigger(); err != nil {
+		return err
+	}
+	if err := c.validateKinesisTrigger(); err != nil {
+		return err
+	}
+
+	sqs := c.Triggers.SQS
+	if !sqs.Enabled() {
+		if sqs.BatchSize != 0 || sqs.BatchWindow != "" {
+			return fmt.Errorf("triggers.sqs: set queue as well")
+		}
+		return nil
+	}
+	if sqs.BatchWindow != "" && !unresolved(sqs.BatchWindow) {
+		window, err := time.ParseDuration(sqs.BatchWindow)
+		if err != nil || window < 0 || window > 5*time.Minute || window%time.Second != 0 {
+			return fmt.Errorf("triggers.sqs.batch_window: want whole seconds up to 5m, got %q", sqs.BatchWindow)
+		}
+	}
+	fifo := strings.HasSuffix(sqs.Queue, ".fifo")
+	switch size := sqs.MessagesPerBatch(); {
+	case size < 1 || size > 10000:
+		return fmt.Errorf("triggers.sqs.batch_size: must be between 1 and 10000, got %d", size)
+	case size > 10 && fifo:
+		return fmt.Errorf("triggers.sqs.batch_size: at most 10 for a FIFO queue, got %d", size)
+	case size > 10 && sqs.BatchWindowSeconds() == 0:
+		return fmt.Errorf("triggers.sqs.batch_size: over 10 needs a batch_window")
+	}
+	if fifo && sqs.BatchWindowSeconds() > 0 {
+		return fmt.Errorf("triggers.sqs.batch_window: not supported for a FIFO queue")
+	}
+	return nil
+}
+
+func (c *Config) validateS3Trigger() error {
+	s3 := c.Triggers.S3
+	if !s3.Enabled() {
+		if len(s3.Events) > 0 || s3.Prefix != "" || s3.Suffix != "" {
+			return fmt.Errorf("triggers.s3: set bucket as well")
+		}
+		return nil
+	}
+	if c.Deploy.Strategy == "bluegreen" {
+		return fmt.Errorf("triggers.s3: not supported with deploy.strategy bluegreen, which does not move bucket notifications")
+	}
+	for _, event := range s3.Events {
+		if !strings.HasPrefix(event, "s3:") && !unresolved(event) {
+			return fmt.Errorf("triggers.s3.events: want S3 event types such as s3:ObjectCreated:*, got %q", event)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateDynamoDBTrigger() error {
+	dynamodb := c.Triggers.DynamoDB
+	if !dynamodb.Enabled() {
+		if dynamodb.StartingPosition != "" || dynamodb.BatchSize != 0 || dynamodb.ParallelizationFactor != 0 {
+			return fmt.Errorf("triggers.dynamodb: set stream as well")
+		}
+		return nil
+	}
+	// The stream's label changes when it is turned off and on, so the ARN
+	// is needed rather than the table name
+	if (!strings.HasPrefix(dynamodb.Stream, "arn:") || !strings.Contains(dynamodb.Stream, ":dynamodb:") || !strings.Contains(dynamodb.Stream, "/stream/")) && !unresolved(dynamodb.Stream) {
+		return fmt.Errorf("triggers.dynamodb.stream: want the stream's ARN, e.g. arn:aws:dynamodb:us-east-1:123456789012:table/orders/stream/2024-01-01T00:00:00.000, got %q", dynamodb.Stream)
+	}
+	if p := dynamodb.Position(); p != "LATEST" && p != "TRIM_HORIZON" {
+		return fmt.Errorf("triggers.dynamodb.starting_position: must be LATEST or TRIM_HORIZON, got %q", p)
+	}
+	if size := dynamodb.RecordsPerBatch(); size < 1 || size > 10000 {
+		return fmt.Errorf("triggers.dynamodb.batch_size: must be between 1 and 10000, got %d", size)
+	}
+	if factor := dynamodb.BatchesPerShard(); factor < 1 || factor > 10 {
+		return fmt.Errorf("triggers.dynamodb.parallelization_factor: must be between 1 and 10, got %d", factor)
+	}
+	return nil
+}
+
+func (c *Config) validateKinesisTrigger() error {
+	kinesis := c.Triggers.Kinesis
+	if !kinesis.Enabled() {
+		if kinesis.StartingPosition != "" || kinesis.TumblingWindow != "" || kinesis.OnFailure != "" {
+			return fmt.Errorf("triggers.kinesis: set stream as well")
+		}
+		return nil
+	}
+	if p := kinesis.Position(); p != "LATEST" && p != "TRIM_HORIZON" {
+		return fmt.Errorf("triggers.kinesis.starting_position: must be LATEST or TRIM_HORIZON, got %q", p)
+	}
+	if kinesis.TumblingWindow != "" && !unresolved(kinesis.TumblingWindow) {
+		window, err := time.ParseDuration(kinesis.TumblingWindow)
+		if err != nil || window < 0 || window > 15*time.Minute || window%time.Second != 0 {
+			return fmt.Errorf("triggers.kinesis.tumbling_window: want whole seconds up to 15m, got %q", kinesis.TumblingWindow)
+		}
+	}
+	if destination := kinesis.OnFailure; destination != "" && !unresolved(destination) &&
+		(!strings.HasPrefix(destination, "arn:") || !strings.Contains(destination, ":sqs:") && !strings.Contains(destination, ":sns:")) {
+		return fmt.Errorf("triggers.kinesis.on_failure: want the ARN of an SQS queue or SNS topic, got %q", destination)
+	}
+	return nil
+}
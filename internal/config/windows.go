@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// DeployWindow is a time deploys are allowed in, from deploy_windows.
+type DeployWindow struct {
+	// Days the window opens on (mon..sun); empty means every day
+	Days []string `yaml:"days"`
+	// Start and End as HH:MM; an End before Start closes the next day
+	Start    string `yaml:"start"`
+	End      string `yaml:"end"`
+	Timezone string `yaml:"timezone"`
+}
+
+// Contains reports whether t falls inside the window.
+func (w DeployWindow) Contains(t time.Time) (bool, error) {
+	location := time.UTC
+	if w.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(w.Timezone); err != nil {
+			return false, fmt.Errorf("invalid deploy window timezone: %v", err)
+		}
+	}
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return false, fmt.Errorf("invalid deploy window start %q: %v", w.Start, err)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return false, fmt.Errorf("invalid deploy window end %q: %v", w.End, err)
+	}
+
+	t = t.In(location)
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := t.Hour()*60 + t.Minute()
+
+	// The window that contains t opened either today or, for windows that
+	// cross midnight, yesterday
+	if endMinute > startMinute {
+		return minute >= startMinute && minute < endMinute && w.onDay(t.Weekday()), nil
+	}
+	if minute >= startMinute {
+		return w.onDay(t.Weekday()), nil
+	}
+	if minute < endMinute {
+		return w.onDay(t.AddDate(0, 0, -1).Weekday()), nil
+	}
+	return false, nil
+}
+
+func (w DeployWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	name := strings.ToLower(day.String()[:3])
+	for _, d := range w.Days {
+		if strings.ToLower(d) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// Package console builds links to the AWS console pages of a function's
+// resources, which commands print after running when given -links, so the
+// page to check next is one click away instead of a search in the console.
+package console
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"example-lambda-go/internal/partition"
+)
+
+// Show is set by the global -links flag.
+var Show bool
+
+// Link is a named console page.
+type Link struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Console builds the links of one region.
+type Console struct {
+	base, region string
+}
+
+// New returns the console of region in partition p.
+func New(p partition.Partition, region string) Console {
+	return Console{base: p.Console(region), region: region}
+}
+
+func (c Console) page(service, fragment string) string {
+	return fmt.Sprintf("%s/%s/home?region=%s#%s", c.base, service, c.region, fragment)
+}
+
+// Function links to the function, or to one of its versions or aliases when
+// qualifier is set.
+func (c Console) Function(name, qualifier string) Link {
+	fragment := "/functions/" + url.PathEscape(name)
+	if qualifier != "" {
+		kind := "aliases"
+		if _, err := strconv.Atoi(qualifier); err == nil {
+			kind = "versions"
+		}
+		fragment += "/" + kind + "/" + url.PathEscape(qualifier)
+	}
+	return Link{"Function", c.page("lambda", fragment)}
+}
+
+// Metrics links to the function's monitoring tab.
+func (c Console) Metrics(name string) Link {
+	return Link{"Metrics", c.page("lambda", "/functions/"+url.PathEscape(name)+"?tab=monitoring")}
+}
+
+// Logs links to the function's log group.
+func (c Console) Logs(name string) Link {
+	// The console escapes the group name twice, writing % as $25
+	group := strings.ReplaceAll(url.QueryEscape("/aws/lambda/"+name), "%", "$25")
+	return Link{"Logs", c.page("cloudwatch", "logsV2:log-groups/log-group/"+group)}
+}
+
+// Alarms links to the CloudWatch alarms of the region.
+func (c Console) Alarms() Link {
+	return Link{"Alarms", c.page("cloudwatch", "alarmsV2:")}
+}
+
+// Dashboard links to a CloudWatch dashboard.
+func (c Console) Dashboard(name string) Link {
+	return Link{"Dashboard " + name, c.page("cloudwatch", "dashboards/dashboard/"+url.PathEscape(name))}
+}
+
+// Repository links to an ECR repository's images.
+func (c Console) Repository(account, repository string) Link {
+	return Link{"Images", fmt.Sprintf("%s/ecr/repositories/private/%s/%s?region=%s", c.base, account, repository, c.region)}
+}
+
+// Image links to one image of an ECR repository by digest.
+func (c Console) Image(account, repository, digest string) Link {
+	return Link{"Image", fmt.Sprintf("%s/ecr/repositories/private/%s/%s/_/image/%s/details?region=%s", c.base, account, repository, digest, c.region)}
+}
+
+// APIStage links to a stage of an API Gateway HTTP API, e.g. $default.
+func (c Console) APIStage(apiID, stage string) Link {
+	return Link{"API stage", fmt.Sprintf("%s/apigateway/main/develop/stages?api=%s&stage=%s&region=%s", c.base, apiID, url.QueryEscape(stage), c.region)}
+}
+
+// Print writes the links under a heading, one per line.
+func Print(w io.Writer, links ...Link) {
+	if len(links) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Console:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, link := range links {
+		fmt.Fprintf(tw, "  %s\t%s\n", link.Name, link.URL)
+	}
+	tw.Flush()
+}
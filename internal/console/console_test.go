@@ -0,0 +1,44 @@
+package console
+
+import (
+	"strings"
+	"testing"
+
+	"example-lambda-go/internal/partition"
+)
+
+func TestLinks(t *testing.T) {
+	c := New(partition.AWS, "eu-west-1")
+	gov := New(partition.GovCloud, "us-gov-west-1")
+	china := New(partition.China, "cn-north-1")
+	for _, test := range []struct {
+		link Link
+		want string
+	}{
+		{c.Function("hello", ""), "https://eu-west-1.console.aws.amazon.com/lambda/home?region=eu-west-1#/functions/hello"},
+		{c.Function("hello", "7"), "https://eu-west-1.console.aws.amazon.com/lambda/home?region=eu-west-1#/functions/hello/versions/7"},
+		{c.Function("hello", "live"), "https://eu-west-1.console.aws.amazon.com/lambda/home?region=eu-west-1#/functions/hello/aliases/live"},
+		{gov.Logs("hello"), "https://console.amazonaws-us-gov.com/cloudwatch/home?region=us-gov-west-1#logsV2:log-groups/log-group/$252Faws$252Flambda$252Fhello"},
+		{china.Image("123", "hello", "sha256:abc"), "https://console.amazonaws.cn/ecr/repositories/private/123/hello/_/image/sha256:abc/details?region=cn-north-1"},
+		{c.APIStage("a1b2c3", "$default"), "https://eu-west-1.console.aws.amazon.com/apigateway/main/develop/stages?api=a1b2c3&stage=%24default&region=eu-west-1"},
+	} {
+		if test.link.URL != test.want {
+			t.Errorf("%s = %s, want %s", test.link.Name, test.link.URL, test.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var b strings.Builder
+	Print(&b, Link{"Function", "https://f"}, Link{"API stage", "https://a"})
+	want := "Console:\n  Function   https://f\n  API stage  https://a\n"
+	if b.String() != want {
+		t.Errorf("Print wrote:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	Print(&b)
+	if b.Len() != 0 {
+		t.Errorf("Print without links wrote %q", b.String())
+	}
+}
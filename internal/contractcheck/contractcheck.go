@@ -0,0 +1,181 @@
+// Package contractcheck compares the JSON Schemas of the handler's contract
+// types against the versions published in contracts/provider.json and the
+// expectations consumers recorded in contracts/consumers/*.json.
+package contractcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example-lambda-go/contract"
+	"example-lambda-go/internal/jsonschema"
+)
+
+const DefaultDir = "contracts"
+
+// Document is a published contract or a consumer's recorded expectations.
+type Document struct {
+	Consumer   string                     `json:"consumer,omitempty"`
+	Version    string                     `json:"version"`
+	Operations map[string]OperationSchema `json:"operations"`
+}
+
+type OperationSchema struct {
+	Request  *jsonschema.Schema `json:"request"`
+	Response *jsonschema.Schema `json:"response"`
+}
+
+type Result struct {
+	Current   Document
+	Published Document
+	// Breaks lists incompatibilities with consumer expectations
+	Breaks []string
+	// PublishedBreaks lists incompatibilities with the published contract
+	PublishedBreaks []string
+	// Changed is set when the current types differ from the published contract
+	Changed bool
+}
+
+// OK reports whether deploying the current types breaks nobody.
+func (r Result) OK() bool {
+	return len(r.Breaks) == 0 && len(r.PublishedBreaks) == 0
+}
+
+// Current returns the contract derived from contract.Operations.
+func Current() Document {
+	doc := Document{Operations: map[string]OperationSchema{}}
+	for _, op := range contract.Operations {
+		doc.Operations[op.Name] = OperationSchema{
+			Request:  jsonschema.Generate(op.Request),
+			Response: jsonschema.Generate(op.Response),
+		}
+	}
+	return doc
+}
+
+// Check compares the current types with everything recorded under dir. A
+// missing dir or provider.json is not an error; there is just nothing to
+// break yet.
+func Check(dir string) (Result, error) {
+	result := Result{Current: Current()}
+
+	published, err := load(filepath.Join(dir, "provider.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return result, err
+	}
+	if err == nil {
+		result.Published = published
+		result.PublishedBreaks = compare(published, result.Current)
+	}
+	result.Current.Version = published.Version
+	result.Changed = !sameOperations(published, result.Current)
+
+	consumers, err := filepath.Glob(filepath.Join(dir, "consumers", "*.json"))
+	if err != nil {
+		return result, err
+	}
+	sort.Strings(consumers)
+	for _, path := range consumers {
+		expected, err := load(path)
+		if err != nil {
+			return result, err
+		}
+		name := expected.Consumer
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		for _, b := range compare(expected, result.Current) {
+			result.Breaks = append(result.Breaks, fmt.Sprintf("%s (recorded against %s): %s", name, expected.Version, b))
+		}
+	}
+	return result, nil
+}
+
+// sameOperations compares the encoded schemas, which is what gets published.
+func sameOperations(a, b Document) bool {
+	encodedA, _ := json.Marshal(a.Operations)
+	encodedB, _ := json.Marshal(b.Operations)
+	return bytes.Equal(encodedA, encodedB)
+}
+
+// compare lists the breaking differences between an expected document and
+// the current contract.
+func compare(expected, current Document) []string {
+	names := make([]string, 0, len(expected.Operations))
+	for name := range expected.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var breaks []string
+	for _, name := range names {
+		want := expected.Operations[name]
+		have, ok := current.Operations[name]
+		if !ok {
+			breaks = append(breaks, fmt.Sprintf("%s: operation removed", name))
+			continue
+		}
+		for _, b := range jsonschema.RequestBreaks(want.Request, have.Request) {
+			breaks = append(breaks, fmt.Sprintf("%s request %s", name, b))
+		}
+		for _, b := range jsonschema.ResponseBreaks(want.Response, have.Response) {
+			breaks = append(breaks, fmt.Sprintf("%s response %s", name, b))
+		}
+	}
+	return breaks
+}
+
+// Publish writes the current contract to dir/provider.json with the next
+// version: a major bump for breaking changes, a minor bump otherwise.
+func Publish(dir string, result Result) (string, error) {
+	major, minor := parseVersion(result.Published.Version)
+	switch {
+	case result.Published.Version == "":
+		major, minor = 1, 0
+	case len(result.PublishedBreaks) > 0:
+		major, minor = major+1, 0
+	case result.Changed:
+		minor++
+	default:
+		return result.Published.Version, nil
+	}
+
+	doc := result.Current
+	doc.Version = fmt.Sprintf("%d.%d", major, minor)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "provider.json"), append(data, '\n'), 0o644); err != nil {
+		return "", err
+	}
+	return doc.Version, nil
+}
+
+func parseVersion(version string) (major, minor int) {
+	majorPart, minorPart, _ := strings.Cut(version, ".")
+	major, _ = strconv.Atoi(majorPart)
+	minor, _ = strconv.Atoi(minorPart)
+	return major, minor
+}
+
+func load(path string) (Document, error) {
+	var doc Document
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return doc, nil
+}
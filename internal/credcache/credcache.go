@@ -0,0 +1,188 @@
+// Package credcache keeps the temporary credentials of an assumed role or SSO
+// session in a local file that all lambda-template commands share, so that
+// running setup, deploy and invoke in a row asks for an MFA code or an SSO
+// login once instead of once per command. The file is encrypted with a key
+// kept in a separate directory, so copying or backing up the cache alone does
+// not reveal the credentials.
+package credcache
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"example-lambda-go/internal/filelock"
+)
+
+// RefreshWindow is how long before they expire cached credentials are
+// replaced, so a command never starts with credentials that run out midway.
+const RefreshWindow = 10 * time.Minute
+
+// Provider returns the cached credentials while they are fresh and otherwise
+// resolves new ones with Base and caches them. Commands that retrieve at the
+// same time wait for each other, so only one of them prompts.
+type Provider struct {
+	Base aws.CredentialsProvider
+	// Path is the encrypted cache file; it is locked through Path + ".lock"
+	Path string
+	// KeyPath holds the AES-256 key, created on first use
+	KeyPath string
+}
+
+// FileName names the cache file of a profile and the role it assumes.
+func FileName(profile, roleARN string) string {
+	sum := sha256.Sum256([]byte(profile + "\x00" + roleARN))
+	return hex.EncodeToString(sum[:16]) + ".json.enc"
+}
+
+type entry struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Source          string
+	Expires         time.Time
+}
+
+func (p *Provider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	lock, err := filelock.Wait(ctx, p.Path+".lock")
+	if err != nil {
+		// Without the lock the cache can't be shared safely; it is only a
+		// convenience, so resolve as if there were none
+		return p.Base.Retrieve(ctx)
+	}
+	defer lock.Unlock()
+
+	key, keyErr := loadKey(p.KeyPath)
+	if keyErr == nil {
+		if creds, ok := p.read(key); ok && time.Until(creds.Expires) > RefreshWindow {
+			return creds, nil
+		}
+	}
+
+	creds, err := p.Base.Retrieve(ctx)
+	if err != nil {
+		return creds, err
+	}
+	// Long-lived keys cost no prompt to resolve and are not worth a copy
+	if creds.CanExpire && keyErr == nil {
+		p.write(key, creds)
+	}
+	return creds, nil
+}
+
+// read returns the cached credentials; a missing, damaged or undecryptable
+// file counts as no cache.
+func (p *Provider) read(key []byte) (aws.Credentials, bool) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	gcm, err := newGCM(key)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return aws.Credentials{}, false
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return aws.Credentials{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(plain, &e); err != nil {
+		return aws.Credentials{}, false
+	}
+	return aws.Credentials{
+		AccessKeyID:     e.AccessKeyID,
+		SecretAccessKey: e.SecretAccessKey,
+		SessionToken:    e.SessionToken,
+		Source:          e.Source,
+		CanExpire:       true,
+		Expires:         e.Expires,
+	}, true
+}
+
+// write caches creds. Failures are ignored: the next command resolves the
+// credentials again, which is all the cache saves.
+func (p *Provider) write(key []byte, creds aws.Credentials) {
+	plain, err := json.Marshal(entry{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Source:          creds.Source,
+		Expires:         creds.Expires,
+	})
+	if err != nil {
+		return
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0o700); err != nil {
+		return
+	}
+	tmp := p.Path + ".tmp"
+	if err := os.WriteFile(tmp, gcm.Seal(nonce, nonce, plain, nil), 0o600); err != nil {
+		return
+	}
+	os.Rename(tmp, p.Path)
+}
+
+// loadKey reads the key at path, creating a random one readable only by the
+// user if there is none.
+func loadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("%s: not a 32-byte key", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	// O_EXCL: if another command created the key meanwhile, use theirs
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return loadKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
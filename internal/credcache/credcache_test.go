@@ -0,0 +1,114 @@
+package credcache
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// countingProvider hands out credentials expiring after ttl and counts how
+// often it was asked, standing in for an MFA prompt.
+type countingProvider struct {
+	calls int
+	ttl   time.Duration
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.calls++
+	return aws.Credentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "very-secret",
+		SessionToken:    "token",
+		CanExpire:       p.ttl > 0,
+		Expires:         time.Now().Add(p.ttl),
+	}, nil
+}
+
+func newProvider(dir string, base aws.CredentialsProvider) *Provider {
+	return &Provider{
+		Base:    base,
+		Path:    filepath.Join(dir, "cache", FileName("dev", "arn:aws:iam::123456789012:role/deploy")),
+		KeyPath: filepath.Join(dir, "config", "credentials.key"),
+	}
+}
+
+func TestSharedBetweenCommands(t *testing.T) {
+	dir := t.TempDir()
+	base := &countingProvider{ttl: time.Hour}
+	ctx := context.Background()
+
+	// Each command builds its own provider over the same files
+	for i := 0; i < 3; i++ {
+		creds, err := newProvider(dir, base).Retrieve(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.SecretAccessKey != "very-secret" || !creds.CanExpire {
+			t.Errorf("credentials = %+v", creds)
+		}
+	}
+	if base.calls != 1 {
+		t.Errorf("base provider called %d times, want 1", base.calls)
+	}
+
+	p := newProvider(dir, base)
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("very-secret")) {
+		t.Error("cache file holds the secret key in the clear")
+	}
+	for _, path := range []string{p.Path, p.KeyPath} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("%s: mode %v, %v; want 0600", path, info.Mode().Perm(), err)
+		}
+	}
+}
+
+func TestRefreshesBeforeExpiry(t *testing.T) {
+	dir := t.TempDir()
+	base := &countingProvider{ttl: RefreshWindow / 2}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := newProvider(dir, base).Retrieve(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.calls != 2 {
+		t.Errorf("base provider called %d times, want 2 for credentials inside the refresh window", base.calls)
+	}
+}
+
+func TestStaticKeysNotCached(t *testing.T) {
+	dir := t.TempDir()
+	p := newProvider(dir, &countingProvider{})
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(p.Path); !os.IsNotExist(err) {
+		t.Errorf("long-lived keys were cached: %v", err)
+	}
+}
+
+func TestUnreadableCacheIgnored(t *testing.T) {
+	dir := t.TempDir()
+	base := &countingProvider{ttl: time.Hour}
+	p := newProvider(dir, base)
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// A new key, as on another machine the cache was copied to
+	os.Remove(p.KeyPath)
+	if _, err := newProvider(dir, base).Retrieve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if base.calls != 2 {
+		t.Errorf("base provider called %d times, want 2 once the cache can't be decrypted", base.calls)
+	}
+}
@@ -0,0 +1,149 @@
+// Package database opens a *sql.DB to Postgres or MySQL through RDS Proxy
+// using IAM authentication instead of a stored password. Open it once per
+// cold start and keep it in a package variable: the pool then survives warm
+// invocations and RDS Proxy multiplexes the few connections each execution
+// environment holds onto the database.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// IAM auth tokens are valid for 15 minutes; refresh well before that so a
+// connection opened at the end of an invocation still gets a valid token.
+const tokenRefresh = 10 * time.Minute
+
+type Config struct {
+	Engine string
+	Host   string
+	Port   int
+	Name   string
+	User   string
+	Region string
+}
+
+// ConfigFromEnv reads the DB_* variables setup and deploy derive from the
+// database section of config.yaml.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Engine: os.Getenv("DB_ENGINE"),
+		Host:   os.Getenv("DB_HOST"),
+		Name:   os.Getenv("DB_NAME"),
+		User:   os.Getenv("DB_USER"),
+		Region: os.Getenv("AWS_REGION"),
+	}
+	cfg.Port, _ = strconv.Atoi(os.Getenv("DB_PORT"))
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort(cfg.Engine)
+	}
+	return cfg
+}
+
+func (c Config) Enabled() bool {
+	return c.Host != ""
+}
+
+func DefaultPort(engine string) int {
+	if engine == MySQL {
+		return 3306
+	}
+	return 5432
+}
+
+// Open returns a pool whose new connections authenticate with a fresh (or
+// cached, if still young) IAM token signed with awsCfg's credentials.
+func Open(cfg Config, awsCfg aws.Config) (*sql.DB, error) {
+	tokens := &tokenSource{cfg: cfg, credentials: awsCfg.Credentials}
+	if cfg.Region == "" {
+		tokens.cfg.Region = awsCfg.Region
+	}
+
+	var db *sql.DB
+	switch cfg.Engine {
+	case Postgres, "":
+		connConfig, err := pgx.ParseConfig(fmt.Sprintf("host=%s port=%d dbname=%s user=%s sslmode=require", cfg.Host, cfg.Port, cfg.Name, cfg.User))
+		if err != nil {
+			return nil, fmt.Errorf("invalid postgres config: %v", err)
+		}
+		db = stdlib.OpenDB(*connConfig, stdlib.OptionBeforeConnect(func(ctx context.Context, c *pgx.ConnConfig) error {
+			token, err := tokens.Token(ctx)
+			c.Password = token
+			return err
+		}))
+	case MySQL:
+		mysqlConfig := mysql.NewConfig()
+		mysqlConfig.Net = "tcp"
+		mysqlConfig.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+		mysqlConfig.DBName = cfg.Name
+		mysqlConfig.User = cfg.User
+		mysqlConfig.TLSConfig = "true"
+		// The token is sent as a cleartext password, which is why TLS is required
+		mysqlConfig.AllowCleartextPasswords = true
+		mysqlConfig.ParseTime = true
+		err := mysqlConfig.Apply(mysql.BeforeConnect(func(ctx context.Context, c *mysql.Config) error {
+			token, err := tokens.Token(ctx)
+			c.Passwd = token
+			return err
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("invalid mysql config: %v", err)
+		}
+		connector, err := mysql.NewConnector(mysqlConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mysql config: %v", err)
+		}
+		db = sql.OpenDB(connector)
+	default:
+		return nil, fmt.Errorf("unsupported database engine %q", cfg.Engine)
+	}
+
+	// One invocation runs at a time per execution environment, so a couple of
+	// connections is plenty; thousands of environments share the proxy's pool.
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(tokenRefresh)
+	return db, nil
+}
+
+type tokenSource struct {
+	cfg         Config
+	credentials aws.CredentialsProvider
+
+	mu      sync.Mutex
+	token   string
+	created time.Time
+}
+
+func (t *tokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Since(t.created) < tokenRefresh {
+		return t.token, nil
+	}
+
+	endpoint := net.JoinHostPort(t.cfg.Host, strconv.Itoa(t.cfg.Port))
+	token, err := auth.BuildAuthToken(ctx, endpoint, t.cfg.Region, t.cfg.User, t.credentials)
+	if err != nil {
+		return "", fmt.Errorf("failed to build IAM auth token: %v", err)
+	}
+	t.token, t.created = token, time.Now()
+	return token, nil
+}
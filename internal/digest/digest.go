@@ -0,0 +1,336 @@
+// Package digest builds the daily health digest of the function: invocations,
+// errors, throttles, p99 duration, an estimate of the cost and any alarms
+// that are not OK. It is sent to Slack and/or an SNS topic (which can fan out
+// to email) by the function itself, invoked on a schedule set up with
+// `lambda-template report schedule`.
+package digest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/middleware"
+)
+
+// DetailType is the detail-type of the scheduled event that asks for a
+// digest; the route in cmd/lambda/routes.yaml matches on it.
+const DetailType = "Health Digest"
+
+// List prices per GB-second and per request in us-east-1. The estimate
+// leaves out the free tier, provisioned concurrency and data transfer.
+const (
+	pricePerGBSecondX86   = 0.0000166667
+	pricePerGBSecondArm64 = 0.0000133334
+	pricePerRequest       = 0.20 / 1e6
+)
+
+// Config is read from the DIGEST_* variables that setup and deploy derive
+// from the report section of config.yaml.
+type Config struct {
+	// Functions are reported together, e.g. a blue/green pair
+	Functions    []string
+	SlackWebhook string
+	SNSTopicARN  string
+	// MemoryMB and Arm64 are what the cost estimate assumes
+	MemoryMB int
+	Arm64    bool
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		SlackWebhook: os.Getenv("DIGEST_SLACK_WEBHOOK"),
+		SNSTopicARN:  os.Getenv("DIGEST_SNS_TOPIC_ARN"),
+		Arm64:        runtime.GOARCH == "arm64",
+	}
+	if functions := os.Getenv("DIGEST_FUNCTIONS"); functions != "" {
+		cfg.Functions = strings.Split(functions, ",")
+	} else if name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); name != "" {
+		cfg.Functions = []string{name}
+	}
+	cfg.MemoryMB, _ = strconv.Atoi(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"))
+	return cfg
+}
+
+// Enabled reports whether there is anywhere to send the digest.
+func (c Config) Enabled() bool {
+	return c.SlackWebhook != "" || c.SNSTopicARN != ""
+}
+
+// CloudWatchAPI is the part of the CloudWatch client the digest reads with.
+type CloudWatchAPI interface {
+	cloudwatch.GetMetricDataAPIClient
+	cloudwatch.DescribeAlarmsAPIClient
+}
+
+type SNSAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+type Alarm struct {
+	Name, State, Reason string
+}
+
+type Digest struct {
+	Functions    []string
+	Since, Until time.Time
+	Invocations  float64
+	Errors       float64
+	Throttles    float64
+	// P99 is the highest p99 duration of the functions
+	P99     time.Duration
+	CostUSD float64
+	Alarms  []Alarm
+}
+
+// Collect builds the digest for the day ending at now.
+func Collect(ctx context.Context, client CloudWatchAPI, cfg Config, now time.Time) (*Digest, error) {
+	d := &Digest{Functions: cfg.Functions, Since: now.Add(-24 * time.Hour), Until: now}
+
+	var queries []cwtypes.MetricDataQuery
+	for i, name := range cfg.Functions {
+		for _, metric := range []struct{ id, name, stat string }{
+			{"invocations", "Invocations", "Sum"},
+			{"errors", "Errors", "Sum"},
+			{"throttles", "Throttles", "Sum"},
+			{"duration", "Duration", "Sum"},
+			{"p99", "Duration", "p99"},
+		} {
+			queries = append(queries, cwtypes.MetricDataQuery{
+				Id: aws.String(fmt.Sprintf("%s_%d", metric.id, i)),
+				MetricStat: &cwtypes.MetricStat{
+					Metric: &cwtypes.Metric{
+						Namespace:  aws.String("AWS/Lambda"),
+						MetricName: aws.String(metric.name),
+						Dimensions: []cwtypes.Dimension{{Name: aws.String("FunctionName"), Value: aws.String(name)}},
+					},
+					Period: aws.Int32(int32((24 * time.Hour).Seconds())),
+					Stat:   aws.String(metric.stat),
+				},
+			})
+		}
+	}
+
+	var durationMS float64
+	metrics := cloudwatch.NewGetMetricDataPaginator(client, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(d.Since),
+		EndTime:           aws.Time(d.Until),
+	})
+	for metrics.HasMorePages() {
+		page, err := metrics.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting metrics: %v", err)
+		}
+		for _, result := range page.MetricDataResults {
+			id, _, _ := strings.Cut(aws.ToString(result.Id), "_")
+			for _, value := range result.Values {
+				switch id {
+				case "invocations":
+					d.Invocations += value
+				case "errors":
+					d.Errors += value
+				case "throttles":
+					d.Throttles += value
+				case "duration":
+					durationMS += value
+				case "p99":
+					if p99 := time.Duration(value * float64(time.Millisecond)); p99 > d.P99 {
+						d.P99 = p99
+					}
+				}
+			}
+		}
+	}
+
+	price := pricePerGBSecondX86
+	if cfg.Arm64 {
+		price = pricePerGBSecondArm64
+	}
+	d.CostUSD = durationMS/1000*float64(cfg.MemoryMB)/1024*price + d.Invocations*pricePerRequest
+
+	alarms, err := alarmsNotOK(ctx, client, cfg.Functions)
+	if err != nil {
+		return nil, err
+	}
+	d.Alarms = alarms
+	return d, nil
+}
+
+// alarmsNotOK returns the metric alarms on the functions that are in ALARM
+// or have insufficient data.
+func alarmsNotOK(ctx context.Context, client CloudWatchAPI, functions []string) ([]Alarm, error) {
+	watched := map[string]bool{}
+	for _, name := range functions {
+		watched[name] = true
+	}
+
+	var alarms []Alarm
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(client, &cloudwatch.DescribeAlarmsInput{
+		AlarmTypes: []cwtypes.AlarmType{cwtypes.AlarmTypeMetricAlarm},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error describing alarms: %v", err)
+		}
+		for _, alarm := range page.MetricAlarms {
+			if alarm.StateValue == cwtypes.StateValueOk || !onFunction(alarm, watched) {
+				continue
+			}
+			alarms = append(alarms, Alarm{
+				Name:   aws.ToString(alarm.AlarmName),
+				State:  string(alarm.StateValue),
+				Reason: aws.ToString(alarm.StateReason),
+			})
+		}
+	}
+	return alarms, nil
+}
+
+func onFunction(alarm cwtypes.MetricAlarm, functions map[string]bool) bool {
+	dimensions := alarm.Dimensions
+	for _, query := range alarm.Metrics {
+		if query.MetricStat != nil && query.MetricStat.Metric != nil {
+			dimensions = append(dimensions, query.MetricStat.Metric.Dimensions...)
+		}
+	}
+	for _, dimension := range dimensions {
+		if aws.ToString(dimension.Name) == "FunctionName" && functions[aws.ToString(dimension.Value)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Subject is the one-line summary used as the email subject.
+func (d *Digest) Subject() string {
+	status := "healthy"
+	switch {
+	case len(d.Alarms) > 0:
+		status = fmt.Sprintf("%d alarm(s)", len(d.Alarms))
+	case d.Errors > 0 || d.Throttles > 0:
+		status = "errors"
+	}
+	return fmt.Sprintf("%s daily digest: %s", strings.Join(d.Functions, ", "), status)
+}
+
+// Text is the digest as plain text, readable in email and in Slack.
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, %s to %s\n\n", strings.Join(d.Functions, ", "),
+		d.Since.UTC().Format("Jan 2 15:04"), d.Until.UTC().Format("Jan 2 15:04 MST"))
+	fmt.Fprintf(&b, "Invocations:  %.0f\n", d.Invocations)
+	errorRate := 0.0
+	if d.Invocations > 0 {
+		errorRate = 100 * d.Errors / d.Invocations
+	}
+	fmt.Fprintf(&b, "Errors:       %.0f (%.2f%%)\n", d.Errors, errorRate)
+	fmt.Fprintf(&b, "Throttles:    %.0f\n", d.Throttles)
+	fmt.Fprintf(&b, "p99 duration: %s\n", d.P99.Round(time.Millisecond))
+	fmt.Fprintf(&b, "Cost:         ~$%.2f (list price, before free tier)\n", d.CostUSD)
+	if len(d.Alarms) == 0 {
+		b.WriteString("Alarms:       all OK\n")
+		return b.String()
+	}
+	b.WriteString("Alarms:\n")
+	for _, alarm := range d.Alarms {
+		fmt.Fprintf(&b, "  %s: %s (%s)\n", alarm.Name, alarm.State, alarm.Reason)
+	}
+	return b.String()
+}
+
+// Send delivers the digest to every configured destination, attempting
+// each even when another fails.
+func Send(ctx context.Context, cfg Config, client SNSAPI, httpClient *http.Client, d *Digest) error {
+	return Notify(ctx, cfg, client, httpClient, d.Subject(), d.Text())
+}
+
+// Notify posts a message to the Slack webhook and SNS topic of cfg, for
+// other reports that go where the digest does.
+func Notify(ctx context.Context, cfg Config, client SNSAPI, httpClient *http.Client, subject, text string) error {
+	var failed []string
+	if cfg.SlackWebhook != "" {
+		if err := postSlack(ctx, httpClient, cfg.SlackWebhook, subject, text); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if cfg.SNSTopicARN != "" {
+		_, err := client.Publish(ctx, &sns.PublishInput{
+			TopicArn: aws.String(cfg.SNSTopicARN),
+			Subject:  aws.String(truncate(subject, 100)),
+			Message:  aws.String(text),
+		})
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("error publishing to SNS: %v", err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func postSlack(ctx context.Context, client *http.Client, webhook, subject, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"text": "*" + subject + "*\n```" + text + "```",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid Slack webhook: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting to Slack: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Handler answers the scheduled digest event: it collects the digest for
+// the past day and sends it.
+func Handler(cfg Config, cw CloudWatchAPI, topic SNSAPI, httpClient *http.Client) lambda.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if !cfg.Enabled() {
+			return nil, fmt.Errorf("digest requested, but neither DIGEST_SLACK_WEBHOOK nor DIGEST_SNS_TOPIC_ARN is set")
+		}
+		d, err := Collect(ctx, cw, cfg, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if err := Send(ctx, cfg, topic, httpClient, d); err != nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Info("digest sent", "functions", d.Functions, "alarms", len(d.Alarms))
+		return nil, nil
+	})
+}
+
+// truncate shortens s to n bytes, as SNS rejects longer email subjects.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
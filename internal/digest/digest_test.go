@@ -0,0 +1,125 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type fakeCloudWatch struct {
+	values map[string]float64
+	alarms []cwtypes.MetricAlarm
+}
+
+func (f *fakeCloudWatch) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	output := &cloudwatch.GetMetricDataOutput{}
+	for _, query := range params.MetricDataQueries {
+		if value, ok := f.values[aws.ToString(query.Id)]; ok {
+			output.MetricDataResults = append(output.MetricDataResults, cwtypes.MetricDataResult{Id: query.Id, Values: []float64{value}})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeCloudWatch) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	return &cloudwatch.DescribeAlarmsOutput{MetricAlarms: f.alarms}, nil
+}
+
+func alarm(name string, state cwtypes.StateValue, function string) cwtypes.MetricAlarm {
+	return cwtypes.MetricAlarm{
+		AlarmName:   aws.String(name),
+		StateValue:  state,
+		StateReason: aws.String("Threshold Crossed"),
+		Dimensions:  []cwtypes.Dimension{{Name: aws.String("FunctionName"), Value: aws.String(function)}},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	client := &fakeCloudWatch{
+		values: map[string]float64{
+			"invocations_0": 900_000, "errors_0": 90, "throttles_0": 0, "duration_0": 90_000_000, "p99_0": 250,
+			"invocations_1": 100_000, "errors_1": 10, "throttles_1": 5, "duration_1": 10_000_000, "p99_1": 400,
+		},
+		alarms: []cwtypes.MetricAlarm{
+			alarm("hello-errors", cwtypes.StateValueAlarm, "hello"),
+			alarm("hello-green-duration", cwtypes.StateValueInsufficientData, "hello-green"),
+			alarm("hello-throttles", cwtypes.StateValueOk, "hello"),
+			alarm("other-errors", cwtypes.StateValueAlarm, "other"),
+		},
+	}
+	cfg := Config{Functions: []string{"hello", "hello-green"}, MemoryMB: 1024}
+
+	d, err := Collect(context.Background(), client, cfg, time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Invocations != 1_000_000 || d.Errors != 100 || d.Throttles != 5 || d.P99 != 400*time.Millisecond {
+		t.Errorf("digest = %+v", d)
+	}
+	// 100,000 GB-seconds on x86 and a million requests
+	if d.CostUSD < 1.86 || d.CostUSD > 1.87 {
+		t.Errorf("cost = %.4f, want about 1.87", d.CostUSD)
+	}
+	if len(d.Alarms) != 2 || d.Alarms[0].Name != "hello-errors" || d.Alarms[1].State != "INSUFFICIENT_DATA" {
+		t.Errorf("alarms = %+v, want the two not OK on hello and hello-green", d.Alarms)
+	}
+	if !strings.Contains(d.Subject(), "2 alarm(s)") || !strings.Contains(d.Text(), "Errors:       100 (0.01%)") {
+		t.Errorf("subject %q, text:\n%s", d.Subject(), d.Text())
+	}
+}
+
+type fakeSNS struct {
+	inputs []*sns.PublishInput
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSend(t *testing.T) {
+	var posted map[string]string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&posted)
+	}))
+	defer slack.Close()
+
+	topic := &fakeSNS{}
+	cfg := Config{SlackWebhook: slack.URL, SNSTopicARN: "arn:aws:sns:us-west-2:123456789012:digest"}
+	d := &Digest{Functions: []string{"hello"}, Invocations: 10}
+	if err := Send(context.Background(), cfg, topic, slack.Client(), d); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(posted["text"], "hello daily digest: healthy") {
+		t.Errorf("Slack message = %q", posted["text"])
+	}
+	if len(topic.inputs) != 1 || aws.ToString(topic.inputs[0].Subject) != "hello daily digest: healthy" {
+		t.Errorf("SNS publishes = %+v", topic.inputs)
+	}
+}
+
+func TestSendReportsEachFailure(t *testing.T) {
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer slack.Close()
+
+	topic := &fakeSNS{}
+	cfg := Config{SlackWebhook: slack.URL, SNSTopicARN: "arn:aws:sns:us-west-2:123456789012:digest"}
+	err := Send(context.Background(), cfg, topic, slack.Client(), &Digest{Functions: []string{"hello"}})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Send() error = %v, want the webhook's status", err)
+	}
+	if len(topic.inputs) != 1 {
+		t.Error("SNS was skipped after Slack failed")
+	}
+}
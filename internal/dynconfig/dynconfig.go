@@ -0,0 +1,259 @@
+// Package dynconfig loads a JSON or YAML document from SSM Parameter Store at
+// cold start and refreshes it once it is older than the TTL, so behaviour can
+// change without a redeploy. Refreshes happen lazily on access because the
+// execution environment is frozen between invocations.
+package dynconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"gopkg.in/yaml.v2"
+
+	"example-lambda-go/internal/logging"
+)
+
+const defaultTTL = time.Minute
+
+// GetParameterAPI is the subset of the SSM client used by Store.
+type GetParameterAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+type Store struct {
+	client GetParameterAPI
+	name   string
+	ttl    time.Duration
+
+	mu       sync.RWMutex
+	document map[string]interface{}
+	version  int64
+	loadedAt time.Time
+}
+
+func New(client GetParameterAPI, name string, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &Store{client: client, name: name, ttl: ttl}
+}
+
+// NewFromEnv reads the parameter name and TTL from DYNCONFIG_PARAMETER and
+// DYNCONFIG_TTL. It returns nil when no parameter is configured.
+func NewFromEnv(client GetParameterAPI) *Store {
+	name := os.Getenv("DYNCONFIG_PARAMETER")
+	if name == "" {
+		return nil
+	}
+	ttl, _ := time.ParseDuration(os.Getenv("DYNCONFIG_TTL"))
+	return New(client, name, ttl)
+}
+
+// Load fetches the document. Call it during init so a missing or invalid
+// parameter fails the cold start instead of the first request.
+func (s *Store) Load(ctx context.Context) error {
+	output, err := s.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(s.name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error reading parameter %s: %v", s.name, err)
+	}
+
+	document, err := Parse([]byte(aws.ToString(output.Parameter.Value)))
+	if err != nil {
+		return fmt.Errorf("error parsing parameter %s: %v", s.name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if output.Parameter.Version != s.version && s.version != 0 {
+		logging.FromContext(ctx).Info("dynamic configuration updated", "parameter", s.name, "version", output.Parameter.Version)
+	}
+	s.document = document
+	s.version = output.Parameter.Version
+	s.loadedAt = time.Now()
+	return nil
+}
+
+// Version returns the parameter version currently in use.
+func (s *Store) Version() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// refresh reloads a stale document. Failures keep the last good document.
+func (s *Store) refresh(ctx context.Context) {
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) > s.ttl
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+	if err := s.Load(ctx); err != nil {
+		logging.FromContext(ctx).Warn("keeping previous dynamic configuration", "error", err)
+		s.mu.Lock()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// Get returns the raw value at a dot-separated key such as "batch.size".
+func (s *Store) Get(ctx context.Context, key string) (interface{}, bool) {
+	s.refresh(ctx)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var value interface{} = s.document
+	for _, part := range strings.Split(key, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func (s *Store) String(ctx context.Context, key, fallback string) string {
+	value, ok := s.Get(ctx, key)
+	if !ok {
+		return fallback
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fallback
+}
+
+func (s *Store) Int(ctx context.Context, key string, fallback int) int {
+	if value, ok := s.Get(ctx, key); ok {
+		switch v := value.(type) {
+		case float64:
+			return int(v)
+		case string:
+			if i, err := strconv.Atoi(v); err == nil {
+				return i
+			}
+		}
+	}
+	return fallback
+}
+
+func (s *Store) Float(ctx context.Context, key string, fallback float64) float64 {
+	if value, ok := s.Get(ctx, key); ok {
+		switch v := value.(type) {
+		case float64:
+			return v
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return fallback
+}
+
+func (s *Store) Bool(ctx context.Context, key string, fallback bool) bool {
+	if value, ok := s.Get(ctx, key); ok {
+		switch v := value.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	}
+	return fallback
+}
+
+func (s *Store) Duration(ctx context.Context, key string, fallback time.Duration) time.Duration {
+	if value, ok := s.Get(ctx, key); ok {
+		if v, ok := value.(string); ok {
+			if d, err := time.ParseDuration(v); err == nil {
+				return d
+			}
+		}
+	}
+	return fallback
+}
+
+// Decode unmarshals the subtree at key (or the whole document when key is
+// empty) into v using its json tags.
+func (s *Store) Decode(ctx context.Context, key string, v interface{}) error {
+	var value interface{}
+	if key == "" {
+		s.refresh(ctx)
+		s.mu.RLock()
+		value = s.document
+		s.mu.RUnlock()
+	} else {
+		var ok bool
+		if value, ok = s.Get(ctx, key); !ok {
+			return fmt.Errorf("key %q not found in dynamic configuration", key)
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Parse decodes a JSON or YAML document whose top level must be a mapping.
+// Values are normalised to the types encoding/json produces.
+func Parse(data []byte) (map[string]interface{}, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err == nil {
+		return document, nil
+	}
+
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	normalised, ok := normalise(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document must be a mapping at the top level")
+	}
+	return normalised, nil
+}
+
+func normalise(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			object[fmt.Sprint(k)] = normalise(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalise(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v
+	}
+}
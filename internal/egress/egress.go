@@ -0,0 +1,179 @@
+// Package egress restricts the outbound connections a function can open to
+// the hostnames and CIDRs listed in EGRESS_ALLOW. It is a defence-in-depth
+// measure against SSRF and data exfiltration for handlers that process
+// untrusted input; network controls (security groups, NAT egress rules)
+// remain the primary boundary.
+//
+// The guard wraps DialContext, so it covers http.DefaultTransport (after
+// Install), internal/httpclient and AWS SDK clients built with HTTPClient.
+// Connections to AWS endpoints (*.amazonaws.com, and *.amazonaws.com.cn in
+// the China regions, and their dual-stack *.api.aws and
+// *.api.amazonwebservices.com.cn), loopback and the Lambda runtime API are
+// always allowed.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/metrics"
+)
+
+const metricsNamespace = "LambdaTemplate/Egress"
+
+// ErrBlocked is returned by the dialer for destinations outside the allow list.
+var ErrBlocked = errors.New("egress: destination not in allow list")
+
+// Always allowed so the SDK and the runtime keep working.
+var implicitAllow = []string{"*.amazonaws.com", "*.amazonaws.com.cn", "*.api.aws", "*.api.amazonwebservices.com.cn", "127.0.0.0/8", "::1/128"}
+
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+type Guard struct {
+	hosts    map[string]bool
+	suffixes []string
+	networks []*net.IPNet
+	resolver *net.Resolver
+}
+
+// New parses allow entries: exact hostnames, *.suffix wildcards and CIDRs.
+func New(allow []string) (*Guard, error) {
+	g := &Guard{hosts: map[string]bool{}, resolver: net.DefaultResolver}
+	if runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API"); runtimeAPI != "" {
+		host, _, _ := net.SplitHostPort(runtimeAPI)
+		allow = append(allow, host)
+	}
+	for _, entry := range append(allow, implicitAllow...) {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid egress CIDR %q: %v", entry, err)
+			}
+			g.networks = append(g.networks, network)
+		case strings.HasPrefix(entry, "*."):
+			g.suffixes = append(g.suffixes, entry[1:])
+		default:
+			g.hosts[entry] = true
+		}
+	}
+	return g, nil
+}
+
+// NewFromEnv returns a guard for the comma-separated EGRESS_ALLOW list set by
+// setup and deploy, or nil when egress is unrestricted.
+func NewFromEnv() (*Guard, error) {
+	allow := os.Getenv("EGRESS_ALLOW")
+	if allow == "" {
+		return nil, nil
+	}
+	return New(strings.Split(allow, ","))
+}
+
+func (g *Guard) hostAllowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if g.hosts[host] {
+		return true
+	}
+	for _, suffix := range g.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Guard) ipAllowed(ip net.IP) bool {
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap returns a dialer that checks the destination before calling next.
+// Hostnames not on the list are resolved and allowed only if every address is
+// inside an allowed CIDR; the connection then goes to the checked address so
+// a second DNS answer can't redirect it.
+func (g *Guard) Wrap(next DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if !g.ipAllowed(ip) && !g.hostAllowed(host) {
+				return nil, g.block(ctx, address)
+			}
+			return next(ctx, network, address)
+		}
+		if g.hostAllowed(host) {
+			return next(ctx, network, address)
+		}
+
+		addrs, err := g.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrs) == 0 {
+			return nil, g.block(ctx, address)
+		}
+		for _, addr := range addrs {
+			if !g.ipAllowed(addr.IP) {
+				return nil, g.block(ctx, address)
+			}
+		}
+		return next(ctx, network, net.JoinHostPort(addrs[0].IP.String(), port))
+	}
+}
+
+func (g *Guard) block(ctx context.Context, address string) error {
+	logging.FromContext(ctx).Warn("blocked outbound connection", "address", address)
+	metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "BlockedConnections", Value: 1, Unit: metrics.Count})
+	return fmt.Errorf("%w: %s", ErrBlocked, address)
+}
+
+var installed atomic.Pointer[Guard]
+
+// Install makes g the process-wide guard: it wraps http.DefaultTransport and
+// every dialer passed through Wrap.
+func Install(g *Guard) {
+	installed.Store(g)
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.DialContext = Wrap(transport.DialContext)
+	}
+}
+
+// Wrap defers to the installed guard at dial time, so clients created before
+// Install (e.g. in package variables) are still covered.
+func Wrap(next DialFunc) DialFunc {
+	if next == nil {
+		next = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if g := installed.Load(); g != nil {
+			return g.Wrap(next)(ctx, network, address)
+		}
+		return next(ctx, network, address)
+	}
+}
+
+// HTTPClient returns an AWS SDK HTTP client whose connections go through
+// the installed guard; pass it to config.WithHTTPClient.
+func HTTPClient() *awshttp.BuildableClient {
+	return awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+		transport.DialContext = Wrap(transport.DialContext)
+	})
+}
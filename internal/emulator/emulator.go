@@ -0,0 +1,98 @@
+// Package emulator runs a function image on this machine through the Runtime
+// Interface Emulator that the Lambda base images include, and invokes it.
+package emulator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// InvocationsPath is where the emulator takes invocations, the path of the
+// Invoke API for a function named "function".
+const InvocationsPath = "/2015-03-31/functions/function/invocations"
+
+// RIE is where the Lambda base images keep the Runtime Interface Emulator.
+const RIE = "/usr/local/bin/aws-lambda-rie"
+
+// Start runs the image, built for arch (amd64 or arm64), in the background,
+// publishing the emulator on port of the loopback interface only, and returns
+// the container ID. Values are passed through the docker client's
+// environment so credentials stay out of the process list.
+//
+// amd64 images start the emulator through the Go base image's entrypoint.
+// arm64 images have the handler binary itself as their entrypoint, which
+// expects the Lambda runtime API, so the emulator is made the entrypoint
+// with the binary as the handler it runs.
+func Start(image string, port int, env []string, arch string) (string, error) {
+	runCmd := exec.Command("docker", "run", "--detach", "--rm", "--publish", fmt.Sprintf("127.0.0.1:%d:8080", port))
+	runCmd.Env = os.Environ()
+	for _, variable := range env {
+		key, _, _ := strings.Cut(variable, "=")
+		runCmd.Args = append(runCmd.Args, "--env", key)
+		runCmd.Env = append(runCmd.Env, variable)
+	}
+	if arch == "arm64" {
+		runCmd.Args = append(runCmd.Args, "--platform", "linux/arm64", "--entrypoint", RIE, image, "/var/task/main")
+	} else {
+		runCmd.Args = append(runCmd.Args, image)
+	}
+	output, err := hostexec.Output(runCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to start the container: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Stop stops the container, which removes it.
+func Stop(container string) {
+	hostexec.Run(exec.Command("docker", "stop", container))
+}
+
+// FreePort returns a port of the loopback interface nothing listens on.
+func FreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("error finding a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Invoke posts the payload to the emulator at baseURL. The emulator takes a
+// moment to listen after the container starts, so failed connections are
+// retried for a few seconds; a timeout is not, as the function may have run.
+func Invoke(ctx context.Context, client *http.Client, baseURL string, payload []byte) ([]byte, int, error) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+InvocationsPath, bytes.NewReader(payload))
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			var netErr net.Error
+			if !(errors.As(err, &netErr) && netErr.Timeout()) && time.Now().Before(deadline) {
+				time.Sleep(250 * time.Millisecond)
+				continue
+			}
+			return nil, 0, fmt.Errorf("error invoking the emulator: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading response: %v", err)
+		}
+		return body, resp.StatusCode, nil
+	}
+}
@@ -0,0 +1,51 @@
+package emulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+func TestInvoke(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != InvocationsPath {
+			t.Errorf("got %s %s, want POST %s", r.Method, r.URL.Path, InvocationsPath)
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, `{"echo":%s}`, body)
+	}))
+	defer server.Close()
+
+	response, status, err := Invoke(context.Background(), server.Client(), server.URL, []byte(`{"name":"Ada"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || string(response) != `{"echo":{"name":"Ada"}}` {
+		t.Errorf("got %d %s", status, response)
+	}
+}
+
+func TestStartRunsArm64ThroughRIE(t *testing.T) {
+	fake := hostexec.NewFake()
+	previous := hostexec.Default
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default = previous })
+
+	for arch, want := range map[string]string{
+		"amd64": "docker run --detach --rm --publish 127.0.0.1:9000:8080 --env AWS_REGION hello:local",
+		"arm64": "docker run --detach --rm --publish 127.0.0.1:9000:8080 --env AWS_REGION --platform linux/arm64 --entrypoint " + RIE + " hello:local /var/task/main",
+	} {
+		fake.Calls = nil
+		if _, err := Start("hello:local", 9000, []string{"AWS_REGION=us-east-1"}, arch); err != nil {
+			t.Fatal(err)
+		}
+		if got := fake.Commands(); len(got) != 1 || got[0] != want {
+			t.Errorf("%s: ran %q, want %q", arch, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package envelope wraps handler events in a versioned envelope
+// ({"type": ..., "version": ..., "data": ...}) and migrates old versions to
+// the current shape before the handler sees them. Each event type registers
+// its current version and one upcaster per older version, so handlers only
+// ever decode the latest struct.
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"example-lambda-go/internal/middleware"
+)
+
+type Envelope struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Upcaster migrates the data of one version to the next.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// RenameField returns an upcaster that moves a top-level field, the most
+// common migration.
+func RenameField(from, to string) Upcaster {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if value, ok := fields[from]; ok {
+			fields[to] = value
+			delete(fields, from)
+		}
+		return json.Marshal(fields)
+	}
+}
+
+type eventType struct {
+	current   int
+	upcasters map[int]Upcaster
+}
+
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]*eventType
+}
+
+func NewRegistry() *Registry {
+	return &Registry{types: map[string]*eventType{}}
+}
+
+// Register declares the current version of an event type. Versions start at 1.
+func (r *Registry) Register(name string, current int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.types[name]; ok {
+		t.current = current
+		return
+	}
+	r.types[name] = &eventType{current: current, upcasters: map[int]Upcaster{}}
+}
+
+// Versions returns the current version of each registered event type.
+func (r *Registry) Versions() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	versions := make(map[string]int, len(r.types))
+	for name, t := range r.types {
+		versions[name] = t.current
+	}
+	return versions
+}
+
+// Upcast registers the migration from version `from` to from+1.
+func (r *Registry) Upcast(name string, from int, upcaster Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.types[name]
+	if !ok {
+		t = &eventType{upcasters: map[int]Upcaster{}}
+		r.types[name] = t
+	}
+	t.upcasters[from] = upcaster
+}
+
+// Migrate returns env with its data upcast to the current version of its
+// type. Unregistered types are returned unchanged.
+func (r *Registry) Migrate(env Envelope) (Envelope, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.types[env.Type]
+	if !ok {
+		return env, nil
+	}
+	if env.Version > t.current {
+		return env, fmt.Errorf("%s version %d is newer than the supported version %d", env.Type, env.Version, t.current)
+	}
+	for env.Version < t.current {
+		upcaster, ok := t.upcasters[env.Version]
+		if !ok {
+			return env, fmt.Errorf("no upcaster registered for %s version %d", env.Type, env.Version)
+		}
+		data, err := upcaster(env.Data)
+		if err != nil {
+			return env, fmt.Errorf("error upcasting %s from version %d: %v", env.Type, env.Version, err)
+		}
+		env.Data = data
+		env.Version++
+	}
+	return env, nil
+}
+
+// Wrap builds an envelope at the current registered version of name.
+func (r *Registry) Wrap(name string, data interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	version := 1
+	if t, ok := r.types[name]; ok && t.current > 0 {
+		version = t.current
+	}
+	r.mu.RUnlock()
+	return json.Marshal(Envelope{Type: name, Version: version, Data: encoded})
+}
+
+type envelopeKey struct{}
+
+// FromContext returns the (migrated) envelope the event arrived in, if any.
+func FromContext(ctx context.Context) (Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return env, ok
+}
+
+// parse recognises an envelope: an object with a type, a version and data.
+func parse(payload []byte) (Envelope, bool) {
+	var env Envelope
+	if len(payload) == 0 || payload[0] != '{' {
+		return env, false
+	}
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return env, false
+	}
+	return env, env.Type != "" && env.Version > 0 && len(env.Data) > 0
+}
+
+// Middleware unwraps enveloped events: the handler receives the upcast data
+// and can read the type and version through FromContext. Payloads that are
+// not envelopes pass through untouched.
+func Middleware(registry *Registry) middleware.Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			env, ok := parse(payload)
+			if !ok {
+				return next.Invoke(ctx, payload)
+			}
+			env, err := registry.Migrate(env)
+			if err != nil {
+				return nil, err
+			}
+			return next.Invoke(context.WithValue(ctx, envelopeKey{}, env), env.Data)
+		})
+	}
+}
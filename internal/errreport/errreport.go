@@ -0,0 +1,181 @@
+// Package errreport ships panics and returned errors to an external error
+// tracker (Sentry or Rollbar) with a stack trace, the Lambda request ID and
+// the release (git sha) that was deployed. Messages are scrubbed of common
+// PII before they leave the function.
+package errreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/middleware"
+)
+
+// Report is the tracker-neutral description of a captured error.
+type Report struct {
+	Type        string
+	Message     string
+	Frames      []Frame
+	Panic       bool
+	RequestID   string
+	Function    string
+	Release     string
+	Environment string
+	Timestamp   time.Time
+}
+
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Reporter delivers a report to a tracker. Send must finish before the
+// invocation returns because the environment may be frozen afterwards.
+type Reporter interface {
+	Send(ctx context.Context, report Report) error
+}
+
+type Config struct {
+	// DSN is a Sentry DSN (https://key@host/project) or rollbar://ACCESS_TOKEN
+	DSN         string
+	SampleRate  float64
+	Release     string
+	Environment string
+}
+
+// ConfigFromEnv reads the ERROR_REPORTING_* variables set by setup and deploy.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		DSN:         os.Getenv("ERROR_REPORTING_DSN"),
+		SampleRate:  1,
+		Release:     os.Getenv("ERROR_REPORTING_RELEASE"),
+		Environment: os.Getenv("ERROR_REPORTING_ENVIRONMENT"),
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("ERROR_REPORTING_SAMPLE_RATE"), 64); err == nil {
+		cfg.SampleRate = rate
+	}
+	return cfg
+}
+
+// NewReporter picks the tracker implementation from the DSN.
+func NewReporter(dsn string) (Reporter, error) {
+	if token, ok := strings.CutPrefix(dsn, "rollbar://"); ok {
+		return &Rollbar{AccessToken: token}, nil
+	}
+	return NewSentry(dsn)
+}
+
+// Middleware reports panics (always) and returned errors (sampled). Panics
+// are re-raised after reporting so the runtime still records the failure.
+func Middleware(cfg Config, reporter Reporter) middleware.Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) (output []byte, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					// Still on the panicking goroutine's stack: skip this
+					// function and the runtime's panic frames above the
+					// panic site
+					report := newReport(ctx, cfg, fmt.Sprint(recovered), "panic", stack(2))
+					report.Panic = true
+					send(ctx, reporter, report)
+					panic(recovered)
+				}
+			}()
+
+			output, err = next.Invoke(ctx, payload)
+			if err != nil && rand.Float64() < cfg.SampleRate {
+				send(ctx, reporter, newReport(ctx, cfg, err.Error(), errorType(err), stack(1)))
+			}
+			return output, err
+		})
+	}
+}
+
+func send(ctx context.Context, reporter Reporter, report Report) {
+	// Leave the handler some of its remaining time even if the tracker hangs
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := reporter.Send(ctx, report); err != nil {
+		logging.FromContext(ctx).Warn("failed to send error report", "error", err)
+	}
+}
+
+func newReport(ctx context.Context, cfg Config, message, errType string, frames []Frame) Report {
+	report := Report{
+		Type:        errType,
+		Message:     Scrub(message),
+		Frames:      frames,
+		Function:    lambdacontext.FunctionName,
+		Release:     cfg.Release,
+		Environment: cfg.Environment,
+		Timestamp:   time.Now().UTC(),
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		report.RequestID = lc.AwsRequestID
+	}
+	return report
+}
+
+// errorType names the innermost wrapped error type, e.g. *url.Error.
+func errorType(err error) string {
+	for {
+		unwrapped := errors.Unwrap(err)
+		if unwrapped == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = unwrapped
+	}
+}
+
+// stack returns the call stack, innermost first, without the runtime's own
+// panic frames at the top. skip counts from stack itself, so stack(1) starts
+// at its caller.
+func stack(skip int) []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var result []Frame
+	for {
+		frame, more := frames.Next()
+		if len(result) > 0 || !strings.HasPrefix(frame.Function, "runtime.") {
+			result = append(result, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			return result
+		}
+	}
+}
+
+var scrubbers = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[email]"},
+	{regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]+=*`), "Bearer [token]"},
+	{regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`), "[aws-key]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`), "[card]"},
+	{regexp.MustCompile(`(?i)(password|secret|token)=[^&\s]+`), "$1=[redacted]"},
+}
+
+// Scrub masks email addresses, bearer tokens, AWS access keys, card numbers
+// and credential query parameters.
+func Scrub(message string) string {
+	for _, s := range scrubbers {
+		message = s.pattern.ReplaceAllString(message, s.replacement)
+	}
+	return message
+}
@@ -0,0 +1,57 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"example-lambda-go/internal/middleware"
+)
+
+type recorder struct{ reports []Report }
+
+func (r *recorder) Send(ctx context.Context, report Report) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func writeNilMap() {
+	var m map[string]int
+	m["a"] = 1
+}
+
+func TestPanicReportStartsAtPanicSite(t *testing.T) {
+	reporter := &recorder{}
+	handler := Middleware(Config{SampleRate: 1}, reporter)(middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		writeNilMap()
+		return nil, nil
+	}))
+	func() {
+		defer func() { recover() }()
+		handler.Invoke(context.Background(), nil)
+	}()
+
+	if len(reporter.reports) != 1 || !reporter.reports[0].Panic {
+		t.Fatalf("reports = %+v, want one panic", reporter.reports)
+	}
+	if frames := reporter.reports[0].Frames; len(frames) == 0 || !strings.HasSuffix(frames[0].Function, ".writeNilMap") {
+		t.Errorf("frames = %+v, want the panic site first", frames)
+	}
+}
+
+func TestErrorReportStartsAtMiddleware(t *testing.T) {
+	reporter := &recorder{}
+	handler := Middleware(Config{SampleRate: 1}, reporter)(middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		return nil, errors.New("token=abc failed")
+	}))
+	handler.Invoke(context.Background(), nil)
+
+	if len(reporter.reports) != 1 {
+		t.Fatalf("reports = %+v, want one", reporter.reports)
+	}
+	report := reporter.reports[0]
+	if report.Message != "token=[redacted] failed" || len(report.Frames) == 0 || !strings.Contains(report.Frames[0].Function, "errreport.Middleware") {
+		t.Errorf("report = %q with frames %+v", report.Message, report.Frames)
+	}
+}
@@ -0,0 +1,82 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const rollbarEndpoint = "https://api.rollbar.com/api/1/item/"
+
+// Rollbar sends reports to the Rollbar item API using a post_server_item token.
+type Rollbar struct {
+	AccessToken string
+	client      *http.Client
+}
+
+func (r *Rollbar) Send(ctx context.Context, report Report) error {
+	// Rollbar expects the outermost frame first
+	frames := make([]map[string]interface{}, 0, len(report.Frames))
+	for i := len(report.Frames) - 1; i >= 0; i-- {
+		frame := report.Frames[i]
+		frames = append(frames, map[string]interface{}{
+			"filename": frame.File,
+			"lineno":   frame.Line,
+			"method":   frame.Function,
+		})
+	}
+
+	level := "error"
+	if report.Panic {
+		level = "critical"
+	}
+
+	item := map[string]interface{}{
+		"data": map[string]interface{}{
+			"environment":  report.Environment,
+			"level":        level,
+			"timestamp":    report.Timestamp.Unix(),
+			"platform":     "go",
+			"language":     "go",
+			"code_version": report.Release,
+			"body": map[string]interface{}{
+				"trace": map[string]interface{}{
+					"frames":    frames,
+					"exception": map[string]string{"class": report.Type, "message": report.Message},
+				},
+			},
+			"custom": map[string]string{
+				"request_id": report.RequestID,
+				"function":   report.Function,
+			},
+		},
+	}
+
+	body, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rollbarEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rollbar-Access-Token", r.AccessToken)
+
+	client := r.client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("rollbar returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Sentry sends reports to the store endpoint derived from a Sentry DSN.
+type Sentry struct {
+	endpoint  string
+	publicKey string
+	client    *http.Client
+}
+
+func NewSentry(dsn string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN")
+	}
+	projectID := strings.TrimPrefix(u.Path, "/")
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+	return &Sentry{
+		endpoint:  fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, projectID),
+		publicKey: u.User.Username(),
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+func (s *Sentry) Send(ctx context.Context, report Report) error {
+	// Sentry expects the outermost frame first
+	frames := make([]map[string]interface{}, 0, len(report.Frames))
+	for i := len(report.Frames) - 1; i >= 0; i-- {
+		frame := report.Frames[i]
+		frames = append(frames, map[string]interface{}{
+			"function": frame.Function,
+			"filename": frame.File,
+			"lineno":   frame.Line,
+			"in_app":   !strings.Contains(frame.File, "/pkg/mod/") && !strings.HasPrefix(frame.Function, "runtime."),
+		})
+	}
+
+	level := "error"
+	if report.Panic {
+		level = "fatal"
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   report.Timestamp.Format(time.RFC3339),
+		"level":       level,
+		"platform":    "go",
+		"release":     report.Release,
+		"environment": report.Environment,
+		"tags": map[string]string{
+			"request_id": report.RequestID,
+			"function":   report.Function,
+		},
+		"exception": map[string]interface{}{
+			"values": []map[string]interface{}{{
+				"type":       report.Type,
+				"value":      report.Message,
+				"stacktrace": map[string]interface{}{"frames": frames},
+			}},
+		},
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=lambda-template/1.0, sentry_key=%s", s.publicKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package events
+
+// Catalog lists every event type this project publishes. deploy generates a
+// JSON Schema for each entry and registers it as <source>@<DetailType>.
+var Catalog = []Event{
+	GreetingSent{},
+}
+
+// GreetingSent is published by the sample handler after it builds a greeting.
+type GreetingSent struct {
+	Name     string `json:"name"`
+	Greeting string `json:"greeting"`
+}
+
+func (GreetingSent) DetailType() string { return "GreetingSent" }
@@ -0,0 +1,142 @@
+// Package events publishes typed domain events to an EventBridge bus.
+//
+// Every event type implements Event and is listed in Catalog so deploy can
+// register its JSON Schema in the EventBridge Schema Registry.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+
+	"example-lambda-go/internal/metrics"
+)
+
+const metricsNamespace = "LambdaTemplate/Events"
+
+// PutEventsRequest accepts at most 10 entries.
+const maxBatchSize = 10
+
+// Event is implemented by every type published through a Publisher. The
+// detail type becomes the EventBridge detail-type and the schema name suffix.
+type Event interface {
+	DetailType() string
+}
+
+// PutEventsAPI is the subset of the EventBridge client used by Publisher.
+type PutEventsAPI interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+type Publisher struct {
+	client      PutEventsAPI
+	busName     string
+	source      string
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func NewPublisher(client PutEventsAPI, busName, source string) *Publisher {
+	return &Publisher{
+		client:      client,
+		busName:     busName,
+		source:      source,
+		MaxAttempts: 3,
+		Backoff:     100 * time.Millisecond,
+	}
+}
+
+// NewPublisherFromEnv reads the bus and source from EVENT_BUS_NAME and
+// EVENT_SOURCE, which setup and deploy derive from the events config section.
+// It returns nil when no bus is configured.
+func NewPublisherFromEnv(client PutEventsAPI) *Publisher {
+	busName := os.Getenv("EVENT_BUS_NAME")
+	if busName == "" {
+		return nil
+	}
+	return NewPublisher(client, busName, os.Getenv("EVENT_SOURCE"))
+}
+
+// Publish sends the events in batches, retrying entries EventBridge reports
+// as failed with exponential backoff.
+func (p *Publisher) Publish(ctx context.Context, events ...Event) error {
+	entries := make([]types.PutEventsRequestEntry, 0, len(events))
+	for _, event := range events {
+		detail, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s event: %v", event.DetailType(), err)
+		}
+		entries = append(entries, types.PutEventsRequestEntry{
+			EventBusName: aws.String(p.busName),
+			Source:       aws.String(p.source),
+			DetailType:   aws.String(event.DetailType()),
+			Detail:       aws.String(string(detail)),
+		})
+	}
+
+	for start := 0; start < len(entries); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		if err := p.publishBatch(ctx, entries[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Publisher) publishBatch(ctx context.Context, entries []types.PutEventsRequestEntry) error {
+	started := time.Now()
+	pending := entries
+	backoff := p.Backoff
+	var lastErr error
+
+	for attempt := 1; attempt <= p.MaxAttempts && len(pending) > 0; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		output, err := p.client.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: pending})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Entries are returned in request order; keep only the ones that failed
+		var failed []types.PutEventsRequestEntry
+		for i, result := range output.Entries {
+			if result.ErrorCode != nil {
+				failed = append(failed, pending[i])
+				lastErr = fmt.Errorf("%s: %s", aws.ToString(result.ErrorCode), aws.ToString(result.ErrorMessage))
+			}
+		}
+		pending = failed
+	}
+
+	p.emit(ctx, len(entries)-len(pending), len(pending), time.Since(started))
+
+	if len(pending) > 0 {
+		return fmt.Errorf("failed to publish %d of %d events to %s: %v", len(pending), len(entries), p.busName, lastErr)
+	}
+	return nil
+}
+
+func (p *Publisher) emit(ctx context.Context, published, failed int, elapsed time.Duration) {
+	metrics.Emit(ctx, metricsNamespace, map[string]string{"EventBus": p.busName},
+		metrics.Metric{Name: "EventsPublished", Value: float64(published), Unit: metrics.Count},
+		metrics.Metric{Name: "EventsFailed", Value: float64(failed), Unit: metrics.Count},
+		metrics.Metric{Name: "PublishLatency", Value: float64(elapsed.Milliseconds()), Unit: metrics.Milliseconds},
+	)
+}
@@ -0,0 +1,246 @@
+// Package explain describes what a command is about to do before it does it:
+// the AWS API operations in the order they are called, the IAM permissions
+// each one needs and the config values feeding it. It is meant for security
+// reviews and for working out which permission a restrictive role is missing
+// after an AccessDenied error.
+package explain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"example-lambda-go/internal/registryauth"
+)
+
+// AccountID returns the account for the ARNs in a plan, or a placeholder
+// when the credentials can't be used, which is often why someone asks for
+// the explanation.
+func AccountID(ctx context.Context, client registryauth.IdentityAPI) string {
+	awsAccountID, err := registryauth.AccountID(ctx, client)
+	if err != nil {
+		return "ACCOUNT_ID"
+	}
+	return awsAccountID
+}
+
+type Step struct {
+	// Operation is the API call as service:Action, e.g. iam:CreateRole
+	Operation string
+	Reason    string
+	// When describes the condition under which the call is made, e.g.
+	// "if the role does not exist"; empty means always
+	When      string
+	Resources []string
+	// Extra lists permissions checked besides the operation's own
+	Extra  []Permission
+	Config []string
+	// Values are what the call passes besides config, e.g. the image URI
+	Values []string
+	// Unrestricted operations need no IAM permission, e.g. sts:GetCallerIdentity
+	Unrestricted bool
+}
+
+type Permission struct {
+	Action    string
+	Resources []string
+}
+
+type Plan struct {
+	Command string
+	Steps   []*Step
+}
+
+func New(command string) *Plan {
+	return &Plan{Command: command}
+}
+
+// Call adds an operation to the plan. It needs the permission of the same
+// name on the resources given with On; use Needs for any others.
+func (p *Plan) Call(operation, reason string) *Step {
+	step := &Step{Operation: operation, Reason: reason}
+	p.Steps = append(p.Steps, step)
+	return step
+}
+
+// On sets the resources the operation acts on. Without any, its permission
+// is needed on "*".
+func (s *Step) On(resources ...string) *Step {
+	s.Resources = append(s.Resources, resources...)
+	return s
+}
+
+// Needs adds a permission the operation checks besides its own, such as
+// iam:PassRole on the execution role for lambda:CreateFunction. Without
+// resources it applies to the operation's resources.
+func (s *Step) Needs(action string, resources ...string) *Step {
+	s.Extra = append(s.Extra, Permission{Action: action, Resources: resources})
+	return s
+}
+
+// NoPermission marks an operation any authenticated caller may make.
+func (s *Step) NoPermission() *Step {
+	s.Unrestricted = true
+	return s
+}
+
+// From records a config value the call depends on.
+func (s *Step) From(key string, value interface{}) *Step {
+	s.Config = append(s.Config, fmt.Sprintf("%s=%v", key, value))
+	return s
+}
+
+// With records a value the call passes that does not come straight from
+// config, such as the image URI or the role ARN.
+func (s *Step) With(key string, value interface{}) *Step {
+	s.Values = append(s.Values, fmt.Sprintf("%s=%v", key, value))
+	return s
+}
+
+// If records the condition under which the call is made.
+func (s *Step) If(condition string) *Step {
+	s.When = condition
+	return s
+}
+
+// readOnlyPrefixes start the names of the AWS actions that change nothing.
+var readOnlyPrefixes = []string{"Get", "Describe", "List", "BatchGet", "BatchCheck"}
+
+// Mutates reports whether the operation changes anything in the account.
+func (s *Step) Mutates() bool {
+	_, action, _ := strings.Cut(s.Operation, ":")
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(action, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve settles the condition of the operation's steps made under when,
+// once a dry run has looked at the account: they are dropped if happens is
+// false and become unconditional otherwise.
+func (p *Plan) Resolve(operation, when string, happens bool) {
+	steps := p.Steps[:0]
+	for _, step := range p.Steps {
+		if step.Operation == operation && step.When == when {
+			if !happens {
+				continue
+			}
+			step.When = ""
+		}
+		steps = append(steps, step)
+	}
+	p.Steps = steps
+}
+
+// permissions returns what the step needs, resolving default resources.
+func (s *Step) permissions() []Permission {
+	resources := s.Resources
+	if len(resources) == 0 {
+		resources = []string{"*"}
+	}
+	var permissions []Permission
+	if !s.Unrestricted {
+		permissions = append(permissions, Permission{Action: s.Operation, Resources: resources})
+	}
+	for _, extra := range s.Extra {
+		if len(extra.Resources) == 0 {
+			extra.Resources = resources
+		}
+		permissions = append(permissions, extra)
+	}
+	return permissions
+}
+
+// Permissions returns each required permission with the resources it is
+// needed on, sorted by action.
+func (p *Plan) Permissions() []Permission {
+	resources := map[string]map[string]bool{}
+	for _, step := range p.Steps {
+		for _, permission := range step.permissions() {
+			if resources[permission.Action] == nil {
+				resources[permission.Action] = map[string]bool{}
+			}
+			for _, resource := range permission.Resources {
+				resources[permission.Action][resource] = true
+			}
+		}
+	}
+
+	permissions := make([]Permission, 0, len(resources))
+	for action, set := range resources {
+		permission := Permission{Action: action}
+		for resource := range set {
+			permission.Resources = append(permission.Resources, resource)
+		}
+		sort.Strings(permission.Resources)
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Action < permissions[j].Action })
+	return permissions
+}
+
+// Print writes the plan followed by the permissions it needs.
+func (p *Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "%s will call, in order:\n\n", p.Command)
+	for i, step := range p.Steps {
+		printStep(w, i+1, step)
+		for _, extra := range step.Extra {
+			on := ""
+			if len(extra.Resources) > 0 {
+				on = " on " + strings.Join(extra.Resources, ", ")
+			}
+			fmt.Fprintf(w, "     also needs:  %s%s\n", extra.Action, on)
+		}
+		if step.Unrestricted {
+			fmt.Fprintf(w, "     needs no IAM permission\n")
+		}
+	}
+
+	fmt.Fprintf(w, "\nIAM permissions required:\n\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, permission := range p.Permissions() {
+		fmt.Fprintf(tw, "  %s\t%s\n", permission.Action, strings.Join(permission.Resources, ", "))
+	}
+	tw.Flush()
+}
+
+// PrintChanges writes only the steps that change something, which is what a
+// dry run reports; the lookups between them are left out.
+func (p *Plan) PrintChanges(w io.Writer) {
+	var changes []*Step
+	for _, step := range p.Steps {
+		if step.Mutates() {
+			changes = append(changes, step)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "Dry run: %s would change nothing.\n", p.Command)
+		return
+	}
+	fmt.Fprintf(w, "Dry run: nothing was changed. %s would, in order:\n\n", p.Command)
+	for i, step := range changes {
+		printStep(w, i+1, step)
+	}
+}
+
+func printStep(w io.Writer, n int, step *Step) {
+	fmt.Fprintf(w, "%3d. %s: %s", n, step.Operation, step.Reason)
+	if step.When != "" {
+		fmt.Fprintf(w, " (%s)", step.When)
+	}
+	fmt.Fprintln(w)
+	if len(step.Resources) > 0 {
+		fmt.Fprintf(w, "     resources:   %s\n", strings.Join(step.Resources, ", "))
+	}
+	if len(step.Values) > 0 {
+		fmt.Fprintf(w, "     with:        %s\n", strings.Join(step.Values, ", "))
+	}
+	if len(step.Config) > 0 {
+		fmt.Fprintf(w, "     config:      %s\n", strings.Join(step.Config, ", "))
+	}
+}
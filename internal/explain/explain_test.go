@@ -0,0 +1,98 @@
+package explain
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	p := New("setup")
+	p.Call("sts:GetCallerIdentity", "account ID").NoPermission()
+	p.Call("iam:GetRole", "check role").On("arn:role")
+	p.Call("ecr:GetAuthorizationToken", "login")
+	p.Call("lambda:CreateFunction", "create").
+		On("arn:function").
+		Needs("iam:PassRole", "arn:role").
+		Needs("ecr:BatchGetImage", "arn:repo").
+		Needs("lambda:TagResource")
+	p.Call("iam:GetRole", "check again").On("arn:other-role")
+
+	want := []Permission{
+		{Action: "ecr:BatchGetImage", Resources: []string{"arn:repo"}},
+		{Action: "ecr:GetAuthorizationToken", Resources: []string{"*"}},
+		{Action: "iam:GetRole", Resources: []string{"arn:other-role", "arn:role"}},
+		{Action: "iam:PassRole", Resources: []string{"arn:role"}},
+		{Action: "lambda:CreateFunction", Resources: []string{"arn:function"}},
+		{Action: "lambda:TagResource", Resources: []string{"arn:function"}},
+	}
+	if got := p.Permissions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Permissions() = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	p := New("delete")
+	p.Call("lambda:DeleteFunction", "delete the function").
+		On("arn:function").From("lambda.function_name", "hello").If("always")
+
+	var buf bytes.Buffer
+	p.Print(&buf)
+	for _, want := range []string{
+		"delete will call, in order:",
+		"  1. lambda:DeleteFunction: delete the function (always)",
+		"resources:   arn:function",
+		"config:      lambda.function_name=hello",
+		"IAM permissions required:",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintChanges(t *testing.T) {
+	p := New("setup")
+	p.Call("iam:GetRole", "check role").On("arn:role")
+	p.Call("iam:CreateRole", "create role").On("arn:role").If("if the role does not exist")
+	p.Call("ecr:GetAuthorizationToken", "login")
+	p.Call("lambda:CreateFunction", "create").
+		On("arn:function").With("image", "repo:latest").From("lambda.function_name", "hello").If("if missing")
+	p.Resolve("iam:CreateRole", "if the role does not exist", false)
+	p.Resolve("lambda:CreateFunction", "if missing", true)
+
+	var buf bytes.Buffer
+	p.PrintChanges(&buf)
+	want := `Dry run: nothing was changed. setup would, in order:
+
+  1. lambda:CreateFunction: create
+     resources:   arn:function
+     with:        image=repo:latest
+     config:      lambda.function_name=hello
+`
+	if buf.String() != want {
+		t.Errorf("PrintChanges() wrote:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	setup := New("setup")
+	setup.Call("ecr:CreateRepository", "create").On("arn:repo")
+	setup.Call("ecr:GetAuthorizationToken", "login")
+	deploy := New("deploy")
+	deploy.Call("ecr:DescribeRepositories", "look up").On("arn:repo")
+	deploy.Call("lambda:UpdateFunctionCode", "update").On("arn:function").Needs("ecr:BatchGetImage", "arn:repo")
+
+	want := PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []Statement{
+			{Effect: "Allow", Action: []string{"ecr:BatchGetImage", "ecr:CreateRepository", "ecr:DescribeRepositories"}, Resource: []string{"arn:repo"}},
+			{Effect: "Allow", Action: []string{"ecr:GetAuthorizationToken"}, Resource: []string{"*"}},
+			{Effect: "Allow", Action: []string{"lambda:UpdateFunctionCode"}, Resource: []string{"arn:function"}},
+		},
+	}
+	if got := Policy(setup, deploy); !reflect.DeepEqual(got, want) {
+		t.Errorf("Policy() = %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,41 @@
+package explain
+
+import (
+	"strings"
+)
+
+// PolicyDocument is an IAM identity policy, marshalled as AWS expects it.
+type PolicyDocument struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+type Statement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+// Policy returns a policy allowing exactly what the plans need. Actions
+// needed on the same resources share a statement, which keeps the document
+// under the size limit for managed policies.
+func Policy(plans ...*Plan) PolicyDocument {
+	merged := &Plan{}
+	for _, p := range plans {
+		merged.Steps = append(merged.Steps, p.Steps...)
+	}
+
+	document := PolicyDocument{Version: "2012-10-17", Statement: []Statement{}}
+	statements := map[string]int{}
+	for _, permission := range merged.Permissions() {
+		key := strings.Join(permission.Resources, "\n")
+		i, ok := statements[key]
+		if !ok {
+			i = len(document.Statement)
+			statements[key] = i
+			document.Statement = append(document.Statement, Statement{Effect: "Allow", Resource: permission.Resources})
+		}
+		document.Statement[i].Action = append(document.Statement[i].Action, permission.Action)
+	}
+	return document
+}
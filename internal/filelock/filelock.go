@@ -0,0 +1,68 @@
+// Package filelock guards the local state files the tooling keeps next to
+// config.yaml, so two runs from the same checkout (a deploy started in a second
+// terminal, an IDE task) do not interleave. It uses flock on Unix and
+// LockFileEx on Windows; both locks are released by the OS if the process
+// dies, so a crashed run never leaves a stale lock behind.
+package filelock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned by TryLock when another process holds the lock.
+var ErrLocked = errors.New("lock is held by another process")
+
+type Lock struct {
+	file *os.File
+}
+
+// TryLock takes an exclusive lock on path, creating it and its directory if
+// needed, and fails with ErrLocked instead of waiting.
+func TryLock(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLock(file); err != nil {
+		file.Close()
+		if errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("%s: %w", path, ErrLocked)
+		}
+		return nil, fmt.Errorf("error locking %s: %v", path, err)
+	}
+	return &Lock{file: file}, nil
+}
+
+// Wait takes the lock like TryLock, but while another process holds it,
+// retries until it is released or ctx is done.
+func Wait(ctx context.Context, path string) (*Lock, error) {
+	for {
+		lock, err := TryLock(path)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Unlock releases the lock. The file is left in place; removing it would let
+// a waiting process lock a file that a third one then recreates.
+func (l *Lock) Unlock() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,51 @@
+package filelock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "deploy.lock")
+
+	lock, err := TryLock(path)
+	if err != nil {
+		t.Fatalf("first TryLock: %v", err)
+	}
+	if _, err := TryLock(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second TryLock = %v, want ErrLocked", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	lock, err = TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock after Unlock: %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.lock")
+	lock, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	if _, err := Wait(ctx, path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Wait while held = %v, want ErrLocked once ctx is done", err)
+	}
+
+	time.AfterFunc(150*time.Millisecond, func() { lock.Unlock() })
+	lock, err = Wait(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Wait after release: %v", err)
+	}
+	lock.Unlock()
+}
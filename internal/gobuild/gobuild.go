@@ -0,0 +1,201 @@
+// Package gobuild turns the build.go section of config.yaml into the build
+// arguments the Dockerfile compiles the handler with, and the platform and
+// Lambda architecture the image targets.
+package gobuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// Config is the build.go section of config.yaml.
+type Config struct {
+	// Tags are passed to go build -tags, e.g. [prod, otel]
+	Tags    []string `yaml:"tags"`
+	LDFlags string   `yaml:"ldflags"`
+	// CGO enables cgo. The build stage runs on the build host, so this only
+	// works when the host has the same architecture as GOARCH.
+	CGO bool `yaml:"cgo"`
+	// GOARCH is amd64 (default) or arm64; arm64 images run on Graviton
+	GOARCH  string `yaml:"goarch"`
+	GOFLAGS string `yaml:"goflags"`
+
+	// GOPRIVATE lists module path patterns fetched straight from their
+	// repositories instead of the public proxy, e.g. [github.com/acme/*]
+	GOPRIVATE []string `yaml:"goprivate"`
+	// Netrc is a .netrc file with credentials for the private module hosts,
+	// e.g. ~/.netrc. It is mounted as a BuildKit secret for the go build step
+	// only, so it never ends up in a layer or the image history.
+	Netrc string `yaml:"netrc"`
+	// TokenEnv names an environment variable holding an access token for
+	// TokenHost (default github.com); for CI runners without a .netrc
+	TokenEnv  string `yaml:"token_env"`
+	TokenHost string `yaml:"token_host"`
+	// Vendor runs go mod vendor on the host, with the host's credentials,
+	// and builds from vendor/ so the image build needs none
+	Vendor bool `yaml:"vendor"`
+}
+
+// Validate reports settings Lambda or the Dockerfile cannot use.
+func (c Config) Validate() error {
+	switch c.GOARCH {
+	case "", "amd64", "arm64":
+	default:
+		return fmt.Errorf("build.go.goarch must be amd64 or arm64, not %q", c.GOARCH)
+	}
+	for _, tag := range c.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t") {
+			return fmt.Errorf("build.go.tags: invalid tag %q", tag)
+		}
+	}
+	if c.Netrc != "" && c.TokenEnv != "" {
+		return fmt.Errorf("build.go.netrc and build.go.token_env both give credentials; set one")
+	}
+	return nil
+}
+
+// Arch is the GOARCH the handler is compiled for.
+func (c Config) Arch() string {
+	if c.GOARCH == "" {
+		return "amd64"
+	}
+	return c.GOARCH
+}
+
+// Platform is the docker build --platform for the image.
+func (c Config) Platform() string {
+	return "linux/" + c.Arch()
+}
+
+// LambdaArchitecture is the function architecture the image runs on, as
+// create-function and update-function-code spell it.
+func (c Config) LambdaArchitecture() string {
+	if c.Arch() == "arm64" {
+		return "arm64"
+	}
+	return "x86_64"
+}
+
+// BuildArgs returns the Docker build arguments for the settings that are set;
+// the Dockerfile defaults cover the rest.
+func (c Config) BuildArgs() map[string]string {
+	args := map[string]string{}
+	if len(c.Tags) > 0 {
+		args["GO_TAGS"] = strings.Join(c.Tags, ",")
+	}
+	if c.LDFlags != "" {
+		args["GO_LDFLAGS"] = c.LDFlags
+	}
+	if c.CGO {
+		args["CGO_ENABLED"] = "1"
+	}
+	goflags := c.GOFLAGS
+	if c.Vendor {
+		goflags = strings.TrimSpace(goflags + " -mod=vendor")
+	}
+	if goflags != "" {
+		args["GOFLAGS"] = goflags
+	}
+	if len(c.GOPRIVATE) > 0 {
+		args["GOPRIVATE"] = strings.Join(c.GOPRIVATE, ",")
+	}
+	return args
+}
+
+// Prepare gets a docker build of the handler ready: it vendors modules or
+// provides the private module credentials, and returns the build options
+// with a function that removes whatever it created.
+func (c Config) Prepare(w io.Writer, handler string) (hostexec.BuildOptions, func(), error) {
+	opts := hostexec.BuildOptions{Platform: c.Platform(), Args: c.BuildArgs()}
+	if handler != "" {
+		opts.Args["HANDLER"] = handler
+	}
+	if c.Vendor {
+		cleanup, err := vendor(w)
+		return opts, cleanup, err
+	}
+
+	switch {
+	case c.Netrc != "":
+		path, err := expandHome(c.Netrc)
+		if err != nil {
+			return opts, func() {}, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return opts, func() {}, fmt.Errorf("build.go.netrc: %v", err)
+		}
+		opts.Secrets = []hostexec.Secret{{ID: "netrc", Src: path}}
+	case c.TokenEnv != "":
+		token := os.Getenv(c.TokenEnv)
+		if token == "" {
+			return opts, func() {}, fmt.Errorf("build.go.token_env: %s is not set", c.TokenEnv)
+		}
+		path, err := writeNetrc(c.tokenHost(), token)
+		if err != nil {
+			return opts, func() {}, err
+		}
+		opts.Secrets = []hostexec.Secret{{ID: "netrc", Src: path}}
+		return opts, func() { os.Remove(path) }, nil
+	}
+	return opts, func() {}, nil
+}
+
+func (c Config) tokenHost() string {
+	if c.TokenHost == "" {
+		return "github.com"
+	}
+	return c.TokenHost
+}
+
+// vendor runs go mod vendor, returning a function that removes vendor/
+// again unless it was there already, i.e. checked in.
+func vendor(w io.Writer) (func(), error) {
+	_, err := os.Stat("vendor")
+	existed := err == nil
+
+	cmd := exec.Command("go", "mod", "vendor")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := hostexec.Run(cmd); err != nil {
+		return func() {}, fmt.Errorf("go mod vendor failed: %v", err)
+	}
+	if existed {
+		return func() {}, nil
+	}
+	return func() { os.RemoveAll("vendor") }, nil
+}
+
+// writeNetrc writes a .netrc for token to a private temporary file. Git
+// hosts accept a token as the password with any login.
+func writeNetrc(host, token string) (string, error) {
+	f, err := os.CreateTemp("", "netrc")
+	if err != nil {
+		return "", fmt.Errorf("error creating netrc: %v", err)
+	}
+	_, err = fmt.Fprintf(f, "machine %s\nlogin x-access-token\npassword %s\n", host, token)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error writing netrc: %v", err)
+	}
+	return f.Name(), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("build.go.netrc: %v", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
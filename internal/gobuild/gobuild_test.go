@@ -0,0 +1,106 @@
+package gobuild
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildArgs(t *testing.T) {
+	c := Config{Tags: []string{"prod", "otel"}, LDFlags: "-s -w -X main.version=1.2.3", CGO: true, GOFLAGS: "-trimpath"}
+	want := map[string]string{
+		"GO_TAGS":     "prod,otel",
+		"GO_LDFLAGS":  "-s -w -X main.version=1.2.3",
+		"CGO_ENABLED": "1",
+		"GOFLAGS":     "-trimpath",
+	}
+	if got := c.BuildArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildArgs() = %v, want %v", got, want)
+	}
+	if got := (Config{}).BuildArgs(); len(got) != 0 {
+		t.Errorf("BuildArgs() of an empty config = %v, want none", got)
+	}
+}
+
+func TestArchitecture(t *testing.T) {
+	tests := []struct {
+		goarch, platform, lambda string
+	}{
+		{"", "linux/amd64", "x86_64"},
+		{"amd64", "linux/amd64", "x86_64"},
+		{"arm64", "linux/arm64", "arm64"},
+	}
+	for _, tt := range tests {
+		c := Config{GOARCH: tt.goarch}
+		if got := c.Platform(); got != tt.platform {
+			t.Errorf("Platform() for %q = %q, want %q", tt.goarch, got, tt.platform)
+		}
+		if got := c.LambdaArchitecture(); got != tt.lambda {
+			t.Errorf("LambdaArchitecture() for %q = %q, want %q", tt.goarch, got, tt.lambda)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Config{GOARCH: "386"}).Validate(); err == nil {
+		t.Error("goarch 386 accepted")
+	}
+	if err := (Config{Tags: []string{"a,b"}}).Validate(); err == nil {
+		t.Error("tag with a comma accepted")
+	}
+	if err := (Config{GOARCH: "arm64", Tags: []string{"prod"}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestPrepareToken(t *testing.T) {
+	t.Setenv("TEST_MODULE_TOKEN", "s3cret")
+	c := Config{GOPRIVATE: []string{"github.com/acme/*"}, TokenEnv: "TEST_MODULE_TOKEN", TokenHost: "git.acme.dev"}
+	opts, cleanup, err := c.Prepare(io.Discard, "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opts.Args["GOPRIVATE"] != "github.com/acme/*" || opts.Args["HANDLER"] != "export" {
+		t.Errorf("build args = %v", opts.Args)
+	}
+	for name, value := range opts.Args {
+		if strings.Contains(value, "s3cret") {
+			t.Errorf("build arg %s carries the token", name)
+		}
+	}
+	if len(opts.Secrets) != 1 || opts.Secrets[0].ID != "netrc" {
+		t.Fatalf("secrets = %v, want the netrc", opts.Secrets)
+	}
+	path := opts.Secrets[0].Src
+	netrc, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "machine git.acme.dev\nlogin x-access-token\npassword s3cret\n"; string(netrc) != want {
+		t.Errorf("netrc = %q, want %q", netrc, want)
+	}
+
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("netrc left behind after cleanup: %v", err)
+	}
+}
+
+func TestPrepareMissingToken(t *testing.T) {
+	t.Setenv("TEST_MODULE_TOKEN", "")
+	_, cleanup, err := Config{TokenEnv: "TEST_MODULE_TOKEN"}.Prepare(io.Discard, "")
+	defer cleanup()
+	if err == nil {
+		t.Error("Prepare() succeeded without the token")
+	}
+}
+
+func TestVendorFlag(t *testing.T) {
+	args := Config{Vendor: true, GOFLAGS: "-trimpath"}.BuildArgs()
+	if args["GOFLAGS"] != "-trimpath -mod=vendor" {
+		t.Errorf("GOFLAGS = %q, want -mod=vendor added", args["GOFLAGS"])
+	}
+}
@@ -0,0 +1,169 @@
+// Package health implements the /healthz and /readyz contract shared by every
+// HTTP-mode function: /healthz reports liveness with build information and a
+// hash of the function's configuration, /readyz additionally runs the
+// registered dependency checks and answers 503 when any of them fails.
+package health
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const checkTimeout = 2 * time.Second
+
+// HealthChecker verifies that a dependency is reachable.
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c checkerFunc) Name() string                    { return c.name }
+func (c checkerFunc) Check(ctx context.Context) error { return c.check(ctx) }
+
+// CheckerFunc adapts a function to the HealthChecker interface.
+func CheckerFunc(name string, check func(ctx context.Context) error) HealthChecker {
+	return checkerFunc{name: name, check: check}
+}
+
+type BuildInfo struct {
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+type Report struct {
+	Status     string            `json:"status"`
+	Build      BuildInfo         `json:"build"`
+	ConfigHash string            `json:"config_hash"`
+	Checks     map[string]string `json:"checks,omitempty"`
+}
+
+type Health struct {
+	mu       sync.Mutex
+	checkers []HealthChecker
+	build    BuildInfo
+	hash     string
+}
+
+func New(checkers ...HealthChecker) *Health {
+	return &Health{checkers: checkers, build: ReadBuildInfo(), hash: configHash()}
+}
+
+// Add registers another dependency check.
+func (h *Health) Add(checker HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers = append(h.checkers, checker)
+}
+
+// Register mounts /healthz and /readyz on mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.serveHealth)
+	mux.HandleFunc("/readyz", h.serveReady)
+}
+
+func (h *Health) serveHealth(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: "ok", Build: h.build, ConfigHash: h.hash})
+}
+
+func (h *Health) serveReady(w http.ResponseWriter, r *http.Request) {
+	report := Report{Status: "ok", Build: h.build, ConfigHash: h.hash, Checks: h.Check(r.Context())}
+	status := http.StatusOK
+	for _, result := range report.Checks {
+		if result != "ok" {
+			report.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeReport(w, status, report)
+}
+
+// Check runs all dependency checks concurrently and returns "ok" or the error
+// message for each.
+func (h *Health) Check(ctx context.Context) map[string]string {
+	h.mu.Lock()
+	checkers := append([]HealthChecker(nil), h.checkers...)
+	h.mu.Unlock()
+
+	results := make(map[string]string, len(checkers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			result := "ok"
+			if err := checker.Check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[checker.Name()] = result
+			mu.Unlock()
+		}(checker)
+	}
+	wg.Wait()
+	return results
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ReadBuildInfo returns what the Go linker recorded about the binary.
+func ReadBuildInfo() BuildInfo {
+	build := BuildInfo{GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	build.Version = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.BuildTime = setting.Value
+		}
+	}
+	return build
+}
+
+// configHash fingerprints the function's own environment variables (the ones
+// derived from config.yaml), ignoring those set by the Lambda runtime, so two
+// functions can be compared without exposing values.
+func configHash() string {
+	var variables []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, "AWS_") || strings.HasPrefix(name, "LAMBDA_") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+
+	sum := sha256.Sum256([]byte(strings.Join(variables, "\n")))
+	return hex.EncodeToString(sum[:])[:16]
+}
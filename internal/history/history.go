@@ -0,0 +1,208 @@
+// Package history records each deploy as a JSON line in a local file: when
+// and by whom, the commit and image that went out, and the commits since the
+// deploy before, so `lambda-template history` can answer what went out when.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// DefaultPath is used when audit.history is not set in config.yaml.
+var DefaultPath = filepath.Join(".lambda-template", "deploys.log")
+
+// maxChanges is how many commits a record keeps; a deploy after a long gap
+// is summarized by its newest ones.
+const maxChanges = 200
+
+type Record struct {
+	Time time.Time `json:"time"`
+	// Actor is the caller's ARN as reported by STS
+	Actor    string `json:"actor"`
+	Function string `json:"function"`
+	Commit   string `json:"commit,omitempty"`
+	// Previous is the commit of the deploy before, which Changes start after
+	Previous string   `json:"previous,omitempty"`
+	Image    string   `json:"image,omitempty"`
+	Changes  []Change `json:"changes,omitempty"`
+	// Omitted is the number of commits left out of Changes
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// Change is one commit that went out.
+type Change struct {
+	Commit  string `json:"commit"`
+	Subject string `json:"subject"`
+}
+
+// Append adds the record to the history at path, creating it if needed.
+func Append(path string, r Record) error {
+	if path == "" {
+		path = DefaultPath
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding deploy record: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating deploy history directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening deploy history: %v", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing deploy history: %v", err)
+	}
+	return f.Close()
+}
+
+// Read returns the function's records at path, newest first. A history that
+// does not exist yet has none.
+func Read(path, function string) ([]Record, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening deploy history: %v", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if r.Function == function {
+			records = append([]Record{r}, records...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading deploy history: %v", err)
+	}
+	return records, nil
+}
+
+// Collect lists the commits after previous up to head, newest first, from
+// the git checkout. Without a previous commit, or when git no longer knows
+// it, only head is listed.
+func Collect(previous, head string) ([]Change, int, error) {
+	logCmd := exec.Command("git", "log", "--format=%H%x09%s", previous+".."+head)
+	if previous == "" {
+		logCmd = exec.Command("git", "log", "--format=%H%x09%s", "-n", "1", head)
+	}
+	output, err := hostexec.Output(logCmd)
+	if err != nil && previous != "" {
+		return Collect("", head)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing commits: %v", err)
+	}
+	var changes []Change
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if sha, subject, ok := strings.Cut(line, "\t"); ok {
+			changes = append(changes, Change{Commit: sha, Subject: subject})
+		}
+	}
+	omitted := 0
+	if len(changes) > maxChanges {
+		omitted = len(changes) - maxChanges
+		changes = changes[:maxChanges]
+	}
+	return changes, omitted, nil
+}
+
+// Summary is the changes as short hashes and subjects, newest first, cut to
+// limit bytes with a count of the commits that did not fit.
+func (r Record) Summary(limit int) string {
+	var b strings.Builder
+	for i, c := range r.Changes {
+		entry := ShortCommit(c.Commit) + " " + c.Subject
+		if i > 0 {
+			entry = "; " + entry
+		}
+		left := len(r.Changes) - i - 1 + r.Omitted
+		more := ""
+		if left > 0 {
+			more = fmt.Sprintf(" (+%d more)", left)
+		}
+		if b.Len()+len(entry)+len(more) > limit {
+			if i == 0 {
+				// The newest subject is cut rather than left out
+				b.WriteString(truncate(entry, limit-len(more)))
+				b.WriteString(more)
+			} else {
+				fmt.Fprintf(&b, " (+%d more)", left+1)
+			}
+			return b.String()
+		}
+		b.WriteString(entry)
+	}
+	if r.Omitted > 0 {
+		fmt.Fprintf(&b, " (+%d more)", r.Omitted)
+	}
+	return b.String()
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ShortCommit is the abbreviated hash git prints.
+func ShortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// TagValue makes s a valid tag value: characters AWS does not allow in tags
+// become spaces, and it is cut to 256 characters.
+func TagValue(s string) string {
+	mapped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) || strings.ContainsRune("_.:/=+-@", r) {
+			return r
+		}
+		return ' '
+	}, s)
+	mapped = strings.Join(strings.Fields(mapped), " ")
+	if runes := []rune(mapped); len(runes) > 256 {
+		mapped = string(runes[:256])
+	}
+	return mapped
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploys.log")
+	if records, err := Read(path, "hello"); err != nil || records != nil {
+		t.Fatalf("Read of a missing history = %v, %v", records, err)
+	}
+	for _, r := range []Record{
+		{Function: "hello", Commit: "aaa", Time: time.Unix(1, 0).UTC()},
+		{Function: "other", Commit: "bbb"},
+		{Function: "hello", Commit: "ccc", Previous: "aaa", Changes: []Change{{Commit: "ccc", Subject: "Fix greeting"}}},
+	} {
+		if err := Append(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := Read(path, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Commit != "ccc" || records[1].Commit != "aaa" || records[0].Time.IsZero() {
+		t.Errorf("Read = %+v, want hello's two deploys, newest first", records)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	fake := hostexec.NewFake()
+	previous := hostexec.Default
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default = previous })
+
+	fake.On([]string{"git", "log", "--format=%H%x09%s", "aaa..ccc"}, hostexec.Response{Output: []byte("ccc\tFix greeting\nbbb\tAdd tabs\tto output\n")})
+	changes, omitted, err := Collect("aaa", "ccc")
+	want := []Change{{"ccc", "Fix greeting"}, {"bbb", "Add tabs\tto output"}}
+	if err != nil || omitted != 0 || !reflect.DeepEqual(changes, want) {
+		t.Errorf("Collect = %v, %d, %v; want %v", changes, omitted, err, want)
+	}
+
+	// A previous commit git no longer knows, e.g. after a rebase, gives head alone
+	fake.On([]string{"git", "log", "--format=%H%x09%s", "gone..ccc"}, hostexec.Response{Err: errors.New("exit status 128")})
+	fake.On([]string{"git", "log", "--format=%H%x09%s", "-n", "1", "ccc"}, hostexec.Response{Output: []byte("ccc\tFix greeting\n")})
+	if changes, _, err := Collect("gone", "ccc"); err != nil || len(changes) != 1 {
+		t.Errorf("Collect with an unknown previous commit = %v, %v; want head alone", changes, err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	r := Record{Changes: []Change{
+		{"1111111aaaa", "Fix greeting"},
+		{"2222222bbbb", "Add tabs"},
+		{"3333333cccc", "Bump dependencies"},
+	}}
+	for _, test := range []struct {
+		limit   int
+		omitted int
+		want    string
+	}{
+		{256, 0, "1111111 Fix greeting; 2222222 Add tabs; 3333333 Bump dependencies"},
+		{50, 0, "1111111 Fix greeting; 2222222 Add tabs (+1 more)"},
+		{30, 0, "1111111 Fix greeting (+2 more)"},
+		{20, 0, "1111111 Fi (+2 more)"},
+		{256, 5, "1111111 Fix greeting; 2222222 Add tabs; 3333333 Bump dependencies (+5 more)"},
+	} {
+		r.Omitted = test.omitted
+		got := r.Summary(test.limit)
+		if got != test.want || len(got) > test.limit {
+			t.Errorf("Summary(%d) with %d omitted = %q, want %q", test.limit, test.omitted, got, test.want)
+		}
+	}
+}
+
+func TestTagValue(t *testing.T) {
+	got := TagValue("abc1234 Fix (greeting); use `name` #12")
+	if got != "abc1234 Fix greeting use name 12" {
+		t.Errorf("TagValue = %q", got)
+	}
+	if got := TagValue(strings.Repeat("é", 300)); len([]rune(got)) != 256 {
+		t.Errorf("TagValue kept %d characters, want 256", len([]rune(got)))
+	}
+}
@@ -0,0 +1,83 @@
+package hostexec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+var (
+	awsMu    sync.Mutex
+	profiles = map[string]aws.CredentialsProvider{}
+	awsEnv   []string
+)
+
+// SetAWSEnv replaces the variables added to the environment of aws CLI
+// commands, for the settings the CLI only takes from there, such as
+// AWS_USE_FIPS_ENDPOINT. They stay out of this process's environment, so
+// one configuration's settings don't carry over to the next one loaded.
+func SetAWSEnv(env []string) {
+	awsMu.Lock()
+	defer awsMu.Unlock()
+	awsEnv = env
+}
+
+// AWSEnv returns the variables of the last SetAWSEnv.
+func AWSEnv() []string {
+	awsMu.Lock()
+	defer awsMu.Unlock()
+	return awsEnv
+}
+
+// UseCredentials has aws CLI commands run with --profile profile use
+// credentials instead of resolving the profile themselves: the flag is
+// dropped and the credentials are passed in the command's environment. A
+// profile that assumes a role with MFA or signs in with SSO then prompts once
+// for the session, as the SDK clients do, rather than once per command.
+func UseCredentials(profile string, credentials aws.CredentialsProvider) {
+	awsMu.Lock()
+	defer awsMu.Unlock()
+	profiles[profile] = credentials
+}
+
+// prepareAWS applies SetAWSEnv and UseCredentials to an aws CLI command.
+// When the credentials can't be retrieved the command is left to resolve the
+// profile itself.
+func prepareAWS(cmd *exec.Cmd) {
+	if len(cmd.Args) == 0 || strings.TrimSuffix(filepath.Base(cmd.Args[0]), ".exe") != "aws" {
+		return
+	}
+	awsMu.Lock()
+	env := awsEnv
+	var provider aws.CredentialsProvider
+	profile := -1
+	for i := 1; i+1 < len(cmd.Args); i++ {
+		if cmd.Args[i] == "--profile" {
+			provider, profile = profiles[cmd.Args[i+1]], i
+			break
+		}
+	}
+	awsMu.Unlock()
+
+	if provider != nil {
+		if creds, err := provider.Retrieve(context.Background()); err == nil {
+			cmd.Args = append(cmd.Args[:profile:profile], cmd.Args[profile+2:]...)
+			env = append(env[:len(env):len(env)],
+				"AWS_ACCESS_KEY_ID="+creds.AccessKeyID,
+				"AWS_SECRET_ACCESS_KEY="+creds.SecretAccessKey,
+				"AWS_SESSION_TOKEN="+creds.SessionToken)
+		}
+	}
+	if len(env) == 0 {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, env...)
+}
@@ -0,0 +1,76 @@
+package hostexec
+
+import (
+	"os/exec"
+	"sort"
+)
+
+// LambdaPlatform is the platform the function runs on unless build.go.goarch
+// says otherwise. Docker builds for the host architecture by default, which
+// on Apple silicon and Windows on ARM produces an image Lambda rejects, so
+// builds always pass the platform explicitly.
+const LambdaPlatform = "linux/amd64"
+
+// BuildOptions are the docker build settings besides the tag.
+type BuildOptions struct {
+	// Platform defaults to LambdaPlatform
+	Platform string
+	Args     map[string]string
+	// Secrets are only mounted while a RUN step executes, unlike build args,
+	// and are never stored in a layer or the image history
+	Secrets []Secret
+	// SSH forwards the SSH agent to RUN --mount=type=ssh steps
+	SSH bool
+	// Dockerfile defaults to the Dockerfile in the build context
+	Dockerfile string
+}
+
+// Secret is a BuildKit secret, read from the file Src or the environment
+// variable Env on the build host.
+type Secret struct {
+	ID  string `yaml:"id"`
+	Src string `yaml:"src"`
+	Env string `yaml:"env"`
+}
+
+func (s Secret) String() string {
+	if s.Env != "" {
+		return "id=" + s.ID + ",env=" + s.Env
+	}
+	return "id=" + s.ID + ",src=" + s.Src
+}
+
+// DockerBuild returns a docker build of the current directory for Lambda.
+func DockerBuild(tag string, opts BuildOptions) *exec.Cmd {
+	platform := opts.Platform
+	if platform == "" {
+		platform = LambdaPlatform
+	}
+	cmd := exec.Command("docker", "build", "--platform", platform, "-t", tag)
+	if opts.Dockerfile != "" {
+		cmd.Args = append(cmd.Args, "-f", opts.Dockerfile)
+	}
+	names := make([]string, 0, len(opts.Args))
+	for name := range opts.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd.Args = append(cmd.Args, "--build-arg", name+"="+opts.Args[name])
+	}
+	for _, secret := range opts.Secrets {
+		cmd.Args = append(cmd.Args, "--secret", secret.String())
+	}
+	if opts.SSH {
+		cmd.Args = append(cmd.Args, "--ssh", "default")
+	}
+	cmd.Args = append(cmd.Args, ".")
+	return cmd
+}
+
+// DockerLogin returns a docker login that reads the password from stdin.
+// Passing it as an argument would show up in the process list, and piping
+// through a shell differs between sh and cmd.exe.
+func DockerLogin(registry string) *exec.Cmd {
+	return exec.Command("docker", "login", "--username", "AWS", "--password-stdin", registry)
+}
@@ -0,0 +1,94 @@
+package hostexec
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Response is what a Fake returns for a matching command.
+type Response struct {
+	Output []byte
+	Err    error
+}
+
+// Call records one command a Fake was asked to run.
+type Call struct {
+	Args  []string
+	Stdin string
+}
+
+// Fake is a Runner for tests. Commands are matched against the registered
+// argument prefixes, longest first; unmatched commands succeed with no output.
+type Fake struct {
+	mu        sync.Mutex
+	responses map[string]Response
+	Calls     []Call
+}
+
+func NewFake() *Fake {
+	return &Fake{responses: map[string]Response{}}
+}
+
+// On registers the response for commands starting with args, e.g.
+// On([]string{"aws", "iam", "get-role"}, Response{Err: errors.New("NoSuchEntity")}).
+func (f *Fake) On(args []string, response Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[strings.Join(args, "\x00")] = response
+}
+
+// Commands returns the recorded commands joined with spaces, for assertions.
+func (f *Fake) Commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commands := make([]string, len(f.Calls))
+	for i, call := range f.Calls {
+		commands[i] = strings.Join(call.Args, " ")
+	}
+	return commands
+}
+
+func (f *Fake) respond(cmd *exec.Cmd) Response {
+	call := Call{Args: cmd.Args}
+	if cmd.Stdin != nil {
+		stdin, _ := io.ReadAll(cmd.Stdin)
+		call.Stdin = string(stdin)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, call)
+	for n := len(cmd.Args); n > 0; n-- {
+		if response, ok := f.responses[strings.Join(cmd.Args[:n], "\x00")]; ok {
+			return response
+		}
+	}
+	return Response{}
+}
+
+func (f *Fake) Run(cmd *exec.Cmd) error {
+	response := f.respond(cmd)
+	if cmd.Stdout != nil {
+		cmd.Stdout.Write(response.Output)
+	}
+	return response.Err
+}
+
+func (f *Fake) Output(cmd *exec.Cmd) ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, fmt.Errorf("exec: Stdout already set")
+	}
+	response := f.respond(cmd)
+	return response.Output, response.Err
+}
+
+func (f *Fake) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	if cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, fmt.Errorf("exec: Stdout or Stderr already set")
+	}
+	response := f.respond(cmd)
+	return response.Output, response.Err
+}
@@ -0,0 +1,50 @@
+// Package hostexec runs the external tools (aws, docker, git) the setup and
+// deploy commands shell out to. Commands are built with exec.Command as usual
+// and handed to a Runner, so tests can swap in a Fake and assert on the
+// arguments without the tools installed, on any host OS.
+package hostexec
+
+import (
+	"os/exec"
+)
+
+// Runner runs a prepared command the way the matching exec.Cmd method would.
+type Runner interface {
+	Run(cmd *exec.Cmd) error
+	Output(cmd *exec.Cmd) ([]byte, error)
+	CombinedOutput(cmd *exec.Cmd) ([]byte, error)
+}
+
+// OS runs commands on the host, aws CLI commands with the settings of
+// SetAWSEnv and UseCredentials.
+type OS struct{}
+
+func (OS) Run(cmd *exec.Cmd) error {
+	prepareAWS(cmd)
+	return cmd.Run()
+}
+
+func (OS) Output(cmd *exec.Cmd) ([]byte, error) {
+	prepareAWS(cmd)
+	return cmd.Output()
+}
+
+func (OS) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	prepareAWS(cmd)
+	return cmd.CombinedOutput()
+}
+
+// Default is the Runner used by the package-level helpers.
+var Default Runner = OS{}
+
+func Run(cmd *exec.Cmd) error {
+	return Default.Run(cmd)
+}
+
+func Output(cmd *exec.Cmd) ([]byte, error) {
+	return Default.Output(cmd)
+}
+
+func CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	return Default.CombinedOutput(cmd)
+}
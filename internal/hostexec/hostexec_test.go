@@ -0,0 +1,119 @@
+package hostexec
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestFakeMatchesLongestPrefix(t *testing.T) {
+	fake := NewFake()
+	fake.On([]string{"aws", "iam"}, Response{Output: []byte("iam")})
+	fake.On([]string{"aws", "iam", "get-role"}, Response{Err: errors.New("NoSuchEntity")})
+
+	if _, err := fake.CombinedOutput(exec.Command("aws", "iam", "get-role", "--role-name", "r")); err == nil {
+		t.Error("get-role: want the registered error")
+	}
+	output, err := fake.Output(exec.Command("aws", "iam", "create-role"))
+	if err != nil || string(output) != "iam" {
+		t.Errorf("create-role = %q, %v; want \"iam\", nil", output, err)
+	}
+	if _, err := fake.CombinedOutput(exec.Command("docker", "push")); err != nil {
+		t.Errorf("unmatched command: %v", err)
+	}
+
+	want := []string{"aws iam get-role --role-name r", "aws iam create-role", "docker push"}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %q, want %q", got, want)
+	}
+}
+
+func TestFakeRecordsStdinAndWritesStdout(t *testing.T) {
+	fake := NewFake()
+	fake.On([]string{"docker", "login"}, Response{Output: []byte("Login Succeeded\n")})
+
+	cmd := DockerLogin("123.dkr.ecr.us-east-1.amazonaws.com")
+	cmd.Stdin = strings.NewReader("secret")
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	if err := fake.Run(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "Login Succeeded\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if fake.Calls[0].Stdin != "secret" {
+		t.Errorf("stdin = %q, want the password", fake.Calls[0].Stdin)
+	}
+	for _, arg := range cmd.Args {
+		if arg == "secret" {
+			t.Error("password passed as an argument")
+		}
+	}
+}
+
+func TestDockerBuildTargetsLambdaPlatform(t *testing.T) {
+	cmd := DockerBuild("repo/fn", BuildOptions{Args: map[string]string{"HANDLER": "export", "A": "1"}})
+	want := []string{"docker", "build", "--platform", "linux/amd64", "-t", "repo/fn",
+		"--build-arg", "A=1", "--build-arg", "HANDLER=export", "."}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+
+	cmd = DockerBuild("repo/fn", BuildOptions{
+		Platform:   "linux/arm64",
+		Secrets:    []Secret{{ID: "netrc", Src: "/home/me/.netrc"}, {ID: "npm", Env: "NPM_TOKEN"}},
+		SSH:        true,
+		Dockerfile: "worker.Dockerfile",
+	})
+	want = []string{"docker", "build", "--platform", "linux/arm64", "-t", "repo/fn", "-f", "worker.Dockerfile",
+		"--secret", "id=netrc,src=/home/me/.netrc", "--secret", "id=npm,env=NPM_TOKEN", "--ssh", "default", "."}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestPrepareAWSReplacesProfile(t *testing.T) {
+	UseCredentials("mfa", credentials.NewStaticCredentialsProvider("AKID", "SECRET", "TOKEN"))
+	t.Cleanup(func() { delete(profiles, "mfa") })
+
+	cmd := exec.Command("aws", "lambda", "get-function", "--profile", "mfa", "--region", "us-east-1")
+	prepareAWS(cmd)
+	if want := []string{"aws", "lambda", "get-function", "--region", "us-east-1"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+	if env := strings.Join(cmd.Env, "\n"); !strings.Contains(env, "AWS_ACCESS_KEY_ID=AKID") || !strings.Contains(env, "AWS_SESSION_TOKEN=TOKEN") {
+		t.Error("credentials not in the environment")
+	}
+
+	for _, args := range [][]string{
+		{"aws", "lambda", "get-function", "--profile", "other"},
+		{"docker", "push", "--profile", "mfa"},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		prepareAWS(cmd)
+		if !reflect.DeepEqual(cmd.Args, args) || cmd.Env != nil {
+			t.Errorf("%q changed to %q", args, cmd.Args)
+		}
+	}
+}
+
+func TestPrepareAWSAddsEnv(t *testing.T) {
+	SetAWSEnv([]string{"AWS_USE_FIPS_ENDPOINT=true"})
+	t.Cleanup(func() { SetAWSEnv(nil) })
+
+	cmd := exec.Command("aws", "sts", "get-caller-identity")
+	prepareAWS(cmd)
+	if len(cmd.Env) == 0 || cmd.Env[len(cmd.Env)-1] != "AWS_USE_FIPS_ENDPOINT=true" {
+		t.Errorf("env does not end with the setting: %q", cmd.Env)
+	}
+	docker := exec.Command("docker", "push")
+	prepareAWS(docker)
+	if docker.Env != nil {
+		t.Error("setting passed to docker")
+	}
+}
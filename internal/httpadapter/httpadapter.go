@@ -0,0 +1,198 @@
+// Package httpadapter serves API Gateway HTTP API and function URL events
+// (payload format 2.0), and REST API events (payload format 1.0), with a
+// standard net/http handler.
+package httpadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type Adapter struct {
+	handler http.Handler
+}
+
+func New(handler http.Handler) lambda.Handler {
+	return &Adapter{handler: handler}
+}
+
+// payloadFormat holds the fields that tell the two payload formats apart:
+// 2.0 events have version "2.0", 1.0 events "1.0" or, from older
+// integrations, no version but an httpMethod.
+type payloadFormat struct {
+	Version    string `json:"version"`
+	HTTPMethod string `json:"httpMethod"`
+}
+
+func (f payloadFormat) v1() bool {
+	return f.Version == "1.0" || (f.Version == "" && f.HTTPMethod != "")
+}
+
+func (a *Adapter) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var format payloadFormat
+	if err := json.Unmarshal(payload, &format); err != nil {
+		return nil, fmt.Errorf("error decoding HTTP event: %v", err)
+	}
+	if format.v1() {
+		var event events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, fmt.Errorf("error decoding HTTP event: %v", err)
+		}
+		req, err := NewRequestV1(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		recorder := a.serve(req)
+		return json.Marshal(NewResponseV1(recorder.Result().StatusCode, recorder.Header(), recorder.Body.Bytes()))
+	}
+
+	var event events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("error decoding HTTP event: %v", err)
+	}
+	req, err := NewRequest(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	recorder := a.serve(req)
+	return json.Marshal(NewResponse(recorder.Result().StatusCode, recorder.Header(), recorder.Body.Bytes()))
+}
+
+func (a *Adapter) serve(req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	a.handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// NewRequest converts an HTTP API event into an *http.Request.
+func NewRequest(ctx context.Context, event events.APIGatewayV2HTTPRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding request body: %v", err)
+		}
+		body = decoded
+	}
+
+	url := event.RawPath
+	if event.RawQueryString != "" {
+		url += "?" + event.RawQueryString
+	}
+
+	req, err := http.NewRequestWithContext(ctx, event.RequestContext.HTTP.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %v", err)
+	}
+	for name, value := range event.Headers {
+		req.Header.Set(name, value)
+	}
+	if len(event.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+	req.Host = event.RequestContext.DomainName
+	req.RemoteAddr = event.RequestContext.HTTP.SourceIP
+	req.RequestURI = url
+	return req, nil
+}
+
+// NewRequestV1 converts a REST API event into an *http.Request. Repeated
+// headers and query parameters come from the multi-value fields.
+func NewRequestV1(ctx context.Context, event events.APIGatewayProxyRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding request body: %v", err)
+		}
+		body = decoded
+	}
+
+	query := url.Values{}
+	for name, values := range event.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+	for name, value := range event.QueryStringParameters {
+		if _, ok := query[name]; !ok {
+			query.Set(name, value)
+		}
+	}
+	target := event.Path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, event.HTTPMethod, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building request: %v", err)
+	}
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	for name, value := range event.Headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Host = event.RequestContext.DomainName
+	req.RemoteAddr = event.RequestContext.Identity.SourceIP
+	req.RequestURI = target
+	return req, nil
+}
+
+// NewResponse builds the HTTP API response, base64-encoding bodies that are
+// not valid UTF-8.
+func NewResponse(status int, header http.Header, body []byte) events.APIGatewayV2HTTPResponse {
+	response := events.APIGatewayV2HTTPResponse{
+		StatusCode: status,
+		Headers:    map[string]string{},
+	}
+	for name, values := range header {
+		if strings.EqualFold(name, "Set-Cookie") {
+			response.Cookies = append(response.Cookies, values...)
+			continue
+		}
+		response.Headers[name] = strings.Join(values, ",")
+	}
+
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+	return response
+}
+
+// NewResponseV1 builds the REST API response, with repeated headers such as
+// Set-Cookie in the multi-value headers.
+func NewResponseV1(status int, header http.Header, body []byte) events.APIGatewayProxyResponse {
+	response := events.APIGatewayProxyResponse{
+		StatusCode:        status,
+		MultiValueHeaders: map[string][]string{},
+	}
+	for name, values := range header {
+		response.MultiValueHeaders[name] = values
+	}
+
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+	return response
+}
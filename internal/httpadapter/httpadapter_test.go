@@ -0,0 +1,73 @@
+package httpadapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// echo reports what the handler saw of the request.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body := make([]byte, r.ContentLength)
+	r.Body.Read(body)
+	http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+	http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s %s tag=%v accept=%s body=%s", r.Method, r.URL.Path, r.URL.Query()["tag"], r.Header.Get("Accept"), body)
+})
+
+func TestInvokeV2(t *testing.T) {
+	payload := `{
+		"version": "2.0",
+		"rawPath": "/items",
+		"rawQueryString": "tag=a&tag=b",
+		"headers": {"accept": "text/plain"},
+		"requestContext": {"http": {"method": "POST", "sourceIp": "192.0.2.1"}},
+		"body": "aGk=",
+		"isBase64Encoded": true
+	}`
+	output, err := New(echo).Invoke(context.Background(), []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatal(err)
+	}
+	if want := "POST /items tag=[a b] accept=text/plain body=hi"; response.StatusCode != 201 || response.Body != want {
+		t.Errorf("response = %d %q, want 201 %q", response.StatusCode, response.Body, want)
+	}
+	if len(response.Cookies) != 2 {
+		t.Errorf("cookies = %q, want both", response.Cookies)
+	}
+}
+
+func TestInvokeV1(t *testing.T) {
+	for name, payload := range map[string]string{
+		"version 1.0": `{"version": "1.0", "httpMethod": "POST", "path": "/items",
+			"multiValueQueryStringParameters": {"tag": ["a", "b"]}, "headers": {"Accept": "text/plain"},
+			"requestContext": {"identity": {"sourceIp": "192.0.2.1"}}, "body": "hi"}`,
+		"no version": `{"httpMethod": "POST", "path": "/items",
+			"multiValueQueryStringParameters": {"tag": ["a", "b"]}, "multiValueHeaders": {"Accept": ["text/plain"]},
+			"body": "aGk=", "isBase64Encoded": true}`,
+	} {
+		output, err := New(echo).Invoke(context.Background(), []byte(payload))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var response events.APIGatewayProxyResponse
+		if err := json.Unmarshal(output, &response); err != nil {
+			t.Fatal(err)
+		}
+		if want := "POST /items tag=[a b] accept=text/plain body=hi"; response.StatusCode != 201 || response.Body != want {
+			t.Errorf("%s: response = %d %q, want 201 %q", name, response.StatusCode, response.Body, want)
+		}
+		if cookies := response.MultiValueHeaders["Set-Cookie"]; len(cookies) != 2 {
+			t.Errorf("%s: Set-Cookie = %q, want both cookies", name, cookies)
+		}
+	}
+}
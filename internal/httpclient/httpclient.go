@@ -0,0 +1,221 @@
+// Package httpclient builds the http.Client handlers use for downstream calls.
+// Unlike http.DefaultClient it always has timeouts, retries idempotent
+// requests within a retry budget, propagates trace context, honours the
+// HTTP(S)_PROXY/NO_PROXY environment, enforces the egress allow list and
+// emits per-host EMF metrics.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+
+	"example-lambda-go/internal/egress"
+	"example-lambda-go/internal/metrics"
+)
+
+const metricsNamespace = "LambdaTemplate/HTTPClient"
+
+type Config struct {
+	// Timeout bounds a whole call including retries
+	Timeout time.Duration
+	// MaxAttempts is the number of tries per request, including the first
+	MaxAttempts int
+	Backoff     time.Duration
+	// RetryRatio is the share of requests that may be retried, so an outage
+	// downstream doesn't multiply the load we send it
+	RetryRatio          float64
+	MaxIdleConnsPerHost int
+}
+
+// DefaultConfig is used for any zero fields passed to New.
+var DefaultConfig = Config{
+	Timeout:             10 * time.Second,
+	MaxAttempts:         3,
+	Backoff:             100 * time.Millisecond,
+	RetryRatio:          0.2,
+	MaxIdleConnsPerHost: 16,
+}
+
+// New returns a client with cfg's timeouts and retry policy. Create it once
+// per cold start and reuse it so connections are pooled across invocations.
+func New(cfg Config) *http.Client {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultConfig.MaxAttempts
+	}
+	if cfg.Backoff == 0 {
+		cfg.Backoff = DefaultConfig.Backoff
+	}
+	if cfg.RetryRatio == 0 {
+		cfg.RetryRatio = DefaultConfig.RetryRatio
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = DefaultConfig.MaxIdleConnsPerHost
+	}
+
+	base := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: egress.Wrap((&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: cfg.Timeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &Transport{
+			Base:        base,
+			MaxAttempts: cfg.MaxAttempts,
+			Backoff:     cfg.Backoff,
+			budget:      &retryBudget{ratio: cfg.RetryRatio, tokens: 10, max: 10},
+		},
+	}
+}
+
+// Transport adds trace propagation, retries and metrics to Base.
+type Transport struct {
+	Base        http.RoundTripper
+	MaxAttempts int
+	Backoff     time.Duration
+	budget      *retryBudget
+}
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	req = req.Clone(ctx)
+	inject(ctx, req.Header)
+
+	start := time.Now()
+	retries := 0
+	var resp *http.Response
+	var err error
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		resp, err = t.Base.RoundTrip(req)
+		if attempt >= t.MaxAttempts || !retryable(req, resp, err) || !t.budget.withdraw() {
+			break
+		}
+
+		wait := backoff(t.Backoff, attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		retries++
+	}
+	t.budget.deposit()
+
+	failed := 0.0
+	if err != nil || resp.StatusCode >= 500 {
+		failed = 1
+	}
+	metrics.Emit(ctx, metricsNamespace, map[string]string{"Host": req.URL.Host},
+		metrics.Metric{Name: "Requests", Value: 1, Unit: metrics.Count},
+		metrics.Metric{Name: "Errors", Value: failed, Unit: metrics.Count},
+		metrics.Metric{Name: "Retries", Value: float64(retries), Unit: metrics.Count},
+		metrics.Metric{Name: "Latency", Value: float64(time.Since(start).Milliseconds()), Unit: metrics.Milliseconds},
+	)
+	return resp, err
+}
+
+// inject writes W3C traceparent/baggage for the OpenTelemetry span in ctx,
+// and forwards the X-Ray header Lambda attaches to the invocation.
+func inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	if traceID, ok := ctx.Value("x-amzn-trace-id").(string); ok && traceID != "" && header.Get("X-Amzn-Trace-Id") == "" {
+		header.Set("X-Amzn-Trace-Id", traceID)
+	}
+}
+
+// retryable reports whether another attempt is safe and worthwhile. Only
+// idempotent requests are retried, or POSTs carrying an Idempotency-Key.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		if req.Header.Get("Idempotency-Key") == "" {
+			return false
+		}
+	}
+
+	if err != nil {
+		// The caller's deadline or cancellation is final
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is exponential with full jitter, unless the server sent Retry-After.
+func backoff(base time.Duration, attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return time.Duration(rand.Int63n(int64(base) << (attempt - 1)))
+}
+
+// retryBudget is a token bucket: every completed request adds ratio tokens
+// and every retry spends one.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	tokens float64
+	max    float64
+}
+
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+}
+
+// withdraw always fails on a Transport built without New, which has no budget.
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,380 @@
+// Package imagediff compares two container images as `docker save` writes
+// them: which layers changed, and which files were added, removed or changed
+// in the filesystem the layers add up to. Deploy uses it to show what a new
+// image changes before it is pushed.
+package imagediff
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+type File struct {
+	Size int64
+	Mode int64
+	// Digest is the SHA-256 of the content
+	Digest string
+	// Binary is set for ELF files, i.e. compiled programs and libraries
+	Binary bool
+}
+
+type Layer struct {
+	// DiffID is the SHA-256 of the uncompressed layer, as in the image config
+	DiffID string
+	Size   int64
+	// CreatedBy is the Dockerfile instruction that produced the layer, when known
+	CreatedBy string
+}
+
+type Image struct {
+	Layers []Layer
+	// Files is the filesystem after applying every layer, by path
+	Files map[string]File
+}
+
+// Size is the uncompressed size of all layers.
+func (img *Image) Size() int64 {
+	var size int64
+	for _, layer := range img.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+type layerContent struct {
+	diffID  string
+	size    int64
+	entries []entry
+}
+
+type entry struct {
+	path string
+	file File
+	// whiteout deletes path; opaque clears the directory path from lower layers
+	whiteout, opaque bool
+	dir              bool
+}
+
+// Load reads an image from a `docker save` archive, in either the classic
+// layout or the OCI one written by Docker with the containerd image store.
+func Load(r io.Reader) (*Image, error) {
+	layers := map[string]*layerContent{}
+	blobs := map[string][]byte{}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading image archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content := bufio.NewReaderSize(archive, 64*1024)
+		peek, _ := content.Peek(512)
+		if isTar(peek) || isGzip(peek) {
+			layer, err := readLayer(content, isGzip(peek))
+			if err != nil {
+				return nil, fmt.Errorf("error reading layer %s: %v", header.Name, err)
+			}
+			layers[header.Name] = layer
+			continue
+		}
+		// JSON manifests and configs are small; skip anything else
+		if header.Size < 4<<20 {
+			data, err := io.ReadAll(content)
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %v", header.Name, err)
+			}
+			blobs[header.Name] = data
+		}
+	}
+
+	var manifest []struct {
+		Config string
+		Layers []string
+	}
+	if err := json.Unmarshal(blobs["manifest.json"], &manifest); err != nil || len(manifest) == 0 {
+		return nil, fmt.Errorf("archive has no manifest.json; was it written by docker save?")
+	}
+	var config struct {
+		History []struct {
+			CreatedBy  string `json:"created_by"`
+			EmptyLayer bool   `json:"empty_layer"`
+		} `json:"history"`
+	}
+	json.Unmarshal(blobs[manifest[0].Config], &config)
+	var history []string
+	for _, h := range config.History {
+		if !h.EmptyLayer {
+			history = append(history, h.CreatedBy)
+		}
+	}
+
+	img := &Image{Files: map[string]File{}}
+	for i, name := range manifest[0].Layers {
+		layer, ok := layers[name]
+		if !ok {
+			return nil, fmt.Errorf("layer %s is missing from the archive", name)
+		}
+		l := Layer{DiffID: layer.diffID, Size: layer.size}
+		if i < len(history) {
+			l.CreatedBy = history[i]
+		}
+		img.Layers = append(img.Layers, l)
+		apply(img.Files, layer.entries)
+	}
+	return img, nil
+}
+
+func isTar(peek []byte) bool {
+	return len(peek) >= 262 && string(peek[257:262]) == "ustar"
+}
+
+func isGzip(peek []byte) bool {
+	return len(peek) >= 2 && peek[0] == 0x1f && peek[1] == 0x8b
+}
+
+func readLayer(r io.Reader, compressed bool) (*layerContent, error) {
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(r, hash)}
+
+	layer := &layerContent{}
+	files := tar.NewReader(counter)
+	for {
+		header, err := files.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean("/" + header.Name)
+		dir, base := path.Split(name)
+		switch {
+		case base == ".wh..wh..opq":
+			layer.entries = append(layer.entries, entry{path: path.Clean(dir), opaque: true})
+			continue
+		case strings.HasPrefix(base, ".wh."):
+			layer.entries = append(layer.entries, entry{path: path.Join(dir, strings.TrimPrefix(base, ".wh.")), whiteout: true})
+			continue
+		case header.Typeflag == tar.TypeDir:
+			layer.entries = append(layer.entries, entry{path: name, dir: true})
+			continue
+		}
+
+		file := File{Size: header.Size, Mode: header.Mode}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			contentHash := sha256.New()
+			head := make([]byte, 4)
+			n, _ := io.ReadFull(files, head)
+			contentHash.Write(head[:n])
+			if _, err := io.Copy(contentHash, files); err != nil {
+				return nil, err
+			}
+			file.Digest = hex.EncodeToString(contentHash.Sum(nil))
+			file.Binary = bytes.Equal(head[:n], []byte("\x7fELF"))
+		case tar.TypeSymlink, tar.TypeLink:
+			file.Digest = "link:" + header.Linkname
+		default:
+			continue
+		}
+		layer.entries = append(layer.entries, entry{path: name, file: file})
+	}
+	// Drain the end-of-archive padding so the digest covers the whole layer
+	io.Copy(io.Discard, counter)
+	layer.diffID = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	layer.size = counter.n
+	return layer, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// apply lays a layer over files the way overlayfs would: its whiteouts hide
+// what the lower layers had, then its own files are added.
+func apply(files map[string]File, entries []entry) {
+	for _, e := range entries {
+		if !e.opaque && !e.whiteout {
+			continue
+		}
+		prefix := e.path + "/"
+		for name := range files {
+			if strings.HasPrefix(name, prefix) || (e.whiteout && name == e.path) {
+				delete(files, name)
+			}
+		}
+	}
+	for _, e := range entries {
+		if !e.opaque && !e.whiteout && !e.dir {
+			files[e.path] = e.file
+		}
+	}
+}
+
+type Change struct {
+	Path string
+	// OldSize is -1 for added files, NewSize -1 for removed ones
+	OldSize, NewSize int64
+	Binary           bool
+}
+
+type Report struct {
+	OldSize, NewSize int64
+	// AddedLayers and RemovedLayers are the layers only in one of the images
+	AddedLayers, RemovedLayers []Layer
+	Added, Removed, Changed    []Change
+}
+
+// Diff compares the deployed image with the candidate.
+func Diff(old, new *Image) *Report {
+	report := &Report{OldSize: old.Size(), NewSize: new.Size()}
+
+	oldLayers, newLayers := map[string]bool{}, map[string]bool{}
+	for _, layer := range old.Layers {
+		oldLayers[layer.DiffID] = true
+	}
+	for _, layer := range new.Layers {
+		newLayers[layer.DiffID] = true
+		if !oldLayers[layer.DiffID] {
+			report.AddedLayers = append(report.AddedLayers, layer)
+		}
+	}
+	for _, layer := range old.Layers {
+		if !newLayers[layer.DiffID] {
+			report.RemovedLayers = append(report.RemovedLayers, layer)
+		}
+	}
+
+	for name, file := range new.Files {
+		previous, ok := old.Files[name]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, Change{Path: name, OldSize: -1, NewSize: file.Size, Binary: file.Binary})
+		case previous.Digest != file.Digest || previous.Mode != file.Mode:
+			report.Changed = append(report.Changed, Change{Path: name, OldSize: previous.Size, NewSize: file.Size, Binary: file.Binary || previous.Binary})
+		}
+	}
+	for name, file := range old.Files {
+		if _, ok := new.Files[name]; !ok {
+			report.Removed = append(report.Removed, Change{Path: name, OldSize: file.Size, NewSize: -1, Binary: file.Binary})
+		}
+	}
+
+	// Biggest first, which is what someone looking for bloat wants to see
+	for _, changes := range [][]Change{report.Added, report.Removed, report.Changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			di, dj := abs(changes[i].NewSize-changes[i].OldSize), abs(changes[j].NewSize-changes[j].OldSize)
+			if di != dj {
+				return di > dj
+			}
+			return changes[i].Path < changes[j].Path
+		})
+	}
+	return report
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Print writes the report, listing at most limit files per section.
+func (r *Report) Print(w io.Writer, limit int) {
+	fmt.Fprintf(w, "Image size: %s -> %s (%s)\n", humanSize(r.OldSize), humanSize(r.NewSize), signedSize(r.NewSize-r.OldSize))
+	fmt.Fprintf(w, "Layers: %d added, %d removed\n", len(r.AddedLayers), len(r.RemovedLayers))
+	for _, layer := range r.AddedLayers {
+		fmt.Fprintf(w, "  + %-10s %s\n", humanSize(layer.Size), truncate(layer.CreatedBy, 100))
+	}
+	for _, layer := range r.RemovedLayers {
+		fmt.Fprintf(w, "  - %-10s %s\n", humanSize(layer.Size), truncate(layer.CreatedBy, 100))
+	}
+
+	section := func(title, sign string, changes []Change) {
+		fmt.Fprintf(w, "%s: %d\n", title, len(changes))
+		for i, change := range changes {
+			if i == limit {
+				fmt.Fprintf(w, "  ... and %d more\n", len(changes)-limit)
+				break
+			}
+			size := ""
+			switch {
+			case change.OldSize < 0:
+				size = humanSize(change.NewSize)
+			case change.NewSize < 0:
+				size = humanSize(change.OldSize)
+			default:
+				size = fmt.Sprintf("%s -> %s", humanSize(change.OldSize), humanSize(change.NewSize))
+			}
+			binary := ""
+			if change.Binary {
+				binary = " (binary)"
+			}
+			fmt.Fprintf(w, "  %s %s  %s%s\n", sign, change.Path, size, binary)
+		}
+	}
+	section("Files added", "+", r.Added)
+	section("Files removed", "-", r.Removed)
+	section("Files changed", "~", r.Changed)
+}
+
+func humanSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+func signedSize(n int64) string {
+	if n < 0 {
+		return "-" + humanSize(-n)
+	}
+	return "+" + humanSize(n)
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimPrefix(s, "/bin/sh -c ")
+	if len(s) > n {
+		return s[:n-3] + "..."
+	}
+	return s
+}
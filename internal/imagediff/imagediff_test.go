@@ -0,0 +1,125 @@
+package imagediff
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type file struct {
+	name, content string
+	mode          int64
+}
+
+func layerTar(t *testing.T, files ...file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		mode := f.mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: mode, Size: int64(len(f.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+// saved builds a classic `docker save` archive from layer tarballs.
+func saved(t *testing.T, layers ...[]byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	add := func(name string, data []byte) {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+
+	var names []string
+	var history []map[string]string
+	for i, layer := range layers {
+		name := strings.Repeat(string(rune('a'+i)), 8) + "/layer.tar"
+		names = append(names, name)
+		history = append(history, map[string]string{"created_by": "step " + string(rune('a'+i))})
+		add(name, layer)
+	}
+	config, _ := json.Marshal(map[string]interface{}{"history": history})
+	add("config.json", config)
+	manifest, _ := json.Marshal([]map[string]interface{}{{"Config": "config.json", "Layers": names}})
+	add("manifest.json", manifest)
+	tw.Close()
+	return &buf
+}
+
+func TestDiff(t *testing.T) {
+	base := layerTar(t,
+		file{name: "etc/os-release", content: "debian"},
+		file{name: "var/cache/apt/big", content: strings.Repeat("x", 1000)},
+	)
+	oldApp := layerTar(t,
+		file{name: "app/bootstrap", content: "\x7fELF old", mode: 0o755},
+		file{name: "app/config.json", content: "{}"},
+	)
+	newApp := layerTar(t,
+		file{name: "app/bootstrap", content: "\x7fELF new build", mode: 0o755},
+		file{name: "app/config.json", content: "{}"},
+		file{name: "app/testdata/dump.sql", content: strings.Repeat("y", 5000)},
+		file{name: "var/cache/apt/.wh.big"},
+	)
+
+	old, err := Load(saved(t, base, oldApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidate, err := Load(saved(t, base, newApp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := candidate.Files["/var/cache/apt/big"]; ok {
+		t.Error("whiteout did not remove /var/cache/apt/big")
+	}
+
+	report := Diff(old, candidate)
+	if len(report.AddedLayers) != 1 || len(report.RemovedLayers) != 1 {
+		t.Errorf("layers added, removed = %d, %d; want the app layer replaced", len(report.AddedLayers), len(report.RemovedLayers))
+	}
+	if len(report.Added) != 1 || report.Added[0].Path != "/app/testdata/dump.sql" {
+		t.Errorf("added = %+v, want /app/testdata/dump.sql", report.Added)
+	}
+	if len(report.Removed) != 1 || report.Removed[0].Path != "/var/cache/apt/big" {
+		t.Errorf("removed = %+v, want /var/cache/apt/big", report.Removed)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Path != "/app/bootstrap" || !report.Changed[0].Binary {
+		t.Errorf("changed = %+v, want the /app/bootstrap binary", report.Changed)
+	}
+
+	var out bytes.Buffer
+	report.Print(&out, 10)
+	for _, want := range []string{"Layers: 1 added, 1 removed", "+ /app/testdata/dump.sql  4.9 KB", "~ /app/bootstrap", "(binary)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestOpaqueWhiteout(t *testing.T) {
+	lower := layerTar(t, file{name: "app/a", content: "a"}, file{name: "app/b", content: "b"})
+	upper := layerTar(t, file{name: "app/c", content: "c"}, file{name: "app/.wh..wh..opq"})
+
+	img, err := Load(saved(t, lower, upper))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(img.Files) != 1 {
+		t.Errorf("files = %v, want only /app/c", img.Files)
+	}
+	if _, ok := img.Files["/app/c"]; !ok {
+		t.Errorf("files = %v, want /app/c", img.Files)
+	}
+}
@@ -0,0 +1,76 @@
+// Package introspect answers a reserved payload with the manifest of what the
+// binary handles: its routes and handlers, the job types of the worker queue,
+// the versions of the enveloped events it accepts and how it was built. All of
+// it is compiled in, so `lambda-template status -introspect` shows what the
+// deployed code supports rather than what the checkout says.
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"example-lambda-go/internal/health"
+	"example-lambda-go/internal/middleware"
+	"example-lambda-go/internal/router"
+)
+
+// Payload is the reserved event that returns the manifest. No handler sees it.
+const Payload = `{"__introspect": true}`
+
+// Manifest describes the deployed code.
+type Manifest struct {
+	Build health.BuildInfo `json:"build"`
+	// Arch and Tags are the GOARCH and -tags the binary was built with
+	Arch     string         `json:"arch,omitempty"`
+	Tags     string         `json:"tags,omitempty"`
+	Routes   []router.Route `json:"routes"`
+	Handlers []string       `json:"handlers"`
+	Jobs     []string       `json:"jobs,omitempty"`
+	// Events is the current version of each enveloped event type
+	Events map[string]int `json:"events,omitempty"`
+}
+
+// New returns the manifest of the binary's routes and handlers, with the
+// build information the Go linker recorded.
+func New(r *router.Router) Manifest {
+	m := Manifest{Build: health.ReadBuildInfo(), Routes: r.Routes(), Handlers: r.Handlers()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "GOARCH":
+				m.Arch = setting.Value
+			case "-tags":
+				m.Tags = setting.Value
+			}
+		}
+	}
+	return m
+}
+
+// Middleware returns the manifest for the reserved payload and passes every
+// other event on. It goes first in the chain, so the manifest is returned
+// in maintenance mode too and is never mirrored to a shadow.
+func Middleware(m Manifest) middleware.Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			if !isRequest(payload) {
+				return next.Invoke(ctx, payload)
+			}
+			return json.Marshal(m)
+		})
+	}
+}
+
+// isRequest reports whether the payload is Payload: an object with the
+// reserved key alone, set to true.
+func isRequest(payload []byte) bool {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) != nil || len(fields) != 1 {
+		return false
+	}
+	var requested bool
+	return json.Unmarshal(fields["__introspect"], &requested) == nil && requested
+}
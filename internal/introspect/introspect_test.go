@@ -0,0 +1,41 @@
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"example-lambda-go/internal/middleware"
+	"example-lambda-go/internal/router"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := router.New([]router.Route{{Name: "greet", Handler: "greet", Default: true}})
+	handler := middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		return []byte(`"handled"`), nil
+	})
+	r.Register("greet", handler)
+	m := New(r)
+	m.Events = map[string]int{"greet": 2}
+	h := Middleware(m)(handler)
+
+	output, err := h.Invoke(context.Background(), []byte(Payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Manifest
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("%s: %v", output, err)
+	}
+	if got.Build.GoVersion == "" || len(got.Routes) != 1 || got.Handlers[0] != "greet" || got.Events["greet"] != 2 {
+		t.Errorf("manifest = %s", output)
+	}
+
+	// Only the reserved key alone asks for the manifest
+	for _, payload := range []string{`{"__introspect": false}`, `{"__introspect": true, "name": "Ada"}`, `{"name": "Ada"}`, `[]`} {
+		output, err := h.Invoke(context.Background(), []byte(payload))
+		if err != nil || string(output) != `"handled"` {
+			t.Errorf("Invoke(%s) = %s, %v; want it handled", payload, output, err)
+		}
+	}
+}
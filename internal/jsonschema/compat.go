@@ -0,0 +1,81 @@
+package jsonschema
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RequestBreaks lists the ways a handler accepting current would reject
+// payloads a consumer produces according to expected: new required
+// properties and changed types.
+func RequestBreaks(expected, current *Schema) []string {
+	return compare("", expected, current, true)
+}
+
+// ResponseBreaks lists the ways a handler producing current would break a
+// consumer reading expected: removed properties, properties that are no
+// longer always present and changed types.
+func ResponseBreaks(expected, current *Schema) []string {
+	return compare("", expected, current, false)
+}
+
+func compare(path string, expected, current *Schema, request bool) []string {
+	if expected == nil || current == nil {
+		return nil
+	}
+	// An empty type accepts anything, e.g. interface{} or json.RawMessage
+	if expected.Type != "" && current.Type != "" && expected.Type != current.Type {
+		return []string{fmt.Sprintf("%s: type changed from %s to %s", display(path), expected.Type, current.Type)}
+	}
+
+	var breaks []string
+	breaks = append(breaks, compare(path+"[]", expected.Items, current.Items, request)...)
+	breaks = append(breaks, compare(path+"{}", expected.AdditionalProperties, current.AdditionalProperties, request)...)
+
+	names := make([]string, 0, len(expected.Properties)+len(current.Properties))
+	for name := range expected.Properties {
+		names = append(names, name)
+	}
+	for name := range current.Properties {
+		if _, ok := expected.Properties[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := name
+		if path != "" {
+			propertyPath = path + "." + name
+		}
+		want, have := expected.Properties[name], current.Properties[name]
+		switch {
+		case request && want == nil && contains(current.Required, name):
+			breaks = append(breaks, fmt.Sprintf("%s: new required property", propertyPath))
+		case request && want != nil && !contains(expected.Required, name) && contains(current.Required, name):
+			breaks = append(breaks, fmt.Sprintf("%s: property became required", propertyPath))
+		case !request && want != nil && have == nil:
+			breaks = append(breaks, fmt.Sprintf("%s: property removed", propertyPath))
+		case !request && have != nil && contains(expected.Required, name) && !contains(current.Required, name):
+			breaks = append(breaks, fmt.Sprintf("%s: property is no longer always present", propertyPath))
+		}
+		breaks = append(breaks, compare(propertyPath, want, have, request)...)
+	}
+	return breaks
+}
+
+func display(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
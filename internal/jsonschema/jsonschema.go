@@ -0,0 +1,116 @@
+// Package jsonschema derives JSON Schema (draft-04) documents from Go types
+// using the same field names and omitempty rules as encoding/json.
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Draft04 = "http://json-schema.org/draft-04/schema#"
+
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Generate returns the schema for the type of v. Fields without omitempty
+// and without a pointer type are listed as required.
+func Generate(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	schema := generate(t)
+	schema.Schema = Draft04
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schema.Title = t.Name()
+	return schema
+}
+
+func generate(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes []byte as base64
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: generate(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: generate(t.Elem())}
+	case reflect.Struct:
+		return generateStruct(t)
+	default:
+		// interface{} accepts any value
+		return &Schema{}
+	}
+}
+
+func generateStruct(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ = strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+		}
+
+		// Embedded structs without a tag are flattened like encoding/json does
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			embedded := generateStruct(field.Type)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		schema.Properties[name] = generate(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
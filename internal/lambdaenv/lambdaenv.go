@@ -0,0 +1,180 @@
+// Package lambdaenv builds the function's environment variables from
+// config.yaml: the ones read by cmd/export, internal/events, internal/tenant,
+// internal/database, internal/cache, internal/worker and the rest of the
+// handler's packages. setup and deploy both set it, so it is built in one
+// place for the two to agree on every variable.
+package lambdaenv
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+// Resources are the values setup and deploy look up in AWS rather than read
+// from config.yaml.
+type Resources struct {
+	// DatabaseHost is the RDS Proxy endpoint, set when database.proxy_name is
+	DatabaseHost string
+	// WorkerQueueURL is set when worker.queue_name is
+	WorkerQueueURL string
+}
+
+// Function collects the environment variables derived from cfg.
+// lambda.environment is applied last, so its values win.
+func Function(cfg *config.Config, resources Resources) map[string]string {
+	env := map[string]string{}
+	for _, section := range []map[string]string{
+		export(cfg),
+		events(cfg),
+		tenant(cfg),
+		database(cfg, resources.DatabaseHost),
+		cache(cfg),
+		worker(cfg, resources.WorkerQueueURL),
+	} {
+		for k, v := range section {
+			env[k] = v
+		}
+	}
+	// The handler's SDK clients read these, so it reaches AWS the way the CLI does
+	if cfg.AWS.FIPS {
+		env["AWS_USE_FIPS_ENDPOINT"] = "true"
+	}
+	if cfg.AWS.DualStack {
+		env["AWS_USE_DUALSTACK_ENDPOINT"] = "true"
+	}
+	if len(cfg.Egress.Allow) > 0 {
+		env["EGRESS_ALLOW"] = strings.Join(cfg.Egress.Allow, ",")
+	}
+	if cfg.DynConfig.Parameter != "" {
+		env["DYNCONFIG_PARAMETER"] = cfg.DynConfig.Parameter
+		env["DYNCONFIG_TTL"] = cfg.DynConfig.TTL
+	}
+	if cfg.Shadow.Function != "" {
+		env["SHADOW_FUNCTION"] = cfg.Shadow.Function
+		env["SHADOW_SAMPLE_RATE"] = strconv.FormatFloat(cfg.Shadow.SampleRate, 'f', -1, 64)
+	}
+	if cfg.Shadow.Primary != "" {
+		env["SHADOW_PRIMARY"] = cfg.Shadow.Primary
+	}
+	if cfg.Report.SlackWebhook != "" || cfg.Report.SNSTopicARN != "" {
+		env["DIGEST_FUNCTIONS"] = strings.Join(cfg.DeployedFunctions(), ",")
+		env["DIGEST_SLACK_WEBHOOK"] = cfg.Report.SlackWebhook
+		env["DIGEST_SNS_TOPIC_ARN"] = cfg.Report.SNSTopicARN
+	}
+	if cfg.Backup.Bucket != "" {
+		env["BACKUP_BUCKET"] = cfg.Backup.Bucket
+		env["BACKUP_PREFIX"] = cfg.BackupPrefix()
+		env["BACKUP_FUNCTIONS"] = strings.Join(cfg.DeployedFunctions(), ",")
+	}
+	if cfg.ErrorReporting.DSN != "" {
+		env["ERROR_REPORTING_DSN"] = cfg.ErrorReporting.DSN
+		env["ERROR_REPORTING_ENVIRONMENT"] = cfg.ErrorReporting.Environment
+		if cfg.ErrorReporting.SampleRate > 0 {
+			env["ERROR_REPORTING_SAMPLE_RATE"] = strconv.FormatFloat(cfg.ErrorReporting.SampleRate, 'f', -1, 64)
+		}
+		// Tag reports with the commit being deployed; outside a git checkout they go untagged
+		if sha, err := hostexec.Output(exec.Command("git", "rev-parse", "HEAD")); err == nil {
+			env["ERROR_REPORTING_RELEASE"] = strings.TrimSpace(string(sha))
+		}
+	}
+	// lambda.environment is set as written, over the values above
+	for k, v := range cfg.Lambda.Environment {
+		env[k] = v
+	}
+	return env
+}
+
+// export maps the export section onto the variables read by cmd/export.
+func export(cfg *config.Config) map[string]string {
+	if cfg.Export.Bucket == "" {
+		return nil
+	}
+	return map[string]string{
+		"EXPORT_SOURCE_TYPE":   cfg.Export.Source.Type,
+		"EXPORT_SOURCE_TABLE":  cfg.Export.Source.Table,
+		"EXPORT_SOURCE_URL":    cfg.Export.Source.URL,
+		"EXPORT_BUCKET":        cfg.Export.Bucket,
+		"EXPORT_PREFIX":        cfg.Export.Prefix,
+		"EXPORT_GLUE_DATABASE": cfg.Export.Glue.Database,
+		"EXPORT_GLUE_TABLE":    cfg.Export.Glue.Table,
+	}
+}
+
+// events maps the events section onto the variables read by internal/events.
+func events(cfg *config.Config) map[string]string {
+	if cfg.Events.BusName == "" {
+		return nil
+	}
+	return map[string]string{
+		"EVENT_BUS_NAME": cfg.Events.BusName,
+		"EVENT_SOURCE":   cfg.Events.Source,
+	}
+}
+
+// tenant maps the tenant section onto the variables read by internal/tenant.
+func tenant(cfg *config.Config) map[string]string {
+	if cfg.Tenant.Path == "" && cfg.Tenant.Claim == "" {
+		return nil
+	}
+	return map[string]string{
+		"TENANT_PATH":            cfg.Tenant.Path,
+		"TENANT_CLAIM":           cfg.Tenant.Claim,
+		"TENANT_REQUIRED":        strconv.FormatBool(cfg.Tenant.Required),
+		"TENANT_MAX_CONCURRENCY": strconv.Itoa(cfg.Tenant.MaxConcurrency),
+		"TENANT_RATE_LIMIT":      strconv.FormatFloat(cfg.Tenant.RateLimit, 'f', -1, 64),
+		"TENANT_BURST":           strconv.Itoa(cfg.Tenant.Burst),
+	}
+}
+
+// database maps the database section onto the variables read by
+// internal/database, connecting through the proxy at host.
+func database(cfg *config.Config, host string) map[string]string {
+	if host == "" {
+		return nil
+	}
+	engine := cfg.Database.Engine
+	if engine == "" {
+		engine = "postgres"
+	}
+	port := "5432"
+	if engine == "mysql" {
+		port = "3306"
+	}
+	return map[string]string{
+		"DB_ENGINE": engine,
+		"DB_HOST":   host,
+		"DB_PORT":   port,
+		"DB_NAME":   cfg.Database.Name,
+		"DB_USER":   cfg.Database.User,
+	}
+}
+
+// cache maps the cache section onto the variables read by internal/cache.
+func cache(cfg *config.Config) map[string]string {
+	if cfg.Cache.Backend == "" && cfg.Cache.Address == "" {
+		return nil
+	}
+	return map[string]string{
+		"CACHE_BACKEND": cfg.Cache.Backend,
+		"CACHE_ADDRESS": cfg.Cache.Address,
+		"CACHE_TLS":     strconv.FormatBool(cfg.Cache.TLS),
+	}
+}
+
+// worker maps the worker section onto the variables read by internal/worker,
+// with the queue at queueURL.
+func worker(cfg *config.Config, queueURL string) map[string]string {
+	if queueURL == "" {
+		return nil
+	}
+	return map[string]string{
+		"WORKER_QUEUE_URL":    queueURL,
+		"WORKER_BACKOFF_BASE": cfg.Worker.BackoffBase,
+		"WORKER_BACKOFF_MAX":  cfg.Worker.BackoffMax,
+		"WORKER_DEDUP_TTL":    cfg.Worker.DedupTTL,
+	}
+}
@@ -0,0 +1,50 @@
+package lambdaenv
+
+import (
+	"testing"
+
+	"example-lambda-go/internal/config"
+	"example-lambda-go/internal/hostexec"
+)
+
+func TestFunction(t *testing.T) {
+	fake := hostexec.NewFake()
+	fake.On([]string{"git", "rev-parse", "HEAD"}, hostexec.Response{Output: []byte("abc123\n")})
+	previous := hostexec.Default
+	hostexec.Default = fake
+	t.Cleanup(func() { hostexec.Default = previous })
+
+	var cfg config.Config
+	cfg.Database.Engine = "mysql"
+	cfg.Database.Name = "app"
+	cfg.Worker.BackoffBase = "1s"
+	cfg.Cache.Address = "cache:6379"
+	cfg.ErrorReporting.DSN = "https://key@sentry.example.com/1"
+	cfg.Lambda.Environment = map[string]string{"CACHE_TLS": "true", "LOG_LEVEL": "debug"}
+
+	env := Function(&cfg, Resources{DatabaseHost: "proxy.example.com", WorkerQueueURL: "https://sqs/123/jobs"})
+	for name, want := range map[string]string{
+		"DB_HOST":                 "proxy.example.com",
+		"DB_PORT":                 "3306",
+		"DB_NAME":                 "app",
+		"WORKER_QUEUE_URL":        "https://sqs/123/jobs",
+		"WORKER_BACKOFF_BASE":     "1s",
+		"CACHE_ADDRESS":           "cache:6379",
+		"ERROR_REPORTING_RELEASE": "abc123",
+		// lambda.environment wins over the derived values
+		"CACHE_TLS": "true",
+		"LOG_LEVEL": "debug",
+	} {
+		if env[name] != want {
+			t.Errorf("%s = %q, want %q", name, env[name], want)
+		}
+	}
+
+	// Sections whose resources were not looked up are left out
+	env = Function(&cfg, Resources{})
+	for _, name := range []string{"DB_HOST", "WORKER_QUEUE_URL"} {
+		if _, ok := env[name]; ok {
+			t.Errorf("%s set without its resource", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package lambdareport reads the REPORT line Lambda logs at the end of each
+// invocation, with its durations and memory, in either of Lambda's log
+// formats. invoke reads it from the log tail, compare from the tails of both
+// qualifiers and logs inspect from CloudWatch Logs.
+package lambdareport
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Report holds the fields of a REPORT line by name, with their units as
+// Lambda prints them, e.g. "Billed Duration": "13 ms". With tracing on it
+// includes the X-Ray fields, e.g. "XRAY TraceId".
+type Report map[string]string
+
+// Parse returns the first report in logs, one or more lines of the text
+// format or, as the platform.report record, of the JSON format.
+func Parse(logs string) (Report, bool) {
+	lines := strings.Split(logs, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "REPORT ") {
+			r := Report{}
+			r.addFields(line)
+			// The trace follows on the next line
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "XRAY ") {
+				r.addFields(lines[i+1])
+			}
+			return r, true
+		}
+		if r, ok := parseRecord(line); ok {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// addFields adds the tab-separated "Name: value" fields of line. The first
+// field's name keeps the line's prefix unless it is REPORT.
+func (r Report) addFields(line string) {
+	for _, field := range strings.Split(line, "\t") {
+		name, value, ok := strings.Cut(field, ": ")
+		if ok {
+			r[strings.TrimPrefix(strings.TrimSpace(name), "REPORT ")] = strings.TrimSpace(value)
+		}
+	}
+}
+
+// parseRecord reads line as a platform.report record.
+func parseRecord(line string) (Report, bool) {
+	var record struct {
+		Type   string
+		Record struct {
+			RequestID string
+			Status    string
+			Metrics   struct {
+				DurationMs       *json.Number
+				BilledDurationMs *json.Number
+				InitDurationMs   *json.Number
+				MemorySizeMB     *json.Number
+				MaxMemoryUsedMB  *json.Number
+			}
+			Tracing struct {
+				Value string
+			}
+		}
+	}
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &record) != nil || record.Type != "platform.report" {
+		return nil, false
+	}
+	r := Report{}
+	set := func(name string, value string) {
+		if value != "" {
+			r[name] = value
+		}
+	}
+	withUnit := func(n *json.Number, unit string) string {
+		if n == nil {
+			return ""
+		}
+		return n.String() + " " + unit
+	}
+	metrics := record.Record.Metrics
+	set("RequestId", record.Record.RequestID)
+	set("Duration", withUnit(metrics.DurationMs, "ms"))
+	set("Billed Duration", withUnit(metrics.BilledDurationMs, "ms"))
+	set("Init Duration", withUnit(metrics.InitDurationMs, "ms"))
+	set("Memory Size", withUnit(metrics.MemorySizeMB, "MB"))
+	set("Max Memory Used", withUnit(metrics.MaxMemoryUsedMB, "MB"))
+	if record.Record.Status != "" && record.Record.Status != "success" {
+		set("Status", record.Record.Status)
+	}
+	// Root=1-abc-def;Parent=...;Sampled=1
+	for _, part := range strings.Split(record.Record.Tracing.Value, ";") {
+		if traceID, ok := strings.CutPrefix(part, "Root="); ok {
+			set("XRAY TraceId", traceID)
+		}
+	}
+	return r, true
+}
+
+// Number returns the field's value without its unit, e.g. 13 for a Billed
+// Duration of 13 ms.
+func (r Report) Number(name string) (string, bool) {
+	number, _, _ := strings.Cut(r[name], " ")
+	return number, number != ""
+}
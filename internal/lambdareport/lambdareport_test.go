@@ -0,0 +1,53 @@
+package lambdareport
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		logs string
+		want Report
+		ok   bool
+	}{
+		{
+			logs: "START RequestId: abc Version: $LATEST\n" +
+				"hello\n" +
+				"END RequestId: abc\n" +
+				"REPORT RequestId: abc\tDuration: 12.34 ms\tBilled Duration: 13 ms\tMemory Size: 128 MB\tMax Memory Used: 31 MB\tInit Duration: 80.1 ms\t\n" +
+				"XRAY TraceId: 1-abc-def\tSegmentId: 123\tSampled: true\n",
+			want: Report{
+				"RequestId": "abc", "Duration": "12.34 ms", "Billed Duration": "13 ms", "Memory Size": "128 MB", "Max Memory Used": "31 MB", "Init Duration": "80.1 ms",
+				"XRAY TraceId": "1-abc-def", "SegmentId": "123", "Sampled": "true",
+			},
+			ok: true,
+		},
+		{
+			logs: `{"time":"2024-07-01T00:00:00Z","type":"platform.start","record":{"requestId":"abc"}}` + "\n" +
+				`{"time":"2024-07-01T00:00:00Z","type":"platform.report","record":{"requestId":"abc","status":"timeout","metrics":{"durationMs":12.34,"billedDurationMs":13,"memorySizeMB":128,"maxMemoryUsedMB":31},"tracing":{"type":"X-Amzn-Trace-Id","value":"Root=1-abc-def;Parent=123;Sampled=1"}}}` + "\n",
+			want: Report{
+				"RequestId": "abc", "Status": "timeout", "Duration": "12.34 ms", "Billed Duration": "13 ms", "Memory Size": "128 MB", "Max Memory Used": "31 MB",
+				"XRAY TraceId": "1-abc-def",
+			},
+			ok: true,
+		},
+		// No REPORT line
+		{logs: "...ed output\nEND RequestId: abc\n"},
+	} {
+		got, ok := Parse(test.logs)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q) = %v, %v; want %v, %v", test.logs, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	r := Report{"Billed Duration": "13 ms"}
+	if got, ok := r.Number("Billed Duration"); got != "13" || !ok {
+		t.Errorf("Number(Billed Duration) = %q, %v", got, ok)
+	}
+	if _, ok := r.Number("Init Duration"); ok {
+		t.Error("Number(Init Duration) found a missing field")
+	}
+}
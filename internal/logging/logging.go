@@ -0,0 +1,28 @@
+// Package logging carries a structured logger through the request context so
+// middleware can attach attributes (request ID, tenant, route) that show up on
+// every line logged while handling the invocation.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+type loggerKey struct{}
+
+var base = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// FromContext returns the request logger, or the base JSON logger when the
+// context carries none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return base
+}
+
+// With returns a context whose logger includes the given attributes.
+func With(ctx context.Context, args ...any) context.Context {
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).With(args...))
+}
@@ -0,0 +1,74 @@
+// Package maintenance short-circuits invocations while the function is in
+// maintenance mode. `maintenance on` sets MAINTENANCE_MODE on the function,
+// which starts fresh execution environments that pick the flag up at init.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"example-lambda-go/internal/middleware"
+)
+
+// ErrMaintenance is returned for queue and stream batches so the records are
+// retried once maintenance ends instead of being dropped.
+var ErrMaintenance = errors.New("function is in maintenance mode")
+
+const defaultResponse = `{"message":"Service is undergoing maintenance, please retry later."}`
+
+// Enabled reports whether MAINTENANCE_MODE is on.
+func Enabled() bool {
+	return os.Getenv("MAINTENANCE_MODE") == "on"
+}
+
+// Middleware returns the maintenance response (MAINTENANCE_RESPONSE or a
+// default message) instead of calling the handler. HTTP events receive it as
+// the JSON body of a 503, in the response format of their payload version.
+func Middleware() middleware.Middleware {
+	response := body(os.Getenv("MAINTENANCE_RESPONSE"))
+	headers := map[string]string{"Content-Type": "application/json", "Retry-After": "300"}
+
+	return func(next lambda.Handler) lambda.Handler {
+		if !Enabled() {
+			return next
+		}
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			var shape struct {
+				Version    string            `json:"version"`
+				RawPath    string            `json:"rawPath"`
+				HTTPMethod string            `json:"httpMethod"`
+				Records    []json.RawMessage `json:"Records"`
+			}
+			json.Unmarshal(payload, &shape)
+
+			switch {
+			case shape.RawPath != "" && shape.Version == "2.0":
+				return json.Marshal(events.APIGatewayV2HTTPResponse{StatusCode: 503, Headers: headers, Body: response})
+			case shape.HTTPMethod != "":
+				return json.Marshal(events.APIGatewayProxyResponse{StatusCode: 503, Headers: headers, Body: response})
+			case len(shape.Records) > 0:
+				return nil, ErrMaintenance
+			default:
+				return []byte(response), nil
+			}
+		})
+	}
+}
+
+// body is the configured response when it is JSON, and otherwise the
+// default message's shape with it as the message.
+func body(response string) string {
+	if response == "" {
+		return defaultResponse
+	}
+	if json.Valid([]byte(response)) {
+		return response
+	}
+	data, _ := json.Marshal(map[string]string{"message": response})
+	return string(data)
+}
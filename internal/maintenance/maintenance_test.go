@@ -0,0 +1,59 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"example-lambda-go/internal/middleware"
+)
+
+func invoke(t *testing.T, payload string) ([]byte, error) {
+	t.Helper()
+	handler := Middleware()(middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		t.Fatal("handler called in maintenance mode")
+		return nil, nil
+	}))
+	return handler.Invoke(context.Background(), []byte(payload))
+}
+
+func TestHTTPEventsGetJSONResponse(t *testing.T) {
+	t.Setenv("MAINTENANCE_MODE", "on")
+	t.Setenv("MAINTENANCE_RESPONSE", "Back soon")
+
+	for name, payload := range map[string]string{
+		"HTTP API":     `{"version":"2.0","rawPath":"/orders"}`,
+		"function URL": `{"version":"2.0","rawPath":"/"}`,
+		"REST API":     `{"httpMethod":"GET","path":"/orders"}`,
+	} {
+		output, err := invoke(t, payload)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var response events.APIGatewayProxyResponse
+		if err := json.Unmarshal(output, &response); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if response.StatusCode != 503 || response.Headers["Content-Type"] != "application/json" || response.Body != `{"message":"Back soon"}` {
+			t.Errorf("%s: response = %+v", name, response)
+		}
+	}
+}
+
+func TestBatchesAreRetried(t *testing.T) {
+	t.Setenv("MAINTENANCE_MODE", "on")
+	if _, err := invoke(t, `{"Records":[{"body":"x"}]}`); !errors.Is(err, ErrMaintenance) {
+		t.Errorf("err = %v, want ErrMaintenance", err)
+	}
+}
+
+func TestDirectInvokeGetsConfiguredJSON(t *testing.T) {
+	t.Setenv("MAINTENANCE_MODE", "on")
+	t.Setenv("MAINTENANCE_RESPONSE", `{"message":"Back at noon"}`)
+	if output, err := invoke(t, `{"name":"Ada"}`); err != nil || string(output) != `{"message":"Back at noon"}` {
+		t.Errorf("output = %s, %v", output, err)
+	}
+}
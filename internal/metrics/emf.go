@@ -0,0 +1,106 @@
+// Package metrics writes CloudWatch metrics using the Embedded Metric Format.
+// Lambda forwards stdout to CloudWatch Logs, which extracts the metrics from
+// the structured log line without any API calls from the function.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Unit string
+
+const (
+	Count        Unit = "Count"
+	Milliseconds Unit = "Milliseconds"
+	Bytes        Unit = "Bytes"
+	None         Unit = "None"
+)
+
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  Unit
+}
+
+type dimensionsKey struct{}
+
+// WithDimension returns a context whose metrics are all tagged with key=value.
+func WithDimension(ctx context.Context, key, value string) context.Context {
+	dimensions := map[string]string{key: value}
+	for k, v := range dimensionsFromContext(ctx) {
+		if k != key {
+			dimensions[k] = v
+		}
+	}
+	return context.WithValue(ctx, dimensionsKey{}, dimensions)
+}
+
+func dimensionsFromContext(ctx context.Context) map[string]string {
+	dimensions, _ := ctx.Value(dimensionsKey{}).(map[string]string)
+	return dimensions
+}
+
+var (
+	mu  sync.Mutex
+	out io.Writer = os.Stdout
+)
+
+// SetOutput redirects emitted metrics, mainly for local runs.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+// Emit writes one EMF record containing all metrics under the given namespace.
+// The dimensions are combined with any added to ctx through WithDimension.
+func Emit(ctx context.Context, namespace string, dimensions map[string]string, metrics ...Metric) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	record := map[string]interface{}{}
+	for k, v := range dimensionsFromContext(ctx) {
+		record[k] = v
+	}
+	for k, v := range dimensions {
+		record[k] = v
+	}
+	dimensionKeys := make([]string, 0, len(record))
+	for k := range record {
+		dimensionKeys = append(dimensionKeys, k)
+	}
+
+	definitions := make([]map[string]string, 0, len(metrics))
+	for _, m := range metrics {
+		unit := m.Unit
+		if unit == "" {
+			unit = None
+		}
+		definitions = append(definitions, map[string]string{"Name": m.Name, "Unit": string(unit)})
+		record[m.Name] = m.Value
+	}
+
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{{
+			"Namespace":  namespace,
+			"Dimensions": [][]string{dimensionKeys},
+			"Metrics":    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	out.Write(append(line, '\n'))
+}
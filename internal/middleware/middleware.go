@@ -0,0 +1,26 @@
+// Package middleware composes wrappers around a lambda.Handler. Middleware
+// operate on the raw payload so they work with any event type.
+package middleware
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+type Middleware func(next lambda.Handler) lambda.Handler
+
+// HandlerFunc adapts a function to the lambda.Handler interface.
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+func (f HandlerFunc) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return f(ctx, payload)
+}
+
+// Chain wraps h so that the first middleware is the outermost.
+func Chain(h lambda.Handler, middlewares ...Middleware) lambda.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
@@ -0,0 +1,175 @@
+// Package output keeps the console readable when several tasks (builds,
+// deploys to several functions or regions) write at the same time. Each
+// task's lines are prefixed with the task and resource; successful tasks
+// collapse to a single line unless Verbose is set, failed tasks print
+// everything they wrote; Summary prints a table of all tasks at the end.
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type Mux struct {
+	// Verbose streams every line as it is written instead of collapsing
+	// successful tasks
+	Verbose bool
+
+	mu    sync.Mutex
+	out   io.Writer
+	tasks []*Task
+}
+
+func New(out io.Writer) *Mux {
+	return &Mux{out: out}
+}
+
+// Task is an io.Writer for one task's output. It is safe to hand to
+// exec.Cmd.Stdout and Stderr at the same time.
+type Task struct {
+	Name     string
+	Resource string
+
+	mux      *Mux
+	mu       sync.Mutex
+	partial  []byte
+	lines    [][]byte
+	started  time.Time
+	duration time.Duration
+	err      error
+	done     bool
+	skipped  bool
+}
+
+// Task starts a task; resource names what it acts on, e.g. "hello-world (us-east-1)".
+func (m *Mux) Task(name, resource string) *Task {
+	t := &Task{Name: name, Resource: resource, mux: m, started: time.Now()}
+	m.mu.Lock()
+	m.tasks = append(m.tasks, t)
+	m.mu.Unlock()
+	return t
+}
+
+func (t *Task) prefix() string {
+	if t.Resource == "" {
+		return fmt.Sprintf("[%s] ", t.Name)
+	}
+	return fmt.Sprintf("[%s %s] ", t.Name, t.Resource)
+}
+
+// Write splits p into lines; only complete lines are emitted or buffered so
+// lines from parallel tasks never interleave mid-line. Docker's progress
+// output uses \r, which is treated as a line end.
+func (t *Task) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexAny(t.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := bytes.Clone(t.partial[:i])
+		t.partial = t.partial[i+1:]
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		t.emit(line)
+	}
+	return len(p), nil
+}
+
+func (t *Task) emit(line []byte) {
+	if t.mux.Verbose {
+		t.mux.mu.Lock()
+		fmt.Fprintf(t.mux.out, "%s%s\n", t.prefix(), line)
+		t.mux.mu.Unlock()
+		return
+	}
+	t.lines = append(t.lines, line)
+}
+
+// Done finishes the task. Successful tasks print one line; failed tasks
+// replay their buffered output first.
+func (t *Task) Done(err error) {
+	t.mu.Lock()
+	if len(t.partial) > 0 {
+		t.emit(t.partial)
+		t.partial = nil
+	}
+	t.duration = time.Since(t.started)
+	t.err = err
+	t.done = true
+	lines := t.lines
+	t.mu.Unlock()
+
+	m := t.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		fmt.Fprintf(m.out, "%sok (%s)\n", t.prefix(), t.duration.Round(100*time.Millisecond))
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(m.out, "%s%s\n", t.prefix(), line)
+	}
+	fmt.Fprintf(m.out, "%sfailed after %s: %v\n", t.prefix(), t.duration.Round(100*time.Millisecond), err)
+}
+
+// Skip records a task that did not run, e.g. because an earlier run
+// completed it, and prints why.
+func (m *Mux) Skip(name, resource, reason string) {
+	t := &Task{Name: name, Resource: resource, mux: m, done: true, skipped: true}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks = append(m.tasks, t)
+	fmt.Fprintf(m.out, "%sskipped: %s\n", t.prefix(), reason)
+}
+
+// Summary prints one row per task: task, result, duration and resource.
+func (m *Mux) Summary() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.tasks) == 0 {
+		return
+	}
+	fmt.Fprintln(m.out)
+	w := tabwriter.NewWriter(m.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tRESULT\tDURATION\tRESOURCE")
+	for _, t := range m.tasks {
+		t.mu.Lock()
+		result, duration := "ok", t.duration
+		switch {
+		case !t.done:
+			result, duration = "running", time.Since(t.started)
+		case t.err != nil:
+			result = "failed"
+		case t.skipped:
+			result = "skipped"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, result, duration.Round(100*time.Millisecond), t.Resource)
+		t.mu.Unlock()
+	}
+	w.Flush()
+}
+
+type taskKey struct{}
+
+// WithTask returns a context carrying t for Writer.
+func WithTask(ctx context.Context, t *Task) context.Context {
+	return context.WithValue(ctx, taskKey{}, t)
+}
+
+// Writer returns the task writer in ctx, or os.Stdout outside a task.
+func Writer(ctx context.Context) io.Writer {
+	if t, ok := ctx.Value(taskKey{}).(*Task); ok {
+		return t
+	}
+	return os.Stdout
+}
@@ -0,0 +1,109 @@
+// Package partition gives the ARN prefix and DNS suffix of the AWS partition
+// a region belongs to. ARNs and endpoints built by hand must use it rather
+// than arn:aws: and amazonaws.com, which only hold in the commercial regions.
+// Service principals such as lambda.amazonaws.com are the same in every
+// partition and need no translation.
+package partition
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Partition struct {
+	// ID is the partition in ARNs, e.g. aws-us-gov
+	ID string
+	// DNSSuffix ends the service endpoints, e.g. amazonaws.com.cn
+	DNSSuffix string
+	// DualStackDNSSuffix ends the dual-stack (IPv6) ECR registry hosts
+	DualStackDNSSuffix string
+}
+
+var (
+	AWS      = Partition{ID: "aws", DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "on.aws"}
+	GovCloud = Partition{ID: "aws-us-gov", DNSSuffix: "amazonaws.com", DualStackDNSSuffix: "on.aws"}
+	China    = Partition{ID: "aws-cn", DNSSuffix: "amazonaws.com.cn", DualStackDNSSuffix: "on.amazonwebservices.com.cn"}
+)
+
+// Endpoints picks the FIPS and dual-stack (IPv6) variants of the endpoints.
+// The SDK builds its own endpoints from the same two switches; only hosts
+// built by hand, such as the registry docker pushes to, need them here.
+type Endpoints struct {
+	FIPS      bool
+	DualStack bool
+}
+
+// ForRegion returns the partition of region, e.g. GovCloud for
+// us-gov-west-1. Unknown regions are taken to be commercial.
+func ForRegion(region string) Partition {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return GovCloud
+	case strings.HasPrefix(region, "cn-"):
+		return China
+	}
+	return AWS
+}
+
+// ARN builds an ARN in the partition. region and account are empty for
+// global resources, e.g. ARN("s3", "", "", "bucket/key").
+func (p Partition) ARN(service, region, account, resource string) string {
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s", p.ID, service, region, account, resource)
+}
+
+// ManagedPolicyARN returns the ARN of an AWS managed policy, e.g.
+// service-role/AWSLambdaBasicExecutionRole.
+func (p Partition) ManagedPolicyARN(name string) string {
+	return p.ARN("iam", "", "aws", "policy/"+name)
+}
+
+// Host returns the regional endpoint host of service, e.g.
+// sqs.cn-north-1.amazonaws.com.cn.
+func (p Partition) Host(service, region string) string {
+	return fmt.Sprintf("%s.%s.%s", service, region, p.DNSSuffix)
+}
+
+// Registry returns the ECR registry host of the account in region.
+func (p Partition) Registry(account, region string) string {
+	return account + ".dkr." + p.Host("ecr", region)
+}
+
+// PushRegistry returns the ECR registry host of the account in region for
+// the endpoint variant, e.g. 123.dkr-ecr-fips.us-gov-west-1.on.aws. Lambda
+// still names images by Registry; both hosts serve the same repositories.
+func (p Partition) PushRegistry(account, region string, e Endpoints) string {
+	service, suffix := "dkr.ecr", p.DNSSuffix
+	if e.DualStack {
+		service, suffix = "dkr-ecr", p.DualStackDNSSuffix
+	}
+	if e.FIPS {
+		service += "-fips"
+	}
+	return fmt.Sprintf("%s.%s.%s.%s", account, service, region, suffix)
+}
+
+// PushURI moves a repository URI as ECR reports it to the registry host of
+// the endpoint variant. URIs of other registries, such as an emulator's, are
+// returned unchanged.
+func (p Partition) PushURI(repositoryURI string, e Endpoints) string {
+	host, path, _ := strings.Cut(repositoryURI, "/")
+	account, rest, ok := strings.Cut(host, ".dkr.ecr.")
+	region := strings.TrimSuffix(rest, "."+p.DNSSuffix)
+	if !ok || region == rest || strings.Contains(region, ".") {
+		return repositoryURI
+	}
+	return p.PushRegistry(account, region, e) + "/" + path
+}
+
+// Console returns the base URL of the AWS console for region, e.g.
+// https://us-east-1.console.aws.amazon.com. GovCloud and China have one
+// console host each; their pages take the region as a parameter only.
+func (p Partition) Console(region string) string {
+	switch p.ID {
+	case GovCloud.ID:
+		return "https://console.amazonaws-us-gov.com"
+	case China.ID:
+		return "https://console.amazonaws.cn"
+	}
+	return "https://" + region + ".console.aws.amazon.com"
+}
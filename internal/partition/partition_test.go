@@ -0,0 +1,68 @@
+package partition
+
+import "testing"
+
+func TestForRegion(t *testing.T) {
+	for _, test := range []struct {
+		region                                 string
+		arn, managed, queue, registry, console string
+	}{
+		{"us-east-1",
+			"arn:aws:lambda:us-east-1:123:function:hello",
+			"arn:aws:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole",
+			"sqs.us-east-1.amazonaws.com",
+			"123.dkr.ecr.us-east-1.amazonaws.com",
+			"https://us-east-1.console.aws.amazon.com"},
+		{"us-gov-west-1",
+			"arn:aws-us-gov:lambda:us-gov-west-1:123:function:hello",
+			"arn:aws-us-gov:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole",
+			"sqs.us-gov-west-1.amazonaws.com",
+			"123.dkr.ecr.us-gov-west-1.amazonaws.com",
+			"https://console.amazonaws-us-gov.com"},
+		{"cn-north-1",
+			"arn:aws-cn:lambda:cn-north-1:123:function:hello",
+			"arn:aws-cn:iam::aws:policy/service-role/AWSLambdaBasicExecutionRole",
+			"sqs.cn-north-1.amazonaws.com.cn",
+			"123.dkr.ecr.cn-north-1.amazonaws.com.cn",
+			"https://console.amazonaws.cn"},
+	} {
+		p := ForRegion(test.region)
+		if got := p.ARN("lambda", test.region, "123", "function:hello"); got != test.arn {
+			t.Errorf("%s: ARN() = %s, want %s", test.region, got, test.arn)
+		}
+		if got := p.ManagedPolicyARN("service-role/AWSLambdaBasicExecutionRole"); got != test.managed {
+			t.Errorf("%s: ManagedPolicyARN() = %s, want %s", test.region, got, test.managed)
+		}
+		if got := p.Host("sqs", test.region); got != test.queue {
+			t.Errorf("%s: Host() = %s, want %s", test.region, got, test.queue)
+		}
+		if got := p.Registry("123", test.region); got != test.registry {
+			t.Errorf("%s: Registry() = %s, want %s", test.region, got, test.registry)
+		}
+		if got := p.Console(test.region); got != test.console {
+			t.Errorf("%s: Console() = %s, want %s", test.region, got, test.console)
+		}
+	}
+}
+
+func TestPushURI(t *testing.T) {
+	const uri = "123.dkr.ecr.us-gov-west-1.amazonaws.com/hello"
+	for _, test := range []struct {
+		uri       string
+		endpoints Endpoints
+		want      string
+	}{
+		{uri, Endpoints{}, uri},
+		{uri, Endpoints{FIPS: true}, "123.dkr.ecr-fips.us-gov-west-1.amazonaws.com/hello"},
+		{uri, Endpoints{DualStack: true}, "123.dkr-ecr.us-gov-west-1.on.aws/hello"},
+		{uri, Endpoints{FIPS: true, DualStack: true}, "123.dkr-ecr-fips.us-gov-west-1.on.aws/hello"},
+		{"localhost.localstack.cloud:4510/hello", Endpoints{FIPS: true}, "localhost.localstack.cloud:4510/hello"},
+	} {
+		if got := GovCloud.PushURI(test.uri, test.endpoints); got != test.want {
+			t.Errorf("PushURI(%s, %+v) = %s, want %s", test.uri, test.endpoints, got, test.want)
+		}
+	}
+	if got := China.PushURI("123.dkr.ecr.cn-north-1.amazonaws.com.cn/hello", Endpoints{DualStack: true}); got != "123.dkr-ecr.cn-north-1.on.amazonwebservices.com.cn/hello" {
+		t.Errorf("China PushURI() = %s", got)
+	}
+}
@@ -0,0 +1,185 @@
+// Package pipeline times the steps of setup and deploy and, when an OTLP
+// endpoint is configured, exports them as OpenTelemetry spans under one root
+// span per run. Teams aggregating deploys from many repos can then see where
+// the time goes and alert on regressions.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"example-lambda-go/internal/hostexec"
+	"example-lambda-go/internal/output"
+)
+
+type Config struct {
+	// Endpoint is an OTLP/HTTP base URL such as http://collector:4318.
+	// OTEL_EXPORTER_OTLP_ENDPOINT is used when it is empty.
+	Endpoint    string            `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name"`
+}
+
+type Pipeline struct {
+	// Output collects each step's output; set Output.Verbose to stream it
+	Output *output.Mux
+	// OnEnd, if set, is called once when the run ends, including through
+	// Fatalf, with the error that ended it
+	OnEnd func(err error)
+
+	ctx      context.Context
+	root     trace.Span
+	tracer   trace.Tracer
+	provider *sdktrace.TracerProvider
+	resource string
+	started  time.Time
+	ended    bool
+	steps    []StepResult
+}
+
+// StepResult is how one step of a run went.
+type StepResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+	// Skipped is the reason a step was not run
+	Skipped string
+}
+
+// Start begins a run named after the command (e.g. "deploy"). attributes
+// are added to every span, e.g. the function name and region.
+func Start(name string, cfg Config, attributes map[string]string) *Pipeline {
+	p := &Pipeline{
+		Output:   output.New(os.Stdout),
+		resource: attributes["faas.name"],
+		started:  time.Now(),
+		tracer:   noop.NewTracerProvider().Tracer(""),
+	}
+	if region := attributes["cloud.region"]; region != "" {
+		p.resource += " (" + region + ")"
+	}
+
+	var attrs []attribute.KeyValue
+	for k, v := range attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	if sha, err := hostexec.Output(exec.Command("git", "rev-parse", "HEAD")); err == nil {
+		attrs = append(attrs, attribute.String("vcs.revision", strings.TrimSpace(string(sha))))
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint != "" {
+		provider, err := newProvider(endpoint, cfg, attrs)
+		if err != nil {
+			log.Printf("Deploy tracing disabled: %v", err)
+		} else {
+			p.provider = provider
+			p.tracer = provider.Tracer("example-lambda-go/internal/pipeline")
+		}
+	}
+
+	p.ctx, p.root = p.tracer.Start(context.Background(), name, trace.WithAttributes(attrs...))
+	return p
+}
+
+func newProvider(endpoint string, cfg Config, attrs []attribute.KeyValue) (*sdktrace.TracerProvider, error) {
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/") + "/v1/traces")}
+	if len(cfg.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "lambda-template"
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, append(attrs, semconv.ServiceName(serviceName))...)
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// Step runs fn inside a span and as an output task; fn writes its output to
+// output.Writer(ctx).
+func (p *Pipeline) Step(name string, fn func(ctx context.Context) error) error {
+	ctx, span := p.tracer.Start(p.ctx, name)
+	task := p.Output.Task(name, p.resource)
+	started := time.Now()
+	err := fn(output.WithTask(ctx, task))
+	task.Done(err)
+	p.steps = append(p.steps, StepResult{Name: name, Duration: time.Since(started), Err: err})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+// Skip records a step that is not run this time, with the reason.
+func (p *Pipeline) Skip(name, reason string) {
+	p.Output.Skip(name, p.resource, reason)
+	p.steps = append(p.steps, StepResult{Name: name, Skipped: reason})
+}
+
+// Steps returns the steps run or skipped so far, in order.
+func (p *Pipeline) Steps() []StepResult {
+	return p.steps
+}
+
+// Started is when the run began.
+func (p *Pipeline) Started() time.Time {
+	return p.started
+}
+
+// End closes the run, prints the step summary and flushes the spans.
+func (p *Pipeline) End(err error) {
+	if p.ended {
+		return
+	}
+	p.ended = true
+	if err != nil {
+		p.root.RecordError(err)
+		p.root.SetStatus(codes.Error, err.Error())
+	}
+	p.root.End()
+
+	p.Output.Summary()
+	fmt.Printf("Total: %s\n", time.Since(p.started).Round(100*time.Millisecond))
+	if p.OnEnd != nil {
+		p.OnEnd(err)
+	}
+
+	if p.provider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.provider.Shutdown(ctx); err != nil {
+			log.Printf("Failed to export deploy spans: %v", err)
+		}
+	}
+}
+
+// Fatalf ends the run as failed before exiting like log.Fatalf, which would
+// otherwise skip flushing the spans.
+func (p *Pipeline) Fatalf(format string, args ...interface{}) {
+	p.End(fmt.Errorf(format, args...))
+	log.Fatalf(format, args...)
+}
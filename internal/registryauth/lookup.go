@@ -0,0 +1,45 @@
+package registryauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// RepositoryAPI is the ECR call RepositoryURI makes.
+type RepositoryAPI interface {
+	DescribeRepositories(ctx context.Context, params *ecr.DescribeRepositoriesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeRepositoriesOutput, error)
+}
+
+// RepositoryURI asks ECR for the URI of the repository name rather than
+// building it from the account and region, which does not hold for emulators
+// such as LocalStack.
+func RepositoryURI(ctx context.Context, client RepositoryAPI, name string) (string, error) {
+	output, err := client.DescribeRepositories(ctx, &ecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{name},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe ECR repository %s: %v", name, err)
+	}
+	if len(output.Repositories) == 0 {
+		return "", fmt.Errorf("ECR repository %s not found", name)
+	}
+	return aws.ToString(output.Repositories[0].RepositoryUri), nil
+}
+
+// IdentityAPI is the STS call AccountID makes.
+type IdentityAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// AccountID returns the account of the client's credentials.
+func AccountID(ctx context.Context, client IdentityAPI) (string, error) {
+	identity, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get AWS Account ID: %v", err)
+	}
+	return aws.ToString(identity.Account), nil
+}
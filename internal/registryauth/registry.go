@@ -0,0 +1,137 @@
+package registryauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// TokenAPI is the ECR call tokens come from.
+type TokenAPI interface {
+	GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// Token is an ECR authorization token: the password of the user AWS on every
+// repository of the registry, valid for 12 hours.
+type Token struct {
+	Password string
+	Expires  time.Time
+}
+
+// FetchToken asks ECR for a token.
+func FetchToken(ctx context.Context, client TokenAPI) (Token, error) {
+	output, err := client.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return Token{}, err
+	}
+	if len(output.AuthorizationData) == 0 {
+		return Token{}, fmt.Errorf("no authorization data returned")
+	}
+	data := output.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(aws.ToString(data.AuthorizationToken))
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to decode authorization token: %v", err)
+	}
+	// The token is user:password, and the user is always AWS
+	_, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return Token{}, fmt.Errorf("malformed authorization token")
+	}
+	return Token{Password: password, Expires: aws.ToTime(data.ExpiresAt)}, nil
+}
+
+// refreshWindow is how long before it expires a cached token is replaced, so
+// a push that takes a while does not run out of it halfway.
+const refreshWindow = 15 * time.Minute
+
+// Tokens hands out the ECR token for docker login and the registry API,
+// asking ECR again only once it nears expiry. Client must be the ECR client
+// of the registry profile, whose credentials docker's ECR helper gets too. The token is kept in memory
+// only; it can push to every repository in the account.
+type Tokens struct {
+	Client TokenAPI
+
+	mu    sync.Mutex
+	token Token
+}
+
+// Password returns the password of a token valid for at least refreshWindow.
+func (t *Tokens) Password(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.Password != "" && time.Until(t.token.Expires) > refreshWindow {
+		return t.token.Password, nil
+	}
+	token, err := FetchToken(ctx, t.Client)
+	if err != nil {
+		return "", err
+	}
+	t.token = token
+	return token.Password, nil
+}
+
+// ErrNotFound is returned for a manifest the registry does not have.
+var ErrNotFound = errors.New("manifest not found")
+
+// manifestTypes are the manifests Docker and BuildKit push, single-platform
+// and index alike.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Registry calls the HTTP API of an ECR registry, the OCI distribution API,
+// with a token from Tokens. It only reads what the registry holds, such as
+// which image a tag names, and needs no docker login or Docker config for it;
+// images are still pushed and pulled by docker. Given the Tokens docker login
+// takes its password from, it acts as the same principal as docker.
+type Registry struct {
+	// Host is the registry, e.g. 123456789012.dkr.ecr.us-east-1.amazonaws.com
+	Host   string
+	Tokens *Tokens
+	HTTP   *http.Client
+}
+
+// Digest returns the digest of the manifest reference, a tag or a digest,
+// names in repository.
+func (r *Registry) Digest(ctx context.Context, repository, reference string) (string, error) {
+	password, err := r.Tokens.Password(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an ECR token: %v", err)
+	}
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.Host, repository, reference)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("AWS", password)
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	resp, err := r.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%s/%s:%s: %w", r.Host, repository, reference, ErrNotFound)
+	default:
+		return "", fmt.Errorf("%s/%s:%s: registry answered %s", r.Host, repository, reference, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("%s/%s:%s: registry sent no Docker-Content-Digest", r.Host, repository, reference)
+	}
+	return digest, nil
+}
@@ -0,0 +1,272 @@
+// Package registryauth makes ECR credentials available to the docker
+// commands setup and deploy run. docker login hands the password to the
+// credential store the Docker config names, and some stores reject it: the
+// ECR credential helper cannot store anything, and osxkeychain fails over
+// SSH or with the keychain locked. Login works out which store is in use,
+// skips the login when the ECR helper already answers for the registry, and
+// otherwise falls back to a Docker config of its own when the store fails.
+// Either way the docker commands that follow run with the environment Login
+// returns, so they and the helper use the registry profile's credentials.
+// Checks that need no image data, such as which image a tag names, skip
+// docker altogether and call the registry API through Registry.
+package registryauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+// ECRHelper is the name Docker configs use for amazon-ecr-credential-helper,
+// whose binary is docker-credential-ecr-login.
+const ECRHelper = "ecr-login"
+
+// lookPath finds credential helper binaries; tests replace it.
+var lookPath = exec.LookPath
+
+// dockerConfig is the part of config.json that decides where credentials go.
+type dockerConfig struct {
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// Store is the credential store Docker uses for one registry.
+type Store struct {
+	// Helper is the docker-credential-<Helper> binary, or empty when
+	// credentials are kept in config.json itself
+	Helper string
+	// PerRegistry is set when the helper comes from credHelpers rather than
+	// the credsStore every registry shares
+	PerRegistry bool
+}
+
+func (s Store) String() string {
+	switch {
+	case s.Helper == "":
+		return "config.json"
+	case s.PerRegistry:
+		return "credHelpers: " + s.Helper
+	}
+	return "credsStore: " + s.Helper
+}
+
+// ConfigDir is the directory the docker CLI reads config.json from.
+func ConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// Detect returns the store Docker would use for registry. A missing or
+// unreadable config.json means credentials are kept in the file.
+func Detect(registry string) Store {
+	var cfg dockerConfig
+	if data, err := os.ReadFile(filepath.Join(ConfigDir(), "config.json")); err == nil {
+		json.Unmarshal(data, &cfg)
+	}
+	if helper := cfg.CredHelpers[registry]; helper != "" {
+		return Store{Helper: helper, PerRegistry: true}
+	}
+	return Store{Helper: cfg.CredsStore}
+}
+
+// Auth is what Login authenticates to a registry with.
+type Auth struct {
+	// Password returns the password of an ECR token for docker login
+	Password func() (string, error)
+	// Credentials are the AWS credentials the token is fetched with, those
+	// of the registry profile. The ECR credential helper gets them too;
+	// left to itself it would use the default chain, another principal.
+	Credentials aws.CredentialsProvider
+	// Region is the registry's region
+	Region string
+}
+
+// Login makes the ECR password for registry available to the docker commands
+// that push or pull, writing what it does to w, and returns the variables to
+// add to those commands' environment: the registry credentials when the ECR
+// helper answers, and DOCKER_CONFIG when the login has to fall back to a
+// config of its own. The process environment is left alone.
+func Login(ctx context.Context, registry string, auth Auth, w io.Writer) ([]string, error) {
+	store := Detect(registry)
+	if store.Helper == ECRHelper {
+		if _, err := lookPath("docker-credential-" + ECRHelper); err != nil {
+			return nil, fmt.Errorf("the Docker config (%s) uses the ECR credential helper for %s, but docker-credential-%s is not on PATH; install amazon-ecr-credential-helper or remove it from %s",
+				store, registry, ECRHelper, filepath.Join(ConfigDir(), "config.json"))
+		}
+		fmt.Fprintf(w, "Using docker-credential-%s for %s (%s)\n", ECRHelper, registry, store)
+		return auth.env(ctx)
+	}
+
+	secret, err := auth.Password()
+	if err != nil {
+		return nil, fmt.Errorf("getting the ECR password: %v", err)
+	}
+	output, err := login(registry, secret, nil, w)
+	if err == nil || !storeFailed(output) {
+		return nil, err
+	}
+	fmt.Fprintf(w, "docker login could not store the password with %s; retrying with a separate Docker config\n", store)
+
+	dir, err := fallbackConfig(registry)
+	if err != nil {
+		return nil, fmt.Errorf("credential store %s failed and no fallback config could be written: %v", store, err)
+	}
+	env := []string{"DOCKER_CONFIG=" + dir}
+	if _, err := lookPath("docker-credential-" + ECRHelper); err == nil {
+		fmt.Fprintf(w, "Using docker-credential-%s for %s from %s\n", ECRHelper, registry, dir)
+		credentials, err := auth.env(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return append(env, credentials...), nil
+	}
+	if _, err := login(registry, secret, env, w); err != nil {
+		return nil, fmt.Errorf("%v; docker login failed with %s and with a config of its own, so check that docker can reach %s. "+
+			"Installing amazon-ecr-credential-helper and adding \"credHelpers\": {%q: %q} to config.json avoids docker login altogether",
+			err, store, registry, registry, ECRHelper)
+	}
+	return env, nil
+}
+
+// env returns the credentials and region as the variables the ECR helper
+// reads first, ahead of any profile.
+func (a Auth) env(ctx context.Context) ([]string, error) {
+	if a.Credentials == nil {
+		return nil, nil
+	}
+	credentials, err := a.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting the registry credentials for docker-credential-%s: %v", ECRHelper, err)
+	}
+	// Set even when empty, so a session token of the process's own
+	// credentials is not paired with these keys
+	env := []string{
+		"AWS_ACCESS_KEY_ID=" + credentials.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY=" + credentials.SecretAccessKey,
+		"AWS_SESSION_TOKEN=" + credentials.SessionToken,
+	}
+	if a.Region != "" {
+		env = append(env, "AWS_REGION="+a.Region)
+	}
+	return env, nil
+}
+
+// Command gives cmd, a docker command, the environment Login returned.
+func Command(cmd *exec.Cmd, env []string) *exec.Cmd {
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+// login runs docker login with the password on stdin and env added to its
+// environment, returning what it printed alongside writing it to w.
+func login(registry, password string, env []string, w io.Writer) (string, error) {
+	var output bytes.Buffer
+	cmd := Command(hostexec.DockerLogin(registry), env)
+	cmd.Stdin = strings.NewReader(password)
+	cmd.Stdout = io.MultiWriter(w, &output)
+	cmd.Stderr = io.MultiWriter(w, &output)
+	err := hostexec.Run(cmd)
+	return output.String(), err
+}
+
+// storeFailed reports whether docker login output blames the credential
+// store rather than the registry.
+func storeFailed(output string) bool {
+	output = strings.ToLower(output)
+	for _, sign := range []string{
+		"error saving credentials",
+		"error storing credentials",
+		"error getting credentials",
+		"docker-credential-",
+		"keychain",
+		"not implemented",
+	} {
+		if strings.Contains(output, sign) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackConfig writes a Docker config without the failing store to a
+// directory of this tool's own and returns it. The ECR helper answers for
+// registry when it is installed; otherwise docker login keeps the password in
+// the file, as it does without any store. Everything else in the user's
+// Docker directory, such as contexts and CLI plugins, is linked in, so
+// builds use the same daemon and buildx as before.
+func fallbackConfig(registry string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "lambda-template", "docker")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	source := ConfigDir()
+
+	cfg := map[string]any{}
+	if data, err := os.ReadFile(filepath.Join(source, "config.json")); err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return "", fmt.Errorf("%s: %v", filepath.Join(source, "config.json"), err)
+		}
+	}
+	delete(cfg, "credsStore")
+	helpers, _ := cfg["credHelpers"].(map[string]any)
+	if helpers == nil {
+		helpers = map[string]any{}
+	}
+	delete(helpers, registry)
+	if _, err := lookPath("docker-credential-" + ECRHelper); err == nil {
+		helpers[registry] = ECRHelper
+	}
+	cfg["credHelpers"] = helpers
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0o600); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Name() == "config.json" {
+			continue
+		}
+		link := filepath.Join(dir, entry.Name())
+		if info, err := os.Lstat(link); err == nil {
+			if info.Mode()&os.ModeSymlink == 0 {
+				// Written by docker itself while using this config
+				continue
+			}
+			os.Remove(link)
+		}
+		if err := os.Symlink(filepath.Join(source, entry.Name()), link); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
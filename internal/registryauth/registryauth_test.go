@@ -0,0 +1,244 @@
+package registryauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+
+	"example-lambda-go/internal/hostexec"
+)
+
+const registry = "123456789012.dkr.ecr.us-east-1.amazonaws.com"
+
+// useDockerConfig points DOCKER_CONFIG at a directory holding config, the
+// cache at another, and fakes the docker commands and the helpers on PATH.
+func useDockerConfig(t *testing.T, config string, helpers ...string) (*hostexec.Fake, string) {
+	dir := t.TempDir()
+	if config != "" {
+		if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	fake := hostexec.NewFake()
+	previous, previousLookPath := hostexec.Default, lookPath
+	hostexec.Default = fake
+	lookPath = func(file string) (string, error) {
+		for _, helper := range helpers {
+			if file == "docker-credential-"+helper {
+				return "/usr/local/bin/" + file, nil
+			}
+		}
+		return "", errors.New("not found")
+	}
+	t.Cleanup(func() { hostexec.Default, lookPath = previous, previousLookPath })
+	return fake, dir
+}
+
+func password() (string, error) { return "secret", nil }
+
+// auth has the registry profile's credentials.
+var auth = Auth{
+	Password:    password,
+	Credentials: credentials.NewStaticCredentialsProvider("AKIDREGISTRY", "registry-secret", ""),
+	Region:      "us-east-1",
+}
+
+// lookup returns the value of key in env.
+func lookup(env []string, key string) string {
+	value := ""
+	for _, variable := range env {
+		if k, v, _ := strings.Cut(variable, "="); k == key {
+			value = v
+		}
+	}
+	return value
+}
+
+func TestDetect(t *testing.T) {
+	useDockerConfig(t, `{"credsStore":"osxkeychain","credHelpers":{"`+registry+`":"ecr-login"}}`)
+	if store := Detect(registry); store != (Store{Helper: "ecr-login", PerRegistry: true}) {
+		t.Errorf("Detect(%s) = %v", registry, store)
+	}
+	if store := Detect("ghcr.io"); store.String() != "credsStore: osxkeychain" {
+		t.Errorf("Detect(ghcr.io) = %v", store)
+	}
+
+	useDockerConfig(t, "")
+	if store := Detect(registry); store.String() != "config.json" {
+		t.Errorf("Detect without a config = %v", store)
+	}
+}
+
+func TestLoginUsesECRHelper(t *testing.T) {
+	fake, _ := useDockerConfig(t, `{"credsStore":"ecr-login"}`, "ecr-login")
+	helper := auth
+	helper.Password = func() (string, error) { return "", errors.New("GetAuthorizationToken should not be called") }
+
+	env, err := Login(context.Background(), registry, helper, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Calls) != 0 {
+		t.Errorf("ran %q, want no docker login", fake.Commands())
+	}
+	// The helper would otherwise fall back to the default credential chain
+	if lookup(env, "AWS_ACCESS_KEY_ID") != "AKIDREGISTRY" || lookup(env, "AWS_REGION") != "us-east-1" {
+		t.Errorf("env = %q, want the registry profile's credentials", env)
+	}
+
+	useDockerConfig(t, `{"credsStore":"ecr-login"}`)
+	if _, err := Login(context.Background(), registry, helper, io.Discard); err == nil || !strings.Contains(err.Error(), "not on PATH") {
+		t.Errorf("Login without the helper installed = %v", err)
+	}
+}
+
+func TestLoginFallsBackWhenStoreFails(t *testing.T) {
+	keychain := hostexec.Response{
+		Output: []byte("Error saving credentials: error storing credentials - err: exit status 1, out: `User interaction is not allowed.`"),
+		Err:    errors.New("exit status 1"),
+	}
+
+	fake, dir := useDockerConfig(t, `{"credsStore":"osxkeychain","currentContext":"colima"}`)
+	if err := os.Mkdir(filepath.Join(dir, "contexts"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	fake.On([]string{"docker", "login"}, keychain)
+	_, err := Login(context.Background(), registry, auth, io.Discard)
+	// The fake fails the retry too, which names the way out
+	if err == nil || !strings.Contains(err.Error(), `"credHelpers"`) {
+		t.Errorf("Login = %v, want guidance on the ECR helper", err)
+	}
+	if got := len(fake.Calls); got != 2 || fake.Calls[1].Stdin != "secret" {
+		t.Errorf("ran %q, want docker login retried with the password", fake.Commands())
+	}
+	if os.Getenv("DOCKER_CONFIG") != dir {
+		t.Error("Login changed DOCKER_CONFIG of the process")
+	}
+	fallback, err := fallbackConfig(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg map[string]any
+	data, _ := os.ReadFile(filepath.Join(fallback, "config.json"))
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg["credsStore"]; ok || cfg["currentContext"] != "colima" {
+		t.Errorf("fallback config = %s, want the context kept and credsStore removed", data)
+	}
+	if target, err := os.Readlink(filepath.Join(fallback, "contexts")); err != nil || target != filepath.Join(dir, "contexts") {
+		t.Errorf("contexts links to %q, %v", target, err)
+	}
+
+	// With the ECR helper installed the fallback uses it instead
+	fake, _ = useDockerConfig(t, `{"credsStore":"osxkeychain"}`, "ecr-login")
+	fake.On([]string{"docker", "login"}, keychain)
+	env, err := Login(context.Background(), registry, auth, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Calls) != 1 {
+		t.Errorf("ran %q, want only the failed docker login", fake.Commands())
+	}
+	if lookup(env, "AWS_ACCESS_KEY_ID") != "AKIDREGISTRY" {
+		t.Errorf("env = %q, want the registry profile's credentials for the helper", env)
+	}
+	t.Setenv("DOCKER_CONFIG", lookup(env, "DOCKER_CONFIG"))
+	if store := Detect(registry); store.Helper != ECRHelper {
+		t.Errorf("fallback store = %v, want the ECR helper", store)
+	}
+}
+
+func TestLoginReportsRegistryFailure(t *testing.T) {
+	fake, dir := useDockerConfig(t, `{"credsStore":"desktop"}`)
+	fake.On([]string{"docker", "login"}, hostexec.Response{Output: []byte("Error response from daemon: Get \"https://registry/v2/\": dial tcp: i/o timeout"), Err: errors.New("exit status 1")})
+
+	env, err := Login(context.Background(), registry, auth, io.Discard)
+	if err == nil {
+		t.Fatal("Login succeeded")
+	}
+	if len(fake.Calls) != 1 || len(env) != 0 || os.Getenv("DOCKER_CONFIG") != dir {
+		t.Errorf("a registry failure fell back: ran %q with %q", fake.Commands(), env)
+	}
+}
+
+type fakeTokenAPI struct {
+	calls   int
+	expires time.Time
+}
+
+func (f *fakeTokenAPI) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	f.calls++
+	token := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("AWS:secret%d", f.calls)))
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []ecrtypes.AuthorizationData{{AuthorizationToken: aws.String(token), ExpiresAt: aws.Time(f.expires)}},
+	}, nil
+}
+
+func TestTokensCacheUntilNearExpiry(t *testing.T) {
+	client := &fakeTokenAPI{expires: time.Now().Add(12 * time.Hour)}
+	tokens := &Tokens{Client: client}
+	for i := 0; i < 2; i++ {
+		if password, err := tokens.Password(context.Background()); err != nil || password != "secret1" {
+			t.Fatalf("Password() = %q, %v, want the first token", password, err)
+		}
+	}
+
+	client.expires = time.Now().Add(refreshWindow / 2)
+	tokens = &Tokens{Client: client}
+	tokens.Password(context.Background())
+	if password, _ := tokens.Password(context.Background()); password != "secret3" || client.calls != 3 {
+		t.Errorf("Password() of an expiring token = %q after %d calls, want a new one", password, client.calls)
+	}
+}
+
+func TestRegistryDigest(t *testing.T) {
+	const digest = "sha256:4f2b0c1d3e5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "AWS" || password != "secret1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodHead || !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json") {
+			t.Errorf("%s with Accept %q", r.Method, r.Header.Get("Accept"))
+		}
+		if r.URL.Path != "/v2/team/hello/manifests/latest" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+
+	r := Registry{
+		Host:   strings.TrimPrefix(server.URL, "https://"),
+		Tokens: &Tokens{Client: &fakeTokenAPI{expires: time.Now().Add(12 * time.Hour)}},
+		HTTP:   server.Client(),
+	}
+	got, err := r.Digest(context.Background(), "team/hello", "latest")
+	if err != nil || got != digest {
+		t.Errorf("Digest(latest) = %q, %v, want %s", got, err, digest)
+	}
+	if _, err := r.Digest(context.Background(), "team/hello", "v2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Digest(v2) = %v, want ErrNotFound", err)
+	}
+}
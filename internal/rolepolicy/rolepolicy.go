@@ -0,0 +1,42 @@
+// Package rolepolicy attaches the AWS managed policies that settings need to
+// the function's execution role, as setup does when it creates the role and
+// deploy for settings added after setup.
+package rolepolicy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+
+	"example-lambda-go/internal/config"
+)
+
+// Managed policies, by their name in the partition's aws account.
+const (
+	BasicExecution = "service-role/AWSLambdaBasicExecutionRole"
+	// VPCAccess lets the function join vpc.subnet_ids
+	VPCAccess = "service-role/AWSLambdaVPCAccessExecutionRole"
+	// XRayWrite lets the function send the traces of lambda.tracing: Active,
+	// which Lambda only grants itself when tracing is turned on in the console
+	XRayWrite = "AWSXRayDaemonWriteAccess"
+)
+
+// IAMAPI is the subset of the IAM client Attach uses.
+type IAMAPI interface {
+	AttachRolePolicy(ctx context.Context, params *iam.AttachRolePolicyInput, optFns ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error)
+}
+
+// Attach gives lambda.role_name the managed policy name. Attaching it again
+// changes nothing.
+func Attach(ctx context.Context, client IAMAPI, cfg *config.Config, name string) error {
+	_, err := client.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+		RoleName:  aws.String(cfg.Lambda.RoleName),
+		PolicyArn: aws.String(cfg.Partition().ManagedPolicyARN(name)),
+	})
+	if err != nil {
+		return fmt.Errorf("error attaching %s to %s: %v", name, cfg.Lambda.RoleName, err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package router dispatches invocations of a single function to one of
+// several named handlers based on attributes of the incoming event: the HTTP
+// method and path, an SQS message attribute, the EventBridge detail-type or
+// the type of a versioned envelope.
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"gopkg.in/yaml.v2"
+
+	"example-lambda-go/internal/envelope"
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/metrics"
+)
+
+const metricsNamespace = "LambdaTemplate/Routes"
+
+type Match struct {
+	HTTPMethod string `yaml:"http_method" json:"http_method,omitempty"`
+	// HTTPPath matches the request path exactly, or as a prefix when it ends
+	// in "*" (e.g. "/api/*")
+	HTTPPath     string `yaml:"http_path" json:"http_path,omitempty"`
+	SQSAttribute struct {
+		Name  string `yaml:"name" json:"name,omitempty"`
+		Value string `yaml:"value" json:"value,omitempty"`
+	} `yaml:"sqs_attribute" json:"sqs_attribute"`
+	DetailType string `yaml:"detail_type" json:"detail_type,omitempty"`
+	// EventType matches the type of a versioned envelope (internal/envelope)
+	EventType string `yaml:"event_type" json:"event_type,omitempty"`
+}
+
+type Route struct {
+	Name    string `yaml:"name" json:"name"`
+	Handler string `yaml:"handler" json:"handler"`
+	Match   Match  `yaml:"match" json:"match"`
+	// Default marks the route used when no other route matches.
+	Default bool `yaml:"default" json:"default,omitempty"`
+}
+
+type RoutesFile struct {
+	Routes []Route `yaml:"routes"`
+}
+
+// LoadRoutes parses a routes document.
+func LoadRoutes(data []byte) ([]Route, error) {
+	var file RoutesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing routes: %v", err)
+	}
+	return file.Routes, nil
+}
+
+type Router struct {
+	routes   []Route
+	handlers map[string]lambda.Handler
+}
+
+func New(routes []Route) *Router {
+	return &Router{routes: routes, handlers: map[string]lambda.Handler{}}
+}
+
+// Register makes a handler available to routes under the given name.
+func (r *Router) Register(name string, handler lambda.Handler) {
+	r.handlers[name] = handler
+}
+
+// Routes returns the routes in the order they are tried.
+func (r *Router) Routes() []Route {
+	return r.routes
+}
+
+// Handlers returns the names of the registered handlers, sorted.
+func (r *Router) Handlers() []string {
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that every route refers to a registered handler.
+func (r *Router) Validate() error {
+	for _, route := range r.routes {
+		if _, ok := r.handlers[route.Handler]; !ok {
+			return fmt.Errorf("route %q refers to unregistered handler %q", route.Name, route.Handler)
+		}
+	}
+	return nil
+}
+
+// eventShape holds the fields used to recognise the supported event sources.
+type eventShape struct {
+	// API Gateway HTTP API and function URLs (payload format 2.0)
+	RawPath        string `json:"rawPath"`
+	RequestContext struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+	// API Gateway REST API (payload format 1.0)
+	Path       string `json:"path"`
+	HTTPMethod string `json:"httpMethod"`
+	// EventBridge
+	DetailType string `json:"detail-type"`
+	// SQS
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+}
+
+func (r *Router) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var shape eventShape
+	if err := json.Unmarshal(payload, &shape); err != nil {
+		return nil, fmt.Errorf("error decoding event: %v", err)
+	}
+
+	if len(shape.Records) > 0 && shape.Records[0].EventSource == "aws:sqs" {
+		return r.invokeSQS(ctx, payload)
+	}
+
+	route, ok := r.match(func(m Match) bool {
+		switch {
+		case m.HTTPPath != "":
+			method, path := shape.RequestContext.HTTP.Method, shape.RawPath
+			if path == "" {
+				method, path = shape.HTTPMethod, shape.Path
+			}
+			return pathMatches(m.HTTPPath, path) && (m.HTTPMethod == "" || strings.EqualFold(m.HTTPMethod, method))
+		case m.DetailType != "":
+			return m.DetailType == shape.DetailType
+		case m.EventType != "":
+			env, ok := envelope.FromContext(ctx)
+			return ok && env.Type == m.EventType
+		}
+		return false
+	})
+	if !ok {
+		return nil, fmt.Errorf("no route matches event")
+	}
+	return r.invoke(ctx, route, payload)
+}
+
+// invokeSQS groups the batch by route and merges the partial batch failures
+// reported by each handler. A handler error fails every record it was given.
+func (r *Router) invokeSQS(ctx context.Context, payload []byte) ([]byte, error) {
+	var event events.SQSEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("error decoding SQS event: %v", err)
+	}
+
+	var order []string
+	groups := map[string][]events.SQSMessage{}
+	routes := map[string]Route{}
+	for _, record := range event.Records {
+		route, ok := r.match(func(m Match) bool {
+			if m.SQSAttribute.Name == "" {
+				return false
+			}
+			attribute, ok := record.MessageAttributes[m.SQSAttribute.Name]
+			return ok && attribute.StringValue != nil && *attribute.StringValue == m.SQSAttribute.Value
+		})
+		if !ok {
+			return nil, fmt.Errorf("no route matches SQS message %s", record.MessageId)
+		}
+		if _, seen := groups[route.Name]; !seen {
+			order = append(order, route.Name)
+			routes[route.Name] = route
+		}
+		groups[route.Name] = append(groups[route.Name], record)
+	}
+
+	var response events.SQSEventResponse
+	for _, name := range order {
+		records := groups[name]
+		batch, err := json.Marshal(events.SQSEvent{Records: records})
+		if err != nil {
+			return nil, err
+		}
+
+		output, err := r.invoke(ctx, routes[name], batch)
+		if err != nil {
+			for _, record := range records {
+				response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+			}
+			continue
+		}
+
+		var partial events.SQSEventResponse
+		if len(output) > 0 && json.Unmarshal(output, &partial) == nil {
+			response.BatchItemFailures = append(response.BatchItemFailures, partial.BatchItemFailures...)
+		}
+	}
+	return json.Marshal(response)
+}
+
+func pathMatches(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
+
+func (r *Router) match(matches func(Match) bool) (Route, bool) {
+	for _, route := range r.routes {
+		if matches(route.Match) {
+			return route, true
+		}
+	}
+	for _, route := range r.routes {
+		if route.Default {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+func (r *Router) invoke(ctx context.Context, route Route, payload []byte) ([]byte, error) {
+	handler, ok := r.handlers[route.Handler]
+	if !ok {
+		return nil, fmt.Errorf("route %q refers to unregistered handler %q", route.Name, route.Handler)
+	}
+
+	ctx = logging.With(ctx, "route", route.Name)
+	ctx = metrics.WithDimension(ctx, "Route", route.Name)
+
+	started := time.Now()
+	output, err := handler.Invoke(ctx, payload)
+
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	metrics.Emit(ctx, metricsNamespace, nil,
+		metrics.Metric{Name: "Invocations", Value: 1, Unit: metrics.Count},
+		metrics.Metric{Name: "Errors", Value: failed, Unit: metrics.Count},
+		metrics.Metric{Name: "Duration", Value: float64(time.Since(started).Milliseconds()), Unit: metrics.Milliseconds},
+	)
+	return output, err
+}
@@ -0,0 +1,276 @@
+package rules
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"gopkg.in/yaml.v2"
+
+	"example-lambda-go/internal/partition"
+)
+
+// Bundle names a rules file a platform team publishes for every project,
+// signed so that only they can change it. The file has the layout of the
+// rules section (builtin, required_tags, memory, custom) and is fetched on
+// each check, so a new version applies without updating lambda-template.
+type Bundle struct {
+	// URL is https://... or s3://bucket/key
+	URL string `yaml:"url"`
+	// SignatureURL defaults to URL with .sig appended. The signature is the
+	// Ed25519 signature of the file, raw or base64, e.g. from
+	// openssl pkeyutl -sign -rawin -inkey org.pem -in rules.yaml | base64
+	SignatureURL string `yaml:"signature_url"`
+	// PublicKey verifies the signature: a PEM public key, or the base64 of
+	// the 32-byte Ed25519 key
+	PublicKey string `yaml:"public_key"`
+	// CacheTTL is how old the cached copy may be when the fetch fails,
+	// e.g. 24h; defaults to 168h
+	CacheTTL string `yaml:"cache_ttl"`
+}
+
+const defaultCacheTTL = 7 * 24 * time.Hour
+
+// S3API is the part of the S3 client s3:// bundles are fetched with.
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// validate reports a bundle that could never be fetched or verified.
+func (b Bundle) validate() error {
+	if b.URL == "" {
+		return nil
+	}
+	for _, u := range []string{b.URL, b.signatureURL()} {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "s3") || parsed.Host == "" {
+			return fmt.Errorf("rules.bundle: %s is not an https:// or s3:// URL", u)
+		}
+	}
+	if _, err := b.publicKey(); err != nil {
+		return fmt.Errorf("rules.bundle.public_key: %v", err)
+	}
+	if _, err := b.cacheTTL(); err != nil {
+		return fmt.Errorf("rules.bundle.cache_ttl: %v", err)
+	}
+	return nil
+}
+
+func (b Bundle) cacheTTL() (time.Duration, error) {
+	if b.CacheTTL == "" {
+		return defaultCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(b.CacheTTL)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%s is not positive", b.CacheTTL)
+	}
+	return ttl, nil
+}
+
+// ObjectARNs returns the S3 objects of an s3:// bundle and signature in the
+// partition, for the IAM policy of whoever runs the checks.
+func (b Bundle) ObjectARNs(p partition.Partition) []string {
+	var arns []string
+	for _, u := range []string{b.URL, b.signatureURL()} {
+		if parsed, err := url.Parse(u); err == nil && b.URL != "" && parsed.Scheme == "s3" {
+			arns = append(arns, p.ARN("s3", "", "", parsed.Host+parsed.Path))
+		}
+	}
+	return arns
+}
+
+func (b Bundle) signatureURL() string {
+	if b.SignatureURL != "" {
+		return b.SignatureURL
+	}
+	return b.URL + ".sig"
+}
+
+func (b Bundle) publicKey() (ed25519.PublicKey, error) {
+	if b.PublicKey == "" {
+		return nil, fmt.Errorf("required to verify the bundle")
+	}
+	if block, _ := pem.Decode([]byte(b.PublicKey)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("not an Ed25519 key")
+		}
+		return edKey, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("not a PEM key or the base64 of a %d-byte Ed25519 key", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// LoadBundle fetches the bundle and its signature, verifies them and returns
+// the bundle's rules. s3Client is only used for s3:// URLs and httpClient
+// for https:// ones. A verified copy
+// is kept in cacheDir; when the fetch fails it is used instead, with a
+// warning on w, so that an outage of the source doesn't stop deploys while a
+// tampered bundle still does. A copy older than the bundle's cache TTL is
+// not used, so a blocked source can't pin an outdated bundle forever.
+func LoadBundle(ctx context.Context, w io.Writer, b Bundle, s3Client S3API, httpClient *http.Client, cacheDir string) (Config, error) {
+	key, err := b.publicKey()
+	if err != nil {
+		return Config{}, fmt.Errorf("rules.bundle.public_key: %v", err)
+	}
+	ttl, err := b.cacheTTL()
+	if err != nil {
+		return Config{}, fmt.Errorf("rules.bundle.cache_ttl: %v", err)
+	}
+	bundlePath := filepath.Join(cacheDir, "rules-bundle.yaml")
+	signaturePath := bundlePath + ".sig"
+
+	data, signature, err := fetchBundle(ctx, b, s3Client, httpClient)
+	if err != nil {
+		info, cacheErr := os.Stat(bundlePath)
+		if cacheErr == nil {
+			data, cacheErr = os.ReadFile(bundlePath)
+		}
+		if cacheErr == nil {
+			signature, cacheErr = os.ReadFile(signaturePath)
+		}
+		if cacheErr != nil {
+			return Config{}, fmt.Errorf("error fetching rules bundle: %v", err)
+		}
+		if age := time.Since(info.ModTime()); age > ttl {
+			return Config{}, fmt.Errorf("error fetching rules bundle: %v; the cached copy is %s old, past rules.bundle.cache_ttl (%s)", err, age.Round(time.Minute), ttl)
+		}
+		if verifyErr := verify(key, data, signature); verifyErr != nil {
+			return Config{}, fmt.Errorf("error fetching rules bundle: %v; the cached copy is invalid: %v", err, verifyErr)
+		}
+		fmt.Fprintf(w, "WARN  rules-bundle: using the cached copy, as fetching %s failed: %v\n", b.URL, err)
+	} else {
+		if err := verify(key, data, signature); err != nil {
+			return Config{}, fmt.Errorf("rules bundle %s: %v", b.URL, err)
+		}
+		// A cache that can't be written only costs the fallback
+		if err := os.MkdirAll(cacheDir, 0o755); err == nil {
+			os.WriteFile(bundlePath, data, 0o644)
+			os.WriteFile(signaturePath, signature, 0o644)
+		}
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("error parsing rules bundle %s: %v", b.URL, err)
+	}
+	if cfg.Bundle.URL != "" {
+		return Config{}, fmt.Errorf("rules bundle %s names another bundle", b.URL)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("rules bundle %s: %v", b.URL, err)
+	}
+	return cfg, nil
+}
+
+func fetchBundle(ctx context.Context, b Bundle, s3Client S3API, httpClient *http.Client) (data, signature []byte, err error) {
+	if data, err = fetch(ctx, b.URL, s3Client, httpClient); err != nil {
+		return nil, nil, err
+	}
+	if signature, err = fetch(ctx, b.signatureURL(), s3Client, httpClient); err != nil {
+		return nil, nil, err
+	}
+	return data, signature, nil
+}
+
+func fetch(ctx context.Context, rawURL string, s3Client S3API, httpClient *http.Client) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "s3" {
+		output, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(u.Host),
+			Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s: %v", rawURL, err)
+		}
+		defer output.Body.Close()
+		return io.ReadAll(output.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verify checks signature, raw or base64, against data.
+func verify(key ed25519.PublicKey, data, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return fmt.Errorf("malformed signature")
+		}
+		signature = decoded
+	}
+	if !ed25519.Verify(key, data, signature) {
+		return fmt.Errorf("signature does not match the public key")
+	}
+	return nil
+}
+
+// Merge lays the project's rules over the organization's. The project can
+// add rules and tighten the organization's, but not loosen them: a built-in
+// rule takes the stricter of the levels the two set, required tags add up,
+// the memory band narrows and both sets of custom rules apply.
+func Merge(org, project Config) Config {
+	merged := Config{
+		Builtin: map[string]string{},
+		Custom:  append(append([]Rule(nil), org.Custom...), project.Custom...),
+		Bundle:  project.Bundle,
+	}
+	for _, tag := range append(append([]string(nil), org.RequiredTags...), project.RequiredTags...) {
+		if !contains(merged.RequiredTags, tag) {
+			merged.RequiredTags = append(merged.RequiredTags, tag)
+		}
+	}
+	for _, name := range BuiltinNames() {
+		// The organization's level, set or default, is the floor
+		level := project.Level(name)
+		if orgLevel := org.Level(name); severity[orgLevel] > severity[level] {
+			level = orgLevel
+		}
+		merged.Builtin[name] = level
+	}
+	merged.Memory.Min = max(org.Memory.Min, project.Memory.Min)
+	merged.Memory.Max = org.Memory.Max
+	if merged.Memory.Max == 0 || (project.Memory.Max > 0 && project.Memory.Max < merged.Memory.Max) {
+		merged.Memory.Max = project.Memory.Max
+	}
+	return merged
+}
+
+var severity = map[string]int{Off: 0, Warn: 1, Deny: 2}
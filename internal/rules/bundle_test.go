@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"example-lambda-go/internal/partition"
+)
+
+const orgRules = `builtin:
+  tracing: deny
+required_tags: [team]
+memory: {max: 1024}
+`
+
+// serveBundle serves body at /rules.yaml and its signature by key at
+// /rules.yaml.sig.
+func serveBundle(t *testing.T, key ed25519.PrivateKey, body string) (*httptest.Server, Bundle) {
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(body)))
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rules.yaml":
+			io.WriteString(w, body)
+		case "/rules.yaml.sig":
+			io.WriteString(w, signature+"\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	public := key.Public().(ed25519.PublicKey)
+	return server, Bundle{URL: server.URL + "/rules.yaml", PublicKey: base64.StdEncoding.EncodeToString(public)}
+}
+
+func TestLoadBundle(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	server, bundle := serveBundle(t, key, orgRules)
+	cacheDir := t.TempDir()
+
+	cfg, err := LoadBundle(context.Background(), io.Discard, bundle, nil, server.Client(), cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level("tracing") != Deny || !reflect.DeepEqual(cfg.RequiredTags, []string{"team"}) {
+		t.Errorf("LoadBundle() = %+v", cfg)
+	}
+
+	// Once the source is down, the verified copy is used with a warning
+	server.Close()
+	var out strings.Builder
+	if cfg, err = LoadBundle(context.Background(), &out, bundle, nil, server.Client(), cacheDir); err != nil || cfg.Memory.Max != 1024 {
+		t.Errorf("LoadBundle() from the cache = %+v, %v", cfg, err)
+	}
+	if !strings.Contains(out.String(), "using the cached copy") {
+		t.Errorf("no warning about the cached copy: %q", out.String())
+	}
+	if _, err := LoadBundle(context.Background(), io.Discard, bundle, nil, server.Client(), t.TempDir()); err == nil {
+		t.Error("LoadBundle() without source or cache succeeded")
+	}
+
+	// A copy past the TTL isn't used
+	old := time.Now().Add(-defaultCacheTTL - time.Hour)
+	if err := os.Chtimes(filepath.Join(cacheDir, "rules-bundle.yaml"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBundle(context.Background(), io.Discard, bundle, nil, server.Client(), cacheDir); err == nil || !strings.Contains(err.Error(), "cache_ttl") {
+		t.Errorf("LoadBundle() from an expired cache = %v, want a cache_ttl error", err)
+	}
+}
+
+func TestLoadBundleRejectsOtherSigners(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	server, bundle := serveBundle(t, otherKey, "builtin:\n  tracing: off\n")
+	bundle.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+
+	_, err := LoadBundle(context.Background(), io.Discard, bundle, nil, server.Client(), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "signature does not match") {
+		t.Errorf("LoadBundle() = %v, want a signature error", err)
+	}
+}
+
+func TestValidateBundle(t *testing.T) {
+	for _, b := range []Bundle{
+		{URL: "http://example.com/rules.yaml", PublicKey: base64.StdEncoding.EncodeToString(make([]byte, 32))},
+		{URL: "https://example.com/rules.yaml"},
+		{URL: "https://example.com/rules.yaml", PublicKey: "c2hvcnQ="},
+		{URL: "https://example.com/rules.yaml", PublicKey: base64.StdEncoding.EncodeToString(make([]byte, 32)), CacheTTL: "-1h"},
+	} {
+		if err := (Config{Bundle: b}).Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", b)
+		}
+	}
+}
+
+func TestMergeOnlyTightens(t *testing.T) {
+	org := parse(t, orgRules+"custom:\n  - {name: org-timeout, field: lambda.timeout, max: 60}\n")
+	project := parse(t, `builtin:
+  tracing: off
+  async-dlq: warn
+  mutable-image-tag: off
+required_tags: [team, service]
+memory: {min: 256, max: 2048}
+custom:
+  - {name: project-timeout, field: lambda.timeout, max: 30}
+`)
+
+	merged := Merge(org, project)
+	if merged.Level("tracing") != Deny || merged.Level("async-dlq") != Warn || merged.Level("mutable-image-tag") != Warn {
+		t.Errorf("levels = %v", merged.Builtin)
+	}
+	if !reflect.DeepEqual(merged.RequiredTags, []string{"team", "service"}) {
+		t.Errorf("required tags = %v", merged.RequiredTags)
+	}
+	if merged.Memory.Min != 256 || merged.Memory.Max != 1024 {
+		t.Errorf("memory band = %+v, want 256-1024", merged.Memory)
+	}
+	if len(merged.Custom) != 2 || merged.Custom[0].Name != "org-timeout" {
+		t.Errorf("custom = %+v", merged.Custom)
+	}
+}
+
+func TestBundleObjectARNs(t *testing.T) {
+	b := Bundle{URL: "s3://platform-policies/lambda/rules.yaml"}
+	want := []string{"arn:aws:s3:::platform-policies/lambda/rules.yaml", "arn:aws:s3:::platform-policies/lambda/rules.yaml.sig"}
+	if got := b.ObjectARNs(partition.AWS); !reflect.DeepEqual(got, want) {
+		t.Errorf("ObjectARNs() = %q, want %q", got, want)
+	}
+	if got := (Bundle{URL: "https://example.com/rules.yaml"}).ObjectARNs(partition.AWS); got != nil {
+		t.Errorf("ObjectARNs() of an https bundle = %q", got)
+	}
+}
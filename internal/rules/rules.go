@@ -0,0 +1,381 @@
+// Package rules checks the configuration a deploy is about to apply against
+// the standards a platform team sets: built-in rules for common
+// anti-patterns, and custom rules that compare any config.yaml setting with
+// a value, range or pattern. Each rule is off, warn or deny; deploy prints
+// warnings and refuses to go ahead on a denial.
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Levels a rule can have.
+const (
+	Off  = "off"
+	Warn = "warn"
+	Deny = "deny"
+)
+
+// Config is the rules section of config.yaml.
+type Config struct {
+	// Builtin sets the level of built-in rules by name, overriding the
+	// defaults in Builtins
+	Builtin map[string]string `yaml:"builtin"`
+	// RequiredTags must all be set in lambda.tags
+	RequiredTags []string `yaml:"required_tags"`
+	// Memory is the allowed band for lambda.memory_size in MB
+	Memory struct {
+		Min int `yaml:"min"`
+		Max int `yaml:"max"`
+	} `yaml:"memory"`
+	Custom []Rule `yaml:"custom"`
+	// Bundle adds the organization's rules, see LoadBundle
+	Bundle Bundle `yaml:"bundle"`
+}
+
+// Rule checks one setting, addressed by its path in config.yaml, e.g.
+// lambda.memory_size or lambda.tags.team. Every condition that is set must
+// hold. A setting that is not in the file, or is empty or zero, only passes
+// conditions when Required is false and it has no Equals or OneOf.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Level   string `yaml:"level"`
+	Message string `yaml:"message"`
+	Field   string `yaml:"field"`
+	// When names settings of which at least one must be set for the rule to
+	// apply, e.g. [export.schedule]
+	When     []string `yaml:"when"`
+	Required bool     `yaml:"required"`
+	Equals   string   `yaml:"equals"`
+	OneOf    []string `yaml:"one_of"`
+	NotOneOf []string `yaml:"not_one_of"`
+	Min      *float64 `yaml:"min"`
+	Max      *float64 `yaml:"max"`
+	Matches  string   `yaml:"matches"`
+}
+
+// Finding is a rule that did not hold.
+type Finding struct {
+	Rule, Level, Message string
+}
+
+// Builtins are the built-in rules and their default levels. The ones
+// configured by the rules section itself apply only when it sets them.
+var Builtins = map[string]string{
+	// FROM lines of the Dockerfile that use latest or no tag at all. The
+	// function's own image is exempt: deploy pushes it as latest but checks
+	// the digest the tag names, and Lambda runs that digest until the next
+	// deploy.
+	"mutable-image-tag": Warn,
+	// rules.required_tags missing from lambda.tags
+	"required-tags": Deny,
+	// lambda.memory_size outside rules.memory
+	"memory-band": Deny,
+	// lambda.tracing is not Active
+	"tracing": Off,
+	// Asynchronous triggers without lambda.dead_letter_arn
+	"async-dlq": Off,
+}
+
+// asyncTriggers are the settings that have EventBridge invoke the function
+// asynchronously, where a failure after the retries is otherwise lost.
+var asyncTriggers = []string{"export.schedule", "report.slack_webhook", "report.sns_topic_arn"}
+
+// Validate reports rules that could never be evaluated.
+func (c Config) Validate() error {
+	for name, level := range c.Builtin {
+		if _, ok := Builtins[name]; !ok {
+			return fmt.Errorf("rules.builtin: unknown rule %q", name)
+		}
+		if !validLevel(level) {
+			return fmt.Errorf("rules.builtin.%s: level must be off, warn or deny", name)
+		}
+	}
+	for i, rule := range c.Custom {
+		if rule.Name == "" || rule.Field == "" {
+			return fmt.Errorf("rules.custom[%d]: name and field are required", i)
+		}
+		if rule.Level != "" && !validLevel(rule.Level) {
+			return fmt.Errorf("rules.custom %s: level must be off, warn or deny", rule.Name)
+		}
+		if rule.Matches != "" {
+			if _, err := regexp.Compile(rule.Matches); err != nil {
+				return fmt.Errorf("rules.custom %s: invalid pattern: %v", rule.Name, err)
+			}
+		}
+	}
+	return c.Bundle.validate()
+}
+
+func validLevel(level string) bool {
+	return level == Off || level == Warn || level == Deny
+}
+
+// Evaluate checks settings, the configuration as it will be deployed, and
+// the Dockerfile. settings is encoded as YAML to address its fields by the
+// paths used in config.yaml.
+func Evaluate(cfg Config, settings interface{}, dockerfile []byte) ([]Finding, error) {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding settings: %v", err)
+	}
+	var doc map[interface{}]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding settings: %v", err)
+	}
+
+	var findings []Finding
+	for _, rule := range cfg.builtinRules() {
+		if message, ok := rule.check(doc); !ok {
+			findings = append(findings, Finding{rule.Name, rule.Level, message})
+		}
+	}
+	if level := cfg.Level("mutable-image-tag"); level != Off {
+		for _, image := range mutableImages(dockerfile) {
+			findings = append(findings, Finding{"mutable-image-tag", level,
+				fmt.Sprintf("Dockerfile uses %s, which can change under the same name; pin a version tag or digest", image)})
+		}
+	}
+	for _, rule := range cfg.Custom {
+		if rule.Level == "" {
+			rule.Level = Warn
+		}
+		if rule.Level == Off {
+			continue
+		}
+		if message, ok := rule.check(doc); !ok {
+			findings = append(findings, Finding{rule.Name, rule.Level, message})
+		}
+	}
+	return findings, nil
+}
+
+// Check evaluates the rules against settings and the Dockerfile at
+// dockerfilePath, prints the findings to w and returns an error if any of
+// them denies the deploy.
+func Check(w io.Writer, cfg Config, settings interface{}, dockerfilePath string) error {
+	dockerfile, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", dockerfilePath, err)
+	}
+	findings, err := Evaluate(cfg, settings, dockerfile)
+	if err != nil {
+		return err
+	}
+	Print(w, findings)
+	if Denied(findings) {
+		return fmt.Errorf("the configuration breaks rules set to deny")
+	}
+	return nil
+}
+
+// Denied reports whether any finding blocks the deploy.
+func Denied(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Level == Deny {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes one line per finding, denials first.
+func Print(w io.Writer, findings []Finding) {
+	sorted := append([]Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Level == Deny && sorted[j].Level != Deny })
+	for _, f := range sorted {
+		fmt.Fprintf(w, "%s  %s: %s\n", strings.ToUpper(f.Level), f.Rule, f.Message)
+	}
+}
+
+// BuiltinNames returns the names of the built-in rules in order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(Builtins))
+	for name := range Builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Level returns the level of the built-in rule after rules.builtin.
+func (c Config) Level(name string) string {
+	if level, ok := c.Builtin[name]; ok {
+		return level
+	}
+	return Builtins[name]
+}
+
+// builtinRules expresses the built-in rules other than mutable-image-tag as
+// rules on settings.
+func (c Config) builtinRules() []Rule {
+	var rules []Rule
+	add := func(rule Rule) {
+		if rule.Level = c.Level(rule.Name); rule.Level != Off {
+			rules = append(rules, rule)
+		}
+	}
+	for _, tag := range c.RequiredTags {
+		add(Rule{Name: "required-tags", Field: "lambda.tags." + tag, Required: true,
+			Message: fmt.Sprintf("lambda.tags must set %s", tag)})
+	}
+	if c.Memory.Min > 0 || c.Memory.Max > 0 {
+		rule := Rule{Name: "memory-band", Field: "lambda.memory_size",
+			Message: fmt.Sprintf("lambda.memory_size must be between %d and %d MB", c.Memory.Min, c.Memory.Max)}
+		if c.Memory.Min > 0 {
+			min := float64(c.Memory.Min)
+			rule.Min, rule.Required = &min, true
+		}
+		if c.Memory.Max > 0 {
+			max := float64(c.Memory.Max)
+			rule.Max = &max
+		}
+		add(rule)
+	}
+	add(Rule{Name: "tracing", Field: "lambda.tracing", Equals: "Active",
+		Message: "lambda.tracing should be Active so requests can be followed in X-Ray"})
+	add(Rule{Name: "async-dlq", Field: "lambda.dead_letter_arn", Required: true, When: asyncTriggers,
+		Message: "the function has asynchronous triggers but no lambda.dead_letter_arn to keep failed events"})
+	return rules
+}
+
+// check reports whether the rule holds for doc, and the message if not.
+func (r Rule) check(doc map[interface{}]interface{}) (string, bool) {
+	if len(r.When) > 0 {
+		applies := false
+		for _, field := range r.When {
+			if value, ok := lookup(doc, field); ok && !empty(value) {
+				applies = true
+				break
+			}
+		}
+		if !applies {
+			return "", true
+		}
+	}
+
+	value, ok := lookup(doc, r.Field)
+	unset := !ok || empty(value)
+	text := fmt.Sprint(value)
+	fail := func(format string, args ...interface{}) (string, bool) {
+		if r.Message != "" {
+			return r.Message, false
+		}
+		return r.Field + " " + fmt.Sprintf(format, args...), false
+	}
+
+	switch {
+	case unset && (r.Required || r.Equals != "" || len(r.OneOf) > 0):
+		return fail("is not set")
+	case unset:
+		return "", true
+	case r.Equals != "" && text != r.Equals:
+		return fail("is %s, must be %s", text, r.Equals)
+	case len(r.OneOf) > 0 && !contains(r.OneOf, text):
+		return fail("is %s, must be one of %s", text, strings.Join(r.OneOf, ", "))
+	case contains(r.NotOneOf, text):
+		return fail("must not be %s", text)
+	case r.Matches != "" && !regexp.MustCompile(r.Matches).MatchString(text):
+		return fail("is %s, must match %s", text, r.Matches)
+	}
+	if r.Min != nil || r.Max != nil {
+		n, err := strconv.ParseFloat(text, 64)
+		switch {
+		case err != nil:
+			return fail("is %s, must be a number", text)
+		case r.Min != nil && n < *r.Min:
+			return fail("is %s, must be at least %g", text, *r.Min)
+		case r.Max != nil && n > *r.Max:
+			return fail("is %s, must be at most %g", text, *r.Max)
+		}
+	}
+	return "", true
+}
+
+func lookup(doc map[interface{}]interface{}, path string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[interface{}]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// mutableImages returns the base images of FROM lines that have no tag or
+// the latest tag. Earlier stages, scratch and images named by a build
+// argument are skipped.
+func mutableImages(dockerfile []byte) []string {
+	var images []string
+	stages := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(dockerfile))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		image := args[0]
+		switch {
+		case stages[strings.ToLower(image)], image == "scratch", strings.Contains(image, "$"), strings.Contains(image, "@"):
+		default:
+			name := image[strings.LastIndex(image, "/")+1:]
+			if !strings.Contains(name, ":") || strings.HasSuffix(name, ":latest") {
+				images = append(images, image)
+			}
+		}
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+	}
+	return images
+}
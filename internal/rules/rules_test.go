@@ -0,0 +1,164 @@
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// settings stands in for the configuration, which is only read through its
+// YAML encoding.
+type settings struct {
+	Lambda struct {
+		MemorySize    int               `yaml:"memory_size"`
+		Timeout       int               `yaml:"timeout"`
+		Tags          map[string]string `yaml:"tags"`
+		Tracing       string            `yaml:"tracing"`
+		DeadLetterARN string            `yaml:"dead_letter_arn"`
+	} `yaml:"lambda"`
+	Export struct {
+		Schedule string `yaml:"schedule"`
+	} `yaml:"export"`
+}
+
+func parse(t *testing.T, doc string) Config {
+	t.Helper()
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(doc), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func names(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Level+" "+f.Rule)
+	}
+	return out
+}
+
+const pinnedDockerfile = "FROM golang:1.22.3 AS build\nFROM build\n"
+
+func TestBuiltinRules(t *testing.T) {
+	cfg := parse(t, `
+required_tags: [team, cost-center]
+memory: {min: 256, max: 1024}
+builtin:
+  tracing: warn
+  async-dlq: deny
+`)
+	var s settings
+	s.Lambda.MemorySize = 2048
+	s.Lambda.Tags = map[string]string{"team": "payments"}
+	s.Export.Schedule = "rate(1 day)"
+
+	findings, err := Evaluate(cfg, s, []byte(pinnedDockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"deny required-tags", "deny memory-band", "warn tracing", "deny async-dlq"}
+	if got := names(findings); !reflect.DeepEqual(got, want) {
+		t.Errorf("findings = %q, want %q", got, want)
+	}
+	if !Denied(findings) {
+		t.Error("Denied() = false")
+	}
+
+	s.Lambda.MemorySize = 512
+	s.Lambda.Tags["cost-center"] = "cc-1"
+	s.Lambda.Tracing = "Active"
+	s.Lambda.DeadLetterARN = "arn:aws:sqs:us-west-2:123456789012:hello-dlq"
+	if findings, _ := Evaluate(cfg, s, []byte(pinnedDockerfile)); len(findings) != 0 {
+		t.Errorf("findings = %q, want none", names(findings))
+	}
+}
+
+func TestMemoryBandRequiresMemorySize(t *testing.T) {
+	cfg := parse(t, "memory: {min: 256}\n")
+	findings, _ := Evaluate(cfg, settings{}, []byte(pinnedDockerfile))
+	if got := names(findings); !reflect.DeepEqual(got, []string{"deny memory-band"}) {
+		t.Errorf("findings = %q, want the Lambda default of 128 MB refused", got)
+	}
+}
+
+func TestCustomRules(t *testing.T) {
+	cfg := parse(t, `
+custom:
+  - name: short-timeouts
+    level: deny
+    field: lambda.timeout
+    max: 60
+  - name: tracing-mode
+    field: lambda.tracing
+    one_of: [Active, PassThrough]
+  - name: owner-email
+    field: lambda.tags.owner
+    matches: '@example\.com$'
+    message: lambda.tags.owner must be an example.com address
+  - name: disabled
+    level: off
+    field: lambda.timeout
+    min: 1000
+`)
+	var s settings
+	s.Lambda.Timeout = 300
+	s.Lambda.Tags = map[string]string{"owner": "someone@gmail.com"}
+
+	findings, err := Evaluate(cfg, s, []byte(pinnedDockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Finding{
+		{"short-timeouts", Deny, "lambda.timeout is 300, must be at most 60"},
+		{"tracing-mode", Warn, "lambda.tracing is not set"},
+		{"owner-email", Warn, "lambda.tags.owner must be an example.com address"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("findings = %+v, want %+v", findings, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, doc := range []string{
+		"builtin: {no-such-rule: warn}\n",
+		"builtin: {tracing: error}\n",
+		"custom: [{name: x}]\n",
+		"custom: [{name: x, field: lambda.timeout, matches: '('}]\n",
+	} {
+		var cfg Config
+		yaml.Unmarshal([]byte(doc), &cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%q) = nil, want an error", doc)
+		}
+	}
+}
+
+func TestMutableImages(t *testing.T) {
+	dockerfile := `
+FROM --platform=$BUILDPLATFORM golang:1.22.3 as build
+FROM public.ecr.aws/lambda/go:1 as runtime-amd64
+FROM alpine as tools
+FROM localhost:5000/base
+FROM node:latest AS assets
+FROM gcr.io/distroless/static@sha256:abc
+FROM build
+FROM scratch
+FROM runtime-${TARGETARCH}
+`
+	got := mutableImages([]byte(dockerfile))
+	want := []string{"alpine", "localhost:5000/base", "node:latest"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mutableImages() = %q, want %q", got, want)
+	}
+
+	findings, _ := Evaluate(Config{}, settings{}, []byte(dockerfile))
+	if len(findings) != 3 || findings[0].Level != Warn || !strings.Contains(findings[0].Message, "alpine") {
+		t.Errorf("findings = %+v", findings)
+	}
+}
@@ -0,0 +1,183 @@
+// Package secretenv lets environment variables reference Secrets Manager
+// secrets instead of holding their values. A variable set to
+// secretsmanager:<name or ARN>[:<json key>] is replaced at cold start with the
+// secret's value, or with one key of a secret that stores a JSON object, so
+// neither config.yaml nor the function's configuration holds the plaintext.
+package secretenv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Prefix marks a value as a reference to a secret.
+const Prefix = "secretsmanager:"
+
+// Ref is a parsed reference.
+type Ref struct {
+	// Secret is the secret's name or ARN
+	Secret string
+	// Key selects one field of a secret stored as a JSON object
+	Key string
+}
+
+// Parse reads value as a reference. It returns false for values without
+// Prefix, which are not references.
+func Parse(value string) (Ref, bool, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return Ref{}, false, nil
+	}
+	var ref Ref
+	if strings.HasPrefix(rest, "arn:") {
+		// arn:partition:secretsmanager:region:account:secret:name-suffix[:key]
+		parts := strings.SplitN(rest, ":", 8)
+		if len(parts) < 7 || parts[2] != "secretsmanager" || parts[5] != "secret" || parts[6] == "" {
+			return Ref{}, true, fmt.Errorf("%q: not a Secrets Manager secret ARN", value)
+		}
+		ref.Secret = strings.Join(parts[:7], ":")
+		if len(parts) == 8 {
+			ref.Key = parts[7]
+			if ref.Key == "" {
+				return Ref{}, true, fmt.Errorf("%q: empty key after the ARN", value)
+			}
+		}
+		return ref, true, nil
+	}
+	// Secret names cannot contain colons, so the first one starts the key
+	name, key, hasKey := strings.Cut(rest, ":")
+	if name == "" || (hasKey && key == "") {
+		return Ref{}, true, fmt.Errorf("%q: want %s<name or ARN>[:<key>]", value, Prefix)
+	}
+	return Ref{Secret: name, Key: key}, true, nil
+}
+
+// String is the reference as it is written in a variable.
+func (r Ref) String() string {
+	if r.Key == "" {
+		return Prefix + r.Secret
+	}
+	return Prefix + r.Secret + ":" + r.Key
+}
+
+// IsARN reports whether the reference names its secret by ARN.
+func (r Ref) IsARN() bool {
+	return strings.HasPrefix(r.Secret, "arn:")
+}
+
+// secretWords mark variables whose values are hidden when printed.
+var secretWords = []string{"SECRET", "PASSWORD", "TOKEN", "KEY", "DSN", "WEBHOOK", "CREDENTIAL"}
+
+// LooksSecret reports whether the variable's name suggests its value is a
+// secret that should not be printed, e.g. API_KEY or DB_PASSWORD.
+func LooksSecret(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range secretWords {
+		if strings.Contains(upper, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSecretValueAPI is the subset of the Secrets Manager client used by
+// Resolve.
+type GetSecretValueAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Resolve replaces every variable of the process environment that references
+// a secret with its value. Call it during init, before anything reads the
+// environment, so a missing secret fails the cold start instead of the first
+// request.
+func Resolve(ctx context.Context, client GetSecretValueAPI) error {
+	values, err := resolve(ctx, client, os.Environ())
+	if err != nil {
+		return err
+	}
+	for name, value := range values {
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Value returns the value of the setting name, or the secret it references.
+// The CLI uses it for settings such as report.slack_webhook that it reads
+// itself as well as passing to the function.
+func Value(ctx context.Context, client GetSecretValueAPI, name, value string) (string, error) {
+	values, err := resolve(ctx, client, []string{name + "=" + value})
+	if err != nil {
+		return "", err
+	}
+	if resolved, ok := values[name]; ok {
+		return resolved, nil
+	}
+	return value, nil
+}
+
+// resolve returns the values of the variables in environ that reference
+// secrets, reading each secret once.
+func resolve(ctx context.Context, client GetSecretValueAPI, environ []string) (map[string]string, error) {
+	values := map[string]string{}
+	secrets := map[string]string{}
+	sort.Strings(environ)
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		ref, ok, err := Parse(value)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		secret, read := secrets[ref.Secret]
+		if !read {
+			output, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(ref.Secret)})
+			if err != nil {
+				return nil, fmt.Errorf("%s: error reading secret %s: %v", name, ref.Secret, err)
+			}
+			if output.SecretString == nil {
+				return nil, fmt.Errorf("%s: secret %s is binary; only string secrets can be referenced", name, ref.Secret)
+			}
+			secret = aws.ToString(output.SecretString)
+			secrets[ref.Secret] = secret
+		}
+		if ref.Key == "" {
+			values[name] = secret
+			continue
+		}
+		field, err := jsonField(secret, ref.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: secret %s: %v", name, ref.Secret, err)
+		}
+		values[name] = field
+	}
+	return values, nil
+}
+
+// jsonField returns one key of a secret stored as a JSON object. Strings are
+// returned as they are and other values as JSON.
+func jsonField(secret, key string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("not a JSON object, so it has no key %s", key)
+	}
+	raw, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("no key %s", key)
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, nil
+	}
+	return string(raw), nil
+}
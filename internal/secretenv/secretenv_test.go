@@ -0,0 +1,112 @@
+package secretenv
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+type fakeSecretsManager struct {
+	secrets map[string]string
+	reads   []string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	id := aws.ToString(params.SecretId)
+	f.reads = append(f.reads, id)
+	secret, ok := f.secrets[id]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+func TestParse(t *testing.T) {
+	const arn = "arn:aws:secretsmanager:us-west-2:123456789012:secret:hello/db-AbCdEf"
+	for _, test := range []struct {
+		value string
+		want  Ref
+		ok    bool
+		err   string
+	}{
+		{value: "plain", ok: false},
+		{value: "secretsmanager:hello/api-key", want: Ref{Secret: "hello/api-key"}, ok: true},
+		{value: "secretsmanager:hello/db:password", want: Ref{Secret: "hello/db", Key: "password"}, ok: true},
+		{value: "secretsmanager:" + arn, want: Ref{Secret: arn}, ok: true},
+		{value: "secretsmanager:" + arn + ":password", want: Ref{Secret: arn, Key: "password"}, ok: true},
+		{value: "secretsmanager:", ok: true, err: "want secretsmanager:"},
+		{value: "secretsmanager:hello/db:", ok: true, err: "want secretsmanager:"},
+		{value: "secretsmanager:arn:aws:ssm:us-west-2:123456789012:parameter/x", ok: true, err: "not a Secrets Manager secret ARN"},
+	} {
+		got, ok, err := Parse(test.value)
+		if ok != test.ok || got != test.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v, %v", test.value, got, ok, test.want, test.ok)
+		}
+		if (err == nil) != (test.err == "") || (err != nil && !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("Parse(%q) error = %v, want %q", test.value, err, test.err)
+		}
+		if err == nil && ok && got.String() != test.value {
+			t.Errorf("Parse(%q).String() = %q", test.value, got.String())
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	client := &fakeSecretsManager{secrets: map[string]string{
+		"hello/api-key": "s3cr3t",
+		"hello/db":      `{"username": "app", "password": "hunter2", "port": 5432}`,
+	}}
+	got, err := resolve(context.Background(), client, []string{
+		"LOG_LEVEL=info",
+		"API_KEY=secretsmanager:hello/api-key",
+		"DB_USER=secretsmanager:hello/db:username",
+		"DB_PASSWORD=secretsmanager:hello/db:password",
+		"DB_PORT=secretsmanager:hello/db:port",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"API_KEY": "s3cr3t", "DB_USER": "app", "DB_PASSWORD": "hunter2", "DB_PORT": "5432"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolve = %v, want %v", got, want)
+	}
+	if len(client.reads) != 2 {
+		t.Errorf("secrets read %v, want each once", client.reads)
+	}
+
+	for _, test := range []struct {
+		variable, want string
+	}{
+		{"X=secretsmanager:hello/missing", "X: error reading secret hello/missing"},
+		{"X=secretsmanager:hello/db:host", "no key host"},
+		{"X=secretsmanager:hello/api-key:password", "not a JSON object"},
+	} {
+		if _, err := resolve(context.Background(), client, []string{test.variable}); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("resolve(%s) error = %v, want %q", test.variable, err, test.want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	client := &fakeSecretsManager{secrets: map[string]string{"hello/slack": "https://hooks.slack.com/services/T0/B0/x"}}
+	for _, test := range []struct {
+		value, want string
+	}{
+		{"secretsmanager:hello/slack", "https://hooks.slack.com/services/T0/B0/x"},
+		{"https://example.com/hook", "https://example.com/hook"},
+		{"", ""},
+	} {
+		got, err := Value(context.Background(), client, "report.slack_webhook", test.value)
+		if err != nil || got != test.want {
+			t.Errorf("Value(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+	if _, err := Value(context.Background(), client, "report.slack_webhook", "secretsmanager:hello/missing"); err == nil || !strings.Contains(err.Error(), "report.slack_webhook: error reading secret") {
+		t.Errorf("Value(missing) error = %v", err)
+	}
+}
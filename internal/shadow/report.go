@@ -0,0 +1,128 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// LogsAPI is the part of the CloudWatch Logs client the report reads with.
+type LogsAPI interface {
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
+// Comparison is one logged comparison.
+type Comparison struct {
+	ID              string `json:"shadow_id"`
+	Match           bool   `json:"match"`
+	PrimaryMS       int64  `json:"primary_ms"`
+	ShadowMS        int64  `json:"shadow_ms"`
+	PrimaryError    string `json:"primary_error"`
+	ShadowError     string `json:"shadow_error"`
+	PrimaryResponse string `json:"primary_response"`
+	ShadowResponse  string `json:"shadow_response"`
+}
+
+type Report struct {
+	Since                       time.Time
+	Total, Matches              int
+	PrimaryErrors, ShadowErrors int
+	// Latency percentiles in milliseconds
+	PrimaryP50, PrimaryP99 int64
+	ShadowP50, ShadowP99   int64
+	Mismatches             []Comparison
+}
+
+// LogGroup returns the log group of the shadow function, which may be given
+// as a name, ARN or name:alias.
+func LogGroup(function string) string {
+	name := function
+	if strings.HasPrefix(name, "arn:") {
+		// arn:aws:lambda:region:account:function:name[:qualifier]
+		if parts := strings.Split(name, ":"); len(parts) >= 7 {
+			name = parts[6]
+		}
+	} else if n, _, ok := strings.Cut(name, ":"); ok {
+		name = n
+	}
+	return "/aws/lambda/" + name
+}
+
+// Collect reads the comparisons the shadow logged since the given time.
+func Collect(ctx context.Context, client LogsAPI, logGroup string, since time.Time) (*Report, error) {
+	report := &Report{Since: since}
+	var primary, shadow []int64
+
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(client, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:  aws.String(logGroup),
+		StartTime:     aws.Int64(since.UnixMilli()),
+		FilterPattern: aws.String(fmt.Sprintf(`{ $.msg = %q }`, LogMessage)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", logGroup, err)
+		}
+		for _, event := range page.Events {
+			var c Comparison
+			if err := json.Unmarshal([]byte(aws.ToString(event.Message)), &c); err != nil || c.ID == "" {
+				continue
+			}
+			report.Total++
+			if c.Match {
+				report.Matches++
+			} else {
+				report.Mismatches = append(report.Mismatches, c)
+			}
+			if c.PrimaryError != "" {
+				report.PrimaryErrors++
+			}
+			if c.ShadowError != "" {
+				report.ShadowErrors++
+			}
+			primary = append(primary, c.PrimaryMS)
+			shadow = append(shadow, c.ShadowMS)
+		}
+	}
+
+	report.PrimaryP50, report.PrimaryP99 = percentile(primary, 0.5), percentile(primary, 0.99)
+	report.ShadowP50, report.ShadowP99 = percentile(shadow, 0.5), percentile(shadow, 0.99)
+	return report, nil
+}
+
+func percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values[int(p*float64(len(values)-1))]
+}
+
+// Print writes the summary and at most limit mismatches.
+func (r *Report) Print(w io.Writer, limit int) {
+	fmt.Fprintf(w, "Shadow comparisons since %s: %d\n", r.Since.Format(time.RFC3339), r.Total)
+	if r.Total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Matching:   %d (%.1f%%)\n", r.Matches, 100*float64(r.Matches)/float64(r.Total))
+	fmt.Fprintf(w, "Errors:     primary %d, shadow %d\n", r.PrimaryErrors, r.ShadowErrors)
+	fmt.Fprintf(w, "Latency:    primary p50 %dms p99 %dms, shadow p50 %dms p99 %dms\n", r.PrimaryP50, r.PrimaryP99, r.ShadowP50, r.ShadowP99)
+	for i, c := range r.Mismatches {
+		if i == limit {
+			fmt.Fprintf(w, "... and %d more mismatches\n", len(r.Mismatches)-limit)
+			break
+		}
+		fmt.Fprintf(w, "\nMismatch %s\n", c.ID)
+		if c.PrimaryError != "" || c.ShadowError != "" {
+			fmt.Fprintf(w, "  primary error: %s\n  shadow error:  %s\n", c.PrimaryError, c.ShadowError)
+		}
+		fmt.Fprintf(w, "  primary: %s\n  shadow:  %s\n", c.PrimaryResponse, c.ShadowResponse)
+	}
+}
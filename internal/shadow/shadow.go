@@ -0,0 +1,205 @@
+// Package shadow mirrors a sample of invocations to a second "shadow"
+// function, typically a rewrite, to validate it against real traffic before
+// cutover. The primary sends each sampled event asynchronously together with
+// its own response; the shadow, configured with the primary's name, runs its
+// handler on the event, compares the two results and logs the comparison
+// instead of returning anything. The shadow report command aggregates those
+// log lines.
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/middleware"
+)
+
+// LogMessage is the msg of the comparison log lines the report reads.
+const LogMessage = "shadow comparison"
+
+// maxPayload is the limit Lambda puts on asynchronous invocation payloads.
+const maxPayload = 256 * 1024
+
+// mirrorTimeout bounds how long the primary's response waits on queueing
+// the mirrored event; the shadow runs it asynchronously either way.
+const mirrorTimeout = 500 * time.Millisecond
+
+type Config struct {
+	// Function is the shadow's name, ARN or name:alias.
+	Function string
+	// SampleRate is the fraction of invocations mirrored, from 0 to 1.
+	SampleRate float64
+	// Primary is set on the shadow instead: the name of the function whose
+	// mirrored events it compares. Other functions treat the envelope as an
+	// ordinary event.
+	Primary string
+}
+
+// ConfigFromEnv reads the SHADOW_* variables that setup and deploy derive
+// from the shadow section of config.yaml.
+func ConfigFromEnv() Config {
+	cfg := Config{Function: os.Getenv("SHADOW_FUNCTION"), Primary: os.Getenv("SHADOW_PRIMARY")}
+	cfg.SampleRate, _ = strconv.ParseFloat(os.Getenv("SHADOW_SAMPLE_RATE"), 64)
+	return cfg
+}
+
+// Enabled reports whether invocations are mirrored.
+func (c Config) Enabled() bool {
+	return c.Function != "" && c.SampleRate > 0
+}
+
+// InvokeAPI is the part of the Lambda client the primary uses.
+type InvokeAPI interface {
+	Invoke(ctx context.Context, params *awslambda.InvokeInput, optFns ...func(*awslambda.Options)) (*awslambda.InvokeOutput, error)
+}
+
+// mirrored is the payload the shadow receives.
+type mirrored struct {
+	Shadow struct {
+		ID         string `json:"id"`
+		Source     string `json:"source"`
+		Response   []byte `json:"response,omitempty"`
+		Error      string `json:"error,omitempty"`
+		DurationMS int64  `json:"duration_ms"`
+	} `json:"shadow"`
+	Event json.RawMessage `json:"event"`
+}
+
+type shadowKey struct{}
+
+// Active reports whether ctx belongs to a mirrored invocation. Handlers
+// should skip side effects such as publishing events or writing to shared
+// stores when it returns true.
+func Active(ctx context.Context) bool {
+	return ctx.Value(shadowKey{}) != nil
+}
+
+// sample decides whether an invocation is mirrored; tests replace it.
+var sample = func(rate float64) bool { return rand.Float64() < rate }
+
+// Middleware mirrors sampled invocations to cfg.Function on the primary,
+// and compares invocations mirrored from cfg.Primary on the shadow. The same
+// build can run as either, and a shadow never mirrors onwards.
+func Middleware(cfg Config, client InvokeAPI) middleware.Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			var m mirrored
+			if cfg.Primary != "" && json.Unmarshal(payload, &m) == nil && m.Shadow.ID != "" && m.Shadow.Source == cfg.Primary && m.Event != nil {
+				compare(ctx, next, &m)
+				return nil, nil
+			}
+
+			start := time.Now()
+			response, err := next.Invoke(ctx, payload)
+			if cfg.Enabled() && json.Valid(payload) && sample(cfg.SampleRate) {
+				mirror(ctx, cfg, client, payload, response, err, time.Since(start))
+			}
+			return response, err
+		})
+	}
+}
+
+// mirror sends the event and the primary's result to the shadow. Failures
+// are logged and never affect the primary's response.
+func mirror(ctx context.Context, cfg Config, client InvokeAPI, payload, response []byte, err error, duration time.Duration) {
+	var m mirrored
+	m.Shadow.ID = requestID(ctx)
+	m.Shadow.Source = lambdacontext.FunctionName
+	m.Shadow.Response = response
+	m.Shadow.DurationMS = duration.Milliseconds()
+	if err != nil {
+		m.Shadow.Error = err.Error()
+	}
+	m.Event = payload
+
+	logger := logging.FromContext(ctx)
+	body, marshalErr := json.Marshal(m)
+	if marshalErr != nil {
+		logger.Warn("shadow: could not encode mirrored event", "error", marshalErr)
+		return
+	}
+	if len(body) > maxPayload {
+		logger.Warn("shadow: event too large to mirror", "bytes", len(body))
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, mirrorTimeout)
+	defer cancel()
+	_, invokeErr := client.Invoke(ctx, &awslambda.InvokeInput{
+		FunctionName:   aws.String(cfg.Function),
+		InvocationType: lambdatypes.InvocationTypeEvent,
+		Payload:        body,
+	})
+	if invokeErr != nil {
+		logger.Warn("shadow: mirroring failed", "function", cfg.Function, "error", invokeErr)
+	}
+}
+
+// compare runs the handler on the mirrored event and logs how its result
+// compares with the primary's.
+func compare(ctx context.Context, next lambda.Handler, m *mirrored) Comparison {
+	start := time.Now()
+	response, err := next.Invoke(context.WithValue(ctx, shadowKey{}, true), m.Event)
+
+	c := Comparison{
+		ID:           m.Shadow.ID,
+		PrimaryMS:    m.Shadow.DurationMS,
+		ShadowMS:     time.Since(start).Milliseconds(),
+		PrimaryError: m.Shadow.Error,
+	}
+	if err != nil {
+		c.ShadowError = err.Error()
+	}
+	c.Match = c.ShadowError == c.PrimaryError && sameJSON(response, m.Shadow.Response)
+
+	args := []any{
+		"shadow_id", c.ID,
+		"match", c.Match,
+		"primary_ms", c.PrimaryMS,
+		"shadow_ms", c.ShadowMS,
+		"primary_error", c.PrimaryError,
+		"shadow_error", c.ShadowError,
+	}
+	if !c.Match {
+		c.PrimaryResponse, c.ShadowResponse = truncate(m.Shadow.Response), truncate(response)
+		args = append(args, "primary_response", c.PrimaryResponse, "shadow_response", c.ShadowResponse)
+	}
+	logging.FromContext(ctx).Info(LogMessage, args...)
+	return c
+}
+
+// sameJSON compares responses as JSON values, so key order and spacing do
+// not count as differences; anything else is compared byte for byte.
+func sameJSON(a, b []byte) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func truncate(b []byte) string {
+	const limit = 1024
+	if len(b) > limit {
+		return string(b[:limit]) + "..."
+	}
+	return string(b)
+}
+
+func requestID(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok && lc.AwsRequestID != "" {
+		return lc.AwsRequestID
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
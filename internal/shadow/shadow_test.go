@@ -0,0 +1,188 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/middleware"
+)
+
+type fakeLambda struct {
+	inputs []*awslambda.InvokeInput
+}
+
+func (f *fakeLambda) Invoke(ctx context.Context, params *awslambda.InvokeInput, optFns ...func(*awslambda.Options)) (*awslambda.InvokeOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &awslambda.InvokeOutput{StatusCode: 202}, nil
+}
+
+func respond(response string, err error) middleware.HandlerFunc {
+	return func(ctx context.Context, payload []byte) ([]byte, error) {
+		return []byte(response), err
+	}
+}
+
+func alwaysSample(t *testing.T) {
+	previous := sample
+	sample = func(float64) bool { return true }
+	t.Cleanup(func() { sample = previous })
+}
+
+func TestPrimaryMirrorsEventWithItsResponse(t *testing.T) {
+	alwaysSample(t)
+	previous := lambdacontext.FunctionName
+	lambdacontext.FunctionName = "hello"
+	t.Cleanup(func() { lambdacontext.FunctionName = previous })
+	client := &fakeLambda{}
+	handler := Middleware(Config{Function: "hello-rewrite:live", SampleRate: 0.1}, client)(respond(`"Hello, Ada!"`, nil))
+
+	response, err := handler.Invoke(context.Background(), []byte(`{"name":"Ada"}`))
+	if err != nil || string(response) != `"Hello, Ada!"` {
+		t.Fatalf("Invoke = %s, %v; want the primary's own response", response, err)
+	}
+	if len(client.inputs) != 1 {
+		t.Fatalf("%d mirrored invocations, want 1", len(client.inputs))
+	}
+	input := client.inputs[0]
+	if aws.ToString(input.FunctionName) != "hello-rewrite:live" || input.InvocationType != lambdatypes.InvocationTypeEvent {
+		t.Errorf("Invoke(%s, %s), want an asynchronous invoke of the shadow", aws.ToString(input.FunctionName), input.InvocationType)
+	}
+	var m mirrored
+	if err := json.Unmarshal(input.Payload, &m); err != nil {
+		t.Fatal(err)
+	}
+	if string(m.Event) != `{"name":"Ada"}` || string(m.Shadow.Response) != `"Hello, Ada!"` || m.Shadow.ID == "" || m.Shadow.Source != "hello" {
+		t.Errorf("mirrored payload = %s", input.Payload)
+	}
+}
+
+func TestShadowDoesNotMirrorOnwards(t *testing.T) {
+	alwaysSample(t)
+	client := &fakeLambda{}
+	var active bool
+	handler := Middleware(Config{Function: "other", SampleRate: 1, Primary: "hello"}, client)(middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		active = Active(ctx)
+		return []byte(`"ok"`), nil
+	}))
+
+	response, err := handler.Invoke(context.Background(), []byte(`{"shadow":{"id":"req-1","source":"hello","response":"Im9rIg=="},"event":{"name":"Ada"}}`))
+	if err != nil || response != nil {
+		t.Errorf("Invoke = %s, %v; want the response discarded", response, err)
+	}
+	if !active {
+		t.Error("Active(ctx) = false in the shadow's handler")
+	}
+	if len(client.inputs) != 0 {
+		t.Errorf("the shadow mirrored %d invocations", len(client.inputs))
+	}
+}
+
+func TestEnvelopeIsAnOrdinaryEventElsewhere(t *testing.T) {
+	envelope := `{"shadow":{"id":"req-1","source":"hello","response":"Im9rIg=="},"event":{"name":"Ada"}}`
+	for _, cfg := range []Config{{}, {Primary: "other"}} {
+		var received string
+		handler := Middleware(cfg, &fakeLambda{})(middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			received = string(payload)
+			return []byte(`"ok"`), nil
+		}))
+
+		response, err := handler.Invoke(context.Background(), []byte(envelope))
+		if err != nil || string(response) != `"ok"` || received != envelope {
+			t.Errorf("primary %q: Invoke = %s, %v with handler payload %s; want the envelope handled as an event", cfg.Primary, response, err, received)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		primary      string
+		primaryError string
+		shadow       string
+		shadowError  error
+		match        bool
+	}{
+		{"same JSON, different layout", `{"a":1,"b":[2]}`, "", `{ "b": [2], "a": 1 }`, nil, true},
+		{"different value", `{"a":1}`, "", `{"a":2}`, nil, false},
+		{"same error", "", "boom", "", errors.New("boom"), true},
+		{"only the shadow fails", `"ok"`, "", "", errors.New("boom"), false},
+	} {
+		m := &mirrored{Event: json.RawMessage(`{}`)}
+		m.Shadow.ID, m.Shadow.Response, m.Shadow.Error = "req-1", []byte(test.primary), test.primaryError
+		c := compare(context.Background(), respond(test.shadow, test.shadowError), m)
+		if c.Match != test.match {
+			t.Errorf("%s: match = %v, want %v", test.name, c.Match, test.match)
+		}
+	}
+}
+
+type fakeLogs struct {
+	pages [][]string
+	input *cloudwatchlogs.FilterLogEventsInput
+}
+
+func (f *fakeLogs) FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	f.input = params
+	page := 0
+	if params.NextToken != nil {
+		page = 1
+	}
+	output := &cloudwatchlogs.FilterLogEventsOutput{}
+	for _, message := range f.pages[page] {
+		output.Events = append(output.Events, types.FilteredLogEvent{Message: aws.String(message)})
+	}
+	if page+1 < len(f.pages) {
+		output.NextToken = aws.String("next")
+	}
+	return output, nil
+}
+
+func TestCollect(t *testing.T) {
+	client := &fakeLogs{pages: [][]string{
+		{
+			`{"msg":"shadow comparison","shadow_id":"a","match":true,"primary_ms":10,"shadow_ms":20}`,
+			`{"msg":"shadow comparison","shadow_id":"b","match":false,"primary_ms":30,"shadow_ms":5,"shadow_error":"boom","primary_response":"\"ok\""}`,
+		},
+		{
+			`{"msg":"shadow comparison","shadow_id":"c","match":true,"primary_ms":20,"shadow_ms":10}`,
+			`not json`,
+		},
+	}}
+
+	report, err := Collect(context.Background(), client, "/aws/lambda/hello-rewrite", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 3 || report.Matches != 2 || report.ShadowErrors != 1 || len(report.Mismatches) != 1 || report.Mismatches[0].ID != "b" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.PrimaryP50 != 20 || report.ShadowP50 != 10 {
+		t.Errorf("p50 = %d, %d; want 20, 10", report.PrimaryP50, report.ShadowP50)
+	}
+	if !strings.Contains(aws.ToString(client.input.FilterPattern), LogMessage) {
+		t.Errorf("filter pattern = %s", aws.ToString(client.input.FilterPattern))
+	}
+}
+
+func TestLogGroup(t *testing.T) {
+	for function, want := range map[string]string{
+		"hello-rewrite":      "/aws/lambda/hello-rewrite",
+		"hello-rewrite:live": "/aws/lambda/hello-rewrite",
+		"arn:aws:lambda:us-east-1:123456789012:function:hello-rewrite:3": "/aws/lambda/hello-rewrite",
+	} {
+		if got := LogGroup(function); got != want {
+			t.Errorf("LogGroup(%q) = %q, want %q", function, got, want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Package slo checks a function's availability and latency objectives
+// against its CloudWatch metrics. It reports how much of the error budget is
+// left over the SLO window and whether the budget is burning fast enough to
+// alert on, using the multiwindow burn rates from the Google SRE workbook.
+package slo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Config is the slo section of config.yaml.
+type Config struct {
+	// Availability is the percentage of invocations that must succeed, e.g.
+	// 99.9; errors and throttles count against it
+	Availability float64 `yaml:"availability"`
+	Latency      struct {
+		// Threshold is the duration an invocation must finish within, e.g. 500ms
+		Threshold string `yaml:"threshold"`
+		// Target is the percentage of invocations that must meet it, e.g. 99
+		Target float64 `yaml:"target"`
+	} `yaml:"latency"`
+	// Window the budget is spent over, e.g. 30d (default) or 7d
+	Window     string      `yaml:"window"`
+	BurnAlerts []BurnAlert `yaml:"burn_alerts"`
+}
+
+// BurnAlert fires when the budget burns faster than MaxRate times the
+// sustainable rate over both windows; the short one makes it stop firing
+// soon after the problem is fixed.
+type BurnAlert struct {
+	Long    string  `yaml:"long"`
+	Short   string  `yaml:"short"`
+	MaxRate float64 `yaml:"max_rate"`
+}
+
+// DefaultBurnAlerts page when 2% of a 30 day budget is gone in an hour or 5%
+// in six hours.
+var DefaultBurnAlerts = []BurnAlert{
+	{Long: "1h", Short: "5m", MaxRate: 14.4},
+	{Long: "6h", Short: "30m", MaxRate: 6},
+}
+
+// Enabled reports whether any objective is configured.
+func (c Config) Enabled() bool {
+	return c.Availability > 0 || c.Latency.Target > 0
+}
+
+type MetricsAPI interface {
+	cloudwatch.GetMetricDataAPIClient
+}
+
+type Burn struct {
+	Alert               BurnAlert
+	LongRate, ShortRate float64
+	Firing              bool
+}
+
+// Indicator is the result for one objective.
+type Indicator struct {
+	Name string
+	// Target as a fraction, e.g. 0.999
+	Target float64
+	// BadRatio is the fraction of bad invocations over the SLO window
+	BadRatio float64
+	// BudgetRemaining is 1 with no bad invocations and 0 or less once the
+	// budget is exhausted
+	BudgetRemaining float64
+	Burns           []Burn
+}
+
+type Report struct {
+	Window     string
+	Indicators []Indicator
+}
+
+// Exhausted reports whether any objective has used up its error budget.
+func (r *Report) Exhausted() bool {
+	for _, indicator := range r.Indicators {
+		if indicator.BudgetRemaining <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Firing reports whether any burn alert fires.
+func (r *Report) Firing() bool {
+	for _, indicator := range r.Indicators {
+		for _, burn := range indicator.Burns {
+			if burn.Firing {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SLO\tTARGET\tBAD (%s)\tBUDGET LEFT\n", r.Window)
+	for _, indicator := range r.Indicators {
+		fmt.Fprintf(tw, "%s\t%s%%\t%.4f%%\t%.1f%%\n", indicator.Name,
+			strconv.FormatFloat(indicator.Target*100, 'f', -1, 64), indicator.BadRatio*100, indicator.BudgetRemaining*100)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SLO\tWINDOWS\tBURN RATE\tMAX\tALERT\n")
+	for _, indicator := range r.Indicators {
+		for _, burn := range indicator.Burns {
+			alert := "ok"
+			if burn.Firing {
+				alert = "FIRING"
+			}
+			fmt.Fprintf(tw, "%s\t%s/%s\t%.1f/%.1f\t%g\t%s\n", indicator.Name, burn.Alert.Long, burn.Alert.Short,
+				burn.LongRate, burn.ShortRate, burn.Alert.MaxRate, alert)
+		}
+	}
+	tw.Flush()
+}
+
+// Evaluate measures the objectives over the SLO window and the burn alert
+// windows ending at now. The metrics of all functions are added up, so a
+// blue/green pair is measured as one service.
+func Evaluate(ctx context.Context, client MetricsAPI, cfg Config, functionNames []string, now time.Time) (*Report, error) {
+	window := cfg.Window
+	if window == "" {
+		window = "30d"
+	}
+	alerts := cfg.BurnAlerts
+	if len(alerts) == 0 {
+		alerts = DefaultBurnAlerts
+	}
+
+	var threshold time.Duration
+	if cfg.Latency.Target > 0 {
+		var err error
+		if threshold, err = time.ParseDuration(cfg.Latency.Threshold); err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("invalid slo.latency.threshold %q", cfg.Latency.Threshold)
+		}
+	}
+
+	m := measurer{ctx: ctx, client: client, functionNames: functionNames, now: now, threshold: threshold, cache: map[time.Duration]counts{}}
+	report := &Report{Window: window}
+	for _, objective := range []struct {
+		name   string
+		target float64
+		bad    func(counts) float64
+	}{
+		{"availability", cfg.Availability, counts.availabilityBad},
+		{fmt.Sprintf("latency < %s", threshold), cfg.Latency.Target, counts.latencyBad},
+	} {
+		if objective.target <= 0 {
+			continue
+		}
+		if objective.target >= 100 {
+			return nil, fmt.Errorf("%s target must be below 100%%, got %g", objective.name, objective.target)
+		}
+		indicator := Indicator{Name: objective.name, Target: objective.target / 100}
+		budget := 1 - indicator.Target
+
+		c, err := m.measure(window)
+		if err != nil {
+			return nil, err
+		}
+		indicator.BadRatio = objective.bad(c)
+		indicator.BudgetRemaining = 1 - indicator.BadRatio/budget
+
+		for _, alert := range alerts {
+			long, err := m.measure(alert.Long)
+			if err != nil {
+				return nil, err
+			}
+			short, err := m.measure(alert.Short)
+			if err != nil {
+				return nil, err
+			}
+			burn := Burn{
+				Alert:     alert,
+				LongRate:  objective.bad(long) / budget,
+				ShortRate: objective.bad(short) / budget,
+			}
+			burn.Firing = burn.LongRate > alert.MaxRate && burn.ShortRate > alert.MaxRate
+			indicator.Burns = append(indicator.Burns, burn)
+		}
+		report.Indicators = append(report.Indicators, indicator)
+	}
+	return report, nil
+}
+
+// ParseWindow parses a duration that may also be given in days, e.g. 30d.
+func ParseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Minute {
+		return 0, fmt.Errorf("invalid window %q, expected e.g. 5m, 6h or 30d", value)
+	}
+	return d, nil
+}
+
+// counts are metric sums over one window.
+type counts struct {
+	invocations, errors, throttles float64
+	// durations is the number of Duration samples, fast those within the
+	// latency threshold
+	durations, fast float64
+}
+
+func (c counts) availabilityBad() float64 {
+	total := c.invocations + c.throttles
+	if total == 0 {
+		return 0
+	}
+	return (c.errors + c.throttles) / total
+}
+
+func (c counts) latencyBad() float64 {
+	if c.durations == 0 {
+		return 0
+	}
+	return math.Max(0, 1-c.fast/c.durations)
+}
+
+type measurer struct {
+	ctx           context.Context
+	client        MetricsAPI
+	functionNames []string
+	now           time.Time
+	threshold     time.Duration
+	cache         map[time.Duration]counts
+}
+
+func (m *measurer) measure(window string) (counts, error) {
+	length, err := ParseWindow(window)
+	if err != nil {
+		return counts{}, err
+	}
+	if c, ok := m.cache[length]; ok {
+		return c, nil
+	}
+
+	// One bucket per window; CloudWatch wants whole minutes, and whole hours
+	// once the data is older than 63 days
+	unit := time.Minute
+	if length > 24*time.Hour {
+		unit = time.Hour
+	}
+	period := int32((length + unit - 1) / unit * unit / time.Second)
+
+	var queries []types.MetricDataQuery
+	targets := map[string]*float64{}
+	var c counts
+	for i, name := range m.functionNames {
+		for _, metric := range []struct {
+			id, metricName, stat string
+			into                 *float64
+		}{
+			{"invocations", "Invocations", "Sum", &c.invocations},
+			{"errors", "Errors", "Sum", &c.errors},
+			{"throttles", "Throttles", "Sum", &c.throttles},
+			{"durations", "Duration", "SampleCount", &c.durations},
+			{"fast", "Duration", fmt.Sprintf("TC(:%g)", float64(m.threshold)/float64(time.Millisecond)), &c.fast},
+		} {
+			if metric.id == "fast" && m.threshold == 0 {
+				continue
+			}
+			id := fmt.Sprintf("%s%d", metric.id, i)
+			targets[id] = metric.into
+			queries = append(queries, types.MetricDataQuery{
+				Id: aws.String(id),
+				MetricStat: &types.MetricStat{
+					Metric: &types.Metric{
+						Namespace:  aws.String("AWS/Lambda"),
+						MetricName: aws.String(metric.metricName),
+						Dimensions: []types.Dimension{{Name: aws.String("FunctionName"), Value: aws.String(name)}},
+					},
+					Period: aws.Int32(period),
+					Stat:   aws.String(metric.stat),
+				},
+			})
+		}
+	}
+
+	paginator := cloudwatch.NewGetMetricDataPaginator(m.client, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         aws.Time(m.now.Add(-length)),
+		EndTime:           aws.Time(m.now),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(m.ctx)
+		if err != nil {
+			return counts{}, fmt.Errorf("error getting metrics for %s: %v", window, err)
+		}
+		for _, result := range page.MetricDataResults {
+			into := targets[aws.ToString(result.Id)]
+			if into == nil {
+				continue
+			}
+			for _, value := range result.Values {
+				*into += value
+			}
+		}
+	}
+
+	m.cache[length] = c
+	return c, nil
+}
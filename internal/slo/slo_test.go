@@ -0,0 +1,117 @@
+package slo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// fakeMetrics answers every query with the value for its metric id prefix,
+// scaled by the window length in hours so longer windows see more traffic.
+type fakeMetrics struct {
+	// rates per hour by metric id prefix, and per window to model a spike
+	rates  map[string]float64
+	spikes map[time.Duration]map[string]float64
+}
+
+func (f *fakeMetrics) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	length := params.EndTime.Sub(*params.StartTime)
+	output := &cloudwatch.GetMetricDataOutput{}
+	for _, query := range params.MetricDataQueries {
+		id := aws.ToString(query.Id)
+		prefix := strings.TrimRight(id, "0123456789")
+		value := f.rates[prefix] * length.Hours()
+		if spike, ok := f.spikes[length][prefix]; ok {
+			value = spike
+		}
+		output.MetricDataResults = append(output.MetricDataResults, types.MetricDataResult{
+			Id:     query.Id,
+			Values: []float64{value},
+		})
+	}
+	return output, nil
+}
+
+func TestEvaluateBudget(t *testing.T) {
+	cfg := Config{Availability: 99.9}
+	cfg.Latency.Threshold = "500ms"
+	cfg.Latency.Target = 99
+
+	// 0.05% errors and 2% slow invocations
+	client := &fakeMetrics{rates: map[string]float64{
+		"invocations": 10000, "errors": 5, "throttles": 0, "durations": 10000, "fast": 9800,
+	}}
+	report, err := Evaluate(context.Background(), client, cfg, []string{"fn"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Indicators) != 2 {
+		t.Fatalf("got %d indicators, want 2", len(report.Indicators))
+	}
+
+	availability, latency := report.Indicators[0], report.Indicators[1]
+	if got := availability.BudgetRemaining; got < 0.49 || got > 0.51 {
+		t.Errorf("availability budget remaining = %v, want 0.5", got)
+	}
+	if got := latency.BudgetRemaining; got > -0.99 || got < -1.01 {
+		t.Errorf("latency budget remaining = %v, want -1", got)
+	}
+	if !report.Exhausted() {
+		t.Error("Exhausted() = false with the latency budget overspent")
+	}
+	if latency.Name != "latency < 500ms" {
+		t.Errorf("latency name = %q", latency.Name)
+	}
+}
+
+func TestEvaluateBurnAlerts(t *testing.T) {
+	cfg := Config{Availability: 99}
+
+	// A quiet month, then 20% errors over the last hour and five minutes
+	client := &fakeMetrics{
+		rates: map[string]float64{"invocations": 1000},
+		spikes: map[time.Duration]map[string]float64{
+			time.Hour:       {"invocations": 1000, "errors": 200},
+			5 * time.Minute: {"invocations": 100, "errors": 20},
+		},
+	}
+	report, err := Evaluate(context.Background(), client, cfg, []string{"fn", "fn-green"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Exhausted() {
+		t.Error("Exhausted() = true for a month with a single bad hour")
+	}
+	if !report.Firing() {
+		t.Fatal("Firing() = false during an error spike")
+	}
+	burns := report.Indicators[0].Burns
+	if !burns[0].Firing || burns[1].Firing {
+		t.Errorf("firing = %v, %v; want only the 1h/5m alert", burns[0].Firing, burns[1].Firing)
+	}
+	if burns[0].LongRate < 19.9 || burns[0].LongRate > 20.1 {
+		t.Errorf("1h burn rate = %v, want 20", burns[0].LongRate)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for value, want := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"6h":  6 * time.Hour,
+		"5m":  5 * time.Minute,
+	} {
+		if got, err := ParseWindow(value); err != nil || got != want {
+			t.Errorf("ParseWindow(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "0d", "xd", "30s"} {
+		if _, err := ParseWindow(value); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Target is where Restore recreates a function.
+type Target struct {
+	Function, Region, Account string
+	// Image and Role default to the snapshot's, moved to the target account
+	// and region. Lambda only runs images from ECR in its own region, so
+	// restoring to another region needs Image. The versions' images are
+	// taken by digest from Image's repository.
+	Image, Role string
+}
+
+// Restore creates the function of the snapshot at target, then its
+// concurrency, permissions, aliases, function URL and triggers. ARNs of the
+// snapshot's account and region are moved to the target's; the resources
+// they name, such as queues and the role, must exist there. Each version an
+// alias routes to is published again from its own image and environment, and
+// the aliases keep their canary weights. A setting that can't be restored is
+// reported to w and skipped, and counted in the error.
+func Restore(ctx context.Context, c Clients, s *Snapshot, target Target, w io.Writer) error {
+	if target.Function == "" {
+		target.Function = s.Function
+	}
+	move := func(arn string) string { return relocate(arn, s, target) }
+
+	image := target.Image
+	if image == "" {
+		if target.Region != s.Region {
+			return fmt.Errorf("the snapshot's image is in ECR in %s, and Lambda only runs images from its own region; copy it to %s and pass the image", s.Region, target.Region)
+		}
+		image = s.Image
+		if s.ImageDigest != "" {
+			// The digest pins what ran, whatever the tag points at now
+			repository := image
+			if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+				repository = image[:i]
+			}
+			image = repository + "@" + s.ImageDigest
+		}
+		image = strings.Replace(image, s.Account+".dkr.", target.Account+".dkr.", 1)
+	}
+	role := target.Role
+	if role == "" {
+		role = move(s.Role)
+	}
+
+	_, err := c.Lambda.GetFunction(ctx, &awslambda.GetFunctionInput{FunctionName: aws.String(target.Function)})
+	if err == nil {
+		return fmt.Errorf("%s already exists in %s; delete it or restore under another name", target.Function, target.Region)
+	}
+	if !isNotFound(err) {
+		return fmt.Errorf("error checking for %s: %v", target.Function, err)
+	}
+
+	input := &awslambda.CreateFunctionInput{
+		FunctionName: aws.String(target.Function),
+		Role:         aws.String(role),
+		PackageType:  lambdatypes.PackageTypeImage,
+		Code:         &lambdatypes.FunctionCode{ImageUri: aws.String(image)},
+		MemorySize:   aws.Int32(s.MemorySize),
+		Timeout:      aws.Int32(s.Timeout),
+		Tags:         s.Tags,
+	}
+	if s.Description != "" {
+		input.Description = aws.String(s.Description)
+	}
+	for _, architecture := range s.Architectures {
+		input.Architectures = append(input.Architectures, lambdatypes.Architecture(architecture))
+	}
+	if s.EphemeralStorage > 0 {
+		input.EphemeralStorage = &lambdatypes.EphemeralStorage{Size: aws.Int32(s.EphemeralStorage)}
+	}
+	if len(s.Environment) > 0 {
+		input.Environment = &lambdatypes.Environment{Variables: s.Environment}
+	}
+	if s.KMSKey != "" {
+		input.KMSKeyArn = aws.String(move(s.KMSKey))
+	}
+	if s.TracingMode != "" {
+		input.TracingConfig = &lambdatypes.TracingConfig{Mode: lambdatypes.TracingMode(s.TracingMode)}
+	}
+	if s.DeadLetterTarget != "" {
+		input.DeadLetterConfig = &lambdatypes.DeadLetterConfig{TargetArn: aws.String(move(s.DeadLetterTarget))}
+	}
+	if s.VPC != nil {
+		// Subnets and security groups have no ARN to move; a restore to
+		// another account or region fails here unless they are shared
+		input.VpcConfig = &lambdatypes.VpcConfig{SubnetIds: s.VPC.SubnetIDs, SecurityGroupIds: s.VPC.SecurityGroupIDs}
+	}
+	if s.ImageConfig != nil {
+		input.ImageConfig = &lambdatypes.ImageConfig{Command: s.ImageConfig.Command, EntryPoint: s.ImageConfig.EntryPoint}
+		if s.ImageConfig.WorkingDirectory != "" {
+			input.ImageConfig.WorkingDirectory = aws.String(s.ImageConfig.WorkingDirectory)
+		}
+	}
+	created, err := c.Lambda.CreateFunction(ctx, input)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", target.Function, err)
+	}
+	fmt.Fprintf(w, "Function %s created from %s\n", target.Function, image)
+	if err := awslambda.NewFunctionActiveV2Waiter(c.Lambda).Wait(ctx, &awslambda.GetFunctionInput{FunctionName: aws.String(target.Function)}, 5*time.Minute); err != nil {
+		return fmt.Errorf("error waiting for %s to become active: %v", target.Function, err)
+	}
+	functionARN := aws.ToString(created.FunctionArn)
+
+	failed := 0
+	report := func(what string, err error) {
+		var conflict *lambdatypes.ResourceConflictException
+		switch {
+		case err == nil:
+			fmt.Fprintf(w, "%s restored\n", what)
+		case errors.As(err, &conflict):
+			fmt.Fprintf(w, "%s exists already\n", what)
+		default:
+			fmt.Fprintf(w, "Could not restore %s: %v\n", what, err)
+			failed++
+		}
+	}
+
+	if s.ReservedConcurrency != nil {
+		_, err := c.Lambda.PutFunctionConcurrency(ctx, &awslambda.PutFunctionConcurrencyInput{
+			FunctionName:                 aws.String(target.Function),
+			ReservedConcurrentExecutions: s.ReservedConcurrency,
+		})
+		report("Reserved concurrency", err)
+	}
+
+	// Aliases come before the permissions and triggers that name them
+	if len(s.Aliases) > 0 {
+		versions, err := restoreVersions(ctx, c.Lambda, s, target.Function, image, w)
+		if err != nil {
+			return err
+		}
+		for _, alias := range s.Aliases {
+			input := &awslambda.CreateAliasInput{
+				FunctionName:    aws.String(target.Function),
+				Name:            aws.String(alias.Name),
+				FunctionVersion: aws.String(versions[alias.Version]),
+				Description:     aws.String(alias.Description),
+			}
+			if len(alias.Weights) > 0 {
+				weights := map[string]float64{}
+				for version, weight := range alias.Weights {
+					weights[versions[version]] = weight
+				}
+				input.RoutingConfig = &lambdatypes.AliasRoutingConfiguration{AdditionalVersionWeights: weights}
+			}
+			_, err := c.Lambda.CreateAlias(ctx, input)
+			report("Alias "+alias.Name, err)
+		}
+	}
+
+	for _, p := range s.Permissions {
+		input := &awslambda.AddPermissionInput{
+			FunctionName: aws.String(target.Function),
+			StatementId:  aws.String(p.StatementID),
+			Action:       aws.String(p.Action),
+			Principal:    aws.String(move(p.Principal)),
+		}
+		if p.SourceARN != "" {
+			input.SourceArn = aws.String(move(p.SourceARN))
+		}
+		if p.SourceAccount != "" {
+			input.SourceAccount = aws.String(move(p.SourceAccount))
+		}
+		if p.FunctionURLAuthType != "" {
+			input.FunctionUrlAuthType = lambdatypes.FunctionUrlAuthType(p.FunctionURLAuthType)
+		}
+		if p.Qualifier != "" {
+			input.Qualifier = aws.String(p.Qualifier)
+		}
+		_, err := c.Lambda.AddPermission(ctx, input)
+		report("Permission "+p.StatementID, err)
+	}
+
+	if url := s.FunctionURL; url != nil {
+		input := &awslambda.CreateFunctionUrlConfigInput{
+			FunctionName: aws.String(target.Function),
+			AuthType:     lambdatypes.FunctionUrlAuthType(url.AuthType),
+			InvokeMode:   lambdatypes.InvokeMode(url.InvokeMode),
+		}
+		created, err := c.Lambda.CreateFunctionUrlConfig(ctx, input)
+		report("Function URL", err)
+		if err == nil {
+			fmt.Fprintf(w, "The function URL is now %s; point its clients there\n", aws.ToString(created.FunctionUrl))
+		}
+	}
+
+	for _, m := range s.EventSourceMappings {
+		input := &awslambda.CreateEventSourceMappingInput{
+			FunctionName:                   aws.String(qualified(functionARN, m.Qualifier)),
+			EventSourceArn:                 aws.String(move(m.EventSourceARN)),
+			BatchSize:                      m.BatchSize,
+			MaximumBatchingWindowInSeconds: m.MaximumBatchingWindow,
+			Enabled:                        aws.Bool(m.Enabled),
+		}
+		if m.StartingPosition != "" {
+			input.StartingPosition = lambdatypes.EventSourcePosition(m.StartingPosition)
+		}
+		for _, responseType := range m.FunctionResponseTypes {
+			input.FunctionResponseTypes = append(input.FunctionResponseTypes, lambdatypes.FunctionResponseType(responseType))
+		}
+		_, err := c.Lambda.CreateEventSourceMapping(ctx, input)
+		report("Event source mapping of "+m.EventSourceARN, err)
+	}
+
+	for _, rule := range s.Rules {
+		report("Rule "+rule.Name, restoreRule(ctx, c.Events, rule, qualified(functionARN, rule.Qualifier)))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d setting(s) of the snapshot could not be restored", failed)
+	}
+	return nil
+}
+
+// restoreVersions publishes a version of function for each one of the
+// snapshot's, from that version's image and environment, and returns the new
+// version of each old one. $LATEST is left with the image and environment of
+// the function. A snapshot of format 1 recorded no versions; every alias of
+// it gets one version published from the function as it is.
+func restoreVersions(ctx context.Context, client LambdaAPI, s *Snapshot, function, image string, w io.Writer) (map[string]string, error) {
+	description := "Restored from the snapshot of " + s.Taken.Format(time.RFC3339)
+	versions := map[string]string{"$LATEST": "$LATEST"}
+	if len(s.Versions) == 0 {
+		published, err := client.PublishVersion(ctx, &awslambda.PublishVersionInput{
+			FunctionName: aws.String(function),
+			Description:  aws.String(description),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error publishing a version for the aliases: %v", err)
+		}
+		for _, alias := range s.Aliases {
+			if alias.Version != "$LATEST" {
+				versions[alias.Version] = aws.ToString(published.Version)
+			}
+			for version := range alias.Weights {
+				versions[version] = aws.ToString(published.Version)
+			}
+		}
+		return versions, nil
+	}
+
+	repository := image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository = repository[:i]
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	currentImage, currentEnv := image, s.Environment
+	update := func(uri string, env map[string]string) error {
+		if uri != currentImage {
+			_, err := client.UpdateFunctionCode(ctx, &awslambda.UpdateFunctionCodeInput{
+				FunctionName: aws.String(function),
+				ImageUri:     aws.String(uri),
+			})
+			if err != nil {
+				return fmt.Errorf("error updating the image of %s to %s: %v", function, uri, err)
+			}
+			if err := waitUpdated(ctx, client, function); err != nil {
+				return err
+			}
+			currentImage = uri
+		}
+		if !maps.Equal(env, currentEnv) {
+			variables := env
+			if variables == nil {
+				variables = map[string]string{}
+			}
+			_, err := client.UpdateFunctionConfiguration(ctx, &awslambda.UpdateFunctionConfigurationInput{
+				FunctionName: aws.String(function),
+				Environment:  &lambdatypes.Environment{Variables: variables},
+			})
+			if err != nil {
+				return fmt.Errorf("error updating the environment of %s: %v", function, err)
+			}
+			if err := waitUpdated(ctx, client, function); err != nil {
+				return err
+			}
+			currentEnv = env
+		}
+		return nil
+	}
+
+	for _, v := range s.Versions {
+		uri := image
+		if v.ImageDigest != "" {
+			uri = repository + "@" + v.ImageDigest
+		}
+		if err := update(uri, v.Environment); err != nil {
+			return nil, fmt.Errorf("error restoring version %s: %v", v.Version, err)
+		}
+		published, err := client.PublishVersion(ctx, &awslambda.PublishVersionInput{
+			FunctionName: aws.String(function),
+			Description:  aws.String(fmt.Sprintf("Version %s, %s", v.Version, description)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error publishing version %s: %v", v.Version, err)
+		}
+		versions[v.Version] = aws.ToString(published.Version)
+		fmt.Fprintf(w, "Version %s restored as version %s\n", v.Version, aws.ToString(published.Version))
+	}
+	if err := update(image, s.Environment); err != nil {
+		return nil, fmt.Errorf("error restoring $LATEST: %v", err)
+	}
+	return versions, nil
+}
+
+func waitUpdated(ctx context.Context, client LambdaAPI, function string) error {
+	err := awslambda.NewFunctionUpdatedV2Waiter(client).Wait(ctx, &awslambda.GetFunctionInput{FunctionName: aws.String(function)}, 5*time.Minute)
+	if err != nil {
+		return fmt.Errorf("error waiting for the update of %s: %v", function, err)
+	}
+	return nil
+}
+
+func restoreRule(ctx context.Context, client EventsAPI, rule Rule, targetARN string) error {
+	input := &eventbridge.PutRuleInput{
+		Name:  aws.String(rule.Name),
+		State: ebtypes.RuleStateEnabled,
+	}
+	if !rule.Enabled {
+		input.State = ebtypes.RuleStateDisabled
+	}
+	if rule.Description != "" {
+		input.Description = aws.String(rule.Description)
+	}
+	if rule.ScheduleExpression != "" {
+		input.ScheduleExpression = aws.String(rule.ScheduleExpression)
+	}
+	if rule.EventPattern != "" {
+		input.EventPattern = aws.String(rule.EventPattern)
+	}
+	if _, err := client.PutRule(ctx, input); err != nil {
+		return err
+	}
+
+	target := ebtypes.Target{Id: aws.String(rule.TargetID), Arn: aws.String(targetARN)}
+	if rule.Input != "" {
+		target.Input = aws.String(rule.Input)
+	}
+	output, err := client.PutTargets(ctx, &eventbridge.PutTargetsInput{
+		Rule:    aws.String(rule.Name),
+		Targets: []ebtypes.Target{target},
+	})
+	if err != nil {
+		return err
+	}
+	if output.FailedEntryCount > 0 {
+		return fmt.Errorf("%s", aws.ToString(output.FailedEntries[0].ErrorMessage))
+	}
+	return nil
+}
+
+// relocate moves an ARN, or an account ID, of the snapshot's account and
+// region to the target's. Anything else is returned as it is.
+func relocate(arn string, s *Snapshot, target Target) string {
+	if arn == s.Account {
+		return target.Account
+	}
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return arn
+	}
+	if parts[3] == s.Region {
+		parts[3] = target.Region
+	}
+	if parts[4] == s.Account {
+		parts[4] = target.Account
+	}
+	return strings.Join(parts, ":")
+}
+
+func qualified(functionARN, qualifier string) string {
+	if qualifier == "" {
+		return functionARN
+	}
+	return functionARN + ":" + qualifier
+}
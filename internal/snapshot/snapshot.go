@@ -0,0 +1,534 @@
+// Package snapshot records what defines a deployed function, its
+// configuration, environment, resource policy, triggers, aliases and image
+// digest, as a JSON document. Snapshots are kept in S3, and Restore
+// recreates the function from one in the same or another account and region.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/middleware"
+)
+
+// DetailType is the detail-type of the scheduled event that asks the
+// function for a snapshot; the route in cmd/lambda/routes.yaml matches on it.
+const DetailType = "Function Snapshot"
+
+// Format is the version of the snapshot document. Load refuses newer ones,
+// which this build would restore only in part. Format 2 added Versions and
+// the aliases' Weights.
+const Format = 2
+
+type Snapshot struct {
+	Format   int       `json:"format"`
+	Taken    time.Time `json:"taken"`
+	Function string    `json:"function"`
+	Region   string    `json:"region"`
+	Account  string    `json:"account"`
+
+	// Image is the repository and tag the function was deployed from, and
+	// ImageDigest what it resolved to
+	Image       string `json:"image"`
+	ImageDigest string `json:"image_digest"`
+
+	Role                string            `json:"role"`
+	Description         string            `json:"description,omitempty"`
+	MemorySize          int32             `json:"memory_size"`
+	Timeout             int32             `json:"timeout"`
+	EphemeralStorage    int32             `json:"ephemeral_storage,omitempty"`
+	Architectures       []string          `json:"architectures,omitempty"`
+	Environment         map[string]string `json:"environment,omitempty"`
+	KMSKey              string            `json:"kms_key,omitempty"`
+	TracingMode         string            `json:"tracing_mode,omitempty"`
+	DeadLetterTarget    string            `json:"dead_letter_target,omitempty"`
+	VPC                 *VPC              `json:"vpc,omitempty"`
+	ImageConfig         *ImageConfig      `json:"image_config,omitempty"`
+	ReservedConcurrency *int32            `json:"reserved_concurrency,omitempty"`
+	Tags                map[string]string `json:"tags,omitempty"`
+
+	// Policy is the resource-based policy as Lambda returns it, and
+	// Permissions its statements in the form AddPermission takes
+	Policy      string       `json:"policy,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty"`
+
+	Aliases []Alias `json:"aliases,omitempty"`
+	// Versions are the published versions the aliases route to, each with
+	// the image and environment it froze, which may differ from the
+	// function's own: each alias has its environment, and a canary routes
+	// to a newer image
+	Versions            []Version    `json:"versions,omitempty"`
+	FunctionURL         *FunctionURL `json:"function_url,omitempty"`
+	EventSourceMappings []Mapping    `json:"event_source_mappings,omitempty"`
+	Rules               []Rule       `json:"rules,omitempty"`
+}
+
+type VPC struct {
+	SubnetIDs        []string `json:"subnet_ids"`
+	SecurityGroupIDs []string `json:"security_group_ids"`
+}
+
+type ImageConfig struct {
+	Command          []string `json:"command,omitempty"`
+	EntryPoint       []string `json:"entry_point,omitempty"`
+	WorkingDirectory string   `json:"working_directory,omitempty"`
+}
+
+type Permission struct {
+	StatementID   string `json:"statement_id"`
+	Principal     string `json:"principal"`
+	Action        string `json:"action"`
+	SourceARN     string `json:"source_arn,omitempty"`
+	SourceAccount string `json:"source_account,omitempty"`
+	// FunctionURLAuthType is set on the statements of a function URL
+	FunctionURLAuthType string `json:"function_url_auth_type,omitempty"`
+	Qualifier           string `json:"qualifier,omitempty"`
+}
+
+type Alias struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	// Weights are a canary's additional versions and the share of
+	// invocations each gets
+	Weights map[string]float64 `json:"weights,omitempty"`
+}
+
+type Version struct {
+	Version     string            `json:"version"`
+	ImageDigest string            `json:"image_digest,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+type FunctionURL struct {
+	AuthType   string `json:"auth_type"`
+	InvokeMode string `json:"invoke_mode,omitempty"`
+}
+
+// Mapping is an event source mapping: an SQS queue, or a Kinesis or
+// DynamoDB stream.
+type Mapping struct {
+	EventSourceARN        string   `json:"event_source_arn"`
+	Qualifier             string   `json:"qualifier,omitempty"`
+	BatchSize             *int32   `json:"batch_size,omitempty"`
+	MaximumBatchingWindow *int32   `json:"maximum_batching_window,omitempty"`
+	StartingPosition      string   `json:"starting_position,omitempty"`
+	FunctionResponseTypes []string `json:"function_response_types,omitempty"`
+	Enabled               bool     `json:"enabled"`
+}
+
+// Rule is an EventBridge rule on the default bus that targets the function.
+type Rule struct {
+	Name               string `json:"name"`
+	Description        string `json:"description,omitempty"`
+	ScheduleExpression string `json:"schedule_expression,omitempty"`
+	EventPattern       string `json:"event_pattern,omitempty"`
+	Enabled            bool   `json:"enabled"`
+	TargetID           string `json:"target_id"`
+	Input              string `json:"input,omitempty"`
+	Qualifier          string `json:"qualifier,omitempty"`
+}
+
+// LambdaAPI is the part of the Lambda client Take and Restore use.
+type LambdaAPI interface {
+	awslambda.GetFunctionAPIClient
+	awslambda.ListAliasesAPIClient
+	awslambda.ListEventSourceMappingsAPIClient
+	GetPolicy(ctx context.Context, params *awslambda.GetPolicyInput, optFns ...func(*awslambda.Options)) (*awslambda.GetPolicyOutput, error)
+	GetFunctionUrlConfig(ctx context.Context, params *awslambda.GetFunctionUrlConfigInput, optFns ...func(*awslambda.Options)) (*awslambda.GetFunctionUrlConfigOutput, error)
+	CreateFunction(ctx context.Context, params *awslambda.CreateFunctionInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateFunctionOutput, error)
+	PutFunctionConcurrency(ctx context.Context, params *awslambda.PutFunctionConcurrencyInput, optFns ...func(*awslambda.Options)) (*awslambda.PutFunctionConcurrencyOutput, error)
+	AddPermission(ctx context.Context, params *awslambda.AddPermissionInput, optFns ...func(*awslambda.Options)) (*awslambda.AddPermissionOutput, error)
+	PublishVersion(ctx context.Context, params *awslambda.PublishVersionInput, optFns ...func(*awslambda.Options)) (*awslambda.PublishVersionOutput, error)
+	CreateAlias(ctx context.Context, params *awslambda.CreateAliasInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateAliasOutput, error)
+	UpdateFunctionCode(ctx context.Context, params *awslambda.UpdateFunctionCodeInput, optFns ...func(*awslambda.Options)) (*awslambda.UpdateFunctionCodeOutput, error)
+	UpdateFunctionConfiguration(ctx context.Context, params *awslambda.UpdateFunctionConfigurationInput, optFns ...func(*awslambda.Options)) (*awslambda.UpdateFunctionConfigurationOutput, error)
+	CreateFunctionUrlConfig(ctx context.Context, params *awslambda.CreateFunctionUrlConfigInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateFunctionUrlConfigOutput, error)
+	CreateEventSourceMapping(ctx context.Context, params *awslambda.CreateEventSourceMappingInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateEventSourceMappingOutput, error)
+}
+
+// EventsAPI is the part of the EventBridge client Take and Restore use.
+type EventsAPI interface {
+	ListRuleNamesByTarget(ctx context.Context, params *eventbridge.ListRuleNamesByTargetInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListRuleNamesByTargetOutput, error)
+	DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error)
+	ListTargetsByRule(ctx context.Context, params *eventbridge.ListTargetsByRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListTargetsByRuleOutput, error)
+	PutRule(ctx context.Context, params *eventbridge.PutRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutRuleOutput, error)
+	PutTargets(ctx context.Context, params *eventbridge.PutTargetsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutTargetsOutput, error)
+}
+
+type Clients struct {
+	Lambda LambdaAPI
+	Events EventsAPI
+}
+
+// Take snapshots the function.
+func Take(ctx context.Context, c Clients, functionName string, now time.Time) (*Snapshot, error) {
+	function, err := c.Lambda.GetFunction(ctx, &awslambda.GetFunctionInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		return nil, fmt.Errorf("error getting function %s: %v", functionName, err)
+	}
+	fc := function.Configuration
+	if fc.PackageType != lambdatypes.PackageTypeImage {
+		return nil, fmt.Errorf("%s is a %s function; snapshots hold container image functions", functionName, fc.PackageType)
+	}
+	functionARN := aws.ToString(fc.FunctionArn)
+	arn := strings.Split(functionARN, ":")
+	s := &Snapshot{
+		Format:        Format,
+		Taken:         now.UTC(),
+		Function:      aws.ToString(fc.FunctionName),
+		Region:        arn[3],
+		Account:       arn[4],
+		Role:          aws.ToString(fc.Role),
+		Description:   aws.ToString(fc.Description),
+		MemorySize:    aws.ToInt32(fc.MemorySize),
+		Timeout:       aws.ToInt32(fc.Timeout),
+		KMSKey:        aws.ToString(fc.KMSKeyArn),
+		Tags:          function.Tags,
+		Architectures: make([]string, 0, len(fc.Architectures)),
+	}
+	if function.Code != nil {
+		s.Image = aws.ToString(function.Code.ImageUri)
+		if _, digest, ok := strings.Cut(aws.ToString(function.Code.ResolvedImageUri), "@"); ok {
+			s.ImageDigest = digest
+		}
+	}
+	for _, architecture := range fc.Architectures {
+		s.Architectures = append(s.Architectures, string(architecture))
+	}
+	if fc.EphemeralStorage != nil {
+		s.EphemeralStorage = aws.ToInt32(fc.EphemeralStorage.Size)
+	}
+	if fc.Environment != nil {
+		s.Environment = fc.Environment.Variables
+	}
+	if fc.TracingConfig != nil && fc.TracingConfig.Mode != lambdatypes.TracingModePassThrough {
+		s.TracingMode = string(fc.TracingConfig.Mode)
+	}
+	if fc.DeadLetterConfig != nil {
+		s.DeadLetterTarget = aws.ToString(fc.DeadLetterConfig.TargetArn)
+	}
+	if vpc := fc.VpcConfig; vpc != nil && len(vpc.SubnetIds) > 0 {
+		s.VPC = &VPC{SubnetIDs: vpc.SubnetIds, SecurityGroupIDs: vpc.SecurityGroupIds}
+	}
+	if image := fc.ImageConfigResponse; image != nil && image.ImageConfig != nil {
+		s.ImageConfig = &ImageConfig{
+			Command:          image.ImageConfig.Command,
+			EntryPoint:       image.ImageConfig.EntryPoint,
+			WorkingDirectory: aws.ToString(image.ImageConfig.WorkingDirectory),
+		}
+	}
+	if function.Concurrency != nil {
+		s.ReservedConcurrency = function.Concurrency.ReservedConcurrentExecutions
+	}
+
+	if err := s.takePolicy(ctx, c.Lambda); err != nil {
+		return nil, err
+	}
+	if err := s.takeAliases(ctx, c.Lambda); err != nil {
+		return nil, err
+	}
+	if err := s.takeVersions(ctx, c.Lambda); err != nil {
+		return nil, err
+	}
+	url, err := c.Lambda.GetFunctionUrlConfig(ctx, &awslambda.GetFunctionUrlConfigInput{FunctionName: aws.String(functionName)})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error getting function URL: %v", err)
+	}
+	if err == nil {
+		s.FunctionURL = &FunctionURL{AuthType: string(url.AuthType), InvokeMode: string(url.InvokeMode)}
+	}
+
+	// Triggers can point at an alias as well as at the function itself
+	targets := []string{functionARN}
+	for _, alias := range s.Aliases {
+		targets = append(targets, functionARN+":"+alias.Name)
+	}
+	if err := s.takeMappings(ctx, c.Lambda, targets); err != nil {
+		return nil, err
+	}
+	if err := s.takeRules(ctx, c.Events, targets); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshot) takePolicy(ctx context.Context, client LambdaAPI) error {
+	output, err := client.GetPolicy(ctx, &awslambda.GetPolicyInput{FunctionName: aws.String(s.Function)})
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting resource policy: %v", err)
+	}
+	s.Policy = aws.ToString(output.Policy)
+	s.Permissions, err = parsePolicy(s.Policy)
+	return err
+}
+
+// parsePolicy reads the statements of a resource-based policy back into the
+// AddPermission calls that made them.
+func parsePolicy(policy string) ([]Permission, error) {
+	var doc struct {
+		Statement []struct {
+			Sid       string
+			Principal json.RawMessage
+			Action    string
+			Resource  string
+			Condition map[string]map[string]string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &doc); err != nil {
+		return nil, fmt.Errorf("error decoding resource policy: %v", err)
+	}
+	var permissions []Permission
+	for _, statement := range doc.Statement {
+		p := Permission{
+			StatementID:         statement.Sid,
+			Action:              statement.Action,
+			SourceARN:           statement.Condition["ArnLike"]["AWS:SourceArn"],
+			SourceAccount:       statement.Condition["StringEquals"]["AWS:SourceAccount"],
+			FunctionURLAuthType: statement.Condition["StringEquals"]["lambda:FunctionUrlAuthType"],
+			Qualifier:           qualifier(statement.Resource),
+		}
+		// The principal is "*", {"Service": ...} or {"AWS": ...}
+		var principals map[string]string
+		if err := json.Unmarshal(statement.Principal, &p.Principal); err != nil {
+			if err := json.Unmarshal(statement.Principal, &principals); err != nil {
+				return nil, fmt.Errorf("statement %s: unsupported principal %s", statement.Sid, statement.Principal)
+			}
+			p.Principal = principals["Service"]
+			if p.Principal == "" {
+				p.Principal = principals["AWS"]
+			}
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, nil
+}
+
+func (s *Snapshot) takeAliases(ctx context.Context, client LambdaAPI) error {
+	paginator := awslambda.NewListAliasesPaginator(client, &awslambda.ListAliasesInput{FunctionName: aws.String(s.Function)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("error listing aliases: %v", err)
+		}
+		for _, alias := range page.Aliases {
+			a := Alias{
+				Name:        aws.ToString(alias.Name),
+				Version:     aws.ToString(alias.FunctionVersion),
+				Description: aws.ToString(alias.Description),
+			}
+			if alias.RoutingConfig != nil && len(alias.RoutingConfig.AdditionalVersionWeights) > 0 {
+				a.Weights = alias.RoutingConfig.AdditionalVersionWeights
+			}
+			s.Aliases = append(s.Aliases, a)
+		}
+	}
+	return nil
+}
+
+// takeVersions records the image and environment of every published
+// version an alias routes to, oldest first.
+func (s *Snapshot) takeVersions(ctx context.Context, client LambdaAPI) error {
+	seen := map[string]bool{"$LATEST": true}
+	var numbers []int
+	add := func(version string) {
+		if seen[version] {
+			return
+		}
+		seen[version] = true
+		if n, err := strconv.Atoi(version); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	for _, alias := range s.Aliases {
+		add(alias.Version)
+		for version := range alias.Weights {
+			add(version)
+		}
+	}
+	sort.Ints(numbers)
+
+	for _, n := range numbers {
+		number := strconv.Itoa(n)
+		function, err := client.GetFunction(ctx, &awslambda.GetFunctionInput{
+			FunctionName: aws.String(s.Function),
+			Qualifier:    aws.String(number),
+		})
+		if err != nil {
+			return fmt.Errorf("error getting version %s: %v", number, err)
+		}
+		v := Version{Version: number}
+		if function.Code != nil {
+			if _, digest, ok := strings.Cut(aws.ToString(function.Code.ResolvedImageUri), "@"); ok {
+				v.ImageDigest = digest
+			}
+		}
+		if env := function.Configuration.Environment; env != nil {
+			v.Environment = env.Variables
+		}
+		s.Versions = append(s.Versions, v)
+	}
+	return nil
+}
+
+func (s *Snapshot) takeMappings(ctx context.Context, client LambdaAPI, targets []string) error {
+	seen := map[string]bool{}
+	for _, target := range targets {
+		paginator := awslambda.NewListEventSourceMappingsPaginator(client, &awslambda.ListEventSourceMappingsInput{FunctionName: aws.String(target)})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("error listing event source mappings: %v", err)
+			}
+			for _, mapping := range page.EventSourceMappings {
+				if seen[aws.ToString(mapping.UUID)] {
+					continue
+				}
+				seen[aws.ToString(mapping.UUID)] = true
+				m := Mapping{
+					EventSourceARN:        aws.ToString(mapping.EventSourceArn),
+					Qualifier:             qualifier(aws.ToString(mapping.FunctionArn)),
+					BatchSize:             mapping.BatchSize,
+					MaximumBatchingWindow: mapping.MaximumBatchingWindowInSeconds,
+					StartingPosition:      string(mapping.StartingPosition),
+					Enabled:               aws.ToString(mapping.State) == "Enabled" || aws.ToString(mapping.State) == "Enabling",
+				}
+				for _, responseType := range mapping.FunctionResponseTypes {
+					m.FunctionResponseTypes = append(m.FunctionResponseTypes, string(responseType))
+				}
+				s.EventSourceMappings = append(s.EventSourceMappings, m)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) takeRules(ctx context.Context, client EventsAPI, targets []string) error {
+	for _, target := range targets {
+		var token *string
+		for {
+			output, err := client.ListRuleNamesByTarget(ctx, &eventbridge.ListRuleNamesByTargetInput{
+				TargetArn: aws.String(target),
+				NextToken: token,
+			})
+			if err != nil {
+				return fmt.Errorf("error listing rules: %v", err)
+			}
+			for _, name := range output.RuleNames {
+				rule, err := s.takeRule(ctx, client, name, target)
+				if err != nil {
+					return err
+				}
+				s.Rules = append(s.Rules, rule)
+			}
+			if output.NextToken == nil {
+				break
+			}
+			token = output.NextToken
+		}
+	}
+	sort.Slice(s.Rules, func(i, j int) bool { return s.Rules[i].Name < s.Rules[j].Name })
+	return nil
+}
+
+func (s *Snapshot) takeRule(ctx context.Context, client EventsAPI, name, target string) (Rule, error) {
+	description, err := client.DescribeRule(ctx, &eventbridge.DescribeRuleInput{Name: aws.String(name)})
+	if err != nil {
+		return Rule{}, fmt.Errorf("error describing rule %s: %v", name, err)
+	}
+	rule := Rule{
+		Name:               name,
+		Description:        aws.ToString(description.Description),
+		ScheduleExpression: aws.ToString(description.ScheduleExpression),
+		EventPattern:       aws.ToString(description.EventPattern),
+		Enabled:            description.State != ebtypes.RuleStateDisabled,
+		Qualifier:          qualifier(target),
+	}
+	targets, err := client.ListTargetsByRule(ctx, &eventbridge.ListTargetsByRuleInput{Rule: aws.String(name)})
+	if err != nil {
+		return Rule{}, fmt.Errorf("error listing the targets of rule %s: %v", name, err)
+	}
+	for _, t := range targets.Targets {
+		if aws.ToString(t.Arn) == target {
+			rule.TargetID = aws.ToString(t.Id)
+			rule.Input = aws.ToString(t.Input)
+		}
+	}
+	return rule, nil
+}
+
+// qualifier returns the version or alias of a function ARN, if it has one.
+func qualifier(functionARN string) string {
+	// arn:aws:lambda:region:account:function:name[:qualifier]
+	parts := strings.Split(functionARN, ":")
+	if len(parts) == 8 {
+		return parts[7]
+	}
+	return ""
+}
+
+func isNotFound(err error) bool {
+	var notFound *lambdatypes.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
+
+// Config is read from the BACKUP_* variables that setup and deploy derive
+// from the backup section of config.yaml.
+type Config struct {
+	Bucket, Prefix string
+	// Functions are snapshotted together, e.g. a blue/green pair
+	Functions []string
+}
+
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Bucket: os.Getenv("BACKUP_BUCKET"),
+		Prefix: os.Getenv("BACKUP_PREFIX"),
+	}
+	if functions := os.Getenv("BACKUP_FUNCTIONS"); functions != "" {
+		cfg.Functions = strings.Split(functions, ",")
+	} else if name := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); name != "" {
+		cfg.Functions = []string{name}
+	}
+	return cfg
+}
+
+// Handler answers the scheduled snapshot event: it snapshots the functions
+// and stores the snapshots in the bucket.
+func Handler(cfg Config, c Clients, store S3API) lambda.Handler {
+	return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+		if cfg.Bucket == "" {
+			return nil, fmt.Errorf("snapshot requested, but BACKUP_BUCKET is not set")
+		}
+		for _, function := range cfg.Functions {
+			s, err := Take(ctx, c, function, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			key, err := Save(ctx, store, cfg.Bucket, cfg.Prefix, s)
+			if err != nil {
+				return nil, err
+			}
+			logging.FromContext(ctx).Info("snapshot stored", "function", function, "bucket", cfg.Bucket, "key", key)
+		}
+		return nil, nil
+	})
+}
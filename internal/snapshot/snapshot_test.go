@@ -0,0 +1,446 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	ebtypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	awslambda "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	functionARN = "arn:aws:lambda:us-west-2:111111111111:function:hello-world"
+	policy      = `{"Version":"2012-10-17","Statement":[
+		{"Sid":"hello-world-schedule","Effect":"Allow","Principal":{"Service":"events.amazonaws.com"},"Action":"lambda:InvokeFunction",
+		 "Resource":"arn:aws:lambda:us-west-2:111111111111:function:hello-world:live",
+		 "Condition":{"ArnLike":{"AWS:SourceArn":"arn:aws:events:us-west-2:111111111111:rule/hello-world-schedule"}}},
+		{"Sid":"FunctionURLAllowPublicAccess","Effect":"Allow","Principal":"*","Action":"lambda:InvokeFunctionUrl",
+		 "Resource":"arn:aws:lambda:us-west-2:111111111111:function:hello-world",
+		 "Condition":{"StringEquals":{"lambda:FunctionUrlAuthType":"NONE"}}}]}`
+)
+
+// fakeLambda serves one function and records what Restore creates. Once
+// CreateFunction is called, GetFunction finds the new function.
+type fakeLambda struct {
+	LambdaAPI
+	function *awslambda.GetFunctionOutput
+	aliased  []lambdatypes.AliasConfiguration
+	versions map[string]*awslambda.GetFunctionOutput
+
+	created     *awslambda.CreateFunctionInput
+	published   []string
+	images      []string
+	envs        []map[string]string
+	aliases     []*awslambda.CreateAliasInput
+	permissions []*awslambda.AddPermissionInput
+	mappings    []*awslambda.CreateEventSourceMappingInput
+}
+
+func (f *fakeLambda) GetFunction(ctx context.Context, params *awslambda.GetFunctionInput, optFns ...func(*awslambda.Options)) (*awslambda.GetFunctionOutput, error) {
+	if version, ok := f.versions[aws.ToString(params.Qualifier)]; ok {
+		return version, nil
+	}
+	if f.function == nil {
+		return nil, &lambdatypes.ResourceNotFoundException{Message: aws.String("Function not found")}
+	}
+	return f.function, nil
+}
+
+func (f *fakeLambda) GetPolicy(ctx context.Context, params *awslambda.GetPolicyInput, optFns ...func(*awslambda.Options)) (*awslambda.GetPolicyOutput, error) {
+	return &awslambda.GetPolicyOutput{Policy: aws.String(policy)}, nil
+}
+
+func (f *fakeLambda) ListAliases(ctx context.Context, params *awslambda.ListAliasesInput, optFns ...func(*awslambda.Options)) (*awslambda.ListAliasesOutput, error) {
+	if f.aliased != nil {
+		return &awslambda.ListAliasesOutput{Aliases: f.aliased}, nil
+	}
+	return &awslambda.ListAliasesOutput{Aliases: []lambdatypes.AliasConfiguration{
+		{Name: aws.String("live"), FunctionVersion: aws.String("7")},
+	}}, nil
+}
+
+func (f *fakeLambda) GetFunctionUrlConfig(ctx context.Context, params *awslambda.GetFunctionUrlConfigInput, optFns ...func(*awslambda.Options)) (*awslambda.GetFunctionUrlConfigOutput, error) {
+	return &awslambda.GetFunctionUrlConfigOutput{AuthType: lambdatypes.FunctionUrlAuthTypeNone, InvokeMode: lambdatypes.InvokeModeBuffered}, nil
+}
+
+func (f *fakeLambda) ListEventSourceMappings(ctx context.Context, params *awslambda.ListEventSourceMappingsInput, optFns ...func(*awslambda.Options)) (*awslambda.ListEventSourceMappingsOutput, error) {
+	// The same mapping is listed for the function and for its alias
+	return &awslambda.ListEventSourceMappingsOutput{EventSourceMappings: []lambdatypes.EventSourceMappingConfiguration{{
+		UUID:           aws.String("1234"),
+		EventSourceArn: aws.String("arn:aws:sqs:us-west-2:111111111111:jobs"),
+		FunctionArn:    aws.String(functionARN + ":live"),
+		BatchSize:      aws.Int32(10),
+		State:          aws.String("Enabled"),
+	}}}, nil
+}
+
+func (f *fakeLambda) CreateFunction(ctx context.Context, params *awslambda.CreateFunctionInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateFunctionOutput, error) {
+	f.created = params
+	arn := "arn:aws:lambda:eu-west-1:222222222222:function:" + aws.ToString(params.FunctionName)
+	f.function = &awslambda.GetFunctionOutput{Configuration: &lambdatypes.FunctionConfiguration{
+		FunctionArn:      aws.String(arn),
+		State:            lambdatypes.StateActive,
+		LastUpdateStatus: lambdatypes.LastUpdateStatusSuccessful,
+	}}
+	return &awslambda.CreateFunctionOutput{FunctionArn: aws.String(arn)}, nil
+}
+
+func (f *fakeLambda) PutFunctionConcurrency(ctx context.Context, params *awslambda.PutFunctionConcurrencyInput, optFns ...func(*awslambda.Options)) (*awslambda.PutFunctionConcurrencyOutput, error) {
+	return &awslambda.PutFunctionConcurrencyOutput{}, nil
+}
+
+func (f *fakeLambda) PublishVersion(ctx context.Context, params *awslambda.PublishVersionInput, optFns ...func(*awslambda.Options)) (*awslambda.PublishVersionOutput, error) {
+	version := strconv.Itoa(len(f.published) + 1)
+	f.published = append(f.published, aws.ToString(params.Description))
+	return &awslambda.PublishVersionOutput{Version: aws.String(version)}, nil
+}
+
+func (f *fakeLambda) UpdateFunctionCode(ctx context.Context, params *awslambda.UpdateFunctionCodeInput, optFns ...func(*awslambda.Options)) (*awslambda.UpdateFunctionCodeOutput, error) {
+	f.images = append(f.images, aws.ToString(params.ImageUri))
+	return &awslambda.UpdateFunctionCodeOutput{}, nil
+}
+
+func (f *fakeLambda) UpdateFunctionConfiguration(ctx context.Context, params *awslambda.UpdateFunctionConfigurationInput, optFns ...func(*awslambda.Options)) (*awslambda.UpdateFunctionConfigurationOutput, error) {
+	f.envs = append(f.envs, params.Environment.Variables)
+	return &awslambda.UpdateFunctionConfigurationOutput{}, nil
+}
+
+func (f *fakeLambda) CreateAlias(ctx context.Context, params *awslambda.CreateAliasInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateAliasOutput, error) {
+	f.aliases = append(f.aliases, params)
+	return &awslambda.CreateAliasOutput{}, nil
+}
+
+func (f *fakeLambda) AddPermission(ctx context.Context, params *awslambda.AddPermissionInput, optFns ...func(*awslambda.Options)) (*awslambda.AddPermissionOutput, error) {
+	f.permissions = append(f.permissions, params)
+	return &awslambda.AddPermissionOutput{}, nil
+}
+
+func (f *fakeLambda) CreateFunctionUrlConfig(ctx context.Context, params *awslambda.CreateFunctionUrlConfigInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateFunctionUrlConfigOutput, error) {
+	return &awslambda.CreateFunctionUrlConfigOutput{FunctionUrl: aws.String("https://abc.lambda-url.eu-west-1.on.aws/")}, nil
+}
+
+func (f *fakeLambda) CreateEventSourceMapping(ctx context.Context, params *awslambda.CreateEventSourceMappingInput, optFns ...func(*awslambda.Options)) (*awslambda.CreateEventSourceMappingOutput, error) {
+	f.mappings = append(f.mappings, params)
+	return &awslambda.CreateEventSourceMappingOutput{}, nil
+}
+
+// fakeEvents has one schedule rule that targets the live alias.
+type fakeEvents struct {
+	EventsAPI
+	targets []ebtypes.Target
+}
+
+func (f *fakeEvents) ListRuleNamesByTarget(ctx context.Context, params *eventbridge.ListRuleNamesByTargetInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListRuleNamesByTargetOutput, error) {
+	if aws.ToString(params.TargetArn) == functionARN+":live" {
+		return &eventbridge.ListRuleNamesByTargetOutput{RuleNames: []string{"hello-world-schedule"}}, nil
+	}
+	return &eventbridge.ListRuleNamesByTargetOutput{}, nil
+}
+
+func (f *fakeEvents) DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error) {
+	return &eventbridge.DescribeRuleOutput{ScheduleExpression: aws.String("rate(5 minutes)"), State: ebtypes.RuleStateEnabled}, nil
+}
+
+func (f *fakeEvents) ListTargetsByRule(ctx context.Context, params *eventbridge.ListTargetsByRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListTargetsByRuleOutput, error) {
+	return &eventbridge.ListTargetsByRuleOutput{Targets: []ebtypes.Target{
+		{Id: aws.String("hello-world"), Arn: aws.String(functionARN + ":live"), Input: aws.String(`{"source":"lambda-template"}`)},
+	}}, nil
+}
+
+func (f *fakeEvents) PutRule(ctx context.Context, params *eventbridge.PutRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutRuleOutput, error) {
+	return &eventbridge.PutRuleOutput{}, nil
+}
+
+func (f *fakeEvents) PutTargets(ctx context.Context, params *eventbridge.PutTargetsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutTargetsOutput, error) {
+	f.targets = append(f.targets, params.Targets...)
+	return &eventbridge.PutTargetsOutput{}, nil
+}
+
+func deployedFunction() *awslambda.GetFunctionOutput {
+	return &awslambda.GetFunctionOutput{
+		Configuration: &lambdatypes.FunctionConfiguration{
+			FunctionName:  aws.String("hello-world"),
+			FunctionArn:   aws.String(functionARN),
+			PackageType:   lambdatypes.PackageTypeImage,
+			Role:          aws.String("arn:aws:iam::111111111111:role/hello-world-role"),
+			MemorySize:    aws.Int32(256),
+			Timeout:       aws.Int32(30),
+			Architectures: []lambdatypes.Architecture{lambdatypes.ArchitectureArm64},
+			Environment:   &lambdatypes.EnvironmentResponse{Variables: map[string]string{"LOG_LEVEL": "info"}},
+		},
+		Code: &lambdatypes.FunctionCodeLocation{
+			ImageUri:         aws.String("111111111111.dkr.ecr.us-west-2.amazonaws.com/hello-world:latest"),
+			ResolvedImageUri: aws.String("111111111111.dkr.ecr.us-west-2.amazonaws.com/hello-world@sha256:abc"),
+		},
+		Tags: map[string]string{"team": "platform"},
+	}
+}
+
+func TestTake(t *testing.T) {
+	now := time.Date(2024, 7, 1, 3, 0, 0, 0, time.UTC)
+	s, err := Take(context.Background(), Clients{Lambda: &fakeLambda{function: deployedFunction()}, Events: &fakeEvents{}}, "hello-world", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Region != "us-west-2" || s.Account != "111111111111" || s.ImageDigest != "sha256:abc" {
+		t.Errorf("got region %q, account %q, digest %q", s.Region, s.Account, s.ImageDigest)
+	}
+	if !reflect.DeepEqual(s.Architectures, []string{"arm64"}) || s.Environment["LOG_LEVEL"] != "info" {
+		t.Errorf("got architectures %v, environment %v", s.Architectures, s.Environment)
+	}
+	if len(s.Permissions) != 2 || len(s.Aliases) != 1 || s.FunctionURL == nil {
+		t.Errorf("got %d permissions, %d aliases, function URL %v", len(s.Permissions), len(s.Aliases), s.FunctionURL)
+	}
+	if len(s.EventSourceMappings) != 1 || s.EventSourceMappings[0].Qualifier != "live" {
+		t.Errorf("the mapping listed for the function and its alias should be taken once, for the alias: %+v", s.EventSourceMappings)
+	}
+	want := []Rule{{Name: "hello-world-schedule", ScheduleExpression: "rate(5 minutes)", Enabled: true, Qualifier: "live", TargetID: "hello-world", Input: `{"source":"lambda-template"}`}}
+	if !reflect.DeepEqual(s.Rules, want) {
+		t.Errorf("got rules %+v, want %+v", s.Rules, want)
+	}
+}
+
+// publishedVersion is a version of the deployed function, with its own image
+// and environment.
+func publishedVersion(digest, logLevel string) *awslambda.GetFunctionOutput {
+	return &awslambda.GetFunctionOutput{
+		Configuration: &lambdatypes.FunctionConfiguration{
+			Environment: &lambdatypes.EnvironmentResponse{Variables: map[string]string{"LOG_LEVEL": logLevel}},
+		},
+		Code: &lambdatypes.FunctionCodeLocation{
+			ResolvedImageUri: aws.String("111111111111.dkr.ecr.us-west-2.amazonaws.com/hello-world@" + digest),
+		},
+	}
+}
+
+func TestRestoreEachAliasVersion(t *testing.T) {
+	ctx := context.Background()
+	taken := &fakeLambda{
+		function: deployedFunction(),
+		aliased: []lambdatypes.AliasConfiguration{
+			{Name: aws.String("staging"), FunctionVersion: aws.String("12")},
+			{Name: aws.String("live"), FunctionVersion: aws.String("7"), RoutingConfig: &lambdatypes.AliasRoutingConfiguration{
+				AdditionalVersionWeights: map[string]float64{"12": 0.1},
+			}},
+		},
+		versions: map[string]*awslambda.GetFunctionOutput{
+			"7":  publishedVersion("sha256:old", "warn"),
+			"12": publishedVersion("sha256:new", "debug"),
+		},
+	}
+	s, err := Take(ctx, Clients{Lambda: taken, Events: &fakeEvents{}}, "hello-world", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Version{
+		{Version: "7", ImageDigest: "sha256:old", Environment: map[string]string{"LOG_LEVEL": "warn"}},
+		{Version: "12", ImageDigest: "sha256:new", Environment: map[string]string{"LOG_LEVEL": "debug"}},
+	}
+	if !reflect.DeepEqual(s.Versions, want) {
+		t.Fatalf("got versions %+v, want %+v", s.Versions, want)
+	}
+
+	lambdaClient := &fakeLambda{}
+	if err := Restore(ctx, Clients{Lambda: lambdaClient, Events: &fakeEvents{}}, s, Target{Region: "us-west-2", Account: "111111111111"}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	repository := "111111111111.dkr.ecr.us-west-2.amazonaws.com/hello-world@"
+	wantImages := []string{repository + "sha256:old", repository + "sha256:new", repository + "sha256:abc"}
+	if !reflect.DeepEqual(lambdaClient.images, wantImages) {
+		t.Errorf("got images %v, want each version's and then the function's %v", lambdaClient.images, wantImages)
+	}
+	if len(lambdaClient.envs) != 3 || lambdaClient.envs[2]["LOG_LEVEL"] != "info" {
+		t.Errorf("$LATEST should get its own environment back: %v", lambdaClient.envs)
+	}
+
+	aliases := map[string]*awslambda.CreateAliasInput{}
+	for _, alias := range lambdaClient.aliases {
+		aliases[aws.ToString(alias.Name)] = alias
+	}
+	if got := aws.ToString(aliases["staging"].FunctionVersion); got != "2" {
+		t.Errorf("staging should point at version 12 published again as 2, got %s", got)
+	}
+	live := aliases["live"]
+	if aws.ToString(live.FunctionVersion) != "1" || live.RoutingConfig == nil ||
+		!reflect.DeepEqual(live.RoutingConfig.AdditionalVersionWeights, map[string]float64{"2": 0.1}) {
+		t.Errorf("live should point at 1 with its canary on 2: %+v", live)
+	}
+}
+
+func TestTakeRefusesZipFunctions(t *testing.T) {
+	function := deployedFunction()
+	function.Configuration.PackageType = lambdatypes.PackageTypeZip
+	_, err := Take(context.Background(), Clients{Lambda: &fakeLambda{function: function}, Events: &fakeEvents{}}, "hello-world", time.Now())
+	if err == nil {
+		t.Fatal("expected an error for a zip function")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	permissions, err := parsePolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Permission{
+		{
+			StatementID: "hello-world-schedule",
+			Action:      "lambda:InvokeFunction",
+			Principal:   "events.amazonaws.com",
+			SourceARN:   "arn:aws:events:us-west-2:111111111111:rule/hello-world-schedule",
+			Qualifier:   "live",
+		},
+		{
+			StatementID:         "FunctionURLAllowPublicAccess",
+			Action:              "lambda:InvokeFunctionUrl",
+			Principal:           "*",
+			FunctionURLAuthType: "NONE",
+		},
+	}
+	if !reflect.DeepEqual(permissions, want) {
+		t.Errorf("got %+v, want %+v", permissions, want)
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	s := &Snapshot{Region: "us-west-2", Account: "111111111111"}
+	target := Target{Region: "eu-west-1", Account: "222222222222"}
+	tests := map[string]string{
+		"111111111111": "222222222222",
+		"arn:aws:iam::111111111111:role/hello-world-role": "arn:aws:iam::222222222222:role/hello-world-role",
+		"arn:aws:sqs:us-west-2:111111111111:jobs":         "arn:aws:sqs:eu-west-1:222222222222:jobs",
+		"arn:aws:sqs:us-east-1:333333333333:shared":       "arn:aws:sqs:us-east-1:333333333333:shared",
+		"arn:aws:events:us-west-2:111111111111:rule/a:b":  "arn:aws:events:eu-west-1:222222222222:rule/a:b",
+		"events.amazonaws.com":                            "events.amazonaws.com",
+		"*":                                               "*",
+	}
+	for arn, want := range tests {
+		if got := relocate(arn, s, target); got != want {
+			t.Errorf("relocate(%q) = %q, want %q", arn, got, want)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	s, err := Take(ctx, Clients{Lambda: &fakeLambda{function: deployedFunction()}, Events: &fakeEvents{}}, "hello-world", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lambdaClient, events := &fakeLambda{}, &fakeEvents{}
+	target := Target{Region: "eu-west-1", Account: "222222222222", Image: "222222222222.dkr.ecr.eu-west-1.amazonaws.com/hello-world@sha256:abc"}
+	if err := Restore(ctx, Clients{Lambda: lambdaClient, Events: events}, s, target, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	created := lambdaClient.created
+	if aws.ToString(created.Role) != "arn:aws:iam::222222222222:role/hello-world-role" {
+		t.Errorf("the role should move to the target account, got %s", aws.ToString(created.Role))
+	}
+	if aws.ToString(created.Code.ImageUri) != target.Image || aws.ToInt32(created.MemorySize) != 256 {
+		t.Errorf("got image %s, memory %d", aws.ToString(created.Code.ImageUri), aws.ToInt32(created.MemorySize))
+	}
+	if len(lambdaClient.aliases) != 1 || aws.ToString(lambdaClient.aliases[0].FunctionVersion) != "1" {
+		t.Errorf("the alias should point at the published version: %+v", lambdaClient.aliases)
+	}
+	if len(lambdaClient.permissions) != 2 || aws.ToString(lambdaClient.permissions[0].SourceArn) != "arn:aws:events:eu-west-1:222222222222:rule/hello-world-schedule" {
+		t.Errorf("the permissions should name the target's rule: %+v", lambdaClient.permissions)
+	}
+	wantARN := "arn:aws:lambda:eu-west-1:222222222222:function:hello-world:live"
+	if len(lambdaClient.mappings) != 1 || aws.ToString(lambdaClient.mappings[0].FunctionName) != wantARN ||
+		aws.ToString(lambdaClient.mappings[0].EventSourceArn) != "arn:aws:sqs:eu-west-1:222222222222:jobs" {
+		t.Errorf("got mappings %+v", lambdaClient.mappings)
+	}
+	if len(events.targets) != 1 || aws.ToString(events.targets[0].Arn) != wantARN {
+		t.Errorf("got rule targets %+v", events.targets)
+	}
+}
+
+func TestRestorePinsImageDigest(t *testing.T) {
+	s := &Snapshot{
+		Function:    "hello-world",
+		Region:      "us-west-2",
+		Account:     "111111111111",
+		Image:       "111111111111.dkr.ecr.us-west-2.amazonaws.com/hello-world:latest",
+		ImageDigest: "sha256:abc",
+		Role:        "arn:aws:iam::111111111111:role/hello-world-role",
+	}
+	lambdaClient := &fakeLambda{}
+	target := Target{Region: "us-west-2", Account: "222222222222"}
+	if err := Restore(context.Background(), Clients{Lambda: lambdaClient, Events: &fakeEvents{}}, s, target, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	want := "222222222222.dkr.ecr.us-west-2.amazonaws.com/hello-world@sha256:abc"
+	if got := aws.ToString(lambdaClient.created.Code.ImageUri); got != want {
+		t.Errorf("got image %s, want %s", got, want)
+	}
+}
+
+func TestRestoreNeedsImageInAnotherRegion(t *testing.T) {
+	s := &Snapshot{Function: "hello-world", Region: "us-west-2", Account: "111111111111"}
+	lambdaClient := &fakeLambda{}
+	err := Restore(context.Background(), Clients{Lambda: lambdaClient, Events: &fakeEvents{}}, s, Target{Region: "eu-west-1"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "eu-west-1") {
+		t.Errorf("expected an error naming the target region, got %v", err)
+	}
+	if lambdaClient.created != nil {
+		t.Error("no function should be created")
+	}
+}
+
+func TestRestoreRefusesExistingFunction(t *testing.T) {
+	s := &Snapshot{Function: "hello-world", Region: "us-west-2", Account: "111111111111"}
+	lambdaClient := &fakeLambda{function: deployedFunction()}
+	err := Restore(context.Background(), Clients{Lambda: lambdaClient, Events: &fakeEvents{}}, s, Target{Region: "us-west-2"}, io.Discard)
+	if err == nil || lambdaClient.created != nil {
+		t.Errorf("expected an error and no function created, got %v", err)
+	}
+}
+
+type fakeS3 struct {
+	S3API
+	keys []string
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	output := &s3.ListObjectsV2Output{}
+	for _, key := range f.keys {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			output.Contents = append(output.Contents, s3types.Object{Key: aws.String(key)})
+		}
+	}
+	return output, nil
+}
+
+func TestListSortsAcrossRegions(t *testing.T) {
+	client := &fakeS3{keys: []string{
+		"snapshots/hello-world/us-west-2/20240702T030000Z.json",
+		"snapshots/hello-world/eu-west-1/20240703T030000Z.json",
+		"snapshots/hello-world/eu-west-1/20240701T030000Z.json",
+		"snapshots/hello-world/README.md",
+		"snapshots/hello-world-green/us-west-2/20240701T030000Z.json",
+	}}
+	keys, err := List(context.Background(), client, "backups", "snapshots/", "hello-world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"snapshots/hello-world/eu-west-1/20240701T030000Z.json",
+		"snapshots/hello-world/us-west-2/20240702T030000Z.json",
+		"snapshots/hello-world/eu-west-1/20240703T030000Z.json",
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("got %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,101 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3API is the part of the S3 client snapshots are stored with.
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// timeFormat names a snapshot object after the time it was taken, so the
+// keys of a function sort oldest first.
+const timeFormat = "20060102T150405Z"
+
+// Key is where a snapshot is stored: a new object for every snapshot, under
+// prefix, the function and its region.
+func Key(prefix string, s *Snapshot) string {
+	return fmt.Sprintf("%s%s/%s/%s.json", prefix, s.Function, s.Region, s.Taken.UTC().Format(timeFormat))
+}
+
+// Save stores the snapshot, encrypted with the bucket's AWS managed KMS key,
+// and returns its key.
+func Save(ctx context.Context, client S3API, bucket, prefix string, s *Snapshot) (string, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding snapshot: %v", err)
+	}
+	key := Key(prefix, s)
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+		// Snapshots hold the function's environment, secrets among it
+		ServerSideEncryption: s3types.ServerSideEncryptionAwsKms,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error storing snapshot in s3://%s/%s: %v", bucket, key, err)
+	}
+	return key, nil
+}
+
+// List returns the keys of the function's snapshots in every region, oldest
+// first.
+func List(ctx context.Context, client S3API, bucket, prefix, function string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix + function + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing snapshots in s3://%s/%s%s/: %v", bucket, prefix, function, err)
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if _, err := time.Parse(timeFormat, strings.TrimSuffix(path.Base(key), ".json")); err == nil {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return path.Base(keys[i]) < path.Base(keys[j]) })
+	return keys, nil
+}
+
+// Load reads a stored snapshot.
+func Load(ctx context.Context, client S3API, bucket, key string) (*Snapshot, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("error reading s3://%s/%s: %v", bucket, key, err)
+	}
+	defer output.Body.Close()
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading s3://%s/%s: %v", bucket, key, err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %s: %v", key, err)
+	}
+	if s.Format > Format {
+		return nil, fmt.Errorf("snapshot %s has format %d; this build reads up to %d", key, s.Format, Format)
+	}
+	return &s, nil
+}
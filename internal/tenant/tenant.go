@@ -0,0 +1,239 @@
+// Package tenant extracts the tenant ID from incoming events, carries it in
+// the request context and tags logs and metrics with it. It can optionally
+// cap the concurrency and request rate of each tenant within an execution
+// environment.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"golang.org/x/time/rate"
+
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/metrics"
+	"example-lambda-go/internal/middleware"
+)
+
+const metricsNamespace = "LambdaTemplate/Tenants"
+
+var (
+	ErrMissingTenant = errors.New("tenant ID not found in event")
+	ErrThrottled     = errors.New("tenant request limit exceeded")
+)
+
+type Config struct {
+	// Path is a dot-separated path into the event JSON, e.g. "detail.tenantId".
+	Path string
+	// Claim is a JWT claim name looked up in the API Gateway authorizer context.
+	// It is used when Path is empty.
+	Claim string
+	// Required rejects events without a tenant ID.
+	Required bool
+	// MaxConcurrency caps in-flight invocations per tenant; 0 disables the cap.
+	MaxConcurrency int
+	// RateLimit is the sustained requests per second allowed per tenant; 0
+	// disables rate limiting. Burst defaults to the rate rounded up.
+	RateLimit float64
+	Burst     int
+}
+
+// ConfigFromEnv reads the TENANT_* variables that setup and deploy derive
+// from the tenant section of config.yaml.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Path:     os.Getenv("TENANT_PATH"),
+		Claim:    os.Getenv("TENANT_CLAIM"),
+		Required: os.Getenv("TENANT_REQUIRED") == "true",
+	}
+	cfg.MaxConcurrency, _ = strconv.Atoi(os.Getenv("TENANT_MAX_CONCURRENCY"))
+	cfg.RateLimit, _ = strconv.ParseFloat(os.Getenv("TENANT_RATE_LIMIT"), 64)
+	cfg.Burst, _ = strconv.Atoi(os.Getenv("TENANT_BURST"))
+	return cfg
+}
+
+// Enabled reports whether a tenant lookup is configured.
+func (c Config) Enabled() bool {
+	return c.Path != "" || c.Claim != ""
+}
+
+type tenantKey struct{}
+
+// FromContext returns the tenant ID stored by the middleware.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok
+}
+
+// WithTenant stores the tenant ID in ctx and tags the context logger and
+// metrics with it.
+func WithTenant(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, tenantKey{}, id)
+	ctx = logging.With(ctx, "tenant_id", id)
+	return metrics.WithDimension(ctx, "TenantId", id)
+}
+
+// claimPaths are the authorizer locations used by HTTP APIs (JWT authorizer)
+// and REST APIs (Cognito authorizer) respectively.
+var claimPaths = []string{
+	"requestContext.authorizer.jwt.claims.",
+	"requestContext.authorizer.claims.",
+}
+
+// Extract looks up the tenant ID in a raw event payload.
+func (c Config) Extract(payload []byte) (string, bool) {
+	var event interface{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return "", false
+	}
+
+	if c.Path != "" {
+		return lookup(event, c.Path)
+	}
+	for _, prefix := range claimPaths {
+		if id, ok := lookup(event, prefix+c.Claim); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func lookup(value interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// idleAfter is how long a tenant goes without invocations before its
+// limits are dropped. By then its rate limiter has refilled, so a tenant
+// that comes back starts as it would have anyway.
+const idleAfter = 10 * time.Minute
+
+// limits holds the per-tenant semaphores and rate limiters. Limits apply per
+// execution environment, so the effective account-wide limit scales with the
+// number of concurrent environments.
+type limits struct {
+	cfg       Config
+	mu        sync.Mutex
+	tenants   map[string]*tenantLimits
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tenantLimits struct {
+	slots    chan struct{}
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newLimits(cfg Config) *limits {
+	return &limits{cfg: cfg, tenants: map[string]*tenantLimits{}, now: time.Now}
+}
+
+// acquire takes a concurrency slot and then a rate token, so a request
+// turned away for concurrency does not use up the tenant's rate.
+func (l *limits) acquire(id string) (release func(), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	t, exists := l.tenants[id]
+	if !exists {
+		t = &tenantLimits{}
+		if l.cfg.MaxConcurrency > 0 {
+			t.slots = make(chan struct{}, l.cfg.MaxConcurrency)
+		}
+		if l.cfg.RateLimit > 0 {
+			burst := l.cfg.Burst
+			if burst <= 0 {
+				burst = int(l.cfg.RateLimit + 0.999)
+			}
+			t.limiter = rate.NewLimiter(rate.Limit(l.cfg.RateLimit), burst)
+		}
+		l.tenants[id] = t
+	}
+	t.lastUsed = now
+
+	release = func() {}
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+			release = func() { <-t.slots }
+		default:
+			return nil, false
+		}
+	}
+	if t.limiter != nil && !t.limiter.AllowN(now, 1) {
+		release()
+		return nil, false
+	}
+	return release, true
+}
+
+// sweep drops the tenants idle for idleAfter with nothing in flight, at
+// most once per idleAfter.
+func (l *limits) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleAfter {
+		return
+	}
+	l.lastSweep = now
+	for id, t := range l.tenants {
+		if now.Sub(t.lastUsed) >= idleAfter && len(t.slots) == 0 {
+			delete(l.tenants, id)
+		}
+	}
+}
+
+// Middleware resolves the tenant for every invocation, records a per-tenant
+// invocation metric and enforces the configured limits.
+func Middleware(cfg Config) middleware.Middleware {
+	l := newLimits(cfg)
+
+	return func(next lambda.Handler) lambda.Handler {
+		return middleware.HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			id, ok := cfg.Extract(payload)
+			if !ok {
+				if cfg.Required {
+					return nil, ErrMissingTenant
+				}
+				return next.Invoke(ctx, payload)
+			}
+
+			ctx = WithTenant(ctx, id)
+			release, ok := l.acquire(id)
+			if !ok {
+				metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "TenantThrottles", Value: 1, Unit: metrics.Count})
+				logging.FromContext(ctx).Warn("tenant throttled")
+				return nil, fmt.Errorf("%w: %s", ErrThrottled, id)
+			}
+			defer release()
+
+			metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "TenantInvocations", Value: 1, Unit: metrics.Count})
+			return next.Invoke(ctx, payload)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package tenant
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	payload := []byte(`{"detail":{"tenantId":"acme"},"requestContext":{"authorizer":{"jwt":{"claims":{"org":42}}}}}`)
+	if id, ok := (Config{Path: "detail.tenantId"}).Extract(payload); !ok || id != "acme" {
+		t.Errorf("Path: got %q, %v", id, ok)
+	}
+	if id, ok := (Config{Claim: "org"}).Extract(payload); !ok || id != "42" {
+		t.Errorf("Claim: got %q, %v", id, ok)
+	}
+	if _, ok := (Config{Path: "detail.missing"}).Extract(payload); ok {
+		t.Error("missing path found a tenant")
+	}
+}
+
+func TestConcurrencyRejectionKeepsRate(t *testing.T) {
+	l := newLimits(Config{MaxConcurrency: 1, RateLimit: 1, Burst: 2})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	release, ok := l.acquire("acme")
+	if !ok {
+		t.Fatal("first request refused")
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := l.acquire("acme"); ok {
+			t.Fatal("second concurrent request allowed")
+		}
+	}
+	release()
+	// The refused requests took no tokens, so the burst's second one is left
+	if _, ok := l.acquire("acme"); !ok {
+		t.Error("request after release refused; concurrency rejections used up the rate")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	l := newLimits(Config{RateLimit: 1})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if _, ok := l.acquire("acme"); !ok {
+		t.Fatal("first request refused")
+	}
+	if _, ok := l.acquire("acme"); ok {
+		t.Error("second request within the second allowed")
+	}
+	if _, ok := l.acquire("globex"); !ok {
+		t.Error("another tenant shares the limit")
+	}
+	now = now.Add(time.Second)
+	if _, ok := l.acquire("acme"); !ok {
+		t.Error("request a second later refused")
+	}
+}
+
+func TestIdleTenantsAreEvicted(t *testing.T) {
+	l := newLimits(Config{MaxConcurrency: 1})
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	release, _ := l.acquire("busy")
+	idle, _ := l.acquire("idle")
+	idle()
+	now = now.Add(idleAfter)
+	l.acquire("new")
+
+	if _, ok := l.tenants["idle"]; ok {
+		t.Error("idle tenant kept")
+	}
+	if _, ok := l.tenants["busy"]; !ok {
+		t.Error("tenant with a request in flight evicted")
+	}
+	release()
+}
@@ -0,0 +1,9 @@
+//go:build tools
+
+// Package tools pins the code generators run by go generate, so they are
+// versioned in go.mod like any other dependency.
+package tools
+
+import (
+	_ "go.uber.org/mock/mockgen"
+)
@@ -0,0 +1,177 @@
+// Package triggers finds what invokes a function on its own, its event source
+// mappings (SQS queues, Kinesis and DynamoDB streams) and the scheduled
+// EventBridge rules targeting it, and switches them off and on again.
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// LiveTag records on the blue function which of a blue/green pair currently
+// has the triggers; see internal/cli/deploy.
+const LiveTag = "lambda-template:live"
+
+type Kind string
+
+const (
+	EventSourceMapping Kind = "event source mapping"
+	Schedule           Kind = "schedule"
+)
+
+type Trigger struct {
+	Kind Kind
+	// ID is the mapping UUID or the rule name
+	ID string
+	// Source is the queue or stream ARN, or the schedule expression
+	Source  string
+	Enabled bool
+}
+
+type LambdaAPI interface {
+	lambda.ListEventSourceMappingsAPIClient
+	GetFunction(ctx context.Context, params *lambda.GetFunctionInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionOutput, error)
+	UpdateEventSourceMapping(ctx context.Context, params *lambda.UpdateEventSourceMappingInput, optFns ...func(*lambda.Options)) (*lambda.UpdateEventSourceMappingOutput, error)
+}
+
+type EventsAPI interface {
+	ListRuleNamesByTarget(ctx context.Context, params *eventbridge.ListRuleNamesByTargetInput, optFns ...func(*eventbridge.Options)) (*eventbridge.ListRuleNamesByTargetOutput, error)
+	DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error)
+	EnableRule(ctx context.Context, params *eventbridge.EnableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.EnableRuleOutput, error)
+	DisableRule(ctx context.Context, params *eventbridge.DisableRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DisableRuleOutput, error)
+}
+
+// List returns the triggers of the function. Rules that match events rather
+// than run on a schedule are left out; pausing those would drop events.
+func List(ctx context.Context, lambdaClient LambdaAPI, eventsClient EventsAPI, functionARN string) ([]Trigger, error) {
+	var triggers []Trigger
+
+	paginator := lambda.NewListEventSourceMappingsPaginator(lambdaClient, &lambda.ListEventSourceMappingsInput{
+		FunctionName: aws.String(functionARN),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing event source mappings: %v", err)
+		}
+		for _, mapping := range page.EventSourceMappings {
+			state := aws.ToString(mapping.State)
+			triggers = append(triggers, Trigger{
+				Kind:    EventSourceMapping,
+				ID:      aws.ToString(mapping.UUID),
+				Source:  aws.ToString(mapping.EventSourceArn),
+				Enabled: state == "Enabled" || state == "Enabling",
+			})
+		}
+	}
+
+	var token *string
+	for {
+		output, err := eventsClient.ListRuleNamesByTarget(ctx, &eventbridge.ListRuleNamesByTargetInput{
+			TargetArn: aws.String(functionARN),
+			NextToken: token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing rules: %v", err)
+		}
+		for _, name := range output.RuleNames {
+			rule, err := eventsClient.DescribeRule(ctx, &eventbridge.DescribeRuleInput{Name: aws.String(name)})
+			if err != nil {
+				return nil, fmt.Errorf("error describing rule %s: %v", name, err)
+			}
+			if aws.ToString(rule.ScheduleExpression) == "" {
+				continue
+			}
+			triggers = append(triggers, Trigger{
+				Kind:    Schedule,
+				ID:      name,
+				Source:  aws.ToString(rule.ScheduleExpression),
+				Enabled: rule.State != eventbridgetypes.RuleStateDisabled,
+			})
+		}
+		if output.NextToken == nil {
+			break
+		}
+		token = output.NextToken
+	}
+
+	return triggers, nil
+}
+
+// SetEnabled enables or disables a trigger. A mapping keeps its position in
+// the queue or stream while disabled, so nothing is lost; SQS messages wait
+// in the queue until their retention period runs out.
+func SetEnabled(ctx context.Context, lambdaClient LambdaAPI, eventsClient EventsAPI, trigger Trigger, enabled bool) error {
+	var err error
+	switch trigger.Kind {
+	case EventSourceMapping:
+		_, err = lambdaClient.UpdateEventSourceMapping(ctx, &lambda.UpdateEventSourceMappingInput{
+			UUID:    aws.String(trigger.ID),
+			Enabled: aws.Bool(enabled),
+		})
+	case Schedule:
+		if enabled {
+			_, err = eventsClient.EnableRule(ctx, &eventbridge.EnableRuleInput{Name: aws.String(trigger.ID)})
+		} else {
+			_, err = eventsClient.DisableRule(ctx, &eventbridge.DisableRuleInput{Name: aws.String(trigger.ID)})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error updating %s %s: %v", trigger.Kind, trigger.ID, err)
+	}
+	return nil
+}
+
+// State summarises the triggers as "active", "paused" or, when only some
+// are disabled, "partly paused".
+func State(triggers []Trigger) string {
+	if len(triggers) == 0 {
+		return "none"
+	}
+	enabled := 0
+	for _, trigger := range triggers {
+		if trigger.Enabled {
+			enabled++
+		}
+	}
+	switch enabled {
+	case len(triggers):
+		return "active"
+	case 0:
+		return "paused"
+	}
+	return fmt.Sprintf("partly paused (%d of %d disabled)", len(triggers)-enabled, len(triggers))
+}
+
+// LiveFunctionARN returns the ARN of the function that has the triggers:
+// the configured one, or its -green twin after a blue/green deploy moved them.
+func LiveFunctionARN(ctx context.Context, lambdaClient LambdaAPI, functionName string) (string, error) {
+	function, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(functionName)})
+	if err != nil {
+		return "", fmt.Errorf("error getting function %s: %v", functionName, err)
+	}
+	if function.Tags[LiveTag] != "green" {
+		return aws.ToString(function.Configuration.FunctionArn), nil
+	}
+
+	green := functionName + "-green"
+	function, err = lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(green)})
+	if err != nil {
+		return "", fmt.Errorf("error getting function %s: %v", green, err)
+	}
+	return aws.ToString(function.Configuration.FunctionArn), nil
+}
+
+// AliasARN returns the ARN the triggers of functionARN invoke: the one of
+// alias, deploy.alias, when it is set, as setup then points them at it.
+func AliasARN(functionARN, alias string) string {
+	if alias == "" {
+		return functionARN
+	}
+	return functionARN + ":" + alias
+}
@@ -0,0 +1,196 @@
+// Package worker runs typed background jobs delivered through SQS. Handlers
+// are registered per job type with Handle; the worker decodes each message
+// into the registered type, skips duplicates, and on failure delays the
+// retry with exponential backoff by extending the message's visibility
+// timeout. After the queue's maxReceiveCount the message moves to the DLQ.
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"example-lambda-go/contract"
+	"example-lambda-go/internal/cache"
+	"example-lambda-go/internal/logging"
+	"example-lambda-go/internal/metrics"
+	"example-lambda-go/internal/partition"
+)
+
+const metricsNamespace = "LambdaTemplate/Worker"
+
+// VisibilityAPI is the part of the SQS client used to delay retries.
+type VisibilityAPI interface {
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+type Policy struct {
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DedupTTL is how long completed job IDs are remembered
+	DedupTTL time.Duration
+}
+
+// PolicyFromEnv reads the WORKER_* variables set by setup and deploy.
+func PolicyFromEnv() Policy {
+	policy := Policy{BackoffBase: 10 * time.Second, BackoffMax: 15 * time.Minute, DedupTTL: time.Hour}
+	if d, err := time.ParseDuration(os.Getenv("WORKER_BACKOFF_BASE")); err == nil {
+		policy.BackoffBase = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKER_BACKOFF_MAX")); err == nil {
+		policy.BackoffMax = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("WORKER_DEDUP_TTL")); err == nil {
+		policy.DedupTTL = d
+	}
+	return policy
+}
+
+type Worker struct {
+	api      VisibilityAPI
+	policy   Policy
+	seen     *cache.Cache
+	mu       sync.RWMutex
+	handlers map[string]func(ctx context.Context, payload json.RawMessage) error
+}
+
+// New returns a worker. seen remembers completed job IDs; pass nil to
+// disable deduplication (FIFO queues deduplicate on their own).
+func New(api VisibilityAPI, policy Policy, seen *cache.Cache) *Worker {
+	return &Worker{api: api, policy: policy, seen: seen, handlers: map[string]func(context.Context, json.RawMessage) error{}}
+}
+
+// Handle registers the handler for jobs of the given type.
+func Handle[T any](w *Worker, jobType string, handler func(ctx context.Context, job T) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = func(ctx context.Context, payload json.RawMessage) error {
+		var job T
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return fmt.Errorf("error decoding %s job: %v", jobType, err)
+		}
+		return handler(ctx, job)
+	}
+}
+
+// JobTypes returns the job types with a handler, sorted.
+func (w *Worker) JobTypes() []string {
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Invoke handles an SQS batch and reports failed records as partial batch
+// failures. On FIFO queues everything after the first failure is failed too
+// so jobs in a group keep their order.
+func (w *Worker) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var event events.SQSEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("error decoding SQS event: %v", err)
+	}
+
+	var response events.SQSEventResponse
+	failing := false
+	for _, record := range event.Records {
+		if failing {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+			continue
+		}
+		if err := w.process(ctx, record); err != nil {
+			logging.FromContext(ctx).Error("job failed", "message_id", record.MessageId, "error", err)
+			w.delayRetry(ctx, record)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+			failing = strings.HasSuffix(record.EventSourceARN, ".fifo")
+		}
+	}
+	return json.Marshal(response)
+}
+
+func (w *Worker) process(ctx context.Context, record events.SQSMessage) error {
+	var job contract.Job
+	if err := json.Unmarshal([]byte(record.Body), &job); err != nil {
+		return fmt.Errorf("error decoding job: %v", err)
+	}
+	ctx = logging.With(ctx, "job_type", job.Type, "job_id", job.ID)
+	ctx = metrics.WithDimension(ctx, "JobType", job.Type)
+
+	if job.ID != "" && w.seen != nil {
+		if _, ok, _ := w.seen.Get(ctx, "job:"+job.ID); ok {
+			logging.FromContext(ctx).Info("skipping duplicate job")
+			metrics.Emit(ctx, metricsNamespace, nil, metrics.Metric{Name: "Duplicates", Value: 1, Unit: metrics.Count})
+			return nil
+		}
+	}
+
+	w.mu.RLock()
+	handler, ok := w.handlers[job.Type]
+	w.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+
+	started := time.Now()
+	err := handler(ctx, job.Payload)
+	failed := 0.0
+	if err != nil {
+		failed = 1
+	}
+	metrics.Emit(ctx, metricsNamespace, nil,
+		metrics.Metric{Name: "Jobs", Value: 1, Unit: metrics.Count},
+		metrics.Metric{Name: "Failures", Value: failed, Unit: metrics.Count},
+		metrics.Metric{Name: "Duration", Value: float64(time.Since(started).Milliseconds()), Unit: metrics.Milliseconds},
+	)
+	if err != nil {
+		return err
+	}
+
+	if job.ID != "" && w.seen != nil {
+		w.seen.Set(ctx, "job:"+job.ID, []byte{1}, w.policy.DedupTTL)
+	}
+	return nil
+}
+
+// delayRetry hides the message for BackoffBase * 2^(receives-1), capped at
+// BackoffMax, instead of the queue's fixed visibility timeout.
+func (w *Worker) delayRetry(ctx context.Context, record events.SQSMessage) {
+	if w.api == nil {
+		return
+	}
+	receives := 1
+	fmt.Sscan(record.Attributes["ApproximateReceiveCount"], &receives)
+	delay := w.policy.BackoffBase
+	for i := 1; i < receives && delay < w.policy.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.policy.BackoffMax, 12*time.Hour)
+
+	_, err := w.api.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(queueURL(record.EventSourceARN)),
+		ReceiptHandle:     aws.String(record.ReceiptHandle),
+		VisibilityTimeout: int32(delay / time.Second),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to delay job retry", "error", err)
+	}
+}
+
+// queueURL derives the queue URL from arn:partition:sqs:region:account:name.
+func queueURL(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 {
+		return ""
+	}
+	return fmt.Sprintf("https://%s/%s/%s", partition.ForRegion(parts[3]).Host("sqs", parts[3]), parts[4], parts[5])
+}